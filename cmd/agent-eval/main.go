@@ -0,0 +1,120 @@
+// agent-eval 运行 YAML 评测场景，检查提示词或模型调整后 agent 的行为
+//
+// 用法：
+//
+//	go run ./cmd/agent-eval                                  # 使用 .env 中的 LLM 配置在线运行 eval/scenarios
+//	go run ./cmd/agent-eval -record eval/fixtures            # 在线运行并录制模型输出
+//	go run ./cmd/agent-eval -replay eval/fixtures            # 用录制的输出离线回放
+//	go run ./cmd/agent-eval -run 完成 eval/scenarios/a.yaml   # 只运行名称匹配的场景
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+
+	"assistant-qisumi/internal/config"
+	"assistant-qisumi/internal/eval"
+	"assistant-qisumi/internal/llm"
+)
+
+func main() {
+	replayDir := flag.String("replay", "", "用该目录下录制的模型输出离线回放")
+	recordDir := flag.String("record", "", "在线运行并把模型输出录制到该目录")
+	runPattern := flag.String("run", "", "只运行名称匹配该正则的场景")
+	verbose := flag.Bool("v", false, "输出通过场景的回复和 patch")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: agent-eval [flags] [scenario files or directories]\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *replayDir != "" && *recordDir != "" {
+		fmt.Fprintln(os.Stderr, "-replay and -record cannot be used together")
+		os.Exit(2)
+	}
+
+	paths := flag.Args()
+	if len(paths) == 0 {
+		paths = []string{"eval/scenarios"}
+	}
+	scenarios, err := eval.LoadScenarios(paths...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "load scenarios: %v\n", err)
+		os.Exit(2)
+	}
+	if *runPattern != "" {
+		re, err := regexp.Compile(*runPattern)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -run pattern: %v\n", err)
+			os.Exit(2)
+		}
+		filtered := scenarios[:0]
+		for _, sc := range scenarios {
+			if re.MatchString(sc.Name) {
+				filtered = append(filtered, sc)
+			}
+		}
+		scenarios = filtered
+	}
+
+	client, cfg, err := newClient(*replayDir, *recordDir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	runner := eval.NewRunner(client, cfg)
+	results := make([]*eval.Result, 0, len(scenarios))
+	for _, sc := range scenarios {
+		results = append(results, runner.Run(context.Background(), sc))
+	}
+	if failed := eval.WriteReport(os.Stdout, results, *verbose); failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// newClient 回放时不需要 API Key；在线运行使用与服务相同的 LLM 配置和容错策略
+func newClient(replayDir, recordDir string) (llm.Client, llm.Config, error) {
+	appCfg, err := config.LoadConfig()
+	if err != nil {
+		return nil, llm.Config{}, fmt.Errorf("load config: %w", err)
+	}
+	c := appCfg.LLM
+	cfg := llm.Config{
+		BaseURL:         c.APIBaseURL,
+		APIKey:          c.APIKey,
+		Model:           c.ModelName,
+		Protocol:        c.Protocol,
+		ThinkingType:    c.ThinkingType,
+		ReasoningEffort: c.ReasoningEffort,
+		EnableThinking:  c.EnableThinking,
+		AssistantName:   c.AssistantName,
+		IsDefault:       true,
+	}
+
+	if replayDir != "" {
+		client, err := llm.NewReplayClient(replayDir)
+		if err != nil {
+			return nil, cfg, fmt.Errorf("load fixtures: %w", err)
+		}
+		return client, cfg, nil
+	}
+
+	if cfg.APIKey == "" && cfg.Protocol != llm.ProtocolOllama {
+		return nil, cfg, fmt.Errorf("LLM_API_KEY is not set; use -replay to run offline")
+	}
+	var base llm.Client = llm.NewHTTPClient().WithMaxRetries(0)
+	if recordDir != "" {
+		base = llm.NewRecordingClient(base, recordDir)
+	}
+	client := llm.NewResilientClient(base, llm.ResilientOptions{
+		MaxAttempts:      c.MaxAttempts,
+		CallTimeout:      c.CallTimeout,
+		FailureThreshold: c.BreakerThreshold,
+		Cooldown:         c.BreakerCooldown,
+	})
+	return client, cfg, nil
+}
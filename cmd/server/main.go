@@ -1,8 +1,12 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"assistant-qisumi/internal/config"
 	"assistant-qisumi/internal/db"
@@ -60,12 +64,20 @@ func main() {
 	// 初始化HTTP服务器
 	server := http.NewServer(cfg.HTTP, cfg.JWT, cfg.Crypto, cfg.LLM, gormDB, nil)
 
+	// 收到 SIGINT / SIGTERM 后停止定时任务并优雅关闭服务器
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// 定时生成每日/每周回顾
+	server.StartDigestJob(ctx, cfg.Digest)
+
 	// 启动服务器
 	logger.Logger.Info("Server starting",
 		zap.String("host", cfg.HTTP.Host),
 		zap.String("port", cfg.HTTP.Port),
 	)
-	if err := server.Start(); err != nil {
+	if err := server.Start(ctx); err != nil {
 		logger.Logger.Fatal("Failed to start server", zap.Error(err))
 	}
+	logger.Logger.Info("Server stopped")
 }
@@ -1,5 +1,5 @@
 import apiClient from './client';
-import type { Session, SessionMessagesResponse, SendMessageResponse } from '@/types';
+import type { Session, SessionType, SessionMessagesResponse, SendMessageResponse } from '@/types';
 
 export const fetchSessionMessages = async (
   sessionId: number | string
@@ -27,3 +27,31 @@ export const getOrCreateGlobalSession = async (): Promise<Session> => {
   const { data } = await apiClient.get<{ session: Session }>('/sessions/global');
   return data.session;
 };
+
+export interface SessionListParams {
+  type?: SessionType;
+  taskId?: number;
+  archived?: boolean;
+}
+
+export const fetchSessions = async (params: SessionListParams = {}): Promise<Session[]> => {
+  const { data } = await apiClient.get<{ sessions: Session[] }>('/sessions', { params });
+  return data.sessions;
+};
+
+export const createSession = async (payload: {
+  type: SessionType;
+  taskId?: number;
+  title?: string;
+}): Promise<Session> => {
+  const { data } = await apiClient.post<{ session: Session }>('/sessions', payload);
+  return data.session;
+};
+
+export const updateSession = async (
+  sessionId: number | string,
+  payload: { title?: string; archived?: boolean }
+): Promise<Session> => {
+  const { data } = await apiClient.patch<{ session: Session }>(`/sessions/${sessionId}`, payload);
+  return data.session;
+};
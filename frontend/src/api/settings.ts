@@ -33,6 +33,65 @@ export async function updateLLMSettings(settings: LLMSettings): Promise<void> {
   await apiClient.post('/settings/llm', settings);
 }
 
+// 可以单独指派 LLM 配置的 agent，default 作用于所有未单独指派的 agent
+export type LLMAgentName = 'default' | 'router' | 'executor' | 'planner' | 'summarizer' | 'global' | 'task_creation' | 'memory';
+
+// 命名 LLM 配置（不含 API Key）
+export type LLMProtocol = 'openai' | 'anthropic' | 'gemini' | 'ollama';
+
+export interface LLMProfile {
+  id: number;
+  name: string;
+  protocol: LLMProtocol;
+  base_url: string;
+  model: string;
+  thinking_type?: ThinkingType;
+  reasoning_effort?: ReasoningEffort;
+  enable_thinking?: boolean;
+  fallback_order: number; // >0 时作为备用提供商，按从小到大的顺序尝试
+  has_api_key: boolean;
+  agents: LLMAgentName[];
+}
+
+export interface LLMProfileRequest {
+  name: string;
+  protocol?: LLMProtocol; // 默认 openai
+  base_url: string;
+  api_key?: string; // 创建时必填（ollama 除外），更新时为空表示不修改
+  model: string;
+  thinking_type?: ThinkingType;
+  reasoning_effort?: ReasoningEffort;
+  enable_thinking?: boolean;
+  fallback_order?: number;
+}
+
+export type LLMAssignments = Partial<Record<LLMAgentName, number>>;
+
+export async function fetchLLMProfiles(): Promise<{ profiles: LLMProfile[]; assignments: LLMAssignments }> {
+  const { data } = await apiClient.get('/settings/llm/profiles');
+  return data;
+}
+
+export async function createLLMProfile(req: LLMProfileRequest): Promise<LLMProfile> {
+  const { data } = await apiClient.post<LLMProfile>('/settings/llm/profiles', req);
+  return data;
+}
+
+export async function updateLLMProfile(id: number, req: LLMProfileRequest): Promise<LLMProfile> {
+  const { data } = await apiClient.put<LLMProfile>(`/settings/llm/profiles/${id}`, req);
+  return data;
+}
+
+export async function deleteLLMProfile(id: number): Promise<void> {
+  await apiClient.delete(`/settings/llm/profiles/${id}`);
+}
+
+// 整体替换指派，0 表示该 agent 不单独指定
+export async function updateLLMAssignments(assignments: LLMAssignments): Promise<LLMAssignments> {
+  const { data } = await apiClient.put<{ assignments: LLMAssignments }>('/settings/llm/profiles/assignments', assignments);
+  return data.assignments;
+}
+
 // Thinking类型中文映射
 export const ThinkingTypeLabels: Record<ThinkingType, string> = {
   [ThinkingType.Disabled]: '不启用',
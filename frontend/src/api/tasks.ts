@@ -17,6 +17,15 @@ export const createTaskFromText = async (rawText: string): Promise<TaskDetailRes
   return JSON.parse(JSON.stringify(data));
 };
 
+// 快速添加：按 "!high #标签 @明天 due:2025-12-01" 和 "- 步骤" 语法解析，enrich 为 true 时用 LLM 补全
+export const quickAddTask = async (
+  text: string,
+  enrich = false
+): Promise<TaskDetailResponse & { enriched: boolean; warning?: string }> => {
+  const { data } = await apiClient.post('/tasks/quick', { text, enrich });
+  return data;
+};
+
 export interface CreateTaskRequest {
   title: string;
   description?: string;
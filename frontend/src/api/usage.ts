@@ -0,0 +1,40 @@
+import apiClient from './client';
+
+export type UsageGroupBy = 'agent' | 'model' | 'day' | 'user';
+
+// 一个分组内的用量合计
+export interface UsageBucket {
+  key: string;
+  calls: number;
+  prompt_tokens: number;
+  completion_tokens: number;
+  reasoning_tokens: number;
+  total_tokens: number;
+  cost: number;
+}
+
+export interface UsageReport {
+  from: string;
+  to: string;
+  group_by: UsageGroupBy;
+  currency: string;
+  items: UsageBucket[];
+  total: UsageBucket;
+}
+
+export interface UsageQuery {
+  from?: string; // YYYY-MM-DD，默认最近 30 天
+  to?: string; // YYYY-MM-DD，当天包含在内
+  group_by?: UsageGroupBy;
+}
+
+export async function fetchUsage(query: UsageQuery = {}): Promise<UsageReport> {
+  const { data } = await apiClient.get<UsageReport>('/usage', { params: query });
+  return data;
+}
+
+// 全站用量，仅管理员可用
+export async function fetchAllUsage(query: UsageQuery & { user_id?: number } = {}): Promise<UsageReport> {
+  const { data } = await apiClient.get<UsageReport>('/admin/usage', { params: query });
+  return data;
+}
@@ -49,6 +49,7 @@ export interface Task {
   priority: TaskPriority;
   isFocusToday?: boolean;
   dueAt?: string | null;
+  tags?: string[];
   createdAt: string;
   updatedAt: string;
   completedAt?: string | null;
@@ -67,6 +68,9 @@ export interface Session {
   userId: number;
   taskId?: number | null;
   type: SessionType;
+  title: string;
+  archivedAt?: string | null;
+  lastMessageAt?: string | null;
   createdAt: string;
 }
 
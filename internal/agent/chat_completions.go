@@ -3,8 +3,11 @@ package agent
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 
+	"assistant-qisumi/internal/jsonschema"
 	"assistant-qisumi/internal/llm"
 	"assistant-qisumi/internal/logger"
 	"assistant-qisumi/internal/task"
@@ -12,85 +15,118 @@ import (
 	"go.uber.org/zap"
 )
 
+const (
+	// maxArgumentRepairRounds 工具参数校验失败后，同一轮对话中允许模型重新调用工具的次数
+	maxArgumentRepairRounds = 1
+	// maxToolRounds 同一轮对话中允许模型调用工具的最大轮数（只读工具查询后可以继续调用工具）
+	maxToolRounds = 5
+)
+
 // ChatCompletionsHandler 处理完整的Chat Completions流程，包括工具调用和结果处理
 type ChatCompletionsHandler struct {
 	llmClient llm.Client
-	toolMap   map[string]ToolExecutor
-}
-
-// ToolExecutor 工具执行器接口
-type ToolExecutor interface {
-	Execute(args string) (interface{}, error)
+	tools     *ToolRegistry
 }
 
 // NewChatCompletionsHandler 创建Chat Completions处理器
-func NewChatCompletionsHandler(llmClient llm.Client, toolMap map[string]ToolExecutor) *ChatCompletionsHandler {
+func NewChatCompletionsHandler(llmClient llm.Client, tools *ToolRegistry) *ChatCompletionsHandler {
 	return &ChatCompletionsHandler{
 		llmClient: llmClient,
-		toolMap:   toolMap,
+		tools:     tools,
 	}
 }
 
-// HandleChatCompletions 处理完整的Chat Completions流程
+// HandleChatCompletions 处理完整的Chat Completions流程，toolNames 为本次允许模型调用的已注册工具
 func (h *ChatCompletionsHandler) HandleChatCompletions(
 	ctx context.Context,
-	cfg llm.Config,
+	req AgentRequest,
 	initialMessages []llm.Message,
-	tools []llm.Tool,
+	toolNames []string,
 ) (string, []TaskPatch, error) {
+	tools, err := h.tools.Definitions(toolNames)
+	if err != nil {
+		return "", nil, err
+	}
+	cfg := req.LLMConfig
 	logger.Logger.Info("ChatCompletionsHandler开始处理",
 		zap.String("model", cfg.Model),
 		zap.Int("messages_count", len(initialMessages)),
 		zap.Int("tools_count", len(tools)),
 	)
 
-	// 1. 初始LLM调用
-	chatReq := llm.ChatRequest{
-		Model:      cfg.Model,
-		Messages:   initialMessages,
-		Tools:      tools,
-		ToolChoice: "auto",
-	}
-
 	if ctx == nil {
 		ctx = context.Background()
 	}
 
-	logger.Logger.Debug("发送初始LLM请求",
-		zap.String("model", cfg.Model),
-		zap.Int("messages_count", len(initialMessages)),
-		zap.Int("tools_count", len(tools)),
-	)
-	resp, err := h.llmClient.Chat(ctx, cfg, chatReq)
-	if err != nil {
-		logger.Logger.Error("初始LLM请求失败",
-			zap.String("error", err.Error()),
-		)
-		return "", nil, err
+	schemas := toolSchemas(tools)
+	allowed := make(map[string]bool, len(toolNames))
+	for _, name := range toolNames {
+		allowed[name] = true
 	}
+	messages := initialMessages
+	toolChoice := "auto"
+	var taskPatches []TaskPatch
+	var lastContent string
+	repairs := 0
+	for round := 0; ; round++ {
+		// 1. 调用LLM；第一轮允许调用工具，之后只在查询过数据或需要修正参数时允许再次调用
+		chatReq := llm.ChatRequest{
+			Model:      cfg.Model,
+			Messages:   messages,
+			Tools:      tools,
+			ToolChoice: toolChoice,
+		}
 
-	if len(resp.Choices) == 0 {
-		logger.Logger.Error("LLM响应无choices")
-		return "", nil, fmt.Errorf("no choices in llm response")
-	}
+		logger.Logger.Debug("发送LLM请求",
+			zap.Int("round", round),
+			zap.String("model", cfg.Model),
+			zap.Int("messages_count", len(messages)),
+			zap.String("tool_choice", toolChoice),
+		)
+		resp, err := h.llmClient.Chat(ctx, cfg, chatReq)
+		if err != nil {
+			logger.Logger.Error("LLM请求失败",
+				zap.Int("round", round),
+				zap.String("error", err.Error()),
+			)
+			return "", nil, err
+		}
 
-	choice := resp.Choices[0]
-	assistantMessage := choice.Message.Content
-	logger.Logger.Debug("收到LLM响应",
-		zap.Int("content_length", len(assistantMessage)),
-		zap.String("finish_reason", choice.FinishReason),
-		zap.String("content", assistantMessage),
-	)
+		if len(resp.Choices) == 0 {
+			if round > 0 {
+				// 工具已执行，最终回复缺失时沿用上一次的内容并保留已生成的 patch
+				return lastContent, taskPatches, nil
+			}
+			logger.Logger.Error("LLM响应无choices")
+			return "", nil, fmt.Errorf("no choices in llm response")
+		}
+
+		choice := resp.Choices[0]
+		assistantMessage := choice.Message.Content
+		lastContent = assistantMessage
+		logger.Logger.Debug("收到LLM响应",
+			zap.Int("content_length", len(assistantMessage)),
+			zap.String("finish_reason", choice.FinishReason),
+			zap.String("content", assistantMessage),
+		)
+
+		// 2. 不需要（或不再允许）工具调用，直接返回LLM回复
+		if len(choice.Message.ToolCalls) == 0 || toolChoice == "none" {
+			logger.Logger.Info("ChatCompletionsHandler处理完成",
+				zap.Int("rounds", round+1),
+				zap.Int("task_patches_count", len(taskPatches)),
+				zap.Int("response_length", len(assistantMessage)),
+			)
+			return assistantMessage, taskPatches, nil
+		}
 
-	// 2. 检查是否需要工具调用
-	if len(choice.Message.ToolCalls) > 0 {
 		logger.Logger.Info("检测到工具调用",
 			zap.Int("tool_calls_count", len(choice.Message.ToolCalls)),
 		)
-		// 3. 处理工具调用
-		var taskPatches []TaskPatch
+		// 3. 处理工具调用：参数不符合工具声明的 Schema 时不执行，把错误作为工具结果交给模型修正
 		var toolResponses []llm.Message
-
+		invalidArgs := false
+		readOnly := false
 		for i, toolCall := range choice.Message.ToolCalls {
 			logger.Logger.Info("执行工具调用",
 				zap.Int("index", i),
@@ -99,8 +135,77 @@ func (h *ChatCompletionsHandler) HandleChatCompletions(
 				zap.String("arguments", toolCall.Function.Arguments),
 			)
 
+			// 只允许调用本次提供给模型的工具，其他工具（包括已注册但未提供的）不解码也不执行
+			if !allowed[toolCall.Function.Name] {
+				logger.Logger.Warn("模型调用了未提供的工具",
+					zap.String("tool_name", toolCall.Function.Name),
+				)
+				invalidArgs = true
+				toolResponses = append(toolResponses, llm.Message{
+					Role:       "tool",
+					Content:    toolUnavailableContent(toolCall.Function.Name, toolNames),
+					ToolCallID: toolCall.ID,
+					Name:       toolCall.Function.Name,
+				})
+				continue
+			}
+
+			if verr := validateToolArgs(schemas, toolCall); verr != nil {
+				logger.Logger.Warn("工具参数校验失败",
+					zap.String("tool_name", toolCall.Function.Name),
+					zap.String("error", verr.Error()),
+				)
+				invalidArgs = true
+				toolResponses = append(toolResponses, llm.Message{
+					Role:       "tool",
+					Content:    toolArgumentErrorContent(toolCall.Function.Name, verr),
+					ToolCallID: toolCall.ID,
+					Name:       toolCall.Function.Name,
+				})
+				continue
+			}
+
+			// 先生成 TaskPatch 并校验其中的任务和步骤属于当前用户，不属于时不执行，交给模型修正
+			// 参数通过了 Schema 校验但仍无法解码（例如数值超出范围）或时间无法理解时同样交给模型修正
+			patches, err := h.tools.TaskPatches(req, toolCall)
+			if err != nil {
+				logger.Logger.Warn("生成TaskPatch失败",
+					zap.String("tool_name", toolCall.Function.Name),
+					zap.String("error", err.Error()),
+				)
+				invalidArgs = true
+				toolResponses = append(toolResponses, llm.Message{
+					Role:       "tool",
+					Content:    toolDecodeErrorContent(toolCall.Function.Name, err),
+					ToolCallID: toolCall.ID,
+					Name:       toolCall.Function.Name,
+				})
+				continue
+			}
+			if err := h.tools.CheckOwnership(ctx, req.UserID, patches); err != nil {
+				if !errors.Is(err, task.ErrNotOwned) {
+					return "", nil, fmt.Errorf("failed to check ownership for tool %s: %w", toolCall.Function.Name, err)
+				}
+				logger.Logger.Warn("工具引用了不属于用户的任务或步骤",
+					zap.String("tool_name", toolCall.Function.Name),
+					zap.String("error", err.Error()),
+				)
+				invalidArgs = true
+				toolResponses = append(toolResponses, llm.Message{
+					Role:       "tool",
+					Content:    toolNotOwnedContent(toolCall.Function.Name, err),
+					ToolCallID: toolCall.ID,
+					Name:       toolCall.Function.Name,
+				})
+				continue
+			}
+
+			if h.tools.IsReadOnly(toolCall.Function.Name) {
+				readOnly = true
+			}
+
 			// 执行工具调用
-			toolResp, err := h.executeToolCall(toolCall)
+			toolResp, resultPatches, err := h.executeToolCall(ctx, req, toolCall)
 			if err != nil {
 				logger.Logger.Error("工具执行失败",
 					zap.String("tool_name", toolCall.Function.Name),
@@ -115,103 +220,65 @@ func (h *ChatCompletionsHandler) HandleChatCompletions(
 			)
 
 			// 生成工具响应消息
-			toolRespMsg := llm.Message{
+			toolResponses = append(toolResponses, llm.Message{
 				Role:       "tool",
 				Content:    string(toolResp),
 				ToolCallID: toolCall.ID,
 				Name:       toolCall.Function.Name,
-			}
-			toolResponses = append(toolResponses, toolRespMsg)
+			})
 
-			// 解析工具调用结果生成TaskPatch
-			patches, err := h.generateTaskPatchesFromToolCall(toolCall)
-			if err != nil {
-				logger.Logger.Error("生成TaskPatch失败",
-					zap.String("tool_name", toolCall.Function.Name),
-					zap.String("error", err.Error()),
-				)
-				return "", nil, fmt.Errorf("failed to generate task patches from tool call %s: %w", toolCall.Function.Name, err)
-			}
 			logger.Logger.Debug("生成TaskPatch成功",
 				zap.String("tool_name", toolCall.Function.Name),
 				zap.Int("patches_count", len(patches)),
 			)
 			taskPatches = append(taskPatches, patches...)
+			taskPatches = append(taskPatches, resultPatches...)
 		}
 
-		// 4. 二次LLM调用，生成最终回复
-		if len(toolResponses) > 0 {
-			logger.Logger.Debug("发送二次LLM请求生成最终回复",
-				zap.Int("tool_responses_count", len(toolResponses)),
-			)
-			// 合并消息
-			finalMessages := append(initialMessages, llm.Message{
-				Role:      "assistant",
-				Content:   assistantMessage,
-				ToolCalls: choice.Message.ToolCalls,
-			})
-			finalMessages = append(finalMessages, toolResponses...)
-
-			// 调用LLM生成最终回复
-			finalChatReq := llm.ChatRequest{
-				Model:      cfg.Model,
-				Messages:   finalMessages,
-				Tools:      tools,
-				ToolChoice: "none", // 不再调用工具，直接生成回复
-			}
-
-			finalResp, err := h.llmClient.Chat(ctx, cfg, finalChatReq)
-			if err != nil {
-				logger.Logger.Error("二次LLM请求失败",
-					zap.String("error", err.Error()),
-				)
-				return "", nil, err
-			}
-
-			if len(finalResp.Choices) > 0 {
-				assistantMessage = finalResp.Choices[0].Message.Content
-				logger.Logger.Debug("收到最终LLM响应",
-					zap.Int("final_response_length", len(assistantMessage)),
-					zap.String("final_content", assistantMessage),
-				)
-			}
+		// 4. 带上工具结果再次调用LLM：查询了数据或参数有误时允许继续调用工具，否则直接生成最终回复
+		messages = append(messages, llm.Message{
+			Role:      "assistant",
+			Content:   assistantMessage,
+			ToolCalls: choice.Message.ToolCalls,
+		})
+		messages = append(messages, toolResponses...)
+		toolChoice = "none"
+		if invalidArgs && repairs < maxArgumentRepairRounds {
+			repairs++
+			toolChoice = "auto"
+		}
+		if readOnly && round+1 < maxToolRounds {
+			toolChoice = "auto"
 		}
-
-		logger.Logger.Info("ChatCompletionsHandler处理完成（含工具调用）",
-			zap.Int("task_patches_count", len(taskPatches)),
-			zap.Int("final_response_length", len(assistantMessage)),
-		)
-		return assistantMessage, taskPatches, nil
 	}
-
-	// 不需要工具调用，直接返回LLM回复
-	logger.Logger.Info("ChatCompletionsHandler处理完成（无工具调用）",
-		zap.Int("response_length", len(assistantMessage)),
-	)
-	return assistantMessage, nil, nil
 }
 
 // executeToolCall 执行单个工具调用
-func (h *ChatCompletionsHandler) executeToolCall(toolCall llm.ToolCall) ([]byte, error) {
-	executor, ok := h.toolMap[toolCall.Function.Name]
+func (h *ChatCompletionsHandler) executeToolCall(ctx context.Context, req AgentRequest, toolCall llm.ToolCall) ([]byte, []TaskPatch, error) {
+	executor, ok := h.tools.Executor(toolCall.Function.Name)
 	if !ok {
 		logger.Logger.Error("工具执行器未找到",
 			zap.String("tool_name", toolCall.Function.Name),
 		)
-		return nil, fmt.Errorf("tool executor not found for %s", toolCall.Function.Name)
+		return nil, nil, fmt.Errorf("tool executor not found for %s", toolCall.Function.Name)
 	}
 
 	logger.Logger.Debug("开始执行工具",
 		zap.String("tool_name", toolCall.Function.Name),
 	)
 
-	result, err := executor.Execute(toolCall.Function.Arguments)
+	result, err := executor.Execute(ctx, req, toolCall.Function.Arguments)
 	if err != nil {
 		logger.Logger.Error("工具执行失败",
 			zap.String("tool_name", toolCall.Function.Name),
 			zap.String("error", err.Error()),
 		)
-		return nil, err
+		return nil, nil, err
+	}
+
+	var patches []TaskPatch
+	if p, ok := result.(PatchProducer); ok {
+		patches = p.TaskPatches()
 	}
 
 	// 序列化工具执行结果
@@ -220,106 +287,88 @@ func (h *ChatCompletionsHandler) executeToolCall(toolCall llm.ToolCall) ([]byte,
 		logger.Logger.Error("序列化工具结果失败",
 			zap.String("error", err.Error()),
 		)
-		return nil, fmt.Errorf("failed to marshal tool result: %w", err)
+		return nil, nil, fmt.Errorf("failed to marshal tool result: %w", err)
 	}
 
-	return resultJSON, nil
+	return resultJSON, patches, nil
 }
 
-// generateTaskPatchesFromToolCall 从工具调用生成TaskPatch列表
-func (h *ChatCompletionsHandler) generateTaskPatchesFromToolCall(toolCall llm.ToolCall) ([]TaskPatch, error) {
-	var patches []TaskPatch
-	name := toolCall.Function.Name
-	argsJSON := toolCall.Function.Arguments
-
-	switch name {
-	case "update_task":
-		var args UpdateTaskArgs
-		if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
-			return nil, fmt.Errorf("update_task args decode: %w", err)
-		}
-		patches = append(patches, TaskPatch{
-			Kind: PatchUpdateTask,
-			UpdateTask: &UpdateTaskPatch{
-				TaskID: args.TaskID,
-				Fields: args.Fields,
-			},
-		})
-
-	case "update_steps":
-		var args UpdateStepsArgs
-		if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
-			return nil, fmt.Errorf("update_steps args decode: %w", err)
+// toolSchemas 解析各工具声明的参数 Schema；无法解析的 Schema 跳过校验
+func toolSchemas(tools []llm.Tool) map[string]*jsonschema.Schema {
+	schemas := make(map[string]*jsonschema.Schema, len(tools))
+	for _, tool := range tools {
+		if len(tool.Function.Parameters) == 0 {
+			continue
 		}
-		for _, u := range args.Updates {
-			patches = append(patches, TaskPatch{
-				Kind: PatchUpdateStep,
-				UpdateStep: &UpdateStepPatch{
-					TaskID: args.TaskID,
-					StepID: u.StepID,
-					Fields: u.Fields,
-				},
-			})
+		schema, err := jsonschema.Parse(tool.Function.Parameters)
+		if err != nil {
+			logger.Logger.Error("工具参数Schema解析失败",
+				zap.String("tool_name", tool.Function.Name),
+				zap.String("error", err.Error()),
+			)
+			continue
 		}
+		schemas[tool.Function.Name] = schema
+	}
+	return schemas
+}
 
-	case "add_steps":
-		var args AddStepsArgs
-		if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
-			return nil, fmt.Errorf("add_steps args decode: %w", err)
-		}
-		records := make([]task.NewStepRecord, 0, len(args.Steps))
-		for _, s := range args.Steps {
-			records = append(records, task.NewStepRecord{
-				Title:             s.Title,
-				Detail:            s.Detail,
-				EstimateMinutes:   s.EstimateMinutes,
-				InsertAfterStepID: s.InsertAfterStepID,
-			})
-		}
-		patches = append(patches, TaskPatch{
-			Kind: PatchAddSteps,
-			AddSteps: &AddStepsPatch{
-				TaskID:        args.TaskID,
-				ParentStepID:  args.ParentStepID,
-				StepsToInsert: records,
-			},
-		})
+// validateToolArgs 按工具声明的 Schema 校验参数，未声明 Schema 的工具不校验；
+// 调用前需先确认工具在本次提供的工具中
+func validateToolArgs(schemas map[string]*jsonschema.Schema, toolCall llm.ToolCall) *jsonschema.ValidationError {
+	schema, ok := schemas[toolCall.Function.Name]
+	if !ok {
+		return nil
+	}
+	args := toolCall.Function.Arguments
+	if args == "" {
+		args = "{}"
+	}
+	var verr *jsonschema.ValidationError
+	if err := schema.Validate([]byte(args)); errors.As(err, &verr) {
+		return verr
+	}
+	return nil
+}
 
-	case "add_dependencies":
-		var args AddDependenciesArgs
-		if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
-			return nil, fmt.Errorf("add_dependencies args decode: %w", err)
-		}
-		items := make([]task.DependencyItem, 0, len(args.Items))
-		for _, it := range args.Items {
-			items = append(items, task.DependencyItem{
-				PredecessorTaskID: it.PredecessorTaskID,
-				PredecessorStepID: it.PredecessorStepID,
-				SuccessorTaskID:   it.SuccessorTaskID,
-				SuccessorStepID:   it.SuccessorStepID,
-				Condition:         it.Condition,
-				Action:            it.Action,
-			})
-		}
-		patches = append(patches, TaskPatch{
-			Kind: PatchAddDependencies,
-			AddDependencies: &AddDependenciesPatch{
-				Items: items,
-			},
-		})
+// toolArgumentError 参数校验失败时返回给模型的工具结果
+type toolArgumentError struct {
+	Error   string   `json:"error"`
+	Details []string `json:"details"`
+}
 
-	case "mark_tasks_focus_today":
-		var args MarkTasksFocusTodayArgs
-		if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
-			return nil, fmt.Errorf("mark_tasks_focus_today args decode: %w", err)
-		}
-		patches = append(patches, TaskPatch{
-			Kind: PatchMarkTasksFocusToday,
-			MarkTasksFocusToday: &MarkTasksFocusTodayPatch{
-				TaskIDs: args.TaskIDs,
-			},
-		})
+func toolArgumentErrorContent(name string, verr *jsonschema.ValidationError) string {
+	out := toolArgumentError{
+		Error: fmt.Sprintf("invalid arguments for %s, nothing was changed; fix the arguments and call the tool again", name),
 	}
+	for _, fe := range verr.Errors {
+		out.Details = append(out.Details, fe.String())
+	}
+	data, _ := json.Marshal(out)
+	return string(data)
+}
+
+// toolUnavailableContent 模型调用了本次未提供的工具时返回给模型的工具结果
+func toolUnavailableContent(name string, available []string) string {
+	data, _ := json.Marshal(toolErrorResult{
+		Error: fmt.Sprintf("tool %s is not available, nothing was changed; use one of: %s", name, strings.Join(available, ", ")),
+	})
+	return string(data)
+}
+
+// toolDecodeErrorContent 参数无法解码时返回给模型的工具结果
+func toolDecodeErrorContent(name string, err error) string {
+	data, _ := json.Marshal(toolArgumentError{
+		Error:   fmt.Sprintf("invalid arguments for %s, nothing was changed; fix the arguments and call the tool again", name),
+		Details: []string{err.Error()},
+	})
+	return string(data)
+}
 
-	return patches, nil
+// toolNotOwnedContent 工具引用了不存在或不属于当前用户的任务、步骤时返回给模型的工具结果
+func toolNotOwnedContent(name string, err error) string {
+	data, _ := json.Marshal(toolErrorResult{
+		Error: fmt.Sprintf("%s: %v, nothing was changed; use list_tasks, search or get_task to find the right IDs", name, err),
+	})
+	return string(data)
 }
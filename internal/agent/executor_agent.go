@@ -1,7 +1,6 @@
 package agent
 
 import (
-	"context"
 	"fmt"
 
 	"assistant-qisumi/internal/llm"
@@ -10,6 +9,12 @@ import (
 	"go.uber.org/zap"
 )
 
+// executorTools Executor 可以调用的工具，定义见 tool_<name>.go
+var executorTools = []string{
+	"update_task", "update_steps", "start_timer", "stop_timer",
+	"get_task", "get_dependencies", "search", "get_task_history",
+}
+
 type ExecutorAgent struct {
 	llmClient              llm.Client
 	chatCompletionsHandler *ChatCompletionsHandler
@@ -32,7 +37,7 @@ func (a *ExecutorAgent) Handle(req AgentRequest) (*AgentResponse, error) {
 	)
 
 	// 1. 构造 messages
-	messages, err := BuildExecutorMessages(req.Task, req.Dependencies, req.Messages, req.UserInput, req.Now)
+	messages, err := BuildExecutorMessages(req.Task, req.Dependencies, req.Memories, req.Messages, req.UserInput, req.Now)
 	if err != nil {
 		logger.Logger.Error("构造Executor消息失败",
 			zap.String("error", err.Error()),
@@ -44,7 +49,7 @@ func (a *ExecutorAgent) Handle(req AgentRequest) (*AgentResponse, error) {
 	)
 
 	// 2. 定义可用工具
-	tools := llm.ExecutorTools()
+	tools := executorTools
 	logger.Logger.Debug("Executor工具定义",
 		zap.Int("tools_count", len(tools)),
 	)
@@ -52,8 +57,8 @@ func (a *ExecutorAgent) Handle(req AgentRequest) (*AgentResponse, error) {
 	// 3. 使用 ChatCompletionsHandler 处理完整的工具调用流程
 	// 这会自动处理：初始LLM调用 -> 工具执行 -> 二次LLM调用生成最终回复
 	assistantMessage, taskPatches, err := a.chatCompletionsHandler.HandleChatCompletions(
-		context.Background(),
-		req.LLMConfig,
+		req.Context(),
+		req,
 		messages,
 		tools,
 	)
@@ -18,7 +18,13 @@ func buildFallbackAssistantMessage(agentName string, patches []TaskPatch) string
 	addedStepsCount := 0
 	addedDependenciesCount := 0
 	focusTodayCount := 0
+	focusRemovedCount := 0
+	planReordered := false
+	planAccepted := false
+	planProposed := false
 	createdTaskCount := 0
+	timerStarted := false
+	timerStopped := false
 
 	for _, patch := range patches {
 		switch patch.Kind {
@@ -76,10 +82,28 @@ func buildFallbackAssistantMessage(agentName string, patches []TaskPatch) string
 			if patch.MarkTasksFocusToday == nil {
 				continue
 			}
-			focusTodayCount += len(patch.MarkTasksFocusToday.TaskIDs)
+			switch patch.MarkTasksFocusToday.Action {
+			case FocusUnmark:
+				focusRemovedCount += len(patch.MarkTasksFocusToday.TaskIDs) + len(patch.MarkTasksFocusToday.Steps)
+			case FocusReorder:
+				planReordered = true
+			case FocusAccept:
+				planAccepted = true
+			default:
+				focusTodayCount += len(patch.MarkTasksFocusToday.TaskIDs) + len(patch.MarkTasksFocusToday.Steps)
+			}
+
+		case PatchProposeDailyPlan:
+			planProposed = patch.ProposeDailyPlan != nil
 
 		case PatchCreateTask:
 			createdTaskCount++
+
+		case PatchStartTimer:
+			timerStarted = patch.StartTimer != nil
+
+		case PatchStopTimer:
+			timerStopped = patch.StopTimer != nil
 		}
 	}
 
@@ -107,11 +131,29 @@ func buildFallbackAssistantMessage(agentName string, patches []TaskPatch) string
 		parts = append(parts, fmt.Sprintf("已新增 %d 条依赖关系", addedDependenciesCount))
 	}
 	if focusTodayCount > 0 {
-		parts = append(parts, fmt.Sprintf("已将 %d 个任务设为今日重点", focusTodayCount))
+		parts = append(parts, fmt.Sprintf("已将 %d 项加入今日计划", focusTodayCount))
+	}
+	if focusRemovedCount > 0 {
+		parts = append(parts, fmt.Sprintf("已从今日计划移除 %d 项", focusRemovedCount))
+	}
+	if planReordered {
+		parts = append(parts, "已调整今日计划的顺序")
+	}
+	if planProposed {
+		parts = append(parts, "已拟好今日计划，确认后生效")
+	}
+	if planAccepted {
+		parts = append(parts, "已确认今日计划")
 	}
 	if createdTaskCount > 0 {
 		parts = append(parts, fmt.Sprintf("已创建 %d 个任务", createdTaskCount))
 	}
+	if timerStopped {
+		parts = append(parts, "已停止计时")
+	}
+	if timerStarted {
+		parts = append(parts, "已开始计时")
+	}
 
 	if len(parts) == 0 {
 		return defaultAssistantMessage(agentName)
@@ -1,11 +1,9 @@
 package agent
 
 import (
-	"context"
-	"encoding/json"
 	"fmt"
-	"time"
 
+	"assistant-qisumi/internal/dailyplan"
 	"assistant-qisumi/internal/llm"
 	"assistant-qisumi/internal/logger"
 	"assistant-qisumi/internal/prompts"
@@ -13,6 +11,13 @@ import (
 	"go.uber.org/zap"
 )
 
+// globalTools Global 可以调用的工具，定义见 tool_<name>.go
+var globalTools = []string{
+	"update_task", "update_steps", "add_steps", "create_task", "add_dependencies",
+	"mark_tasks_focus_today", "propose_daily_plan", "schedule_steps",
+	"get_task", "list_tasks", "get_dependencies", "search", "get_task_history",
+}
+
 type GlobalAgent struct {
 	llmClient              llm.Client
 	chatCompletionsHandler *ChatCompletionsHandler
@@ -46,29 +51,35 @@ func (a *GlobalAgent) Handle(req AgentRequest) (*AgentResponse, error) {
 	// 添加当前时间信息
 	messages = append(messages, llm.Message{
 		Role:    "system",
-		Content: "当前时间 now: " + req.Now.Format(time.RFC3339),
+		Content: prompts.NowMessage(req.Now),
 	})
 
-	// 添加任务数据到系统消息
+	// 添加关于用户的长期记忆
+	messages = appendMemoryMessage(messages, req.Memories)
+
+	// 添加任务索引到系统消息，步骤等详情由模型通过查询工具按需获取
 	if len(req.Tasks) > 0 {
-		tasksJSON, err := json.Marshal(req.Tasks)
-		if err == nil {
-			messages = append(messages, llm.Message{
-				Role:    "system",
-				Content: "用户的任务数据（JSON格式）：\n" + string(tasksJSON),
-			})
-			logger.Logger.Debug("添加任务数据",
-				zap.Int("tasks_count", len(req.Tasks)),
-			)
-		}
+		messages = append(messages, llm.Message{
+			Role:    "system",
+			Content: BuildTaskIndex(req.Tasks, req.Now.Location()),
+		})
+		logger.Logger.Debug("添加任务索引",
+			zap.Int("tasks_count", len(req.Tasks)),
+		)
 	} else {
 		messages = append(messages, llm.Message{
 			Role:    "system",
-			Content: "用户目前没有任务数据。",
+			Content: "用户目前没有未完成的任务。已完成的任务可以通过 list_tasks 或 search 查询。",
 		})
 		logger.Logger.Debug("用户没有任务数据")
 	}
 
+	// 添加今天的计划，没有计划时提示可以帮用户规划今天
+	messages = append(messages, llm.Message{
+		Role:    "system",
+		Content: BuildDailyPlanMessage(req.Now.Format(dailyplan.DateLayout), req.DailyPlan, req.Tasks),
+	})
+
 	// 添加历史消息
 	messages = append(messages, historyToLLMMessages(req.Messages)...)
 	logger.Logger.Debug("添加历史消息",
@@ -82,7 +93,7 @@ func (a *GlobalAgent) Handle(req AgentRequest) (*AgentResponse, error) {
 	})
 
 	// 定义可用工具
-	tools := llm.GlobalTools()
+	tools := globalTools
 	logger.Logger.Debug("Global工具定义",
 		zap.Int("tools_count", len(tools)),
 	)
@@ -90,8 +101,8 @@ func (a *GlobalAgent) Handle(req AgentRequest) (*AgentResponse, error) {
 	// 使用 ChatCompletionsHandler 处理完整的工具调用流程
 	// 这会自动处理：初始LLM调用 -> 工具执行 -> 二次LLM调用生成最终回复
 	assistantMessage, taskPatches, err := a.chatCompletionsHandler.HandleChatCompletions(
-		context.Background(),
-		req.LLMConfig,
+		req.Context(),
+		req,
 		messages,
 		tools,
 	)
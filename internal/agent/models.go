@@ -1,23 +1,62 @@
 package agent
 
 import (
+	"context"
 	"time"
 
+	"assistant-qisumi/internal/dailyplan"
+	"assistant-qisumi/internal/domain"
 	"assistant-qisumi/internal/llm"
+	"assistant-qisumi/internal/memory"
+	"assistant-qisumi/internal/profile"
 	"assistant-qisumi/internal/session"
 	"assistant-qisumi/internal/task"
+	"assistant-qisumi/internal/timetrack"
 )
 
 type AgentRequest struct {
-	UserID       uint64
-	Session      *session.Session
-	Task         *task.Task       // 单个任务（保持向后兼容）
-	Tasks        []task.Task      // 用户的所有任务（用于全局助手）
-	Dependencies []task.TaskDependency // 依赖关系信息（用于Executor判断隐含前置条件）
-	Messages     []session.Message
-	UserInput    string
-	Now          time.Time
-	LLMConfig    llm.Config
+	UserID           uint64
+	Session          *session.Session
+	Task             *task.Task            // 单个任务（保持向后兼容）
+	Tasks            []task.Task           // 用户的所有任务（用于全局助手）
+	DailyPlan        *dailyplan.DailyPlan  // 今天的计划（用于全局助手），还没有计划时为空
+	Dependencies     []task.TaskDependency // 依赖关系信息（用于Executor判断隐含前置条件）
+	EstimateAccuracy *timetrack.Accuracy   // 用户历史估时准确度（用于Planner校准估时，样本不足时为空）
+	Profile          *profile.UserProfile  // 用户时区与工作时间，为空时使用默认值
+	Memories         []memory.Memory       // 与本轮输入相关的长期记忆（用户事实和偏好），路由只选择 agent，不使用
+	Messages         []session.Message
+	UserInput        string
+	Now              time.Time
+	LLMConfig        llm.Config
+	AgentLLMConfigs  map[string]llm.Config // 用户为各 agent 单独指派的配置，键为 agent 名称或 "default"
+	Ctx              context.Context       // 请求的 context，携带用户资料和 LLM 调用归属（用量统计）
+}
+
+// Location 返回用户时区（Now 的时区），未设置 Now 时为服务器时区
+func (r AgentRequest) Location() *time.Location {
+	if r.Now.IsZero() {
+		return time.Local
+	}
+	return r.Now.Location()
+}
+
+// Context 返回请求的 context，未设置时为 context.Background()
+func (r AgentRequest) Context() context.Context {
+	if r.Ctx == nil {
+		return context.Background()
+	}
+	return r.Ctx
+}
+
+// LLMConfigFor 返回指定 agent 应使用的 LLM 配置：单独指派 > "default" 指派 > LLMConfig
+func (r AgentRequest) LLMConfigFor(agent string) llm.Config {
+	if cfg, ok := r.AgentLLMConfigs[agent]; ok {
+		return cfg
+	}
+	if cfg, ok := r.AgentLLMConfigs[domain.LLMAgentDefault]; ok {
+		return cfg
+	}
+	return r.LLMConfig
 }
 
 type AgentResponse struct {
@@ -1,6 +1,13 @@
 package agent
 
-import "assistant-qisumi/internal/domain"
+import (
+	"fmt"
+	"time"
+
+	"assistant-qisumi/internal/domain"
+	"assistant-qisumi/internal/nldate"
+	"assistant-qisumi/internal/task"
+)
 
 type PatchKind string
 
@@ -11,6 +18,9 @@ const (
 	PatchAddDependencies     PatchKind = "add_dependencies"
 	PatchMarkTasksFocusToday PatchKind = "mark_tasks_focus_today"
 	PatchCreateTask          PatchKind = "create_task"
+	PatchStartTimer          PatchKind = "start_timer"
+	PatchStopTimer           PatchKind = "stop_timer"
+	PatchProposeDailyPlan    PatchKind = "propose_daily_plan"
 )
 
 // 顶层 Patch，Kind 决定哪个字段非 nil
@@ -23,6 +33,9 @@ type TaskPatch struct {
 	AddDependencies     *AddDependenciesPatch     `json:"addDependencies,omitempty"`
 	MarkTasksFocusToday *MarkTasksFocusTodayPatch `json:"markTasksFocusToday,omitempty"`
 	CreateTask          *CreateTaskPatch          `json:"createTask,omitempty"`
+	StartTimer          *StartTimerPatch          `json:"startTimer,omitempty"`
+	StopTimer           *StopTimerPatch           `json:"stopTimer,omitempty"`
+	ProposeDailyPlan    *ProposeDailyPlanPatch    `json:"proposeDailyPlan,omitempty"`
 }
 
 // --- 各种具体 Patch Payload ---
@@ -48,8 +61,33 @@ type AddDependenciesPatch struct {
 	Items []domain.DependencyItem `json:"items"`
 }
 
+// 今日计划的修改方式，见 MarkTasksFocusTodayPatch
+const (
+	FocusMark    = "mark"
+	FocusUnmark  = "unmark"
+	FocusReorder = "reorder"
+	FocusAccept  = "accept"
+)
+
+// MarkTasksFocusTodayPatch 修改今天的计划（今日重点）：Action 为空时等同 mark；
+// 计划项依次为 TaskIDs 中的任务和 Steps 中的步骤，reorder 时即新的顺序
 type MarkTasksFocusTodayPatch struct {
-	TaskIDs []uint64 `json:"taskIds"`
+	Action  string        `json:"action,omitempty"`
+	TaskIDs []uint64      `json:"taskIds"`
+	Steps   []PlanItemRef `json:"steps,omitempty"`
+	Note    *string       `json:"note,omitempty"`
+}
+
+// PlanItemRef 计划中的任务或步骤，StepID 为空表示整个任务
+type PlanItemRef struct {
+	TaskID uint64  `json:"taskId"`
+	StepID *uint64 `json:"stepId,omitempty"`
+}
+
+// ProposeDailyPlanPatch 助手提出的今天的计划，等待用户确认
+type ProposeDailyPlanPatch struct {
+	Items []PlanItemRef `json:"items"`
+	Note  string        `json:"note,omitempty"`
 }
 
 type CreateTaskPatch struct {
@@ -59,3 +97,105 @@ type CreateTaskPatch struct {
 	Priority    string                 `json:"priority"`
 	Steps       []domain.NewStepRecord `json:"steps"`
 }
+
+type StartTimerPatch struct {
+	TaskID uint64  `json:"taskId"`
+	StepID *uint64 `json:"stepId,omitempty"`
+	Note   string  `json:"note,omitempty"`
+}
+
+// StopTimerPatch TaskID / StepID 为空时停止用户当前所有计时器
+type StopTimerPatch struct {
+	TaskID *uint64 `json:"taskId,omitempty"`
+	StepID *uint64 `json:"stepId,omitempty"`
+}
+
+// patchRefs 收集 Patch 引用的任务和步骤，应用前用于校验归属
+func patchRefs(patches []TaskPatch) ([]uint64, []task.StepRef) {
+	var taskIDs []uint64
+	var steps []task.StepRef
+	step := func(taskID uint64, stepID *uint64) {
+		if stepID != nil {
+			steps = append(steps, task.StepRef{TaskID: taskID, StepID: *stepID})
+		}
+	}
+	for _, p := range patches {
+		switch {
+		case p.Kind == PatchUpdateTask && p.UpdateTask != nil:
+			taskIDs = append(taskIDs, p.UpdateTask.TaskID)
+		case p.Kind == PatchUpdateStep && p.UpdateStep != nil:
+			step(p.UpdateStep.TaskID, &p.UpdateStep.StepID)
+		case p.Kind == PatchAddSteps && p.AddSteps != nil:
+			taskIDs = append(taskIDs, p.AddSteps.TaskID)
+			step(p.AddSteps.TaskID, p.AddSteps.ParentStepID)
+			for _, s := range p.AddSteps.StepsToInsert {
+				step(p.AddSteps.TaskID, s.InsertAfterStepID)
+			}
+		case p.Kind == PatchAddDependencies && p.AddDependencies != nil:
+			for _, it := range p.AddDependencies.Items {
+				taskIDs = append(taskIDs, it.PredecessorTaskID, it.SuccessorTaskID)
+				step(it.PredecessorTaskID, it.PredecessorStepID)
+				step(it.SuccessorTaskID, it.SuccessorStepID)
+			}
+		case p.Kind == PatchMarkTasksFocusToday && p.MarkTasksFocusToday != nil:
+			taskIDs = append(taskIDs, p.MarkTasksFocusToday.TaskIDs...)
+			for _, it := range p.MarkTasksFocusToday.Steps {
+				taskIDs = append(taskIDs, it.TaskID)
+				step(it.TaskID, it.StepID)
+			}
+		case p.Kind == PatchProposeDailyPlan && p.ProposeDailyPlan != nil:
+			for _, it := range p.ProposeDailyPlan.Items {
+				taskIDs = append(taskIDs, it.TaskID)
+				step(it.TaskID, it.StepID)
+			}
+		case p.Kind == PatchStartTimer && p.StartTimer != nil:
+			taskIDs = append(taskIDs, p.StartTimer.TaskID)
+			step(p.StartTimer.TaskID, p.StartTimer.StepID)
+		case p.Kind == PatchStopTimer && p.StopTimer != nil && p.StopTimer.TaskID != nil:
+			taskIDs = append(taskIDs, *p.StopTimer.TaskID)
+			step(*p.StopTimer.TaskID, p.StopTimer.StepID)
+		}
+	}
+	return taskIDs, steps
+}
+
+// normalizeTimes 把 Patch 中的时间参数统一为 RFC3339：固定格式中不带时区的按 now 的时区（用户时区）理解，
+// 其他的按自然语言（"明天下午三点"、"下周五前"）相对 now 解析
+func normalizeTimes(patches []TaskPatch, now time.Time) error {
+	for _, p := range patches {
+		var err error
+		switch {
+		case p.Kind == PatchCreateTask && p.CreateTask != nil:
+			p.CreateTask.DueAt, err = normalizeTime("due_at", p.CreateTask.DueAt, now, nldate.EndOfDay)
+		case p.Kind == PatchUpdateTask && p.UpdateTask != nil:
+			p.UpdateTask.Fields.DueAt, err = normalizeTime("due_at", p.UpdateTask.Fields.DueAt, now, nldate.EndOfDay)
+		case p.Kind == PatchUpdateStep && p.UpdateStep != nil:
+			f := &p.UpdateStep.Fields
+			if f.PlannedStart, err = normalizeTime("planned_start", f.PlannedStart, now, 9*time.Hour); err == nil {
+				f.PlannedEnd, err = normalizeTime("planned_end", f.PlannedEnd, now, 18*time.Hour)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// normalizeTime 解析一个时间参数，空字符串表示清空、原样保留；
+// 自然语言日期没有具体时刻时使用 defaultClock
+func normalizeTime(name string, value *string, now time.Time, defaultClock time.Duration) (*string, error) {
+	if value == nil || *value == "" {
+		return value, nil
+	}
+	var t time.Time
+	if ft, err := domain.ParseFlexibleTimeIn(*value, now.Location()); err == nil {
+		t = ft.ToTime()
+	} else if r, nlErr := nldate.Parse(*value, now); nlErr == nil {
+		t = r.At(defaultClock)
+	} else {
+		return nil, fmt.Errorf("%s: cannot understand time %q, use ISO 8601 like 2025-12-08T18:00:00 or a phrase like 明天下午3点", name, *value)
+	}
+	out := t.Format(time.RFC3339)
+	return &out, nil
+}
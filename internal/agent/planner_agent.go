@@ -1,7 +1,6 @@
 package agent
 
 import (
-	"context"
 	"fmt"
 
 	"assistant-qisumi/internal/llm"
@@ -10,6 +9,12 @@ import (
 	"go.uber.org/zap"
 )
 
+// plannerTools Planner 可以调用的工具，定义见 tool_<name>.go
+var plannerTools = []string{
+	"update_task", "update_steps", "add_steps", "add_dependencies", "schedule_steps",
+	"get_task", "list_tasks", "get_dependencies", "search",
+}
+
 type PlannerAgent struct {
 	llmClient              llm.Client
 	chatCompletionsHandler *ChatCompletionsHandler
@@ -32,7 +37,7 @@ func (a *PlannerAgent) Handle(req AgentRequest) (*AgentResponse, error) {
 	)
 
 	// 1. 构造 messages
-	messages, err := BuildPlannerMessages(req.Task, req.Messages, req.UserInput, req.Now)
+	messages, err := BuildPlannerMessages(req.Task, req.EstimateAccuracy, req.Memories, req.Messages, req.UserInput, req.Now)
 	if err != nil {
 		logger.Logger.Error("构造Planner消息失败",
 			zap.String("error", err.Error()),
@@ -44,7 +49,7 @@ func (a *PlannerAgent) Handle(req AgentRequest) (*AgentResponse, error) {
 	)
 
 	// 2. 准备tools
-	tools := llm.PlannerTools()
+	tools := plannerTools
 	logger.Logger.Debug("Planner工具定义",
 		zap.Int("tools_count", len(tools)),
 	)
@@ -52,8 +57,8 @@ func (a *PlannerAgent) Handle(req AgentRequest) (*AgentResponse, error) {
 	// 3. 使用 ChatCompletionsHandler 处理完整的工具调用流程
 	// 这会自动处理：初始LLM调用 -> 工具执行 -> 二次LLM调用生成最终回复
 	assistantMessage, taskPatches, err := a.chatCompletionsHandler.HandleChatCompletions(
-		context.Background(),
-		req.LLMConfig,
+		req.Context(),
+		req,
 		messages,
 		tools,
 	)
@@ -6,10 +6,13 @@ import (
 	"strings"
 	"time"
 
+	"assistant-qisumi/internal/dailyplan"
 	"assistant-qisumi/internal/llm"
+	"assistant-qisumi/internal/memory"
 	"assistant-qisumi/internal/prompts"
 	"assistant-qisumi/internal/session"
 	"assistant-qisumi/internal/task"
+	"assistant-qisumi/internal/timetrack"
 )
 
 // historyToLLMMessages 将内部的对话消息转换为 LLM 消息（简单版：只保留 role+content）
@@ -32,14 +35,110 @@ func historyToLLMMessages(history []session.Message) []llm.Message {
 	return msgs
 }
 
+// BuildTaskIndex 把任务列表整理成每行一个任务、每个步骤一行的索引，供 GlobalAgent 使用
+// 只包含识别任务和步骤所需的字段，步骤详情、依赖和历史由模型通过查询工具获取；截止时间按用户时区 loc 显示
+func BuildTaskIndex(tasks []task.Task, loc *time.Location) string {
+	var b strings.Builder
+	b.WriteString("用户未完成的任务索引（任务：编号 | 标题 | 状态 | 优先级 | 截止时间 | 今日重点；步骤：编号 | 状态 | 标题）：\n")
+	for _, t := range tasks {
+		due := "无"
+		if t.DueAt != nil {
+			due = t.DueAt.ToTime().In(loc).Format("2006-01-02 15:04")
+		}
+		focus := ""
+		if t.IsFocusToday {
+			focus = "是"
+		}
+		fmt.Fprintf(&b, "#%d | %s | %s | %s | %s | %s\n", t.ID, t.Title, t.Status, t.Priority, due, focus)
+		for _, st := range t.Steps {
+			fmt.Fprintf(&b, "  - 步骤#%d | %s | %s\n", st.ID, st.Status, st.Title)
+		}
+	}
+	b.WriteString("步骤详情、依赖关系、已完成任务和历史记录请使用 get_task / list_tasks / get_dependencies / search / get_task_history 查询。")
+	return b.String()
+}
+
+// BuildDailyPlanMessage 描述今天已确认的计划和待用户确认的计划，供 GlobalAgent 判断是否需要规划今天以及修改计划
+// 任务和步骤标题从 tasks（未完成任务索引）中查找，找不到时只显示编号
+func BuildDailyPlanMessage(date string, plan *dailyplan.DailyPlan, tasks []task.Task) string {
+	proposed := plan != nil && plan.Status == dailyplan.StatusProposed
+	if plan == nil || (len(plan.Items) == 0 && plan.Note == "" && !proposed) {
+		return fmt.Sprintf("今天（%s）还没有计划。用户想规划今天时，用 propose_daily_plan 拟定计划，用户确认后再调用 mark_tasks_focus_today（action=accept）。", date)
+	}
+
+	taskTitles := make(map[uint64]string, len(tasks))
+	stepTitles := make(map[uint64]string)
+	for _, t := range tasks {
+		taskTitles[t.ID] = t.Title
+		for _, st := range t.Steps {
+			stepTitles[st.ID] = st.Title
+		}
+	}
+	writeItems := func(b *strings.Builder, items []dailyplan.DailyPlanItem, note string) {
+		for i, it := range items {
+			fmt.Fprintf(b, "%d. #%d %s", i+1, it.TaskID, taskTitles[it.TaskID])
+			if it.StepID != nil {
+				fmt.Fprintf(b, " / 步骤#%d %s", *it.StepID, stepTitles[*it.StepID])
+			}
+			b.WriteString("\n")
+		}
+		if note != "" {
+			fmt.Fprintf(b, "备注：%s\n", note)
+		}
+	}
+
+	var b strings.Builder
+	if len(plan.Items) > 0 || plan.Note != "" {
+		fmt.Fprintf(&b, "今天（%s）的计划（已确认）：\n", date)
+		writeItems(&b, plan.Items, plan.Note)
+	} else {
+		fmt.Fprintf(&b, "今天（%s）还没有已确认的计划。\n", date)
+	}
+	if proposed {
+		b.WriteString("助手拟定的计划（待用户确认，确认后替换上面的计划）：\n")
+		writeItems(&b, plan.Proposal, plan.ProposalNote)
+	}
+	b.WriteString("修改计划使用 mark_tasks_focus_today（mark / unmark / reorder / accept），mark / unmark / reorder 修改的是已确认的计划。")
+	return b.String()
+}
+
+// BuildMemoryMessage 列出关于用户的长期记忆，跨会话保留用户的事实和偏好
+func BuildMemoryMessage(memories []memory.Memory) string {
+	var b strings.Builder
+	b.WriteString("关于用户的长期记忆（来自以往对话或用户手动添加，与用户当前说法冲突时以当前说法为准）：")
+	for _, m := range memories {
+		fmt.Fprintf(&b, "\n- [%s] %s", memoryCategoryLabels[m.Category], m.Content)
+	}
+	return b.String()
+}
+
+var memoryCategoryLabels = map[string]string{
+	memory.CategoryPreference: "偏好",
+	memory.CategorySchedule:   "作息",
+	memory.CategoryPerson:     "人物",
+	memory.CategoryFact:       "事实",
+}
+
+// appendMemoryMessage 有相关记忆时追加一条长期记忆的 system 消息
+func appendMemoryMessage(msgs []llm.Message, memories []memory.Memory) []llm.Message {
+	if len(memories) == 0 {
+		return msgs
+	}
+	return append(msgs, llm.Message{
+		Role:    "system",
+		Content: BuildMemoryMessage(memories),
+	})
+}
+
 // BuildExecutorMessages 构造 ExecutorAgent 的 messages：
 // - system: ExecutorSystemPrompt
 // - system: 当前任务 JSON
 // - system: 依赖关系 JSON（用于判断隐含前置条件）
 // - system: 当前时间
+// - system: 长期记忆（可选）
 // - 历史消息（可选）
 // - user: 最新输入
-func BuildExecutorMessages(t *task.Task, dependencies []task.TaskDependency, history []session.Message, userInput string, now time.Time) ([]llm.Message, error) {
+func BuildExecutorMessages(t *task.Task, dependencies []task.TaskDependency, memories []memory.Memory, history []session.Message, userInput string, now time.Time) ([]llm.Message, error) {
 	taskJSON, err := json.Marshal(t)
 	if err != nil {
 		return nil, err
@@ -76,8 +175,9 @@ func BuildExecutorMessages(t *task.Task, dependencies []task.TaskDependency, his
 
 	msgs = append(msgs, llm.Message{
 		Role:    "system",
-		Content: fmt.Sprintf("当前时间 now: %s", now.Format(time.RFC3339)),
+		Content: prompts.NowMessage(now),
 	})
+	msgs = appendMemoryMessage(msgs, memories)
 
 	// 历史消息
 	msgs = append(msgs, historyToLLMMessages(history)...)
@@ -92,8 +192,8 @@ func BuildExecutorMessages(t *task.Task, dependencies []task.TaskDependency, his
 }
 
 // BuildPlannerMessages 构造 PlannerAgent 的 messages。
-// 这里同样包含：系统提示词 + 当前任务 JSON + 当前时间 + 历史 + 最新 user。
-func BuildPlannerMessages(t *task.Task, history []session.Message, userInput string, now time.Time) ([]llm.Message, error) {
+// 这里同样包含：系统提示词 + 当前任务 JSON + 估时准确度（可选）+ 当前时间 + 长期记忆（可选）+ 历史 + 最新 user。
+func BuildPlannerMessages(t *task.Task, accuracy *timetrack.Accuracy, memories []memory.Memory, history []session.Message, userInput string, now time.Time) ([]llm.Message, error) {
 	taskJSON, err := json.Marshal(t)
 	if err != nil {
 		return nil, err
@@ -111,12 +211,21 @@ func BuildPlannerMessages(t *task.Task, history []session.Message, userInput str
 				taskJSON,
 			),
 		},
-		{
+	}
+
+	if accuracy != nil {
+		msgs = append(msgs, llm.Message{
 			Role:    "system",
-			Content: fmt.Sprintf("当前时间 now: %s", now.Format(time.RFC3339)),
-		},
+			Content: accuracy.Describe(),
+		})
 	}
 
+	msgs = append(msgs, llm.Message{
+		Role:    "system",
+		Content: prompts.NowMessage(now),
+	})
+	msgs = appendMemoryMessage(msgs, memories)
+
 	msgs = append(msgs, historyToLLMMessages(history)...)
 
 	msgs = append(msgs, llm.Message{
@@ -1,7 +1,6 @@
 package agent
 
 import (
-	"context"
 	"encoding/json"
 	"strings"
 
@@ -135,20 +134,21 @@ func (a *RouterAgent) llmBasedRoute(req AgentRequest) (string, error) {
 		},
 	}
 
-	// 构造 Chat 请求
+	// 构造 Chat 请求（路由可以单独指派便宜的模型）
+	cfg := req.LLMConfigFor(a.Name())
 	chatReq := llm.ChatRequest{
-		Model:      req.LLMConfig.Model,
+		Model:      cfg.Model,
 		Messages:   messages,
 		ToolChoice: "none", // Router 不需要工具调用
 	}
 
 	// 调用 LLM
 	logger.Logger.Debug("发送LLM路由请求",
-		zap.String("model", req.LLMConfig.Model),
+		zap.String("model", cfg.Model),
 		zap.String("session_type", sessionType),
 		zap.String("has_task", hasTask),
 	)
-	resp, err := a.llmClient.Chat(context.Background(), req.LLMConfig, chatReq)
+	resp, err := a.llmClient.Chat(llm.WithAgent(req.Context(), a.Name()), cfg, chatReq)
 	if err != nil {
 		logger.Logger.Error("LLM路由请求失败",
 			zap.String("error", err.Error()),
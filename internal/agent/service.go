@@ -2,15 +2,22 @@ package agent
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
+	"assistant-qisumi/internal/dailyplan"
 	"assistant-qisumi/internal/dependency"
+	"assistant-qisumi/internal/history"
 	"assistant-qisumi/internal/llm"
 	"assistant-qisumi/internal/logger"
+	"assistant-qisumi/internal/memory"
+	"assistant-qisumi/internal/profile"
 	"assistant-qisumi/internal/session"
 	"assistant-qisumi/internal/task"
+	"assistant-qisumi/internal/timetrack"
 
 	"go.uber.org/zap"
 	"gorm.io/gorm"
@@ -25,6 +32,44 @@ type Service struct {
 	db                     *gorm.DB
 	llmClient              llm.Client
 	chatCompletionsHandler *ChatCompletionsHandler
+	llmConfigResolver      LLMConfigResolver
+	memorySvc              *memory.Service
+	history                *history.Service
+	background             sync.WaitGroup // 对话结束后的后台工作（记忆提取、历史摘要）
+}
+
+// LLMConfigResolver 解析用户为各 agent 单独指派的 LLM 配置
+type LLMConfigResolver interface {
+	AgentLLMConfigs(ctx context.Context, userID uint64) (map[string]llm.Config, error)
+}
+
+// SetLLMConfigResolver 设置按 agent 解析 LLM 配置的来源，未设置时所有 agent 使用同一配置
+func (s *Service) SetLLMConfigResolver(r LLMConfigResolver) {
+	s.llmConfigResolver = r
+}
+
+// maxPromptMemories 每轮提供给 agent 的长期记忆条数上限
+const maxPromptMemories = 10
+
+// SetMemory 设置长期记忆服务：每轮对话前取出相关记忆，对话后在后台提取新的记忆。未设置时不使用记忆
+func (s *Service) SetMemory(m *memory.Service) {
+	s.memorySvc = m
+}
+
+// Wait 等待后台工作（记忆提取、历史摘要）结束，用于测试和优雅退出
+func (s *Service) Wait() {
+	s.background.Wait()
+}
+
+// NewDefaultAgents 创建服务使用的全部 agent，共享同一个 Chat Completions 处理器
+func NewDefaultAgents(llmClient llm.Client, chatCompletionsHandler *ChatCompletionsHandler) []Agent {
+	return []Agent{
+		NewExecutorAgent(llmClient, chatCompletionsHandler),
+		NewPlannerAgent(llmClient, chatCompletionsHandler),
+		NewSummarizerAgent(llmClient),
+		NewGlobalAgent(llmClient, chatCompletionsHandler),
+		NewTaskCreationAgent(llmClient),
+	}
 }
 
 func NewService(
@@ -41,11 +86,17 @@ func NewService(
 		m[ag.Name()] = ag
 	}
 
-	// 初始化工具执行器映射
-	toolMap := NewToolExecutors()
+	// 初始化工具注册表
+	tools := NewToolRegistry(taskRepo, sessionRepo)
 
 	// 初始化Chat Completions处理器
-	chatCompletionsHandler := NewChatCompletionsHandler(llmClient, toolMap)
+	chatCompletionsHandler := NewChatCompletionsHandler(llmClient, tools)
+
+	// 历史消息按 token 预算组装，较早的消息合并进滚动摘要；没有数据库时退回只取最近 20 条
+	var hist *history.Service
+	if db != nil {
+		hist = history.NewService(db, llmClient)
+	}
 
 	return &Service{
 		router:                 router,
@@ -56,6 +107,14 @@ func NewService(
 		db:                     db,
 		llmClient:              llmClient,
 		chatCompletionsHandler: chatCompletionsHandler,
+		history:                hist,
+	}
+}
+
+// SetHistoryBudget 设置历史消息的 token 预算，默认为 history.DefaultBudget
+func (s *Service) SetHistoryBudget(b history.Budget) {
+	if s.history != nil {
+		s.history.WithBudget(b)
 	}
 }
 
@@ -82,13 +141,36 @@ func (s *Service) HandleUserMessage(
 		)
 		return nil, fmt.Errorf("GetSession failed: %w", err)
 	}
-	msgs, err := s.sessionRepo.ListRecentMessages(ctx, sessionID, 20)
+	msgs, err := s.loadHistory(ctx, sessionID)
 	if err != nil {
 		logger.Logger.Error("获取历史消息失败",
 			zap.String("session_id", fmt.Sprintf("%d", sessionID)),
 			zap.String("error", err.Error()),
 		)
-		return nil, fmt.Errorf("ListRecentMessages failed: %w", err)
+		return nil, fmt.Errorf("load history failed: %w", err)
+	}
+
+	// 用户资料：时区用于渲染 now 和理解模型输出的时间，工作时间用于排期
+	prof := profile.FromContext(ctx)
+	if prof == nil {
+		prof = profile.Default(userID)
+		if s.db != nil {
+			if p, err := profile.NewService(profile.NewRepository(s.db)).Get(ctx, userID); err == nil {
+				prof = p
+			}
+		}
+		ctx = profile.NewContext(ctx, prof)
+	}
+
+	// 先按今天的计划同步今日重点（需要用户时区），任务会话和全局会话读到的 is_focus_today 都与计划一致
+	var plans *dailyplan.Service
+	if s.db != nil {
+		plans = dailyplan.NewService(s.db)
+		if err := plans.SyncFocus(ctx, userID); err != nil {
+			logger.Logger.Warn("同步今日重点失败，将继续处理",
+				zap.String("error", err.Error()),
+			)
+		}
 	}
 
 	var t *task.Task
@@ -99,15 +181,6 @@ func (s *Service) HandleUserMessage(
 		}
 	}
 
-	// 获取用户的所有任务（用于全局助手）
-	var allTasks []task.Task
-	if sess.Type == "global" {
-		allTasks, err = s.taskRepo.ListTasks(ctx, userID)
-		if err != nil {
-			return nil, fmt.Errorf("ListTasks failed: %w", err)
-		}
-	}
-
 	// 获取依赖关系信息（用于Executor判断隐含前置条件）
 	var dependencies []task.TaskDependency
 	if sess.TaskID != nil {
@@ -120,16 +193,80 @@ func (s *Service) HandleUserMessage(
 		}
 	}
 
+	// 获取历史估时准确度（用于Planner校准新步骤的估时）
+	var accuracy *timetrack.Accuracy
+	if sess.TaskID != nil && s.db != nil {
+		accuracy, err = timetrack.NewService(s.db).EstimateAccuracy(ctx, userID)
+		if err != nil {
+			logger.Logger.Warn("获取估时准确度失败，将继续处理",
+				zap.String("error", err.Error()),
+			)
+			accuracy = nil
+		}
+	}
+
+	// 全局助手：获取今天的计划和未完成的任务及步骤摘要
+	var allTasks []task.Task
+	var todayPlan *dailyplan.DailyPlan
+	if sess.Type == "global" {
+		if plans != nil {
+			todayPlan, err = plans.Get(ctx, userID, plans.Today(ctx))
+			if err != nil {
+				logger.Logger.Warn("获取今日计划失败，将继续处理",
+					zap.String("error", err.Error()),
+				)
+				todayPlan = nil
+			}
+		}
+		allTasks, err = s.taskRepo.FindTasks(ctx, userID, task.TaskFilter{Statuses: []string{"todo", "in_progress"}})
+		if err != nil {
+			return nil, fmt.Errorf("FindTasks failed: %w", err)
+		}
+	}
+
+	// 按 agent 指派的 LLM 配置（例如路由和总结用便宜模型，规划用强模型）
+	var agentConfigs map[string]llm.Config
+	if s.llmConfigResolver != nil {
+		agentConfigs, err = s.llmConfigResolver.AgentLLMConfigs(ctx, userID)
+		if err != nil {
+			logger.Logger.Warn("获取agent LLM配置失败，使用默认配置",
+				zap.String("error", err.Error()),
+			)
+			agentConfigs = nil
+		}
+	}
+
+	// 长期记忆：按本轮输入（和当前任务标题）挑选相关的若干条
+	var memories []memory.Memory
+	if s.memorySvc != nil {
+		query := userInput
+		if t != nil {
+			query = t.Title + " " + userInput
+		}
+		memories, err = s.memorySvc.Relevant(ctx, userID, query, maxPromptMemories)
+		if err != nil {
+			logger.Logger.Warn("获取长期记忆失败，将继续处理",
+				zap.String("error", err.Error()),
+			)
+			memories = nil
+		}
+	}
+
 	req := AgentRequest{
-		UserID:       userID,
-		Session:      sess,
-		Task:         t,
-		Tasks:        allTasks,
-		Dependencies: dependencies,
-		Messages:     msgs,
-		UserInput:    userInput,
-		Now:          time.Now(),
-		LLMConfig:    cfg,
+		UserID:           userID,
+		Session:          sess,
+		Task:             t,
+		Tasks:            allTasks,
+		DailyPlan:        todayPlan,
+		Dependencies:     dependencies,
+		EstimateAccuracy: accuracy,
+		Messages:         msgs,
+		UserInput:        userInput,
+		Profile:          prof,
+		Memories:         memories,
+		Now:              time.Now().In(profile.Location(prof)),
+		LLMConfig:        cfg,
+		AgentLLMConfigs:  agentConfigs,
 	}
 
 	agentName := s.router.Route(req)
@@ -149,6 +286,9 @@ func (s *Service) HandleUserMessage(
 		ag = s.agents["executor"]
 	}
 
+	req.LLMConfig = req.LLMConfigFor(ag.Name())
+	// 用量统计：本轮的 LLM 调用都记在该用户、会话和 agent 名下
+	req.Ctx = llm.WithCallInfo(ctx, llm.CallInfo{UserID: userID, SessionID: &sessionID, Agent: ag.Name()})
 	resp, err := ag.Handle(req)
 	if err != nil {
 		logger.Logger.Error("Agent处理失败",
@@ -167,6 +307,15 @@ func (s *Service) HandleUserMessage(
 	if err := s.sessionRepo.CreateMessage(ctx, &userMsg); err != nil {
 		return nil, fmt.Errorf("CreateMessage (user) failed: %w", err)
 	}
+	// 还没有命名过的会话用第一条用户消息命名
+	if !sess.Titled && sess.Title == "" {
+		if err := s.sessionRepo.SetTitleIfUntitled(ctx, sessionID, session.TitleFromMessage(userInput)); err != nil {
+			logger.Logger.Warn("设置会话标题失败",
+				zap.String("session_id", fmt.Sprintf("%d", sessionID)),
+				zap.String("error", err.Error()),
+			)
+		}
+	}
 
 	// 1. 开启事务: 应用 TaskPatches 更新 task & steps & dependencies
 	if len(resp.TaskPatches) > 0 {
@@ -207,6 +356,9 @@ func (s *Service) HandleUserMessage(
 		return nil, fmt.Errorf("CreateMessage failed: %w", err)
 	}
 
+	s.extractMemories(ctx, req, sessionID, userInput, resp.AssistantMessage)
+	s.compactHistory(ctx, req, sessionID)
+
 	logger.Logger.Info("Agent请求完成",
 		zap.String("agent", agentName),
 		zap.String("session_id", fmt.Sprintf("%d", sessionID)),
@@ -217,8 +369,84 @@ func (s *Service) HandleUserMessage(
 	return resp, nil
 }
 
+// loadHistory 读取提供给 agent 的历史：滚动摘要（作为第一条 system 消息）加预算内的最近消息。
+// 读取摘要失败时退回只取最近 20 条消息
+func (s *Service) loadHistory(ctx context.Context, sessionID uint64) ([]session.Message, error) {
+	if s.history != nil {
+		hc, err := s.history.Load(ctx, sessionID)
+		if err == nil {
+			return hc.AgentMessages(), nil
+		}
+		logger.Logger.Warn("按预算组装历史消息失败，只取最近消息",
+			zap.String("session_id", fmt.Sprintf("%d", sessionID)),
+			zap.String("error", err.Error()),
+		)
+	}
+	return s.sessionRepo.ListRecentMessages(ctx, sessionID, 20)
+}
+
+// compactHistory 在后台把超出预算的较早消息合并进滚动摘要，下一轮对话开始生效；失败只记录日志
+func (s *Service) compactHistory(ctx context.Context, req AgentRequest, sessionID uint64) {
+	if s.history == nil {
+		return
+	}
+	ctx = llm.WithCallInfo(context.WithoutCancel(ctx), llm.CallInfo{UserID: req.UserID, SessionID: &sessionID, Agent: "summarizer"})
+	cfg := req.LLMConfigFor("summarizer")
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+		updated, err := s.history.Compact(ctx, sessionID, cfg)
+		if err != nil {
+			logger.Logger.Warn("更新会话摘要失败",
+				zap.String("session_id", fmt.Sprintf("%d", sessionID)),
+				zap.String("error", err.Error()),
+			)
+			return
+		}
+		if updated {
+			logger.Logger.Info("已更新会话摘要",
+				zap.String("session_id", fmt.Sprintf("%d", sessionID)),
+			)
+		}
+	}()
+}
+
+// extractMemories 在后台从本轮对话中提取长期记忆，不阻塞回复；提取失败只记录日志
+func (s *Service) extractMemories(ctx context.Context, req AgentRequest, sessionID uint64, userInput, reply string) {
+	if s.memorySvc == nil {
+		return
+	}
+	// 请求结束后 ctx 会被取消，提取沿用其中的值（用户资料）但不随请求取消
+	ctx = llm.WithCallInfo(context.WithoutCancel(ctx), llm.CallInfo{UserID: req.UserID, SessionID: &sessionID, Agent: "memory"})
+	cfg := req.LLMConfigFor("memory")
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+		saved, err := s.memorySvc.Extract(ctx, req.UserID, &sessionID, cfg, userInput, reply)
+		if err != nil {
+			logger.Logger.Warn("提取长期记忆失败",
+				zap.String("session_id", fmt.Sprintf("%d", sessionID)),
+				zap.String("error", err.Error()),
+			)
+			return
+		}
+		if len(saved) > 0 {
+			logger.Logger.Info("已提取长期记忆",
+				zap.String("session_id", fmt.Sprintf("%d", sessionID)),
+				zap.Int("count", len(saved)),
+			)
+		}
+	}()
+}
+
 // applyTaskPatches 应用TaskPatches更新数据库
 func (s *Service) applyTaskPatches(ctx context.Context, userID uint64, tx *gorm.DB, patches []TaskPatch) error {
+	// 模型给出的任务和步骤 ID 必须属于当前用户
+	taskIDs, steps := patchRefs(patches)
+	if err := s.taskRepo.WithTx(tx).CheckOwnership(ctx, userID, taskIDs, steps); err != nil {
+		return err
+	}
+
 	for _, p := range patches {
 		switch p.Kind {
 		case PatchUpdateTask:
@@ -262,7 +490,45 @@ func (s *Service) applyTaskPatches(ctx context.Context, userID uint64, tx *gorm.
 			if fp == nil {
 				continue
 			}
-			if err := s.applyUpdateTasksFocusToday(ctx, userID, tx, fp.TaskIDs); err != nil {
+			if err := s.applyUpdateTasksFocusToday(ctx, userID, tx, fp); err != nil {
+				return err
+			}
+
+		case PatchProposeDailyPlan:
+			pp := p.ProposeDailyPlan
+			if pp == nil {
+				continue
+			}
+			plans := dailyplan.NewService(tx)
+			if _, err := plans.Propose(ctx, userID, plans.Today(ctx), planItemRefs(nil, pp.Items), pp.Note); err != nil {
+				return err
+			}
+
+		case PatchCreateTask:
+			cp := p.CreateTask
+			if cp == nil {
+				continue
+			}
+			if err := s.applyCreateTask(ctx, userID, tx, cp); err != nil {
+				return err
+			}
+
+		case PatchStartTimer:
+			tp := p.StartTimer
+			if tp == nil {
+				continue
+			}
+			if _, err := timetrack.NewService(tx).StartTimer(ctx, userID, tp.TaskID, tp.StepID, tp.Note); err != nil {
+				return err
+			}
+
+		case PatchStopTimer:
+			tp := p.StopTimer
+			if tp == nil {
+				continue
+			}
+			// 没有正在运行的计时器时忽略，不影响同一轮的其他修改
+			if _, err := timetrack.NewService(tx).StopTimer(ctx, userID, tp.TaskID, tp.StepID); err != nil && !errors.Is(err, timetrack.ErrNoRunningTimer) {
 				return err
 			}
 		}
@@ -398,7 +664,75 @@ func (s *Service) applyInsertDependencies(ctx context.Context, _ uint64, tx *gor
 	return repo.AddDependencies(ctx, deps)
 }
 
-func (s *Service) applyUpdateTasksFocusToday(ctx context.Context, userID uint64, tx *gorm.DB, taskIDs []uint64) error {
-	repo := s.taskRepo.WithTx(tx)
-	return repo.MarkTasksFocusToday(ctx, userID, taskIDs)
+// applyCreateTask 创建任务及其步骤，不带时区的截止时间按用户时区理解
+func (s *Service) applyCreateTask(ctx context.Context, userID uint64, tx *gorm.DB, cp *CreateTaskPatch) error {
+	t := &task.Task{
+		UserID:      userID,
+		Title:       cp.Title,
+		Description: cp.Description,
+		Status:      "todo",
+		Priority:    cp.Priority,
+		CreatedFrom: "agent",
+	}
+	if t.Priority == "" {
+		t.Priority = "medium"
+	}
+	if cp.DueAt != nil && *cp.DueAt != "" {
+		due, err := task.ParseFlexibleTimeIn(*cp.DueAt, task.LocationFromContext(ctx))
+		if err != nil {
+			return fmt.Errorf("invalid dueAt: %w", err)
+		}
+		t.DueAt = due
+	}
+	for i, st := range cp.Steps {
+		t.Steps = append(t.Steps, task.TaskStep{
+			Title:       st.Title,
+			Detail:      st.Detail,
+			EstimateMin: st.EstimateMinutes,
+			OrderIndex:  i,
+			Status:      "todo",
+		})
+	}
+	return s.taskRepo.WithTx(tx).InsertTaskWithSteps(ctx, t)
+}
+
+// applyUpdateTasksFocusToday 修改今天的计划，is_focus_today 由计划同步
+func (s *Service) applyUpdateTasksFocusToday(ctx context.Context, userID uint64, tx *gorm.DB, fp *MarkTasksFocusTodayPatch) error {
+	plans := dailyplan.NewService(tx)
+	date := plans.Today(ctx)
+	refs := planItemRefs(fp.TaskIDs, fp.Steps)
+
+	var err error
+	switch fp.Action {
+	case FocusUnmark:
+		_, err = plans.Unmark(ctx, userID, date, refs)
+	case FocusReorder:
+		_, err = plans.Reorder(ctx, userID, date, refs)
+	case FocusAccept:
+		// 没有待确认的计划时忽略，不影响同一轮的其他修改
+		if _, err = plans.Accept(ctx, userID, date); errors.Is(err, dailyplan.ErrNoPlan) {
+			err = nil
+		}
+	default:
+		_, err = plans.Mark(ctx, userID, date, refs)
+	}
+	if err != nil {
+		return err
+	}
+	if fp.Note != nil {
+		_, err = plans.SetNote(ctx, userID, date, *fp.Note)
+	}
+	return err
+}
+
+// planItemRefs 把任务 ID 和计划项转换为 dailyplan 的计划项，任务在前
+func planItemRefs(taskIDs []uint64, items []PlanItemRef) []dailyplan.ItemRef {
+	refs := make([]dailyplan.ItemRef, 0, len(taskIDs)+len(items))
+	for _, id := range taskIDs {
+		refs = append(refs, dailyplan.ItemRef{TaskID: id})
+	}
+	for _, it := range items {
+		refs = append(refs, dailyplan.ItemRef{TaskID: it.TaskID, StepID: it.StepID})
+	}
+	return refs
 }
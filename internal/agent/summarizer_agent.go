@@ -1,13 +1,12 @@
 package agent
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
-	"time"
 
 	"assistant-qisumi/internal/llm"
 	"assistant-qisumi/internal/logger"
+	"assistant-qisumi/internal/prompts"
 
 	"go.uber.org/zap"
 )
@@ -76,8 +75,9 @@ func (a *SummarizerAgent) Handle(req AgentRequest) (*AgentResponse, error) {
 	// 添加当前时间信息
 	messages = append(messages, llm.Message{
 		Role:    "system",
-		Content: "当前时间 now: " + req.Now.Format(time.RFC3339),
+		Content: prompts.NowMessage(req.Now),
 	})
+	messages = appendMemoryMessage(messages, req.Memories)
 
 	// 添加历史消息
 	messages = append(messages, historyToLLMMessages(req.Messages)...)
@@ -106,7 +106,7 @@ func (a *SummarizerAgent) Handle(req AgentRequest) (*AgentResponse, error) {
 	logger.Logger.Debug("发送Summarizer请求到LLM",
 		zap.String("model", req.LLMConfig.Model),
 	)
-	resp, err := a.llmClient.Chat(context.Background(), req.LLMConfig, chatReq)
+	resp, err := a.llmClient.Chat(req.Context(), req.LLMConfig, chatReq)
 	if err != nil {
 		logger.Logger.Error("Summarizer LLM调用失败",
 			zap.String("error", err.Error()),
@@ -1,12 +1,11 @@
 package agent
 
 import (
-	"context"
-	"time"
+	"errors"
 
-	"assistant-qisumi/internal/domain"
 	"assistant-qisumi/internal/llm"
 	"assistant-qisumi/internal/prompts"
+	"assistant-qisumi/internal/task"
 )
 
 type TaskCreationAgent struct {
@@ -28,44 +27,32 @@ func (a *TaskCreationAgent) Handle(req AgentRequest) (*AgentResponse, error) {
 		},
 		{
 			Role:    "system",
-			Content: "当前时间 now: " + req.Now.Format(time.RFC3339),
+			Content: prompts.NowMessage(req.Now),
 		},
-		{
-			Role:    "user",
-			Content: req.UserInput,
-		},
-	}
-
-	// 构造Chat请求
-	chatReq := llm.ChatRequest{
-		Model:    req.LLMConfig.Model,
-		Messages: messages,
-	}
-
-	// 调用LLM
-	resp, err := a.llmClient.Chat(context.Background(), req.LLMConfig, chatReq)
-	if err != nil {
-		return nil, err
 	}
+	// 长期记忆（例如常用的称呼、工作时间）帮助补全任务信息
+	messages = appendMemoryMessage(messages, req.Memories)
+	messages = append(messages, llm.Message{
+		Role:    "user",
+		Content: req.UserInput,
+	})
 
-	// 2. 处理 LLM 响应
-	if len(resp.Choices) == 0 {
+	// 2. 结构化输出 / 工具调用生成任务，输出不合规时会自动修正一次
+	output, err := task.GenerateTaskCreation(req.Context(), a.llmClient, req.LLMConfig, messages)
+	if errors.Is(err, task.ErrInvalidTaskCreation) {
 		return &AgentResponse{
-			AssistantMessage: "未能从文本生成任务，请重试。",
+			AssistantMessage: "未能从文本生成有效的任务数据，请补充更具体的信息后重试。",
 			TaskPatches:      []TaskPatch{},
 		}, nil
 	}
-
-	// 3. 使用 domain 包的共享逻辑解析 JSON 响应
-	output, err := domain.ParseTaskCreationResponse(resp.Choices[0].Message.Content)
 	if err != nil {
-		return &AgentResponse{
-			AssistantMessage: "未能解析生成的任务数据，请重试。",
-			TaskPatches:      []TaskPatch{},
-		}, nil
+		return nil, err
 	}
 
-	// 4. 生成 TaskPatches
+	// 模型输出的不带时区的时间按用户时区理解
+	output.DueAt.AssumeLocation(req.Now.Location())
+
+	// 3. 生成 TaskPatches
 	patches := []TaskPatch{
 		{
 			Kind: PatchCreateTask,
@@ -0,0 +1,42 @@
+package agent
+
+import "assistant-qisumi/internal/task"
+
+// AddDependenciesArgs 对应 tool: add_dependencies
+type AddDependenciesArgs struct {
+	Items []DependencyItemArgs `json:"items"`
+}
+
+// DependencyItemArgs 与 task.DependencyItem 字段相同，但使用模型参数的蛇形命名
+type DependencyItemArgs struct {
+	PredecessorTaskID uint64  `json:"predecessor_task_id"`
+	PredecessorStepID *uint64 `json:"predecessor_step_id" description:"Optional step ID. If null, the whole task is the predecessor."`
+	SuccessorTaskID   uint64  `json:"successor_task_id"`
+	SuccessorStepID   *uint64 `json:"successor_step_id" description:"Optional step ID. If null, the whole task is the successor."`
+	Condition         string  `json:"condition" enum:"task_done,step_done"`
+	Action            string  `json:"action" enum:"unlock_step,set_task_todo,notify_only"`
+}
+
+func init() {
+	registerTool(ToolSpec[AddDependenciesArgs]{
+		Name:        "add_dependencies",
+		Description: "Create dependencies between tasks or steps. When the predecessor is done, the successor can be unlocked or activated.",
+		Patches: func(a *AddDependenciesArgs) []TaskPatch {
+			items := make([]task.DependencyItem, 0, len(a.Items))
+			for _, it := range a.Items {
+				items = append(items, task.DependencyItem{
+					PredecessorTaskID: it.PredecessorTaskID,
+					PredecessorStepID: it.PredecessorStepID,
+					SuccessorTaskID:   it.SuccessorTaskID,
+					SuccessorStepID:   it.SuccessorStepID,
+					Condition:         it.Condition,
+					Action:            it.Action,
+				})
+			}
+			return []TaskPatch{{
+				Kind:            PatchAddDependencies,
+				AddDependencies: &AddDependenciesPatch{Items: items},
+			}}
+		},
+	})
+}
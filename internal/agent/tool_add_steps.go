@@ -0,0 +1,43 @@
+package agent
+
+import "assistant-qisumi/internal/task"
+
+// AddStepsArgs 对应 tool: add_steps
+type AddStepsArgs struct {
+	TaskID       uint64             `json:"task_id"`
+	ParentStepID *uint64            `json:"parent_step_id" description:"Optional parent step ID for substeps. Use null for top-level steps."`
+	Steps        []NewStepArgsInput `json:"steps"`
+}
+
+type NewStepArgsInput struct {
+	Title             string  `json:"title"`
+	Detail            string  `json:"detail,omitempty"`
+	EstimateMinutes   *int    `json:"estimate_minutes" minimum:"1"`
+	InsertAfterStepID *uint64 `json:"insert_after_step_id" description:"Insert after this step. If null, append to the end."`
+}
+
+func init() {
+	registerTool(ToolSpec[AddStepsArgs]{
+		Name:        "add_steps",
+		Description: "Add new steps to an existing task.",
+		Patches: func(a *AddStepsArgs) []TaskPatch {
+			records := make([]task.NewStepRecord, 0, len(a.Steps))
+			for _, s := range a.Steps {
+				records = append(records, task.NewStepRecord{
+					Title:             s.Title,
+					Detail:            s.Detail,
+					EstimateMinutes:   s.EstimateMinutes,
+					InsertAfterStepID: s.InsertAfterStepID,
+				})
+			}
+			return []TaskPatch{{
+				Kind: PatchAddSteps,
+				AddSteps: &AddStepsPatch{
+					TaskID:        a.TaskID,
+					ParentStepID:  a.ParentStepID,
+					StepsToInsert: records,
+				},
+			}}
+		},
+	})
+}
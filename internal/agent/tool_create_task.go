@@ -0,0 +1,49 @@
+package agent
+
+import "assistant-qisumi/internal/task"
+
+// CreateTaskArgs 对应 tool: create_task
+type CreateTaskArgs struct {
+	Title       string               `json:"title"`
+	Description string               `json:"description,omitempty"`
+	DueAt       *string              `json:"due_at" description:"ISO8601 time, null if there is no deadline"`
+	Priority    *string              `json:"priority" enum:"low,medium,high" description:"Defaults to medium"`
+	Steps       []CreateTaskStepArgs `json:"steps,omitempty"`
+}
+
+type CreateTaskStepArgs struct {
+	Title           string `json:"title"`
+	Detail          string `json:"detail,omitempty"`
+	EstimateMinutes *int   `json:"estimate_minutes" minimum:"1"`
+}
+
+func init() {
+	registerTool(ToolSpec[CreateTaskArgs]{
+		Name:        "create_task",
+		Description: "Create a new task for the user, optionally with steps. Only use it when the user asks for a new task that does not exist yet.",
+		Patches: func(a *CreateTaskArgs) []TaskPatch {
+			priority := "medium"
+			if a.Priority != nil {
+				priority = *a.Priority
+			}
+			steps := make([]task.NewStepRecord, 0, len(a.Steps))
+			for _, s := range a.Steps {
+				steps = append(steps, task.NewStepRecord{
+					Title:           s.Title,
+					Detail:          s.Detail,
+					EstimateMinutes: s.EstimateMinutes,
+				})
+			}
+			return []TaskPatch{{
+				Kind: PatchCreateTask,
+				CreateTask: &CreateTaskPatch{
+					Title:       a.Title,
+					Description: a.Description,
+					DueAt:       a.DueAt,
+					Priority:    priority,
+					Steps:       steps,
+				},
+			}}
+		},
+	})
+}
@@ -0,0 +1,30 @@
+package agent
+
+import (
+	"context"
+	"errors"
+)
+
+// GetDependenciesArgs 对应 tool: get_dependencies
+type GetDependenciesArgs struct {
+	TaskID *uint64 `json:"task_id" description:"Only dependencies where this task is the predecessor or the successor. If null, all dependencies of the user."`
+}
+
+func init() {
+	registerTool(ToolSpec[GetDependenciesArgs]{
+		Name:        "get_dependencies",
+		Description: "Get dependencies between tasks and steps: what must be done before what, and what happens when it is done.",
+		ReadOnly:    true,
+		Execute: func(ctx context.Context, deps ToolDeps, req AgentRequest, a *GetDependenciesArgs) (any, error) {
+			if deps.TaskRepo == nil {
+				return nil, errors.New("get_dependencies is not available")
+			}
+			if a.TaskID == nil {
+				items, err := deps.TaskRepo.GetAllUserDependencies(ctx, req.UserID)
+				return map[string]any{"dependencies": items}, err
+			}
+			items, err := deps.TaskRepo.GetTaskDependencies(ctx, req.UserID, *a.TaskID)
+			return map[string]any{"dependencies": items}, err
+		},
+	})
+}
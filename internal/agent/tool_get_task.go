@@ -0,0 +1,45 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// GetTaskArgs 对应 tool: get_task
+type GetTaskArgs struct {
+	TaskID uint64 `json:"task_id"`
+}
+
+// toolErrorResult 查询不到数据等可由模型自行处理的错误，作为工具结果返回而不是中断对话
+type toolErrorResult struct {
+	Error string `json:"error"`
+}
+
+func taskNotFound(taskID uint64) toolErrorResult {
+	return toolErrorResult{Error: fmt.Sprintf("task %d not found", taskID)}
+}
+
+func init() {
+	registerTool(ToolSpec[GetTaskArgs]{
+		Name:        "get_task",
+		Description: "Get one task with all its steps (status, estimates, planned times, blocking reasons).",
+		ReadOnly:    true,
+		Execute: func(ctx context.Context, deps ToolDeps, req AgentRequest, a *GetTaskArgs) (any, error) {
+			if deps.TaskRepo == nil {
+				return nil, errors.New("get_task is not available")
+			}
+			t, err := deps.TaskRepo.GetTaskWithSteps(ctx, req.UserID, a.TaskID)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return taskNotFound(a.TaskID), nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			t.ToLocation(req.Location())
+			return t, nil
+		},
+	})
+}
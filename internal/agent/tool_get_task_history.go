@@ -0,0 +1,110 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"assistant-qisumi/internal/task"
+
+	"gorm.io/gorm"
+)
+
+const defaultTaskHistoryLimit = 20
+
+// GetTaskHistoryArgs 对应 tool: get_task_history
+type GetTaskHistoryArgs struct {
+	TaskID uint64 `json:"task_id"`
+	Limit  *int   `json:"limit" minimum:"1" maximum:"100" description:"Maximum number of conversation messages, default 20"`
+}
+
+// TaskHistory get_task_history 的返回结果
+type TaskHistory struct {
+	TaskID         uint64               `json:"task_id"`
+	Title          string               `json:"title"`
+	Status         string               `json:"status"`
+	CreatedAt      string               `json:"created_at"`
+	CompletedAt    *string              `json:"completed_at,omitempty"`
+	CompletedSteps []CompletedStepEntry `json:"completed_steps"`
+	Messages       []TaskHistoryMessage `json:"messages"`
+}
+
+// CompletedStepEntry 已完成的步骤，按完成时间排序
+type CompletedStepEntry struct {
+	ID          uint64 `json:"id"`
+	Title       string `json:"title"`
+	CompletedAt string `json:"completed_at"`
+}
+
+// TaskHistoryMessage 任务会话中的一条消息
+type TaskHistoryMessage struct {
+	Role      string `json:"role"`
+	Content   string `json:"content"`
+	CreatedAt string `json:"created_at"`
+}
+
+func executeGetTaskHistory(ctx context.Context, deps ToolDeps, req AgentRequest, a *GetTaskHistoryArgs) (any, error) {
+	if deps.TaskRepo == nil || deps.SessionRepo == nil {
+		return nil, errors.New("get_task_history is not available")
+	}
+	t, err := deps.TaskRepo.GetTaskWithSteps(ctx, req.UserID, a.TaskID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return taskNotFound(a.TaskID), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	loc := req.Location()
+	t.ToLocation(loc)
+
+	out := TaskHistory{
+		TaskID:         t.ID,
+		Title:          t.Title,
+		Status:         t.Status,
+		CreatedAt:      t.CreatedAt.Format(time.RFC3339),
+		CompletedSteps: []CompletedStepEntry{},
+		Messages:       []TaskHistoryMessage{},
+	}
+	if t.CompletedAt != nil {
+		done := t.CompletedAt.Format(time.RFC3339)
+		out.CompletedAt = &done
+	}
+	var done []task.TaskStep
+	for _, st := range t.Steps {
+		if st.Status == "done" && st.CompletedAt != nil {
+			done = append(done, st)
+		}
+	}
+	sort.SliceStable(done, func(i, j int) bool { return done[i].CompletedAt.Before(*done[j].CompletedAt) })
+	for _, st := range done {
+		out.CompletedSteps = append(out.CompletedSteps, CompletedStepEntry{
+			ID: st.ID, Title: st.Title, CompletedAt: st.CompletedAt.Format(time.RFC3339),
+		})
+	}
+
+	// 任务可能有多个会话，取所有会话中最近的消息
+	limit := defaultTaskHistoryLimit
+	if a.Limit != nil {
+		limit = *a.Limit
+	}
+	msgs, err := deps.SessionRepo.ListRecentTaskMessages(ctx, req.UserID, t.ID, limit)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range msgs {
+		out.Messages = append(out.Messages, TaskHistoryMessage{
+			Role: m.Role, Content: m.Content, CreatedAt: m.CreatedAt.In(loc).Format(time.RFC3339),
+		})
+	}
+	return out, nil
+}
+
+func init() {
+	registerTool(ToolSpec[GetTaskHistoryArgs]{
+		Name:        "get_task_history",
+		Description: "Get the history of one task: when it was created and completed, completed steps in order, and the most recent messages of its conversations.",
+		ReadOnly:    true,
+		Execute:     executeGetTaskHistory,
+	})
+}
@@ -0,0 +1,107 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"assistant-qisumi/internal/domain"
+	"assistant-qisumi/internal/task"
+)
+
+const (
+	defaultListTasksLimit = 50
+	maxListTasksLimit     = 100
+)
+
+// ListTasksArgs 对应 tool: list_tasks
+type ListTasksArgs struct {
+	Status     []string `json:"status,omitempty" enum:"todo,in_progress,done,cancelled" description:"Only tasks with these statuses. Defaults to todo and in_progress."`
+	Priority   *string  `json:"priority" enum:"low,medium,high"`
+	FocusToday *bool    `json:"focus_today" description:"Only tasks that are (true) or are not (false) marked as today's focus"`
+	DueBefore  *string  `json:"due_before" description:"ISO8601 time. Only tasks due before this time."`
+	Limit      *int     `json:"limit" minimum:"1" maximum:"100" description:"Maximum number of tasks, default 50"`
+}
+
+// TaskSummary list_tasks 返回的任务摘要，不含步骤详情
+type TaskSummary struct {
+	ID         uint64  `json:"id"`
+	Title      string  `json:"title"`
+	Status     string  `json:"status"`
+	Priority   string  `json:"priority"`
+	DueAt      *string `json:"due_at,omitempty"`
+	FocusToday bool    `json:"focus_today"`
+	StepsDone  int     `json:"steps_done"`
+	StepsTotal int     `json:"steps_total"`
+}
+
+// summarizeTask 生成任务摘要，时间按 loc（用户时区）展示
+func summarizeTask(t task.Task, loc *time.Location) TaskSummary {
+	s := TaskSummary{
+		ID:         t.ID,
+		Title:      t.Title,
+		Status:     t.Status,
+		Priority:   t.Priority,
+		FocusToday: t.IsFocusToday,
+		StepsTotal: len(t.Steps),
+	}
+	if t.DueAt != nil {
+		due := t.DueAt.ToTime().In(loc).Format(time.RFC3339)
+		s.DueAt = &due
+	}
+	for _, st := range t.Steps {
+		if st.Status == "done" {
+			s.StepsDone++
+		}
+	}
+	return s
+}
+
+func executeListTasks(ctx context.Context, deps ToolDeps, req AgentRequest, a *ListTasksArgs) (any, error) {
+	if deps.TaskRepo == nil {
+		return nil, errors.New("list_tasks is not available")
+	}
+	filter := task.TaskFilter{
+		Statuses:   a.Status,
+		FocusToday: a.FocusToday,
+		Limit:      defaultListTasksLimit,
+	}
+	if len(filter.Statuses) == 0 {
+		filter.Statuses = []string{"todo", "in_progress"}
+	}
+	if a.Priority != nil {
+		filter.Priority = *a.Priority
+	}
+	if a.Limit != nil {
+		filter.Limit = min(*a.Limit, maxListTasksLimit)
+	}
+	loc := req.Location()
+	if a.DueBefore != nil && *a.DueBefore != "" {
+		ft, err := domain.ParseFlexibleTimeIn(*a.DueBefore, loc)
+		if err != nil {
+			return toolErrorResult{Error: fmt.Sprintf("invalid due_before: %v", err)}, nil
+		}
+		due := ft.ToTime()
+		filter.DueBefore = &due
+	}
+
+	tasks, err := deps.TaskRepo.FindTasks(ctx, req.UserID, filter)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]TaskSummary, 0, len(tasks))
+	for _, t := range tasks {
+		out = append(out, summarizeTask(t, loc))
+	}
+	return map[string]any{"tasks": out}, nil
+}
+
+func init() {
+	registerTool(ToolSpec[ListTasksArgs]{
+		Name:        "list_tasks",
+		Description: "List the user's tasks as compact summaries (no step details), sorted by due time. Use get_task for the steps of one task.",
+		ReadOnly:    true,
+		Execute:     executeListTasks,
+	})
+}
@@ -0,0 +1,36 @@
+package agent
+
+// MarkTasksFocusTodayArgs 对应 tool: mark_tasks_focus_today
+type MarkTasksFocusTodayArgs struct {
+	Action  *string        `json:"action" enum:"mark,unmark,reorder,accept" description:"mark (default) adds to today's plan, unmark removes, reorder puts the given items first in the given order, accept confirms a proposed plan"`
+	TaskIDs []uint64       `json:"task_ids,omitempty"`
+	Steps   []PlanStepArgs `json:"steps,omitempty" description:"Individual steps, placed after task_ids"`
+	Note    *string        `json:"note" description:"Optional note for today's plan"`
+}
+
+// PlanStepArgs 计划中的一个步骤
+type PlanStepArgs struct {
+	TaskID uint64 `json:"task_id"`
+	StepID uint64 `json:"step_id"`
+}
+
+func init() {
+	registerTool(ToolSpec[MarkTasksFocusTodayArgs]{
+		Name:        "mark_tasks_focus_today",
+		Description: "Change today's plan (the user's focus for today): add, remove or reorder tasks and steps, or accept the plan proposed earlier.",
+		Patches: func(a *MarkTasksFocusTodayArgs) []TaskPatch {
+			patch := &MarkTasksFocusTodayPatch{TaskIDs: a.TaskIDs, Note: a.Note}
+			if a.Action != nil {
+				patch.Action = *a.Action
+			}
+			for _, st := range a.Steps {
+				stepID := st.StepID
+				patch.Steps = append(patch.Steps, PlanItemRef{TaskID: st.TaskID, StepID: &stepID})
+			}
+			return []TaskPatch{{
+				Kind:                PatchMarkTasksFocusToday,
+				MarkTasksFocusToday: patch,
+			}}
+		},
+	})
+}
@@ -0,0 +1,30 @@
+package agent
+
+// ProposeDailyPlanArgs 对应 tool: propose_daily_plan
+type ProposeDailyPlanArgs struct {
+	Items []PlanItemArgs `json:"items" description:"Tasks and steps for the day, in the suggested order"`
+	Note  string         `json:"note,omitempty" description:"Short note for the day, e.g. the main goal"`
+}
+
+// PlanItemArgs 计划中的任务或步骤
+type PlanItemArgs struct {
+	TaskID uint64  `json:"task_id"`
+	StepID *uint64 `json:"step_id" description:"Optional step ID. If null, the whole task."`
+}
+
+func init() {
+	registerTool(ToolSpec[ProposeDailyPlanArgs]{
+		Name:        "propose_daily_plan",
+		Description: "Propose today's plan for the user to confirm. It replaces today's plan and takes effect after the user accepts it (mark_tasks_focus_today with action=accept).",
+		Patches: func(a *ProposeDailyPlanArgs) []TaskPatch {
+			patch := &ProposeDailyPlanPatch{Note: a.Note, Items: make([]PlanItemRef, 0, len(a.Items))}
+			for _, it := range a.Items {
+				patch.Items = append(patch.Items, PlanItemRef{TaskID: it.TaskID, StepID: it.StepID})
+			}
+			return []TaskPatch{{
+				Kind:             PatchProposeDailyPlan,
+				ProposeDailyPlan: patch,
+			}}
+		},
+	})
+}
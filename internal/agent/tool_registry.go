@@ -0,0 +1,201 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"assistant-qisumi/internal/jsonschema"
+	"assistant-qisumi/internal/llm"
+	"assistant-qisumi/internal/session"
+	"assistant-qisumi/internal/task"
+)
+
+// 工具注册
+//
+// 每个工具在自己的文件（tool_<name>.go）中通过 init 调用 registerTool，声明名称、说明、参数结构体、
+// 执行逻辑和 TaskPatch 转换；参数 Schema 由参数结构体生成（见 jsonschema.Reflect）。
+// Agent 只声明自己使用的工具名，由 ToolRegistry 解析为发给模型的工具定义。
+
+// ToolDeps 工具执行时可以使用的依赖
+type ToolDeps struct {
+	TaskRepo    *task.Repository
+	SessionRepo *session.Repository
+}
+
+// ToolSpec 一个工具的完整定义，A 为参数结构体
+type ToolSpec[A any] struct {
+	Name        string
+	Description string
+
+	// ReadOnly 只读取数据的工具；模型调用后可以继续调用其他工具
+	ReadOnly bool
+
+	// Execute 执行工具，返回值序列化后作为工具结果交给模型；为空时返回 {"success": true}
+	// 返回值实现 PatchProducer 时，其 TaskPatch 会随本轮回复一起应用
+	Execute func(ctx context.Context, deps ToolDeps, req AgentRequest, args *A) (any, error)
+
+	// Patches 把参数转换为 TaskPatch；只读或不直接修改数据的工具可以为空
+	Patches func(args *A) []TaskPatch
+}
+
+// ToolExecutor 工具执行器接口
+// req 提供当前用户、任务和时间等上下文，供需要读取数据的工具使用
+type ToolExecutor interface {
+	Execute(ctx context.Context, req AgentRequest, args string) (interface{}, error)
+}
+
+// PatchProducer 工具执行结果如果实现了该接口，其返回的 TaskPatch 会随本轮回复一起应用
+type PatchProducer interface {
+	TaskPatches() []TaskPatch
+}
+
+// toolEntry 去掉参数类型后的工具定义
+type toolEntry struct {
+	definition llm.Tool
+	readOnly   bool
+	execute    func(ctx context.Context, deps ToolDeps, req AgentRequest, args string) (any, error)
+	patches    func(args string) ([]TaskPatch, error)
+}
+
+var toolEntries = map[string]*toolEntry{}
+
+// registerTool 注册工具，名称重复时 panic
+func registerTool[A any](spec ToolSpec[A]) {
+	if _, ok := toolEntries[spec.Name]; ok {
+		panic(fmt.Sprintf("tool %s registered twice", spec.Name))
+	}
+	var zero A
+	params, err := json.Marshal(jsonschema.Reflect(zero))
+	if err != nil {
+		panic(fmt.Sprintf("tool %s schema: %v", spec.Name, err))
+	}
+
+	decode := func(raw string) (*A, error) {
+		args := new(A)
+		if raw == "" {
+			return args, nil
+		}
+		if err := json.Unmarshal([]byte(raw), args); err != nil {
+			return nil, fmt.Errorf("%s args decode: %w", spec.Name, err)
+		}
+		return args, nil
+	}
+
+	toolEntries[spec.Name] = &toolEntry{
+		definition: llm.Tool{
+			Type:     "function",
+			Function: llm.ToolFunction{Name: spec.Name, Description: spec.Description, Parameters: params},
+		},
+		readOnly: spec.ReadOnly,
+		execute: func(ctx context.Context, deps ToolDeps, req AgentRequest, raw string) (any, error) {
+			args, err := decode(raw)
+			if err != nil {
+				return nil, err
+			}
+			if spec.Execute == nil {
+				return map[string]interface{}{"success": true}, nil
+			}
+			return spec.Execute(ctx, deps, req, args)
+		},
+		patches: func(raw string) ([]TaskPatch, error) {
+			if spec.Patches == nil {
+				return nil, nil
+			}
+			args, err := decode(raw)
+			if err != nil {
+				return nil, err
+			}
+			return spec.Patches(args), nil
+		},
+	}
+}
+
+// ToolRegistry 绑定了依赖的全部已注册工具
+type ToolRegistry struct {
+	deps ToolDeps
+}
+
+func NewToolRegistry(taskRepo *task.Repository, sessionRepo *session.Repository) *ToolRegistry {
+	return &ToolRegistry{deps: ToolDeps{TaskRepo: taskRepo, SessionRepo: sessionRepo}}
+}
+
+// Names 返回全部已注册的工具名
+func (r *ToolRegistry) Names() []string {
+	names := make([]string, 0, len(toolEntries))
+	for name := range toolEntries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Definitions 按顺序返回工具定义，存在未注册的工具名时返回错误
+func (r *ToolRegistry) Definitions(names []string) ([]llm.Tool, error) {
+	tools := make([]llm.Tool, 0, len(names))
+	for _, name := range names {
+		entry, ok := toolEntries[name]
+		if !ok {
+			return nil, fmt.Errorf("tool %s is not registered", name)
+		}
+		tools = append(tools, entry.definition)
+	}
+	return tools, nil
+}
+
+// IsReadOnly 工具是否只读取数据
+func (r *ToolRegistry) IsReadOnly(name string) bool {
+	entry, ok := toolEntries[name]
+	return ok && entry.readOnly
+}
+
+// Executor 返回工具的执行器
+func (r *ToolRegistry) Executor(name string) (ToolExecutor, bool) {
+	entry, ok := toolEntries[name]
+	if !ok {
+		return nil, false
+	}
+	return &boundTool{entry: entry, deps: r.deps}, true
+}
+
+// TaskPatches 把一次工具调用的参数转换为 TaskPatch，其中的时间参数按 req.Now 和用户时区统一为 RFC3339；
+// 返回的错误（无法解码的参数、无法理解的时间）应交给模型修正
+func (r *ToolRegistry) TaskPatches(req AgentRequest, call llm.ToolCall) ([]TaskPatch, error) {
+	entry, ok := toolEntries[call.Function.Name]
+	if !ok {
+		return nil, fmt.Errorf("tool %s is not registered", call.Function.Name)
+	}
+	patches, err := entry.patches(call.Function.Arguments)
+	if err != nil {
+		return nil, err
+	}
+	now := req.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	if err := normalizeTimes(patches, now); err != nil {
+		return nil, err
+	}
+	return patches, nil
+}
+
+// CheckOwnership 校验 Patch 引用的任务和步骤都属于当前用户，未配置数据库时跳过
+func (r *ToolRegistry) CheckOwnership(ctx context.Context, userID uint64, patches []TaskPatch) error {
+	if r.deps.TaskRepo == nil {
+		return nil
+	}
+	taskIDs, steps := patchRefs(patches)
+	return r.deps.TaskRepo.CheckOwnership(ctx, userID, taskIDs, steps)
+}
+
+// boundTool 绑定了依赖的工具执行器
+type boundTool struct {
+	entry *toolEntry
+	deps  ToolDeps
+}
+
+func (t *boundTool) Execute(ctx context.Context, req AgentRequest, args string) (interface{}, error) {
+	return t.entry.execute(ctx, t.deps, req, args)
+}
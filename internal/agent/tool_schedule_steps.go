@@ -0,0 +1,133 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"assistant-qisumi/internal/domain"
+	"assistant-qisumi/internal/profile"
+	"assistant-qisumi/internal/scheduler"
+)
+
+// ScheduleStepsArgs 对应 tool: schedule_steps
+type ScheduleStepsArgs struct {
+	TaskIDs                []uint64 `json:"task_ids,omitempty" description:"Tasks to schedule. Defaults to the current task, or all unfinished tasks when there is no current task."`
+	StartFrom              *string  `json:"start_from" description:"ISO8601 time to start scheduling from, defaults to now"`
+	WorkStart              *string  `json:"work_start" description:"Working day start, HH:MM, default 09:00"`
+	WorkEnd                *string  `json:"work_end" description:"Working day end, HH:MM, default 18:00"`
+	WorkDays               []int    `json:"work_days,omitempty" minimum:"1" maximum:"7" description:"Working weekdays, 1 = Monday ... 7 = Sunday, default Monday to Friday"`
+	DefaultEstimateMinutes *int     `json:"default_estimate_minutes" description:"Duration used for steps without an estimate, default 30"`
+	Apply                  *bool    `json:"apply" description:"Write the planned times to the steps. Use false to only preview."` // 默认写回步骤
+}
+
+// ScheduleStepsResult schedule_steps 的返回结果，Applied 为 true 时排期会写回步骤
+type ScheduleStepsResult struct {
+	*scheduler.Result
+	Applied bool `json:"applied"`
+	patches []TaskPatch
+}
+
+// TaskPatches 实现 PatchProducer
+func (r *ScheduleStepsResult) TaskPatches() []TaskPatch { return r.patches }
+
+// executeScheduleSteps 调用排期引擎为步骤计算计划时间
+// 排期结果由引擎确定性地给出，模型只负责解释，不再自行编造时间
+func executeScheduleSteps(ctx context.Context, deps ToolDeps, req AgentRequest, a *ScheduleStepsArgs) (any, error) {
+	if deps.TaskRepo == nil {
+		return nil, errors.New("schedule_steps is not available")
+	}
+
+	now := req.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	in := scheduler.Input{
+		TaskIDs: a.TaskIDs,
+		Hours:   scheduler.DefaultWorkingHours(now.Location()),
+		Start:   now,
+	}
+	if req.Profile != nil {
+		in.Hours = profile.WorkingHours(req.Profile)
+	}
+	if len(in.TaskIDs) == 0 && req.Task != nil {
+		in.TaskIDs = []uint64{req.Task.ID}
+	}
+	if a.StartFrom != nil && *a.StartFrom != "" {
+		ft, err := domain.ParseFlexibleTimeIn(*a.StartFrom, now.Location())
+		if err != nil {
+			return toolErrorResult{Error: fmt.Sprintf("invalid start_from: %v", err)}, nil
+		}
+		if ft.ToTime().After(now) {
+			in.Start = ft.ToTime()
+		}
+	}
+	if a.WorkStart != nil && *a.WorkStart != "" {
+		d, err := scheduler.ParseClock(*a.WorkStart)
+		if err != nil {
+			return toolErrorResult{Error: fmt.Sprintf("invalid work_start: %v", err)}, nil
+		}
+		in.Hours.Start = d
+	}
+	if a.WorkEnd != nil && *a.WorkEnd != "" {
+		d, err := scheduler.ParseClock(*a.WorkEnd)
+		if err != nil {
+			return toolErrorResult{Error: fmt.Sprintf("invalid work_end: %v", err)}, nil
+		}
+		in.Hours.End = d
+	}
+	if len(a.WorkDays) > 0 {
+		in.Hours.Days = nil
+		for _, d := range a.WorkDays {
+			if d < 1 || d > 7 {
+				return toolErrorResult{Error: fmt.Sprintf("invalid work day %d, expected 1-7", d)}, nil
+			}
+			in.Hours.Days = append(in.Hours.Days, time.Weekday(d%7))
+		}
+	}
+	if a.DefaultEstimateMinutes != nil {
+		in.DefaultEstimateMin = *a.DefaultEstimateMinutes
+	}
+
+	tasks, err := deps.TaskRepo.ListOpenTasksWithSteps(ctx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	dependencies, err := deps.TaskRepo.GetAllUserDependencies(ctx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	in.Tasks = tasks
+	in.Dependencies = dependencies
+
+	// Schedule 只因参数不合法（例如工作时间）失败，交给模型修正后重试
+	res, err := scheduler.Schedule(in)
+	if err != nil {
+		return toolErrorResult{Error: err.Error()}, nil
+	}
+
+	out := &ScheduleStepsResult{Result: res, Applied: a.Apply == nil || *a.Apply}
+	if out.Applied {
+		for _, it := range res.Items {
+			start, end := it.Start.Format(time.RFC3339), it.End.Format(time.RFC3339)
+			out.patches = append(out.patches, TaskPatch{
+				Kind: PatchUpdateStep,
+				UpdateStep: &UpdateStepPatch{
+					TaskID: it.TaskID,
+					StepID: it.StepID,
+					Fields: domain.UpdateStepFields{PlannedStart: &start, PlannedEnd: &end},
+				},
+			})
+		}
+	}
+	return out, nil
+}
+
+func init() {
+	registerTool(ToolSpec[ScheduleStepsArgs]{
+		Name:        "schedule_steps",
+		Description: "Compute planned_start/planned_end for unfinished steps with a deterministic scheduler. It respects step order, dependencies, estimates, priorities, due dates and the user's working hours, and never overlaps steps. Always use this instead of inventing times yourself, then explain the returned schedule and warnings to the user.",
+		Execute:     executeScheduleSteps,
+	})
+}
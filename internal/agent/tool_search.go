@@ -0,0 +1,66 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+const defaultSearchLimit = 10
+
+// SearchArgs 对应 tool: search
+type SearchArgs struct {
+	Query string `json:"query" description:"Keyword to look for in task titles, descriptions and step titles/details (case-insensitive)"`
+	Limit *int   `json:"limit" minimum:"1" maximum:"50" description:"Maximum number of tasks, default 10"`
+}
+
+// SearchHit 搜索命中的任务及其中命中的步骤
+type SearchHit struct {
+	TaskSummary
+	MatchedSteps []SearchStepHit `json:"matched_steps,omitempty"`
+}
+
+// SearchStepHit 命中的步骤
+type SearchStepHit struct {
+	ID     uint64 `json:"id"`
+	Title  string `json:"title"`
+	Status string `json:"status"`
+}
+
+func init() {
+	registerTool(ToolSpec[SearchArgs]{
+		Name:        "search",
+		Description: "Search all tasks of the user, including finished ones, by keyword. Returns matching tasks and the steps that matched.",
+		ReadOnly:    true,
+		Execute: func(ctx context.Context, deps ToolDeps, req AgentRequest, a *SearchArgs) (any, error) {
+			if deps.TaskRepo == nil {
+				return nil, errors.New("search is not available")
+			}
+			query := strings.TrimSpace(a.Query)
+			if query == "" {
+				return toolErrorResult{Error: "query must not be empty"}, nil
+			}
+			limit := defaultSearchLimit
+			if a.Limit != nil {
+				limit = *a.Limit
+			}
+			tasks, err := deps.TaskRepo.SearchTasks(ctx, req.UserID, query, limit)
+			if err != nil {
+				return nil, err
+			}
+
+			lower, loc := strings.ToLower(query), req.Location()
+			hits := make([]SearchHit, 0, len(tasks))
+			for _, t := range tasks {
+				hit := SearchHit{TaskSummary: summarizeTask(t, loc)}
+				for _, st := range t.Steps {
+					if strings.Contains(strings.ToLower(st.Title), lower) || strings.Contains(strings.ToLower(st.Detail), lower) {
+						hit.MatchedSteps = append(hit.MatchedSteps, SearchStepHit{ID: st.ID, Title: st.Title, Status: st.Status})
+					}
+				}
+				hits = append(hits, hit)
+			}
+			return map[string]any{"results": hits}, nil
+		},
+	})
+}
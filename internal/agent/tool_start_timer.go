@@ -0,0 +1,21 @@
+package agent
+
+// StartTimerArgs 对应 tool: start_timer
+type StartTimerArgs struct {
+	TaskID uint64  `json:"task_id"`
+	StepID *uint64 `json:"step_id" description:"Optional step ID. If null, time is tracked on the whole task."`
+	Note   string  `json:"note,omitempty"`
+}
+
+func init() {
+	registerTool(ToolSpec[StartTimerArgs]{
+		Name:        "start_timer",
+		Description: "Start tracking time on a task or one of its steps. Any other running timer of the user is stopped first.",
+		Patches: func(a *StartTimerArgs) []TaskPatch {
+			return []TaskPatch{{
+				Kind:       PatchStartTimer,
+				StartTimer: &StartTimerPatch{TaskID: a.TaskID, StepID: a.StepID, Note: a.Note},
+			}}
+		},
+	})
+}
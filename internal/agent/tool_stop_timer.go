@@ -0,0 +1,20 @@
+package agent
+
+// StopTimerArgs 对应 tool: stop_timer
+type StopTimerArgs struct {
+	TaskID *uint64 `json:"task_id"`
+	StepID *uint64 `json:"step_id"`
+}
+
+func init() {
+	registerTool(ToolSpec[StopTimerArgs]{
+		Name:        "stop_timer",
+		Description: "Stop the user's running timer and record the elapsed time. Optionally only stop the timer on a specific task or step.",
+		Patches: func(a *StopTimerArgs) []TaskPatch {
+			return []TaskPatch{{
+				Kind:      PatchStopTimer,
+				StopTimer: &StopTimerPatch{TaskID: a.TaskID, StepID: a.StepID},
+			}}
+		},
+	})
+}
@@ -0,0 +1,56 @@
+package agent
+
+import "assistant-qisumi/internal/domain"
+
+// UpdateStepsArgs 对应 tool: update_steps
+type UpdateStepsArgs struct {
+	TaskID  uint64               `json:"task_id"`
+	Updates []UpdateStepItemArgs `json:"updates"`
+}
+
+type UpdateStepItemArgs struct {
+	StepID uint64         `json:"step_id"`
+	Fields StepFieldsArgs `json:"fields"`
+}
+
+// StepFieldsArgs 模型可以修改的步骤字段
+type StepFieldsArgs struct {
+	Title           *string `json:"title"`
+	Detail          *string `json:"detail"`
+	Status          *string `json:"status" enum:"locked,todo,in_progress,done,blocked"`
+	BlockingReason  *string `json:"blocking_reason"`
+	EstimateMinutes *int    `json:"estimate_minutes" minimum:"1"`
+	OrderIndex      *int    `json:"order_index" description:"New order index, smaller means earlier."`
+	PlannedStart    *string `json:"planned_start" description:"Planned start time in ISO 8601."`
+	PlannedEnd      *string `json:"planned_end" description:"Planned end time in ISO 8601."`
+}
+
+func (f StepFieldsArgs) toDomain() domain.UpdateStepFields {
+	return domain.UpdateStepFields{
+		Title:          f.Title,
+		Detail:         f.Detail,
+		Status:         f.Status,
+		BlockingReason: f.BlockingReason,
+		EstimateMin:    f.EstimateMinutes,
+		OrderIndex:     f.OrderIndex,
+		PlannedStart:   f.PlannedStart,
+		PlannedEnd:     f.PlannedEnd,
+	}
+}
+
+func init() {
+	registerTool(ToolSpec[UpdateStepsArgs]{
+		Name:        "update_steps",
+		Description: "Update one or more existing steps in a task.",
+		Patches: func(a *UpdateStepsArgs) []TaskPatch {
+			patches := make([]TaskPatch, 0, len(a.Updates))
+			for _, u := range a.Updates {
+				patches = append(patches, TaskPatch{
+					Kind:       PatchUpdateStep,
+					UpdateStep: &UpdateStepPatch{TaskID: a.TaskID, StepID: u.StepID, Fields: u.Fields.toDomain()},
+				})
+			}
+			return patches
+		},
+	})
+}
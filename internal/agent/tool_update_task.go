@@ -0,0 +1,41 @@
+package agent
+
+import "assistant-qisumi/internal/domain"
+
+// UpdateTaskArgs 对应 tool: update_task
+type UpdateTaskArgs struct {
+	TaskID uint64         `json:"task_id" description:"The ID of the task to update."`
+	Fields TaskFieldsArgs `json:"fields" description:"Fields to update. Only include fields that need to be changed."`
+}
+
+// TaskFieldsArgs 模型可以修改的任务字段
+type TaskFieldsArgs struct {
+	Title       *string `json:"title"`
+	Description *string `json:"description"`
+	Status      *string `json:"status" enum:"todo,in_progress,done,cancelled"`
+	Priority    *string `json:"priority" enum:"low,medium,high"`
+	DueAt       *string `json:"due_at" description:"New due date time in ISO 8601 format, e.g. 2025-12-08T20:00:00"`
+}
+
+func (f TaskFieldsArgs) toDomain() domain.UpdateTaskFields {
+	return domain.UpdateTaskFields{
+		Title:       f.Title,
+		Description: f.Description,
+		Status:      f.Status,
+		Priority:    f.Priority,
+		DueAt:       f.DueAt,
+	}
+}
+
+func init() {
+	registerTool(ToolSpec[UpdateTaskArgs]{
+		Name:        "update_task",
+		Description: "Update a task's metadata such as title, description, status, priority or due_at.",
+		Patches: func(a *UpdateTaskArgs) []TaskPatch {
+			return []TaskPatch{{
+				Kind:       PatchUpdateTask,
+				UpdateTask: &UpdateTaskPatch{TaskID: a.TaskID, Fields: a.Fields.toDomain()},
+			}}
+		},
+	})
+}
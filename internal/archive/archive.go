@@ -0,0 +1,136 @@
+// Package archive 负责账号数据的整体导出与导入
+// 导出格式是带版本号的 JSON（也可以打包成 zip），导入时会重新分配所有 ID 并重建引用关系
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"time"
+
+	"assistant-qisumi/internal/domain"
+)
+
+// CurrentVersion 当前导出格式版本，结构发生不兼容变化时递增
+const CurrentVersion = 1
+
+// archiveFileName zip 包中存放数据的文件名
+const archiveFileName = "archive.json"
+
+// Archive 一个账号的完整数据快照
+// 其中的 ID 都是导出端数据库中的原始 ID，仅用于在导入时重建引用关系
+type Archive struct {
+	Version      int                     `json:"version"`
+	ExportedAt   time.Time               `json:"exportedAt"`
+	Tasks        []domain.Task           `json:"tasks"`
+	Dependencies []domain.TaskDependency `json:"dependencies"`
+	Sessions     []SessionRecord         `json:"sessions"`
+	Settings     *SettingsRecord         `json:"settings,omitempty"`
+	Profile      *ProfileRecord          `json:"profile,omitempty"`
+}
+
+// SessionRecord 会话及其全部消息
+type SessionRecord struct {
+	domain.Session
+	Messages []domain.Message `json:"messages"`
+}
+
+// SettingsRecord 用户 LLM 设置（不含 API Key）
+type SettingsRecord struct {
+	BaseURL         string `json:"baseUrl"`
+	Model           string `json:"model"`
+	ThinkingType    string `json:"thinkingType"`
+	ReasoningEffort string `json:"reasoningEffort"`
+	EnableThinking  bool   `json:"enableThinking"`
+	AssistantName   string `json:"assistantName"`
+}
+
+// ProfileRecord 用户时区与工作时间
+type ProfileRecord struct {
+	Timezone  string   `json:"timezone"`
+	WorkStart string   `json:"workStart"`
+	WorkEnd   string   `json:"workEnd"`
+	WorkDays  []int    `json:"workDays"`
+	DaysOff   []string `json:"daysOff"`
+}
+
+// ImportResult 导入结果统计
+type ImportResult struct {
+	Tasks               int  `json:"tasks"`
+	Steps               int  `json:"steps"`
+	Dependencies        int  `json:"dependencies"`
+	SkippedDependencies int  `json:"skippedDependencies"`
+	Sessions            int  `json:"sessions"`
+	Messages            int  `json:"messages"`
+	SettingsRestored    bool `json:"settingsRestored"`
+	ProfileRestored     bool `json:"profileRestored"`
+}
+
+// WriteZip 把归档写成只包含 archive.json 的 zip 包
+func WriteZip(w io.Writer, a *Archive) error {
+	zw := zip.NewWriter(w)
+	f, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     archiveFileName,
+		Method:   zip.Deflate,
+		Modified: a.ExportedAt,
+	})
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(a); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// Read 解析导出文件，自动识别 JSON 和 zip
+func Read(data []byte) (*Archive, error) {
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		var err error
+		data, err = readZipEntry(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var a Archive
+	if err := json.Unmarshal(data, &a); err != nil {
+		return nil, fmt.Errorf("invalid archive: %w", err)
+	}
+	if a.Version <= 0 || a.Version > CurrentVersion {
+		return nil, fmt.Errorf("unsupported archive version %d", a.Version)
+	}
+	return &a, nil
+}
+
+func readZipEntry(data []byte) ([]byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid zip archive: %w", err)
+	}
+	var candidate *zip.File
+	for _, f := range zr.File {
+		if path.Base(f.Name) == archiveFileName {
+			candidate = f
+			break
+		}
+		if candidate == nil && path.Ext(f.Name) == ".json" {
+			candidate = f
+		}
+	}
+	if candidate == nil {
+		return nil, errors.New("zip archive does not contain " + archiveFileName)
+	}
+	rc, err := candidate.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
@@ -0,0 +1,330 @@
+package archive
+
+import (
+	"context"
+	"time"
+
+	"assistant-qisumi/internal/domain"
+	"assistant-qisumi/internal/task"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Service struct {
+	db       *gorm.DB
+	taskRepo *task.Repository
+}
+
+func NewService(db *gorm.DB, taskRepo *task.Repository) *Service {
+	return &Service{db: db, taskRepo: taskRepo}
+}
+
+// Export 导出用户的全部任务、步骤、依赖、会话、消息和 LLM 设置（不含 API Key）
+func (s *Service) Export(ctx context.Context, userID uint64) (*Archive, error) {
+	db := s.db.WithContext(ctx)
+	a := &Archive{
+		Version:      CurrentVersion,
+		ExportedAt:   time.Now(),
+		Tasks:        []domain.Task{},
+		Dependencies: []domain.TaskDependency{},
+		Sessions:     []SessionRecord{},
+	}
+
+	err := db.Preload("Steps", func(db *gorm.DB) *gorm.DB {
+		return db.Order("order_index ASC, id ASC")
+	}).
+		Where("user_id = ?", userID).
+		Order("id ASC").
+		Find(&a.Tasks).Error
+	if err != nil {
+		return nil, err
+	}
+
+	deps, err := s.taskRepo.GetAllUserDependencies(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	a.Dependencies = append(a.Dependencies, deps...)
+
+	var sessions []domain.Session
+	if err := db.Where("user_id = ?", userID).Order("id ASC").Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	for _, sess := range sessions {
+		var msgs []domain.Message
+		if err := db.Where("session_id = ?", sess.ID).Order("id ASC").Find(&msgs).Error; err != nil {
+			return nil, err
+		}
+		a.Sessions = append(a.Sessions, SessionRecord{Session: sess, Messages: msgs})
+	}
+
+	var settings []domain.UserLLMSetting
+	if err := db.Where("user_id = ?", userID).Limit(1).Find(&settings).Error; err != nil {
+		return nil, err
+	}
+	if len(settings) > 0 {
+		st := settings[0]
+		a.Settings = &SettingsRecord{
+			BaseURL:         st.BaseURL,
+			Model:           st.Model,
+			ThinkingType:    st.ThinkingType,
+			ReasoningEffort: st.ReasoningEffort,
+			EnableThinking:  st.EnableThinking,
+			AssistantName:   st.AssistantName,
+		}
+	}
+
+	var profiles []domain.UserProfile
+	if err := db.Where("user_id = ?", userID).Limit(1).Find(&profiles).Error; err != nil {
+		return nil, err
+	}
+	if len(profiles) > 0 {
+		p := profiles[0]
+		a.Profile = &ProfileRecord{
+			Timezone:  p.Timezone,
+			WorkStart: p.WorkStart,
+			WorkEnd:   p.WorkEnd,
+			WorkDays:  p.WorkDays,
+			DaysOff:   p.DaysOff,
+		}
+	}
+
+	return a, nil
+}
+
+// Import 把归档恢复到指定用户下，所有记录都会分配新 ID，依赖和会话按新 ID 重新关联。
+// replace 为 true 时先清空该用户已有的任务、会话及引用它们的数据；否则追加到现有数据中，
+// 已存在的全局会话会直接接收归档里全局会话的消息。
+// 由于归档不含 API Key，LLM 设置只会覆盖到已有配置上，没有配置时跳过。
+func (s *Service) Import(ctx context.Context, userID uint64, a *Archive, replace bool) (*ImportResult, error) {
+	result := &ImportResult{}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if replace {
+			if err := deleteUserData(tx, userID); err != nil {
+				return err
+			}
+		}
+
+		taskIDs := make(map[uint64]uint64, len(a.Tasks))
+		stepIDs := make(map[uint64]uint64)
+		repo := s.taskRepo.WithTx(tx)
+		for _, old := range a.Tasks {
+			t := old
+			t.ID = 0
+			t.UserID = userID
+			t.Steps = make([]domain.TaskStep, len(old.Steps))
+			for i, st := range old.Steps {
+				st.ID = 0
+				st.TaskID = 0
+				t.Steps[i] = st
+			}
+			if err := repo.InsertTaskWithSteps(ctx, &t); err != nil {
+				return err
+			}
+			taskIDs[old.ID] = t.ID
+			for i, st := range old.Steps {
+				stepIDs[st.ID] = t.Steps[i].ID
+			}
+			result.Tasks++
+			result.Steps += len(t.Steps)
+		}
+
+		for _, old := range a.Dependencies {
+			dep, ok := remapDependency(old, taskIDs, stepIDs)
+			if !ok {
+				result.SkippedDependencies++
+				continue
+			}
+			if err := tx.Create(&dep).Error; err != nil {
+				return err
+			}
+			result.Dependencies++
+		}
+
+		for _, rec := range a.Sessions {
+			sessionID, created, err := s.importSession(tx, userID, rec.Session, taskIDs)
+			if err != nil {
+				return err
+			}
+			if sessionID == 0 {
+				continue
+			}
+			if created {
+				result.Sessions++
+			}
+			for _, m := range rec.Messages {
+				m.ID = 0
+				m.SessionID = sessionID
+				if err := tx.Create(&m).Error; err != nil {
+					return err
+				}
+				result.Messages++
+			}
+		}
+
+		if a.Settings != nil {
+			restored, err := restoreSettings(tx, userID, a.Settings)
+			if err != nil {
+				return err
+			}
+			result.SettingsRestored = restored
+		}
+		if a.Profile != nil {
+			if err := restoreProfile(tx, userID, a.Profile); err != nil {
+				return err
+			}
+			result.ProfileRestored = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// importSession 返回消息应写入的会话 ID；所属任务不在归档中时返回 0 表示跳过
+func (s *Service) importSession(tx *gorm.DB, userID uint64, old domain.Session, taskIDs map[uint64]uint64) (uint64, bool, error) {
+	sess := old
+	sess.ID = 0
+	sess.UserID = userID
+
+	if sess.TaskID != nil {
+		newTaskID, ok := taskIDs[*sess.TaskID]
+		if !ok {
+			return 0, false, nil
+		}
+		sess.TaskID = &newTaskID
+	}
+
+	if sess.Type == "global" {
+		var existing []domain.Session
+		if err := tx.Where("user_id = ? AND type = 'global'", userID).Limit(1).Find(&existing).Error; err != nil {
+			return 0, false, err
+		}
+		if len(existing) > 0 {
+			return existing[0].ID, false, nil
+		}
+	}
+
+	if err := tx.Create(&sess).Error; err != nil {
+		return 0, false, err
+	}
+	return sess.ID, true, nil
+}
+
+func remapDependency(old domain.TaskDependency, taskIDs, stepIDs map[uint64]uint64) (domain.TaskDependency, bool) {
+	dep := old
+	dep.ID = 0
+
+	var ok bool
+	if dep.PredecessorTaskID, ok = taskIDs[old.PredecessorTaskID]; !ok {
+		return dep, false
+	}
+	if dep.SuccessorTaskID, ok = taskIDs[old.SuccessorTaskID]; !ok {
+		return dep, false
+	}
+	if old.PredecessorStepID != nil {
+		id, ok := stepIDs[*old.PredecessorStepID]
+		if !ok {
+			return dep, false
+		}
+		dep.PredecessorStepID = &id
+	}
+	if old.SuccessorStepID != nil {
+		id, ok := stepIDs[*old.SuccessorStepID]
+		if !ok {
+			return dep, false
+		}
+		dep.SuccessorStepID = &id
+	}
+	return dep, true
+}
+
+func restoreSettings(tx *gorm.DB, userID uint64, rec *SettingsRecord) (bool, error) {
+	var settings []domain.UserLLMSetting
+	if err := tx.Where("user_id = ?", userID).Limit(1).Find(&settings).Error; err != nil {
+		return false, err
+	}
+	if len(settings) == 0 {
+		return false, nil
+	}
+	st := settings[0]
+	st.BaseURL = rec.BaseURL
+	st.Model = rec.Model
+	st.ThinkingType = rec.ThinkingType
+	st.ReasoningEffort = rec.ReasoningEffort
+	st.EnableThinking = rec.EnableThinking
+	if rec.AssistantName != "" {
+		st.AssistantName = rec.AssistantName
+	}
+	if err := tx.Save(&st).Error; err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// restoreProfile 用归档中的时区与工作时间覆盖用户资料
+func restoreProfile(tx *gorm.DB, userID uint64, rec *ProfileRecord) error {
+	p := domain.UserProfile{
+		UserID:    userID,
+		Timezone:  rec.Timezone,
+		WorkStart: rec.WorkStart,
+		WorkEnd:   rec.WorkEnd,
+		WorkDays:  rec.WorkDays,
+		DaysOff:   rec.DaysOff,
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"timezone", "work_start", "work_end", "work_days", "days_off", "updated_at"}),
+	}).Create(&p).Error
+}
+
+// deleteUserData 删除用户的任务、步骤、依赖、会话和消息，以及引用它们的时间记录、每日计划、会话摘要和回顾摘要：
+// 导入的数据会分配新的 ID，留下的旧记录会指向不存在或无关的任务和会话。
+// 账号、LLM 设置和记忆保留，记忆和用量记录只解除与会话的关联
+func deleteUserData(tx *gorm.DB, userID uint64) error {
+	taskIDs := tx.Model(&domain.Task{}).Select("id").Where("user_id = ?", userID)
+	sessionIDs := tx.Model(&domain.Session{}).Select("id").Where("user_id = ?", userID)
+	planIDs := tx.Model(&domain.DailyPlan{}).Select("id").Where("user_id = ?", userID)
+
+	if err := tx.Where("predecessor_task_id IN (?) OR successor_task_id IN (?)", taskIDs, taskIDs).
+		Delete(&domain.TaskDependency{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("task_id IN (?)", taskIDs).Delete(&domain.TaskStep{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", userID).Delete(&domain.TimeEntry{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("plan_id IN (?)", planIDs).Delete(&domain.DailyPlanItem{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", userID).Delete(&domain.DailyPlan{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", userID).Delete(&domain.Digest{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("session_id IN (?)", sessionIDs).Delete(&domain.SessionSummary{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("session_id IN (?)", sessionIDs).Delete(&domain.Message{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&domain.Memory{}).Where("user_id = ? AND session_id IS NOT NULL", userID).
+		Update("session_id", nil).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&domain.LLMUsage{}).Where("user_id = ? AND session_id IS NOT NULL", userID).
+		Update("session_id", nil).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", userID).Delete(&domain.Session{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("user_id = ?", userID).Delete(&domain.Task{}).Error
+}
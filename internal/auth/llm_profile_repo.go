@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// LLMProfileRepository 命名 LLM 配置及 agent 指派的仓库
+type LLMProfileRepository struct {
+	db *gorm.DB
+}
+
+// NewLLMProfileRepository 创建命名 LLM 配置仓库
+func NewLLMProfileRepository(db *gorm.DB) *LLMProfileRepository {
+	return &LLMProfileRepository{db: db}
+}
+
+// List 获取用户的全部命名配置
+func (r *LLMProfileRepository) List(ctx context.Context, userID uint64) ([]LLMProfile, error) {
+	var profiles []LLMProfile
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("id ASC").Find(&profiles).Error
+	return profiles, err
+}
+
+// Get 获取单个命名配置，不存在时返回 nil
+func (r *LLMProfileRepository) Get(ctx context.Context, userID, id uint64) (*LLMProfile, error) {
+	var profiles []LLMProfile
+	if err := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).Limit(1).Find(&profiles).Error; err != nil {
+		return nil, err
+	}
+	if len(profiles) == 0 {
+		return nil, nil
+	}
+	return &profiles[0], nil
+}
+
+// FindByName 按名称查找命名配置，不存在时返回 nil
+func (r *LLMProfileRepository) FindByName(ctx context.Context, userID uint64, name string) (*LLMProfile, error) {
+	var profiles []LLMProfile
+	if err := r.db.WithContext(ctx).Where("user_id = ? AND name = ?", userID, name).Limit(1).Find(&profiles).Error; err != nil {
+		return nil, err
+	}
+	if len(profiles) == 0 {
+		return nil, nil
+	}
+	return &profiles[0], nil
+}
+
+// Create 创建命名配置
+func (r *LLMProfileRepository) Create(ctx context.Context, p *LLMProfile) error {
+	return r.db.WithContext(ctx).Create(p).Error
+}
+
+// Update 保存命名配置
+func (r *LLMProfileRepository) Update(ctx context.Context, p *LLMProfile) error {
+	return r.db.WithContext(ctx).Save(p).Error
+}
+
+// Delete 删除命名配置以及指向它的 agent 指派
+func (r *LLMProfileRepository) Delete(ctx context.Context, userID, id uint64) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND profile_id = ?", userID, id).Delete(&LLMAgentAssignment{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ? AND user_id = ?", id, userID).Delete(&LLMProfile{}).Error
+	})
+}
+
+// ListAssignments 获取用户的全部 agent 指派
+func (r *LLMProfileRepository) ListAssignments(ctx context.Context, userID uint64) ([]LLMAgentAssignment, error) {
+	var items []LLMAgentAssignment
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("agent ASC").Find(&items).Error
+	return items, err
+}
+
+// ReplaceAssignments 用新的指派整体替换用户的 agent 指派
+func (r *LLMProfileRepository) ReplaceAssignments(ctx context.Context, userID uint64, items []LLMAgentAssignment) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&LLMAgentAssignment{}).Error; err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		return tx.Create(&items).Error
+	})
+}
@@ -0,0 +1,338 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"assistant-qisumi/internal/domain"
+)
+
+var (
+	// ErrLLMProfileNotFound 命名配置不存在或不属于该用户
+	ErrLLMProfileNotFound = errors.New("llm profile not found")
+	// ErrInvalidLLMProfile 命名配置或 agent 指派不合法
+	ErrInvalidLLMProfile = errors.New("invalid llm profile")
+)
+
+// WithProfiles 启用命名配置（多个 LLM 配置 + 按 agent 指派）
+// 未启用时所有 agent 都使用 GetLLMConfig 返回的单一配置
+func (s *LLMSettingService) WithProfiles(repo *LLMProfileRepository) *LLMSettingService {
+	s.profiles = repo
+	return s
+}
+
+func (s *LLMSettingService) requireProfiles() error {
+	if s.profiles == nil {
+		return errors.New("llm profiles are not enabled")
+	}
+	return nil
+}
+
+// ListProfiles 获取用户的命名配置以及 agent -> 配置 ID 的指派
+func (s *LLMSettingService) ListProfiles(ctx context.Context, userID uint64) ([]LLMProfileView, map[string]uint64, error) {
+	if err := s.requireProfiles(); err != nil {
+		return nil, nil, err
+	}
+	profiles, err := s.profiles.List(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	assignments, err := s.GetAssignments(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	views := make([]LLMProfileView, 0, len(profiles))
+	for i := range profiles {
+		views = append(views, profileView(&profiles[i], assignments))
+	}
+	return views, assignments, nil
+}
+
+// CreateProfile 创建命名配置，API Key 加密保存
+func (s *LLMSettingService) CreateProfile(ctx context.Context, userID uint64, req LLMProfileRequest) (*LLMProfileView, error) {
+	if err := s.requireProfiles(); err != nil {
+		return nil, err
+	}
+	if req.APIKey == "" && normalizeProtocol(req.Protocol) != domain.LLMProtocolOllama {
+		return nil, fmt.Errorf("%w: api_key is required", ErrInvalidLLMProfile)
+	}
+	if err := checkProtocol(req.Protocol); err != nil {
+		return nil, err
+	}
+	name, err := s.checkProfileName(ctx, userID, 0, req.Name)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := s.encryptAPIKey(req.APIKey)
+	if err != nil {
+		return nil, err
+	}
+	p := &LLMProfile{UserID: userID, Name: name, APIKeyEnc: enc}
+	applyProfileRequest(p, req)
+	if err := s.profiles.Create(ctx, p); err != nil {
+		return nil, err
+	}
+	view := profileView(p, nil)
+	return &view, nil
+}
+
+// UpdateProfile 更新命名配置，api_key 为空时保留原有密钥
+func (s *LLMSettingService) UpdateProfile(ctx context.Context, userID, id uint64, req LLMProfileRequest) (*LLMProfileView, error) {
+	if err := s.requireProfiles(); err != nil {
+		return nil, err
+	}
+	p, err := s.profiles.Get(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, ErrLLMProfileNotFound
+	}
+	if err := checkProtocol(req.Protocol); err != nil {
+		return nil, err
+	}
+	if p.Name, err = s.checkProfileName(ctx, userID, id, req.Name); err != nil {
+		return nil, err
+	}
+	if req.APIKey != "" {
+		if p.APIKeyEnc, err = s.encryptAPIKey(req.APIKey); err != nil {
+			return nil, err
+		}
+	}
+	applyProfileRequest(p, req)
+	if err := s.profiles.Update(ctx, p); err != nil {
+		return nil, err
+	}
+	assignments, err := s.GetAssignments(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	view := profileView(p, assignments)
+	return &view, nil
+}
+
+// DeleteProfile 删除命名配置，原本使用它的 agent 回退到默认配置
+func (s *LLMSettingService) DeleteProfile(ctx context.Context, userID, id uint64) error {
+	if err := s.requireProfiles(); err != nil {
+		return err
+	}
+	p, err := s.profiles.Get(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return ErrLLMProfileNotFound
+	}
+	return s.profiles.Delete(ctx, userID, id)
+}
+
+// GetAssignments 获取 agent -> 配置 ID 的指派
+func (s *LLMSettingService) GetAssignments(ctx context.Context, userID uint64) (map[string]uint64, error) {
+	result := map[string]uint64{}
+	if s.profiles == nil {
+		return result, nil
+	}
+	items, err := s.profiles.ListAssignments(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, it := range items {
+		result[it.Agent] = it.ProfileID
+	}
+	return result, nil
+}
+
+// SetAssignments 整体替换 agent 指派；配置 ID 为 0 表示该 agent 不单独指定
+func (s *LLMSettingService) SetAssignments(ctx context.Context, userID uint64, assignments map[string]uint64) (map[string]uint64, error) {
+	if err := s.requireProfiles(); err != nil {
+		return nil, err
+	}
+	var items []LLMAgentAssignment
+	for agent, profileID := range assignments {
+		if !slices.Contains(domain.LLMAgentNames, agent) {
+			return nil, fmt.Errorf("%w: unknown agent %q, expected one of %s", ErrInvalidLLMProfile, agent, strings.Join(domain.LLMAgentNames, ", "))
+		}
+		if profileID == 0 {
+			continue
+		}
+		p, err := s.profiles.Get(ctx, userID, profileID)
+		if err != nil {
+			return nil, err
+		}
+		if p == nil {
+			return nil, fmt.Errorf("%w: profile %d", ErrLLMProfileNotFound, profileID)
+		}
+		items = append(items, LLMAgentAssignment{UserID: userID, Agent: agent, ProfileID: profileID})
+	}
+	if err := s.profiles.ReplaceAssignments(ctx, userID, items); err != nil {
+		return nil, err
+	}
+	return s.GetAssignments(ctx, userID)
+}
+
+// AgentLLMConfigs 返回每个被单独指派了命名配置的 agent 的 LLM 配置（含解密后的 API Key）
+// 未出现在结果中的 agent 使用 "default" 指派，或者 GetLLMConfig 返回的配置。
+// 每个配置都带上用户设置的备用提供商（Fallbacks）。
+func (s *LLMSettingService) AgentLLMConfigs(ctx context.Context, userID uint64) (map[string]LLMConfig, error) {
+	result := map[string]LLMConfig{}
+	if s.profiles == nil {
+		return result, nil
+	}
+	assignments, err := s.GetAssignments(ctx, userID)
+	if err != nil || len(assignments) == 0 {
+		return result, err
+	}
+	configs, fallbacks, err := s.profileConfigs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for agent, profileID := range assignments {
+		if cfg, ok := configs[profileID]; ok {
+			cfg.Fallbacks = fallbacks
+			result[agent] = cfg
+		}
+	}
+	return result, nil
+}
+
+// GetAgentLLMConfig 获取某个 agent 实际使用的 LLM 配置：
+// 该 agent 的指派 > "default" 指派 > GetLLMConfig（用户单一配置或系统默认配置）
+func (s *LLMSettingService) GetAgentLLMConfig(ctx context.Context, userID uint64, agent string) (*LLMConfig, error) {
+	configs, err := s.AgentLLMConfigs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if cfg, ok := configs[agent]; ok {
+		return &cfg, nil
+	}
+	if cfg, ok := configs[domain.LLMAgentDefault]; ok {
+		return &cfg, nil
+	}
+	base, err := s.GetLLMConfig(ctx, userID)
+	if err != nil || base == nil || s.profiles == nil {
+		return base, err
+	}
+	_, fallbacks, err := s.profileConfigs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	// 复制一份，不修改共享的系统默认配置
+	cfg := *base
+	cfg.Fallbacks = fallbacks
+	return &cfg, nil
+}
+
+// profileConfigs 解密用户的全部命名配置，并按 FallbackOrder 返回备用提供商列表
+func (s *LLMSettingService) profileConfigs(ctx context.Context, userID uint64) (map[uint64]LLMConfig, []LLMConfig, error) {
+	profiles, err := s.profiles.List(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	assistantName := ""
+	if s.defaultConfig != nil {
+		assistantName = s.defaultConfig.AssistantName
+	}
+
+	configs := make(map[uint64]LLMConfig, len(profiles))
+	var ordered []LLMProfile
+	for _, p := range profiles {
+		apiKey, err := s.decryptAPIKey(p.APIKeyEnc)
+		if err != nil {
+			return nil, nil, fmt.Errorf("decrypt api key of llm profile %q: %w", p.Name, err)
+		}
+		configs[p.ID] = LLMConfig{
+			BaseURL:         p.BaseURL,
+			APIKey:          apiKey,
+			Model:           p.Model,
+			ThinkingType:    p.ThinkingType,
+			ReasoningEffort: p.ReasoningEffort,
+			EnableThinking:  p.EnableThinking,
+			AssistantName:   assistantName,
+			HasAPIKey:       apiKey != "",
+			Protocol:        normalizeProtocol(p.Protocol),
+		}
+		if p.FallbackOrder > 0 {
+			ordered = append(ordered, p)
+		}
+	}
+
+	slices.SortStableFunc(ordered, func(a, b LLMProfile) int { return a.FallbackOrder - b.FallbackOrder })
+	var fallbacks []LLMConfig
+	for _, p := range ordered {
+		fallbacks = append(fallbacks, configs[p.ID])
+	}
+	return configs, fallbacks, nil
+}
+
+// checkProfileName 校验名称非空且在该用户下唯一（excludeID 为正在更新的配置）
+func (s *LLMSettingService) checkProfileName(ctx context.Context, userID, excludeID uint64, name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("%w: name is required", ErrInvalidLLMProfile)
+	}
+	existing, err := s.profiles.FindByName(ctx, userID, name)
+	if err != nil {
+		return "", err
+	}
+	if existing != nil && existing.ID != excludeID {
+		return "", fmt.Errorf("%w: a profile named %q already exists", ErrInvalidLLMProfile, name)
+	}
+	return name, nil
+}
+
+// normalizeProtocol 协议名统一为小写，为空时为 openai
+func normalizeProtocol(protocol string) string {
+	protocol = strings.ToLower(strings.TrimSpace(protocol))
+	if protocol == "" {
+		return domain.LLMProtocolOpenAI
+	}
+	return protocol
+}
+
+func checkProtocol(protocol string) error {
+	if !slices.Contains(domain.LLMProtocols, normalizeProtocol(protocol)) {
+		return fmt.Errorf("%w: unknown protocol %q, expected one of %s", ErrInvalidLLMProfile, protocol, strings.Join(domain.LLMProtocols, ", "))
+	}
+	return nil
+}
+
+func applyProfileRequest(p *LLMProfile, req LLMProfileRequest) {
+	p.BaseURL = req.BaseURL
+	p.Model = req.Model
+	p.ThinkingType = req.ThinkingType
+	p.ReasoningEffort = req.ReasoningEffort
+	p.EnableThinking = req.EnableThinking
+	p.FallbackOrder = max(req.FallbackOrder, 0)
+	p.Protocol = normalizeProtocol(req.Protocol)
+	if p.ThinkingType == "" {
+		p.ThinkingType = "auto"
+	}
+	if p.ReasoningEffort == "" {
+		p.ReasoningEffort = "medium"
+	}
+}
+
+func profileView(p *LLMProfile, assignments map[string]uint64) LLMProfileView {
+	agents := []string{}
+	for _, agent := range domain.LLMAgentNames {
+		if id, ok := assignments[agent]; ok && id == p.ID {
+			agents = append(agents, agent)
+		}
+	}
+	return LLMProfileView{
+		ID:              p.ID,
+		Name:            p.Name,
+		BaseURL:         p.BaseURL,
+		Model:           p.Model,
+		ThinkingType:    p.ThinkingType,
+		ReasoningEffort: p.ReasoningEffort,
+		EnableThinking:  p.EnableThinking,
+		FallbackOrder:   p.FallbackOrder,
+		Protocol:        normalizeProtocol(p.Protocol),
+		HasAPIKey:       p.APIKeyEnc != "",
+		Agents:          agents,
+	}
+}
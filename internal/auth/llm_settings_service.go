@@ -17,6 +17,7 @@ type LLMSettingService struct {
 	repo          *LLMSettingRepository
 	encryptionKey []byte
 	defaultConfig *domain.LLMConfig
+	profiles      *LLMProfileRepository // 命名配置，为空时不支持按 agent 指派
 }
 
 // 类型别名 - 使用 domain 包中的统一定义
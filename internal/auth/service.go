@@ -43,3 +43,16 @@ func (s *Service) Login(ctx context.Context, email, password string) (string, er
 	}
 	return s.jwt.GenerateToken(u.ID)
 }
+
+// IsAdmin 判断用户是否为管理员。只看 users.is_admin，由运维直接在数据库中设置；
+// 注册时的邮箱未经验证，不能作为授权依据
+func (s *Service) IsAdmin(ctx context.Context, userID uint64) (bool, error) {
+	var users []User
+	if err := s.db.WithContext(ctx).Where("id = ?", userID).Limit(1).Find(&users).Error; err != nil {
+		return false, err
+	}
+	if len(users) == 0 {
+		return false, nil
+	}
+	return users[0].IsAdmin, nil
+}
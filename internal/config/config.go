@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -18,6 +19,7 @@ type Config struct {
 	Crypto CryptoConfig
 	LLM    LLMConfig
 	Log    LogConfig
+	Digest DigestConfig
 }
 
 // LLMConfig LLM配置
@@ -25,10 +27,33 @@ type LLMConfig struct {
 	APIKey          string
 	ModelName       string
 	APIBaseURL      string
+	Protocol        string // openai, anthropic, gemini, ollama
 	ThinkingType    string // disabled, enabled, auto
 	ReasoningEffort string // low, medium, high, minimal
 	EnableThinking  bool   // true/false, 用于某些 API 提供商
 	AssistantName   string // 助手名称
+
+	// 容错策略
+	MaxAttempts      int           // 每个提供商的最大尝试次数
+	CallTimeout      time.Duration // 单次调用超时
+	BreakerThreshold int           // 连续失败多少次后熔断
+	BreakerCooldown  time.Duration // 熔断后多久允许试探调用
+
+	// 对话历史中原样提供给模型的最近消息的 token 预算，更早的消息合并为摘要
+	HistoryTokens int
+
+	// 录制每次 LLM 调用的请求和响应，用于离线回放测试；为空时不录制
+	RecordDir string
+
+	// 用量计费
+	PriceFile     string // 价格表 JSON 文件路径，为空时费用记为 0
+	PriceCurrency string // 价格表的币种
+
+	// 限额（0 表示不限制），分别适用于使用服务器默认 API Key 和使用自己 API Key 的用户
+	DefaultKeyRPM          int
+	DefaultKeyTokensPerDay int64
+	OwnKeyRPM              int
+	OwnKeyTokensPerDay     int64
 }
 
 // DBConfig 数据库配置
@@ -59,6 +84,14 @@ type CryptoConfig struct {
 	APIKeyEncryptionKey string
 }
 
+// DigestConfig 定时生成每日/每周回顾的配置
+type DigestConfig struct {
+	Enabled  bool
+	Hour     int           // 用户时区的几点之后生成当天的回顾，周日同时生成本周回顾
+	Polish   bool          // 是否用用户的 LLM 配置润色
+	Interval time.Duration // 检查间隔
+}
+
 // LogConfig 日志配置
 type LogConfig struct {
 	Level string // debug, info, warn, error
@@ -77,6 +110,23 @@ func LoadConfig() (*Config, error) {
 
 	expireHour, _ := strconv.Atoi(getEnv("JWT_EXPIRE_HOUR", "24"))
 	enableThinking := getEnv("LLM_ENABLE_THINKING", "false") == "true"
+	maxAttempts, _ := strconv.Atoi(getEnv("LLM_MAX_ATTEMPTS", "3"))
+	callTimeout, _ := strconv.Atoi(getEnv("LLM_CALL_TIMEOUT_SECONDS", "120"))
+	breakerThreshold, _ := strconv.Atoi(getEnv("LLM_BREAKER_THRESHOLD", "5"))
+	breakerCooldown, _ := strconv.Atoi(getEnv("LLM_BREAKER_COOLDOWN_SECONDS", "30"))
+	defaultKeyRPM, _ := strconv.Atoi(getEnv("QUOTA_DEFAULT_KEY_RPM", "10"))
+	defaultKeyTokens, _ := strconv.ParseInt(getEnv("QUOTA_DEFAULT_KEY_TOKENS_PER_DAY", "200000"), 10, 64)
+	ownKeyRPM, _ := strconv.Atoi(getEnv("QUOTA_OWN_KEY_RPM", "60"))
+	ownKeyTokens, _ := strconv.ParseInt(getEnv("QUOTA_OWN_KEY_TOKENS_PER_DAY", "0"), 10, 64)
+	digestHour, _ := strconv.Atoi(getEnv("DIGEST_HOUR", "21"))
+	historyTokens, _ := strconv.Atoi(getEnv("LLM_HISTORY_TOKENS", "3000"))
+	if historyTokens <= 0 {
+		historyTokens = 3000
+	}
+	digestInterval, _ := strconv.Atoi(getEnv("DIGEST_CHECK_INTERVAL_MINUTES", "15"))
+	if digestInterval <= 0 {
+		digestInterval = 15
+	}
 
 	// 默认数据库文件路径为可执行文件所在目录
 	defaultDBPath := filepath.Join(execDir, "assistant.db")
@@ -106,14 +156,38 @@ func LoadConfig() (*Config, error) {
 			APIKey:          getEnv("LLM_API_KEY", ""),
 			ModelName:       getEnv("LLM_MODEL_NAME", "qwen-plus"),
 			APIBaseURL:      getEnv("LLM_API_BASE_URL", "https://dashscope.aliyuncs.com/compatible-mode/v1"),
+			Protocol:        getEnv("LLM_PROTOCOL", "openai"),
 			ThinkingType:    getEnv("LLM_THINKING_TYPE", "auto"),
 			ReasoningEffort: getEnv("LLM_REASONING_EFFORT", "medium"),
 			EnableThinking:  enableThinking,
 			AssistantName:   getEnv("ASSISTANT_NAME", "小奇"),
+
+			MaxAttempts:      maxAttempts,
+			CallTimeout:      time.Duration(callTimeout) * time.Second,
+			BreakerThreshold: breakerThreshold,
+			BreakerCooldown:  time.Duration(breakerCooldown) * time.Second,
+
+			HistoryTokens: historyTokens,
+
+			RecordDir: getEnv("LLM_RECORD_DIR", ""),
+
+			PriceFile:     getEnv("LLM_PRICE_FILE", ""),
+			PriceCurrency: getEnv("LLM_PRICE_CURRENCY", "CNY"),
+
+			DefaultKeyRPM:          defaultKeyRPM,
+			DefaultKeyTokensPerDay: defaultKeyTokens,
+			OwnKeyRPM:              ownKeyRPM,
+			OwnKeyTokensPerDay:     ownKeyTokens,
 		},
 		Log: LogConfig{
 			Level: getEnv("LOG_LEVEL", "info"),
 		},
+		Digest: DigestConfig{
+			Enabled:  getEnv("DIGEST_ENABLED", "true") == "true",
+			Hour:     digestHour,
+			Polish:   getEnv("DIGEST_POLISH", "false") == "true",
+			Interval: time.Duration(digestInterval) * time.Minute,
+		},
 	}, nil
 }
 
@@ -0,0 +1,32 @@
+package dailyplan
+
+import "assistant-qisumi/internal/domain"
+
+// 类型别名 - 引用 domain 包中的定义，避免循环依赖
+type DailyPlan = domain.DailyPlan
+type DailyPlanItem = domain.DailyPlanItem
+
+// 计划状态
+const (
+	StatusProposed = "proposed"
+	StatusAccepted = "accepted"
+)
+
+// DateLayout 计划日期格式
+const DateLayout = "2006-01-02"
+
+// ItemRef 计划项引用的任务或步骤，StepID 为空表示整个任务
+type ItemRef struct {
+	TaskID uint64  `json:"taskId"`
+	StepID *uint64 `json:"stepId,omitempty"`
+}
+
+func (r ItemRef) matches(it DailyPlanItem) bool {
+	if r.TaskID != it.TaskID {
+		return false
+	}
+	if r.StepID == nil || it.StepID == nil {
+		return r.StepID == nil && it.StepID == nil
+	}
+	return *r.StepID == *it.StepID
+}
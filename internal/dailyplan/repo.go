@@ -0,0 +1,97 @@
+package dailyplan
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) WithTx(tx *gorm.DB) *Repository {
+	return &Repository{db: tx}
+}
+
+// Get 获取用户某天的计划及计划项，不存在时返回 nil
+func (r *Repository) Get(ctx context.Context, userID uint64, date string) (*DailyPlan, error) {
+	var plan DailyPlan
+	result := r.db.WithContext(ctx).
+		Preload("Items", func(db *gorm.DB) *gorm.DB {
+			return db.Order("order_index ASC, id ASC")
+		}).
+		Where("user_id = ? AND plan_date = ?", userID, date).
+		Limit(1).
+		Find(&plan)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	items := plan.Items
+	plan.Items = nil
+	for _, it := range items {
+		if it.Proposed {
+			plan.Proposal = append(plan.Proposal, it)
+		} else {
+			plan.Items = append(plan.Items, it)
+		}
+	}
+	return &plan, nil
+}
+
+// Save 保存计划，计划项和待确认的计划项整体替换并按顺序重新编号
+func (r *Repository) Save(ctx context.Context, plan *DailyPlan) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		items := plan.Items
+		plan.Items = nil
+		err := tx.Save(plan).Error
+		plan.Items = items
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("plan_id = ?", plan.ID).Delete(&DailyPlanItem{}).Error; err != nil {
+			return err
+		}
+		if err := createItems(tx, plan.ID, plan.Items, false); err != nil {
+			return err
+		}
+		return createItems(tx, plan.ID, plan.Proposal, true)
+	})
+}
+
+func createItems(tx *gorm.DB, planID uint64, items []DailyPlanItem, proposed bool) error {
+	for i := range items {
+		items[i].ID = 0
+		items[i].PlanID = planID
+		items[i].Proposed = proposed
+		items[i].OrderIndex = i
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	return tx.Create(&items).Error
+}
+
+// SetFocusTasks 把用户任务的 is_focus_today 设为是否在 taskIDs 中
+func (r *Repository) SetFocusTasks(ctx context.Context, userID uint64, taskIDs []uint64) error {
+	clear := r.db.WithContext(ctx).Table("tasks").Where("user_id = ? AND is_focus_today = ?", userID, true)
+	if len(taskIDs) > 0 {
+		clear = clear.Where("id NOT IN ?", taskIDs)
+	}
+	if err := clear.Update("is_focus_today", false).Error; err != nil {
+		return err
+	}
+	if len(taskIDs) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Table("tasks").
+		Where("user_id = ? AND id IN ? AND is_focus_today = ?", userID, taskIDs, false).
+		Update("is_focus_today", true).Error
+}
@@ -0,0 +1,278 @@
+// Package dailyplan 按日期保存用户的每日计划：有序的任务/步骤和一段备注。
+// 当天已确认的计划决定哪些任务是「今日重点」，tasks.is_focus_today 由它同步，不再单独维护。
+package dailyplan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"assistant-qisumi/internal/domain"
+	"assistant-qisumi/internal/task"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidDate 日期不是 YYYY-MM-DD
+	ErrInvalidDate = errors.New("invalid date")
+	// ErrNoPlan 该日期还没有计划
+	ErrNoPlan = errors.New("no plan for this date")
+)
+
+type Service struct {
+	repo  *Repository
+	tasks *task.Repository
+	now   func() time.Time
+}
+
+func NewService(db *gorm.DB) *Service {
+	return &Service{repo: NewRepository(db), tasks: task.NewRepository(db), now: time.Now}
+}
+
+// WithTx 返回绑定到事务的 Service，供 agent 在应用 TaskPatch 时使用
+func (s *Service) WithTx(tx *gorm.DB) *Service {
+	return &Service{repo: s.repo.WithTx(tx), tasks: s.tasks.WithTx(tx), now: s.now}
+}
+
+// WithClock 替换当前时间来源，用于测试
+func (s *Service) WithClock(now func() time.Time) *Service {
+	return &Service{repo: s.repo, tasks: s.tasks, now: now}
+}
+
+// Today 返回用户时区（见 domain.LocationFromContext）的今天
+func (s *Service) Today(ctx context.Context) string {
+	return s.now().In(domain.LocationFromContext(ctx)).Format(DateLayout)
+}
+
+// ResolveDate 校验日期，空字符串和 "today" 表示今天
+func (s *Service) ResolveDate(ctx context.Context, date string) (string, error) {
+	if date == "" || date == "today" {
+		return s.Today(ctx), nil
+	}
+	if _, err := time.Parse(DateLayout, date); err != nil {
+		return "", fmt.Errorf("%w: %q", ErrInvalidDate, date)
+	}
+	return date, nil
+}
+
+// Get 获取某天的计划，没有时返回 nil
+func (s *Service) Get(ctx context.Context, userID uint64, date string) (*DailyPlan, error) {
+	return s.repo.Get(ctx, userID, date)
+}
+
+// Mark 把任务或步骤追加到计划末尾，已在计划中的忽略；当天没有计划时创建已确认的计划
+func (s *Service) Mark(ctx context.Context, userID uint64, date string, refs []ItemRef) (*DailyPlan, error) {
+	return s.update(ctx, userID, date, refs, func(plan *DailyPlan) {
+		for _, ref := range refs {
+			if indexOf(plan.Items, ref) < 0 {
+				plan.Items = append(plan.Items, DailyPlanItem{TaskID: ref.TaskID, StepID: ref.StepID})
+			}
+		}
+	})
+}
+
+// Unmark 从计划中移除任务或步骤；只给任务时同时移除该任务下的步骤
+func (s *Service) Unmark(ctx context.Context, userID uint64, date string, refs []ItemRef) (*DailyPlan, error) {
+	return s.update(ctx, userID, date, refs, func(plan *DailyPlan) {
+		kept := plan.Items[:0]
+		for _, it := range plan.Items {
+			removed := false
+			for _, ref := range refs {
+				if ref.matches(it) || (ref.StepID == nil && ref.TaskID == it.TaskID) {
+					removed = true
+					break
+				}
+			}
+			if !removed {
+				kept = append(kept, it)
+			}
+		}
+		plan.Items = kept
+	})
+}
+
+// Reorder 把给出的计划项按给出的顺序排在最前，其余保持原有顺序排在后面；不在计划中的忽略
+func (s *Service) Reorder(ctx context.Context, userID uint64, date string, refs []ItemRef) (*DailyPlan, error) {
+	return s.update(ctx, userID, date, refs, func(plan *DailyPlan) {
+		ordered := make([]DailyPlanItem, 0, len(plan.Items))
+		used := make([]bool, len(plan.Items))
+		for _, ref := range refs {
+			if i := indexOf(plan.Items, ref); i >= 0 && !used[i] {
+				used[i] = true
+				ordered = append(ordered, plan.Items[i])
+			}
+		}
+		for i, it := range plan.Items {
+			if !used[i] {
+				ordered = append(ordered, it)
+			}
+		}
+		plan.Items = ordered
+	})
+}
+
+// SetNote 设置计划备注
+func (s *Service) SetNote(ctx context.Context, userID uint64, date, note string) (*DailyPlan, error) {
+	return s.update(ctx, userID, date, nil, func(plan *DailyPlan) {
+		plan.Note = note
+	})
+}
+
+// Propose 保存助手拟定的计划，等待用户确认；已确认的计划和今日重点在确认前保持不变
+func (s *Service) Propose(ctx context.Context, userID uint64, date string, refs []ItemRef, note string) (*DailyPlan, error) {
+	return s.update(ctx, userID, date, refs, func(plan *DailyPlan) {
+		plan.Status = StatusProposed
+		plan.ProposalNote = note
+		plan.Proposal = nil
+		for _, ref := range refs {
+			if indexOf(plan.Proposal, ref) < 0 {
+				plan.Proposal = append(plan.Proposal, DailyPlanItem{TaskID: ref.TaskID, StepID: ref.StepID})
+			}
+		}
+	})
+}
+
+// Accept 确认当天待确认的计划，用它替换已确认的计划；没有待确认的计划时不做修改
+func (s *Service) Accept(ctx context.Context, userID uint64, date string) (*DailyPlan, error) {
+	plan, err := s.repo.Get(ctx, userID, date)
+	if err != nil {
+		return nil, err
+	}
+	if plan == nil {
+		return nil, ErrNoPlan
+	}
+	if plan.Status != StatusProposed {
+		return plan, nil
+	}
+	return s.update(ctx, userID, date, nil, func(plan *DailyPlan) {
+		plan.Status = StatusAccepted
+		plan.Items, plan.Note = plan.Proposal, plan.ProposalNote
+		plan.Proposal, plan.ProposalNote = nil, ""
+	})
+}
+
+// update 校验计划项属于该用户，修改并保存计划，修改的是今天的计划时同步今日重点
+func (s *Service) update(ctx context.Context, userID uint64, date string, refs []ItemRef, fn func(plan *DailyPlan)) (*DailyPlan, error) {
+	var taskIDs []uint64
+	var steps []task.StepRef
+	for _, ref := range refs {
+		if ref.StepID != nil {
+			steps = append(steps, task.StepRef{TaskID: ref.TaskID, StepID: *ref.StepID})
+		} else {
+			taskIDs = append(taskIDs, ref.TaskID)
+		}
+	}
+	if err := s.tasks.CheckOwnership(ctx, userID, taskIDs, steps); err != nil {
+		return nil, err
+	}
+
+	plan, err := s.repo.Get(ctx, userID, date)
+	if err != nil {
+		return nil, err
+	}
+	if plan == nil {
+		plan = &DailyPlan{UserID: userID, Date: date, Status: StatusAccepted}
+	}
+	fn(plan)
+	if err := s.repo.Save(ctx, plan); err != nil {
+		return nil, err
+	}
+	if date == s.Today(ctx) {
+		if err := s.SyncFocus(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+	return plan, nil
+}
+
+// SyncFocus 按今天已确认的计划项更新任务的 is_focus_today，前一天的重点在日期变化后自动清除
+func (s *Service) SyncFocus(ctx context.Context, userID uint64) error {
+	plan, err := s.repo.Get(ctx, userID, s.Today(ctx))
+	if err != nil {
+		return err
+	}
+	var taskIDs []uint64
+	if plan != nil {
+		for _, it := range plan.Items {
+			taskIDs = append(taskIDs, it.TaskID)
+		}
+	}
+	return s.repo.SetFocusTasks(ctx, userID, taskIDs)
+}
+
+// TodayView GET /today 的返回结果
+type TodayView struct {
+	Date string     `json:"date"`
+	Plan *DailyPlan `json:"plan"` // 没有计划时为 null
+	// Items 按计划顺序展开的任务和步骤，已删除的任务不再出现
+	Items []TodayItem `json:"items"`
+	// NeedsPlanning 今天还没有确认过的计划，前端可以提示用户让助手规划今天
+	NeedsPlanning bool `json:"needsPlanning"`
+}
+
+// TodayItem 计划中的一项及其任务（不含步骤列表）和步骤
+type TodayItem struct {
+	Task domain.Task      `json:"task"`
+	Step *domain.TaskStep `json:"step,omitempty"`
+}
+
+// View 返回今天的计划，同时同步今日重点
+func (s *Service) View(ctx context.Context, userID uint64) (*TodayView, error) {
+	if err := s.SyncFocus(ctx, userID); err != nil {
+		return nil, err
+	}
+	view := &TodayView{Date: s.Today(ctx), Items: []TodayItem{}}
+	plan, err := s.repo.Get(ctx, userID, view.Date)
+	if err != nil {
+		return nil, err
+	}
+	view.Plan = plan
+	view.NeedsPlanning = plan == nil || (plan.Status != StatusAccepted && len(plan.Items) == 0)
+	if plan == nil || len(plan.Items) == 0 {
+		return view, nil
+	}
+
+	ids := make([]uint64, 0, len(plan.Items))
+	for _, it := range plan.Items {
+		ids = append(ids, it.TaskID)
+	}
+	tasks, err := s.tasks.FindTasks(ctx, userID, task.TaskFilter{IDs: ids})
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uint64]domain.Task, len(tasks))
+	for _, t := range tasks {
+		byID[t.ID] = t
+	}
+	for _, it := range plan.Items {
+		t, ok := byID[it.TaskID]
+		if !ok {
+			continue
+		}
+		item := TodayItem{Task: t}
+		item.Task.Steps = nil
+		if it.StepID != nil {
+			for i := range t.Steps {
+				if t.Steps[i].ID == *it.StepID {
+					item.Step = &t.Steps[i]
+				}
+			}
+			if item.Step == nil {
+				continue
+			}
+		}
+		view.Items = append(view.Items, item)
+	}
+	return view, nil
+}
+
+func indexOf(items []DailyPlanItem, ref ItemRef) int {
+	for i, it := range items {
+		if ref.matches(it) {
+			return i
+		}
+	}
+	return -1
+}
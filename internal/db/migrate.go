@@ -13,10 +13,21 @@ func AutoMigrate(db *gorm.DB) error {
 	return db.AutoMigrate(
 		&domain.User{},
 		&domain.UserLLMSetting{},
+		&domain.LLMProfile{},
+		&domain.LLMAgentAssignment{},
 		&domain.Task{},
 		&domain.TaskStep{},
 		&domain.TaskDependency{},
 		&domain.Session{},
 		&domain.Message{},
+		&domain.SessionSummary{},
+		&domain.ReportTemplate{},
+		&domain.TimeEntry{},
+		&domain.DailyPlan{},
+		&domain.DailyPlanItem{},
+		&domain.Digest{},
+		&domain.Memory{},
+		&domain.UserProfile{},
+		&domain.LLMUsage{},
 	)
 }
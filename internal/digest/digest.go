@@ -0,0 +1,252 @@
+// Package digest 按天或按周生成跨任务的回顾：完成了什么、哪些没按时完成、哪些已过期、
+// 接下来要到期的任务和受阻的步骤。内容完全由数据库中的任务数据确定性地生成，可选地再交给 LLM 润色。
+package digest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"assistant-qisumi/internal/domain"
+)
+
+// Kind 回顾周期
+type Kind string
+
+const (
+	KindDaily  Kind = "daily"
+	KindWeekly Kind = "weekly"
+)
+
+// DateLayout 回顾周期的日期格式
+const DateLayout = "2006-01-02"
+
+// ParseKind 解析回顾周期，空字符串表示每日回顾
+func ParseKind(s string) (Kind, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "daily", "day":
+		return KindDaily, nil
+	case "weekly", "week":
+		return KindWeekly, nil
+	default:
+		return "", fmt.Errorf("unsupported digest kind %q", s)
+	}
+}
+
+// Period 返回包含 day 的周期 [start, end)：每日回顾为当天，每周回顾为周一开始的一周，时区取 day 的时区
+func Period(kind Kind, day time.Time) (time.Time, time.Time) {
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	if kind == KindWeekly {
+		start = start.AddDate(0, 0, -((int(start.Weekday()) + 6) % 7))
+		return start, start.AddDate(0, 0, 7)
+	}
+	return start, start.AddDate(0, 0, 1)
+}
+
+// upcomingDays 回顾中「即将到期」看多少天
+func upcomingDays(kind Kind) int {
+	if kind == KindWeekly {
+		return 7
+	}
+	return 1
+}
+
+// Input 生成回顾所需的数据
+type Input struct {
+	Kind  Kind
+	Start time.Time // 周期开始（用户时区）
+	End   time.Time // 周期结束（不含）
+	Now   time.Time
+	Tasks []domain.Task // 用户的全部任务（含步骤）
+}
+
+// Data 回顾的结构化内容，也是渲染 Markdown 和交给 LLM 润色的依据
+type Data struct {
+	Kind           Kind          `json:"kind"`
+	PeriodStart    string        `json:"periodStart"`
+	PeriodEnd      string        `json:"periodEnd"`
+	Completed      []TaskItem    `json:"completed"`      // 周期内完成的任务
+	CompletedSteps int           `json:"completedSteps"` // 周期内完成的步骤数
+	Slipped        []TaskItem    `json:"slipped"`        // 截止时间在周期内但没有按时完成
+	Overdue        []TaskItem    `json:"overdue"`        // 周期开始前就已过期、至今未完成
+	Upcoming       []TaskItem    `json:"upcoming"`       // 从现在（周期已结束时为周期结束）到周期结束后 1 天（每周 7 天）内到期
+	Blocked        []BlockedStep `json:"blocked"`        // 未完成任务中受阻的步骤
+}
+
+// TaskItem 回顾中的一个任务
+type TaskItem struct {
+	ID          uint64     `json:"id"`
+	Title       string     `json:"title"`
+	Priority    string     `json:"priority"`
+	Status      string     `json:"status"`
+	DueAt       *time.Time `json:"dueAt,omitempty"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+}
+
+// BlockedStep 受阻的步骤及原因
+type BlockedStep struct {
+	TaskID    uint64 `json:"taskId"`
+	TaskTitle string `json:"taskTitle"`
+	StepID    uint64 `json:"stepId"`
+	StepTitle string `json:"stepTitle"`
+	Reason    string `json:"reason"`
+}
+
+// Empty 周期内没有任何值得回顾的内容
+func (d *Data) Empty() bool {
+	return len(d.Completed) == 0 && d.CompletedSteps == 0 && len(d.Slipped) == 0 &&
+		len(d.Overdue) == 0 && len(d.Upcoming) == 0 && len(d.Blocked) == 0
+}
+
+func isOpen(t domain.Task) bool {
+	return t.Status != "done" && t.Status != "cancelled"
+}
+
+func within(t, start, end time.Time) bool {
+	return !t.Before(start) && t.Before(end)
+}
+
+func newTaskItem(t domain.Task) TaskItem {
+	item := TaskItem{ID: t.ID, Title: t.Title, Priority: t.Priority, Status: t.Status, CompletedAt: t.CompletedAt}
+	if t.DueAt != nil {
+		due := t.DueAt.ToTime()
+		item.DueAt = &due
+	}
+	return item
+}
+
+// Build 根据任务数据生成回顾内容，结果只取决于输入
+func Build(in Input) *Data {
+	d := &Data{
+		Kind:        in.Kind,
+		PeriodStart: in.Start.Format(DateLayout),
+		PeriodEnd:   in.End.Format(DateLayout),
+		Completed:   []TaskItem{},
+		Slipped:     []TaskItem{},
+		Overdue:     []TaskItem{},
+		Upcoming:    []TaskItem{},
+		Blocked:     []BlockedStep{},
+	}
+	// 周期还没结束时，「过期」和「没按时完成」只算到现在
+	cutoff := in.End
+	if in.Now.Before(cutoff) {
+		cutoff = in.Now
+	}
+	upcomingEnd := in.End.AddDate(0, 0, upcomingDays(in.Kind))
+
+	for _, t := range in.Tasks {
+		if t.CompletedAt != nil && t.Status == "done" && within(*t.CompletedAt, in.Start, in.End) {
+			d.Completed = append(d.Completed, newTaskItem(t))
+		}
+		for _, st := range t.Steps {
+			if st.CompletedAt != nil && st.Status == "done" && within(*st.CompletedAt, in.Start, in.End) {
+				d.CompletedSteps++
+			}
+		}
+
+		if t.DueAt != nil && t.Status != "cancelled" {
+			due := t.DueAt.ToTime()
+			late := t.CompletedAt == nil || t.Status != "done" || t.CompletedAt.After(due)
+			switch {
+			case within(due, in.Start, cutoff) && late:
+				d.Slipped = append(d.Slipped, newTaskItem(t))
+			case due.Before(in.Start) && isOpen(t):
+				d.Overdue = append(d.Overdue, newTaskItem(t))
+			case within(due, cutoff, upcomingEnd) && isOpen(t):
+				d.Upcoming = append(d.Upcoming, newTaskItem(t))
+			}
+		}
+
+		if isOpen(t) {
+			for _, st := range t.Steps {
+				if st.Status == "blocked" {
+					d.Blocked = append(d.Blocked, BlockedStep{
+						TaskID: t.ID, TaskTitle: t.Title, StepID: st.ID, StepTitle: st.Title, Reason: st.BlockingReason,
+					})
+				}
+			}
+		}
+	}
+
+	byDue := func(items []TaskItem) {
+		sort.SliceStable(items, func(i, j int) bool {
+			if items[i].DueAt == nil || items[j].DueAt == nil {
+				return items[j].DueAt == nil && items[i].DueAt != nil
+			}
+			return items[i].DueAt.Before(*items[j].DueAt)
+		})
+	}
+	sort.SliceStable(d.Completed, func(i, j int) bool { return d.Completed[i].CompletedAt.Before(*d.Completed[j].CompletedAt) })
+	byDue(d.Slipped)
+	byDue(d.Overdue)
+	byDue(d.Upcoming)
+	return d
+}
+
+var priorityNames = map[string]string{"high": "高", "medium": "中", "low": "低"}
+
+// Render 把回顾渲染成 Markdown，时间按 loc 显示
+func Render(d *Data, loc *time.Location) string {
+	var b strings.Builder
+	if d.Kind == KindWeekly {
+		end, _ := time.Parse(DateLayout, d.PeriodEnd)
+		fmt.Fprintf(&b, "# 每周回顾 %s ~ %s\n", d.PeriodStart, end.AddDate(0, 0, -1).Format(DateLayout))
+	} else {
+		fmt.Fprintf(&b, "# 每日回顾 %s\n", d.PeriodStart)
+	}
+	if d.Empty() {
+		b.WriteString("\n这段时间没有完成、到期或受阻的任务。\n")
+		return b.String()
+	}
+
+	clock := func(t *time.Time) string {
+		return t.In(loc).Format("01-02 15:04")
+	}
+	section := func(title string, n int) {
+		fmt.Fprintf(&b, "\n## %s（%d）\n", title, n)
+	}
+
+	if len(d.Completed) > 0 || d.CompletedSteps > 0 {
+		section("已完成", len(d.Completed))
+		for _, t := range d.Completed {
+			fmt.Fprintf(&b, "- %s（%s优先级）\n", t.Title, priorityNames[t.Priority])
+		}
+		if d.CompletedSteps > 0 {
+			fmt.Fprintf(&b, "\n共完成 %d 个步骤。\n", d.CompletedSteps)
+		}
+	}
+	if len(d.Slipped) > 0 {
+		section("没能按时完成", len(d.Slipped))
+		for _, t := range d.Slipped {
+			state := "仍未完成"
+			if t.CompletedAt != nil && t.Status == "done" {
+				state = "于 " + clock(t.CompletedAt) + " 完成"
+			}
+			fmt.Fprintf(&b, "- %s：截止 %s，%s\n", t.Title, clock(t.DueAt), state)
+		}
+	}
+	if len(d.Overdue) > 0 {
+		section("已过期", len(d.Overdue))
+		for _, t := range d.Overdue {
+			fmt.Fprintf(&b, "- %s：截止 %s\n", t.Title, clock(t.DueAt))
+		}
+	}
+	if len(d.Upcoming) > 0 {
+		section("即将到期", len(d.Upcoming))
+		for _, t := range d.Upcoming {
+			fmt.Fprintf(&b, "- %s：截止 %s\n", t.Title, clock(t.DueAt))
+		}
+	}
+	if len(d.Blocked) > 0 {
+		section("受阻的步骤", len(d.Blocked))
+		for _, st := range d.Blocked {
+			reason := st.Reason
+			if reason == "" {
+				reason = "未填写原因"
+			}
+			fmt.Fprintf(&b, "- %s / %s：%s\n", st.TaskTitle, st.StepTitle, reason)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,140 @@
+package digest
+
+import (
+	"context"
+	"time"
+
+	"assistant-qisumi/internal/domain"
+	"assistant-qisumi/internal/llm"
+	"assistant-qisumi/internal/logger"
+	"assistant-qisumi/internal/profile"
+	"assistant-qisumi/internal/session"
+
+	"go.uber.org/zap"
+)
+
+// LLMConfigSource 提供用户用于润色的 LLM 配置（见 auth.LLMSettingService）
+type LLMConfigSource interface {
+	GetAgentLLMConfig(ctx context.Context, userID uint64, agent string) (*llm.Config, error)
+}
+
+// JobOptions 定时生成回顾的选项
+type JobOptions struct {
+	Hour   int  // 用户时区的几点之后生成当天的回顾（周日同时生成本周回顾）
+	Polish bool // 是否用用户的 LLM 配置润色，用户没有可用配置时不润色
+}
+
+// Job 定时为每个用户生成每日和每周回顾，并作为系统消息发到用户的全局会话，
+// 与依赖完成的通知一样出现在助手的消息里
+type Job struct {
+	digests  *Service
+	sessions *session.Repository
+	profiles *profile.Service
+	configs  LLMConfigSource
+	opts     JobOptions
+}
+
+// NewJob 创建定时任务，configs 为空时不润色
+func NewJob(digests *Service, sessions *session.Repository, profiles *profile.Service, configs LLMConfigSource) *Job {
+	return &Job{digests: digests, sessions: sessions, profiles: profiles, configs: configs, opts: JobOptions{Hour: 21}}
+}
+
+// WithOptions 设置生成时间和是否润色
+func (j *Job) WithOptions(opts JobOptions) *Job {
+	cp := *j
+	cp.opts = opts
+	return &cp
+}
+
+// Run 每隔 interval 检查一次，直到 ctx 结束
+func (j *Job) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := j.RunOnce(ctx); err != nil {
+			logger.Logger.Error("生成回顾失败", zap.String("error", err.Error()))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce 为已到生成时间、且本周期的回顾还没发出的用户生成并发送回顾；单个用户失败不影响其他用户
+func (j *Job) RunOnce(ctx context.Context) error {
+	userIDs, err := j.digests.repo.ListUserIDs(ctx)
+	if err != nil {
+		return err
+	}
+	for _, userID := range userIDs {
+		if err := j.runForUser(ctx, userID); err != nil {
+			logger.Logger.Warn("为用户生成回顾失败",
+				zap.Uint64("user_id", userID),
+				zap.String("error", err.Error()),
+			)
+		}
+	}
+	return nil
+}
+
+func (j *Job) runForUser(ctx context.Context, userID uint64) error {
+	prof, err := j.profiles.Get(ctx, userID)
+	if err != nil {
+		return err
+	}
+	ctx = profile.NewContext(ctx, prof)
+	local := j.digests.now().In(profile.Location(prof))
+	if local.Hour() < j.opts.Hour {
+		return nil
+	}
+
+	kinds := []Kind{KindDaily}
+	if local.Weekday() == time.Sunday {
+		kinds = append(kinds, KindWeekly)
+	}
+	for _, kind := range kinds {
+		// 已生成但没发出去的回顾（例如上次发送失败、或用户手动生成过）重新生成后再发送
+		delivered, err := j.digests.Delivered(ctx, userID, kind, local)
+		if err != nil {
+			return err
+		}
+		if delivered {
+			continue
+		}
+		d, err := j.digests.Generate(ctx, userID, kind, local.Format(DateLayout), j.polishConfig(ctx, userID))
+		if err != nil {
+			return err
+		}
+		if err := j.deliver(ctx, userID, d); err != nil {
+			return err
+		}
+		if err := j.digests.MarkDelivered(ctx, userID, d.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// polishConfig 返回用于润色的配置，不润色或用户没有可用配置时为空
+func (j *Job) polishConfig(ctx context.Context, userID uint64) *llm.Config {
+	if !j.opts.Polish || j.configs == nil {
+		return nil
+	}
+	cfg, err := j.configs.GetAgentLLMConfig(ctx, userID, "summarizer")
+	if err != nil || cfg == nil || (cfg.APIKey == "" && cfg.Protocol != domain.LLMProtocolOllama) {
+		return nil
+	}
+	return cfg
+}
+
+// deliver 把回顾作为系统消息发到用户的全局会话
+func (j *Job) deliver(ctx context.Context, userID uint64, d *Digest) error {
+	sess, err := j.sessions.GetGlobalSessionOrCreate(ctx, userID)
+	if err != nil {
+		return err
+	}
+	agentName := "digest"
+	return j.sessions.CreateSystemMessage(ctx, sess.ID, &agentName, d.Content)
+}
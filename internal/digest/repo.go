@@ -0,0 +1,83 @@
+package digest
+
+import (
+	"context"
+	"time"
+
+	"assistant-qisumi/internal/domain"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 类型别名 - 引用 domain 包中的定义，避免循环依赖
+type Digest = domain.Digest
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Save 创建或覆盖用户同一周期的回顾
+func (r *Repository) Save(ctx context.Context, d *Digest) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "kind"}, {Name: "period_start"}},
+			DoUpdates: clause.AssignmentColumns([]string{"period_end", "content", "data", "polished", "updated_at"}),
+		}).
+		Create(d).Error
+}
+
+// MarkDelivered 记录回顾已发到用户的全局会话
+func (r *Repository) MarkDelivered(ctx context.Context, userID, id uint64, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&Digest{}).
+		Where("user_id = ? AND id = ?", userID, id).
+		Update("delivered_at", at).Error
+}
+
+// Get 获取用户某个周期的回顾，不存在时返回 nil
+func (r *Repository) Get(ctx context.Context, userID uint64, kind Kind, periodStart string) (*Digest, error) {
+	var digests []Digest
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND kind = ? AND period_start = ?", userID, string(kind), periodStart).
+		Limit(1).
+		Find(&digests).Error
+	if err != nil || len(digests) == 0 {
+		return nil, err
+	}
+	return &digests[0], nil
+}
+
+// GetByID 获取用户的某条回顾，不存在时返回 nil
+func (r *Repository) GetByID(ctx context.Context, userID, id uint64) (*Digest, error) {
+	var digests []Digest
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND id = ?", userID, id).
+		Limit(1).
+		Find(&digests).Error
+	if err != nil || len(digests) == 0 {
+		return nil, err
+	}
+	return &digests[0], nil
+}
+
+// List 按周期从新到旧列出用户的回顾，kind 为空时不限周期类型
+func (r *Repository) List(ctx context.Context, userID uint64, kind Kind, limit int) ([]Digest, error) {
+	q := r.db.WithContext(ctx).Where("user_id = ?", userID)
+	if kind != "" {
+		q = q.Where("kind = ?", string(kind))
+	}
+	var digests []Digest
+	err := q.Order("period_start DESC, id DESC").Limit(limit).Find(&digests).Error
+	return digests, err
+}
+
+// ListUserIDs 列出所有用户，供定时任务逐个生成回顾
+func (r *Repository) ListUserIDs(ctx context.Context) ([]uint64, error) {
+	var ids []uint64
+	err := r.db.WithContext(ctx).Model(&domain.User{}).Order("id").Pluck("id", &ids).Error
+	return ids, err
+}
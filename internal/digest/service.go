@@ -0,0 +1,133 @@
+package digest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"assistant-qisumi/internal/domain"
+	"assistant-qisumi/internal/llm"
+	"assistant-qisumi/internal/logger"
+	"assistant-qisumi/internal/prompts"
+	"assistant-qisumi/internal/task"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidDate 日期不是 YYYY-MM-DD
+var ErrInvalidDate = errors.New("invalid date")
+
+type Service struct {
+	repo      *Repository
+	tasks     *task.Repository
+	llmClient llm.Client
+	now       func() time.Time
+}
+
+// NewService 创建回顾服务，llmClient 为空时不支持润色
+func NewService(db *gorm.DB, llmClient llm.Client) *Service {
+	return &Service{repo: NewRepository(db), tasks: task.NewRepository(db), llmClient: llmClient, now: time.Now}
+}
+
+// WithClock 替换当前时间来源，用于测试
+func (s *Service) WithClock(now func() time.Time) *Service {
+	return &Service{repo: s.repo, tasks: s.tasks, llmClient: s.llmClient, now: now}
+}
+
+// Generate 生成（或重新生成）包含 date 的周期的回顾并保存，date 为空或 "today" 表示今天（用户时区）。
+// cfg 不为空时交给 LLM 润色，润色失败时保留确定性生成的内容
+func (s *Service) Generate(ctx context.Context, userID uint64, kind Kind, date string, cfg *llm.Config) (*Digest, error) {
+	loc := domain.LocationFromContext(ctx)
+	now := s.now().In(loc)
+	day := now
+	if date != "" && date != "today" {
+		d, err := time.ParseInLocation(DateLayout, date, loc)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidDate, date)
+		}
+		day = d
+	}
+	start, end := Period(kind, day)
+
+	tasks, err := s.tasks.FindTasks(ctx, userID, task.TaskFilter{})
+	if err != nil {
+		return nil, err
+	}
+	data := Build(Input{Kind: kind, Start: start, End: end, Now: now, Tasks: tasks})
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Digest{
+		UserID:      userID,
+		Kind:        string(kind),
+		PeriodStart: data.PeriodStart,
+		PeriodEnd:   data.PeriodEnd,
+		Content:     Render(data, loc),
+		Data:        string(raw),
+	}
+	if cfg != nil && !data.Empty() {
+		if polished, err := s.polish(ctx, userID, *cfg, d.Content); err != nil {
+			logger.Logger.Warn("润色回顾失败，使用自动生成的内容",
+				zap.Uint64("user_id", userID),
+				zap.String("error", err.Error()),
+			)
+		} else {
+			d.Content, d.Polished = polished, true
+		}
+	}
+
+	if err := s.repo.Save(ctx, d); err != nil {
+		return nil, err
+	}
+	return s.repo.Get(ctx, userID, kind, d.PeriodStart)
+}
+
+// polish 让 LLM 在不改动事实的前提下润色回顾
+func (s *Service) polish(ctx context.Context, userID uint64, cfg llm.Config, content string) (string, error) {
+	if s.llmClient == nil {
+		return "", errors.New("no LLM client")
+	}
+	ctx = llm.WithCallInfo(ctx, llm.CallInfo{UserID: userID, Agent: "digest"})
+	resp, err := s.llmClient.Chat(ctx, cfg, llm.ChatRequest{
+		Model: cfg.Model,
+		Messages: []llm.Message{
+			{Role: "system", Content: prompts.DigestPolishSystemPrompt},
+			{Role: "user", Content: content},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Choices) == 0 || strings.TrimSpace(resp.Choices[0].Message.Content) == "" {
+		return "", errors.New("empty response")
+	}
+	return strings.TrimSpace(resp.Choices[0].Message.Content), nil
+}
+
+// Delivered 用户某个周期的回顾是否已由定时任务发出，供定时任务避免重复发送
+func (s *Service) Delivered(ctx context.Context, userID uint64, kind Kind, day time.Time) (bool, error) {
+	start, _ := Period(kind, day)
+	d, err := s.repo.Get(ctx, userID, kind, start.Format(DateLayout))
+	return d != nil && d.DeliveredAt != nil, err
+}
+
+// MarkDelivered 记录回顾已发到用户的全局会话
+func (s *Service) MarkDelivered(ctx context.Context, userID, id uint64) error {
+	return s.repo.MarkDelivered(ctx, userID, id, s.now())
+}
+
+// List 按周期从新到旧列出用户的回顾，kind 为空时不限周期类型
+func (s *Service) List(ctx context.Context, userID uint64, kind Kind, limit int) ([]Digest, error) {
+	return s.repo.List(ctx, userID, kind, limit)
+}
+
+// Get 获取用户的某条回顾，不存在时返回 nil
+func (s *Service) Get(ctx context.Context, userID, id uint64) (*Digest, error) {
+	return s.repo.GetByID(ctx, userID, id)
+}
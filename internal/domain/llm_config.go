@@ -12,6 +12,10 @@ type LLMConfig struct {
 	AssistantName   string `json:"assistant_name"`   // 助手名称
 	HasAPIKey       bool   `json:"has_api_key"`      // 是否已设置 API Key
 	IsDefault       bool   `json:"is_default"`       // 是否使用默认配置
+	Protocol        string `json:"protocol"`         // openai（默认）, anthropic, gemini, ollama
+
+	// Fallbacks 主配置不可用时按顺序尝试的备用配置（不序列化，避免泄露密钥）
+	Fallbacks []LLMConfig `json:"-"`
 }
 
 // LLMSettingRequest 创建或更新 LLM 配置的请求
@@ -34,5 +38,51 @@ func (c *LLMConfig) ToClientConfig() *LLMConfig {
 		ThinkingType:    c.ThinkingType,
 		ReasoningEffort: c.ReasoningEffort,
 		EnableThinking:  c.EnableThinking,
+		Protocol:        c.Protocol,
 	}
 }
+
+// LLM 接口协议
+const (
+	LLMProtocolOpenAI    = "openai"    // OpenAI 兼容的 chat completions（默认）
+	LLMProtocolAnthropic = "anthropic" // Anthropic Messages API
+	LLMProtocolGemini    = "gemini"    // Gemini generateContent
+	LLMProtocolOllama    = "ollama"    // Ollama /api/chat
+)
+
+// LLMProtocols 支持的接口协议
+var LLMProtocols = []string{LLMProtocolOpenAI, LLMProtocolAnthropic, LLMProtocolGemini, LLMProtocolOllama}
+
+// LLMAgentDefault 作为 agent 名称时表示“所有未单独指定的 agent”
+const LLMAgentDefault = "default"
+
+// LLMAgentNames 可以单独指定 LLM 配置的 agent
+var LLMAgentNames = []string{LLMAgentDefault, "router", "executor", "planner", "summarizer", "global", "task_creation", "memory"}
+
+// LLMProfileRequest 创建或更新命名 LLM 配置的请求
+type LLMProfileRequest struct {
+	Name            string `json:"name" binding:"required"`
+	BaseURL         string `json:"base_url" binding:"required"`
+	APIKey          string `json:"api_key"` // 创建时必填；更新时为空表示不修改
+	Model           string `json:"model" binding:"required"`
+	ThinkingType    string `json:"thinking_type"`
+	ReasoningEffort string `json:"reasoning_effort"`
+	EnableThinking  bool   `json:"enable_thinking"`
+	FallbackOrder   int    `json:"fallback_order"` // >0 时作为备用提供商，按从小到大的顺序尝试
+	Protocol        string `json:"protocol"`       // 为空时为 openai
+}
+
+// LLMProfileView 返回给客户端的命名 LLM 配置（不含 API Key）
+type LLMProfileView struct {
+	ID              uint64   `json:"id"`
+	Name            string   `json:"name"`
+	BaseURL         string   `json:"base_url"`
+	Model           string   `json:"model"`
+	ThinkingType    string   `json:"thinking_type"`
+	ReasoningEffort string   `json:"reasoning_effort"`
+	EnableThinking  bool     `json:"enable_thinking"`
+	FallbackOrder   int      `json:"fallback_order"`
+	Protocol        string   `json:"protocol"`
+	HasAPIKey       bool     `json:"has_api_key"`
+	Agents          []string `json:"agents"` // 使用该配置的 agent
+}
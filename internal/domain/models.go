@@ -11,6 +11,7 @@ type User struct {
 	Email        string    `gorm:"column:email;type:varchar(255);uniqueIndex;not null" json:"email"`
 	DisplayName  string    `gorm:"column:display_name;type:varchar(255)" json:"display_name"`
 	PasswordHash string    `gorm:"column:password_hash;type:varchar(255);not null" json:"password_hash"`
+	IsAdmin      bool      `gorm:"column:is_admin;not null;default:false" json:"is_admin"` // 管理员可以查看全站用量
 	CreatedAt    time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
 }
 
@@ -32,14 +33,49 @@ type UserLLMSetting struct {
 
 func (UserLLMSetting) TableName() string { return "user_llm_settings" }
 
+// LLMProfile 用户的命名 LLM 配置，一个用户可以有多个（如便宜的路由模型、强力的规划模型）
+type LLMProfile struct {
+	ID              uint64    `gorm:"primaryKey;column:id" json:"id"`
+	UserID          uint64    `gorm:"column:user_id;not null;uniqueIndex:idx_llm_profile_user_name" json:"user_id"`
+	Name            string    `gorm:"column:name;type:varchar(100);not null;uniqueIndex:idx_llm_profile_user_name" json:"name"`
+	BaseURL         string    `gorm:"column:base_url;type:varchar(512);not null" json:"base_url"`
+	APIKeyEnc       string    `gorm:"column:api_key_enc;type:text;not null" json:"-"`
+	Model           string    `gorm:"column:model;type:varchar(255);not null" json:"model"`
+	ThinkingType    string    `gorm:"column:thinking_type;type:varchar(20);default:'auto'" json:"thinking_type"`
+	ReasoningEffort string    `gorm:"column:reasoning_effort;type:varchar(20);default:'medium'" json:"reasoning_effort"`
+	EnableThinking  bool      `gorm:"column:enable_thinking;default:false" json:"enable_thinking"`
+	FallbackOrder   int       `gorm:"column:fallback_order;default:0" json:"fallback_order"`             // >0 时作为备用提供商，按从小到大的顺序尝试
+	Protocol        string    `gorm:"column:protocol;type:varchar(20);default:'openai'" json:"protocol"` // 接口协议，见 LLMProtocols
+	CreatedAt       time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt       time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+func (LLMProfile) TableName() string { return "user_llm_profiles" }
+
+// LLMAgentAssignment 指定某个 agent 使用哪个 LLMProfile
+// Agent 为 "default" 时作用于所有没有单独指定的 agent
+type LLMAgentAssignment struct {
+	UserID    uint64 `gorm:"primaryKey;column:user_id" json:"user_id"`
+	Agent     string `gorm:"primaryKey;column:agent;type:varchar(50)" json:"agent"`
+	ProfileID uint64 `gorm:"column:profile_id;not null;index" json:"profile_id"`
+}
+
+func (LLMAgentAssignment) TableName() string { return "user_llm_agent_assignments" }
+
 // ==================== Session 相关模型 ====================
 
+// Session 对话会话，每个任务和全局范围都可以有多个会话
+// 没有归档的会话中最近活跃的一个是该范围的当前会话；还没有命名过（Titled 为 false）的会话由第一条用户消息生成标题
 type Session struct {
-	ID        uint64    `gorm:"primaryKey;column:id" json:"id"`
-	UserID    uint64    `gorm:"column:user_id;not null;index" json:"userId"`
-	TaskID    *uint64   `gorm:"column:task_id;index" json:"taskId,omitempty"`
-	Type      string    `gorm:"column:type;type:varchar(20);not null;default:'task'" json:"type"` // "task" or "global"
-	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+	ID            uint64     `gorm:"primaryKey;column:id" json:"id"`
+	UserID        uint64     `gorm:"column:user_id;not null;index" json:"userId"`
+	TaskID        *uint64    `gorm:"column:task_id;index" json:"taskId,omitempty"`
+	Type          string     `gorm:"column:type;type:varchar(20);not null;default:'task'" json:"type"` // "task" or "global"
+	Title         string     `gorm:"column:title;type:varchar(100);not null;default:''" json:"title"`
+	Titled        bool       `gorm:"column:titled;not null;default:false" json:"-"` // 已自动命名或由用户设置过标题（包括清空），之后不再自动命名
+	ArchivedAt    *time.Time `gorm:"column:archived_at" json:"archivedAt,omitempty"`
+	LastMessageAt *time.Time `gorm:"column:last_message_at" json:"lastMessageAt,omitempty"`
+	CreatedAt     time.Time  `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
 }
 
 func (Session) TableName() string { return "sessions" }
@@ -55,6 +91,18 @@ type Message struct {
 
 func (Message) TableName() string { return "messages" }
 
+// SessionSummary 会话中较早消息的滚动摘要，每个会话一条，随对话增长增量更新
+// UpToMessageID 为摘要覆盖到的最后一条消息，之后的消息原样提供给模型
+type SessionSummary struct {
+	SessionID     uint64    `gorm:"primaryKey;autoIncrement:false;column:session_id" json:"sessionId"`
+	Content       string    `gorm:"column:content;type:text;not null" json:"content"`
+	UpToMessageID uint64    `gorm:"column:up_to_message_id;not null" json:"upToMessageId"`
+	MessageCount  int       `gorm:"column:message_count;not null;default:0" json:"messageCount"` // 摘要累计覆盖的消息条数
+	UpdatedAt     time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updatedAt"`
+}
+
+func (SessionSummary) TableName() string { return "session_summaries" }
+
 // ==================== Task 相关模型 ====================
 
 type Task struct {
@@ -67,6 +115,7 @@ type Task struct {
 	IsFocusToday bool          `gorm:"column:is_focus_today;default:false" json:"isFocusToday"`
 	DueAt        *FlexibleTime `gorm:"column:due_at" json:"dueAt,omitempty"`
 	CreatedFrom  string        `gorm:"column:created_from;type:text" json:"createdFrom,omitempty"`
+	Tags         []string      `gorm:"column:tags;type:text;serializer:json" json:"tags,omitempty"`
 	CreatedAt    time.Time     `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
 	UpdatedAt    time.Time     `gorm:"column:updated_at;autoUpdateTime" json:"updatedAt"`
 	CompletedAt  *time.Time    `gorm:"column:completed_at" json:"completedAt,omitempty"`
@@ -109,6 +158,145 @@ type TaskDependency struct {
 
 func (TaskDependency) TableName() string { return "task_dependencies" }
 
+// ==================== 时间记录相关模型 ====================
+
+// TimeEntry 一段实际投入的时间，可以挂在任务或具体步骤上
+// Source: "timer" 计时器 | "manual" 手动补录 | "auto" 步骤 in_progress→done 自动生成（仅在没有其他记录时参与统计）
+type TimeEntry struct {
+	ID          uint64     `gorm:"primaryKey;column:id" json:"id"`
+	UserID      uint64     `gorm:"column:user_id;not null;index" json:"userId"`
+	TaskID      uint64     `gorm:"column:task_id;not null;index" json:"taskId"`
+	StepID      *uint64    `gorm:"column:step_id;index" json:"stepId,omitempty"`
+	Source      string     `gorm:"column:source;type:varchar(20);not null;default:'timer'" json:"source"`
+	StartedAt   time.Time  `gorm:"column:started_at;not null" json:"startedAt"`
+	EndedAt     *time.Time `gorm:"column:ended_at" json:"endedAt,omitempty"` // 为空表示仍在计时
+	DurationMin int        `gorm:"column:duration_minutes;not null;default:0" json:"durationMinutes"`
+	Note        string     `gorm:"column:note;type:text" json:"note,omitempty"`
+	CreatedAt   time.Time  `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+}
+
+func (TimeEntry) TableName() string { return "time_entries" }
+
+// ==================== 每日计划相关模型 ====================
+
+// DailyPlan 用户某一天的计划，Date 为用户时区的日期（YYYY-MM-DD）
+// Items 和 Note 是已确认的计划，其中的任务即当天的重点（is_focus_today 由它同步）；
+// 助手拟定的计划放在 Proposal 和 ProposalNote 中，用户确认后才替换已确认的计划
+// Status: "proposed" 有等待用户确认的计划 | "accepted" 没有待确认的计划
+type DailyPlan struct {
+	ID           uint64          `gorm:"primaryKey;column:id" json:"id"`
+	UserID       uint64          `gorm:"column:user_id;not null;uniqueIndex:idx_daily_plan_user_date" json:"userId"`
+	Date         string          `gorm:"column:plan_date;type:varchar(10);not null;uniqueIndex:idx_daily_plan_user_date" json:"date"`
+	Status       string          `gorm:"column:status;type:varchar(20);not null;default:'accepted'" json:"status"`
+	Note         string          `gorm:"column:note;type:text" json:"note"`
+	ProposalNote string          `gorm:"column:proposal_note;type:text" json:"proposalNote,omitempty"`
+	CreatedAt    time.Time       `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+	UpdatedAt    time.Time       `gorm:"column:updated_at;autoUpdateTime" json:"updatedAt"`
+	Items        []DailyPlanItem `gorm:"foreignKey:PlanID" json:"items"`
+	Proposal     []DailyPlanItem `gorm:"-" json:"proposal,omitempty"` // 与 Items 存在同一张表，Proposed 为 true
+}
+
+func (DailyPlan) TableName() string { return "daily_plans" }
+
+// DailyPlanItem 计划中的一项，StepID 为空表示整个任务
+type DailyPlanItem struct {
+	ID         uint64    `gorm:"primaryKey;column:id" json:"id"`
+	PlanID     uint64    `gorm:"column:plan_id;not null;index" json:"planId"`
+	TaskID     uint64    `gorm:"column:task_id;not null;index" json:"taskId"`
+	StepID     *uint64   `gorm:"column:step_id" json:"stepId,omitempty"`
+	Proposed   bool      `gorm:"column:proposed;not null;default:false" json:"-"` // 属于待确认的计划
+	OrderIndex int       `gorm:"column:order_index;not null;default:0" json:"orderIndex"`
+	CreatedAt  time.Time `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+}
+
+func (DailyPlanItem) TableName() string { return "daily_plan_items" }
+
+// ==================== 回顾摘要相关模型 ====================
+
+// Digest 按天或按周生成的跨任务回顾，PeriodStart/PeriodEnd 为用户时区的日期（YYYY-MM-DD，End 不含）
+// Kind: "daily" | "weekly"；Content 为 Markdown，Data 为生成 Content 的结构化数据（JSON）
+type Digest struct {
+	ID          uint64     `gorm:"primaryKey;column:id" json:"id"`
+	UserID      uint64     `gorm:"column:user_id;not null;uniqueIndex:idx_digest_user_period" json:"userId"`
+	Kind        string     `gorm:"column:kind;type:varchar(20);not null;uniqueIndex:idx_digest_user_period" json:"kind"`
+	PeriodStart string     `gorm:"column:period_start;type:varchar(10);not null;uniqueIndex:idx_digest_user_period" json:"periodStart"`
+	PeriodEnd   string     `gorm:"column:period_end;type:varchar(10);not null" json:"periodEnd"`
+	Content     string     `gorm:"column:content;type:text;not null" json:"content"`
+	Data        string     `gorm:"column:data;type:text" json:"-"`
+	Polished    bool       `gorm:"column:polished;not null;default:false" json:"polished"` // Content 是否经过 LLM 润色
+	DeliveredAt *time.Time `gorm:"column:delivered_at" json:"deliveredAt,omitempty"`       // 定时任务发到全局会话的时间，还没发送时为空
+	CreatedAt   time.Time  `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+	UpdatedAt   time.Time  `gorm:"column:updated_at;autoUpdateTime" json:"updatedAt"`
+}
+
+func (Digest) TableName() string { return "digests" }
+
+// ==================== 长期记忆相关模型 ====================
+
+// Memory 关于用户的一条长期记忆（事实或偏好），会提供给各个 agent
+// Category: "preference" 偏好 | "schedule" 作息和时间约束 | "person" 相关的人 | "fact" 其他事实
+// Source: "extracted" 从对话中提取 | "manual" 用户手动添加
+type Memory struct {
+	ID        uint64    `gorm:"primaryKey;column:id" json:"id"`
+	UserID    uint64    `gorm:"column:user_id;not null;index" json:"userId"`
+	Content   string    `gorm:"column:content;type:text;not null" json:"content"`
+	Category  string    `gorm:"column:category;type:varchar(20);not null;default:'fact'" json:"category"`
+	Source    string    `gorm:"column:source;type:varchar(20);not null;default:'extracted'" json:"source"`
+	SessionID *uint64   `gorm:"column:session_id" json:"sessionId,omitempty"` // 提取自哪个会话
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+	UpdatedAt time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updatedAt"`
+}
+
+func (Memory) TableName() string { return "user_memories" }
+
+// ==================== Report 相关模型 ====================
+
+// ReportTemplate 用户自定义的任务报告模板，覆盖内置模板
+type ReportTemplate struct {
+	ID        uint64    `gorm:"primaryKey;column:id" json:"id"`
+	UserID    uint64    `gorm:"column:user_id;not null;uniqueIndex:idx_report_tpl_user_format" json:"userId"`
+	Format    string    `gorm:"column:format;type:varchar(20);not null;uniqueIndex:idx_report_tpl_user_format" json:"format"` // "markdown" | "html"
+	Content   string    `gorm:"column:content;type:text;not null" json:"content"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+	UpdatedAt time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updatedAt"`
+}
+
+func (ReportTemplate) TableName() string { return "report_templates" }
+
+// UserProfile 用户的时区与工作时间，没有记录时使用默认值
+type UserProfile struct {
+	ID        uint64    `gorm:"primaryKey;column:id" json:"id"`
+	UserID    uint64    `gorm:"column:user_id;not null;uniqueIndex" json:"userId"`
+	Timezone  string    `gorm:"column:timezone;type:varchar(64);not null;default:''" json:"timezone"`        // IANA 时区名，例如 Asia/Shanghai；为空表示服务器时区
+	WorkStart string    `gorm:"column:work_start;type:varchar(5);not null;default:'09:00'" json:"workStart"` // HH:MM
+	WorkEnd   string    `gorm:"column:work_end;type:varchar(5);not null;default:'18:00'" json:"workEnd"`     // HH:MM
+	WorkDays  []int     `gorm:"column:work_days;type:text;serializer:json" json:"workDays"`                  // 1 = 周一 ... 7 = 周日
+	DaysOff   []string  `gorm:"column:days_off;type:text;serializer:json" json:"daysOff"`                    // 休假等额外休息日，YYYY-MM-DD
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+	UpdatedAt time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updatedAt"`
+}
+
+func (UserProfile) TableName() string { return "user_profiles" }
+
+// LLMUsage 一次成功的 LLM 调用消耗的 token 数和费用
+type LLMUsage struct {
+	ID               uint64    `gorm:"primaryKey;column:id" json:"id"`
+	UserID           uint64    `gorm:"column:user_id;not null;index:idx_llm_usage_user_time,priority:1" json:"user_id"`
+	SessionID        *uint64   `gorm:"column:session_id;index" json:"session_id,omitempty"`
+	Agent            string    `gorm:"column:agent;type:varchar(32);not null;default:''" json:"agent"`
+	Provider         string    `gorm:"column:provider;type:varchar(255);not null;default:''" json:"provider"` // 实际使用的 base URL
+	Model            string    `gorm:"column:model;type:varchar(128);not null;default:''" json:"model"`
+	DefaultKey       bool      `gorm:"column:default_key;not null;default:false" json:"default_key"` // 是否使用服务器默认的 API Key
+	PromptTokens     int64     `gorm:"column:prompt_tokens;not null;default:0" json:"prompt_tokens"`
+	CompletionTokens int64     `gorm:"column:completion_tokens;not null;default:0" json:"completion_tokens"`
+	ReasoningTokens  int64     `gorm:"column:reasoning_tokens;not null;default:0" json:"reasoning_tokens"`
+	TotalTokens      int64     `gorm:"column:total_tokens;not null;default:0" json:"total_tokens"`
+	Cost             float64   `gorm:"column:cost;not null;default:0" json:"cost"` // 按记录时的价格表计算
+	CreatedAt        time.Time `gorm:"column:created_at;autoCreateTime;index:idx_llm_usage_user_time,priority:2;index" json:"created_at"`
+}
+
+func (LLMUsage) TableName() string { return "llm_usage" }
+
 // ==================== Task 更新相关结构 ====================
 
 type UpdateTaskFields struct {
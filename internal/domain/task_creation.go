@@ -3,7 +3,10 @@ package domain
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
+
+	"assistant-qisumi/internal/jsonschema"
 )
 
 // TaskCreationInput 任务创建的输入
@@ -29,12 +32,66 @@ type StepData struct {
 	OrderIndex      int    `json:"order_index"`
 }
 
-// ParseTaskCreationResponse 解析 LLM 返回的任务创建响应
+// TaskCreationSchema 任务创建结果的 JSON Schema：既作为结构化输出 / create_task 工具的参数发给模型，
+// 也用于校验模型的输出。只要求必需的字段，缺少 due_at、detail 等可选字段的输出仍然可用
+const TaskCreationSchema = `{
+  "type": "object",
+  "properties": {
+    "title": { "type": "string", "minLength": 1, "description": "任务标题，用一句话概括" },
+    "description": { "type": "string", "description": "简短描述" },
+    "due_at": {
+      "type": ["string", "null"],
+      "description": "截止时间，ISO 8601 格式，例如 2025-12-08T23:00:00+08:00；没有明确时间时为 null"
+    },
+    "priority": { "type": "string", "enum": ["low", "medium", "high"] },
+    "steps": {
+      "type": "array",
+      "description": "按执行顺序排列的步骤",
+      "items": {
+        "type": "object",
+        "properties": {
+          "title": { "type": "string", "minLength": 1 },
+          "detail": { "type": "string" },
+          "estimate_minutes": { "type": "integer", "minimum": 1, "description": "预估需要的分钟数" },
+          "order_index": { "type": "integer", "description": "从 1 开始的执行顺序" }
+        },
+        "required": ["title"]
+      }
+    }
+  },
+  "required": ["title", "priority", "steps"]
+}`
+
+var taskCreationSchema = jsonschema.MustParse(TaskCreationSchema)
+
+// ParseTaskCreationResponse 解析并校验 LLM 返回的任务创建响应（兼容包在 Markdown 代码块中的 JSON）
+// 不符合 TaskCreationSchema 时返回的错误可以用 errors.As 取出 *jsonschema.ValidationError，
+// 其中的字段错误可以直接反馈给模型修正
 func ParseTaskCreationResponse(content string) (*TaskCreationOutput, error) {
 	content = ExtractJSON(content)
+	if err := taskCreationSchema.Validate([]byte(content)); err != nil {
+		return nil, fmt.Errorf("invalid task creation response: %w", err)
+	}
+
 	var output TaskCreationOutput
 	if err := json.Unmarshal([]byte(content), &output); err != nil {
-		return nil, fmt.Errorf("failed to parse task creation response: %w, content: %s", err, content)
+		// Schema 已保证结构正确，这里只可能是截止时间格式无法识别
+		return nil, fmt.Errorf("invalid task creation response: %w", &jsonschema.ValidationError{
+			Errors: []jsonschema.FieldError{{Path: "due_at", Message: "is not a valid ISO 8601 time"}},
+		})
+	}
+
+	var errs []jsonschema.FieldError
+	if strings.TrimSpace(output.Title) == "" {
+		errs = append(errs, jsonschema.FieldError{Path: "title", Message: "must not be blank"})
+	}
+	for i, step := range output.Steps {
+		if strings.TrimSpace(step.Title) == "" {
+			errs = append(errs, jsonschema.FieldError{Path: fmt.Sprintf("steps[%d].title", i), Message: "must not be blank"})
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid task creation response: %w", &jsonschema.ValidationError{Errors: errs})
 	}
 	return &output, nil
 }
@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"context"
 	"database/sql/driver"
 	"fmt"
 	"strings"
@@ -11,17 +12,40 @@ import (
 // 用于处理 LLM 返回的不同日期格式
 type FlexibleTime struct {
 	Time time.Time
+	// floating 表示原始字符串不带时区（例如 "2025-12-08T20:00:00"），
+	// 暂按 UTC 解析，需要通过 AssumeLocation 按用户时区重新理解
+	floating bool
 }
 
-// 支持的日期格式列表
-var timeFormats = []string{
-	time.RFC3339,           // "2006-01-02T15:04:05Z07:00"
-	"2006-01-02T15:04:05Z", // "2006-01-02T15:04:05Z"
-	"2006-01-02T15:04:05",  // "2006-01-02T15:04:05"
-	"2006-01-02 15:04:05",  // "2006-01-02 15:04:05"
-	"2006-01-02",           // "2006-01-02" (仅日期)
-	"2006/01/02",           // "2006/01/02"
-	"2006年01月02日",          // "2006年01月02日"
+// 支持的日期格式列表，zoned 表示格式本身带有时区信息
+var timeFormats = []struct {
+	layout string
+	zoned  bool
+}{
+	{time.RFC3339, true},           // "2006-01-02T15:04:05Z07:00"
+	{"2006-01-02T15:04:05Z", true}, // "2006-01-02T15:04:05Z"
+	{"2006-01-02T15:04:05", false}, // "2006-01-02T15:04:05"
+	{"2006-01-02T15:04", false},    // "2006-01-02T15:04"
+	{"2006-01-02 15:04:05", false}, // "2006-01-02 15:04:05"
+	{"2006-01-02 15:04", false},    // "2006-01-02 15:04"
+	{"2006-01-02", false},          // "2006-01-02" (仅日期)
+	{"2006/01/02", false},          // "2006/01/02"
+	{"2006年01月02日", false},         // "2006年01月02日"
+}
+
+// parseIn 按支持的格式解析时间，不带时区的格式按 loc 解析
+func parseIn(s string, loc *time.Location) (time.Time, bool, error) {
+	for _, f := range timeFormats {
+		zone := loc
+		if f.zoned {
+			zone = time.UTC
+		}
+		t, err := time.ParseInLocation(f.layout, s, zone)
+		if err == nil {
+			return t, !f.zoned, nil
+		}
+	}
+	return time.Time{}, false, fmt.Errorf("无法解析时间: %q", s)
 }
 
 // UnmarshalJSON 实现 json.Unmarshaler 接口
@@ -30,19 +54,77 @@ func (ft *FlexibleTime) UnmarshalJSON(data []byte) error {
 	s := strings.Trim(string(data), `"`)
 	if s == "" || s == "null" {
 		ft.Time = time.Time{}
+		ft.floating = false
 		return nil
 	}
 
-	// 尝试用各种格式解析
-	for _, format := range timeFormats {
-		t, err := time.Parse(format, s)
-		if err == nil {
-			ft.Time = t
-			return nil
-		}
+	t, floating, err := parseIn(s, time.UTC)
+	if err != nil {
+		return err
+	}
+	ft.Time = t
+	ft.floating = floating
+	return nil
+}
+
+// AssumeLocation 把不带时区的时间按 loc 的墙上时间重新解释，带时区的时间保持不变
+func (ft *FlexibleTime) AssumeLocation(loc *time.Location) {
+	if ft == nil || !ft.floating || loc == nil {
+		return
+	}
+	t := ft.Time
+	ft.Time = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+	ft.floating = false
+}
+
+// FloatingTime 用不带时区的墙上时间构造 FlexibleTime，之后需要通过 AssumeLocation 按用户时区理解
+func FloatingTime(t time.Time) *FlexibleTime {
+	return &FlexibleTime{Time: t, floating: true}
+}
+
+// AssumeLocation 把任务及其步骤中用户提供的不带时区的时间按 loc 理解
+func (t *Task) AssumeLocation(loc *time.Location) {
+	t.DueAt.AssumeLocation(loc)
+	for i := range t.Steps {
+		t.Steps[i].AssumeLocation(loc)
+	}
+}
+
+// AssumeLocation 把步骤计划时间中不带时区的时间按 loc 理解
+func (s *TaskStep) AssumeLocation(loc *time.Location) {
+	s.PlannedStart.AssumeLocation(loc)
+	s.PlannedEnd.AssumeLocation(loc)
+}
+
+// ToLocation 把任务及其步骤中的时间换算到 loc（表示的时刻不变），用于按用户时区展示
+func (t *Task) ToLocation(loc *time.Location) {
+	if loc == nil {
+		return
 	}
+	t.DueAt.toLocation(loc)
+	t.CreatedAt, t.UpdatedAt = t.CreatedAt.In(loc), t.UpdatedAt.In(loc)
+	t.CompletedAt = timeIn(t.CompletedAt, loc)
+	for i := range t.Steps {
+		s := &t.Steps[i]
+		s.PlannedStart.toLocation(loc)
+		s.PlannedEnd.toLocation(loc)
+		s.CreatedAt, s.UpdatedAt = s.CreatedAt.In(loc), s.UpdatedAt.In(loc)
+		s.CompletedAt = timeIn(s.CompletedAt, loc)
+	}
+}
+
+func (ft *FlexibleTime) toLocation(loc *time.Location) {
+	if ft != nil && !ft.IsZero() {
+		ft.Time = ft.Time.In(loc)
+	}
+}
 
-	return fmt.Errorf("无法解析时间: %q", s)
+func timeIn(t *time.Time, loc *time.Location) *time.Time {
+	if t == nil {
+		return nil
+	}
+	in := t.In(loc)
+	return &in
 }
 
 // MarshalJSON 实现 json.Marshaler 接口
@@ -101,6 +183,7 @@ func (ft FlexibleTime) IsZero() bool {
 }
 
 // ParseFlexibleTime 从字符串解析时间，支持多种格式
+// 不带时区的字符串按 UTC 解析，需要按用户时区理解时使用 ParseFlexibleTimeIn
 func ParseFlexibleTime(s string) (*FlexibleTime, error) {
 	if s == "" {
 		return nil, nil
@@ -112,6 +195,38 @@ func ParseFlexibleTime(s string) (*FlexibleTime, error) {
 	return &ft, nil
 }
 
+// ParseFlexibleTimeIn 从字符串解析时间，不带时区的字符串按 loc 解析
+func ParseFlexibleTimeIn(s string, loc *time.Location) (*FlexibleTime, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	t, _, err := parseIn(s, loc)
+	if err != nil {
+		return nil, err
+	}
+	return &FlexibleTime{Time: t}, nil
+}
+
+type locationKey struct{}
+
+// WithLocation 在 context 中记录当前用户的时区，用于解析不带时区的时间
+func WithLocation(ctx context.Context, loc *time.Location) context.Context {
+	return context.WithValue(ctx, locationKey{}, loc)
+}
+
+// LocationFromContext 获取 context 中的用户时区，未设置时为服务器时区
+func LocationFromContext(ctx context.Context) *time.Location {
+	if ctx != nil {
+		if loc, ok := ctx.Value(locationKey{}).(*time.Location); ok && loc != nil {
+			return loc
+		}
+	}
+	return time.Local
+}
+
 // ParseRFC3339 从字符串解析 RFC3339 格式的时间
 func ParseRFC3339(s string) (time.Time, error) {
 	if s == "" {
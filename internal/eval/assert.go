@@ -0,0 +1,159 @@
+package eval
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"assistant-qisumi/internal/agent"
+	"assistant-qisumi/internal/task"
+)
+
+// outcome 一次运行的结果：回复、patch 以及应用 patch 后的任务
+type outcome struct {
+	Reply   string
+	Patches []agent.TaskPatch
+	Tasks   []task.Task
+}
+
+func (o *outcome) task(id uint64) *task.Task {
+	for i := range o.Tasks {
+		if o.Tasks[i].ID == id {
+			return &o.Tasks[i]
+		}
+	}
+	return nil
+}
+
+func (o *outcome) step(id uint64) *task.TaskStep {
+	for i := range o.Tasks {
+		for j := range o.Tasks[i].Steps {
+			if o.Tasks[i].Steps[j].ID == id {
+				return &o.Tasks[i].Steps[j]
+			}
+		}
+	}
+	return nil
+}
+
+// assertion 检查结果，不满足时返回原因
+type assertion func(o *outcome) (bool, string)
+
+type assertionRule struct {
+	pattern *regexp.Regexp
+	build   func(m []string) assertion
+}
+
+// 支持的断言：
+//
+//	step <id> <status>          步骤状态
+//	task <id> <status>          任务状态
+//	task <id> focus today       任务被标记为今日重点
+//	task <id> has <n> steps     任务的步骤数
+//	no task <status>            没有任务处于该状态，例如 no task cancelled
+//	no step <status>            没有步骤处于该状态
+//	patches <n> / no patches    patch 数量
+//	reply contains <text>       回复包含文本
+//	reply not contains <text>   回复不包含文本
+var assertionRules = []assertionRule{
+	{regexp.MustCompile(`(?i)^step (\d+) (\w+)$`), func(m []string) assertion {
+		id, status := parseID(m[1]), strings.ToLower(m[2])
+		return func(o *outcome) (bool, string) {
+			st := o.step(id)
+			if st == nil {
+				return false, fmt.Sprintf("step %d not found", id)
+			}
+			return st.Status == status, fmt.Sprintf("step %d is %s", id, st.Status)
+		}
+	}},
+	{regexp.MustCompile(`(?i)^task (\d+) focus today$`), func(m []string) assertion {
+		id := parseID(m[1])
+		return func(o *outcome) (bool, string) {
+			t := o.task(id)
+			if t == nil {
+				return false, fmt.Sprintf("task %d not found", id)
+			}
+			return t.IsFocusToday, fmt.Sprintf("task %d is not marked focus today", id)
+		}
+	}},
+	{regexp.MustCompile(`(?i)^task (\d+) has (\d+) steps?$`), func(m []string) assertion {
+		id := parseID(m[1])
+		n, _ := strconv.Atoi(m[2])
+		return func(o *outcome) (bool, string) {
+			t := o.task(id)
+			if t == nil {
+				return false, fmt.Sprintf("task %d not found", id)
+			}
+			return len(t.Steps) == n, fmt.Sprintf("task %d has %d steps", id, len(t.Steps))
+		}
+	}},
+	{regexp.MustCompile(`(?i)^task (\d+) (\w+)$`), func(m []string) assertion {
+		id, status := parseID(m[1]), strings.ToLower(m[2])
+		return func(o *outcome) (bool, string) {
+			t := o.task(id)
+			if t == nil {
+				return false, fmt.Sprintf("task %d not found", id)
+			}
+			return t.Status == status, fmt.Sprintf("task %d is %s", id, t.Status)
+		}
+	}},
+	{regexp.MustCompile(`(?i)^no task (\w+)$`), func(m []string) assertion {
+		status := strings.ToLower(m[1])
+		return func(o *outcome) (bool, string) {
+			for _, t := range o.Tasks {
+				if t.Status == status {
+					return false, fmt.Sprintf("task %d is %s", t.ID, status)
+				}
+			}
+			return true, ""
+		}
+	}},
+	{regexp.MustCompile(`(?i)^no step (\w+)$`), func(m []string) assertion {
+		status := strings.ToLower(m[1])
+		return func(o *outcome) (bool, string) {
+			for _, t := range o.Tasks {
+				for _, st := range t.Steps {
+					if st.Status == status {
+						return false, fmt.Sprintf("step %d is %s", st.ID, status)
+					}
+				}
+			}
+			return true, ""
+		}
+	}},
+	{regexp.MustCompile(`(?i)^(?:no patches|patches (\d+))$`), func(m []string) assertion {
+		n, _ := strconv.Atoi(m[1])
+		return func(o *outcome) (bool, string) {
+			return len(o.Patches) == n, fmt.Sprintf("got %d patches", len(o.Patches))
+		}
+	}},
+	{regexp.MustCompile(`(?i)^reply (not )?contains (.+)$`), func(m []string) assertion {
+		negate, text := m[1] != "", strings.Trim(m[2], `"'`)
+		return func(o *outcome) (bool, string) {
+			if strings.Contains(o.Reply, text) == negate {
+				if negate {
+					return false, fmt.Sprintf("reply contains %q", text)
+				}
+				return false, fmt.Sprintf("reply does not contain %q", text)
+			}
+			return true, ""
+		}
+	}},
+}
+
+// parseAssertion 关键字和状态不区分大小写，reply 断言的文本保持原样
+func parseAssertion(s string) (assertion, error) {
+	normalized := strings.Join(strings.Fields(s), " ")
+	for _, rule := range assertionRules {
+		if m := rule.pattern.FindStringSubmatch(normalized); m != nil {
+			return rule.build(m), nil
+		}
+	}
+	return nil, fmt.Errorf("unknown assertion %q", s)
+}
+
+func parseID(s string) uint64 {
+	id, _ := strconv.ParseUint(s, 10, 64)
+	return id
+}
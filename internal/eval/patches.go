@@ -0,0 +1,116 @@
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"assistant-qisumi/internal/agent"
+)
+
+// patchMatch 期望与实际 patch 的对应结果
+type patchMatch struct {
+	Matched    []any // 命中的实际 patch
+	Missing    []any // 没有命中的期望 patch
+	Unexpected []any // 没有对应期望的实际 patch
+}
+
+// matchPatches 按顺序为每个期望 patch 找到第一个包含其全部字段的实际 patch
+func matchPatches(expected []map[string]any, actual []agent.TaskPatch) (*patchMatch, error) {
+	exp, err := toGeneric(expected)
+	if err != nil {
+		return nil, fmt.Errorf("expected patches: %w", err)
+	}
+	act, err := toGeneric(actual)
+	if err != nil {
+		return nil, err
+	}
+	expList, _ := exp.([]any)
+	actList, _ := act.([]any)
+
+	result := &patchMatch{}
+	used := make([]bool, len(actList))
+	for _, e := range expList {
+		found := false
+		for i, a := range actList {
+			if !used[i] && containsValue(a, e) {
+				used[i], found = true, true
+				result.Matched = append(result.Matched, a)
+				break
+			}
+		}
+		if !found {
+			result.Missing = append(result.Missing, e)
+		}
+	}
+	for i, a := range actList {
+		if !used[i] {
+			result.Unexpected = append(result.Unexpected, a)
+		}
+	}
+	return result, nil
+}
+
+// toGeneric 经过 JSON 转换为通用结构，数字统一为 float64
+func toGeneric(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out any
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// containsValue 实际值是否包含期望值：对象只比较期望中写出的键，数组要求长度相同且逐项包含
+func containsValue(actual, expected any) bool {
+	switch e := expected.(type) {
+	case map[string]any:
+		a, ok := actual.(map[string]any)
+		if !ok {
+			return false
+		}
+		for k, ev := range e {
+			av, ok := a[k]
+			if !ok || !containsValue(av, ev) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		a, ok := actual.([]any)
+		if !ok || len(a) != len(e) {
+			return false
+		}
+		for i := range e {
+			if !containsValue(a[i], e[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(actual, expected)
+	}
+}
+
+// diff 以 "-" 标出缺失的期望 patch，"+" 标出预期之外的实际 patch，命中的 patch 不带标记
+func (m *patchMatch) diff() string {
+	var b strings.Builder
+	write := func(prefix string, v any) {
+		data, _ := json.Marshal(v)
+		fmt.Fprintf(&b, "%s %s\n", prefix, data)
+	}
+	for _, v := range m.Matched {
+		write(" ", v)
+	}
+	for _, v := range m.Missing {
+		write("-", v)
+	}
+	for _, v := range m.Unexpected {
+		write("+", v)
+	}
+	return b.String()
+}
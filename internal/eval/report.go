@@ -0,0 +1,58 @@
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteReport 输出每个场景的通过情况和失败原因，返回失败的场景数
+// verbose 为 true 时同时输出通过场景的回复和 patch
+func WriteReport(w io.Writer, results []*Result, verbose bool) int {
+	failed := 0
+	for _, r := range results {
+		status := "PASS"
+		if !r.Passed() {
+			status = "FAIL"
+			failed++
+		}
+		fmt.Fprintf(w, "--- %s: %s (%s, agent=%s, %.2fs)\n", status, r.Scenario.Name, r.Scenario.File, orDash(r.Agent), r.Duration.Seconds())
+
+		if r.Err != nil {
+			fmt.Fprintf(w, "    error: %v\n", r.Err)
+			continue
+		}
+		for _, f := range r.Failures {
+			fmt.Fprintf(w, "    %s\n", f)
+		}
+		if r.PatchDiff != "" {
+			fmt.Fprintf(w, "    patches (- expected but missing, + unexpected):\n")
+			writeIndented(w, r.PatchDiff, "      ")
+		}
+		if verbose || !r.Passed() {
+			fmt.Fprintf(w, "    reply: %s\n", strings.ReplaceAll(strings.TrimSpace(r.Reply), "\n", "\n           "))
+		}
+		if verbose && r.PatchDiff == "" {
+			for _, p := range r.Patches {
+				data, _ := json.Marshal(p)
+				fmt.Fprintf(w, "    patch: %s\n", data)
+			}
+		}
+	}
+	fmt.Fprintf(w, "\n%d passed, %d failed, %d total\n", len(results)-failed, failed, len(results))
+	return failed
+}
+
+func writeIndented(w io.Writer, text, indent string) {
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		fmt.Fprintf(w, "%s%s\n", indent, line)
+	}
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,242 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"assistant-qisumi/internal/agent"
+	"assistant-qisumi/internal/dailyplan"
+	"assistant-qisumi/internal/db"
+	"assistant-qisumi/internal/dependency"
+	"assistant-qisumi/internal/domain"
+	"assistant-qisumi/internal/llm"
+	"assistant-qisumi/internal/session"
+	"assistant-qisumi/internal/task"
+
+	"gorm.io/gorm"
+)
+
+// evalUserID 场景数据都属于这个用户
+const evalUserID uint64 = 1
+
+// Result 单个场景的运行结果
+type Result struct {
+	Scenario  *Scenario
+	Agent     string
+	Reply     string
+	Patches   []agent.TaskPatch
+	Failures  []string
+	PatchDiff string
+	Err       error
+	Duration  time.Duration
+}
+
+// Passed 运行成功且所有期望都满足
+func (r *Result) Passed() bool {
+	return r.Err == nil && len(r.Failures) == 0
+}
+
+// Runner 在独立的临时数据库中通过 agent.Service 运行场景
+type Runner struct {
+	client llm.Client
+	cfg    llm.Config
+}
+
+func NewRunner(client llm.Client, cfg llm.Config) *Runner {
+	return &Runner{client: client, cfg: cfg}
+}
+
+// Run 运行单个场景；场景本身的错误记录在 Result.Err 中
+func (r *Runner) Run(ctx context.Context, sc *Scenario) *Result {
+	start := time.Now()
+	result := &Result{Scenario: sc}
+	result.Err = r.run(ctx, sc, result)
+	result.Duration = time.Since(start)
+	return result
+}
+
+func (r *Runner) run(ctx context.Context, sc *Scenario, result *Result) error {
+	// 使用临时文件而不是 :memory:，连接池中的每个连接都能看到同一个数据库
+	dir, err := os.MkdirTemp("", "agent-eval-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	gormDB, err := db.NewGormDB("sqlite", filepath.Join(dir, "eval.db"))
+	if err != nil {
+		return err
+	}
+	if sqlDB, err := gormDB.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+	if err := db.AutoMigrate(gormDB); err != nil {
+		return err
+	}
+
+	taskRepo := task.NewRepository(gormDB)
+	sessionRepo := session.NewRepository(gormDB)
+	sessionID, err := seed(ctx, gormDB, taskRepo, sc)
+	if err != nil {
+		return fmt.Errorf("seed: %w", err)
+	}
+
+	tools := agent.NewToolRegistry(taskRepo, sessionRepo)
+	agents := agent.NewDefaultAgents(r.client, agent.NewChatCompletionsHandler(r.client, tools))
+	// 记录实际处理的 agent
+	router := &recordingRouter{Router: agent.NewSimpleRouter()}
+	svc := agent.NewService(router, agents, taskRepo, sessionRepo, dependency.NewService(gormDB, taskRepo, sessionRepo), gormDB, r.client)
+	// 先等后台工作（记忆提取、历史摘要）结束，再关闭并删除临时数据库
+	defer svc.Wait()
+
+	resp, err := svc.HandleUserMessage(ctx, evalUserID, sessionID, sc.Input, r.cfg)
+	result.Agent = router.agent
+	if err != nil {
+		return err
+	}
+	result.Reply = resp.AssistantMessage
+	result.Patches = resp.TaskPatches
+
+	out := &outcome{Reply: resp.AssistantMessage, Patches: resp.TaskPatches}
+	tasks, err := taskRepo.ListTasks(ctx, evalUserID)
+	if err != nil {
+		return err
+	}
+	for _, t := range tasks {
+		full, err := taskRepo.GetTaskWithSteps(ctx, evalUserID, t.ID)
+		if err != nil {
+			return err
+		}
+		out.Tasks = append(out.Tasks, *full)
+	}
+
+	if len(sc.Expect.Patches) > 0 || sc.Expect.ExactPatches {
+		match, err := matchPatches(sc.Expect.Patches, resp.TaskPatches)
+		if err != nil {
+			return err
+		}
+		if len(match.Missing) > 0 {
+			result.Failures = append(result.Failures, fmt.Sprintf("%d expected patches not found", len(match.Missing)))
+		}
+		if sc.Expect.ExactPatches && len(match.Unexpected) > 0 {
+			result.Failures = append(result.Failures, fmt.Sprintf("%d unexpected patches", len(match.Unexpected)))
+		}
+		if len(result.Failures) > 0 {
+			result.PatchDiff = match.diff()
+		}
+	}
+
+	for _, a := range sc.Expect.Assertions {
+		check, err := parseAssertion(a)
+		if err != nil {
+			return err
+		}
+		if ok, detail := check(out); !ok {
+			result.Failures = append(result.Failures, fmt.Sprintf("%s: %s", a, detail))
+		}
+	}
+	return nil
+}
+
+// seed 写入场景的初始任务、会话和历史消息，返回会话 ID
+func seed(ctx context.Context, gormDB *gorm.DB, taskRepo *task.Repository, sc *Scenario) (uint64, error) {
+	var firstTaskID uint64
+	var focus []dailyplan.ItemRef
+	for _, ts := range sc.Tasks {
+		t := &task.Task{
+			ID:          ts.ID,
+			UserID:      evalUserID,
+			Title:       ts.Title,
+			Description: ts.Description,
+			Status:      defaultString(ts.Status, "todo"),
+			Priority:    defaultString(ts.Priority, "medium"),
+		}
+		if ts.DueAt != "" {
+			due, err := domain.ParseFlexibleTime(ts.DueAt)
+			if err != nil {
+				return 0, fmt.Errorf("task %q due_at: %w", ts.Title, err)
+			}
+			t.DueAt = due
+		}
+		for i, ss := range ts.Steps {
+			t.Steps = append(t.Steps, task.TaskStep{
+				ID:          ss.ID,
+				OrderIndex:  i,
+				Title:       ss.Title,
+				Detail:      ss.Detail,
+				Status:      defaultString(ss.Status, "todo"),
+				EstimateMin: ss.EstimateMinutes,
+			})
+		}
+		if err := taskRepo.InsertTaskWithSteps(ctx, t); err != nil {
+			return 0, err
+		}
+		if firstTaskID == 0 {
+			firstTaskID = t.ID
+		}
+		if ts.FocusToday {
+			focus = append(focus, dailyplan.ItemRef{TaskID: t.ID})
+		}
+	}
+	// 今日重点由今天已确认的计划决定（每轮对话都会按计划同步），因此写入计划而不是直接设置 is_focus_today
+	if len(focus) > 0 {
+		plans := dailyplan.NewService(gormDB)
+		if _, err := plans.Mark(ctx, evalUserID, plans.Today(ctx), focus); err != nil {
+			return 0, fmt.Errorf("daily plan: %w", err)
+		}
+	}
+
+	sess := session.Session{UserID: evalUserID, Type: "task"}
+	if sc.Session == "global" {
+		sess.Type = "global"
+	} else {
+		taskID := sc.TaskID
+		if taskID == 0 {
+			taskID = firstTaskID
+		}
+		sess.TaskID = &taskID
+	}
+	if err := gormDB.WithContext(ctx).Create(&sess).Error; err != nil {
+		return 0, err
+	}
+
+	// 历史消息的时间依次递增，保证读取顺序
+	base := time.Now().Add(-time.Duration(len(sc.Messages)+1) * time.Minute)
+	for i, ms := range sc.Messages {
+		msg := session.Message{
+			SessionID: sess.ID,
+			Role:      ms.Role,
+			Content:   strings.TrimSpace(ms.Content),
+			CreatedAt: base.Add(time.Duration(i) * time.Minute),
+		}
+		if ms.Agent != "" {
+			name := ms.Agent
+			msg.AgentName = &name
+		}
+		if err := gormDB.WithContext(ctx).Create(&msg).Error; err != nil {
+			return 0, err
+		}
+	}
+	return sess.ID, nil
+}
+
+func defaultString(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+// recordingRouter 记录路由到的 agent
+type recordingRouter struct {
+	agent.Router
+	agent string
+}
+
+func (r *recordingRouter) Route(req agent.AgentRequest) string {
+	r.agent = r.Router.Route(req)
+	return r.agent
+}
@@ -0,0 +1,144 @@
+package eval
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+)
+
+// Scenario 一个评测场景：初始任务和对话、用户输入以及期望的结果
+type Scenario struct {
+	Name        string        `yaml:"name"`
+	Description string        `yaml:"description"`
+	Session     string        `yaml:"session"` // task（默认）或 global
+	TaskID      uint64        `yaml:"task_id"` // task 会话所属的任务，默认第一个任务
+	Tasks       []TaskSeed    `yaml:"tasks"`
+	Messages    []MessageSeed `yaml:"messages"`
+	Input       string        `yaml:"input"`
+	Expect      Expectation   `yaml:"expect"`
+
+	File string `yaml:"-"`
+}
+
+// TaskSeed 场景的初始任务，ID 为空时按顺序分配
+type TaskSeed struct {
+	ID          uint64     `yaml:"id"`
+	Title       string     `yaml:"title"`
+	Description string     `yaml:"description"`
+	Status      string     `yaml:"status"`
+	Priority    string     `yaml:"priority"`
+	DueAt       string     `yaml:"due_at"`
+	FocusToday  bool       `yaml:"focus_today"`
+	Steps       []StepSeed `yaml:"steps"`
+}
+
+// StepSeed 初始步骤，ID 为空时按顺序分配
+type StepSeed struct {
+	ID              uint64 `yaml:"id"`
+	Title           string `yaml:"title"`
+	Detail          string `yaml:"detail"`
+	Status          string `yaml:"status"`
+	EstimateMinutes *int   `yaml:"estimate_minutes"`
+}
+
+// MessageSeed 用户输入之前的历史消息
+type MessageSeed struct {
+	Role    string `yaml:"role"` // user, assistant, system
+	Content string `yaml:"content"`
+	Agent   string `yaml:"agent"`
+}
+
+// Expectation 期望的结果
+type Expectation struct {
+	// Patches 期望出现的 TaskPatch，格式与 agent.TaskPatch 的 JSON 相同，只比较写出的字段
+	Patches []map[string]any `yaml:"patches"`
+	// ExactPatches 为 true 时不允许出现预期之外的 patch
+	ExactPatches bool `yaml:"exact_patches"`
+	// Assertions 对回复和应用 patch 后任务状态的断言，例如 "step 3 done"、"no task cancelled"
+	Assertions []string `yaml:"assertions"`
+}
+
+// LoadScenario 读取单个场景文件
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var sc Scenario
+	if err := yaml.UnmarshalWithOptions(data, &sc, yaml.Strict()); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	sc.File = path
+	if sc.Name == "" {
+		sc.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if err := sc.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &sc, nil
+}
+
+// LoadScenarios 读取路径下的场景：目录读取其中所有 .yaml / .yml 文件，按文件名排序
+func LoadScenarios(paths ...string) ([]*Scenario, error) {
+	var files []string
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		entries, err := os.ReadDir(p)
+		if err != nil {
+			return nil, err
+		}
+		var names []string
+		for _, e := range entries {
+			ext := filepath.Ext(e.Name())
+			if !e.IsDir() && (ext == ".yaml" || ext == ".yml") {
+				names = append(names, e.Name())
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			files = append(files, filepath.Join(p, name))
+		}
+	}
+
+	scenarios := make([]*Scenario, 0, len(files))
+	for _, f := range files {
+		sc, err := LoadScenario(f)
+		if err != nil {
+			return nil, err
+		}
+		scenarios = append(scenarios, sc)
+	}
+	return scenarios, nil
+}
+
+func (sc *Scenario) validate() error {
+	if strings.TrimSpace(sc.Input) == "" {
+		return fmt.Errorf("input is required")
+	}
+	switch sc.Session {
+	case "", "task":
+		if len(sc.Tasks) == 0 {
+			return fmt.Errorf("task session requires at least one task")
+		}
+	case "global":
+	default:
+		return fmt.Errorf("unknown session type %q", sc.Session)
+	}
+	for _, a := range sc.Expect.Assertions {
+		if _, err := parseAssertion(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package history
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// GetSummary 获取会话的滚动摘要，还没有摘要时返回 nil
+func (r *Repository) GetSummary(ctx context.Context, sessionID uint64) (*Summary, error) {
+	var summaries []Summary
+	err := r.db.WithContext(ctx).
+		Where("session_id = ?", sessionID).
+		Limit(1).
+		Find(&summaries).Error
+	if err != nil || len(summaries) == 0 {
+		return nil, err
+	}
+	return &summaries[0], nil
+}
+
+// SaveSummary 新建或覆盖会话的滚动摘要
+func (r *Repository) SaveSummary(ctx context.Context, s *Summary) error {
+	return r.db.WithContext(ctx).Save(s).Error
+}
+
+// DeleteSummary 删除会话的滚动摘要（清空消息时一并删除）
+func (r *Repository) DeleteSummary(ctx context.Context, sessionID uint64) error {
+	return r.db.WithContext(ctx).Where("session_id = ?", sessionID).Delete(&Summary{}).Error
+}
+
+// ListMessagesBetween 列出会话中 ID 在 (afterID, beforeID) 之间的最早 limit 条消息，按时间顺序
+func (r *Repository) ListMessagesBetween(ctx context.Context, sessionID, afterID, beforeID uint64, limit int) ([]Message, error) {
+	var messages []Message
+	err := r.db.WithContext(ctx).
+		Where("session_id = ? AND id > ? AND id < ?", sessionID, afterID, beforeID).
+		Order("id ASC").
+		Limit(limit).
+		Find(&messages).Error
+	return messages, err
+}
+
+// ListMessagesAfter 列出会话中 ID 大于 afterID 的最近 limit 条消息，按时间顺序
+func (r *Repository) ListMessagesAfter(ctx context.Context, sessionID, afterID uint64, limit int) ([]Message, error) {
+	var messages []Message
+	err := r.db.WithContext(ctx).
+		Where("session_id = ? AND id > ?", sessionID, afterID).
+		Order("id DESC").
+		Limit(limit).
+		Find(&messages).Error
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages, nil
+}
@@ -0,0 +1,196 @@
+// Package history 按 token 预算组装提供给模型的对话历史：最近的消息原样保留，
+// 更早的消息合并进每个会话一条的滚动摘要（增量更新），长对话不会撑爆上下文窗口。
+package history
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"assistant-qisumi/internal/llm"
+	"assistant-qisumi/internal/prompts"
+
+	"gorm.io/gorm"
+)
+
+// Budget 历史消息的 token 预算（按 llm.EstimateTokens 估算）
+type Budget struct {
+	RecentTokens int // 原样提供给模型的最近消息最多占用的 token 数，超过时触发摘要
+	KeepTokens   int // 摘要后原样保留的最近消息的 token 数，小于 RecentTokens，避免每轮都重新摘要
+	MaxMessages  int // 每次最多读取的未摘要消息条数
+}
+
+// DefaultBudget 默认预算，最近的若干轮对话约 3000 token
+var DefaultBudget = BudgetFor(3000)
+
+// BudgetFor 按原样保留的最近消息 token 数生成预算，摘要后保留其中约 40%
+func BudgetFor(recentTokens int) Budget {
+	return Budget{RecentTokens: recentTokens, KeepTokens: recentTokens * 2 / 5, MaxMessages: 200}
+}
+
+// Context 一轮对话可用的历史：较早消息的摘要（可能为空）和最近的原文消息
+type Context struct {
+	Summary  *Summary
+	Messages []Message
+}
+
+// AgentMessages 返回提供给 agent 的历史消息：有摘要时在最前面加一条 system 消息
+func (c *Context) AgentMessages() []Message {
+	if c.Summary == nil || strings.TrimSpace(c.Summary.Content) == "" {
+		return c.Messages
+	}
+	msgs := make([]Message, 0, len(c.Messages)+1)
+	msgs = append(msgs, Message{
+		SessionID: c.Summary.SessionID,
+		Role:      "system",
+		Content:   fmt.Sprintf("本会话较早的 %d 条消息的摘要（原文已不再提供）：\n%s", c.Summary.MessageCount, c.Summary.Content),
+	})
+	return append(msgs, c.Messages...)
+}
+
+type Service struct {
+	repo      *Repository
+	llmClient llm.Client
+	budget    Budget
+}
+
+func NewService(db *gorm.DB, llmClient llm.Client) *Service {
+	return &Service{repo: NewRepository(db), llmClient: llmClient, budget: DefaultBudget}
+}
+
+// WithBudget 替换 token 预算，返回自身便于链式调用
+func (s *Service) WithBudget(b Budget) *Service {
+	s.budget = b
+	return s
+}
+
+// messageTokens 估算一条消息占用的 token 数
+func messageTokens(m Message) int {
+	return llm.EstimateMessageTokens(m.Role, m.Content)
+}
+
+// recent 从最新的消息往前取，直到总 token 数超过 budget；至少保留最后一条
+func recent(msgs []Message, budget int) []Message {
+	total := 0
+	for i := len(msgs) - 1; i >= 0; i-- {
+		total += messageTokens(msgs[i])
+		if total > budget && i < len(msgs)-1 {
+			return msgs[i+1:]
+		}
+	}
+	return msgs
+}
+
+// Load 读取会话的摘要和摘要之后的消息，原文消息按 RecentTokens 截取最近的部分
+func (s *Service) Load(ctx context.Context, sessionID uint64) (*Context, error) {
+	summary, msgs, err := s.unsummarized(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return &Context{Summary: summary, Messages: recent(msgs, s.budget.RecentTokens)}, nil
+}
+
+func (s *Service) unsummarized(ctx context.Context, sessionID uint64) (*Summary, []Message, error) {
+	summary, err := s.repo.GetSummary(ctx, sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	var after uint64
+	if summary != nil {
+		after = summary.UpToMessageID
+	}
+	msgs, err := s.repo.ListMessagesAfter(ctx, sessionID, after, s.budget.MaxMessages)
+	if err != nil {
+		return nil, nil, err
+	}
+	return summary, msgs, nil
+}
+
+// Compact 未摘要的消息超过 RecentTokens（或超过一次能读取的 MaxMessages 条）时，
+// 把 KeepTokens 之外的较早消息从最早的开始、每批至多 MaxMessages 条依次合并进摘要。
+// 返回是否更新了摘要；消息未超出预算时不调用模型
+func (s *Service) Compact(ctx context.Context, sessionID uint64, cfg llm.Config) (bool, error) {
+	if s.llmClient == nil {
+		return false, nil
+	}
+	summary, msgs, err := s.unsummarized(ctx, sessionID)
+	if err != nil {
+		return false, err
+	}
+	total := 0
+	for _, m := range msgs {
+		total += messageTokens(m)
+	}
+	if total <= s.budget.RecentTokens && len(msgs) < s.budget.MaxMessages {
+		return false, nil
+	}
+	// 原样保留的最近消息从 keepFrom 开始，之前的全部需要摘要（可能比 msgs 更早）
+	keepFrom := recent(msgs, s.budget.KeepTokens)[0].ID
+
+	var after uint64
+	if summary != nil {
+		after = summary.UpToMessageID
+	}
+	updated := false
+	for {
+		older, err := s.repo.ListMessagesBetween(ctx, sessionID, after, keepFrom, s.budget.MaxMessages)
+		if err != nil {
+			return updated, err
+		}
+		if len(older) == 0 {
+			return updated, nil
+		}
+		if summary, err = s.summarize(ctx, sessionID, cfg, summary, older); err != nil {
+			return updated, err
+		}
+		updated = true
+		after = summary.UpToMessageID
+	}
+}
+
+// summarize 把一批较早的消息合并进摘要并保存，返回更新后的摘要
+func (s *Service) summarize(ctx context.Context, sessionID uint64, cfg llm.Config, summary *Summary, older []Message) (*Summary, error) {
+	var b strings.Builder
+	b.WriteString("已有的摘要：\n")
+	if summary == nil {
+		b.WriteString("（无）\n")
+	} else {
+		b.WriteString(summary.Content + "\n")
+	}
+	b.WriteString("\n需要合并进摘要的较早消息：\n")
+	for _, m := range older {
+		fmt.Fprintf(&b, "[%s] %s\n", roleLabels[m.Role], m.Content)
+	}
+
+	resp, err := s.llmClient.Chat(ctx, cfg, llm.ChatRequest{
+		Model: cfg.Model,
+		Messages: []llm.Message{
+			{Role: "system", Content: prompts.HistorySummarySystemPrompt},
+			{Role: "user", Content: b.String()},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Choices) == 0 || strings.TrimSpace(resp.Choices[0].Message.Content) == "" {
+		return nil, fmt.Errorf("llm returned empty summary")
+	}
+
+	next := &Summary{SessionID: sessionID}
+	if summary != nil {
+		next = summary
+	}
+	next.Content = strings.TrimSpace(resp.Choices[0].Message.Content)
+	next.UpToMessageID = older[len(older)-1].ID
+	next.MessageCount += len(older)
+	if err := s.repo.SaveSummary(ctx, next); err != nil {
+		return nil, err
+	}
+	return next, nil
+}
+
+var roleLabels = map[string]string{
+	"user":      "用户",
+	"assistant": "助手",
+	"system":    "系统通知",
+}
@@ -0,0 +1,118 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+
+	"assistant-qisumi/internal/archive"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxArchiveSize 导入文件大小上限
+const maxArchiveSize = 32 << 20
+
+// ArchiveHandler 处理账号数据导出/导入请求
+type ArchiveHandler struct {
+	archiveSvc *archive.Service
+}
+
+// NewArchiveHandler 创建新的导出/导入处理器
+func NewArchiveHandler(archiveSvc *archive.Service) *ArchiveHandler {
+	return &ArchiveHandler{archiveSvc: archiveSvc}
+}
+
+// RegisterRoutes 注册导出/导入路由
+func (h *ArchiveHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/export", h.exportData)
+	rg.POST("/import", h.importData)
+}
+
+// exportData 导出当前用户的全部数据，format=json（默认）或 zip
+func (h *ArchiveHandler) exportData(c *gin.Context) {
+	userID := GetUserID(c)
+
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "zip" {
+		R.BadRequest(c, "format must be json or zip")
+		return
+	}
+
+	a, err := h.archiveSvc.Export(c.Request.Context(), userID)
+	if err != nil {
+		R.InternalError(c, err.Error())
+		return
+	}
+
+	filename := fmt.Sprintf("assistant-export-%s", a.ExportedAt.Format("20060102-150405"))
+	if format == "zip" {
+		var buf bytes.Buffer
+		if err := archive.WriteZip(&buf, a); err != nil {
+			R.InternalError(c, err.Error())
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, filename))
+		c.Data(http.StatusOK, "application/zip", buf.Bytes())
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.json"`, filename))
+	c.IndentedJSON(http.StatusOK, a)
+}
+
+// importData 导入导出文件，mode=merge（默认，追加到现有数据）或 replace（先清空现有任务和会话）
+// 请求体可以直接是 JSON / zip 内容，也可以是 multipart 上传（字段 file）
+func (h *ArchiveHandler) importData(c *gin.Context) {
+	userID := GetUserID(c)
+
+	mode := c.DefaultQuery("mode", "merge")
+	if mode != "merge" && mode != "replace" {
+		R.BadRequest(c, "mode must be merge or replace")
+		return
+	}
+
+	var (
+		data []byte
+		err  error
+	)
+	if c.ContentType() == "multipart/form-data" {
+		file, ferr := c.FormFile("file")
+		if ferr != nil {
+			R.BadRequest(c, "missing file")
+			return
+		}
+		f, ferr := file.Open()
+		if ferr != nil {
+			R.BadRequest(c, ferr.Error())
+			return
+		}
+		defer f.Close()
+		data, err = io.ReadAll(io.LimitReader(f, maxArchiveSize+1))
+	} else {
+		data, err = io.ReadAll(io.LimitReader(c.Request.Body, maxArchiveSize+1))
+	}
+	if err != nil {
+		R.BadRequest(c, err.Error())
+		return
+	}
+	if len(data) > maxArchiveSize {
+		R.Error(c, http.StatusRequestEntityTooLarge, "archive is too large")
+		return
+	}
+
+	a, err := archive.Read(data)
+	if err != nil {
+		R.BadRequest(c, err.Error())
+		return
+	}
+
+	result, err := h.archiveSvc.Import(c.Request.Context(), userID, a, mode == "replace")
+	if err != nil {
+		R.InternalError(c, err.Error())
+		return
+	}
+
+	R.Success(c, result)
+}
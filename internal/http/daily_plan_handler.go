@@ -0,0 +1,206 @@
+package http
+
+import (
+	"context"
+	"errors"
+
+	"assistant-qisumi/internal/agent"
+	"assistant-qisumi/internal/auth"
+	"assistant-qisumi/internal/dailyplan"
+	"assistant-qisumi/internal/session"
+	"assistant-qisumi/internal/task"
+
+	"github.com/gin-gonic/gin"
+)
+
+// planMyDayMessage 「规划今天」时以用户身份发给全局助手的消息
+const planMyDayMessage = "帮我规划一下今天：按截止时间、优先级和依赖挑出今天要做的任务或步骤，排好顺序拟一个计划给我确认。"
+
+// DailyPlanHandler 处理每日计划和今日视图相关请求
+type DailyPlanHandler struct {
+	planSvc       *dailyplan.Service
+	agentSvc      *agent.Service
+	sessionRepo   *session.Repository
+	llmSettingSvc *auth.LLMSettingService
+	llmLimit      gin.HandlerFunc
+}
+
+// NewDailyPlanHandler 创建新的每日计划处理器
+func NewDailyPlanHandler(planSvc *dailyplan.Service, agentSvc *agent.Service, sessionRepo *session.Repository, llmSettingSvc *auth.LLMSettingService) *DailyPlanHandler {
+	return &DailyPlanHandler{
+		planSvc:       planSvc,
+		agentSvc:      agentSvc,
+		sessionRepo:   sessionRepo,
+		llmSettingSvc: llmSettingSvc,
+	}
+}
+
+// WithLLMLimit 为会调用 LLM 的路由加上限额中间件（见 QuotaMiddleware），需在 RegisterRoutes 之前调用
+func (h *DailyPlanHandler) WithLLMLimit(mw gin.HandlerFunc) *DailyPlanHandler {
+	h.llmLimit = mw
+	return h
+}
+
+// RegisterRoutes 注册每日计划相关路由，:date 为 YYYY-MM-DD 或 today
+func (h *DailyPlanHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/today", h.getToday)
+	rg.POST("/today/plan", withMiddleware(h.llmLimit, h.planToday)...)
+	rg.GET("/daily-plans/:date", h.getPlan)
+	rg.POST("/daily-plans/:date/items", h.markItems)
+	rg.POST("/daily-plans/:date/unmark", h.unmarkItems)
+	rg.PUT("/daily-plans/:date/order", h.reorderItems)
+	rg.PUT("/daily-plans/:date/note", h.setNote)
+	rg.POST("/daily-plans/:date/accept", h.acceptPlan)
+}
+
+func respondDailyPlanError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, dailyplan.ErrInvalidDate):
+		R.BadRequest(c, err.Error())
+	case errors.Is(err, dailyplan.ErrNoPlan), errors.Is(err, task.ErrNotOwned):
+		R.NotFound(c, err.Error())
+	default:
+		R.InternalError(c, err.Error())
+	}
+}
+
+// resolveDate 解析路径中的日期，失败时已写入响应
+func (h *DailyPlanHandler) resolveDate(c *gin.Context) (string, bool) {
+	date, err := h.planSvc.ResolveDate(c.Request.Context(), c.Param("date"))
+	if err != nil {
+		respondDailyPlanError(c, err)
+		return "", false
+	}
+	return date, true
+}
+
+// getToday 今天的计划，按计划顺序带上任务和步骤
+func (h *DailyPlanHandler) getToday(c *gin.Context) {
+	view, err := h.planSvc.View(c.Request.Context(), GetUserID(c))
+	if err != nil {
+		respondDailyPlanError(c, err)
+		return
+	}
+	R.Success(c, view)
+}
+
+// planToday 让全局助手拟定今天的计划（状态为 proposed），用户确认后通过 accept 生效
+func (h *DailyPlanHandler) planToday(c *gin.Context) {
+	userID := GetUserID(c)
+
+	cfg, err := GetLLMConfig(c, h.llmSettingSvc, userID)
+	if err != nil {
+		return
+	}
+
+	sess, err := h.sessionRepo.GetGlobalSessionOrCreate(c, userID)
+	if err != nil {
+		R.InternalError(c, err.Error())
+		return
+	}
+
+	resp, err := h.agentSvc.HandleUserMessage(c, userID, sess.ID, planMyDayMessage, *cfg)
+	if err != nil {
+		RespondLLMError(c, "HandleUserMessage failed: ", err)
+		return
+	}
+
+	view, err := h.planSvc.View(c.Request.Context(), userID)
+	if err != nil {
+		respondDailyPlanError(c, err)
+		return
+	}
+
+	R.Success(c, gin.H{
+		"sessionId":        sess.ID,
+		"assistantMessage": resp.AssistantMessage,
+		"today":            view,
+	})
+}
+
+func (h *DailyPlanHandler) getPlan(c *gin.Context) {
+	date, ok := h.resolveDate(c)
+	if !ok {
+		return
+	}
+	plan, err := h.planSvc.Get(c.Request.Context(), GetUserID(c), date)
+	if err != nil {
+		respondDailyPlanError(c, err)
+		return
+	}
+	R.Success(c, gin.H{"date": date, "plan": plan})
+}
+
+// PlanItemsReq 计划项列表，stepId 为空表示整个任务；可以直接使用返回的计划项
+type PlanItemsReq struct {
+	Items []dailyplan.ItemRef `json:"items" binding:"required"`
+}
+
+// markItems 把任务或步骤加入计划末尾
+func (h *DailyPlanHandler) markItems(c *gin.Context) {
+	h.updateItems(c, h.planSvc.Mark)
+}
+
+// unmarkItems 把任务或步骤移出计划，移出任务时也会移出它的步骤
+func (h *DailyPlanHandler) unmarkItems(c *gin.Context) {
+	h.updateItems(c, h.planSvc.Unmark)
+}
+
+// reorderItems 按给出的顺序排列计划项，未列出的保持原顺序排在后面
+func (h *DailyPlanHandler) reorderItems(c *gin.Context) {
+	h.updateItems(c, h.planSvc.Reorder)
+}
+
+func (h *DailyPlanHandler) updateItems(c *gin.Context, update func(ctx context.Context, userID uint64, date string, refs []dailyplan.ItemRef) (*dailyplan.DailyPlan, error)) {
+	date, ok := h.resolveDate(c)
+	if !ok {
+		return
+	}
+	var req PlanItemsReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		R.BadRequest(c, err.Error())
+		return
+	}
+	plan, err := update(c.Request.Context(), GetUserID(c), date, req.Items)
+	if err != nil {
+		respondDailyPlanError(c, err)
+		return
+	}
+	R.Success(c, gin.H{"date": date, "plan": plan})
+}
+
+type SetPlanNoteReq struct {
+	Note string `json:"note"`
+}
+
+func (h *DailyPlanHandler) setNote(c *gin.Context) {
+	date, ok := h.resolveDate(c)
+	if !ok {
+		return
+	}
+	var req SetPlanNoteReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		R.BadRequest(c, err.Error())
+		return
+	}
+	plan, err := h.planSvc.SetNote(c.Request.Context(), GetUserID(c), date, req.Note)
+	if err != nil {
+		respondDailyPlanError(c, err)
+		return
+	}
+	R.Success(c, gin.H{"date": date, "plan": plan})
+}
+
+// acceptPlan 确认助手拟定的计划
+func (h *DailyPlanHandler) acceptPlan(c *gin.Context) {
+	date, ok := h.resolveDate(c)
+	if !ok {
+		return
+	}
+	plan, err := h.planSvc.Accept(c.Request.Context(), GetUserID(c), date)
+	if err != nil {
+		respondDailyPlanError(c, err)
+		return
+	}
+	R.Success(c, gin.H{"date": date, "plan": plan})
+}
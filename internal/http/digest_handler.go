@@ -0,0 +1,129 @@
+package http
+
+import (
+	"errors"
+	"strconv"
+
+	"assistant-qisumi/internal/auth"
+	"assistant-qisumi/internal/digest"
+	"assistant-qisumi/internal/llm"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DigestHandler 处理每日/每周回顾相关请求
+type DigestHandler struct {
+	digestSvc     *digest.Service
+	llmSettingSvc *auth.LLMSettingService
+	llmLimit      gin.HandlerFunc
+}
+
+// NewDigestHandler 创建新的回顾处理器
+func NewDigestHandler(digestSvc *digest.Service, llmSettingSvc *auth.LLMSettingService) *DigestHandler {
+	return &DigestHandler{digestSvc: digestSvc, llmSettingSvc: llmSettingSvc}
+}
+
+// WithLLMLimit 为会调用 LLM 的路由加上限额中间件（见 QuotaMiddleware），需在 RegisterRoutes 之前调用
+func (h *DigestHandler) WithLLMLimit(mw gin.HandlerFunc) *DigestHandler {
+	h.llmLimit = mw
+	return h
+}
+
+// RegisterRoutes 注册回顾相关路由
+func (h *DigestHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/digests", h.listDigests)
+	rg.GET("/digests/:id", h.getDigest)
+	// 生成时可以选择润色，润色会调用 LLM
+	rg.POST("/digests", withMiddleware(h.llmLimit, h.generateDigest)...)
+}
+
+// listDigests 按周期从新到旧列出回顾
+// kind=daily | weekly（为空时全部）；limit 默认 20，最多 100
+func (h *DigestHandler) listDigests(c *gin.Context) {
+	userID := GetUserID(c)
+	var kind digest.Kind
+	if c.Query("kind") != "" {
+		k, err := digest.ParseKind(c.Query("kind"))
+		if err != nil {
+			R.BadRequest(c, err.Error())
+			return
+		}
+		kind = k
+	}
+	limit := 20
+	if s := c.Query("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			R.BadRequest(c, "limit must be a positive integer")
+			return
+		}
+		limit = min(n, 100)
+	}
+
+	digests, err := h.digestSvc.List(c.Request.Context(), userID, kind, limit)
+	if err != nil {
+		R.InternalError(c, err.Error())
+		return
+	}
+	R.Success(c, gin.H{"digests": digests})
+}
+
+func (h *DigestHandler) getDigest(c *gin.Context) {
+	userID := GetUserID(c)
+	id, err := ParseUint64Param(c, "id")
+	if err != nil {
+		return
+	}
+	d, err := h.digestSvc.Get(c.Request.Context(), userID, id)
+	if err != nil {
+		R.InternalError(c, err.Error())
+		return
+	}
+	if d == nil {
+		R.NotFound(c, "digest not found")
+		return
+	}
+	R.Success(c, d)
+}
+
+// GenerateDigestReq 生成回顾的参数，date 为周期内任意一天（YYYY-MM-DD），为空表示今天
+type GenerateDigestReq struct {
+	Kind   string `json:"kind"`
+	Date   string `json:"date"`
+	Polish bool   `json:"polish"`
+}
+
+// generateDigest 生成（或重新生成）回顾
+func (h *DigestHandler) generateDigest(c *gin.Context) {
+	userID := GetUserID(c)
+	var req GenerateDigestReq
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			R.BadRequest(c, err.Error())
+			return
+		}
+	}
+	kind, err := digest.ParseKind(req.Kind)
+	if err != nil {
+		R.BadRequest(c, err.Error())
+		return
+	}
+
+	var cfg *llm.Config
+	if req.Polish {
+		if cfg, err = GetAgentLLMConfig(c, h.llmSettingSvc, userID, "summarizer"); err != nil {
+			return
+		}
+	}
+
+	d, err := h.digestSvc.Generate(c.Request.Context(), userID, kind, req.Date, cfg)
+	if err != nil {
+		if errors.Is(err, digest.ErrInvalidDate) {
+			R.BadRequest(c, err.Error())
+			return
+		}
+		R.InternalError(c, err.Error())
+		return
+	}
+	R.Success(c, d)
+}
@@ -2,10 +2,15 @@ package http
 
 import (
 	"errors"
+	"math"
+	"net/http"
 	"strconv"
+	"time"
 
 	"assistant-qisumi/internal/auth"
 	"assistant-qisumi/internal/domain"
+	"assistant-qisumi/internal/llm"
+	"assistant-qisumi/internal/quota"
 
 	"github.com/gin-gonic/gin"
 )
@@ -24,14 +29,59 @@ func ParseUint64Param(c *gin.Context, paramName string) (uint64, error) {
 // GetLLMConfig 获取LLM配置
 // 现在 auth.LLMConfig 和 domain.LLMConfig 是同一类型，无需类型转换
 func GetLLMConfig(c *gin.Context, svc *auth.LLMSettingService, userID uint64) (*domain.LLMConfig, error) {
-	llmConfig, err := svc.GetLLMConfig(c.Request.Context(), userID)
+	return GetAgentLLMConfig(c, svc, userID, domain.LLMAgentDefault)
+}
+
+// GetAgentLLMConfig 获取指定 agent 使用的LLM配置（考虑用户的命名配置指派）
+func GetAgentLLMConfig(c *gin.Context, svc *auth.LLMSettingService, userID uint64, agent string) (*domain.LLMConfig, error) {
+	llmConfig, err := svc.GetAgentLLMConfig(c.Request.Context(), userID, agent)
 	if err != nil {
 		R.InternalError(c, "failed to get LLM config")
 		return nil, err
 	}
-	if llmConfig == nil || llmConfig.APIKey == "" {
+	// 本地 Ollama 不需要 API Key
+	if llmConfig == nil || (llmConfig.APIKey == "" && llmConfig.Protocol != domain.LLMProtocolOllama) {
 		R.BadRequest(c, "LLM API key not set. Please configure it in settings or contact administrator.")
 		return nil, errors.New("LLM API key not set")
 	}
 	return llmConfig, nil
 }
+
+// RespondLLMError 输出调用 LLM 失败的错误：超出限额返回 429，提供商不可用时返回 503，便于客户端稍后重试
+func RespondLLMError(c *gin.Context, message string, err error) {
+	var exceeded *quota.ExceededError
+	if errors.As(err, &exceeded) {
+		RespondQuotaExceeded(c, exceeded)
+		return
+	}
+	if errors.Is(err, llm.ErrUnavailable) {
+		R.Error(c, http.StatusServiceUnavailable, message+err.Error())
+		return
+	}
+	R.InternalError(c, message+err.Error())
+}
+
+// RespondQuotaExceeded 输出 429，带上 Retry-After 和恢复时间
+func RespondQuotaExceeded(c *gin.Context, e *quota.ExceededError) {
+	retryAfter := int64(math.Ceil(time.Until(e.ResetAt).Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	c.Header("Retry-After", strconv.FormatInt(retryAfter, 10))
+	c.Header("X-RateLimit-Reset", strconv.FormatInt(e.ResetAt.Unix(), 10))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+		"error":               e.Error(),
+		"limit":               e.Limit,
+		"max":                 e.Max,
+		"reset_at":            e.ResetAt,
+		"retry_after_seconds": retryAfter,
+	})
+}
+
+// withMiddleware 在 handler 前加上可选的中间件
+func withMiddleware(mw gin.HandlerFunc, handler gin.HandlerFunc) []gin.HandlerFunc {
+	if mw == nil {
+		return []gin.HandlerFunc{handler}
+	}
+	return []gin.HandlerFunc{mw, handler}
+}
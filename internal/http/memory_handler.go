@@ -0,0 +1,104 @@
+package http
+
+import (
+	"errors"
+
+	"assistant-qisumi/internal/memory"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MemoryHandler 处理长期记忆相关请求：用户可以查看、添加、修改和删除助手记住的内容
+type MemoryHandler struct {
+	memorySvc *memory.Service
+}
+
+// NewMemoryHandler 创建新的长期记忆处理器
+func NewMemoryHandler(memorySvc *memory.Service) *MemoryHandler {
+	return &MemoryHandler{memorySvc: memorySvc}
+}
+
+// RegisterRoutes 注册长期记忆相关路由
+func (h *MemoryHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/memories", h.listMemories)
+	rg.POST("/memories", h.createMemory)
+	rg.PUT("/memories/:id", h.updateMemory)
+	rg.DELETE("/memories/:id", h.deleteMemory)
+	// 清空全部记忆
+	rg.DELETE("/memories", h.deleteAllMemories)
+}
+
+func respondMemoryError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, memory.ErrInvalidMemory):
+		R.BadRequest(c, err.Error())
+	case errors.Is(err, memory.ErrNotFound):
+		R.NotFound(c, err.Error())
+	default:
+		R.InternalError(c, err.Error())
+	}
+}
+
+// listMemories 列出全部记忆，最近更新的在前
+func (h *MemoryHandler) listMemories(c *gin.Context) {
+	memories, err := h.memorySvc.List(c.Request.Context(), GetUserID(c))
+	if err != nil {
+		R.InternalError(c, err.Error())
+		return
+	}
+	R.Success(c, gin.H{"memories": memories})
+}
+
+// createMemory 手动添加一条记忆
+func (h *MemoryHandler) createMemory(c *gin.Context) {
+	var req memory.Input
+	if err := c.ShouldBindJSON(&req); err != nil {
+		R.BadRequest(c, err.Error())
+		return
+	}
+	m, err := h.memorySvc.Create(c.Request.Context(), GetUserID(c), req)
+	if err != nil {
+		respondMemoryError(c, err)
+		return
+	}
+	R.Success(c, m)
+}
+
+// updateMemory 修改一条记忆的内容和分类
+func (h *MemoryHandler) updateMemory(c *gin.Context) {
+	id, err := ParseUint64Param(c, "id")
+	if err != nil {
+		return
+	}
+	var req memory.Input
+	if err := c.ShouldBindJSON(&req); err != nil {
+		R.BadRequest(c, err.Error())
+		return
+	}
+	m, err := h.memorySvc.Update(c.Request.Context(), GetUserID(c), id, req)
+	if err != nil {
+		respondMemoryError(c, err)
+		return
+	}
+	R.Success(c, m)
+}
+
+func (h *MemoryHandler) deleteMemory(c *gin.Context) {
+	id, err := ParseUint64Param(c, "id")
+	if err != nil {
+		return
+	}
+	if err := h.memorySvc.Delete(c.Request.Context(), GetUserID(c), id); err != nil {
+		respondMemoryError(c, err)
+		return
+	}
+	R.SuccessWithMessage(c, "memory deleted", nil)
+}
+
+func (h *MemoryHandler) deleteAllMemories(c *gin.Context) {
+	if err := h.memorySvc.DeleteAll(c.Request.Context(), GetUserID(c)); err != nil {
+		R.InternalError(c, err.Error())
+		return
+	}
+	R.SuccessWithMessage(c, "memories deleted", nil)
+}
@@ -1,11 +1,18 @@
 package http
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"assistant-qisumi/internal/auth"
+	"assistant-qisumi/internal/domain"
+	"assistant-qisumi/internal/logger"
+	"assistant-qisumi/internal/profile"
+	"assistant-qisumi/internal/quota"
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 )
 
 func AuthMiddleware(jwtMgr *auth.JWTManager) gin.HandlerFunc {
@@ -30,6 +37,80 @@ func AuthMiddleware(jwtMgr *auth.JWTManager) gin.HandlerFunc {
 	}
 }
 
+// ProfileMiddleware 把当前用户的资料（时区、工作时间）放入请求的 context，
+// 之后解析不带时区的时间、渲染 now 和排期都按用户时区进行。必须在 AuthMiddleware 之后使用
+func ProfileMiddleware(profileSvc *profile.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := GetUserID(c)
+		p, err := profileSvc.Get(c.Request.Context(), userID)
+		if err != nil {
+			logger.Logger.Warn("加载用户资料失败，使用默认时区",
+				zap.Uint64("user_id", userID),
+				zap.String("error", err.Error()),
+			)
+			p = profile.Default(userID)
+		}
+		c.Request = c.Request.WithContext(profile.NewContext(c.Request.Context(), p))
+		c.Next()
+	}
+}
+
+// AdminMiddleware 只允许管理员访问。必须在 AuthMiddleware 之后使用
+func AdminMiddleware(authSvc *auth.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ok, err := authSvc.IsAdmin(c.Request.Context(), GetUserID(c))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin only"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// QuotaMiddleware 检查每分钟请求数和当天的 token 用量，超出时返回 429。
+// 使用服务器默认 API Key 和使用自己 API Key 的用户适用不同的限额。必须在 AuthMiddleware 之后使用
+func QuotaMiddleware(limiter *quota.Limiter, llmSettingSvc *auth.LLMSettingService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := GetUserID(c)
+		ctx := c.Request.Context()
+
+		// 无法确定时按默认 Key 处理（限额更严格）
+		defaultKey := true
+		if cfg, err := llmSettingSvc.GetAgentLLMConfig(ctx, userID, domain.LLMAgentDefault); err == nil && cfg != nil {
+			defaultKey = cfg.IsDefault
+		}
+
+		status, err := limiter.AllowRequest(userID, defaultKey)
+		if err != nil {
+			respondQuotaError(c, err)
+			return
+		}
+		if status != nil {
+			c.Header("X-RateLimit-Limit", strconv.FormatInt(status.Max, 10))
+			c.Header("X-RateLimit-Remaining", strconv.FormatInt(status.Remaining, 10))
+			c.Header("X-RateLimit-Reset", strconv.FormatInt(status.ResetAt.Unix(), 10))
+		}
+		if _, err := limiter.CheckTokens(ctx, userID, defaultKey); err != nil {
+			respondQuotaError(c, err)
+			return
+		}
+		c.Next()
+	}
+}
+
+func respondQuotaError(c *gin.Context, err error) {
+	var exceeded *quota.ExceededError
+	if errors.As(err, &exceeded) {
+		RespondQuotaExceeded(c, exceeded)
+		return
+	}
+	c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
 func GetUserID(c *gin.Context) uint64 {
 	if v, ok := c.Get("userID"); ok {
 		if id, ok := v.(uint64); ok {
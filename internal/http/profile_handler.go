@@ -0,0 +1,73 @@
+package http
+
+import (
+	"errors"
+
+	"assistant-qisumi/internal/profile"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProfileHandler 处理用户时区和工作时间设置
+type ProfileHandler struct {
+	profileSvc *profile.Service
+}
+
+// NewProfileHandler 创建新的用户资料处理器
+func NewProfileHandler(profileSvc *profile.Service) *ProfileHandler {
+	return &ProfileHandler{profileSvc: profileSvc}
+}
+
+// RegisterRoutes 注册用户资料相关路由
+func (h *ProfileHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/settings/profile", h.getProfile)
+	rg.PUT("/settings/profile", h.updateProfile)
+}
+
+// getProfile 获取当前用户的时区和工作时间，未设置时返回默认值
+func (h *ProfileHandler) getProfile(c *gin.Context) {
+	userID := GetUserID(c)
+
+	p, err := h.profileSvc.Get(c.Request.Context(), userID)
+	if err != nil {
+		R.InternalError(c, err.Error())
+		return
+	}
+	R.Success(c, p)
+}
+
+type UpdateProfileReq struct {
+	Timezone  *string  `json:"timezone"`   // IANA 时区名，空字符串表示使用服务器时区
+	WorkStart *string  `json:"work_start"` // HH:MM
+	WorkEnd   *string  `json:"work_end"`   // HH:MM
+	WorkDays  []int    `json:"work_days"`  // 1 = 周一 ... 7 = 周日
+	DaysOff   []string `json:"days_off"`   // YYYY-MM-DD
+}
+
+// updateProfile 更新当前用户的时区和工作时间，未传的字段保持不变
+func (h *ProfileHandler) updateProfile(c *gin.Context) {
+	userID := GetUserID(c)
+
+	var req UpdateProfileReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		R.BadRequest(c, err.Error())
+		return
+	}
+
+	p, err := h.profileSvc.Update(c.Request.Context(), userID, profile.UpdateInput{
+		Timezone:  req.Timezone,
+		WorkStart: req.WorkStart,
+		WorkEnd:   req.WorkEnd,
+		WorkDays:  req.WorkDays,
+		DaysOff:   req.DaysOff,
+	})
+	if err != nil {
+		if errors.Is(err, profile.ErrInvalidProfile) {
+			R.BadRequest(c, err.Error())
+			return
+		}
+		R.InternalError(c, err.Error())
+		return
+	}
+	R.Success(c, p)
+}
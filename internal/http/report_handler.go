@@ -0,0 +1,139 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"assistant-qisumi/internal/report"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ReportHandler 处理任务报告导出与报告模板设置
+type ReportHandler struct {
+	reportSvc *report.Service
+}
+
+// NewReportHandler 创建新的报告处理器
+func NewReportHandler(reportSvc *report.Service) *ReportHandler {
+	return &ReportHandler{reportSvc: reportSvc}
+}
+
+// RegisterRoutes 注册报告相关路由
+func (h *ReportHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/tasks/:id/export", h.exportTask)
+	rg.GET("/settings/report-templates/:format", h.getTemplate)
+	rg.PUT("/settings/report-templates/:format", h.saveTemplate)
+	rg.DELETE("/settings/report-templates/:format", h.resetTemplate)
+}
+
+// exportTask 导出单个任务报告
+// format=markdown（默认）| html | json；include_summary=true 时附带 summarizer 最新总结
+func (h *ReportHandler) exportTask(c *gin.Context) {
+	userID := GetUserID(c)
+	id, err := ParseUint64Param(c, "id")
+	if err != nil {
+		return
+	}
+
+	format, err := report.ParseFormat(c.Query("format"))
+	if err != nil {
+		R.BadRequest(c, err.Error())
+		return
+	}
+
+	data, err := h.reportSvc.Export(c.Request.Context(), userID, id, report.Options{
+		Format:         format,
+		IncludeSummary: c.Query("include_summary") == "true",
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			R.NotFound(c, "task not found")
+			return
+		}
+		R.InternalError(c, err.Error())
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="task-%d.%s"`, id, format.Extension()))
+	c.Data(http.StatusOK, format.ContentType(), data)
+}
+
+// parseTemplateFormat 解析模板格式参数，只有 markdown / html 支持模板
+func parseTemplateFormat(c *gin.Context) (report.Format, bool) {
+	format, err := report.ParseFormat(c.Param("format"))
+	if err != nil || !format.Templated() {
+		R.BadRequest(c, "format must be markdown or html")
+		return "", false
+	}
+	return format, true
+}
+
+// getTemplate 获取当前生效的报告模板（自定义模板或内置模板）
+func (h *ReportHandler) getTemplate(c *gin.Context) {
+	userID := GetUserID(c)
+	format, ok := parseTemplateFormat(c)
+	if !ok {
+		return
+	}
+
+	content, custom, err := h.reportSvc.GetTemplate(c.Request.Context(), userID, format)
+	if err != nil {
+		R.InternalError(c, err.Error())
+		return
+	}
+
+	R.Success(c, gin.H{
+		"format":  format,
+		"content": content,
+		"custom":  custom,
+	})
+}
+
+type SaveReportTemplateReq struct {
+	Content string `json:"content" binding:"required"`
+}
+
+// saveTemplate 保存自定义报告模板
+func (h *ReportHandler) saveTemplate(c *gin.Context) {
+	userID := GetUserID(c)
+	format, ok := parseTemplateFormat(c)
+	if !ok {
+		return
+	}
+
+	var req SaveReportTemplateReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		R.BadRequest(c, err.Error())
+		return
+	}
+
+	if err := h.reportSvc.SaveTemplate(c.Request.Context(), userID, format, req.Content); err != nil {
+		if errors.Is(err, report.ErrInvalidTemplate) {
+			R.BadRequest(c, err.Error())
+			return
+		}
+		R.InternalError(c, err.Error())
+		return
+	}
+
+	R.SuccessWithMessage(c, "report template saved", nil)
+}
+
+// resetTemplate 删除自定义报告模板，恢复内置模板
+func (h *ReportHandler) resetTemplate(c *gin.Context) {
+	userID := GetUserID(c)
+	format, ok := parseTemplateFormat(c)
+	if !ok {
+		return
+	}
+
+	if err := h.reportSvc.ResetTemplate(c.Request.Context(), userID, format); err != nil {
+		R.InternalError(c, err.Error())
+		return
+	}
+
+	R.SuccessWithMessage(c, "report template reset", nil)
+}
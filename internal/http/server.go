@@ -1,18 +1,33 @@
 package http
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"sync"
+	"time"
 
 	"assistant-qisumi/internal/agent"
+	"assistant-qisumi/internal/archive"
 	"assistant-qisumi/internal/auth"
 	"assistant-qisumi/internal/config"
+	"assistant-qisumi/internal/dailyplan"
 	"assistant-qisumi/internal/dependency"
+	"assistant-qisumi/internal/digest"
+	"assistant-qisumi/internal/history"
 	"assistant-qisumi/internal/llm"
+	"assistant-qisumi/internal/logger"
+	"assistant-qisumi/internal/memory"
+	"assistant-qisumi/internal/profile"
+	"assistant-qisumi/internal/quota"
+	"assistant-qisumi/internal/report"
 	"assistant-qisumi/internal/session"
 	"assistant-qisumi/internal/task"
+	"assistant-qisumi/internal/timetrack"
+	"assistant-qisumi/internal/usage"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
@@ -25,16 +40,57 @@ type Server struct {
 	cryptoCfg config.CryptoConfig
 	llmCfg    config.LLMConfig
 	llmClient llm.Client
+	usageSvc  *usage.Service
+	limiter   *quota.Limiter
+	digestJob *digest.Job
+	agentSvc  *agent.Service
+
+	// background 定时回顾等由 Server 启动的后台任务
+	background sync.WaitGroup
 }
 
+// shutdownTimeout 关闭服务器时等待进行中的请求完成的最长时间
+const shutdownTimeout = 30 * time.Second
+
 // NewServer 创建新的HTTP服务器
 func NewServer(cfg config.HTTPConfig, jwtCfg config.JWTConfig, cryptoCfg config.CryptoConfig, llmCfg config.LLMConfig, db *gorm.DB, llmClient llm.Client) *Server {
 	engine := gin.Default()
+	// 处理器把 *gin.Context 直接当作 context 传给下游，需要能读到请求 context 中的用户资料
+	engine.ContextWithFallback = true
 
 	if llmClient == nil {
-		llmClient = llm.NewHTTPClient()
+		// SDK 自身不再重试，由 ResilientClient 统一负责重试、熔断和备用提供商切换
+		var base llm.Client = llm.NewHTTPClient().WithMaxRetries(0)
+		if llmCfg.RecordDir != "" {
+			base = llm.NewRecordingClient(base, llmCfg.RecordDir)
+		}
+		llmClient = llm.NewResilientClient(base, llm.ResilientOptions{
+			MaxAttempts:      llmCfg.MaxAttempts,
+			CallTimeout:      llmCfg.CallTimeout,
+			FailureThreshold: llmCfg.BreakerThreshold,
+			Cooldown:         llmCfg.BreakerCooldown,
+		})
 	}
 
+	// 用量统计：记录每次成功调用的 token 数，并按价格表折算费用
+	prices, err := usage.LoadPriceTable(llmCfg.PriceFile)
+	if err != nil {
+		logger.Logger.Error("加载LLM价格表失败，费用将记为0",
+			zap.String("path", llmCfg.PriceFile),
+			zap.String("error", err.Error()),
+		)
+		prices = usage.PriceTable{}
+	}
+	usageSvc := usage.NewService(usage.NewRepository(db), prices, llmCfg.PriceCurrency)
+	llmClient = llm.NewMeteredClient(llmClient, usageSvc)
+
+	// 限额：HTTP 中间件限制请求频率，Client 在每次调用前检查当天的 token 用量
+	limiter := quota.NewLimiter(quota.Policy{
+		DefaultKey: quota.Limits{RequestsPerMinute: llmCfg.DefaultKeyRPM, TokensPerDay: llmCfg.DefaultKeyTokensPerDay},
+		OwnKey:     quota.Limits{RequestsPerMinute: llmCfg.OwnKeyRPM, TokensPerDay: llmCfg.OwnKeyTokensPerDay},
+	}, usageSvc)
+	llmClient = quota.NewClient(llmClient, limiter)
+
 	s := &Server{
 		engine:    engine,
 		db:        db,
@@ -43,6 +99,8 @@ func NewServer(cfg config.HTTPConfig, jwtCfg config.JWTConfig, cryptoCfg config.
 		cryptoCfg: cryptoCfg,
 		llmCfg:    llmCfg,
 		llmClient: llmClient,
+		usageSvc:  usageSvc,
+		limiter:   limiter,
 	}
 
 	// 注册路由
@@ -78,13 +136,15 @@ func (s *Server) setupRoutes() {
 			BaseURL:         s.llmCfg.APIBaseURL,
 			APIKey:          s.llmCfg.APIKey,
 			Model:           s.llmCfg.ModelName,
+			Protocol:        s.llmCfg.Protocol,
 			ThinkingType:    s.llmCfg.ThinkingType,
 			ReasoningEffort: s.llmCfg.ReasoningEffort,
 			AssistantName:   s.llmCfg.AssistantName,
 			HasAPIKey:       s.llmCfg.APIKey != "",
 			IsDefault:       true,
 		}
-		llmSettingService := auth.NewLLMSettingService(llmSettingRepo, s.cryptoCfg.APIKeyEncryptionKey, defaultLLMConfig)
+		llmSettingService := auth.NewLLMSettingService(llmSettingRepo, s.cryptoCfg.APIKeyEncryptionKey, defaultLLMConfig).
+			WithProfiles(auth.NewLLMProfileRepository(s.db))
 
 		taskRepo := task.NewRepository(s.db)
 		taskSvc := task.NewService(taskRepo, s.llmClient)
@@ -97,33 +157,63 @@ func (s *Server) setupRoutes() {
 		// Agents
 		router := agent.NewSimpleRouter()
 
-		// 初始化工具执行器映射（提前创建供所有agent使用）
-		toolMap := agent.NewToolExecutors()
+		// 初始化工具注册表（提前创建供所有agent使用）
+		tools := agent.NewToolRegistry(taskRepo, sessionRepo)
 
 		// 初始化Chat Completions处理器（提前创建供所有agent使用）
-		chatCompletionsHandler := agent.NewChatCompletionsHandler(s.llmClient, toolMap)
+		chatCompletionsHandler := agent.NewChatCompletionsHandler(s.llmClient, tools)
 
 		// 创建agents，传入chatCompletionsHandler
-		executorAgent := agent.NewExecutorAgent(s.llmClient, chatCompletionsHandler)
-		plannerAgent := agent.NewPlannerAgent(s.llmClient, chatCompletionsHandler)
-		summarizerAgent := agent.NewSummarizerAgent(s.llmClient)
-		globalAgent := agent.NewGlobalAgent(s.llmClient, chatCompletionsHandler)
-		taskCreationAgent := agent.NewTaskCreationAgent(s.llmClient)
-		agents := []agent.Agent{executorAgent, plannerAgent, summarizerAgent, globalAgent, taskCreationAgent}
+		agents := agent.NewDefaultAgents(s.llmClient, chatCompletionsHandler)
 		agentSvc := agent.NewService(router, agents, taskRepo, sessionRepo, dependencySvc, s.db, s.llmClient)
+		agentSvc.SetLLMConfigResolver(llmSettingService)
+		agentSvc.SetHistoryBudget(history.BudgetFor(s.llmCfg.HistoryTokens))
+		s.agentSvc = agentSvc
+
+		// 长期记忆：对话后提取，对话前按相关度注入
+		memorySvc := memory.NewService(s.db, s.llmClient)
+		agentSvc.SetMemory(memorySvc)
+
+		// 数据导出/导入
+		archiveSvc := archive.NewService(s.db, taskRepo)
+
+		// 用户资料（时区、工作时间）
+		profileSvc := profile.NewService(profile.NewRepository(s.db))
+
+		// 时间记录
+		timeSvc := timetrack.NewService(s.db)
+
+		// 任务报告（实际耗时来自时间记录）
+		reportSvc := report.NewService(report.NewRepository(s.db), taskRepo, timeSvc)
+
+		// 每日计划（今日重点按日期保存）
+		planSvc := dailyplan.NewService(s.db)
+
+		// 每日/每周回顾，定时任务见 StartDigestJob
+		digestSvc := digest.NewService(s.db, s.llmClient)
+		s.digestJob = digest.NewJob(digestSvc, sessionRepo, profileSvc, llmSettingService)
 
 		// 初始化处理器
 		authHandler := NewAuthHandler(authSvc)
-		taskHandler := NewTaskHandler(taskSvc, sessionRepo, llmSettingService)
-		sessionHandler := NewSessionHandler(agentSvc, sessionRepo, llmSettingService)
+		llmLimit := QuotaMiddleware(s.limiter, llmSettingService)
+		taskHandler := NewTaskHandler(taskSvc, sessionRepo, llmSettingService).WithLLMLimit(llmLimit).WithDailyPlans(planSvc)
+		sessionHandler := NewSessionHandler(agentSvc, sessionRepo, taskRepo, llmSettingService).WithLLMLimit(llmLimit)
 		settingsHandler := NewSettingsHandler(llmSettingService)
+		archiveHandler := NewArchiveHandler(archiveSvc)
+		reportHandler := NewReportHandler(reportSvc)
+		timeHandler := NewTimeHandler(timeSvc)
+		profileHandler := NewProfileHandler(profileSvc)
+		usageHandler := NewUsageHandler(s.usageSvc)
+		digestHandler := NewDigestHandler(digestSvc, llmSettingService).WithLLMLimit(llmLimit)
+		memoryHandler := NewMemoryHandler(memorySvc)
+		dailyPlanHandler := NewDailyPlanHandler(planSvc, agentSvc, sessionRepo, llmSettingService).WithLLMLimit(llmLimit)
 
 		// 认证路由
 		authHandler.RegisterRoutes(api.Group("/auth"))
 
 		// 需要登录的路由
 		authGroup := api.Group("")
-		authGroup.Use(AuthMiddleware(jwtMgr))
+		authGroup.Use(AuthMiddleware(jwtMgr), ProfileMiddleware(profileSvc))
 
 		// 任务路由
 		taskHandler.RegisterRoutes(authGroup)
@@ -133,6 +223,35 @@ func (s *Server) setupRoutes() {
 
 		// 设置路由
 		settingsHandler.RegisterRoutes(authGroup)
+
+		// 数据导出/导入路由
+		archiveHandler.RegisterRoutes(authGroup)
+
+		// 任务报告路由
+		reportHandler.RegisterRoutes(authGroup)
+
+		// 计时路由
+		timeHandler.RegisterRoutes(authGroup)
+
+		// 用户资料路由
+		profileHandler.RegisterRoutes(authGroup)
+
+		// 用量路由
+		usageHandler.RegisterRoutes(authGroup)
+
+		// 每日计划路由
+		dailyPlanHandler.RegisterRoutes(authGroup)
+
+		// 回顾路由
+		digestHandler.RegisterRoutes(authGroup)
+
+		// 长期记忆路由
+		memoryHandler.RegisterRoutes(authGroup)
+
+		// 管理员路由
+		adminGroup := authGroup.Group("/admin")
+		adminGroup.Use(AdminMiddleware(authSvc))
+		usageHandler.RegisterAdminRoutes(adminGroup)
 	}
 }
 
@@ -143,10 +262,51 @@ func (s *Server) healthCheck(c *gin.Context) {
 	})
 }
 
-// Start 启动HTTP服务器
-func (s *Server) Start() error {
-	addr := fmt.Sprintf("%s:%s", s.cfg.Host, s.cfg.Port)
-	return s.engine.Run(addr)
+// StartDigestJob 在后台定时生成每日/每周回顾并发到用户的全局会话，ctx 结束时停止
+func (s *Server) StartDigestJob(ctx context.Context, cfg config.DigestConfig) {
+	if !cfg.Enabled {
+		return
+	}
+	job := s.digestJob.WithOptions(digest.JobOptions{Hour: cfg.Hour, Polish: cfg.Polish})
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+		job.Run(ctx, cfg.Interval)
+	}()
+}
+
+// Start 启动HTTP服务器。ctx 结束时停止接收新请求，
+// 等待进行中的请求和后台工作（回顾、记忆提取、历史摘要）结束后返回
+func (s *Server) Start(ctx context.Context) error {
+	srv := &http.Server{
+		Addr:    fmt.Sprintf("%s:%s", s.cfg.Host, s.cfg.Port),
+		Handler: s.engine,
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	logger.Logger.Info("Server shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err := srv.Shutdown(shutdownCtx)
+	s.Wait()
+	return err
+}
+
+// Wait 等待后台工作结束：定时回顾（需先结束 StartDigestJob 的 ctx）以及 agent 的记忆提取和历史摘要
+func (s *Server) Wait() {
+	s.background.Wait()
+	if s.agentSvc != nil {
+		s.agentSvc.Wait()
+	}
 }
 
 // ServeHTTP 实现 http.Handler 接口，方便测试
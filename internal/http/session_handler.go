@@ -2,10 +2,15 @@ package http
 
 import (
 	"errors"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"assistant-qisumi/internal/agent"
 	"assistant-qisumi/internal/auth"
 	"assistant-qisumi/internal/session"
+	"assistant-qisumi/internal/task"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -13,40 +18,61 @@ import (
 
 // validateSessionOwner 验证 session 属于该用户
 func (h *SessionHandler) validateSessionOwner(c *gin.Context, sid, userID uint64) error {
+	_, err := h.ownedSession(c, sid, userID)
+	return err
+}
+
+// ownedSession 获取属于该用户的 session，失败时已写入响应
+func (h *SessionHandler) ownedSession(c *gin.Context, sid, userID uint64) (*session.Session, error) {
 	sess, err := h.sessionRepo.GetSession(c, sid)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			R.NotFound(c, "session not found")
-			return err
+			return nil, err
 		}
 		R.InternalError(c, err.Error())
-		return err
+		return nil, err
 	}
 	if sess.UserID != userID {
 		R.Forbidden(c, "forbidden")
-		return errors.New("forbidden")
+		return nil, errors.New("forbidden")
 	}
-	return nil
+	return sess, nil
 }
 
 type SessionHandler struct {
 	agentSvc      *agent.Service
 	sessionRepo   *session.Repository
 	llmSettingSvc *auth.LLMSettingService
+	llmLimit      gin.HandlerFunc
+	taskRepo      *task.Repository
 }
 
-func NewSessionHandler(agentSvc *agent.Service, sessionRepo *session.Repository, llmSettingSvc *auth.LLMSettingService) *SessionHandler {
+// NewSessionHandler 创建会话处理器，taskRepo 用于新建任务会话时检查任务属于该用户
+func NewSessionHandler(agentSvc *agent.Service, sessionRepo *session.Repository, taskRepo *task.Repository, llmSettingSvc *auth.LLMSettingService) *SessionHandler {
 	return &SessionHandler{
 		agentSvc:      agentSvc,
 		sessionRepo:   sessionRepo,
+		taskRepo:      taskRepo,
 		llmSettingSvc: llmSettingSvc,
 	}
 }
 
+// WithLLMLimit 为会调用 LLM 的路由加上限额中间件（见 QuotaMiddleware），需在 RegisterRoutes 之前调用
+func (h *SessionHandler) WithLLMLimit(mw gin.HandlerFunc) *SessionHandler {
+	h.llmLimit = mw
+	return h
+}
+
 func (h *SessionHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	// 每个任务和全局范围都可以有多个会话，按标题区分，可以归档
+	rg.GET("/sessions", h.listSessions)
+	rg.POST("/sessions", h.createSession)
 	rg.GET("/sessions/global", h.getGlobalSession)
+	rg.GET("/sessions/:id", h.getSession)
+	rg.PATCH("/sessions/:id", h.updateSession)
 	rg.GET("/sessions/:id/messages", h.listMessages)
-	rg.POST("/sessions/:id/messages", h.postMessage)
+	rg.POST("/sessions/:id/messages", withMiddleware(h.llmLimit, h.postMessage)...)
 	rg.DELETE("/sessions/:id/messages", h.clearMessages)
 }
 
@@ -60,6 +86,161 @@ func (h *SessionHandler) getGlobalSession(c *gin.Context) {
 	R.Success(c, gin.H{"session": sess})
 }
 
+// listSessions 列出会话，最近活跃的在前
+// type=task | global；taskId 只列出该任务的会话；archived=true 列出已归档的会话（默认只列出没有归档的）
+func (h *SessionHandler) listSessions(c *gin.Context) {
+	userID := GetUserID(c)
+	var f session.SessionFilter
+	switch t := c.Query("type"); t {
+	case "", "task", "global":
+		f.Type = t
+	default:
+		R.BadRequest(c, "type must be task or global")
+		return
+	}
+	if s := c.Query("taskId"); s != "" {
+		id, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			R.BadRequest(c, "invalid taskId")
+			return
+		}
+		f.TaskID = &id
+	}
+	if s := c.Query("archived"); s != "" {
+		archived, err := strconv.ParseBool(s)
+		if err != nil {
+			R.BadRequest(c, "archived must be true or false")
+			return
+		}
+		f.Archived = &archived
+	}
+
+	sessions, err := h.sessionRepo.ListSessions(c, userID, f)
+	if err != nil {
+		R.InternalError(c, err.Error())
+		return
+	}
+	R.Success(c, gin.H{"sessions": sessions})
+}
+
+// CreateSessionReq 新建会话的参数，type 为 task 时必须提供 taskId；title 为空时由第一条消息生成
+type CreateSessionReq struct {
+	Type   string  `json:"type"`
+	TaskID *uint64 `json:"taskId"`
+	Title  string  `json:"title"`
+}
+
+// createSession 开始一个新会话，之前的会话和消息保留，新会话成为该范围的当前会话
+func (h *SessionHandler) createSession(c *gin.Context) {
+	userID := GetUserID(c)
+	var req CreateSessionReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		R.BadRequest(c, err.Error())
+		return
+	}
+	title, ok := validSessionTitle(c, req.Title)
+	if !ok {
+		return
+	}
+
+	sess := &session.Session{UserID: userID, Type: req.Type, Title: title, Titled: title != ""}
+	switch req.Type {
+	case "global":
+	case "task":
+		if req.TaskID == nil {
+			R.BadRequest(c, "taskId is required for task sessions")
+			return
+		}
+		if err := h.taskRepo.CheckOwnership(c, userID, []uint64{*req.TaskID}, nil); err != nil {
+			if errors.Is(err, task.ErrNotOwned) {
+				R.NotFound(c, "task not found")
+				return
+			}
+			R.InternalError(c, err.Error())
+			return
+		}
+		sess.TaskID = req.TaskID
+	default:
+		R.BadRequest(c, "type must be task or global")
+		return
+	}
+
+	if err := h.sessionRepo.CreateSession(c, sess); err != nil {
+		R.InternalError(c, err.Error())
+		return
+	}
+	R.Success(c, gin.H{"session": sess})
+}
+
+func (h *SessionHandler) getSession(c *gin.Context) {
+	sid, err := ParseUint64Param(c, "id")
+	if err != nil {
+		return
+	}
+	sess, err := h.ownedSession(c, sid, GetUserID(c))
+	if err != nil {
+		return
+	}
+	R.Success(c, gin.H{"session": sess})
+}
+
+// UpdateSessionReq 修改会话的参数，字段为空表示不修改
+type UpdateSessionReq struct {
+	Title    *string `json:"title"`
+	Archived *bool   `json:"archived"`
+}
+
+// updateSession 重命名、归档或取消归档会话；归档后不再作为当前会话，但消息保留
+func (h *SessionHandler) updateSession(c *gin.Context) {
+	sid, err := ParseUint64Param(c, "id")
+	if err != nil {
+		return
+	}
+	sess, err := h.ownedSession(c, sid, GetUserID(c))
+	if err != nil {
+		return
+	}
+	var req UpdateSessionReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		R.BadRequest(c, err.Error())
+		return
+	}
+
+	if req.Title != nil {
+		title, ok := validSessionTitle(c, *req.Title)
+		if !ok {
+			return
+		}
+		// 用户设置过标题（包括清空）后不再自动命名
+		sess.Title, sess.Titled = title, true
+	}
+	if req.Archived != nil {
+		switch {
+		case *req.Archived && sess.ArchivedAt == nil:
+			now := time.Now()
+			sess.ArchivedAt = &now
+		case !*req.Archived:
+			sess.ArchivedAt = nil
+		}
+	}
+
+	if err := h.sessionRepo.SaveSession(c, sess); err != nil {
+		R.InternalError(c, err.Error())
+		return
+	}
+	R.Success(c, gin.H{"session": sess})
+}
+
+// validSessionTitle 去掉标题首尾空白并检查长度，失败时已写入响应
+func validSessionTitle(c *gin.Context, title string) (string, bool) {
+	title = strings.TrimSpace(title)
+	if utf8.RuneCountInString(title) > 100 {
+		R.BadRequest(c, "title must be at most 100 characters")
+		return "", false
+	}
+	return title, true
+}
+
 func (h *SessionHandler) listMessages(c *gin.Context) {
 	userID := GetUserID(c)
 	sid, err := ParseUint64Param(c, "id")
@@ -113,7 +294,7 @@ func (h *SessionHandler) postMessage(c *gin.Context) {
 
 	resp, err := h.agentSvc.HandleUserMessage(c, userID, sid, req.Content, *cfg)
 	if err != nil {
-		R.InternalError(c, "HandleUserMessage failed: "+err.Error())
+		RespondLLMError(c, "HandleUserMessage failed: ", err)
 		return
 	}
 
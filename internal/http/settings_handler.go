@@ -1,6 +1,8 @@
 package http
 
 import (
+	"errors"
+
 	"assistant-qisumi/internal/auth"
 
 	"github.com/gin-gonic/gin"
@@ -23,6 +25,13 @@ func (h *SettingsHandler) RegisterRoutes(rg *gin.RouterGroup) {
 	rg.GET("/settings/llm", h.getLLMSettings)
 	rg.POST("/settings/llm", h.updateLLMSettings)
 	rg.DELETE("/settings/llm", h.deleteLLMSettings)
+
+	// 命名配置及按 agent 指派
+	rg.GET("/settings/llm/profiles", h.listLLMProfiles)
+	rg.POST("/settings/llm/profiles", h.createLLMProfile)
+	rg.PUT("/settings/llm/profiles/assignments", h.updateLLMAssignments)
+	rg.PUT("/settings/llm/profiles/:id", h.updateLLMProfile)
+	rg.DELETE("/settings/llm/profiles/:id", h.deleteLLMProfile)
 }
 
 // getLLMSettings 获取当前用户的LLM设置
@@ -74,3 +83,102 @@ func (h *SettingsHandler) deleteLLMSettings(c *gin.Context) {
 
 	R.SuccessWithMessage(c, "LLM settings deleted", nil)
 }
+
+// listLLMProfiles 获取命名LLM配置及 agent 指派
+func (h *SettingsHandler) listLLMProfiles(c *gin.Context) {
+	userID := GetUserID(c)
+
+	profiles, assignments, err := h.llmSettingService.ListProfiles(c.Request.Context(), userID)
+	if err != nil {
+		R.InternalError(c, err.Error())
+		return
+	}
+	R.Success(c, gin.H{
+		"profiles":    profiles,
+		"assignments": assignments,
+	})
+}
+
+// createLLMProfile 创建命名LLM配置
+func (h *SettingsHandler) createLLMProfile(c *gin.Context) {
+	userID := GetUserID(c)
+
+	var req auth.LLMProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		R.BadRequest(c, err.Error())
+		return
+	}
+
+	profile, err := h.llmSettingService.CreateProfile(c.Request.Context(), userID, req)
+	if err != nil {
+		h.handleProfileError(c, err)
+		return
+	}
+	R.Created(c, profile)
+}
+
+// updateLLMProfile 更新命名LLM配置
+func (h *SettingsHandler) updateLLMProfile(c *gin.Context) {
+	userID := GetUserID(c)
+	id, err := ParseUint64Param(c, "id")
+	if err != nil {
+		return
+	}
+
+	var req auth.LLMProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		R.BadRequest(c, err.Error())
+		return
+	}
+
+	profile, err := h.llmSettingService.UpdateProfile(c.Request.Context(), userID, id, req)
+	if err != nil {
+		h.handleProfileError(c, err)
+		return
+	}
+	R.Success(c, profile)
+}
+
+// deleteLLMProfile 删除命名LLM配置
+func (h *SettingsHandler) deleteLLMProfile(c *gin.Context) {
+	userID := GetUserID(c)
+	id, err := ParseUint64Param(c, "id")
+	if err != nil {
+		return
+	}
+
+	if err := h.llmSettingService.DeleteProfile(c.Request.Context(), userID, id); err != nil {
+		h.handleProfileError(c, err)
+		return
+	}
+	R.SuccessWithMessage(c, "LLM profile deleted", nil)
+}
+
+// updateLLMAssignments 整体替换 agent 指派，例如 {"router": 1, "planner": 2}，0 表示不单独指定
+func (h *SettingsHandler) updateLLMAssignments(c *gin.Context) {
+	userID := GetUserID(c)
+
+	var req map[string]uint64
+	if err := c.ShouldBindJSON(&req); err != nil {
+		R.BadRequest(c, err.Error())
+		return
+	}
+
+	assignments, err := h.llmSettingService.SetAssignments(c.Request.Context(), userID, req)
+	if err != nil {
+		h.handleProfileError(c, err)
+		return
+	}
+	R.Success(c, gin.H{"assignments": assignments})
+}
+
+func (h *SettingsHandler) handleProfileError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, auth.ErrLLMProfileNotFound):
+		R.NotFound(c, err.Error())
+	case errors.Is(err, auth.ErrInvalidLLMProfile):
+		R.BadRequest(c, err.Error())
+	default:
+		R.InternalError(c, err.Error())
+	}
+}
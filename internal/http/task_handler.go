@@ -2,8 +2,16 @@ package http
 
 import (
 	"errors"
+	"io"
+	"path/filepath"
+	"strings"
 
 	"assistant-qisumi/internal/auth"
+	"assistant-qisumi/internal/dailyplan"
+	"assistant-qisumi/internal/domain"
+	"assistant-qisumi/internal/importer"
+	"assistant-qisumi/internal/llm"
+	"assistant-qisumi/internal/quickadd"
 	"assistant-qisumi/internal/session"
 	"assistant-qisumi/internal/task"
 
@@ -15,6 +23,8 @@ type TaskHandler struct {
 	taskSvc       *task.Service
 	sessionRepo   *session.Repository
 	llmSettingSvc *auth.LLMSettingService
+	llmLimit      gin.HandlerFunc
+	planSvc       *dailyplan.Service
 }
 
 func NewTaskHandler(taskSvc *task.Service, sessionRepo *session.Repository, llmSettingSvc *auth.LLMSettingService) *TaskHandler {
@@ -25,8 +35,22 @@ func NewTaskHandler(taskSvc *task.Service, sessionRepo *session.Repository, llmS
 	}
 }
 
+// WithLLMLimit 为会调用 LLM 的路由加上限额中间件（见 QuotaMiddleware），需在 RegisterRoutes 之前调用
+func (h *TaskHandler) WithLLMLimit(mw gin.HandlerFunc) *TaskHandler {
+	h.llmLimit = mw
+	return h
+}
+
+// WithDailyPlans 让任务列表和 isFocusToday 的修改与今天的计划保持一致（见 dailyplan），需在 RegisterRoutes 之前调用
+func (h *TaskHandler) WithDailyPlans(planSvc *dailyplan.Service) *TaskHandler {
+	h.planSvc = planSvc
+	return h
+}
+
 func (h *TaskHandler) RegisterRoutes(rg *gin.RouterGroup) {
-	rg.POST("/tasks/from-text", h.createFromText)
+	rg.POST("/tasks/from-text", withMiddleware(h.llmLimit, h.createFromText)...)
+	rg.POST("/tasks/quick", h.quickAdd)
+	rg.POST("/tasks/import", h.importTasks)
 	rg.GET("/tasks", h.listTasks)
 	rg.GET("/tasks/completed", h.listCompletedTasks)
 	rg.POST("/tasks", h.createTask)
@@ -50,14 +74,14 @@ func (h *TaskHandler) createFromText(c *gin.Context) {
 		return
 	}
 
-	cfg, err := GetLLMConfig(c, h.llmSettingSvc, userID)
+	cfg, err := GetAgentLLMConfig(c, h.llmSettingSvc, userID, "task_creation")
 	if err != nil {
 		return
 	}
 
 	t, err := h.taskSvc.CreateFromText(c, userID, req.RawText, *cfg)
 	if err != nil {
-		R.InternalError(c, err.Error())
+		RespondLLMError(c, "", err)
 		return
 	}
 
@@ -74,9 +98,151 @@ func (h *TaskHandler) createFromText(c *gin.Context) {
 	})
 }
 
+type QuickAddReq struct {
+	Text   string `json:"text" binding:"required"`
+	Enrich bool   `json:"enrich"` // 配置了 LLM 时用它补全步骤等信息
+}
+
+// quickAdd 用 "写周报 !high #工作 @周五" 这样的内联语法快速创建任务，默认不调用 LLM
+func (h *TaskHandler) quickAdd(c *gin.Context) {
+	userID := GetUserID(c)
+	var req QuickAddReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		R.BadRequest(c, err.Error())
+		return
+	}
+
+	// 补全是可选的：没有配置 LLM 时直接跳过，而不是让整个请求失败
+	var cfg *llm.Config
+	warning := ""
+	if req.Enrich {
+		if llmConfig, err := h.llmSettingSvc.GetAgentLLMConfig(c, userID, "task_creation"); err == nil && llmConfig != nil && llmConfig.APIKey != "" {
+			cfg = llmConfig
+		} else {
+			warning = "LLM is not configured, enrichment skipped"
+		}
+	}
+
+	result, err := h.taskSvc.QuickAdd(c, userID, req.Text, cfg)
+	if err != nil {
+		if errors.Is(err, quickadd.ErrInvalidInput) {
+			R.BadRequest(c, err.Error())
+		} else {
+			R.InternalError(c, err.Error())
+		}
+		return
+	}
+	if result.Warning == "" {
+		result.Warning = warning
+	}
+
+	sess, err := h.sessionRepo.GetTaskSessionOrCreate(c, userID, result.Task.ID)
+	if err != nil {
+		R.InternalError(c, "failed to get or create session")
+		return
+	}
+
+	R.Success(c, gin.H{
+		"task":     result.Task,
+		"session":  sess,
+		"enriched": result.Enriched,
+		"warning":  result.Warning,
+	})
+}
+
+type ImportTasksReq struct {
+	Format  string `json:"format" binding:"required"` // ics | csv | markdown
+	Content string `json:"content" binding:"required"`
+	DryRun  bool   `json:"dryRun"`
+}
+
+// importTasks 从 iCalendar / CSV / Markdown 导入任务
+// 支持 JSON 请求体，也支持 multipart 上传（字段 file，可选 format、dryRun）
+// dryRun 为 true 时只返回解析预览，不写入数据库
+func (h *TaskHandler) importTasks(c *gin.Context) {
+	userID := GetUserID(c)
+
+	var req ImportTasksReq
+	if c.ContentType() == "multipart/form-data" {
+		file, err := c.FormFile("file")
+		if err != nil {
+			R.BadRequest(c, "missing file")
+			return
+		}
+		f, err := file.Open()
+		if err != nil {
+			R.BadRequest(c, err.Error())
+			return
+		}
+		defer f.Close()
+		content, err := io.ReadAll(f)
+		if err != nil {
+			R.BadRequest(c, err.Error())
+			return
+		}
+		req.Content = string(content)
+		req.Format = c.PostForm("format")
+		if req.Format == "" {
+			req.Format = filepath.Ext(file.Filename)
+		}
+		req.DryRun = c.PostForm("dryRun") == "true"
+	} else if err := c.ShouldBindJSON(&req); err != nil {
+		R.BadRequest(c, err.Error())
+		return
+	}
+
+	format, err := importer.ParseFormat(req.Format)
+	if err != nil {
+		R.BadRequest(c, err.Error())
+		return
+	}
+
+	tasks, err := importer.Parse(format, strings.NewReader(req.Content))
+	if err != nil {
+		R.BadRequest(c, err.Error())
+		return
+	}
+	loc := domain.LocationFromContext(c.Request.Context())
+	for _, t := range tasks {
+		t.AssumeLocation(loc)
+	}
+	if len(tasks) == 0 {
+		R.BadRequest(c, "no tasks found in content")
+		return
+	}
+
+	if !req.DryRun {
+		if err := h.taskSvc.ImportTasks(c, userID, tasks); err != nil {
+			R.InternalError(c, err.Error())
+			return
+		}
+	}
+
+	R.Success(c, gin.H{
+		"tasks":  tasks,
+		"total":  len(tasks),
+		"dryRun": req.DryRun,
+	})
+}
+
+// syncFocus 按今天的计划同步今日重点，日期变化后清除前一天的重点；失败时已写入响应
+func (h *TaskHandler) syncFocus(c *gin.Context, userID uint64) bool {
+	if h.planSvc == nil {
+		return true
+	}
+	if err := h.planSvc.SyncFocus(c.Request.Context(), userID); err != nil {
+		R.InternalError(c, err.Error())
+		return false
+	}
+	return true
+}
+
 // listTasks 获取任务列表
 func (h *TaskHandler) listTasks(c *gin.Context) {
 	userID := GetUserID(c)
+	if !h.syncFocus(c, userID) {
+		return
+	}
 	tasks, err := h.taskSvc.ListTasks(c, userID)
 	if err != nil {
 		R.InternalError(c, err.Error())
@@ -109,6 +275,9 @@ func (h *TaskHandler) getTask(c *gin.Context) {
 	if err != nil {
 		return
 	}
+	if !h.syncFocus(c, userID) {
+		return
+	}
 
 	t, err := h.taskSvc.GetTask(c, userID, id)
 	if err != nil {
@@ -147,6 +316,21 @@ func (h *TaskHandler) patchTask(c *gin.Context) {
 		R.InternalError(c, err.Error())
 		return
 	}
+	if h.planSvc != nil && fields.IsFocusToday != nil {
+		// 今日重点由今天的计划决定，勾选/取消即加入或移出计划
+		ctx := c.Request.Context()
+		date := h.planSvc.Today(ctx)
+		refs := []dailyplan.ItemRef{{TaskID: id}}
+		if *fields.IsFocusToday {
+			_, err = h.planSvc.Mark(ctx, userID, date, refs)
+		} else {
+			_, err = h.planSvc.Unmark(ctx, userID, date, refs)
+		}
+		if err != nil {
+			respondDailyPlanError(c, err)
+			return
+		}
+	}
 	R.SuccessWithMessage(c, "task updated", nil)
 }
 
@@ -159,6 +343,8 @@ func (h *TaskHandler) createTask(c *gin.Context) {
 		return
 	}
 	t.UserID = userID
+	// 不带时区的时间按用户时区理解
+	t.AssumeLocation(domain.LocationFromContext(c.Request.Context()))
 
 	if err := h.taskSvc.CreateTask(c, &t); err != nil {
 		R.InternalError(c, err.Error())
@@ -226,6 +412,7 @@ func (h *TaskHandler) addStep(c *gin.Context) {
 		R.BadRequest(c, err.Error())
 		return
 	}
+	step.AssumeLocation(domain.LocationFromContext(c.Request.Context()))
 
 	if err := h.taskSvc.AddStep(c, userID, taskID, &step); err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
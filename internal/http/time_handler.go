@@ -0,0 +1,223 @@
+package http
+
+import (
+	"errors"
+	"time"
+
+	"assistant-qisumi/internal/domain"
+	"assistant-qisumi/internal/timetrack"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// TimeHandler 处理计时器和时间统计相关请求
+type TimeHandler struct {
+	timeSvc *timetrack.Service
+}
+
+// NewTimeHandler 创建新的计时处理器
+func NewTimeHandler(timeSvc *timetrack.Service) *TimeHandler {
+	return &TimeHandler{timeSvc: timeSvc}
+}
+
+// RegisterRoutes 注册计时相关路由
+func (h *TimeHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/timer", h.listRunningTimers)
+	rg.POST("/timer/stop", h.stopTimer)
+	rg.POST("/tasks/:id/timer/start", h.startTimer)
+	rg.GET("/tasks/:id/time", h.getTaskTime)
+	rg.POST("/tasks/:id/time-entries", h.addTimeEntry)
+	rg.DELETE("/time-entries/:id", h.deleteTimeEntry)
+	rg.GET("/time/weekly", h.getWeeklySummary)
+	rg.GET("/time/accuracy", h.getEstimateAccuracy)
+}
+
+func respondTimeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		R.NotFound(c, "task or step not found")
+	case errors.Is(err, timetrack.ErrNoRunningTimer):
+		R.NotFound(c, err.Error())
+	case errors.Is(err, timetrack.ErrInvalidEntry):
+		R.BadRequest(c, err.Error())
+	default:
+		R.InternalError(c, err.Error())
+	}
+}
+
+type StartTimerReq struct {
+	StepID *uint64 `json:"step_id"`
+	Note   string  `json:"note"`
+}
+
+// startTimer 开始为任务或步骤计时
+func (h *TimeHandler) startTimer(c *gin.Context) {
+	userID := GetUserID(c)
+	taskID, err := ParseUint64Param(c, "id")
+	if err != nil {
+		return
+	}
+
+	var req StartTimerReq
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			R.BadRequest(c, err.Error())
+			return
+		}
+	}
+
+	entry, err := h.timeSvc.StartTimer(c.Request.Context(), userID, taskID, req.StepID, req.Note)
+	if err != nil {
+		respondTimeError(c, err)
+		return
+	}
+	R.Created(c, entry)
+}
+
+type StopTimerReq struct {
+	TaskID *uint64 `json:"task_id"`
+	StepID *uint64 `json:"step_id"`
+}
+
+// stopTimer 停止正在运行的计时器
+func (h *TimeHandler) stopTimer(c *gin.Context) {
+	userID := GetUserID(c)
+
+	var req StopTimerReq
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			R.BadRequest(c, err.Error())
+			return
+		}
+	}
+
+	entries, err := h.timeSvc.StopTimer(c.Request.Context(), userID, req.TaskID, req.StepID)
+	if err != nil {
+		respondTimeError(c, err)
+		return
+	}
+	R.Success(c, entries)
+}
+
+// listRunningTimers 获取正在运行的计时器
+func (h *TimeHandler) listRunningTimers(c *gin.Context) {
+	userID := GetUserID(c)
+
+	entries, err := h.timeSvc.RunningTimers(c.Request.Context(), userID)
+	if err != nil {
+		R.InternalError(c, err.Error())
+		return
+	}
+	R.Success(c, entries)
+}
+
+// getTaskTime 获取任务各步骤的预估与实际耗时
+func (h *TimeHandler) getTaskTime(c *gin.Context) {
+	userID := GetUserID(c)
+	taskID, err := ParseUint64Param(c, "id")
+	if err != nil {
+		return
+	}
+
+	summary, err := h.timeSvc.TaskSummary(c.Request.Context(), userID, taskID)
+	if err != nil {
+		respondTimeError(c, err)
+		return
+	}
+	R.Success(c, summary)
+}
+
+type AddTimeEntryReq struct {
+	StepID    *uint64 `json:"step_id"`
+	StartedAt string  `json:"started_at"` // 可选，默认为当前时间往前推 minutes 分钟
+	Minutes   int     `json:"minutes" binding:"required,min=1"`
+	Note      string  `json:"note"`
+}
+
+// addTimeEntry 手动补录时间
+func (h *TimeHandler) addTimeEntry(c *gin.Context) {
+	userID := GetUserID(c)
+	taskID, err := ParseUint64Param(c, "id")
+	if err != nil {
+		return
+	}
+
+	var req AddTimeEntryReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		R.BadRequest(c, err.Error())
+		return
+	}
+
+	var startedAt time.Time
+	if req.StartedAt != "" {
+		ft, err := domain.ParseFlexibleTimeIn(req.StartedAt, domain.LocationFromContext(c.Request.Context()))
+		if err != nil {
+			R.BadRequest(c, "invalid started_at")
+			return
+		}
+		startedAt = ft.ToTime()
+	}
+
+	entry, err := h.timeSvc.AddManualEntry(c.Request.Context(), userID, taskID, req.StepID, startedAt, req.Minutes, req.Note)
+	if err != nil {
+		respondTimeError(c, err)
+		return
+	}
+	R.Created(c, entry)
+}
+
+// deleteTimeEntry 删除时间记录
+func (h *TimeHandler) deleteTimeEntry(c *gin.Context) {
+	userID := GetUserID(c)
+	entryID, err := ParseUint64Param(c, "id")
+	if err != nil {
+		return
+	}
+
+	if err := h.timeSvc.DeleteEntry(c.Request.Context(), userID, entryID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			R.NotFound(c, "time entry not found")
+			return
+		}
+		R.InternalError(c, err.Error())
+		return
+	}
+	R.SuccessWithMessage(c, "time entry deleted", nil)
+}
+
+// getWeeklySummary 获取一周的时间统计，week 为该周内任意一天（YYYY-MM-DD），默认本周
+func (h *TimeHandler) getWeeklySummary(c *gin.Context) {
+	userID := GetUserID(c)
+
+	// 按用户时区划分周和天
+	loc := domain.LocationFromContext(c.Request.Context())
+	ref := time.Now().In(loc)
+	if week := c.Query("week"); week != "" {
+		t, err := time.ParseInLocation("2006-01-02", week, loc)
+		if err != nil {
+			R.BadRequest(c, "week must be in YYYY-MM-DD format")
+			return
+		}
+		ref = t
+	}
+
+	summary, err := h.timeSvc.WeeklySummary(c.Request.Context(), userID, ref)
+	if err != nil {
+		R.InternalError(c, err.Error())
+		return
+	}
+	R.Success(c, summary)
+}
+
+// getEstimateAccuracy 获取历史估时准确度，样本不足时返回 null
+func (h *TimeHandler) getEstimateAccuracy(c *gin.Context) {
+	userID := GetUserID(c)
+
+	acc, err := h.timeSvc.EstimateAccuracy(c.Request.Context(), userID)
+	if err != nil {
+		R.InternalError(c, err.Error())
+		return
+	}
+	R.Success(c, acc)
+}
@@ -0,0 +1,126 @@
+package http
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"assistant-qisumi/internal/domain"
+	"assistant-qisumi/internal/usage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultUsageDays 未指定时间范围时统计最近多少天
+const defaultUsageDays = 30
+
+// UsageHandler 处理 LLM 用量统计相关请求
+type UsageHandler struct {
+	usageSvc *usage.Service
+}
+
+// NewUsageHandler 创建新的用量处理器
+func NewUsageHandler(usageSvc *usage.Service) *UsageHandler {
+	return &UsageHandler{usageSvc: usageSvc}
+}
+
+// RegisterRoutes 注册当前用户的用量路由
+func (h *UsageHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/usage", h.getUsage)
+}
+
+// RegisterAdminRoutes 注册全站用量路由，rg 需要已经挂上 AdminMiddleware
+func (h *UsageHandler) RegisterAdminRoutes(rg *gin.RouterGroup) {
+	rg.GET("/usage", h.getAllUsage)
+}
+
+// getUsage 当前用户的用量：GET /usage?from=&to=&group_by=agent|model|day
+func (h *UsageHandler) getUsage(c *gin.Context) {
+	userID := GetUserID(c)
+	q, err := parseUsageQuery(c)
+	if err != nil {
+		R.BadRequest(c, err.Error())
+		return
+	}
+	q.UserID = &userID
+	h.respondReport(c, q)
+}
+
+// getAllUsage 全站用量，额外支持 group_by=user 和 user_id 过滤
+func (h *UsageHandler) getAllUsage(c *gin.Context) {
+	q, err := parseUsageQuery(c)
+	if err != nil {
+		R.BadRequest(c, err.Error())
+		return
+	}
+	if v := c.Query("user_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			R.BadRequest(c, "invalid user_id")
+			return
+		}
+		q.UserID = &id
+	}
+	h.respondReport(c, q)
+}
+
+func (h *UsageHandler) respondReport(c *gin.Context, q usage.Query) {
+	report, err := h.usageSvc.Report(c.Request.Context(), q)
+	if err != nil {
+		if errors.Is(err, usage.ErrInvalidQuery) {
+			R.BadRequest(c, err.Error())
+			return
+		}
+		R.InternalError(c, err.Error())
+		return
+	}
+	R.Success(c, report)
+}
+
+// parseUsageQuery 解析 from / to / group_by。
+// from、to 可以是 YYYY-MM-DD（按用户时区，to 当天包含在内）或带时间的字符串；
+// 默认统计最近 30 天，按 agent 分组。
+func parseUsageQuery(c *gin.Context) (usage.Query, error) {
+	loc := domain.LocationFromContext(c.Request.Context())
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	q := usage.Query{
+		From:     today.AddDate(0, 0, -defaultUsageDays+1),
+		To:       today.AddDate(0, 0, 1),
+		GroupBy:  usage.GroupByAgent,
+		Location: loc,
+	}
+	if v := c.Query("group_by"); v != "" {
+		q.GroupBy = usage.GroupBy(v)
+	}
+	if v := c.Query("from"); v != "" {
+		t, _, err := parseUsageTime(v, loc)
+		if err != nil {
+			return q, errors.New("invalid from: " + err.Error())
+		}
+		q.From = t
+	}
+	if v := c.Query("to"); v != "" {
+		t, dateOnly, err := parseUsageTime(v, loc)
+		if err != nil {
+			return q, errors.New("invalid to: " + err.Error())
+		}
+		if dateOnly {
+			t = t.AddDate(0, 0, 1)
+		}
+		q.To = t
+	}
+	return q, nil
+}
+
+func parseUsageTime(v string, loc *time.Location) (time.Time, bool, error) {
+	if t, err := time.ParseInLocation("2006-01-02", v, loc); err == nil {
+		return t, true, nil
+	}
+	ft, err := domain.ParseFlexibleTimeIn(v, loc)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return ft.Time, false, nil
+}
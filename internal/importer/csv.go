@@ -0,0 +1,136 @@
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"assistant-qisumi/internal/domain"
+)
+
+// csvColumnAliases 表头别名 -> 标准列名
+var csvColumnAliases = map[string]string{
+	"title":       "title",
+	"name":        "title",
+	"summary":     "title",
+	"task":        "title",
+	"description": "description",
+	"desc":        "description",
+	"notes":       "description",
+	"due":         "due",
+	"due_at":      "due",
+	"due date":    "due",
+	"deadline":    "due",
+	"priority":    "priority",
+	"steps":       "steps",
+	"subtasks":    "steps",
+}
+
+// ParseCSV 解析带表头的 CSV，必须包含 title 列
+// steps 列中多个步骤以换行或分号分隔，以 [x] 开头的步骤视为已完成
+func ParseCSV(r io.Reader) ([]*domain.Task, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("csv is empty")
+		}
+		return nil, err
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if std, ok := csvColumnAliases[name]; ok {
+			columns[std] = i
+		}
+	}
+	if _, ok := columns["title"]; !ok {
+		return nil, errors.New("csv header must contain a title column")
+	}
+
+	var tasks []*domain.Task
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		get := func(col string) string {
+			i, ok := columns[col]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		title := get("title")
+		if title == "" {
+			// 跳过空行
+			if strings.TrimSpace(strings.Join(record, "")) == "" {
+				continue
+			}
+			return nil, &LineError{Line: line, Err: errors.New("title is empty")}
+		}
+
+		t := newTask(title)
+		t.Description = get("description")
+
+		if due := get("due"); due != "" {
+			ft, err := domain.ParseFlexibleTime(due)
+			if err != nil {
+				return nil, &LineError{Line: line, Err: fmt.Errorf("due: %w", err)}
+			}
+			t.DueAt = ft
+		}
+
+		priority, err := normalizePriority(get("priority"))
+		if err != nil {
+			return nil, &LineError{Line: line, Err: err}
+		}
+		t.Priority = priority
+
+		for _, s := range splitCSVSteps(get("steps")) {
+			title, done := trimCheckbox(s)
+			if title == "" {
+				continue
+			}
+			appendStep(t, title, done)
+		}
+
+		tasks = append(tasks, t)
+	}
+
+	return tasks, nil
+}
+
+func splitCSVSteps(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == '\n' || r == ';' || r == '；'
+	})
+}
+
+// trimCheckbox 去掉步骤前的 "[ ]" / "[x]" 标记，返回标题和是否已完成
+func trimCheckbox(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	switch {
+	case strings.HasPrefix(s, "[x]"), strings.HasPrefix(s, "[X]"):
+		return strings.TrimSpace(s[3:]), true
+	case strings.HasPrefix(s, "[ ]"):
+		return strings.TrimSpace(s[3:]), false
+	default:
+		return s, false
+	}
+}
@@ -0,0 +1,329 @@
+package importer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"assistant-qisumi/internal/domain"
+)
+
+// icsProperty iCalendar 中的一行属性，例如 DUE;TZID=Asia/Shanghai:20251201T180000
+type icsProperty struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// icsTodo 单个 VTODO 组件
+type icsTodo struct {
+	line      int
+	uid       string
+	relatedTo string
+	props     []icsProperty
+}
+
+// ParseICS 解析 iCalendar 中的 VTODO 组件
+// RELATED-TO 指向另一个 VTODO 的条目会被视为该任务的步骤
+func ParseICS(r io.Reader) ([]*domain.Task, error) {
+	lines, err := unfoldICSLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		todos   []*icsTodo
+		current *icsTodo
+		nested  int // 当前 VTODO 内嵌套组件（例如 VALARM）的层数，其中的属性不属于任务
+	)
+	for _, l := range lines {
+		prop, err := parseICSProperty(l.text)
+		if err != nil {
+			return nil, &LineError{Line: l.number, Err: err}
+		}
+		switch {
+		case current != nil && prop.Name == "BEGIN":
+			nested++
+		case current != nil && nested > 0:
+			if prop.Name == "END" {
+				nested--
+			}
+		case prop.Name == "BEGIN" && strings.EqualFold(prop.Value, "VTODO"):
+			current = &icsTodo{line: l.number}
+		case prop.Name == "END" && strings.EqualFold(prop.Value, "VTODO"):
+			if current == nil {
+				return nil, &LineError{Line: l.number, Err: errors.New("END:VTODO without BEGIN:VTODO")}
+			}
+			todos = append(todos, current)
+			current = nil
+		case current != nil:
+			switch prop.Name {
+			case "UID":
+				current.uid = prop.Value
+			case "RELATED-TO":
+				// 只处理默认的 PARENT 关系
+				if rel := prop.Params["RELTYPE"]; rel == "" || strings.EqualFold(rel, "PARENT") {
+					current.relatedTo = prop.Value
+				}
+			}
+			current.props = append(current.props, prop)
+		}
+	}
+	if current != nil {
+		return nil, &LineError{Line: current.line, Err: errors.New("unterminated VTODO")}
+	}
+
+	var tasks []*domain.Task
+	byUID := make(map[string]*domain.Task)
+	var children []*icsTodo
+	for _, todo := range todos {
+		if todo.relatedTo != "" {
+			children = append(children, todo)
+			continue
+		}
+		t, err := todo.toTask()
+		if err != nil {
+			return nil, &LineError{Line: todo.line, Err: err}
+		}
+		tasks = append(tasks, t)
+		if todo.uid != "" {
+			byUID[todo.uid] = t
+		}
+	}
+
+	for _, todo := range children {
+		parent, ok := byUID[todo.relatedTo]
+		if !ok {
+			// 找不到父任务时按独立任务处理
+			t, err := todo.toTask()
+			if err != nil {
+				return nil, &LineError{Line: todo.line, Err: err}
+			}
+			tasks = append(tasks, t)
+			continue
+		}
+		step, err := todo.toStep()
+		if err != nil {
+			return nil, &LineError{Line: todo.line, Err: err}
+		}
+		step.OrderIndex = len(parent.Steps)
+		parent.Steps = append(parent.Steps, step)
+	}
+
+	return tasks, nil
+}
+
+func (todo *icsTodo) toTask() (*domain.Task, error) {
+	t := newTask("")
+	for _, p := range todo.props {
+		switch p.Name {
+		case "SUMMARY":
+			t.Title = unescapeICSText(p.Value)
+		case "DESCRIPTION":
+			t.Description = unescapeICSText(p.Value)
+		case "DUE":
+			due, floating, err := parseICSTimeFloating(p)
+			if err != nil {
+				return nil, err
+			}
+			t.DueAt = &domain.FlexibleTime{Time: due}
+			if floating {
+				t.DueAt = domain.FloatingTime(due)
+			}
+		case "PRIORITY":
+			t.Priority = icsPriority(p.Value)
+		case "STATUS":
+			t.Status = icsTaskStatus(p.Value)
+		case "COMPLETED":
+			completed, err := parseICSTime(p)
+			if err != nil {
+				return nil, err
+			}
+			t.CompletedAt = &completed
+		}
+	}
+	if strings.TrimSpace(t.Title) == "" {
+		return nil, errors.New("VTODO missing SUMMARY")
+	}
+	return t, nil
+}
+
+func (todo *icsTodo) toStep() (domain.TaskStep, error) {
+	step := domain.TaskStep{Status: "todo"}
+	for _, p := range todo.props {
+		switch p.Name {
+		case "SUMMARY":
+			step.Title = unescapeICSText(p.Value)
+		case "DESCRIPTION":
+			step.Detail = unescapeICSText(p.Value)
+		case "STATUS":
+			step.Status = icsStepStatus(p.Value)
+		case "COMPLETED":
+			completed, err := parseICSTime(p)
+			if err != nil {
+				return step, err
+			}
+			step.CompletedAt = &completed
+		}
+	}
+	if strings.TrimSpace(step.Title) == "" {
+		return step, errors.New("VTODO missing SUMMARY")
+	}
+	return step, nil
+}
+
+type icsLine struct {
+	number int
+	text   string
+}
+
+// unfoldICSLines 处理 RFC 5545 的折行：以空格或制表符开头的行是上一行的续行
+func unfoldICSLines(r io.Reader) ([]icsLine, error) {
+	var lines []icsLine
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	n := 0
+	for scanner.Scan() {
+		n++
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if text == "" {
+			continue
+		}
+		if (text[0] == ' ' || text[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1].text += text[1:]
+			continue
+		}
+		lines = append(lines, icsLine{number: n, text: text})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+func parseICSProperty(line string) (icsProperty, error) {
+	colon := indexOutsideQuotes(line, ':')
+	if colon < 0 {
+		return icsProperty{}, fmt.Errorf("invalid content line %q", line)
+	}
+	head, value := line[:colon], line[colon+1:]
+	parts := strings.Split(head, ";")
+	prop := icsProperty{
+		Name:   strings.ToUpper(parts[0]),
+		Params: make(map[string]string),
+		Value:  value,
+	}
+	for _, p := range parts[1:] {
+		k, v, ok := strings.Cut(p, "=")
+		if !ok {
+			continue
+		}
+		prop.Params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+	}
+	return prop, nil
+}
+
+func indexOutsideQuotes(s string, sep byte) int {
+	inQuotes := false
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			inQuotes = !inQuotes
+		case sep:
+			if !inQuotes {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// parseICSTime 支持 UTC（…Z）、带 TZID 的本地时间、浮动时间和纯日期
+func parseICSTime(p icsProperty) (time.Time, error) {
+	t, _, err := parseICSTimeFloating(p)
+	return t, err
+}
+
+// parseICSTimeFloating 同 parseICSTime，另外返回是否为不带时区的浮动时间或纯日期（暂按 UTC 解析）
+func parseICSTimeFloating(p icsProperty) (time.Time, bool, error) {
+	loc := time.UTC
+	if tzid := p.Params["TZID"]; tzid != "" {
+		l, err := time.LoadLocation(tzid)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("%s: unknown TZID %q", p.Name, tzid)
+		}
+		loc = l
+	}
+	v := strings.TrimSpace(p.Value)
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		if strings.HasSuffix(layout, "Z") {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, false, nil
+			}
+			continue
+		}
+		if t, err := time.ParseInLocation(layout, v, loc); err == nil {
+			return t, p.Params["TZID"] == "", nil
+		}
+	}
+	return time.Time{}, false, fmt.Errorf("%s: invalid date-time %q", p.Name, v)
+}
+
+func unescapeICSText(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// icsPriority RFC 5545: 1-4 高，5 中，6-9 低，0 未定义
+func icsPriority(v string) string {
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+	switch {
+	case err != nil || n == 0 || n == 5:
+		return "medium"
+	case n < 5:
+		return "high"
+	default:
+		return "low"
+	}
+}
+
+func icsTaskStatus(v string) string {
+	switch strings.ToUpper(strings.TrimSpace(v)) {
+	case "COMPLETED":
+		return "done"
+	case "IN-PROCESS":
+		return "in_progress"
+	case "CANCELLED":
+		return "cancelled"
+	default:
+		return "todo"
+	}
+}
+
+func icsStepStatus(v string) string {
+	switch strings.ToUpper(strings.TrimSpace(v)) {
+	case "COMPLETED":
+		return "done"
+	case "IN-PROCESS":
+		return "in_progress"
+	default:
+		return "todo"
+	}
+}
@@ -0,0 +1,108 @@
+// Package importer 把外部格式（iCalendar VTODO、CSV、Markdown 清单）解析为任务
+// 解析结果只是内存中的 domain.Task，不涉及数据库，由调用方决定预览还是入库
+package importer
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"assistant-qisumi/internal/domain"
+)
+
+// Format 导入文件格式
+type Format string
+
+const (
+	FormatICS      Format = "ics"
+	FormatCSV      Format = "csv"
+	FormatMarkdown Format = "markdown"
+)
+
+// ParseFormat 解析格式名称，兼容常见别名和扩展名
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(strings.TrimPrefix(strings.TrimSpace(s), ".")) {
+	case "ics", "ical", "icalendar", "vtodo":
+		return FormatICS, nil
+	case "csv":
+		return FormatCSV, nil
+	case "md", "markdown":
+		return FormatMarkdown, nil
+	default:
+		return "", fmt.Errorf("unsupported import format: %q", s)
+	}
+}
+
+// Parse 按格式解析输入，返回待创建的任务（UserID 为空，由调用方填充）
+// 不带时区的时间暂按 UTC 解析，调用方需要通过 Task.AssumeLocation 按用户时区理解
+func Parse(format Format, r io.Reader) ([]*domain.Task, error) {
+	var (
+		tasks []*domain.Task
+		err   error
+	)
+	switch format {
+	case FormatICS:
+		tasks, err = ParseICS(r)
+	case FormatCSV:
+		tasks, err = ParseCSV(r)
+	case FormatMarkdown:
+		tasks, err = ParseMarkdown(r)
+	default:
+		return nil, fmt.Errorf("unsupported import format: %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tasks {
+		t.CreatedFrom = "import:" + string(format)
+	}
+	return tasks, nil
+}
+
+// LineError 带行号的解析错误
+type LineError struct {
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error { return e.Err }
+
+// newTask 构造一个默认状态的任务
+func newTask(title string) *domain.Task {
+	return &domain.Task{
+		Title:    strings.TrimSpace(title),
+		Status:   "todo",
+		Priority: "medium",
+	}
+}
+
+// appendStep 以追加方式为任务添加步骤，自动维护 order_index
+func appendStep(t *domain.Task, title string, done bool) {
+	status := "todo"
+	if done {
+		status = "done"
+	}
+	t.Steps = append(t.Steps, domain.TaskStep{
+		Title:      strings.TrimSpace(title),
+		Status:     status,
+		OrderIndex: len(t.Steps),
+	})
+}
+
+// normalizePriority 将各种写法的优先级归一为 low / medium / high
+func normalizePriority(s string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "medium", "normal", "med", "m", "2", "中":
+		return "medium", nil
+	case "high", "h", "urgent", "1", "高":
+		return "high", nil
+	case "low", "l", "3", "低":
+		return "low", nil
+	default:
+		return "", fmt.Errorf("invalid priority %q", s)
+	}
+}
@@ -0,0 +1,106 @@
+package importer
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	"assistant-qisumi/internal/domain"
+)
+
+// ParseMarkdown 解析 Markdown 清单：
+//   - "# 标题"（任意级别）开始一个任务
+//   - 标题下的 "- [ ] 步骤" / "- [x] 步骤" 是该任务的步骤
+//   - 标题下的其他文字并入任务描述
+//   - 不在任何标题下的清单项各自成为独立任务
+//   - 没有步骤也没有描述的标题会被忽略
+func ParseMarkdown(r io.Reader) ([]*domain.Task, error) {
+	var (
+		tasks   []*domain.Task
+		current *domain.Task
+		desc    []string
+	)
+
+	flush := func() {
+		if current == nil {
+			return
+		}
+		current.Description = strings.TrimSpace(strings.Join(desc, "\n"))
+		// 只有标题、没有任何内容的小节（通常是文档标题或分组标题）不作为任务
+		if len(current.Steps) > 0 || current.Description != "" {
+			tasks = append(tasks, current)
+		}
+		current, desc = nil, nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	inFence := false
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmed, "```") {
+			inFence = !inFence
+		}
+		if !inFence {
+			if title, ok := markdownHeading(trimmed); ok {
+				flush()
+				current = newTask(title)
+				continue
+			}
+			if title, done, ok := markdownCheckbox(trimmed); ok {
+				if current == nil {
+					t := newTask(title)
+					if done {
+						t.Status = "done"
+					}
+					tasks = append(tasks, t)
+					continue
+				}
+				appendStep(current, title, done)
+				continue
+			}
+		}
+		if current != nil {
+			desc = append(desc, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+
+	return tasks, nil
+}
+
+func markdownHeading(line string) (string, bool) {
+	if !strings.HasPrefix(line, "#") {
+		return "", false
+	}
+	level := len(line) - len(strings.TrimLeft(line, "#"))
+	if level > 6 {
+		return "", false
+	}
+	rest := line[level:]
+	if rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+		return "", false
+	}
+	title := strings.TrimSpace(strings.TrimRight(strings.TrimSpace(rest), "#"))
+	return title, title != ""
+}
+
+func markdownCheckbox(line string) (string, bool, bool) {
+	for _, bullet := range []string{"- ", "* ", "+ "} {
+		if !strings.HasPrefix(line, bullet) {
+			continue
+		}
+		rest := strings.TrimSpace(line[len(bullet):])
+		if !strings.HasPrefix(rest, "[ ]") && !strings.HasPrefix(rest, "[x]") && !strings.HasPrefix(rest, "[X]") {
+			return "", false, false
+		}
+		title, done := trimCheckbox(rest)
+		return title, done, title != ""
+	}
+	return "", false, false
+}
@@ -0,0 +1,111 @@
+package jsonschema
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Reflect 根据 Go 结构体生成参数 Schema，字段名取 json 标签：
+//   - 指针字段可以省略，也可以为 null；带 omitempty 的字段可以省略；其他字段必填
+//   - 对象不允许出现未声明的字段
+//   - description / enum（逗号分隔）/ minimum / maximum 标签写在字段上，切片字段的 enum 等约束作用于元素
+func Reflect(v any) *Schema {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return reflectType(t)
+}
+
+func reflectType(t reflect.Type) *Schema {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return reflectType(t.Elem())
+	case reflect.Bool:
+		return &Schema{Type: Types{"boolean"}}
+	case reflect.String:
+		return &Schema{Type: Types{"string"}}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Schema{Type: Types{"integer"}}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		// 负数无法解码到无符号整数
+		min := 0.0
+		return &Schema{Type: Types{"integer"}, Minimum: &min}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: Types{"number"}}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: Types{"array"}, Items: reflectType(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: Types{"object"}}
+	case reflect.Struct:
+		return reflectStruct(t)
+	}
+	panic(fmt.Sprintf("jsonschema: unsupported type %s", t))
+}
+
+func reflectStruct(t reflect.Type) *Schema {
+	closed := false
+	s := &Schema{Type: Types{"object"}, Properties: map[string]*Schema{}, AdditionalProperties: &closed}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		prop := reflectType(f.Type)
+		// 约束写在切片字段上时作用于元素
+		target := prop
+		if prop.Items != nil {
+			target = prop.Items
+		}
+		applyTags(f, prop, target)
+
+		optional := strings.Contains(","+opts+",", ",omitempty,")
+		if f.Type.Kind() == reflect.Pointer {
+			optional = true
+			prop.Type = append(prop.Type, "null")
+			if len(prop.Enum) > 0 {
+				prop.Enum = append(prop.Enum, nil)
+			}
+		}
+		if !optional {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = prop
+	}
+	return s
+}
+
+func applyTags(f reflect.StructField, prop, target *Schema) {
+	if desc := f.Tag.Get("description"); desc != "" {
+		prop.Description = desc
+	}
+	if enum := f.Tag.Get("enum"); enum != "" {
+		for _, e := range strings.Split(enum, ",") {
+			target.Enum = append(target.Enum, strings.TrimSpace(e))
+		}
+	}
+	if v := f.Tag.Get("minimum"); v != "" {
+		target.Minimum = mustFloat(f, v)
+	}
+	if v := f.Tag.Get("maximum"); v != "" {
+		target.Maximum = mustFloat(f, v)
+	}
+}
+
+func mustFloat(f reflect.StructField, v string) *float64 {
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		panic(fmt.Sprintf("jsonschema: field %s: invalid number %q", f.Name, v))
+	}
+	return &n
+}
@@ -0,0 +1,249 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	anthropicDefaultBaseURL   = "https://api.anthropic.com"
+	anthropicVersion          = "2023-06-01"
+	anthropicDefaultMaxTokens = 4096
+)
+
+type anthropicRequest struct {
+	Model       string             `json:"model"`
+	System      string             `json:"system,omitempty"`
+	Messages    []anthropicMessage `json:"messages"`
+	MaxTokens   int                `json:"max_tokens"`
+	Temperature *float64           `json:"temperature,omitempty"`
+	Tools       []anthropicTool    `json:"tools,omitempty"`
+	ToolChoice  *anthropicChoice   `json:"tool_choice,omitempty"`
+	Thinking    *anthropicThinking `json:"thinking,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string           `json:"role"` // user, assistant
+	Content []anthropicBlock `json:"content"`
+}
+
+type anthropicBlock struct {
+	Type      string          `json:"type"` // text, tool_use, tool_result, thinking
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+}
+
+type anthropicTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+type anthropicChoice struct {
+	Type string `json:"type"` // auto, any, none
+}
+
+type anthropicThinking struct {
+	Type         string `json:"type"` // enabled
+	BudgetTokens int    `json:"budget_tokens"`
+}
+
+type anthropicResponse struct {
+	Model      string           `json:"model"`
+	Content    []anthropicBlock `json:"content"`
+	StopReason string           `json:"stop_reason"`
+	Usage      struct {
+		InputTokens              int64 `json:"input_tokens"`
+		OutputTokens             int64 `json:"output_tokens"`
+		CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
+		CacheReadInputTokens     int64 `json:"cache_read_input_tokens"`
+	} `json:"usage"`
+}
+
+// chatAnthropic 通过 Anthropic Messages API 调用
+func (c *HTTPClient) chatAnthropic(ctx context.Context, cfg Config, req ChatRequest) (*ChatResponse, error) {
+	body, err := buildAnthropicRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	base := strings.TrimRight(cfg.BaseURL, "/")
+	if base == "" {
+		base = anthropicDefaultBaseURL
+	}
+	if !strings.HasSuffix(base, "/v1") {
+		base += "/v1"
+	}
+	headers := map[string]string{
+		"x-api-key":         cfg.APIKey,
+		"anthropic-version": anthropicVersion,
+	}
+
+	var resp anthropicResponse
+	if err := postJSON(ctx, c.httpClient, base+"/messages", headers, body, &resp, anthropicErrorMessage); err != nil {
+		return nil, err
+	}
+	return fromAnthropicResponse(&resp), nil
+}
+
+func buildAnthropicRequest(req ChatRequest) (*anthropicRequest, error) {
+	if req.ResponseFormat != nil {
+		return nil, fmt.Errorf("response format is not supported by the anthropic protocol, use a forced tool call instead")
+	}
+	out := &anthropicRequest{Model: req.Model, MaxTokens: req.MaxTokens}
+	if out.MaxTokens <= 0 {
+		out.MaxTokens = anthropicDefaultMaxTokens
+	}
+
+	var system []string
+	for _, msg := range req.Messages {
+		var role string
+		var blocks []anthropicBlock
+		switch msg.Role {
+		case "system":
+			if msg.Content != "" {
+				system = append(system, msg.Content)
+			}
+			continue
+		case "user":
+			role = "user"
+			if msg.Content != "" {
+				blocks = append(blocks, anthropicBlock{Type: "text", Text: msg.Content})
+			}
+		case "assistant":
+			role = "assistant"
+			if msg.Content != "" {
+				blocks = append(blocks, anthropicBlock{Type: "text", Text: msg.Content})
+			}
+			for _, call := range msg.ToolCalls {
+				args, err := toolCallArgs(call.Function.Arguments)
+				if err != nil {
+					return nil, err
+				}
+				blocks = append(blocks, anthropicBlock{Type: "tool_use", ID: call.ID, Name: call.Function.Name, Input: args})
+			}
+		case "tool":
+			if msg.ToolCallID == "" {
+				return nil, fmt.Errorf("tool message missing tool_call_id")
+			}
+			// 工具结果以 user 消息中的 tool_result 块返回
+			role = "user"
+			blocks = append(blocks, anthropicBlock{Type: "tool_result", ToolUseID: msg.ToolCallID, Content: msg.Content})
+		default:
+			return nil, fmt.Errorf("unsupported message role: %s", msg.Role)
+		}
+		if len(blocks) == 0 {
+			continue
+		}
+		// 连续的同角色消息合并为一条（例如多个工具结果）
+		if n := len(out.Messages); n > 0 && out.Messages[n-1].Role == role {
+			out.Messages[n-1].Content = append(out.Messages[n-1].Content, blocks...)
+			continue
+		}
+		out.Messages = append(out.Messages, anthropicMessage{Role: role, Content: blocks})
+	}
+	out.System = strings.Join(system, "\n\n")
+
+	for _, tool := range req.Tools {
+		if tool.Type != "" && tool.Type != "function" {
+			return nil, fmt.Errorf("unsupported tool type: %s", tool.Type)
+		}
+		schema := tool.Function.Parameters
+		if len(schema) == 0 {
+			schema = json.RawMessage(`{"type":"object","properties":{}}`)
+		}
+		out.Tools = append(out.Tools, anthropicTool{Name: tool.Function.Name, Description: tool.Function.Description, InputSchema: schema})
+	}
+	if len(out.Tools) > 0 {
+		switch req.ToolChoice {
+		case "none":
+			out.ToolChoice = &anthropicChoice{Type: "none"}
+		case "required":
+			out.ToolChoice = &anthropicChoice{Type: "any"}
+		case "", "auto":
+			out.ToolChoice = &anthropicChoice{Type: "auto"}
+		}
+	}
+
+	// 开启思考时，带工具调用的 assistant 消息必须附带原始的 thinking 块（含签名），
+	// ChatMessage 不保留这些内容，所以工具调用后的后续请求不再开启思考
+	if budget := thinkingBudget(req.ReasoningEffort); thinkingEnabled(req) && budget > 0 && !hasToolCalls(req.Messages) {
+		out.Thinking = &anthropicThinking{Type: "enabled", BudgetTokens: budget}
+		// max_tokens 必须大于思考预算；开启思考时不能设置 temperature
+		if out.MaxTokens <= budget {
+			out.MaxTokens = budget + anthropicDefaultMaxTokens
+		}
+	} else if req.Temperature != 0 {
+		t := req.Temperature
+		out.Temperature = &t
+	}
+	return out, nil
+}
+
+func hasToolCalls(messages []Message) bool {
+	for _, msg := range messages {
+		if len(msg.ToolCalls) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func fromAnthropicResponse(resp *anthropicResponse) *ChatResponse {
+	msg := ChatMessage{Role: "assistant"}
+	var texts []string
+	for _, block := range resp.Content {
+		switch block.Type {
+		case "text":
+			texts = append(texts, block.Text)
+		case "tool_use":
+			args := string(block.Input)
+			if args == "" {
+				args = "{}"
+			}
+			msg.ToolCalls = append(msg.ToolCalls, ToolCall{
+				ID:       block.ID,
+				Type:     "function",
+				Function: ToolCallFunc{Name: block.Name, Arguments: args},
+			})
+		}
+	}
+	msg.Content = strings.Join(texts, "")
+
+	finishReason := resp.StopReason
+	switch resp.StopReason {
+	case "end_turn", "stop_sequence":
+		finishReason = "stop"
+	case "tool_use":
+		finishReason = "tool_calls"
+	case "max_tokens":
+		finishReason = "length"
+	}
+
+	prompt := resp.Usage.InputTokens + resp.Usage.CacheCreationInputTokens + resp.Usage.CacheReadInputTokens
+	usage := Usage{
+		PromptTokens:     prompt,
+		CompletionTokens: resp.Usage.OutputTokens,
+		TotalTokens:      prompt + resp.Usage.OutputTokens,
+	}
+	return newChoiceResponse(msg, finishReason, usage)
+}
+
+func anthropicErrorMessage(data []byte) string {
+	var body struct {
+		Error struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(data, &body) != nil || body.Error.Message == "" {
+		return ""
+	}
+	return body.Error.Type + ": " + body.Error.Message
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/openai/openai-go"
 	"github.com/openai/openai-go/option"
+	"github.com/openai/openai-go/shared"
 	"go.uber.org/zap"
 )
 
@@ -36,6 +37,9 @@ type ChatRequest struct {
 	ThinkingType    string    `json:"thinking_type,omitempty"`    // disabled, enabled, auto
 	ReasoningEffort string    `json:"reasoning_effort,omitempty"` // low, medium, high, minimal
 	EnableThinking  bool      `json:"enable_thinking,omitempty"`  // true/false, 用于某些 API 提供商
+
+	// ResponseFormat 要求模型按 JSON Schema 输出，只在 SupportsJSONSchema 为 true 的协议上使用
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
 }
 
 type Tool struct {
@@ -73,6 +77,14 @@ type ChatResponse struct {
 		Message      ChatMessage `json:"message"`
 		FinishReason string      `json:"finish_reason"`
 	} `json:"choices"`
+
+	// Usage 本次调用消耗的 token 数
+	Usage Usage `json:"usage"`
+
+	// 以下字段由 ResilientClient 填充：实际使用的提供商、模型以及总尝试次数
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+	Attempts int    `json:"attempts,omitempty"`
 }
 
 type Client interface {
@@ -81,12 +93,19 @@ type Client interface {
 
 type HTTPClient struct {
 	httpClient *http.Client
+	maxRetries *int // SDK 内置重试次数，为空时使用 SDK 默认值
 }
 
 func NewHTTPClient() *HTTPClient {
 	return &HTTPClient{httpClient: &http.Client{}}
 }
 
+// WithMaxRetries 设置 SDK 内置的重试次数；由 ResilientClient 包装时应设为 0
+func (c *HTTPClient) WithMaxRetries(n int) *HTTPClient {
+	c.maxRetries = &n
+	return c
+}
+
 func (c *HTTPClient) Chat(ctx context.Context, cfg Config, req ChatRequest) (*ChatResponse, error) {
 	startTime := time.Now()
 
@@ -105,7 +124,9 @@ func (c *HTTPClient) Chat(ctx context.Context, cfg Config, req ChatRequest) (*Ch
 		req.EnableThinking = cfg.EnableThinking
 	}
 
+	protocol := normalizeProtocol(cfg.Protocol)
 	logger.Logger.Debug("LLM Client Chat请求开始",
+		zap.String("protocol", protocol),
 		zap.String("model", req.Model),
 		zap.String("base_url", cfg.BaseURL),
 		zap.Int("messages_count", len(req.Messages)),
@@ -116,19 +137,24 @@ func (c *HTTPClient) Chat(ctx context.Context, cfg Config, req ChatRequest) (*Ch
 		zap.Bool("enable_thinking", req.EnableThinking),
 	)
 
-	params, err := buildChatParams(req)
-	if err != nil {
-		logger.Logger.Error("构建Chat请求参数失败",
-			zap.String("error", err.Error()),
-		)
-		return nil, err
+	var chatResp *ChatResponse
+	var err error
+	switch protocol {
+	case ProtocolAnthropic:
+		chatResp, err = c.chatAnthropic(ctx, cfg, req)
+	case ProtocolGemini:
+		chatResp, err = c.chatGemini(ctx, cfg, req)
+	case ProtocolOllama:
+		chatResp, err = c.chatOllama(ctx, cfg, req)
+	case ProtocolOpenAI:
+		chatResp, err = c.chatOpenAI(ctx, cfg, req)
+	default:
+		err = fmt.Errorf("unsupported llm protocol: %s", protocol)
 	}
-
-	client := newOpenAIClient(cfg, c.httpClient)
-	resp, err := client.Chat.Completions.New(ctx, params)
 	if err != nil {
 		duration := time.Since(startTime)
 		logger.Logger.Error("LLM API调用失败",
+			zap.String("protocol", protocol),
 			zap.String("model", req.Model),
 			zap.String("error", err.Error()),
 			zap.Duration("duration", duration),
@@ -137,11 +163,13 @@ func (c *HTTPClient) Chat(ctx context.Context, cfg Config, req ChatRequest) (*Ch
 	}
 
 	duration := time.Since(startTime)
-	chatResp := fromOpenAIChatResponse(resp)
 
 	logger.Logger.Info("LLM API调用成功",
+		zap.String("protocol", protocol),
 		zap.String("model", req.Model),
 		zap.Int("choices_count", len(chatResp.Choices)),
+		zap.Int64("prompt_tokens", chatResp.Usage.PromptTokens),
+		zap.Int64("completion_tokens", chatResp.Usage.CompletionTokens),
 		zap.Duration("duration", duration),
 	)
 
@@ -171,7 +199,22 @@ func (c *HTTPClient) Chat(ctx context.Context, cfg Config, req ChatRequest) (*Ch
 	return chatResp, nil
 }
 
-func newOpenAIClient(cfg Config, httpClient *http.Client) openai.Client {
+// chatOpenAI 通过 OpenAI 兼容的 chat completions 接口调用
+func (c *HTTPClient) chatOpenAI(ctx context.Context, cfg Config, req ChatRequest) (*ChatResponse, error) {
+	params, err := buildChatParams(req)
+	if err != nil {
+		return nil, err
+	}
+
+	client := newOpenAIClient(cfg, c.httpClient, c.maxRetries)
+	resp, err := client.Chat.Completions.New(ctx, params)
+	if err != nil {
+		return nil, wrapOpenAIError(err)
+	}
+	return fromOpenAIChatResponse(resp), nil
+}
+
+func newOpenAIClient(cfg Config, httpClient *http.Client, maxRetries *int) openai.Client {
 	opts := []option.RequestOption{}
 	if cfg.APIKey != "" {
 		opts = append(opts, option.WithAPIKey(cfg.APIKey))
@@ -182,6 +225,9 @@ func newOpenAIClient(cfg Config, httpClient *http.Client) openai.Client {
 	if httpClient != nil {
 		opts = append(opts, option.WithHTTPClient(httpClient))
 	}
+	if maxRetries != nil {
+		opts = append(opts, option.WithMaxRetries(*maxRetries))
+	}
 	return openai.NewClient(opts...)
 }
 
@@ -215,6 +261,21 @@ func buildChatParams(req ChatRequest) (openai.ChatCompletionNewParams, error) {
 		}
 	}
 
+	if req.ResponseFormat != nil {
+		schema, err := req.ResponseFormat.schemaObject()
+		if err != nil {
+			return openai.ChatCompletionNewParams{}, err
+		}
+		jsonSchema := shared.ResponseFormatJSONSchemaJSONSchemaParam{
+			Name:   req.ResponseFormat.Name,
+			Schema: schema,
+			Strict: openai.Bool(req.ResponseFormat.Strict),
+		}
+		params.ResponseFormat = openai.ChatCompletionNewParamsResponseFormatUnion{
+			OfJSONSchema: &shared.ResponseFormatJSONSchemaParam{JSONSchema: jsonSchema},
+		}
+	}
+
 	// 处理 reasoning_effort 参数（SDK 原生支持）
 	// 支持 o1 系列模型的推理深度设置: low, medium, high
 	if req.ReasoningEffort != "" {
@@ -392,7 +453,7 @@ func fromOpenAIChatResponse(resp *openai.ChatCompletion) *ChatResponse {
 		})
 	}
 
-	return &ChatResponse{Choices: choices}
+	return &ChatResponse{Choices: choices, Usage: fromOpenAIUsage(resp.Usage)}
 }
 
 func fromOpenAIToolCalls(calls []openai.ChatCompletionMessageToolCall) []ToolCall {
@@ -0,0 +1,76 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/openai/openai-go"
+)
+
+// APIError 带 HTTP 状态码的提供商错误，供重试策略判断是否可以重试
+type APIError struct {
+	StatusCode int
+	RetryAfter time.Duration // 提供商通过 Retry-After 要求的等待时间，没有时为 0
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("llm api error: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// Retryable 429 和 5xx 可以重试，其他 4xx 说明请求本身有问题
+func (e *APIError) Retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// wrapOpenAIError 把 SDK 的错误转换为 APIError，其他错误原样返回
+func wrapOpenAIError(err error) error {
+	var apiErr *openai.Error
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	out := &APIError{StatusCode: apiErr.StatusCode, Message: apiErr.Message}
+	if out.Message == "" {
+		out.Message = apiErr.RawJSON()
+	}
+	if apiErr.Response != nil {
+		out.RetryAfter = parseRetryAfter(apiErr.Response.Header.Get("Retry-After"), time.Now())
+	}
+	return out
+}
+
+// parseRetryAfter 解析 Retry-After（秒数或 HTTP 日期），无法解析时返回 0
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// isRetryable 判断一次调用失败后是否值得重试
+// parent 是调用方的 context：调用方自己取消或超时不重试，只有单次调用超时才重试
+func isRetryable(parent context.Context, err error) bool {
+	if parent.Err() != nil {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Retryable()
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,307 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	geminiDefaultBaseURL = "https://generativelanguage.googleapis.com"
+	geminiDefaultVersion = "v1beta"
+)
+
+type geminiRequest struct {
+	SystemInstruction *geminiContent          `json:"systemInstruction,omitempty"`
+	Contents          []geminiContent         `json:"contents"`
+	Tools             []geminiTool            `json:"tools,omitempty"`
+	ToolConfig        *geminiToolConfig       `json:"toolConfig,omitempty"`
+	GenerationConfig  *geminiGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"` // user, model
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiPart struct {
+	Text             string                  `json:"text,omitempty"`
+	Thought          bool                    `json:"thought,omitempty"`
+	FunctionCall     *geminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *geminiFunctionResponse `json:"functionResponse,omitempty"`
+}
+
+type geminiFunctionCall struct {
+	ID   string          `json:"id,omitempty"`
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+type geminiFunctionResponse struct {
+	ID       string          `json:"id,omitempty"`
+	Name     string          `json:"name"`
+	Response json.RawMessage `json:"response"`
+}
+
+type geminiTool struct {
+	FunctionDeclarations []geminiFunctionDeclaration `json:"functionDeclarations"`
+}
+
+type geminiFunctionDeclaration struct {
+	Name                 string          `json:"name"`
+	Description          string          `json:"description,omitempty"`
+	ParametersJSONSchema json.RawMessage `json:"parametersJsonSchema,omitempty"`
+}
+
+type geminiToolConfig struct {
+	FunctionCallingConfig struct {
+		Mode string `json:"mode"` // AUTO, ANY, NONE
+	} `json:"functionCallingConfig"`
+}
+
+type geminiGenerationConfig struct {
+	Temperature     *float64              `json:"temperature,omitempty"`
+	MaxOutputTokens int                   `json:"maxOutputTokens,omitempty"`
+	ThinkingConfig  *geminiThinkingConfig `json:"thinkingConfig,omitempty"`
+
+	ResponseMimeType   string          `json:"responseMimeType,omitempty"`
+	ResponseJSONSchema json.RawMessage `json:"responseJsonSchema,omitempty"`
+}
+
+type geminiThinkingConfig struct {
+	ThinkingBudget int `json:"thinkingBudget"` // 0 关闭思考
+}
+
+type geminiResponse struct {
+	Candidates []struct {
+		Content      geminiContent `json:"content"`
+		FinishReason string        `json:"finishReason"`
+	} `json:"candidates"`
+	UsageMetadata struct {
+		PromptTokenCount     int64 `json:"promptTokenCount"`
+		CandidatesTokenCount int64 `json:"candidatesTokenCount"`
+		ThoughtsTokenCount   int64 `json:"thoughtsTokenCount"`
+		TotalTokenCount      int64 `json:"totalTokenCount"`
+	} `json:"usageMetadata"`
+}
+
+// chatGemini 通过 Gemini generateContent 接口调用
+// BaseURL 不带版本号时使用 v1beta，例如 https://generativelanguage.googleapis.com
+func (c *HTTPClient) chatGemini(ctx context.Context, cfg Config, req ChatRequest) (*ChatResponse, error) {
+	body, err := buildGeminiRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	base := strings.TrimRight(cfg.BaseURL, "/")
+	if base == "" {
+		base = geminiDefaultBaseURL
+	}
+	if last := base[strings.LastIndex(base, "/")+1:]; !strings.HasPrefix(last, "v1") {
+		base += "/" + geminiDefaultVersion
+	}
+	endpoint := fmt.Sprintf("%s/models/%s:generateContent", base, url.PathEscape(strings.TrimPrefix(req.Model, "models/")))
+	headers := map[string]string{"x-goog-api-key": cfg.APIKey}
+
+	var resp geminiResponse
+	if err := postJSON(ctx, c.httpClient, endpoint, headers, body, &resp, geminiErrorMessage); err != nil {
+		return nil, err
+	}
+	return fromGeminiResponse(&resp), nil
+}
+
+func buildGeminiRequest(req ChatRequest) (*geminiRequest, error) {
+	out := &geminiRequest{}
+	toolNames := toolNamesByCallID(req.Messages)
+
+	var system []geminiPart
+	for _, msg := range req.Messages {
+		var role string
+		var parts []geminiPart
+		switch msg.Role {
+		case "system":
+			if msg.Content != "" {
+				system = append(system, geminiPart{Text: msg.Content})
+			}
+			continue
+		case "user":
+			role = "user"
+			if msg.Content != "" {
+				parts = append(parts, geminiPart{Text: msg.Content})
+			}
+		case "assistant":
+			role = "model"
+			if msg.Content != "" {
+				parts = append(parts, geminiPart{Text: msg.Content})
+			}
+			for _, call := range msg.ToolCalls {
+				args, err := toolCallArgs(call.Function.Arguments)
+				if err != nil {
+					return nil, err
+				}
+				parts = append(parts, geminiPart{FunctionCall: &geminiFunctionCall{ID: call.ID, Name: call.Function.Name, Args: args}})
+			}
+		case "tool":
+			// Gemini 按函数名关联工具结果，结果必须是 JSON 对象
+			name := msg.Name
+			if name == "" {
+				name = toolNames[msg.ToolCallID]
+			}
+			if name == "" {
+				return nil, fmt.Errorf("tool message %q has no matching tool call", msg.ToolCallID)
+			}
+			role = "user"
+			parts = append(parts, geminiPart{FunctionResponse: &geminiFunctionResponse{ID: msg.ToolCallID, Name: name, Response: geminiToolResult(msg.Content)}})
+		default:
+			return nil, fmt.Errorf("unsupported message role: %s", msg.Role)
+		}
+		if len(parts) == 0 {
+			continue
+		}
+		if n := len(out.Contents); n > 0 && out.Contents[n-1].Role == role {
+			out.Contents[n-1].Parts = append(out.Contents[n-1].Parts, parts...)
+			continue
+		}
+		out.Contents = append(out.Contents, geminiContent{Role: role, Parts: parts})
+	}
+	if len(system) > 0 {
+		out.SystemInstruction = &geminiContent{Parts: system}
+	}
+
+	var decls []geminiFunctionDeclaration
+	for _, tool := range req.Tools {
+		if tool.Type != "" && tool.Type != "function" {
+			return nil, fmt.Errorf("unsupported tool type: %s", tool.Type)
+		}
+		decls = append(decls, geminiFunctionDeclaration{
+			Name:                 tool.Function.Name,
+			Description:          tool.Function.Description,
+			ParametersJSONSchema: tool.Function.Parameters,
+		})
+	}
+	if len(decls) > 0 {
+		out.Tools = []geminiTool{{FunctionDeclarations: decls}}
+		mode := ""
+		switch req.ToolChoice {
+		case "none":
+			mode = "NONE"
+		case "required":
+			mode = "ANY"
+		case "auto":
+			mode = "AUTO"
+		}
+		if mode != "" {
+			out.ToolConfig = &geminiToolConfig{}
+			out.ToolConfig.FunctionCallingConfig.Mode = mode
+		}
+	}
+
+	gen := &geminiGenerationConfig{MaxOutputTokens: req.MaxTokens}
+	if req.Temperature != 0 {
+		t := req.Temperature
+		gen.Temperature = &t
+	}
+	// auto 时不设置，由模型自行决定；显式关闭时预算为 0
+	if thinkingEnabled(req) {
+		gen.ThinkingConfig = &geminiThinkingConfig{ThinkingBudget: thinkingBudget(req.ReasoningEffort)}
+	} else if req.ThinkingType == "disabled" {
+		gen.ThinkingConfig = &geminiThinkingConfig{ThinkingBudget: 0}
+	}
+	if req.ResponseFormat != nil {
+		gen.ResponseMimeType = "application/json"
+		gen.ResponseJSONSchema = req.ResponseFormat.Schema
+	}
+	if gen.Temperature != nil || gen.MaxOutputTokens > 0 || gen.ThinkingConfig != nil || gen.ResponseMimeType != "" {
+		out.GenerationConfig = gen
+	}
+	return out, nil
+}
+
+// geminiToolResult 工具结果不是 JSON 对象时包装为 {"result": ...}
+func geminiToolResult(content string) json.RawMessage {
+	trimmed := strings.TrimSpace(content)
+	if strings.HasPrefix(trimmed, "{") && json.Valid([]byte(trimmed)) {
+		return json.RawMessage(trimmed)
+	}
+	var result any = content
+	if json.Valid([]byte(trimmed)) && trimmed != "" {
+		result = json.RawMessage(trimmed)
+	}
+	wrapped, _ := json.Marshal(map[string]any{"result": result})
+	return wrapped
+}
+
+func fromGeminiResponse(resp *geminiResponse) *ChatResponse {
+	msg := ChatMessage{Role: "assistant"}
+	finishReason := ""
+	if len(resp.Candidates) > 0 {
+		cand := resp.Candidates[0]
+		var texts []string
+		for _, part := range cand.Content.Parts {
+			switch {
+			case part.FunctionCall != nil:
+				id := part.FunctionCall.ID
+				if id == "" {
+					// 旧版本接口不返回调用 ID，按顺序生成
+					id = fmt.Sprintf("call_%d", len(msg.ToolCalls))
+				}
+				args := string(part.FunctionCall.Args)
+				if args == "" {
+					args = "{}"
+				}
+				msg.ToolCalls = append(msg.ToolCalls, ToolCall{
+					ID:       id,
+					Type:     "function",
+					Function: ToolCallFunc{Name: part.FunctionCall.Name, Arguments: args},
+				})
+			case part.Thought:
+				// 思考摘要不作为回复内容
+			case part.Text != "":
+				texts = append(texts, part.Text)
+			}
+		}
+		msg.Content = strings.Join(texts, "")
+
+		switch cand.FinishReason {
+		case "STOP":
+			finishReason = "stop"
+		case "MAX_TOKENS":
+			finishReason = "length"
+		case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII":
+			finishReason = "content_filter"
+		default:
+			finishReason = strings.ToLower(cand.FinishReason)
+		}
+		if len(msg.ToolCalls) > 0 {
+			finishReason = "tool_calls"
+		}
+	}
+
+	meta := resp.UsageMetadata
+	usage := Usage{
+		PromptTokens: meta.PromptTokenCount,
+		// 与 OpenAI 一致：思考 token 计入 completion
+		CompletionTokens: meta.CandidatesTokenCount + meta.ThoughtsTokenCount,
+		ReasoningTokens:  meta.ThoughtsTokenCount,
+		TotalTokens:      meta.TotalTokenCount,
+	}
+	if usage.TotalTokens == 0 {
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	return newChoiceResponse(msg, finishReason, usage)
+}
+
+func geminiErrorMessage(data []byte) string {
+	var body struct {
+		Error struct {
+			Status  string `json:"status"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(data, &body) != nil || body.Error.Message == "" {
+		return ""
+	}
+	return body.Error.Status + ": " + body.Error.Message
+}
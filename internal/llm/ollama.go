@@ -0,0 +1,181 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const ollamaDefaultBaseURL = "http://localhost:11434"
+
+type ollamaRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Tools    []Tool          `json:"tools,omitempty"` // 与 OpenAI 的工具定义格式相同
+	Stream   bool            `json:"stream"`
+	Think    *bool           `json:"think,omitempty"`
+	Options  map[string]any  `json:"options,omitempty"`
+	Format   json.RawMessage `json:"format,omitempty"` // 结构化输出的 JSON Schema
+}
+
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	Thinking  string           `json:"thinking,omitempty"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"`
+}
+
+type ollamaToolCall struct {
+	ID       string `json:"id,omitempty"`
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+type ollamaResponse struct {
+	Model           string        `json:"model"`
+	Message         ollamaMessage `json:"message"`
+	DoneReason      string        `json:"done_reason"`
+	PromptEvalCount int64         `json:"prompt_eval_count"`
+	EvalCount       int64         `json:"eval_count"`
+}
+
+// chatOllama 通过 Ollama 原生的 /api/chat 接口调用（非流式）
+func (c *HTTPClient) chatOllama(ctx context.Context, cfg Config, req ChatRequest) (*ChatResponse, error) {
+	body, err := buildOllamaRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	base := strings.TrimRight(cfg.BaseURL, "/")
+	if base == "" {
+		base = ollamaDefaultBaseURL
+	}
+	base = strings.TrimSuffix(base, "/api")
+	headers := map[string]string{}
+	if cfg.APIKey != "" {
+		// 本地 Ollama 不需要密钥，经过反向代理时可能需要
+		headers["Authorization"] = "Bearer " + cfg.APIKey
+	}
+
+	var resp ollamaResponse
+	if err := postJSON(ctx, c.httpClient, base+"/api/chat", headers, body, &resp, ollamaErrorMessage); err != nil {
+		return nil, err
+	}
+	return fromOllamaResponse(&resp), nil
+}
+
+func buildOllamaRequest(req ChatRequest) (*ollamaRequest, error) {
+	out := &ollamaRequest{Model: req.Model, Stream: false}
+	toolNames := toolNamesByCallID(req.Messages)
+
+	for _, msg := range req.Messages {
+		m := ollamaMessage{Role: msg.Role, Content: msg.Content}
+		switch msg.Role {
+		case "system", "user":
+		case "assistant":
+			for _, call := range msg.ToolCalls {
+				args, err := toolCallArgs(call.Function.Arguments)
+				if err != nil {
+					return nil, err
+				}
+				var tc ollamaToolCall
+				tc.ID = call.ID
+				tc.Function.Name = call.Function.Name
+				tc.Function.Arguments = args
+				m.ToolCalls = append(m.ToolCalls, tc)
+			}
+		case "tool":
+			m.ToolName = msg.Name
+			if m.ToolName == "" {
+				m.ToolName = toolNames[msg.ToolCallID]
+			}
+		default:
+			return nil, fmt.Errorf("unsupported message role: %s", msg.Role)
+		}
+		out.Messages = append(out.Messages, m)
+	}
+
+	// Ollama 不支持 tool_choice，none 时不传工具
+	if req.ToolChoice != "none" {
+		for _, tool := range req.Tools {
+			if tool.Type != "" && tool.Type != "function" {
+				return nil, fmt.Errorf("unsupported tool type: %s", tool.Type)
+			}
+			tool.Type = "function"
+			out.Tools = append(out.Tools, tool)
+		}
+	}
+
+	if req.ResponseFormat != nil {
+		out.Format = req.ResponseFormat.Schema
+	}
+
+	// auto 时不设置，由模型自行决定
+	if thinkingEnabled(req) {
+		think := true
+		out.Think = &think
+	} else if req.ThinkingType == "disabled" {
+		think := false
+		out.Think = &think
+	}
+
+	options := map[string]any{}
+	if req.Temperature != 0 {
+		options["temperature"] = req.Temperature
+	}
+	if req.MaxTokens > 0 {
+		options["num_predict"] = req.MaxTokens
+	}
+	if len(options) > 0 {
+		out.Options = options
+	}
+	return out, nil
+}
+
+func fromOllamaResponse(resp *ollamaResponse) *ChatResponse {
+	msg := ChatMessage{Role: "assistant", Content: resp.Message.Content}
+	for i, call := range resp.Message.ToolCalls {
+		id := call.ID
+		if id == "" {
+			id = fmt.Sprintf("call_%d", i)
+		}
+		args := string(call.Function.Arguments)
+		if args == "" || args == "null" {
+			args = "{}"
+		}
+		msg.ToolCalls = append(msg.ToolCalls, ToolCall{
+			ID:       id,
+			Type:     "function",
+			Function: ToolCallFunc{Name: call.Function.Name, Arguments: args},
+		})
+	}
+
+	finishReason := resp.DoneReason
+	if finishReason == "" {
+		finishReason = "stop"
+	}
+	if len(msg.ToolCalls) > 0 {
+		finishReason = "tool_calls"
+	}
+
+	usage := Usage{
+		PromptTokens:     resp.PromptEvalCount,
+		CompletionTokens: resp.EvalCount,
+		TotalTokens:      resp.PromptEvalCount + resp.EvalCount,
+	}
+	return newChoiceResponse(msg, finishReason, usage)
+}
+
+func ollamaErrorMessage(data []byte) string {
+	var body struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(data, &body) != nil {
+		return ""
+	}
+	return body.Error
+}
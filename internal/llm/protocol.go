@@ -0,0 +1,131 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"assistant-qisumi/internal/domain"
+)
+
+// 接口协议，由 Config.Protocol 选择
+const (
+	ProtocolOpenAI    = domain.LLMProtocolOpenAI
+	ProtocolAnthropic = domain.LLMProtocolAnthropic
+	ProtocolGemini    = domain.LLMProtocolGemini
+	ProtocolOllama    = domain.LLMProtocolOllama
+)
+
+// normalizeProtocol 协议名统一为小写，为空时为 openai
+func normalizeProtocol(protocol string) string {
+	protocol = strings.ToLower(strings.TrimSpace(protocol))
+	if protocol == "" {
+		return ProtocolOpenAI
+	}
+	return protocol
+}
+
+// thinkingEnabled 是否显式开启了深度思考；auto 表示交给提供商决定
+func thinkingEnabled(req ChatRequest) bool {
+	return req.ThinkingType == "enabled" || (req.EnableThinking && req.ThinkingType != "disabled")
+}
+
+// thinkingBudget 按思考强度换算成 token 预算（Anthropic / Gemini 使用预算而不是强度）
+func thinkingBudget(effort string) int {
+	switch effort {
+	case "minimal":
+		return 0
+	case "low":
+		return 1024
+	case "high":
+		return 16384
+	default:
+		return 4096
+	}
+}
+
+// toolCallArgs 把 OpenAI 风格的字符串参数解析为 JSON 对象，其他协议要求参数是对象
+func toolCallArgs(arguments string) (json.RawMessage, error) {
+	arguments = strings.TrimSpace(arguments)
+	if arguments == "" {
+		return json.RawMessage("{}"), nil
+	}
+	if !json.Valid([]byte(arguments)) {
+		return nil, fmt.Errorf("tool call arguments are not valid JSON: %s", arguments)
+	}
+	return json.RawMessage(arguments), nil
+}
+
+// toolNamesByCallID 建立 tool_call_id -> 函数名的映射，用于只按名称关联工具结果的协议
+func toolNamesByCallID(messages []Message) map[string]string {
+	names := map[string]string{}
+	for _, msg := range messages {
+		for _, call := range msg.ToolCalls {
+			names[call.ID] = call.Function.Name
+		}
+	}
+	return names
+}
+
+// postJSON 发送 JSON 请求并解析响应；非 2xx 时返回 APIError，message 由 errMessage 从响应体中提取
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, body, out any, errMessage func([]byte) string) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("marshal request: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		httpReq.Header.Set(k, v)
+	}
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg := ""
+		if errMessage != nil {
+			msg = errMessage(data)
+		}
+		if msg == "" {
+			msg = strings.TrimSpace(string(data))
+		}
+		return &APIError{
+			StatusCode: resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+			Message:    msg,
+		}
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// newChoiceResponse 构造只有一个候选的响应
+func newChoiceResponse(msg ChatMessage, finishReason string, usage Usage) *ChatResponse {
+	resp := &ChatResponse{Usage: usage}
+	resp.Choices = append(resp.Choices, struct {
+		Message      ChatMessage `json:"message"`
+		FinishReason string      `json:"finish_reason"`
+	}{Message: msg, FinishReason: finishReason})
+	return resp
+}
@@ -0,0 +1,287 @@
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"assistant-qisumi/internal/logger"
+
+	"go.uber.org/zap"
+)
+
+// ErrFixtureNotFound 回放时找不到与请求匹配的录制结果
+var ErrFixtureNotFound = errors.New("llm fixture not found")
+
+// Fixture 一次录制的请求/响应，保存为 JSON 文件
+type Fixture struct {
+	Key        string        `json:"key"`
+	Seq        int           `json:"seq"` // 同一请求在一次录制中出现多次时的顺序
+	Agent      string        `json:"agent,omitempty"`
+	RecordedAt time.Time     `json:"recorded_at"`
+	Config     FixtureConfig `json:"config"`
+	Request    ChatRequest   `json:"request"`
+	Response   *ChatResponse `json:"response"`
+}
+
+// FixtureConfig 录制时使用的配置，不包含 API Key
+type FixtureConfig struct {
+	Protocol string `json:"protocol,omitempty"`
+	BaseURL  string `json:"base_url,omitempty"`
+	Model    string `json:"model,omitempty"`
+}
+
+const redacted = "[REDACTED]"
+
+// secretPatterns 常见的密钥格式，录制时即使出现在消息内容中也会被替换
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`sk-[A-Za-z0-9_\-]{16,}`),
+	regexp.MustCompile(`AIza[0-9A-Za-z_\-]{35}`),
+	regexp.MustCompile(`(?i)bearer\s+[A-Za-z0-9._\-]{16,}`),
+}
+
+// RecordingClient 把每次成功调用的请求和响应写入 dir 下的 fixture 文件，供 ReplayClient 离线回放
+type RecordingClient struct {
+	inner Client
+	dir   string
+
+	mu   sync.Mutex
+	seqs map[string]int
+}
+
+func NewRecordingClient(inner Client, dir string) *RecordingClient {
+	return &RecordingClient{inner: inner, dir: dir, seqs: map[string]int{}}
+}
+
+func (c *RecordingClient) Chat(ctx context.Context, cfg Config, req ChatRequest) (*ChatResponse, error) {
+	resp, err := c.inner.Chat(ctx, cfg, req)
+	if err != nil {
+		return nil, err
+	}
+	// 录制失败不影响本次调用
+	if path, err := c.record(ctx, cfg, req, resp); err != nil {
+		logger.Logger.Error("录制LLM调用失败",
+			zap.String("dir", c.dir),
+			zap.String("error", err.Error()),
+		)
+	} else {
+		logger.Logger.Debug("已录制LLM调用", zap.String("path", path))
+	}
+	return resp, nil
+}
+
+func (c *RecordingClient) record(ctx context.Context, cfg Config, req ChatRequest, resp *ChatResponse) (string, error) {
+	key := FixtureKey(req)
+	c.mu.Lock()
+	seq := c.seqs[key]
+	c.seqs[key]++
+	c.mu.Unlock()
+
+	agent := CallInfoFromContext(ctx).Agent
+	fixture := Fixture{
+		Key:        key,
+		Seq:        seq,
+		Agent:      agent,
+		RecordedAt: time.Now(),
+		Config:     FixtureConfig{Protocol: cfg.Protocol, BaseURL: cfg.BaseURL, Model: cfg.Model},
+		Request:    req,
+		Response:   resp,
+	}
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	data = scrubSecrets(data, cfg.APIKey)
+
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return "", err
+	}
+	name := key[:16]
+	if agent != "" {
+		name = agent + "-" + name
+	}
+	if seq > 0 {
+		name = fmt.Sprintf("%s-%d", name, seq)
+	}
+	path := filepath.Join(c.dir, name+".json")
+	return path, os.WriteFile(path, data, 0o644)
+}
+
+// scrubSecrets 替换录制内容中的 API Key 以及常见格式的密钥
+func scrubSecrets(data []byte, apiKey string) []byte {
+	s := string(data)
+	if apiKey != "" {
+		s = strings.ReplaceAll(s, apiKey, redacted)
+	}
+	for _, re := range secretPatterns {
+		s = re.ReplaceAllString(s, redacted)
+	}
+	return []byte(s)
+}
+
+// ReplayClient 按规范化后的请求内容返回录制的响应，不访问网络
+type ReplayClient struct {
+	mu       sync.Mutex
+	fixtures map[string][]*Fixture
+	next     map[string]int
+}
+
+// NewReplayClient 加载 dir 下的所有 fixture 文件
+func NewReplayClient(dir string) (*ReplayClient, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	c := &ReplayClient{fixtures: map[string][]*Fixture{}, next: map[string]int{}}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var fixture Fixture
+		if err := json.Unmarshal(data, &fixture); err != nil {
+			return nil, fmt.Errorf("parse fixture %s: %w", path, err)
+		}
+		if fixture.Response == nil {
+			return nil, fmt.Errorf("fixture %s has no response", path)
+		}
+		// 按当前的规范化规则重新计算，规则调整后旧的录制仍然可用
+		key := FixtureKey(fixture.Request)
+		c.fixtures[key] = append(c.fixtures[key], &fixture)
+	}
+	for _, list := range c.fixtures {
+		sort.SliceStable(list, func(i, j int) bool { return list[i].Seq < list[j].Seq })
+	}
+	return c, nil
+}
+
+// Chat 同一请求录制了多次时依次返回，用完后重复最后一次
+func (c *ReplayClient) Chat(ctx context.Context, cfg Config, req ChatRequest) (*ChatResponse, error) {
+	key := FixtureKey(req)
+
+	c.mu.Lock()
+	list := c.fixtures[key]
+	if len(list) == 0 {
+		c.mu.Unlock()
+		return nil, fmt.Errorf("%w: key %s, last user message %q", ErrFixtureNotFound, key[:16], lastUserContent(req.Messages))
+	}
+	i := c.next[key]
+	if i < len(list)-1 {
+		c.next[key]++
+	}
+	fixture := list[i]
+	c.mu.Unlock()
+
+	// 返回副本，避免调用方修改影响后续回放
+	data, err := json.Marshal(fixture.Response)
+	if err != nil {
+		return nil, err
+	}
+	var resp ChatResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func lastUserContent(messages []Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			return messages[i].Content
+		}
+	}
+	return ""
+}
+
+var (
+	timestampPattern  = regexp.MustCompile(`\d{4}-\d{2}-\d{2}([T ]\d{2}:\d{2}(:\d{2}(\.\d+)?)?(Z|[+-]\d{2}:?\d{2})?)?`)
+	whitespacePattern = regexp.MustCompile(`\s+`)
+)
+
+// normalizedRequest 参与匹配的请求内容
+type normalizedRequest struct {
+	Messages   []Message `json:"messages"`
+	Tools      []string  `json:"tools,omitempty"`
+	ToolChoice string    `json:"tool_choice,omitempty"`
+}
+
+// FixtureKey 计算请求的匹配键。规范化规则：
+//   - 系统消息不参与匹配（提示词经常调整，当前时间等也放在系统消息中）
+//   - 消息内容中的日期时间替换为占位符，空白字符合并
+//   - 工具调用 ID 按出现顺序重新编号，参数转为键有序的 JSON
+//   - 工具只比较名称；模型、温度、思考参数等不参与匹配
+func FixtureKey(req ChatRequest) string {
+	norm := normalizedRequest{ToolChoice: req.ToolChoice}
+	callIDs := map[string]string{}
+	callID := func(id string) string {
+		if _, ok := callIDs[id]; !ok {
+			callIDs[id] = fmt.Sprintf("call_%d", len(callIDs))
+		}
+		return callIDs[id]
+	}
+
+	for _, msg := range req.Messages {
+		if msg.Role == "system" {
+			continue
+		}
+		m := Message{Role: msg.Role, Content: normalizeContent(msg.Content), Name: msg.Name}
+		for _, call := range msg.ToolCalls {
+			// 模型偶尔输出不合法的参数，原样参与匹配
+			args, err := canonicalJSON(call.Function.Arguments)
+			if err != nil {
+				args = call.Function.Arguments
+			}
+			m.ToolCalls = append(m.ToolCalls, ToolCall{
+				ID:       callID(call.ID),
+				Type:     "function",
+				Function: ToolCallFunc{Name: call.Function.Name, Arguments: normalizeContent(args)},
+			})
+		}
+		if msg.ToolCallID != "" {
+			m.ToolCallID = callID(msg.ToolCallID)
+		}
+		norm.Messages = append(norm.Messages, m)
+	}
+	for _, tool := range req.Tools {
+		norm.Tools = append(norm.Tools, tool.Function.Name)
+	}
+	sort.Strings(norm.Tools)
+
+	data, _ := json.Marshal(norm)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func normalizeContent(s string) string {
+	if canonical, err := canonicalJSON(s); err == nil && strings.HasPrefix(strings.TrimSpace(s), "{") {
+		s = canonical
+	}
+	s = timestampPattern.ReplaceAllString(s, "<TIME>")
+	return strings.TrimSpace(whitespacePattern.ReplaceAllString(s, " "))
+}
+
+// canonicalJSON 重新序列化 JSON，使对象的键有序；空字符串视为 {}
+func canonicalJSON(s string) (string, error) {
+	if strings.TrimSpace(s) == "" {
+		return "{}", nil
+	}
+	var v any
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return "", fmt.Errorf("invalid tool call arguments: %w", err)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
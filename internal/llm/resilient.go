@@ -0,0 +1,283 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"assistant-qisumi/internal/logger"
+
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrCircuitOpen 提供商的熔断器处于打开状态，本次调用直接跳过
+	ErrCircuitOpen = errors.New("llm provider circuit breaker is open")
+	// ErrUnavailable 所有提供商都因自身问题（限流、5xx、超时、熔断、网络错误）调用失败
+	ErrUnavailable = errors.New("llm provider unavailable")
+)
+
+// ResilientOptions 重试、超时与熔断策略
+type ResilientOptions struct {
+	MaxAttempts      int           // 每个提供商的最大尝试次数（含首次），默认 3
+	BaseDelay        time.Duration // 指数退避的初始等待时间，默认 500ms
+	MaxDelay         time.Duration // 单次等待上限；Retry-After 超过该值时不再等待，直接切换提供商。默认 30s
+	CallTimeout      time.Duration // 单次调用超时，默认 120s；为负数时不设超时
+	FailureThreshold int           // 连续失败多少次后熔断，默认 5
+	Cooldown         time.Duration // 熔断后多久允许试探调用，默认 30s
+}
+
+// DefaultResilientOptions 默认策略
+func DefaultResilientOptions() ResilientOptions {
+	return ResilientOptions{
+		MaxAttempts:      3,
+		BaseDelay:        500 * time.Millisecond,
+		MaxDelay:         30 * time.Second,
+		CallTimeout:      120 * time.Second,
+		FailureThreshold: 5,
+		Cooldown:         30 * time.Second,
+	}
+}
+
+// ResilientClient 在另一个 Client 外层增加重试、单次调用超时、按 base URL 的熔断，
+// 以及提供商自身出问题（5xx、429、超时、熔断）时按 cfg.Fallbacks 顺序切换备用提供商。
+// 成功的响应上会记录实际使用的提供商（Provider / Model）和总尝试次数（Attempts）。
+// 被包装的 Client 自身不应再重试，否则重试次数会相乘。
+type ResilientClient struct {
+	inner Client
+	opts  ResilientOptions
+
+	mu       sync.Mutex
+	breakers map[string]*breaker
+}
+
+// NewResilientClient 创建带容错能力的 Client，opts 中为零的字段使用默认值
+func NewResilientClient(inner Client, opts ResilientOptions) *ResilientClient {
+	def := DefaultResilientOptions()
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = def.MaxAttempts
+	}
+	if opts.BaseDelay <= 0 {
+		opts.BaseDelay = def.BaseDelay
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = def.MaxDelay
+	}
+	if opts.CallTimeout == 0 {
+		opts.CallTimeout = def.CallTimeout
+	}
+	if opts.FailureThreshold <= 0 {
+		opts.FailureThreshold = def.FailureThreshold
+	}
+	if opts.Cooldown <= 0 {
+		opts.Cooldown = def.Cooldown
+	}
+	return &ResilientClient{inner: inner, opts: opts, breakers: map[string]*breaker{}}
+}
+
+func (c *ResilientClient) Chat(ctx context.Context, cfg Config, req ChatRequest) (*ChatResponse, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	attempts := 0
+	var lastErr error
+	for i, provider := range providerChain(cfg) {
+		providerReq := req
+		if i > 0 || providerReq.Model == "" {
+			// 备用提供商使用它自己的模型
+			providerReq.Model = provider.Model
+		}
+
+		br := c.breaker(provider.BaseURL)
+		for attempt := 1; attempt <= c.opts.MaxAttempts; attempt++ {
+			if !br.allow(time.Now()) {
+				lastErr = fmt.Errorf("%w: %s", ErrCircuitOpen, provider.BaseURL)
+				break
+			}
+
+			attempts++
+			resp, err := c.call(ctx, provider, providerReq)
+			if err == nil {
+				br.success()
+				resp.Provider = provider.BaseURL
+				resp.Model = providerReq.Model
+				resp.Attempts = attempts
+				return resp, nil
+			}
+			lastErr = err
+			if ctx.Err() != nil {
+				br.release()
+				return nil, err
+			}
+			if countsAsProviderFailure(ctx, err) {
+				br.failure(time.Now(), c.opts.FailureThreshold, c.opts.Cooldown)
+			} else {
+				// 提供商可以正常响应，只是请求本身有问题
+				br.success()
+			}
+
+			retryable := isRetryable(ctx, err)
+			logger.Logger.Warn("LLM调用失败",
+				zap.String("base_url", provider.BaseURL),
+				zap.String("model", providerReq.Model),
+				zap.Int("attempt", attempt),
+				zap.Bool("retryable", retryable),
+				zap.String("error", err.Error()),
+			)
+			if !countsAsProviderFailure(ctx, err) {
+				// 请求本身被拒绝（429 以外的 4xx），换提供商也无济于事；原样返回，
+				// 调用方可以据此降级（例如 IsResponseFormatRejected 后去掉 response_format 重试）
+				return nil, fmt.Errorf("llm request failed after %d attempts: %w", attempts, err)
+			}
+			if !retryable || attempt == c.opts.MaxAttempts {
+				break
+			}
+			delay, ok := c.retryDelay(err, attempt)
+			if !ok {
+				// 提供商要求等待太久，直接尝试下一个提供商
+				break
+			}
+			if err := sleep(ctx, delay); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if lastErr == nil {
+		lastErr = errors.New("no llm provider configured")
+	}
+	if errors.Is(lastErr, ErrCircuitOpen) || countsAsProviderFailure(ctx, lastErr) {
+		return nil, fmt.Errorf("%w after %d attempts: %w", ErrUnavailable, attempts, lastErr)
+	}
+	return nil, fmt.Errorf("llm request failed after %d attempts: %w", attempts, lastErr)
+}
+
+// call 执行一次带超时的调用
+func (c *ResilientClient) call(ctx context.Context, cfg Config, req ChatRequest) (*ChatResponse, error) {
+	if c.opts.CallTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.opts.CallTimeout)
+		defer cancel()
+	}
+	resp, err := c.inner.Chat(ctx, cfg, req)
+	if err == nil && resp == nil {
+		err = errors.New("llm returned nil response")
+	}
+	return resp, err
+}
+
+// retryDelay 计算下次重试前的等待时间：优先遵循 Retry-After，否则指数退避
+// 第二个返回值为 false 表示等待时间超过上限，不应在该提供商上继续重试
+func (c *ResilientClient) retryDelay(err error, attempt int) (time.Duration, bool) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		return apiErr.RetryAfter, apiErr.RetryAfter <= c.opts.MaxDelay
+	}
+	delay := c.opts.BaseDelay << (attempt - 1)
+	if delay > c.opts.MaxDelay || delay <= 0 {
+		delay = c.opts.MaxDelay
+	}
+	return delay, true
+}
+
+func (c *ResilientClient) breaker(baseURL string) *breaker {
+	key := strings.TrimRight(baseURL, "/")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, ok := c.breakers[key]
+	if !ok {
+		b = &breaker{}
+		c.breakers[key] = b
+	}
+	return b
+}
+
+// providerChain 返回主配置及其备用配置，按 base URL + 模型去重
+func providerChain(cfg Config) []Config {
+	chain := []Config{cfg}
+	seen := map[string]bool{cfg.BaseURL + "|" + cfg.Model: true}
+	for _, fb := range cfg.Fallbacks {
+		key := fb.BaseURL + "|" + fb.Model
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		fb.Fallbacks = nil
+		chain = append(chain, fb)
+	}
+	return chain
+}
+
+// countsAsProviderFailure 只有提供商自身的问题（5xx、429、超时、网络错误）才计入熔断
+func countsAsProviderFailure(parent context.Context, err error) bool {
+	if parent.Err() != nil {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Retryable()
+	}
+	return true
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// breaker 简单的熔断器：连续失败达到阈值后打开，冷却期过后放行一次试探调用，
+// 试探成功则关闭，失败则重新打开
+type breaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func (b *breaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.openUntil.IsZero() {
+		return true
+	}
+	if now.Before(b.openUntil) || b.probing {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.openUntil = time.Time{}
+	b.probing = false
+}
+
+// release 试探调用被调用方取消时，允许下一次试探
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+func (b *breaker) failure(now time.Time, threshold int, cooldown time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.probing || b.failures >= threshold {
+		b.openUntil = now.Add(cooldown)
+		b.probing = false
+	}
+}
@@ -0,0 +1,42 @@
+package llm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ResponseFormat 结构化输出：要求模型的回复内容是符合 Schema 的 JSON
+type ResponseFormat struct {
+	Name   string          `json:"name"` // 只能包含字母、数字、下划线和连字符
+	Schema json.RawMessage `json:"schema"`
+	Strict bool            `json:"strict,omitempty"`
+}
+
+func (f *ResponseFormat) schemaObject() (map[string]any, error) {
+	var schema map[string]any
+	if err := json.Unmarshal(f.Schema, &schema); err != nil {
+		return nil, fmt.Errorf("response format %s schema: %w", f.Name, err)
+	}
+	return schema, nil
+}
+
+// SupportsJSONSchema 该配置的协议是否支持 ResponseFormat；不支持时应改用强制工具调用
+func SupportsJSONSchema(cfg Config) bool {
+	switch normalizeProtocol(cfg.Protocol) {
+	case ProtocolOpenAI, ProtocolGemini, ProtocolOllama:
+		return true
+	}
+	return false
+}
+
+// IsResponseFormatRejected 提供商以 400 / 422 拒绝了请求，
+// OpenAI 兼容的服务不支持 json_schema 时通常如此，调用方可以改用工具调用重试
+func IsResponseFormatRejected(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.StatusCode == http.StatusBadRequest || apiErr.StatusCode == http.StatusUnprocessableEntity
+}
@@ -0,0 +1,26 @@
+package llm
+
+import "unicode"
+
+// messageOverheadTokens 每条消息除内容外的固定开销（角色、分隔符等）
+const messageOverheadTokens = 4
+
+// EstimateTokens 粗略估算文本的 token 数，不依赖具体模型的分词器：
+// 汉字、假名等按每字 1 个 token，其余字符按每 4 个字符 1 个 token。
+// 只用于决定上下文里放多少历史消息，实际用量以接口返回为准
+func EstimateTokens(text string) int {
+	cjk, other := 0, 0
+	for _, r := range text {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			cjk++
+		} else {
+			other++
+		}
+	}
+	return cjk + (other+3)/4
+}
+
+// EstimateMessageTokens 估算一条消息占用的 token 数
+func EstimateMessageTokens(role, content string) int {
+	return messageOverheadTokens + EstimateTokens(role) + EstimateTokens(content)
+}
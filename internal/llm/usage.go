@@ -0,0 +1,110 @@
+package llm
+
+import (
+	"context"
+
+	"assistant-qisumi/internal/logger"
+
+	"github.com/openai/openai-go"
+	"go.uber.org/zap"
+)
+
+// Usage 一次调用消耗的 token 数
+// ReasoningTokens 是 CompletionTokens 中用于深度思考的部分，不另外计入 TotalTokens
+type Usage struct {
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+	ReasoningTokens  int64 `json:"reasoning_tokens"`
+	TotalTokens      int64 `json:"total_tokens"`
+}
+
+func fromOpenAIUsage(u openai.CompletionUsage) Usage {
+	usage := Usage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		ReasoningTokens:  u.CompletionTokensDetails.ReasoningTokens,
+		TotalTokens:      u.TotalTokens,
+	}
+	if usage.TotalTokens == 0 {
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	return usage
+}
+
+// CallInfo 标识一次 LLM 调用属于哪个用户、会话和 agent，用于用量统计
+type CallInfo struct {
+	UserID    uint64
+	SessionID *uint64
+	Agent     string
+}
+
+type callInfoKey struct{}
+
+// WithCallInfo 在 context 中记录调用归属
+func WithCallInfo(ctx context.Context, info CallInfo) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, callInfoKey{}, info)
+}
+
+// WithAgent 只替换 context 中调用归属的 agent，保留用户和会话
+func WithAgent(ctx context.Context, agent string) context.Context {
+	info := CallInfoFromContext(ctx)
+	info.Agent = agent
+	return WithCallInfo(ctx, info)
+}
+
+// CallInfoFromContext 获取 context 中的调用归属，没有时返回零值
+func CallInfoFromContext(ctx context.Context) CallInfo {
+	if ctx == nil {
+		return CallInfo{}
+	}
+	info, _ := ctx.Value(callInfoKey{}).(CallInfo)
+	return info
+}
+
+// UsageRecorder 保存每次成功调用的用量
+type UsageRecorder interface {
+	RecordUsage(ctx context.Context, info CallInfo, cfg Config, resp *ChatResponse) error
+}
+
+// MeteredClient 在另一个 Client 外层记录每次成功调用的 token 用量。
+// 调用归属来自 context（WithCallInfo）；记录失败只打日志，不影响调用结果。
+type MeteredClient struct {
+	inner    Client
+	recorder UsageRecorder
+}
+
+// NewMeteredClient 创建记录用量的 Client
+func NewMeteredClient(inner Client, recorder UsageRecorder) *MeteredClient {
+	return &MeteredClient{inner: inner, recorder: recorder}
+}
+
+func (c *MeteredClient) Chat(ctx context.Context, cfg Config, req ChatRequest) (*ChatResponse, error) {
+	resp, err := c.inner.Chat(ctx, cfg, req)
+	if err != nil || resp == nil || c.recorder == nil {
+		return resp, err
+	}
+
+	if resp.Model == "" {
+		resp.Model = req.Model
+		if resp.Model == "" {
+			resp.Model = cfg.Model
+		}
+	}
+	if resp.Provider == "" {
+		resp.Provider = cfg.BaseURL
+	}
+
+	info := CallInfoFromContext(ctx)
+	if recErr := c.recorder.RecordUsage(context.WithoutCancel(ctx), info, cfg, resp); recErr != nil {
+		logger.Logger.Warn("记录LLM用量失败",
+			zap.Uint64("user_id", info.UserID),
+			zap.String("agent", info.Agent),
+			zap.String("model", resp.Model),
+			zap.String("error", recErr.Error()),
+		)
+	}
+	return resp, nil
+}
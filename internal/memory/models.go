@@ -0,0 +1,43 @@
+package memory
+
+import (
+	"errors"
+
+	"assistant-qisumi/internal/domain"
+)
+
+// 类型别名 - 引用 domain 包中的定义，避免循环依赖
+type Memory = domain.Memory
+
+// 记忆分类
+const (
+	CategoryPreference = "preference"
+	CategorySchedule   = "schedule"
+	CategoryPerson     = "person"
+	CategoryFact       = "fact"
+)
+
+// Categories 支持的记忆分类
+var Categories = []string{CategoryPreference, CategorySchedule, CategoryPerson, CategoryFact}
+
+// 记忆来源
+const (
+	SourceExtracted = "extracted"
+	SourceManual    = "manual"
+)
+
+// MaxContentLength 单条记忆的最大长度（按字符计）
+const MaxContentLength = 200
+
+var (
+	// ErrNotFound 记忆不存在或不属于该用户
+	ErrNotFound = errors.New("memory not found")
+	// ErrInvalidMemory 内容为空、过长或分类不支持
+	ErrInvalidMemory = errors.New("invalid memory")
+)
+
+// Input 创建或修改记忆的内容，Category 为空时为 fact
+type Input struct {
+	Content  string `json:"content"`
+	Category string `json:"category"`
+}
@@ -0,0 +1,66 @@
+package memory
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// List 列出用户的全部记忆，最近更新的在前
+func (r *Repository) List(ctx context.Context, userID uint64) ([]Memory, error) {
+	var memories []Memory
+	err := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("updated_at DESC, id DESC").
+		Find(&memories).Error
+	return memories, err
+}
+
+// Get 获取用户的某条记忆，不存在时返回 nil
+func (r *Repository) Get(ctx context.Context, userID, id uint64) (*Memory, error) {
+	var memories []Memory
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND id = ?", userID, id).
+		Limit(1).
+		Find(&memories).Error
+	if err != nil || len(memories) == 0 {
+		return nil, err
+	}
+	return &memories[0], nil
+}
+
+func (r *Repository) Create(ctx context.Context, m *Memory) error {
+	return r.db.WithContext(ctx).Create(m).Error
+}
+
+func (r *Repository) Save(ctx context.Context, m *Memory) error {
+	return r.db.WithContext(ctx).Save(m).Error
+}
+
+// UpdateExtracted 替换一条提取出的记忆，返回是否更新了记录；
+// 记忆已被删除或已被用户修改（来源变为手动）时不更新
+func (r *Repository) UpdateExtracted(ctx context.Context, m *Memory) (bool, error) {
+	res := r.db.WithContext(ctx).Model(&Memory{}).
+		Where("id = ? AND user_id = ? AND source = ?", m.ID, m.UserID, SourceExtracted).
+		Updates(map[string]interface{}{"content": m.Content, "category": m.Category, "session_id": m.SessionID})
+	return res.RowsAffected > 0, res.Error
+}
+
+// Delete 删除用户的某条记忆，返回是否删除了记录
+func (r *Repository) Delete(ctx context.Context, userID, id uint64) (bool, error) {
+	res := r.db.WithContext(ctx).Where("user_id = ? AND id = ?", userID, id).Delete(&Memory{})
+	return res.RowsAffected > 0, res.Error
+}
+
+// DeleteAll 删除用户的全部记忆
+func (r *Repository) DeleteAll(ctx context.Context, userID uint64) error {
+	return r.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&Memory{}).Error
+}
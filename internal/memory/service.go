@@ -0,0 +1,267 @@
+// Package memory 保存关于用户的长期记忆（事实和偏好）：对话结束后由 LLM 提取，
+// 也可以由用户手动添加、修改和删除；每次对话时按相关度挑选若干条提供给 agent。
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"assistant-qisumi/internal/domain"
+	"assistant-qisumi/internal/llm"
+	"assistant-qisumi/internal/prompts"
+
+	"gorm.io/gorm"
+)
+
+// minExtractInputLength 用户输入少于这么多字符时（例如「好的」「完成了」）不提取，避免无意义的 LLM 调用
+const minExtractInputLength = 6
+
+// maxExtractedPerTurn 每轮对话最多新增或更新的记忆条数
+const maxExtractedPerTurn = 5
+
+type Service struct {
+	repo      *Repository
+	llmClient llm.Client
+}
+
+// NewService 创建记忆服务，llmClient 为空时不提取
+func NewService(db *gorm.DB, llmClient llm.Client) *Service {
+	return &Service{repo: NewRepository(db), llmClient: llmClient}
+}
+
+// List 列出用户的全部记忆，最近更新的在前
+func (s *Service) List(ctx context.Context, userID uint64) ([]Memory, error) {
+	return s.repo.List(ctx, userID)
+}
+
+// normalizeInput 校验内容和分类，返回整理后的值
+func normalizeInput(in Input) (Input, error) {
+	in.Content = strings.TrimSpace(in.Content)
+	if in.Content == "" {
+		return in, fmt.Errorf("%w: content is required", ErrInvalidMemory)
+	}
+	if utf8.RuneCountInString(in.Content) > MaxContentLength {
+		return in, fmt.Errorf("%w: content is longer than %d characters", ErrInvalidMemory, MaxContentLength)
+	}
+	if in.Category == "" {
+		in.Category = CategoryFact
+	}
+	if !slices.Contains(Categories, in.Category) {
+		return in, fmt.Errorf("%w: unknown category %q, expected one of %s", ErrInvalidMemory, in.Category, strings.Join(Categories, ", "))
+	}
+	return in, nil
+}
+
+// Create 用户手动添加一条记忆
+func (s *Service) Create(ctx context.Context, userID uint64, in Input) (*Memory, error) {
+	in, err := normalizeInput(in)
+	if err != nil {
+		return nil, err
+	}
+	m := &Memory{UserID: userID, Content: in.Content, Category: in.Category, Source: SourceManual}
+	if err := s.repo.Create(ctx, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Update 修改一条记忆，修改后视为用户确认过的记忆
+func (s *Service) Update(ctx context.Context, userID, id uint64, in Input) (*Memory, error) {
+	in, err := normalizeInput(in)
+	if err != nil {
+		return nil, err
+	}
+	m, err := s.repo.Get(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, ErrNotFound
+	}
+	m.Content, m.Category, m.Source = in.Content, in.Category, SourceManual
+	if err := s.repo.Save(ctx, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Delete 删除一条记忆
+func (s *Service) Delete(ctx context.Context, userID, id uint64) error {
+	deleted, err := s.repo.Delete(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteAll 删除用户的全部记忆
+func (s *Service) DeleteAll(ctx context.Context, userID uint64) error {
+	return s.repo.DeleteAll(ctx, userID)
+}
+
+// Relevant 挑选与 query 最相关的至多 limit 条记忆。记忆不多时全部返回；
+// 否则按与 query 共有的词（英文单词、中文相邻两字）数量排序，相同时最近更新的在前
+func (s *Service) Relevant(ctx context.Context, userID uint64, query string, limit int) ([]Memory, error) {
+	memories, err := s.repo.List(ctx, userID)
+	if err != nil || len(memories) <= limit {
+		return memories, err
+	}
+
+	queryTerms := terms(query)
+	scores := make([]int, len(memories))
+	for i, m := range memories {
+		for t := range terms(m.Content) {
+			if queryTerms[t] {
+				scores[i]++
+			}
+		}
+	}
+	idx := make([]int, len(memories))
+	for i := range idx {
+		idx[i] = i
+	}
+	// memories 已按更新时间排序，稳定排序保留相同分数下的顺序
+	sort.SliceStable(idx, func(a, b int) bool { return scores[idx[a]] > scores[idx[b]] })
+
+	out := make([]Memory, 0, limit)
+	for _, i := range idx[:limit] {
+		out = append(out, memories[i])
+	}
+	return out, nil
+}
+
+// terms 把文本切成用于匹配的词：连续的字母数字（小写）和相邻的两个汉字
+func terms(text string) map[string]bool {
+	out := make(map[string]bool)
+	var word []rune
+	var prevHan rune
+	flush := func() {
+		if len(word) >= 2 {
+			out[strings.ToLower(string(word))] = true
+		}
+		word = word[:0]
+	}
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flush()
+			if prevHan != 0 {
+				out[string([]rune{prevHan, r})] = true
+			}
+			prevHan = r
+			continue
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word = append(word, r)
+		default:
+			flush()
+		}
+		prevHan = 0
+	}
+	flush()
+	return out
+}
+
+// extraction 模型返回的提取结果
+type extraction struct {
+	Memories []struct {
+		Content  string  `json:"content"`
+		Category string  `json:"category"`
+		Replaces *uint64 `json:"replaces"`
+	} `json:"memories"`
+}
+
+// Extract 从一轮对话中提取值得长期记住的用户事实和偏好并保存，返回新增或更新的记忆。
+// 新记忆与已有记忆内容相同时忽略；模型指出替换的已有记忆时原地更新，
+// 但只能替换提取出的记忆，用户手动添加或修改过的记忆不会被改动（此时作为新记忆保存）
+func (s *Service) Extract(ctx context.Context, userID uint64, sessionID *uint64, cfg llm.Config, userInput, assistantReply string) ([]Memory, error) {
+	if s.llmClient == nil || utf8.RuneCountInString(strings.TrimSpace(userInput)) < minExtractInputLength {
+		return nil, nil
+	}
+	existing, err := s.repo.List(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	b.WriteString("已经记住的内容：\n")
+	if len(existing) == 0 {
+		b.WriteString("（无）\n")
+	}
+	for _, m := range existing {
+		if m.Source == SourceManual {
+			fmt.Fprintf(&b, "#%d [%s] (用户手动记录，不能替换) %s\n", m.ID, m.Category, m.Content)
+			continue
+		}
+		fmt.Fprintf(&b, "#%d [%s] %s\n", m.ID, m.Category, m.Content)
+	}
+	fmt.Fprintf(&b, "\n最新一轮对话：\n用户：%s\n助手：%s", userInput, assistantReply)
+
+	resp, err := s.llmClient.Chat(ctx, cfg, llm.ChatRequest{
+		Model: cfg.Model,
+		Messages: []llm.Message{
+			{Role: "system", Content: prompts.MemoryExtractionSystemPrompt},
+			{Role: "user", Content: b.String()},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("llm returned empty response")
+	}
+	var out extraction
+	if err := json.Unmarshal([]byte(domain.ExtractJSON(resp.Choices[0].Message.Content)), &out); err != nil {
+		return nil, fmt.Errorf("invalid memory extraction: %w", err)
+	}
+
+	// 只有提取出的记忆可以被替换
+	replaceable := make(map[uint64]*Memory, len(existing))
+	known := make(map[string]bool, len(existing))
+	for i := range existing {
+		if existing[i].Source != SourceManual {
+			replaceable[existing[i].ID] = &existing[i]
+		}
+		known[existing[i].Content] = true
+	}
+
+	var saved []Memory
+	for _, item := range out.Memories {
+		if len(saved) == maxExtractedPerTurn {
+			break
+		}
+		in, err := normalizeInput(Input{Content: item.Content, Category: item.Category})
+		if err != nil || known[in.Content] {
+			continue
+		}
+		known[in.Content] = true
+
+		m := &Memory{UserID: userID, Content: in.Content, Category: in.Category, Source: SourceExtracted, SessionID: sessionID}
+		if item.Replaces != nil && replaceable[*item.Replaces] != nil {
+			m = replaceable[*item.Replaces]
+			// 同一条记忆每轮只替换一次
+			delete(replaceable, m.ID)
+			m.Content, m.Category, m.Source, m.SessionID = in.Content, in.Category, SourceExtracted, sessionID
+			// 提取在后台进行，期间用户可能修改或删除了这条记忆，这时以用户为准，不再保存
+			updated, err := s.repo.UpdateExtracted(ctx, m)
+			if err != nil {
+				return saved, err
+			}
+			if !updated {
+				continue
+			}
+		} else if err := s.repo.Create(ctx, m); err != nil {
+			return saved, err
+		}
+		saved = append(saved, *m)
+	}
+	return saved, nil
+}
@@ -0,0 +1,599 @@
+// Package nldate 解析常见的中英文自然语言时间表达式（"明天下午三点"、"下周五前"、
+// "in 2 days"、"end of month" 等），不依赖 LLM。
+//
+// 所有表达式都相对于参考时间 ref 解析，结果使用 ref 的时区。
+// 周以周一为第一天："下周五" 指下一周的周五，"周五" 指今天或之后最近的周五。
+package nldate
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrUnrecognized 无法识别的表达式
+var ErrUnrecognized = errors.New("unrecognized date expression")
+
+// EndOfDay 截止时间没有具体时刻时默认使用的时刻
+const EndOfDay = 23*time.Hour + 59*time.Minute
+
+// Result 解析结果
+type Result struct {
+	Time     time.Time // 没有具体时刻时为当天 0 点
+	HasClock bool      // 表达式包含具体时刻（或"下午""2 小时后"这类可以推出时刻的说法）
+}
+
+// At 返回结果时间，表达式没有具体时刻时使用 clock（距当天 0 点的偏移）
+func (r *Result) At(clock time.Duration) time.Time {
+	if r.HasClock {
+		return r.Time
+	}
+	return r.Time.Add(clock)
+}
+
+// Parse 解析自然语言时间表达式，整段文本都必须能被识别
+func Parse(s string, ref time.Time) (*Result, error) {
+	text := strings.TrimSpace(s)
+	if text == "" {
+		return nil, ErrUnrecognized
+	}
+	if r, ok := parseAbsolute(text, ref.Location()); ok {
+		return r, nil
+	}
+
+	p := &parser{ref: ref, rest: " " + strings.ToLower(text) + " "}
+	p.run()
+	if !p.matched || strings.TrimSpace(fillerRe.ReplaceAllString(p.rest, "")) != "" {
+		return nil, fmt.Errorf("%w: %q", ErrUnrecognized, s)
+	}
+	return p.result(), nil
+}
+
+// ParseDeadline 解析截止时间，没有具体时刻时取当天 23:59
+func ParseDeadline(s string, ref time.Time) (time.Time, error) {
+	r, err := Parse(s, ref)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return r.At(EndOfDay), nil
+}
+
+// absoluteLayouts 明确的日期时间格式，不带时区的按 ref 时区理解
+var absoluteLayouts = []struct {
+	layout   string
+	hasClock bool
+}{
+	{time.RFC3339, true},
+	{"2006-01-02T15:04:05", true},
+	{"2006-01-02T15:04", true},
+	{"2006-01-02 15:04:05", true},
+	{"2006-01-02 15:04", true},
+	{"2006/01/02 15:04", true},
+	{"2006-01-02", false},
+	{"2006/01/02", false},
+	{"2006.01.02", false},
+}
+
+func parseAbsolute(s string, loc *time.Location) (*Result, bool) {
+	for _, l := range absoluteLayouts {
+		if t, err := time.ParseInLocation(l.layout, s, loc); err == nil {
+			return &Result{Time: t.In(loc), HasClock: l.hasClock}, true
+		}
+	}
+	return nil, false
+}
+
+// fillerRe 识别完所有成分后允许剩下的词
+var fillerRe = regexp.MustCompile(`(?:之前|以前|前|截止到?|截至|为止|之内|以内|内|左右|大概|大约|的|在|到|\b(?:by|before|due|on|at|until|till|the|of|no later than)\b|[\s,，.。!！:：])`)
+
+var cnDigits = map[rune]int{'零': 0, '〇': 0, '一': 1, '二': 2, '两': 2, '三': 3, '四': 4, '五': 5, '六': 6, '七': 7, '八': 8, '九': 9}
+
+// cnNumberRe 中文数字（0-99），星期几单独处理，不在这里转换
+var cnNumberRe = regexp.MustCompile(`[零〇一二两三四五六七八九十]+`)
+
+// cnToInt 把 "十二" "二十五" "两" 这类中文数字转换为整数
+func cnToInt(s string) (int, bool) {
+	runes := []rune(s)
+	n, cur := 0, 0
+	for i, r := range runes {
+		if r == '十' {
+			if i == 0 {
+				cur = 1
+			}
+			n += cur * 10
+			cur = 0
+			continue
+		}
+		d, ok := cnDigits[r]
+		if !ok {
+			return 0, false
+		}
+		if i > 0 && runes[i-1] != '十' && cur > 0 {
+			// 连写的数字，例如 "二〇二五"
+			cur = cur*10 + d
+			continue
+		}
+		cur = d
+	}
+	return n + cur, true
+}
+
+var enNumbers = map[string]int{
+	"a": 1, "an": 1, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5,
+	"six": 6, "seven": 7, "eight": 8, "nine": 9, "ten": 10, "couple of": 2,
+}
+
+func atoi(s string) int {
+	if n, ok := enNumbers[s]; ok {
+		return n
+	}
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+var cnWeekdays = map[string]time.Weekday{
+	"一": time.Monday, "二": time.Tuesday, "三": time.Wednesday, "四": time.Thursday,
+	"五": time.Friday, "六": time.Saturday, "日": time.Sunday, "天": time.Sunday,
+	"1": time.Monday, "2": time.Tuesday, "3": time.Wednesday, "4": time.Thursday,
+	"5": time.Friday, "6": time.Saturday, "7": time.Sunday,
+}
+
+var enWeekdays = map[string]time.Weekday{
+	"mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday, "thu": time.Thursday,
+	"fri": time.Friday, "sat": time.Saturday, "sun": time.Sunday,
+}
+
+var enMonths = map[string]time.Month{
+	"jan": time.January, "feb": time.February, "mar": time.March, "apr": time.April,
+	"may": time.May, "jun": time.June, "jul": time.July, "aug": time.August,
+	"sep": time.September, "oct": time.October, "nov": time.November, "dec": time.December,
+}
+
+// 时段：是否为下午（小时需要 +12）以及没有具体时刻时的默认小时
+type period struct {
+	pm          bool
+	defaultHour int
+}
+
+var periods = map[string]period{
+	"凌晨": {false, -1}, "早上": {false, 9}, "早晨": {false, 9}, "上午": {false, 9}, "今早": {false, 9},
+	"中午": {false, 12}, "下午": {true, 15}, "傍晚": {true, 18}, "晚上": {true, 20}, "今晚": {true, 20}, "夜里": {true, 21},
+	"morning": {false, 9}, "noon": {false, 12}, "afternoon": {true, 15}, "evening": {true, 20}, "tonight": {true, 20}, "night": {true, 21},
+}
+
+type parser struct {
+	ref     time.Time
+	rest    string
+	matched bool
+
+	date  *time.Time // 日期（当天 0 点）
+	exact *time.Time // "2 小时后" 这类精确时间
+
+	hasClock  bool
+	hour, min int
+	period    *period
+	// twelveHour 时刻来自 "3点" "at 3" 这类没有区分上下午的说法
+	twelveHour bool
+}
+
+// take 在剩余文本中查找 re 的第一个匹配，fn 返回 true 时消费掉该匹配
+func (p *parser) take(re *regexp.Regexp, fn func(m []string) bool) {
+	loc := re.FindStringSubmatchIndex(p.rest)
+	if loc == nil {
+		return
+	}
+	m := make([]string, len(loc)/2)
+	for i := range m {
+		if loc[2*i] >= 0 {
+			m[i] = p.rest[loc[2*i]:loc[2*i+1]]
+		}
+	}
+	if fn(m) {
+		p.rest = p.rest[:loc[0]] + " " + p.rest[loc[1]:]
+		p.matched = true
+	}
+}
+
+func (p *parser) today() time.Time {
+	y, m, d := p.ref.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, p.ref.Location())
+}
+
+func (p *parser) setDate(t time.Time) bool {
+	if t.IsZero() || p.date != nil || p.exact != nil {
+		return false
+	}
+	y, m, d := t.Date()
+	day := time.Date(y, m, d, 0, 0, 0, 0, p.ref.Location())
+	p.date = &day
+	return true
+}
+
+// weekStart 返回 t 所在周的周一
+func weekStart(t time.Time) time.Time {
+	offset := (int(t.Weekday()) + 6) % 7
+	return t.AddDate(0, 0, -offset)
+}
+
+// weekday 解析 "下周五" 这类表达式，weeks 为相对本周的周数，relative 为 false 表示"今天或之后最近的一天"
+func (p *parser) weekday(wd time.Weekday, weeks int, relative bool) bool {
+	today := p.today()
+	if !relative {
+		diff := (int(wd) - int(today.Weekday()) + 7) % 7
+		return p.setDate(today.AddDate(0, 0, diff))
+	}
+	offset := (int(wd) + 6) % 7
+	return p.setDate(weekStart(today).AddDate(0, 0, 7*weeks+offset))
+}
+
+func endOfMonth(t time.Time, months int) time.Time {
+	y, m, _ := t.Date()
+	return time.Date(y, m+time.Month(months)+1, 0, 0, 0, 0, 0, t.Location())
+}
+
+var (
+	reCNFullDate  = regexp.MustCompile(`(\d{4})年(\d{1,2})月(\d{1,2})[日号]?`)
+	reCNMonthDay  = regexp.MustCompile(`(\d{1,2})月(\d{1,2})[日号]?`)
+	reCNDayOnly   = regexp.MustCompile(`(下个?月|本月|这个?月)?(\d{1,2})[日号]`)
+	reCNRelDay    = regexp.MustCompile(`大后天|后天|明天|明日|明早|明晚|今天|今日|今晚|今早|昨天|前天`)
+	reCNWeekday   = regexp.MustCompile(`(上上|上|这|本|下下|下)?个?(?:周|星期|礼拜)([一二三四五六日天1-7])`)
+	reCNWeekend   = regexp.MustCompile(`(下下|下|这|本)?个?周末`)
+	reCNMonthEdge = regexp.MustCompile(`(下个?|本|这个?)?月(底|末|初)`)
+	reCNYearEnd   = regexp.MustCompile(`(明|今)?年(底|末)`)
+	reCNNextUnit  = regexp.MustCompile(`(下下|下)个?(周|星期|礼拜|月)`)
+	reCNOffset    = regexp.MustCompile(`(\d+|半)个?(天|日|周|星期|礼拜|月|小时|钟头|分钟)(?:后|以后|之后|内|以内|之内)`)
+	reCNClock     = regexp.MustCompile(`(\d{1,2})[点時时](?:(\d{1,2})分?|(半)|(1|3)刻)?`)
+
+	reISODate    = regexp.MustCompile(`\b(\d{4})[-/.](\d{1,2})[-/.](\d{1,2})\b`)
+	reENMonthDay = regexp.MustCompile(`\b(jan|feb|mar|apr|may|jun|jul|aug|sep|oct|nov|dec)[a-z]*\.?\s+(\d{1,2})(?:st|nd|rd|th)?(?:,?\s+(\d{4}))?\b`)
+	reENDayMonth = regexp.MustCompile(`\b(\d{1,2})(?:st|nd|rd|th)?\s+(jan|feb|mar|apr|may|jun|jul|aug|sep|oct|nov|dec)[a-z]*\.?(?:,?\s+(\d{4}))?\b`)
+	reENRelDay   = regexp.MustCompile(`\b(day after tomorrow|today|tomorrow|tmr|yesterday)\b`)
+	reENWeekday  = regexp.MustCompile(`\b(?:(next|this|last)\s+)?(mon(?:day)?|tue(?:s|sday)?|wed(?:nesday)?|thu(?:rs|rsday)?|fri(?:day)?|sat(?:urday)?|sun(?:day)?)\b`)
+	reENWeekend  = regexp.MustCompile(`\b(?:(next|this)\s+)?weekend\b`)
+	reENEndOf    = regexp.MustCompile(`\b(?:end of(?: the)?|eo)\s*(?:(next|this)\s+)?(day|week|month|year)\b|\b(eod|eow|eom|eoy)\b`)
+	reENNextUnit = regexp.MustCompile(`\bnext\s+(week|month)\b`)
+	reENIn       = regexp.MustCompile(`\bin\s+(\d+|an?|one|two|three|four|five|six|seven|eight|nine|ten|couple of)\s+(minute|min|hour|hr|day|week|month)s?\b`)
+	reENLater    = regexp.MustCompile(`\b(\d+|an?|one|two|three|four|five|six|seven|eight|nine|ten)\s+(minute|min|hour|hr|day|week|month)s?\s+(?:later|from now)\b`)
+	reENAmPm     = regexp.MustCompile(`\b(\d{1,2})(?::(\d{2}))?\s*(am|pm|a\.m\.|p\.m\.)`)
+	reColonClock = regexp.MustCompile(`(\d{1,2}):(\d{2})`)
+	reENAtHour   = regexp.MustCompile(`\bat\s+(\d{1,2})\b`)
+	rePeriod     = regexp.MustCompile(`凌晨|早上|早晨|上午|中午|下午|傍晚|晚上|夜里|\b(?:morning|noon|afternoon|evening|tonight|night)\b`)
+	reENThisMorn = regexp.MustCompile(`\bthis\s+(morning|afternoon|evening)\b`)
+)
+
+func (p *parser) run() {
+	// 星期几要在中文数字转换之前识别（"周一" 里的 "一" 不是数字）
+	p.take(reCNWeekday, func(m []string) bool {
+		weeks := map[string]int{"上上": -2, "上": -1, "这": 0, "本": 0, "下": 1, "下下": 2}
+		w, relative := weeks[m[1]], m[1] != ""
+		return p.weekday(cnWeekdays[m[2]], w, relative)
+	})
+	p.rest = cnNumberRe.ReplaceAllStringFunc(p.rest, func(s string) string {
+		if n, ok := cnToInt(s); ok {
+			return strconv.Itoa(n)
+		}
+		return s
+	})
+
+	p.parseDates()
+	p.parseClock()
+}
+
+func (p *parser) parseDates() {
+	today := p.today()
+
+	p.take(reCNFullDate, func(m []string) bool {
+		return p.setDate(time.Date(atoi(m[1]), time.Month(atoi(m[2])), atoi(m[3]), 0, 0, 0, 0, p.ref.Location()))
+	})
+	p.take(reISODate, func(m []string) bool {
+		return p.setDate(time.Date(atoi(m[1]), time.Month(atoi(m[2])), atoi(m[3]), 0, 0, 0, 0, p.ref.Location()))
+	})
+	// 相对偏移要在 "N 日" 之前识别，避免 "3日内" 被当成 3 号
+	p.take(reCNOffset, func(m []string) bool {
+		return p.offset(m[1], m[2])
+	})
+	p.take(reENIn, func(m []string) bool {
+		return p.offset(m[1], m[2])
+	})
+	p.take(reENLater, func(m []string) bool {
+		return p.offset(m[1], m[2])
+	})
+
+	p.take(reCNMonthDay, func(m []string) bool {
+		return p.setDate(p.nextMonthDay(time.Month(atoi(m[1])), atoi(m[2]), 0))
+	})
+	p.take(reENMonthDay, func(m []string) bool {
+		return p.setDate(p.nextMonthDay(enMonths[m[1]], atoi(m[2]), atoi(m[3])))
+	})
+	p.take(reENDayMonth, func(m []string) bool {
+		return p.setDate(p.nextMonthDay(enMonths[m[2]], atoi(m[1]), atoi(m[3])))
+	})
+	p.take(reCNDayOnly, func(m []string) bool {
+		day := atoi(m[2])
+		if day < 1 || day > 31 {
+			return false
+		}
+		y, mo, _ := today.Date()
+		switch {
+		case strings.HasPrefix(m[1], "下"):
+			mo++
+		case m[1] == "":
+			if day < today.Day() {
+				mo++
+			}
+		}
+		return p.setDate(time.Date(y, mo, day, 0, 0, 0, 0, p.ref.Location()))
+	})
+
+	p.take(reCNRelDay, func(m []string) bool {
+		offsets := map[string]int{"大后天": 3, "后天": 2, "明天": 1, "明日": 1, "明早": 1, "明晚": 1, "今天": 0, "今日": 0, "今晚": 0, "今早": 0, "昨天": -1, "前天": -2}
+		switch m[0] {
+		case "今晚", "明晚":
+			p.period = &period{true, 20}
+		case "今早", "明早":
+			p.period = &period{false, 9}
+		}
+		return p.setDate(today.AddDate(0, 0, offsets[m[0]]))
+	})
+	p.take(reENRelDay, func(m []string) bool {
+		offsets := map[string]int{"day after tomorrow": 2, "tomorrow": 1, "tmr": 1, "today": 0, "yesterday": -1}
+		return p.setDate(today.AddDate(0, 0, offsets[m[1]]))
+	})
+	p.take(reENThisMorn, func(m []string) bool {
+		pd := periods[m[1]]
+		p.period = &pd
+		return p.setDate(today)
+	})
+	p.take(reENWeekday, func(m []string) bool {
+		weeks := map[string]int{"last": -1, "this": 0, "next": 1}
+		return p.weekday(enWeekdays[m[2][:3]], weeks[m[1]], m[1] != "")
+	})
+
+	p.take(reCNWeekend, func(m []string) bool {
+		weeks := map[string]int{"这": 0, "本": 0, "下": 1, "下下": 2}
+		return p.weekday(time.Saturday, weeks[m[1]], m[1] != "")
+	})
+	p.take(reENWeekend, func(m []string) bool {
+		return p.weekday(time.Saturday, map[string]int{"this": 0, "next": 1}[m[1]], m[1] != "")
+	})
+
+	p.take(reCNMonthEdge, func(m []string) bool {
+		months := 0
+		if strings.HasPrefix(m[1], "下") {
+			months = 1
+		}
+		if m[2] == "初" {
+			y, mo, _ := today.Date()
+			if m[1] == "" && today.Day() > 1 {
+				months = 1
+			}
+			return p.setDate(time.Date(y, mo+time.Month(months), 1, 0, 0, 0, 0, p.ref.Location()))
+		}
+		return p.setDate(endOfMonth(today, months))
+	})
+	p.take(reCNYearEnd, func(m []string) bool {
+		y := today.Year()
+		if m[1] == "明" {
+			y++
+		}
+		return p.setDate(time.Date(y, time.December, 31, 0, 0, 0, 0, p.ref.Location()))
+	})
+	p.take(reENEndOf, func(m []string) bool {
+		unit, next := m[2], m[1] == "next"
+		switch m[3] {
+		case "eod":
+			unit = "day"
+		case "eow":
+			unit = "week"
+		case "eom":
+			unit = "month"
+		case "eoy":
+			unit = "year"
+		}
+		n := 0
+		if next {
+			n = 1
+		}
+		switch unit {
+		case "day":
+			return p.setDate(today.AddDate(0, 0, n))
+		case "week":
+			// 工作周的最后一天（周五）
+			return p.weekday(time.Friday, n, true)
+		case "month":
+			return p.setDate(endOfMonth(today, n))
+		default:
+			return p.setDate(time.Date(today.Year()+n, time.December, 31, 0, 0, 0, 0, p.ref.Location()))
+		}
+	})
+
+	// 只有 "下周" "下个月" 时取该周周一 / 该月 1 号
+	p.take(reCNNextUnit, func(m []string) bool {
+		n := 1
+		if m[1] == "下下" {
+			n = 2
+		}
+		if m[2] == "月" {
+			y, mo, _ := today.Date()
+			return p.setDate(time.Date(y, mo+time.Month(n), 1, 0, 0, 0, 0, p.ref.Location()))
+		}
+		return p.weekday(time.Monday, n, true)
+	})
+	p.take(reENNextUnit, func(m []string) bool {
+		if m[1] == "month" {
+			y, mo, _ := today.Date()
+			return p.setDate(time.Date(y, mo+1, 1, 0, 0, 0, 0, p.ref.Location()))
+		}
+		return p.weekday(time.Monday, 1, true)
+	})
+}
+
+// nextMonthDay 某月某日；没有给出年份时取今天或之后最近的一次
+func (p *parser) nextMonthDay(month time.Month, day, year int) time.Time {
+	if month < 1 || month > 12 || day < 1 || day > 31 {
+		return time.Time{}
+	}
+	today := p.today()
+	if year > 0 {
+		t := time.Date(year, month, day, 0, 0, 0, 0, p.ref.Location())
+		if t.Month() != month {
+			return time.Time{}
+		}
+		return t
+	}
+	t := time.Date(today.Year(), month, day, 0, 0, 0, 0, p.ref.Location())
+	if t.Before(today) {
+		t = time.Date(today.Year()+1, month, day, 0, 0, 0, 0, p.ref.Location())
+	}
+	if t.Month() != month {
+		return time.Time{} // 不存在的日期，例如 2 月 30 日
+	}
+	return t
+}
+
+// maxOffsetDays 相对时间最多约 100 年，更大的数字（例如 "in 999999999999 days"）视为无法识别
+const maxOffsetDays = 100 * 366
+
+// offsetUnitDays 每种相对时间单位约合的天数，用于检查是否超出 maxOffsetDays
+var offsetUnitDays = map[string]float64{
+	"天": 1, "日": 1, "day": 1,
+	"周": 7, "星期": 7, "礼拜": 7, "week": 7,
+	"月": 31, "month": 31,
+	"小时": 1.0 / 24, "钟头": 1.0 / 24, "hour": 1.0 / 24, "hr": 1.0 / 24,
+	"分钟": 1.0 / 1440, "minute": 1.0 / 1440, "min": 1.0 / 1440,
+}
+
+// offset 处理 "3 天后" "in 2 hours" 这类相对时间
+func (p *parser) offset(amount, unit string) bool {
+	if p.date != nil || p.exact != nil {
+		return false
+	}
+	var d time.Duration
+	n := atoi(amount)
+	if float64(n)*offsetUnitDays[unit] > maxOffsetDays {
+		return false
+	}
+	if amount == "半" {
+		// 只支持 "半小时"
+		switch unit {
+		case "小时", "钟头":
+			d = 30 * time.Minute
+		default:
+			return false
+		}
+	}
+	switch unit {
+	case "天", "日", "day":
+		return p.setDate(p.today().AddDate(0, 0, n))
+	case "周", "星期", "礼拜", "week":
+		return p.setDate(p.today().AddDate(0, 0, 7*n))
+	case "月", "month":
+		return p.setDate(p.today().AddDate(0, n, 0))
+	case "小时", "钟头", "hour", "hr":
+		if d == 0 {
+			d = time.Duration(n) * time.Hour
+		}
+	case "分钟", "minute", "min":
+		d = time.Duration(n) * time.Minute
+	}
+	t := p.ref.Add(d).Truncate(time.Minute)
+	p.exact = &t
+	return true
+}
+
+func (p *parser) parseClock() {
+	setClock := func(h, m int) bool {
+		if p.hasClock || h > 24 || m > 59 {
+			return false
+		}
+		p.hasClock, p.hour, p.min = true, h, m
+		return true
+	}
+
+	p.take(reENAmPm, func(m []string) bool {
+		h := atoi(m[1])
+		if h < 1 || h > 12 {
+			return false
+		}
+		if strings.HasPrefix(m[3], "p") && h < 12 {
+			h += 12
+		}
+		if strings.HasPrefix(m[3], "a") && h == 12 {
+			h = 0
+		}
+		return setClock(h, atoi(m[2]))
+	})
+	p.take(reCNClock, func(m []string) bool {
+		min := atoi(m[2])
+		switch {
+		case m[3] == "半":
+			min = 30
+		case m[4] != "":
+			min = 15 * atoi(m[4])
+		}
+		p.twelveHour = setClock(atoi(m[1]), min)
+		return p.twelveHour
+	})
+	p.take(reColonClock, func(m []string) bool {
+		return setClock(atoi(m[1]), atoi(m[2]))
+	})
+	p.take(reENAtHour, func(m []string) bool {
+		p.twelveHour = setClock(atoi(m[1]), 0)
+		return p.twelveHour
+	})
+	p.take(rePeriod, func(m []string) bool {
+		if p.period != nil {
+			return false
+		}
+		pd := periods[m[0]]
+		p.period = &pd
+		return true
+	})
+}
+
+func (p *parser) result() *Result {
+	if p.exact != nil {
+		return &Result{Time: *p.exact, HasClock: true}
+	}
+
+	day := p.today()
+	if p.date != nil {
+		day = *p.date
+	}
+
+	hour, min, hasClock := p.hour, p.min, p.hasClock
+	if hasClock && p.period != nil {
+		switch {
+		case hour == 12 && p.period.pm && p.period.defaultHour >= 20:
+			// "晚上 12 点" "今晚 12 点" 指次日 0 点
+			hour = 24
+		case hour == 0:
+			// 0 点不做 12 小时换算
+		case p.period.pm && hour < 12:
+			hour += 12
+		case p.period.defaultHour == 12 && hour < 3:
+			// "中午 1 点" 指 13 点
+			hour += 12
+		}
+	}
+	if !hasClock && p.period != nil && p.period.defaultHour >= 0 {
+		hour, hasClock = p.period.defaultHour, true
+	}
+	if !hasClock {
+		return &Result{Time: day}
+	}
+
+	t := day.Add(time.Duration(hour)*time.Hour + time.Duration(min)*time.Minute)
+	// 只给了时刻且已经过去：1~11 点先尝试理解为下午（14:30 说"3点"指 15:00），否则是明天
+	if p.date == nil && t.Before(p.ref) {
+		if p.twelveHour && p.period == nil && hour > 0 && hour < 12 && !t.Add(12*time.Hour).Before(p.ref) {
+			t = t.Add(12 * time.Hour)
+		} else {
+			t = t.AddDate(0, 0, 1)
+		}
+	}
+	return &Result{Time: t, HasClock: true}
+}
@@ -0,0 +1,25 @@
+package profile
+
+import "assistant-qisumi/internal/domain"
+
+// 类型别名 - 引用 domain 包中的定义，避免循环依赖
+type UserProfile = domain.UserProfile
+
+// 默认工作时间：周一到周五 9:00-18:00，服务器时区
+const (
+	DefaultWorkStart = "09:00"
+	DefaultWorkEnd   = "18:00"
+)
+
+var DefaultWorkDays = []int{1, 2, 3, 4, 5}
+
+// Default 返回用户未设置时使用的默认资料
+func Default(userID uint64) *UserProfile {
+	return &UserProfile{
+		UserID:    userID,
+		WorkStart: DefaultWorkStart,
+		WorkEnd:   DefaultWorkEnd,
+		WorkDays:  append([]int(nil), DefaultWorkDays...),
+		DaysOff:   []string{},
+	}
+}
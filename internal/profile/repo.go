@@ -0,0 +1,39 @@
+package profile
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Get 获取用户资料，不存在时返回 nil
+func (r *Repository) Get(ctx context.Context, userID uint64) (*UserProfile, error) {
+	var profiles []UserProfile
+	err := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Limit(1).
+		Find(&profiles).Error
+	if err != nil || len(profiles) == 0 {
+		return nil, err
+	}
+	return &profiles[0], nil
+}
+
+// Save 创建或覆盖用户资料
+func (r *Repository) Save(ctx context.Context, p *UserProfile) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"timezone", "work_start", "work_end", "work_days", "days_off", "updated_at"}),
+		}).
+		Create(p).Error
+}
@@ -0,0 +1,184 @@
+package profile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"assistant-qisumi/internal/domain"
+	"assistant-qisumi/internal/scheduler"
+)
+
+// ErrInvalidProfile 资料字段不合法
+var ErrInvalidProfile = errors.New("invalid profile")
+
+type Service struct {
+	repo *Repository
+}
+
+func NewService(repo *Repository) *Service {
+	return &Service{repo: repo}
+}
+
+// Get 获取用户资料，未设置时返回默认值
+func (s *Service) Get(ctx context.Context, userID uint64) (*UserProfile, error) {
+	p, err := s.repo.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return Default(userID), nil
+	}
+	normalize(p)
+	return p, nil
+}
+
+// UpdateInput 更新资料的字段，nil 表示不修改
+type UpdateInput struct {
+	Timezone  *string
+	WorkStart *string
+	WorkEnd   *string
+	WorkDays  []int
+	DaysOff   []string
+}
+
+// Update 校验并保存用户资料
+func (s *Service) Update(ctx context.Context, userID uint64, in UpdateInput) (*UserProfile, error) {
+	p, err := s.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if in.Timezone != nil {
+		if *in.Timezone != "" {
+			if _, err := time.LoadLocation(*in.Timezone); err != nil {
+				return nil, fmt.Errorf("%w: unknown timezone %q", ErrInvalidProfile, *in.Timezone)
+			}
+		}
+		p.Timezone = *in.Timezone
+	}
+	if in.WorkStart != nil {
+		p.WorkStart = *in.WorkStart
+	}
+	if in.WorkEnd != nil {
+		p.WorkEnd = *in.WorkEnd
+	}
+	if in.WorkDays != nil {
+		days := make(map[int]bool)
+		p.WorkDays = p.WorkDays[:0]
+		for _, d := range in.WorkDays {
+			if d < 1 || d > 7 {
+				return nil, fmt.Errorf("%w: work day %d must be between 1 and 7", ErrInvalidProfile, d)
+			}
+			if !days[d] {
+				days[d] = true
+				p.WorkDays = append(p.WorkDays, d)
+			}
+		}
+		sort.Ints(p.WorkDays)
+	}
+	if in.DaysOff != nil {
+		p.DaysOff = make([]string, 0, len(in.DaysOff))
+		for _, d := range in.DaysOff {
+			if _, err := time.Parse("2006-01-02", d); err != nil {
+				return nil, fmt.Errorf("%w: day off %q must be in YYYY-MM-DD format", ErrInvalidProfile, d)
+			}
+			p.DaysOff = append(p.DaysOff, d)
+		}
+		sort.Strings(p.DaysOff)
+	}
+
+	// 校验工作时间能被排期使用
+	if _, err := hoursOf(p); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidProfile, err)
+	}
+
+	if err := s.repo.Save(ctx, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Location 返回用户时区，未设置或无法识别时为服务器时区
+func Location(p *UserProfile) *time.Location {
+	if p == nil || p.Timezone == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// WorkingHours 把用户资料转换为排期使用的工作时间，资料不合法时退回默认工作时间
+func WorkingHours(p *UserProfile) scheduler.WorkingHours {
+	h, err := hoursOf(p)
+	if err != nil {
+		return scheduler.DefaultWorkingHours(Location(p))
+	}
+	return h
+}
+
+func hoursOf(p *UserProfile) (scheduler.WorkingHours, error) {
+	h := scheduler.DefaultWorkingHours(Location(p))
+	if p == nil {
+		return h, nil
+	}
+	start, err := scheduler.ParseClock(p.WorkStart)
+	if err != nil {
+		return h, err
+	}
+	end, err := scheduler.ParseClock(p.WorkEnd)
+	if err != nil {
+		return h, err
+	}
+	if end <= start {
+		return h, errors.New("work end must be after work start")
+	}
+	if len(p.WorkDays) == 0 {
+		return h, errors.New("at least one work day is required")
+	}
+	h.Start, h.End = start, end
+	h.Days = h.Days[:0]
+	for _, d := range p.WorkDays {
+		h.Days = append(h.Days, time.Weekday(d%7))
+	}
+	h.DaysOff = p.DaysOff
+	return h, nil
+}
+
+// normalize 补全旧记录中缺失的字段
+func normalize(p *UserProfile) {
+	if p.WorkStart == "" {
+		p.WorkStart = DefaultWorkStart
+	}
+	if p.WorkEnd == "" {
+		p.WorkEnd = DefaultWorkEnd
+	}
+	if len(p.WorkDays) == 0 {
+		p.WorkDays = append([]int(nil), DefaultWorkDays...)
+	}
+	if p.DaysOff == nil {
+		p.DaysOff = []string{}
+	}
+}
+
+type profileKey struct{}
+
+// NewContext 在 context 中记录用户资料，同时记录时区供时间解析使用
+func NewContext(ctx context.Context, p *UserProfile) context.Context {
+	ctx = context.WithValue(ctx, profileKey{}, p)
+	return domain.WithLocation(ctx, Location(p))
+}
+
+// FromContext 获取 context 中的用户资料，没有时返回 nil
+func FromContext(ctx context.Context) *UserProfile {
+	if ctx == nil {
+		return nil
+	}
+	p, _ := ctx.Value(profileKey{}).(*UserProfile)
+	return p
+}
@@ -0,0 +1,17 @@
+package prompts
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RepairMessage 把结构化输出的校验错误反馈给模型，要求输出修正后的完整结果
+func RepairMessage(errs []string) string {
+	var b strings.Builder
+	b.WriteString("你上一次的输出不符合要求的 JSON Schema，存在以下问题：\n")
+	for _, e := range errs {
+		fmt.Fprintf(&b, "- %s\n", e)
+	}
+	b.WriteString("请修正这些问题，重新输出完整的结果，不要只输出改动的部分。")
+	return b.String()
+}
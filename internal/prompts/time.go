@@ -0,0 +1,17 @@
+package prompts
+
+import (
+	"fmt"
+	"time"
+)
+
+// NowMessage 生成告诉模型当前时间和用户时区的系统消息内容
+// now 应已转换到用户时区；模型输出不带时区的时间时会按该时区理解
+func NowMessage(now time.Time) string {
+	zone := now.Location().String()
+	if zone == "Local" {
+		zone, _ = now.Zone()
+	}
+	return fmt.Sprintf("当前时间 now: %s（用户时区 %s，输出时间请带上时区偏移，例如 %s）",
+		now.Format(time.RFC3339), zone, now.Format("2006-01-02T15:04:05Z07:00"))
+}
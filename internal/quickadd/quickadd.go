@@ -0,0 +1,169 @@
+// Package quickadd 解析 Todoist 风格的快速添加语法，不依赖 LLM：
+//
+//	写周报 !high #工作 @周五 due:2025-12-01
+//	- 收集数据
+//	- 写初稿
+//
+// 第一行（去掉标记后）是任务标题，"- " / "* " 开头的行是步骤，其余文字并入描述。
+// 标记可以出现在标题和描述行的任意位置：
+//   - !high / !medium / !low（也接受 !1 / !2 / !3、!p1、!高 / !中 / !低）设置优先级
+//   - #标签 添加标签
+//   - @明天、due:2025-12-01 设置截止时间；含空格的表达式可以写成 @"next friday" 或 @next_friday；
+//     无法识别为日期的 @ 标记（例如 @alice）保留在文字中，due: 后无法识别的日期视为错误
+package quickadd
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"assistant-qisumi/internal/domain"
+	"assistant-qisumi/internal/nldate"
+)
+
+// CreatedFrom 通过快速添加创建的任务的来源标记
+const CreatedFrom = "quick"
+
+// ErrInvalidInput 输入无法解析（缺少标题、无法识别的日期等），调用方应视为客户端错误
+var ErrInvalidInput = errors.New("invalid quick-add input")
+
+var priorities = map[string]string{
+	"high": "high", "h": "high", "1": "high", "p1": "high", "高": "high",
+	"medium": "medium", "m": "medium", "2": "medium", "p2": "medium", "中": "medium",
+	"low": "low", "l": "low", "3": "low", "p3": "low", "低": "low",
+}
+
+// tokenRe 匹配以空白或行首开头的标记，第 1 组是前导空白
+var tokenRe = regexp.MustCompile(`(^|\s)(!\S+|#\S+|@"[^"]*"|@\S+|(?i:due):"[^"]*"|(?i:due):\S+)`)
+
+var stepRe = regexp.MustCompile(`^(?:[-*+]|\d+[.)])\s+(?:\[[ xX]\]\s*)?(.*)$`)
+
+// Parse 把快速添加文本解析为任务（UserID 为空，由调用方填充）
+// ref 是解析相对日期的参考时间，其时区即用户时区。
+// 没有显式指定优先级时 Priority 为空，调用方可以据此决定是否由 LLM 补全。
+func Parse(text string, ref time.Time) (*domain.Task, error) {
+	t := &domain.Task{Status: "todo", CreatedFrom: CreatedFrom}
+	var desc []string
+	titleSeen := false
+
+	for _, raw := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		line := strings.TrimSpace(raw)
+		if line == "" {
+			continue
+		}
+		if m := stepRe.FindStringSubmatch(line); m != nil {
+			if !titleSeen {
+				// 整段都是清单时，第一项作为标题
+				line = m[1]
+			} else {
+				if title := strings.TrimSpace(m[1]); title != "" {
+					t.Steps = append(t.Steps, domain.TaskStep{Title: title, Status: "todo", OrderIndex: len(t.Steps)})
+				}
+				continue
+			}
+		}
+
+		rest, err := extractTokens(line, t, ref)
+		if err != nil {
+			return nil, err
+		}
+		if !titleSeen {
+			if rest == "" {
+				// 只有标记的行不能作为标题，继续寻找
+				continue
+			}
+			t.Title = rest
+			titleSeen = true
+			continue
+		}
+		if rest != "" {
+			desc = append(desc, rest)
+		}
+	}
+
+	if t.Title == "" {
+		return nil, fmt.Errorf("%w: missing title", ErrInvalidInput)
+	}
+	t.Description = strings.Join(desc, "\n")
+	return t, nil
+}
+
+// extractTokens 从一行中取出标记并应用到任务上，返回去掉标记后的文字
+func extractTokens(line string, t *domain.Task, ref time.Time) (string, error) {
+	var firstErr error
+	rest := tokenRe.ReplaceAllStringFunc(line, func(match string) string {
+		m := tokenRe.FindStringSubmatch(match)
+		lead, tok := m[1], m[2]
+		ok, err := applyToken(tok, t, ref)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if !ok {
+			// 不是可识别的标记（例如正文里的感叹号），原样保留
+			return match
+		}
+		return lead
+	})
+	if firstErr != nil {
+		return "", firstErr
+	}
+	return strings.Join(strings.Fields(rest), " "), nil
+}
+
+// applyToken 应用单个标记，返回该标记是否被识别
+func applyToken(tok string, t *domain.Task, ref time.Time) (bool, error) {
+	switch {
+	case strings.HasPrefix(tok, "!"):
+		p, ok := priorities[strings.ToLower(tok[1:])]
+		if ok {
+			t.Priority = p
+		}
+		return ok, nil
+	case strings.HasPrefix(tok, "#"):
+		tag := strings.TrimRight(tok[1:], ",，.。")
+		if tag == "" {
+			return false, nil
+		}
+		addTag(t, tag)
+		return true, nil
+	case strings.HasPrefix(tok, "@"):
+		// 无法识别的日期（例如 @alice）原样保留在文字中
+		due, err := parseDue(tok[1:], ref)
+		if err != nil {
+			return false, nil
+		}
+		t.DueAt = due
+		return true, nil
+	default: // due:
+		due, err := parseDue(tok[len("due:"):], ref)
+		if err != nil {
+			return false, err
+		}
+		t.DueAt = due
+		return true, nil
+	}
+}
+
+func addTag(t *domain.Task, tag string) {
+	for _, existing := range t.Tags {
+		if strings.EqualFold(existing, tag) {
+			return
+		}
+	}
+	t.Tags = append(t.Tags, tag)
+}
+
+// parseDue 解析截止时间，只有日期时取当天 23:59；后出现的标记覆盖先出现的
+func parseDue(value string, ref time.Time) (*domain.FlexibleTime, error) {
+	expr := strings.TrimSpace(strings.ReplaceAll(strings.Trim(value, `"`), "_", " "))
+	if expr == "" {
+		return nil, fmt.Errorf("%w: empty due date", ErrInvalidInput)
+	}
+	due, err := nldate.ParseDeadline(expr, ref)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unrecognized due date %q", ErrInvalidInput, expr)
+	}
+	return &domain.FlexibleTime{Time: due}, nil
+}
@@ -0,0 +1,30 @@
+package quota
+
+import (
+	"context"
+
+	"assistant-qisumi/internal/llm"
+)
+
+// Client 在另一个 Client 外层检查每天的 token 限额。
+// 一条用户消息可能触发多次 LLM 调用（工具调用后的二次调用等），每次调用前都会检查；
+// 每分钟请求数由 HTTP 中间件按用户请求计数，这里不重复计数。
+// 调用归属来自 context（llm.WithCallInfo），没有用户归属的调用不受限制。
+type Client struct {
+	inner   llm.Client
+	limiter *Limiter
+}
+
+// NewClient 创建带限额检查的 Client，应放在 ResilientClient 外层，避免限额错误被重试
+func NewClient(inner llm.Client, limiter *Limiter) *Client {
+	return &Client{inner: inner, limiter: limiter}
+}
+
+func (c *Client) Chat(ctx context.Context, cfg llm.Config, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	if info := llm.CallInfoFromContext(ctx); info.UserID != 0 && c.limiter != nil {
+		if _, err := c.limiter.CheckTokens(ctx, info.UserID, cfg.IsDefault); err != nil {
+			return nil, err
+		}
+	}
+	return c.inner.Chat(ctx, cfg, req)
+}
@@ -0,0 +1,161 @@
+// Package quota 限制每个用户调用 LLM 的频率（每分钟请求数）和每天消耗的 token 数。
+// 使用服务器默认 API Key 与使用自己 API Key 的用户分别适用不同的限额。
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"assistant-qisumi/internal/domain"
+)
+
+// 限额类型
+const (
+	LimitRequestsPerMinute = "requests_per_minute"
+	LimitTokensPerDay      = "tokens_per_day"
+)
+
+// ErrQuotaExceeded 超出限额
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// Limits 一组限额，0 表示不限制
+type Limits struct {
+	RequestsPerMinute int
+	TokensPerDay      int64
+}
+
+// Policy 按 API Key 来源区分的限额
+type Policy struct {
+	DefaultKey Limits // 使用服务器默认 API Key
+	OwnKey     Limits // 使用用户自己的 API Key
+}
+
+// For 返回适用的限额
+func (p Policy) For(defaultKey bool) Limits {
+	if defaultKey {
+		return p.DefaultKey
+	}
+	return p.OwnKey
+}
+
+// ExceededError 超出限额的详情，可以用 errors.Is(err, ErrQuotaExceeded) 判断
+type ExceededError struct {
+	Limit   string    // LimitRequestsPerMinute 或 LimitTokensPerDay
+	Max     int64     // 限额
+	ResetAt time.Time // 何时恢复
+}
+
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("%s: %s limit of %d reached, resets at %s", ErrQuotaExceeded, e.Limit, e.Max, e.ResetAt.Format(time.RFC3339))
+}
+
+func (e *ExceededError) Unwrap() error { return ErrQuotaExceeded }
+
+// Status 某项限额的当前状态，用于输出 X-RateLimit-* 响应头
+type Status struct {
+	Max       int64
+	Remaining int64
+	ResetAt   time.Time
+}
+
+// TokenCounter 统计用户自某时刻以来消耗的 token 数（只统计同一 API Key 来源的调用）
+type TokenCounter interface {
+	TokensUsed(ctx context.Context, userID uint64, defaultKey bool, since time.Time) (int64, error)
+}
+
+// Limiter 执行限额检查。
+// 每分钟请求数使用内存中的滑动窗口（多实例部署时各实例分别计数）；
+// 每天的 token 数来自用量记录，按用户时区的自然日计算。
+type Limiter struct {
+	policy Policy
+	tokens TokenCounter
+	now    func() time.Time
+
+	mu        sync.Mutex
+	requests  map[uint64][]time.Time
+	lastSweep time.Time
+}
+
+// NewLimiter 创建限额检查器，tokens 为空时不检查 token 限额
+func NewLimiter(policy Policy, tokens TokenCounter) *Limiter {
+	return &Limiter{policy: policy, tokens: tokens, now: time.Now, requests: map[uint64][]time.Time{}}
+}
+
+// WithClock 替换获取当前时间的函数，返回自身便于链式调用
+func (l *Limiter) WithClock(now func() time.Time) *Limiter {
+	l.now = now
+	return l
+}
+
+// TrackedUsers 返回内存中仍在计数的用户数
+func (l *Limiter) TrackedUsers() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.requests)
+}
+
+// sweep 删除窗口内已没有请求的用户，避免不再活跃的用户一直占用内存；每分钟最多执行一次
+func (l *Limiter) sweep(now, windowStart time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	for userID, times := range l.requests {
+		if len(times) == 0 || !times[len(times)-1].After(windowStart) {
+			delete(l.requests, userID)
+		}
+	}
+}
+
+// AllowRequest 记录一次请求并检查每分钟请求数。超出时返回 *ExceededError，且该次请求不计数
+func (l *Limiter) AllowRequest(userID uint64, defaultKey bool) (*Status, error) {
+	limit := l.policy.For(defaultKey).RequestsPerMinute
+	if limit <= 0 {
+		return nil, nil
+	}
+	now := l.now()
+	windowStart := now.Add(-time.Minute)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now, windowStart)
+	times := l.requests[userID]
+	i := 0
+	for i < len(times) && !times[i].After(windowStart) {
+		i++
+	}
+	times = times[i:]
+
+	if len(times) >= limit {
+		l.requests[userID] = times
+		// 窗口内最早的请求过期后恢复
+		return nil, &ExceededError{Limit: LimitRequestsPerMinute, Max: int64(limit), ResetAt: times[len(times)-limit].Add(time.Minute)}
+	}
+	times = append(times, now)
+	l.requests[userID] = times
+	return &Status{Max: int64(limit), Remaining: int64(limit - len(times)), ResetAt: times[0].Add(time.Minute)}, nil
+}
+
+// CheckTokens 检查当天已消耗的 token 数是否达到上限。
+// 只在调用前检查，正在进行的调用可能让当天用量略超上限
+func (l *Limiter) CheckTokens(ctx context.Context, userID uint64, defaultKey bool) (*Status, error) {
+	limit := l.policy.For(defaultKey).TokensPerDay
+	if limit <= 0 || l.tokens == nil {
+		return nil, nil
+	}
+	now := l.now().In(domain.LocationFromContext(ctx))
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	resetAt := dayStart.AddDate(0, 0, 1)
+
+	used, err := l.tokens.TokensUsed(ctx, userID, defaultKey, dayStart)
+	if err != nil {
+		return nil, err
+	}
+	if used >= limit {
+		return nil, &ExceededError{Limit: LimitTokensPerDay, Max: limit, ResetAt: resetAt}
+	}
+	return &Status{Max: limit, Remaining: limit - used, ResetAt: resetAt}, nil
+}
@@ -0,0 +1,78 @@
+package report
+
+import (
+	"context"
+
+	"assistant-qisumi/internal/domain"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// GetTemplate 获取用户某个格式的自定义模板，不存在时返回 nil
+func (r *Repository) GetTemplate(ctx context.Context, userID uint64, format Format) (*domain.ReportTemplate, error) {
+	var tpls []domain.ReportTemplate
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND format = ?", userID, string(format)).
+		Limit(1).
+		Find(&tpls).Error
+	if err != nil || len(tpls) == 0 {
+		return nil, err
+	}
+	return &tpls[0], nil
+}
+
+// SaveTemplate 创建或覆盖用户某个格式的模板
+func (r *Repository) SaveTemplate(ctx context.Context, tpl *domain.ReportTemplate) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "format"}},
+			DoUpdates: clause.AssignmentColumns([]string{"content", "updated_at"}),
+		}).
+		Create(tpl).Error
+}
+
+// DeleteTemplate 删除用户某个格式的模板，恢复使用内置模板
+func (r *Repository) DeleteTemplate(ctx context.Context, userID uint64, format Format) error {
+	return r.db.WithContext(ctx).
+		Where("user_id = ? AND format = ?", userID, string(format)).
+		Delete(&domain.ReportTemplate{}).Error
+}
+
+// LatestSummary 获取任务会话中 summarizer 最近一次的回复
+func (r *Repository) LatestSummary(ctx context.Context, userID, taskID uint64) (string, error) {
+	var msgs []domain.Message
+	err := r.db.WithContext(ctx).
+		Where("session_id IN (SELECT id FROM sessions WHERE user_id = ? AND task_id = ?)", userID, taskID).
+		Where("role = ? AND agent_name = ?", "assistant", "summarizer").
+		Order("id DESC").
+		Limit(1).
+		Find(&msgs).Error
+	if err != nil || len(msgs) == 0 {
+		return "", err
+	}
+	return msgs[0].Content, nil
+}
+
+// ListTasksByIDs 获取用户指定的若干任务（含步骤）
+func (r *Repository) ListTasksByIDs(ctx context.Context, userID uint64, ids []uint64) ([]domain.Task, error) {
+	var tasks []domain.Task
+	if len(ids) == 0 {
+		return tasks, nil
+	}
+	err := r.db.WithContext(ctx).
+		Preload("Steps", func(db *gorm.DB) *gorm.DB {
+			return db.Order("order_index ASC")
+		}).
+		Where("user_id = ? AND id IN ?", userID, ids).
+		Find(&tasks).Error
+	return tasks, err
+}
@@ -0,0 +1,248 @@
+// Package report 把单个任务渲染成可直接粘贴到周报里的 Markdown / HTML / JSON
+// Markdown 与 HTML 使用 Go 模板渲染，用户可以用自己的模板覆盖内置模板
+package report
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"assistant-qisumi/internal/domain"
+)
+
+// Format 报告格式
+type Format string
+
+const (
+	FormatMarkdown Format = "markdown"
+	FormatHTML     Format = "html"
+	FormatJSON     Format = "json"
+)
+
+// ParseFormat 解析报告格式，支持 md / htm 等常见写法
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "markdown", "md":
+		return FormatMarkdown, nil
+	case "html", "htm":
+		return FormatHTML, nil
+	case "json":
+		return FormatJSON, nil
+	default:
+		return "", fmt.Errorf("unsupported report format %q", s)
+	}
+}
+
+// Templated 该格式是否通过模板渲染（JSON 直接序列化，不支持模板）
+func (f Format) Templated() bool {
+	return f == FormatMarkdown || f == FormatHTML
+}
+
+// ContentType 对应的 HTTP Content-Type
+func (f Format) ContentType() string {
+	switch f {
+	case FormatHTML:
+		return "text/html; charset=utf-8"
+	case FormatJSON:
+		return "application/json; charset=utf-8"
+	default:
+		return "text/markdown; charset=utf-8"
+	}
+}
+
+// Extension 导出文件扩展名
+func (f Format) Extension() string {
+	switch f {
+	case FormatHTML:
+		return "html"
+	case FormatJSON:
+		return "json"
+	default:
+		return "md"
+	}
+}
+
+// Report 模板的数据模型
+type Report struct {
+	Task         ReportTask         `json:"task"`
+	Steps        []ReportStep       `json:"steps"`
+	Dependencies []ReportDependency `json:"dependencies"`
+	Progress     Progress           `json:"progress"`
+	Summary      string             `json:"summary,omitempty"`
+	GeneratedAt  time.Time          `json:"generatedAt"`
+}
+
+type ReportTask struct {
+	ID          uint64     `json:"id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description,omitempty"`
+	Status      string     `json:"status"`
+	Priority    string     `json:"priority"`
+	DueAt       *time.Time `json:"dueAt,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+}
+
+type ReportStep struct {
+	ID             uint64     `json:"id"`
+	Index          int        `json:"index"` // 从 1 开始的序号
+	Title          string     `json:"title"`
+	Detail         string     `json:"detail,omitempty"`
+	Status         string     `json:"status"`
+	Done           bool       `json:"done"`
+	BlockingReason string     `json:"blockingReason,omitempty"`
+	EstimateMin    *int       `json:"estimateMinutes,omitempty"`
+	ActualMin      *int       `json:"actualMinutes,omitempty"`
+	PlannedStart   *time.Time `json:"plannedStart,omitempty"`
+	PlannedEnd     *time.Time `json:"plannedEnd,omitempty"`
+	CompletedAt    *time.Time `json:"completedAt,omitempty"`
+}
+
+// ReportDependency 从当前任务视角描述的一条依赖
+type ReportDependency struct {
+	Direction string `json:"direction"` // "blocked_by": 当前任务依赖对方；"blocks": 对方依赖当前任务
+	TaskID    uint64 `json:"taskId"`
+	TaskTitle string `json:"taskTitle"`
+	StepTitle string `json:"stepTitle,omitempty"` // 对方任务中的步骤
+	OwnStep   string `json:"ownStep,omitempty"`   // 当前任务中的步骤
+	Condition string `json:"condition"`
+	Action    string `json:"action"`
+}
+
+type Progress struct {
+	TotalSteps  int  `json:"totalSteps"`
+	DoneSteps   int  `json:"doneSteps"`
+	Percent     int  `json:"percent"`
+	EstimateMin int  `json:"estimateMinutes"`
+	ActualMin   *int `json:"actualMinutes,omitempty"` // 没有任何实际耗时记录时为空
+}
+
+// Input 构建报告需要的原始数据
+type Input struct {
+	Task         *domain.Task
+	Dependencies []domain.TaskDependency
+	// RelatedTasks 依赖中出现的其他任务（含步骤），用于显示标题
+	RelatedTasks map[uint64]*domain.Task
+	// ActualMinutes 步骤 ID -> 实际耗时（分钟），可以为空
+	ActualMinutes map[uint64]int
+	Summary       string
+	// Now 生成时间，报告中的时间都换算到它的时区（用户时区）
+	Now time.Time
+}
+
+// Build 把任务数据整理成模板使用的报告结构
+func Build(in Input) *Report {
+	t := in.Task
+	loc := in.Now.Location()
+	r := &Report{
+		Task: ReportTask{
+			ID:          t.ID,
+			Title:       t.Title,
+			Description: t.Description,
+			Status:      t.Status,
+			Priority:    t.Priority,
+			DueAt:       flexibleTimePtr(t.DueAt, loc),
+			CreatedAt:   t.CreatedAt.In(loc),
+			CompletedAt: timeIn(t.CompletedAt, loc),
+		},
+		Steps:        []ReportStep{},
+		Dependencies: []ReportDependency{},
+		Summary:      strings.TrimSpace(in.Summary),
+		GeneratedAt:  in.Now,
+	}
+
+	ownSteps := make(map[uint64]string, len(t.Steps))
+	actualTotal, hasActual := 0, false
+	for i, s := range t.Steps {
+		ownSteps[s.ID] = s.Title
+		step := ReportStep{
+			ID:             s.ID,
+			Index:          i + 1,
+			Title:          s.Title,
+			Detail:         s.Detail,
+			Status:         s.Status,
+			Done:           s.Status == "done",
+			BlockingReason: s.BlockingReason,
+			EstimateMin:    s.EstimateMin,
+			PlannedStart:   flexibleTimePtr(s.PlannedStart, loc),
+			PlannedEnd:     flexibleTimePtr(s.PlannedEnd, loc),
+			CompletedAt:    timeIn(s.CompletedAt, loc),
+		}
+		if m, ok := in.ActualMinutes[s.ID]; ok {
+			m := m
+			step.ActualMin = &m
+			actualTotal += m
+			hasActual = true
+		}
+		if step.Done {
+			r.Progress.DoneSteps++
+		}
+		if s.EstimateMin != nil {
+			r.Progress.EstimateMin += *s.EstimateMin
+		}
+		r.Steps = append(r.Steps, step)
+	}
+	r.Progress.TotalSteps = len(t.Steps)
+	if r.Progress.TotalSteps > 0 {
+		r.Progress.Percent = r.Progress.DoneSteps * 100 / r.Progress.TotalSteps
+	} else if t.Status == "done" {
+		r.Progress.Percent = 100
+	}
+	if hasActual {
+		r.Progress.ActualMin = &actualTotal
+	}
+
+	for _, d := range in.Dependencies {
+		var dep ReportDependency
+		var otherStep, ownStep *uint64
+		switch {
+		case d.SuccessorTaskID == t.ID && d.PredecessorTaskID != t.ID:
+			dep.Direction = "blocked_by"
+			dep.TaskID = d.PredecessorTaskID
+			otherStep, ownStep = d.PredecessorStepID, d.SuccessorStepID
+		case d.PredecessorTaskID == t.ID && d.SuccessorTaskID != t.ID:
+			dep.Direction = "blocks"
+			dep.TaskID = d.SuccessorTaskID
+			otherStep, ownStep = d.SuccessorStepID, d.PredecessorStepID
+		default:
+			continue
+		}
+		dep.Condition = d.Condition
+		dep.Action = d.Action
+		if other, ok := in.RelatedTasks[dep.TaskID]; ok {
+			dep.TaskTitle = other.Title
+			if otherStep != nil {
+				for _, s := range other.Steps {
+					if s.ID == *otherStep {
+						dep.StepTitle = s.Title
+						break
+					}
+				}
+			}
+		} else {
+			dep.TaskTitle = fmt.Sprintf("#%d", dep.TaskID)
+		}
+		if ownStep != nil {
+			dep.OwnStep = ownSteps[*ownStep]
+		}
+		r.Dependencies = append(r.Dependencies, dep)
+	}
+
+	return r
+}
+
+func flexibleTimePtr(ft *domain.FlexibleTime, loc *time.Location) *time.Time {
+	if ft == nil || ft.IsZero() {
+		return nil
+	}
+	t := ft.ToTime().In(loc)
+	return &t
+}
+
+func timeIn(t *time.Time, loc *time.Location) *time.Time {
+	if t == nil {
+		return nil
+	}
+	in := t.In(loc)
+	return &in
+}
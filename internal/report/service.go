@@ -0,0 +1,134 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"assistant-qisumi/internal/domain"
+	"assistant-qisumi/internal/task"
+)
+
+// DurationProvider 提供步骤的实际耗时（分钟），未记录的步骤不出现在结果中
+type DurationProvider interface {
+	ActualMinutes(ctx context.Context, userID, taskID uint64) (map[uint64]int, error)
+}
+
+// ErrInvalidTemplate 用户模板无法解析或渲染
+var ErrInvalidTemplate = errors.New("invalid report template")
+
+type Service struct {
+	repo      *Repository
+	taskRepo  *task.Repository
+	durations DurationProvider
+}
+
+// NewService 创建报告服务，durations 为空时报告中的实际耗时显示为 "-"
+func NewService(repo *Repository, taskRepo *task.Repository, durations DurationProvider) *Service {
+	return &Service{repo: repo, taskRepo: taskRepo, durations: durations}
+}
+
+// Options 导出选项
+type Options struct {
+	Format         Format
+	IncludeSummary bool
+}
+
+// Build 收集任务、依赖、实际耗时和最新总结，生成报告数据
+func (s *Service) Build(ctx context.Context, userID, taskID uint64, includeSummary bool) (*Report, error) {
+	t, err := s.taskRepo.GetTaskWithSteps(ctx, userID, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	deps, err := s.taskRepo.GetTaskDependencies(ctx, userID, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	var relatedIDs []uint64
+	for _, d := range deps {
+		for _, id := range []uint64{d.PredecessorTaskID, d.SuccessorTaskID} {
+			if id != taskID {
+				relatedIDs = append(relatedIDs, id)
+			}
+		}
+	}
+	related, err := s.repo.ListTasksByIDs(ctx, userID, relatedIDs)
+	if err != nil {
+		return nil, err
+	}
+	relatedMap := make(map[uint64]*domain.Task, len(related))
+	for i := range related {
+		relatedMap[related[i].ID] = &related[i]
+	}
+
+	in := Input{
+		Task:         t,
+		Dependencies: deps,
+		RelatedTasks: relatedMap,
+		Now:          time.Now().In(domain.LocationFromContext(ctx)),
+	}
+	if s.durations != nil {
+		if in.ActualMinutes, err = s.durations.ActualMinutes(ctx, userID, taskID); err != nil {
+			return nil, err
+		}
+	}
+	if includeSummary {
+		if in.Summary, err = s.repo.LatestSummary(ctx, userID, taskID); err != nil {
+			return nil, err
+		}
+	}
+
+	return Build(in), nil
+}
+
+// Export 按指定格式渲染任务报告，优先使用用户自定义模板
+func (s *Service) Export(ctx context.Context, userID, taskID uint64, opts Options) ([]byte, error) {
+	r, err := s.Build(ctx, userID, taskID, opts.IncludeSummary)
+	if err != nil {
+		return nil, err
+	}
+
+	if !opts.Format.Templated() {
+		return json.MarshalIndent(r, "", "  ")
+	}
+
+	src, _, err := s.GetTemplate(ctx, userID, opts.Format)
+	if err != nil {
+		return nil, err
+	}
+	return render(opts.Format, src, r)
+}
+
+// GetTemplate 返回用户当前生效的模板源码，以及是否为自定义模板
+func (s *Service) GetTemplate(ctx context.Context, userID uint64, format Format) (string, bool, error) {
+	tpl, err := s.repo.GetTemplate(ctx, userID, format)
+	if err != nil {
+		return "", false, err
+	}
+	if tpl != nil {
+		return tpl.Content, true, nil
+	}
+	src, err := DefaultTemplate(format)
+	return src, false, err
+}
+
+// SaveTemplate 校验并保存用户自定义模板
+func (s *Service) SaveTemplate(ctx context.Context, userID uint64, format Format, content string) error {
+	if err := ValidateTemplate(format, content); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+	}
+	return s.repo.SaveTemplate(ctx, &domain.ReportTemplate{
+		UserID:  userID,
+		Format:  string(format),
+		Content: content,
+	})
+}
+
+// ResetTemplate 删除自定义模板，恢复内置模板
+func (s *Service) ResetTemplate(ctx context.Context, userID uint64, format Format) error {
+	return s.repo.DeleteTemplate(ctx, userID, format)
+}
@@ -0,0 +1,153 @@
+package report
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	texttemplate "text/template"
+	"time"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+// maxTemplateSize 用户自定义模板的大小上限
+const maxTemplateSize = 64 << 10
+
+var statusLabels = map[string]string{
+	"todo":        "待办",
+	"in_progress": "进行中",
+	"done":        "已完成",
+	"blocked":     "阻塞",
+	"locked":      "未解锁",
+	"cancelled":   "已取消",
+}
+
+var priorityLabels = map[string]string{
+	"high":   "高",
+	"medium": "中",
+	"low":    "低",
+}
+
+// templateFuncs 模板中可用的辅助函数，Markdown 和 HTML 模板共用
+var templateFuncs = map[string]any{
+	"checkbox": func(done bool) string {
+		if done {
+			return "[x]"
+		}
+		return "[ ]"
+	},
+	"status": func(s string) string {
+		if l, ok := statusLabels[s]; ok {
+			return l
+		}
+		return s
+	},
+	"priority": func(s string) string {
+		if l, ok := priorityLabels[s]; ok {
+			return l
+		}
+		return s
+	},
+	"datetime": func(t *time.Time) string {
+		if t == nil {
+			return "-"
+		}
+		return t.Format("2006-01-02 15:04")
+	},
+	"minutes": func(m *int) string {
+		if m == nil {
+			return "-"
+		}
+		return formatMinutes(*m)
+	},
+	"duration": formatMinutes,
+}
+
+func formatMinutes(m int) string {
+	if m < 60 {
+		return fmt.Sprintf("%d 分钟", m)
+	}
+	if m%60 == 0 {
+		return fmt.Sprintf("%d 小时", m/60)
+	}
+	return fmt.Sprintf("%d 小时 %d 分钟", m/60, m%60)
+}
+
+// executor 统一 text/template 和 html/template 的执行接口
+type executor interface {
+	Execute(w io.Writer, data any) error
+}
+
+// DefaultTemplate 返回内置模板源码
+func DefaultTemplate(format Format) (string, error) {
+	if !format.Templated() {
+		return "", fmt.Errorf("format %q does not use templates", format)
+	}
+	data, err := templateFS.ReadFile("templates/task." + format.Extension() + ".tmpl")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// parseTemplate 按格式解析模板：HTML 使用 html/template 自动转义，Markdown 使用 text/template
+func parseTemplate(format Format, src string) (executor, error) {
+	switch format {
+	case FormatHTML:
+		return htmltemplate.New("report").Funcs(htmltemplate.FuncMap(templateFuncs)).Parse(src)
+	case FormatMarkdown:
+		return texttemplate.New("report").Funcs(texttemplate.FuncMap(templateFuncs)).Parse(src)
+	default:
+		return nil, fmt.Errorf("format %q does not use templates", format)
+	}
+}
+
+// ValidateTemplate 校验用户模板：能解析，并且能用示例数据渲染
+func ValidateTemplate(format Format, src string) error {
+	if len(src) == 0 {
+		return errors.New("template is empty")
+	}
+	if len(src) > maxTemplateSize {
+		return fmt.Errorf("template is larger than %d bytes", maxTemplateSize)
+	}
+	tpl, err := parseTemplate(format, src)
+	if err != nil {
+		return err
+	}
+	return tpl.Execute(io.Discard, sampleReport())
+}
+
+func render(format Format, src string, r *Report) ([]byte, error) {
+	tpl, err := parseTemplate(format, src)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, r); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// sampleReport 用于校验模板的示例数据，尽量覆盖所有可选字段
+func sampleReport() *Report {
+	now := time.Now()
+	estimate, actual := 30, 45
+	return &Report{
+		Task: ReportTask{ID: 1, Title: "示例任务", Description: "描述", Status: "in_progress", Priority: "high", DueAt: &now, CreatedAt: now},
+		Steps: []ReportStep{
+			{ID: 1, Index: 1, Title: "步骤一", Status: "done", Done: true, EstimateMin: &estimate, ActualMin: &actual, CompletedAt: &now},
+			{ID: 2, Index: 2, Title: "步骤二", Status: "blocked", BlockingReason: "等待反馈", PlannedStart: &now, PlannedEnd: &now},
+		},
+		Dependencies: []ReportDependency{
+			{Direction: "blocked_by", TaskID: 2, TaskTitle: "前置任务", StepTitle: "前置步骤", OwnStep: "步骤二", Condition: "step_done", Action: "unlock_step"},
+		},
+		Progress:    Progress{TotalSteps: 2, DoneSteps: 1, Percent: 50, EstimateMin: 30, ActualMin: &actual},
+		Summary:     "示例总结",
+		GeneratedAt: now,
+	}
+}
@@ -0,0 +1,563 @@
+// Package scheduler 根据预估时长、依赖顺序、优先级、截止时间和工作时间，
+// 为步骤确定性地生成互不重叠的 planned_start / planned_end
+//
+// 算法是简单的列表调度：每次从「前置都已排好」的步骤里按
+// 进行中 > 截止时间早 > 优先级高 > 任务 ID > 步骤顺序 选出一个，
+// 放进它最早可以开始的空闲工作时段（已排的步骤和外部占用都视为忙碌）
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"assistant-qisumi/internal/domain"
+)
+
+const (
+	// DefaultEstimateMin 步骤没有预估时长时使用的默认值
+	DefaultEstimateMin = 30
+	// DefaultHorizon 最远排到多少天之后
+	DefaultHorizon = 60 * 24 * time.Hour
+	// slotGranularity 开始时间向上取整的粒度
+	slotGranularity = 5 * time.Minute
+)
+
+// Interval 一段被占用的时间 [Start, End)
+type Interval struct {
+	Start time.Time
+	End   time.Time
+}
+
+// WorkingHours 每天的工作时段及工作日
+type WorkingHours struct {
+	Start    time.Duration // 距离当天 0 点的偏移，例如 9h
+	End      time.Duration
+	Days     []time.Weekday
+	DaysOff  []string // 额外的休息日，YYYY-MM-DD
+	Location *time.Location
+}
+
+// DefaultWorkingHours 周一到周五 9:00-18:00
+func DefaultWorkingHours(loc *time.Location) WorkingHours {
+	if loc == nil {
+		loc = time.Local
+	}
+	return WorkingHours{
+		Start:    9 * time.Hour,
+		End:      18 * time.Hour,
+		Days:     []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+		Location: loc,
+	}
+}
+
+// ParseClock 解析 "HH:MM" 为距离 0 点的偏移
+func ParseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid clock %q, expected HH:MM", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func (h WorkingHours) validate() error {
+	if h.Start < 0 || h.End > 24*time.Hour || h.End <= h.Start {
+		return errors.New("working hours end must be after start")
+	}
+	if len(h.Days) == 0 {
+		return errors.New("at least one working day is required")
+	}
+	return nil
+}
+
+func (h WorkingHours) isWorkday(day time.Time) bool {
+	date := day.Format("2006-01-02")
+	for _, off := range h.DaysOff {
+		if off == date {
+			return false
+		}
+	}
+	for _, wd := range h.Days {
+		if wd == day.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// Input 排期输入
+type Input struct {
+	// Tasks 用户所有未完成的任务（含步骤），不在 TaskIDs 中的任务只作为占用和依赖参考
+	Tasks []domain.Task
+	// TaskIDs 需要排期的任务，为空表示 Tasks 中的全部任务
+	TaskIDs      []uint64
+	Dependencies []domain.TaskDependency
+	// Busy 额外的占用时段（例如会议）
+	Busy               []Interval
+	Hours              WorkingHours
+	Start              time.Time
+	DefaultEstimateMin int
+	Horizon            time.Duration
+}
+
+// Item 一个已排期的步骤
+type Item struct {
+	TaskID          uint64     `json:"taskId"`
+	StepID          uint64     `json:"stepId"`
+	TaskTitle       string     `json:"taskTitle"`
+	StepTitle       string     `json:"stepTitle"`
+	Start           time.Time  `json:"plannedStart"`
+	End             time.Time  `json:"plannedEnd"`
+	EstimateMin     int        `json:"estimateMinutes"`
+	EstimateAssumed bool       `json:"estimateAssumed,omitempty"` // 步骤没有预估，使用了默认时长
+	DueAt           *time.Time `json:"dueAt,omitempty"`
+	Late            bool       `json:"late,omitempty"` // 结束时间晚于任务截止时间
+}
+
+// Unscheduled 无法排期的步骤及原因
+type Unscheduled struct {
+	TaskID    uint64 `json:"taskId"`
+	StepID    uint64 `json:"stepId"`
+	TaskTitle string `json:"taskTitle"`
+	StepTitle string `json:"stepTitle"`
+	Reason    string `json:"reason"`
+}
+
+// Result 排期结果，Items 按开始时间排序
+type Result struct {
+	Items       []Item        `json:"items"`
+	Unscheduled []Unscheduled `json:"unscheduled"`
+	Warnings    []string      `json:"warnings"`
+}
+
+// node 待排期的步骤
+type node struct {
+	task  *domain.Task
+	step  *domain.TaskStep
+	preds []uint64 // 需要先排好的步骤 ID
+	// release 外部前置（未参与本次排期的任务）最早完成时间
+	release time.Time
+	// blockedBy 无法满足的原因，非空时不排期
+	blockedBy string
+	placed    bool
+	end       time.Time
+}
+
+var priorityRank = map[string]int{"high": 0, "medium": 1, "low": 2}
+
+// Schedule 计算排期
+func Schedule(in Input) (*Result, error) {
+	if err := in.Hours.validate(); err != nil {
+		return nil, err
+	}
+	loc := in.Hours.Location
+	if loc == nil {
+		loc = time.Local
+		in.Hours.Location = loc
+	}
+	if in.DefaultEstimateMin <= 0 {
+		in.DefaultEstimateMin = DefaultEstimateMin
+	}
+	if in.Horizon <= 0 {
+		in.Horizon = DefaultHorizon
+	}
+	start := roundUp(in.Start.In(loc), slotGranularity)
+	limit := start.Add(in.Horizon)
+
+	targets := make(map[uint64]bool, len(in.TaskIDs))
+	for _, id := range in.TaskIDs {
+		targets[id] = true
+	}
+	inScope := func(taskID uint64) bool { return len(targets) == 0 || targets[taskID] }
+
+	tasks := make(map[uint64]*domain.Task, len(in.Tasks))
+	stepOwner := make(map[uint64]*domain.Task)
+	stepByID := make(map[uint64]*domain.TaskStep)
+	for i := range in.Tasks {
+		t := &in.Tasks[i]
+		tasks[t.ID] = t
+		for j := range t.Steps {
+			stepOwner[t.Steps[j].ID] = t
+			stepByID[t.Steps[j].ID] = &t.Steps[j]
+		}
+	}
+
+	res := &Result{Items: []Item{}, Unscheduled: []Unscheduled{}, Warnings: []string{}}
+	busy := append([]Interval(nil), in.Busy...)
+
+	// 1. 构造节点：本次排期范围内未完成的步骤，任务内按顺序串行
+	nodes := make(map[uint64]*node)
+	var order []uint64
+	firstOpen := make(map[uint64]uint64) // 任务 ID -> 第一个未完成步骤
+	lastOpen := make(map[uint64]uint64)  // 任务 ID -> 最后一个未完成步骤
+	for i := range in.Tasks {
+		t := &in.Tasks[i]
+		steps := make([]*domain.TaskStep, 0, len(t.Steps))
+		for j := range t.Steps {
+			if t.Steps[j].Status != "done" {
+				steps = append(steps, &t.Steps[j])
+			}
+		}
+		sort.SliceStable(steps, func(a, b int) bool {
+			if steps[a].OrderIndex != steps[b].OrderIndex {
+				return steps[a].OrderIndex < steps[b].OrderIndex
+			}
+			return steps[a].ID < steps[b].ID
+		})
+
+		if !inScope(t.ID) {
+			// 范围外步骤的已有计划视为占用
+			for _, s := range steps {
+				if s.PlannedStart != nil && s.PlannedEnd != nil && s.PlannedEnd.ToTime().After(s.PlannedStart.ToTime()) {
+					busy = append(busy, Interval{Start: s.PlannedStart.ToTime(), End: s.PlannedEnd.ToTime()})
+				}
+			}
+			continue
+		}
+
+		var prev uint64
+		for _, s := range steps {
+			n := &node{task: t, step: s}
+			if prev != 0 {
+				n.preds = append(n.preds, prev)
+			} else {
+				firstOpen[t.ID] = s.ID
+			}
+			if s.Status == "blocked" {
+				n.blockedBy = "步骤处于阻塞状态"
+				if s.BlockingReason != "" {
+					n.blockedBy += "：" + s.BlockingReason
+				}
+			}
+			nodes[s.ID] = n
+			order = append(order, s.ID)
+			prev = s.ID
+		}
+		if prev != 0 {
+			lastOpen[t.ID] = prev
+		}
+	}
+
+	// 2. 依赖关系
+	for _, d := range in.Dependencies {
+		// 后继：指定步骤，或任务的第一个未完成步骤
+		var succ uint64
+		if d.SuccessorStepID != nil {
+			succ = *d.SuccessorStepID
+		} else {
+			succ = firstOpen[d.SuccessorTaskID]
+		}
+		n, ok := nodes[succ]
+		if !ok {
+			continue
+		}
+
+		// 前驱：指定步骤，或任务的最后一个未完成步骤
+		var pred uint64
+		predTask := tasks[d.PredecessorTaskID]
+		if d.PredecessorStepID != nil {
+			pred = *d.PredecessorStepID
+			if s, ok := stepByID[pred]; !ok || s.Status == "done" {
+				continue // 已完成或所在任务已结束
+			}
+		} else {
+			if predTask == nil {
+				continue // 前置任务已完成
+			}
+			pred = lastOpen[d.PredecessorTaskID]
+			if pred == 0 {
+				pred = lastOpenStep(predTask)
+			}
+			if pred == 0 {
+				continue
+			}
+		}
+		if pred == succ {
+			continue
+		}
+
+		if _, ok := nodes[pred]; ok {
+			n.preds = append(n.preds, pred)
+			continue
+		}
+		// 前置不在本次排期范围内：使用它已有的计划结束时间
+		ps := stepByID[pred]
+		if ps.PlannedEnd == nil || ps.PlannedEnd.IsZero() {
+			n.blockedBy = fmt.Sprintf("依赖的「%s / %s」尚未排期", stepOwner[pred].Title, ps.Title)
+			continue
+		}
+		if end := ps.PlannedEnd.ToTime(); end.After(n.release) {
+			n.release = end
+		}
+	}
+
+	// 3. 列表调度
+	remaining := len(order)
+	for remaining > 0 {
+		var ready []*node
+		for _, id := range order {
+			n := nodes[id]
+			if n.placed || n.blockedBy != "" {
+				continue
+			}
+			ok := true
+			for _, p := range n.preds {
+				pn := nodes[p]
+				if !pn.placed {
+					ok = false
+					break
+				}
+			}
+			if ok {
+				ready = append(ready, n)
+			}
+		}
+		if len(ready) == 0 {
+			break
+		}
+		sort.SliceStable(ready, func(a, b int) bool { return less(ready[a], ready[b]) })
+		n := ready[0]
+
+		earliest := start
+		if n.release.After(earliest) {
+			earliest = roundUp(n.release.In(loc), slotGranularity)
+		}
+		for _, p := range n.preds {
+			if e := nodes[p].end; e.After(earliest) {
+				earliest = e
+			}
+		}
+
+		minutes := in.DefaultEstimateMin
+		assumed := true
+		if n.step.EstimateMin != nil && *n.step.EstimateMin > 0 {
+			minutes = *n.step.EstimateMin
+			assumed = false
+		}
+
+		s, e, ok := place(busy, in.Hours, earliest, time.Duration(minutes)*time.Minute, limit)
+		if !ok {
+			n.blockedBy = "超出可排期范围"
+			continue
+		}
+		n.placed = true
+		n.end = e
+		remaining--
+		busy = append(busy, Interval{Start: s, End: e})
+
+		item := Item{
+			TaskID:          n.task.ID,
+			StepID:          n.step.ID,
+			TaskTitle:       n.task.Title,
+			StepTitle:       n.step.Title,
+			Start:           s,
+			End:             e,
+			EstimateMin:     minutes,
+			EstimateAssumed: assumed,
+		}
+		if n.task.DueAt != nil && !n.task.DueAt.IsZero() {
+			due := n.task.DueAt.ToTime()
+			item.DueAt = &due
+			item.Late = e.After(due)
+		}
+		res.Items = append(res.Items, item)
+	}
+
+	// 4. 未能排期的步骤：自身被阻塞，或前置未能排期
+	for _, id := range order {
+		n := nodes[id]
+		if n.placed {
+			continue
+		}
+		reason := n.blockedBy
+		if reason == "" {
+			reason = "前置步骤未能排期"
+		}
+		res.Unscheduled = append(res.Unscheduled, Unscheduled{
+			TaskID:    n.task.ID,
+			StepID:    n.step.ID,
+			TaskTitle: n.task.Title,
+			StepTitle: n.step.Title,
+			Reason:    reason,
+		})
+	}
+
+	sort.SliceStable(res.Items, func(a, b int) bool { return res.Items[a].Start.Before(res.Items[b].Start) })
+	res.Warnings = buildWarnings(res.Items)
+	return res, nil
+}
+
+// less 就绪步骤的优先顺序
+func less(a, b *node) bool {
+	ap, bp := a.step.Status == "in_progress", b.step.Status == "in_progress"
+	if ap != bp {
+		return ap
+	}
+	ad, bd := dueOf(a.task), dueOf(b.task)
+	if !ad.Equal(bd) {
+		if ad.IsZero() || bd.IsZero() {
+			return bd.IsZero()
+		}
+		return ad.Before(bd)
+	}
+	ar, br := rank(a.task.Priority), rank(b.task.Priority)
+	if ar != br {
+		return ar < br
+	}
+	if a.task.ID != b.task.ID {
+		return a.task.ID < b.task.ID
+	}
+	if a.step.OrderIndex != b.step.OrderIndex {
+		return a.step.OrderIndex < b.step.OrderIndex
+	}
+	return a.step.ID < b.step.ID
+}
+
+func dueOf(t *domain.Task) time.Time {
+	if t.DueAt == nil {
+		return time.Time{}
+	}
+	return t.DueAt.ToTime()
+}
+
+func rank(p string) int {
+	if r, ok := priorityRank[p]; ok {
+		return r
+	}
+	return priorityRank["medium"]
+}
+
+func lastOpenStep(t *domain.Task) uint64 {
+	var last *domain.TaskStep
+	for i := range t.Steps {
+		s := &t.Steps[i]
+		if s.Status == "done" {
+			continue
+		}
+		if last == nil || s.OrderIndex > last.OrderIndex || (s.OrderIndex == last.OrderIndex && s.ID > last.ID) {
+			last = s
+		}
+	}
+	if last == nil {
+		return 0
+	}
+	return last.ID
+}
+
+// place 找到 earliest 之后第一个能放下 d 的位置
+// 不超过一天工作时长的步骤必须放在一段连续空闲时间里；更长的步骤按顺序占用多个空闲段，
+// 但段与段之间只能隔着非工作时间：步骤只记录一个 [start, end)，中间有占用时会与之重叠，这时从下一段重新开始
+func place(busy []Interval, h WorkingHours, earliest time.Time, d time.Duration, limit time.Time) (time.Time, time.Time, bool) {
+	sorted := append([]Interval(nil), busy...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+
+	contiguous := d <= h.End-h.Start
+	t := earliest
+	var start, prevEnd time.Time
+	remaining := d
+	for t.Before(limit) {
+		segStart, segEnd, ok := nextFree(sorted, h, t, limit)
+		if !ok {
+			return time.Time{}, time.Time{}, false
+		}
+		length := segEnd.Sub(segStart)
+		if contiguous {
+			if length >= d {
+				return segStart, segStart.Add(d), true
+			}
+			t = segEnd
+			continue
+		}
+		if start.IsZero() || overlapsAny(sorted, prevEnd, segStart) {
+			start, remaining = segStart, d
+		}
+		if length >= remaining {
+			return start, segStart.Add(remaining), true
+		}
+		remaining -= length
+		prevEnd = segEnd
+		t = segEnd
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+// overlapsAny 判断 [start, end) 是否与任何占用时段重叠
+func overlapsAny(sorted []Interval, start, end time.Time) bool {
+	for _, b := range sorted {
+		if b.Start.Before(end) && b.End.After(start) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextFree 返回 t 之后最早的一段空闲工作时间 [start, end)
+func nextFree(sorted []Interval, h WorkingHours, t, limit time.Time) (time.Time, time.Time, bool) {
+	loc := h.Location
+	t = t.In(loc)
+	for !t.After(limit) {
+		y, m, d := t.Date()
+		day := time.Date(y, m, d, 0, 0, 0, 0, loc)
+		next := time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+		if h.isWorkday(day) {
+			ws, we := day.Add(h.Start), day.Add(h.End)
+			s := t
+			if s.Before(ws) {
+				s = ws
+			}
+			for s.Before(we) {
+				moved := false
+				for _, b := range sorted {
+					if !b.End.After(s) || !b.Start.Before(we) {
+						continue
+					}
+					if !b.Start.After(s) {
+						s = b.End
+						moved = true
+						break
+					}
+					return s, b.Start, true
+				}
+				if !moved {
+					return s, we, true
+				}
+			}
+		}
+		t = next
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+func roundUp(t time.Time, d time.Duration) time.Time {
+	r := t.Truncate(d)
+	if r.Before(t) {
+		r = r.Add(d)
+	}
+	return r
+}
+
+// buildWarnings 为每个会错过截止时间的任务生成一条提醒
+func buildWarnings(items []Item) []string {
+	warnings := []string{}
+	lateEnd := make(map[uint64]Item)
+	var lateOrder []uint64
+	for _, it := range items {
+		if !it.Late {
+			continue
+		}
+		prev, ok := lateEnd[it.TaskID]
+		if !ok {
+			lateOrder = append(lateOrder, it.TaskID)
+		}
+		if !ok || it.End.After(prev.End) {
+			lateEnd[it.TaskID] = it
+		}
+	}
+	for _, id := range lateOrder {
+		it := lateEnd[id]
+		warnings = append(warnings, fmt.Sprintf("「%s」预计 %s 才能完成，晚于截止时间 %s",
+			it.TaskTitle, it.End.Format("2006-01-02 15:04"), it.DueAt.In(it.End.Location()).Format("2006-01-02 15:04")))
+	}
+	return warnings
+}
@@ -3,6 +3,8 @@ package session
 import (
 	"context"
 
+	"assistant-qisumi/internal/domain"
+
 	"gorm.io/gorm"
 )
 
@@ -27,8 +29,14 @@ func (r *Repository) GetSession(ctx context.Context, sessionID uint64) (*Session
 	return &s, nil
 }
 
+// CreateMessage 保存消息并更新会话的最后活跃时间
 func (r *Repository) CreateMessage(ctx context.Context, m *Message) error {
-	return r.db.WithContext(ctx).Create(m).Error
+	if err := r.db.WithContext(ctx).Create(m).Error; err != nil {
+		return err
+	}
+	return r.db.WithContext(ctx).Model(&Session{}).
+		Where("id = ?", m.SessionID).
+		UpdateColumn("last_message_at", m.CreatedAt).Error
 }
 
 func (r *Repository) ListRecentMessages(ctx context.Context, sessionID uint64, limit int) ([]Message, error) {
@@ -54,29 +62,102 @@ func reverseSlice[T any](s []T) {
 	}
 }
 
-// GetTaskSessionOrCreate: 针对某个 user + task 找到一个 task session，没有就创建。
-func (r *Repository) GetTaskSessionOrCreate(ctx context.Context, userID, taskID uint64) (*Session, error) {
-	var sess Session
-	result := r.db.WithContext(ctx).
-		Where("user_id = ? AND task_id = ? AND type = 'task'", userID, taskID).
+// currentSession 查找某个范围内没有归档、最近活跃的会话，不存在时返回 nil
+func (r *Repository) currentSession(ctx context.Context, userID uint64, sessType string, taskID *uint64) (*Session, error) {
+	q := r.db.WithContext(ctx).
+		Where("user_id = ? AND type = ? AND archived_at IS NULL", userID, sessType)
+	if taskID != nil {
+		q = q.Where("task_id = ?", *taskID)
+	}
+	var sessions []Session
+	err := q.Order("COALESCE(last_message_at, created_at) DESC, id DESC").
 		Limit(1).
-		Find(&sess)
-	if result.Error != nil {
-		return nil, result.Error
+		Find(&sessions).Error
+	if err != nil || len(sessions) == 0 {
+		return nil, err
 	}
-	if result.RowsAffected > 0 {
-		return &sess, nil
+	return &sessions[0], nil
+}
+
+// GetTaskSessionOrCreate: 针对某个 user + task 找到当前的 task session（没有归档、最近活跃），没有就创建。
+func (r *Repository) GetTaskSessionOrCreate(ctx context.Context, userID, taskID uint64) (*Session, error) {
+	sess, err := r.currentSession(ctx, userID, "task", &taskID)
+	if err != nil || sess != nil {
+		return sess, err
 	}
 
-	sess = Session{
+	sess = &Session{
 		UserID: userID,
 		TaskID: &taskID,
 		Type:   "task",
 	}
-	if err := r.db.WithContext(ctx).Create(&sess).Error; err != nil {
+	if err := r.CreateSession(ctx, sess); err != nil {
+		return nil, err
+	}
+	return sess, nil
+}
+
+// CreateSession 新建会话，之前的会话和消息保持不变
+func (r *Repository) CreateSession(ctx context.Context, sess *Session) error {
+	return r.db.WithContext(ctx).Create(sess).Error
+}
+
+// SaveSession 保存会话的标题和归档状态
+func (r *Repository) SaveSession(ctx context.Context, sess *Session) error {
+	return r.db.WithContext(ctx).Model(sess).
+		Select("title", "titled", "archived_at").
+		Updates(sess).Error
+}
+
+// SetTitleIfUntitled 会话还没有命名过时设置标题，用于按第一条用户消息自动命名；
+// 用户设置过（包括清空）的标题不会被覆盖
+func (r *Repository) SetTitleIfUntitled(ctx context.Context, sessionID uint64, title string) error {
+	return r.db.WithContext(ctx).Model(&Session{}).
+		Where("id = ? AND titled = ? AND title = ''", sessionID, false).
+		UpdateColumns(map[string]interface{}{"title": title, "titled": true}).Error
+}
+
+// SessionFilter 列出会话的条件，零值表示不限制；Archived 为空时只列出没有归档的会话
+type SessionFilter struct {
+	Type     string
+	TaskID   *uint64
+	Archived *bool
+}
+
+// ListSessions 列出用户的会话，最近活跃的在前
+func (r *Repository) ListSessions(ctx context.Context, userID uint64, f SessionFilter) ([]Session, error) {
+	q := r.db.WithContext(ctx).Where("user_id = ?", userID)
+	if f.Type != "" {
+		q = q.Where("type = ?", f.Type)
+	}
+	if f.TaskID != nil {
+		q = q.Where("task_id = ?", *f.TaskID)
+	}
+	if f.Archived != nil && *f.Archived {
+		q = q.Where("archived_at IS NOT NULL")
+	} else {
+		q = q.Where("archived_at IS NULL")
+	}
+	var sessions []Session
+	err := q.Order("COALESCE(last_message_at, created_at) DESC, id DESC").Find(&sessions).Error
+	return sessions, err
+}
+
+// ListRecentTaskMessages 列出任务所有会话中最近的 limit 条消息，最早的在前
+func (r *Repository) ListRecentTaskMessages(ctx context.Context, userID, taskID uint64, limit int) ([]Message, error) {
+	var messages []Message
+	err := r.db.WithContext(ctx).
+		Where("session_id IN (?)", r.db.Model(&Session{}).
+			Select("id").
+			Where("user_id = ? AND task_id = ? AND type = 'task'", userID, taskID)).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Find(&messages).Error
+	if err != nil {
 		return nil, err
 	}
-	return &sess, nil
+	reverseSlice(messages)
+	return messages, nil
 }
 
 // CreateSystemMessage 在指定 session 中插入 system 消息
@@ -87,7 +168,7 @@ func (r *Repository) CreateSystemMessage(ctx context.Context, sessionID uint64,
 		AgentName: agentName,
 		Content:   content,
 	}
-	return r.db.WithContext(ctx).Create(&msg).Error
+	return r.CreateMessage(ctx, &msg)
 }
 
 // CreateSystemMessageForTask: 针对某个任务（以及用户）写一条系统消息。
@@ -105,33 +186,29 @@ func (r *Repository) CreateSystemMessageForTask(
 	return r.CreateSystemMessage(ctx, sess.ID, &systemAgent, content)
 }
 
-// GetGlobalSessionOrCreate: 获取或创建全局会话
+// GetGlobalSessionOrCreate: 获取当前的全局会话（没有归档、最近活跃），没有就创建
 func (r *Repository) GetGlobalSessionOrCreate(ctx context.Context, userID uint64) (*Session, error) {
-	var sess Session
-	result := r.db.WithContext(ctx).
-		Where("user_id = ? AND type = 'global'", userID).
-		Limit(1).
-		Find(&sess)
-	if result.Error != nil {
-		return nil, result.Error
-	}
-	if result.RowsAffected > 0 {
-		return &sess, nil
+	sess, err := r.currentSession(ctx, userID, "global", nil)
+	if err != nil || sess != nil {
+		return sess, err
 	}
 
-	sess = Session{
+	sess = &Session{
 		UserID: userID,
 		Type:   "global",
 	}
-	if err := r.db.WithContext(ctx).Create(&sess).Error; err != nil {
+	if err := r.CreateSession(ctx, sess); err != nil {
 		return nil, err
 	}
-	return &sess, nil
+	return sess, nil
 }
 
-// ClearMessages 清空指定 session 的所有消息
+// ClearMessages 清空指定 session 的所有消息，较早消息的滚动摘要一并删除
 func (r *Repository) ClearMessages(ctx context.Context, sessionID uint64) error {
-	return r.db.WithContext(ctx).
-		Where("session_id = ?", sessionID).
-		Delete(&Message{}).Error
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("session_id = ?", sessionID).Delete(&Message{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("session_id = ?", sessionID).Delete(&domain.SessionSummary{}).Error
+	})
 }
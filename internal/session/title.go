@@ -0,0 +1,22 @@
+package session
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// maxTitleLength 自动生成的会话标题最多的字符数
+const maxTitleLength = 30
+
+// TitleFromMessage 用第一条用户消息的第一行生成会话标题，过长时截断并加省略号
+func TitleFromMessage(content string) string {
+	line := strings.TrimSpace(content)
+	if i := strings.IndexByte(line, '\n'); i >= 0 {
+		line = strings.TrimSpace(line[:i])
+	}
+	line = strings.Join(strings.Fields(line), " ")
+	if utf8.RuneCountInString(line) <= maxTitleLength {
+		return line
+	}
+	return string([]rune(line)[:maxTitleLength]) + "…"
+}
@@ -0,0 +1,109 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"assistant-qisumi/internal/domain"
+	"assistant-qisumi/internal/jsonschema"
+	"assistant-qisumi/internal/llm"
+	"assistant-qisumi/internal/logger"
+	"assistant-qisumi/internal/prompts"
+
+	"go.uber.org/zap"
+)
+
+// ErrInvalidTaskCreation 修正一轮之后模型的输出仍不符合 domain.TaskCreationSchema
+var ErrInvalidTaskCreation = errors.New("invalid task creation output")
+
+// GenerateTaskCreation 调用 LLM 把 messages 中的文本转换为任务创建结果
+//
+// 协议支持时使用 JSON Schema 结构化输出，否则（或提供商拒绝 response_format 时）强制模型调用 create_task 工具。
+// 输出不符合 Schema 时把校验错误反馈给模型自动修正一次，仍不符合时返回 ErrInvalidTaskCreation。
+// 截止时间按模型原样解析，调用方需要自行按用户时区处理不带时区的时间。
+func GenerateTaskCreation(ctx context.Context, client llm.Client, cfg llm.Config, messages []llm.Message) (*domain.TaskCreationOutput, error) {
+	structured := llm.SupportsJSONSchema(cfg)
+	messages = append([]llm.Message(nil), messages...)
+
+	for repaired := false; ; repaired = true {
+		resp, err := client.Chat(ctx, cfg, taskCreationRequest(cfg, messages, structured))
+		if err != nil && structured && llm.IsResponseFormatRejected(err) {
+			logger.Logger.Warn("提供商不支持结构化输出，改用 create_task 工具",
+				zap.String("model", cfg.Model),
+				zap.Error(err),
+			)
+			structured = false
+			resp, err = client.Chat(ctx, cfg, taskCreationRequest(cfg, messages, structured))
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Choices) == 0 {
+			return nil, fmt.Errorf("%w: llm returned empty response", ErrInvalidTaskCreation)
+		}
+
+		msg := resp.Choices[0].Message
+		content, call := taskCreationContent(msg)
+		output, err := domain.ParseTaskCreationResponse(content)
+		if err == nil {
+			return output, nil
+		}
+		var verr *jsonschema.ValidationError
+		if repaired || !errors.As(err, &verr) {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidTaskCreation, err)
+		}
+
+		logger.Logger.Info("任务创建结果校验失败，要求模型修正",
+			zap.Bool("structured", structured),
+			zap.String("errors", verr.Error()),
+		)
+		messages = append(messages, repairMessages(msg, call, verr)...)
+	}
+}
+
+func taskCreationRequest(cfg llm.Config, messages []llm.Message, structured bool) llm.ChatRequest {
+	req := llm.ChatRequest{Model: cfg.Model, Messages: messages}
+	if structured {
+		req.ResponseFormat = &llm.ResponseFormat{
+			Name:   "task_creation",
+			Schema: json.RawMessage(domain.TaskCreationSchema),
+		}
+	} else {
+		req.Tools = []llm.Tool{llm.CreateTaskTool()}
+		req.ToolChoice = "required"
+	}
+	return req
+}
+
+// taskCreationContent 优先取 create_task 工具调用的参数；模型直接回复了文本时使用文本
+func taskCreationContent(msg llm.ChatMessage) (string, *llm.ToolCall) {
+	for i, call := range msg.ToolCalls {
+		if call.Function.Name == llm.CreateTaskTool().Function.Name {
+			return call.Function.Arguments, &msg.ToolCalls[i]
+		}
+	}
+	return msg.Content, nil
+}
+
+// repairMessages 把模型上一次的输出和校验错误追加到对话中：
+// 工具调用以工具结果的形式反馈，文本输出以用户消息的形式反馈
+func repairMessages(msg llm.ChatMessage, call *llm.ToolCall, verr *jsonschema.ValidationError) []llm.Message {
+	errs := make([]string, len(verr.Errors))
+	for i, fe := range verr.Errors {
+		errs[i] = fe.String()
+	}
+	feedback := prompts.RepairMessage(errs)
+
+	if call != nil {
+		return []llm.Message{
+			{Role: "assistant", Content: msg.Content, ToolCalls: []llm.ToolCall{*call}},
+			{Role: "tool", ToolCallID: call.ID, Name: call.Function.Name, Content: feedback},
+		}
+	}
+	return []llm.Message{
+		{Role: "assistant", Content: msg.Content},
+		{Role: "user", Content: feedback},
+	}
+}
@@ -14,4 +14,6 @@ type FlexibleTime = domain.FlexibleTime
 
 // 导出 domain 包的时间解析函数
 var ParseFlexibleTime = domain.ParseFlexibleTime
+var ParseFlexibleTimeIn = domain.ParseFlexibleTimeIn
+var LocationFromContext = domain.LocationFromContext
 var ParseRFC3339 = domain.ParseRFC3339
@@ -0,0 +1,66 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrNotOwned 任务不存在或不属于该用户，步骤不存在或不属于指定任务
+var ErrNotOwned = errors.New("not found")
+
+// StepRef 指定任务下的一个步骤
+type StepRef struct {
+	TaskID uint64
+	StepID uint64
+}
+
+// CheckOwnership 校验任务都属于该用户、步骤都属于对应任务，模型给出的 ID 在写入前都要经过校验
+func (r *Repository) CheckOwnership(ctx context.Context, userID uint64, taskIDs []uint64, steps []StepRef) error {
+	for _, s := range steps {
+		taskIDs = append(taskIDs, s.TaskID)
+	}
+	ids := uniqueIDs(taskIDs)
+	if len(ids) > 0 {
+		var owned []uint64
+		if err := r.db.WithContext(ctx).Model(&Task{}).
+			Where("user_id = ? AND id IN ?", userID, ids).
+			Pluck("id", &owned).Error; err != nil {
+			return err
+		}
+		found := make(map[uint64]bool, len(owned))
+		for _, id := range owned {
+			found[id] = true
+		}
+		for _, id := range ids {
+			if !found[id] {
+				return fmt.Errorf("task %d: %w", id, ErrNotOwned)
+			}
+		}
+	}
+
+	for _, s := range steps {
+		var n int64
+		if err := r.db.WithContext(ctx).Model(&TaskStep{}).
+			Where("id = ? AND task_id = ?", s.StepID, s.TaskID).
+			Count(&n).Error; err != nil {
+			return err
+		}
+		if n == 0 {
+			return fmt.Errorf("step %d of task %d: %w", s.StepID, s.TaskID, ErrNotOwned)
+		}
+	}
+	return nil
+}
+
+func uniqueIDs(ids []uint64) []uint64 {
+	seen := make(map[uint64]bool, len(ids))
+	out := make([]uint64, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
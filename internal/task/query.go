@@ -0,0 +1,83 @@
+package task
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TaskFilter 任务查询条件，零值字段表示不限制
+type TaskFilter struct {
+	IDs        []uint64
+	Statuses   []string
+	Priority   string
+	FocusToday *bool
+	DueBefore  *time.Time // 只返回截止时间早于该时间的任务
+	Limit      int
+}
+
+// FindTasks 按条件查询任务及其步骤，有截止时间的任务按截止时间在前，其余按创建时间倒序
+func (r *Repository) FindTasks(ctx context.Context, userID uint64, f TaskFilter) ([]Task, error) {
+	q := r.db.WithContext(ctx).
+		Preload("Steps", orderSteps).
+		Where("user_id = ?", userID)
+	if len(f.IDs) > 0 {
+		q = q.Where("id IN ?", f.IDs)
+	}
+	if len(f.Statuses) > 0 {
+		q = q.Where("status IN ?", f.Statuses)
+	}
+	if f.Priority != "" {
+		q = q.Where("priority = ?", f.Priority)
+	}
+	if f.FocusToday != nil {
+		q = q.Where("is_focus_today = ?", *f.FocusToday)
+	}
+
+	var tasks []Task
+	if err := q.Order("due_at IS NULL, due_at ASC, created_at DESC").Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+
+	// 截止时间在内存中比较，避免不同数据库时间格式的差异
+	out := tasks[:0]
+	for _, t := range tasks {
+		if f.DueBefore != nil && (t.DueAt == nil || !t.DueAt.ToTime().Before(*f.DueBefore)) {
+			continue
+		}
+		out = append(out, t)
+		if f.Limit > 0 && len(out) == f.Limit {
+			break
+		}
+	}
+	return out, nil
+}
+
+// SearchTasks 在任务标题、描述以及步骤标题、说明中搜索关键字（不区分大小写），返回命中的任务及其全部步骤
+func (r *Repository) SearchTasks(ctx context.Context, userID uint64, query string, limit int) ([]Task, error) {
+	pattern := "%" + escapeLike(strings.ToLower(strings.TrimSpace(query))) + "%"
+	q := r.db.WithContext(ctx).
+		Preload("Steps", orderSteps).
+		Where("user_id = ?", userID).
+		Where("LOWER(title) LIKE ? ESCAPE '!' OR LOWER(description) LIKE ? ESCAPE '!' OR "+
+			"id IN (SELECT task_id FROM task_steps WHERE LOWER(title) LIKE ? ESCAPE '!' OR LOWER(detail) LIKE ? ESCAPE '!')",
+			pattern, pattern, pattern, pattern).
+		Order("updated_at DESC")
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+	var tasks []Task
+	err := q.Find(&tasks).Error
+	return tasks, err
+}
+
+func orderSteps(db *gorm.DB) *gorm.DB {
+	return db.Order("order_index ASC")
+}
+
+// escapeLike 转义 LIKE 通配符，配合 ESCAPE '!' 使用（反斜杠在 MySQL 字符串中需要再次转义）
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"time"
 
+	"assistant-qisumi/internal/timetrack"
+
 	"gorm.io/gorm"
 )
 
@@ -62,13 +64,26 @@ func (r *Repository) ListCompletedTasks(ctx context.Context, userID uint64) ([]T
 	return tasks, err
 }
 
+// ListOpenTasksWithSteps 获取用户未完成（且未取消）的任务及其步骤，供排期使用
+func (r *Repository) ListOpenTasksWithSteps(ctx context.Context, userID uint64) ([]Task, error) {
+	var tasks []Task
+	err := r.db.WithContext(ctx).
+		Preload("Steps", func(db *gorm.DB) *gorm.DB {
+			return db.Order("order_index ASC")
+		}).
+		Where("user_id = ? AND status NOT IN ?", userID, []string{"done", "cancelled"}).
+		Order("id ASC").
+		Find(&tasks).Error
+	return tasks, err
+}
+
 // ApplyUpdateTaskFields 动态更新 tasks
 func (r *Repository) ApplyUpdateTaskFields(
 	ctx context.Context,
 	userID, taskID uint64,
 	fields UpdateTaskFields,
 ) error {
-	updates, err := buildTaskUpdateMap(fields)
+	updates, err := buildTaskUpdateMap(fields, LocationFromContext(ctx))
 	if err != nil {
 		return err
 	}
@@ -88,8 +103,9 @@ func (r *Repository) ApplyUpdateStepFields(
 	userID, taskID, stepID uint64,
 	fields UpdateStepFields,
 ) error {
-	// 如果需要更新 status，需要先查询当前状态来决定是否自动设置 completedAt
-	if fields.Status != nil && fields.CompletedAt == nil {
+	// 如果需要更新 status，需要先查询当前状态来决定是否自动设置 completedAt 以及维护自动时间记录
+	var previousStatus string
+	if fields.Status != nil {
 		var currentStep TaskStep
 		subQuery := r.db.
 			Select("id").
@@ -103,20 +119,25 @@ func (r *Repository) ApplyUpdateStepFields(
 			return err
 		}
 
+		previousStatus = currentStep.Status
+
 		// 自动处理 completedAt：当状态变为 done 时设置为当前时间，否则清除
+		// 调用方显式指定了 completedAt 时不覆盖
 		now := r.db.NowFunc()
-		if *fields.Status == "done" && currentStep.Status != "done" {
-			// 状态从非 done 变为 done，设置 completedAt
-			completedAtStr := now.Format(time.RFC3339)
-			fields.CompletedAt = &completedAtStr
-		} else if *fields.Status != "done" && currentStep.Status == "done" {
-			// 状态从 done 变为非 done，清除 completedAt
-			emptyStr := ""
-			fields.CompletedAt = &emptyStr
+		if fields.CompletedAt == nil {
+			if *fields.Status == "done" && currentStep.Status != "done" {
+				// 状态从非 done 变为 done，设置 completedAt
+				completedAtStr := now.Format(time.RFC3339)
+				fields.CompletedAt = &completedAtStr
+			} else if *fields.Status != "done" && currentStep.Status == "done" {
+				// 状态从 done 变为非 done，清除 completedAt
+				emptyStr := ""
+				fields.CompletedAt = &emptyStr
+			}
 		}
 	}
 
-	updates, err := buildStepUpdateMap(fields)
+	updates, err := buildStepUpdateMap(fields, LocationFromContext(ctx))
 	if err != nil {
 		return err
 	}
@@ -130,10 +151,18 @@ func (r *Repository) ApplyUpdateStepFields(
 		Table("tasks").
 		Where("id = ? AND user_id = ?", taskID, userID)
 
-	return r.db.WithContext(ctx).
-		Model(&TaskStep{}).
-		Where("id = ? AND task_id IN (?)", stepID, subQuery).
-		Updates(updates).Error
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&TaskStep{}).
+			Where("id = ? AND task_id IN (?)", stepID, subQuery).
+			Updates(updates).Error; err != nil {
+			return err
+		}
+		if fields.Status == nil {
+			return nil
+		}
+		// 状态变化时维护自动时间记录（in_progress -> done 的兜底耗时）
+		return timetrack.NewRepository(tx).OnStepStatusChange(ctx, userID, taskID, stepID, previousStatus, *fields.Status, r.db.NowFunc())
+	})
 }
 
 // AddStep 添加新步骤
@@ -220,7 +249,8 @@ func (r *Repository) MarkTasksFocusToday(ctx context.Context, userID uint64, tas
 }
 
 // 把 UpdateTaskFields 转成 GORM Updates 使用s的 map
-func buildTaskUpdateMap(fields UpdateTaskFields) (map[string]any, error) {
+// loc 为用户时区，用于解析不带时区的时间
+func buildTaskUpdateMap(fields UpdateTaskFields, loc *time.Location) (map[string]any, error) {
 	updates := make(map[string]any)
 
 	setIfNotNil(updates, "title", fields.Title)
@@ -229,7 +259,7 @@ func buildTaskUpdateMap(fields UpdateTaskFields) (map[string]any, error) {
 	setIfNotNil(updates, "priority", fields.Priority)
 	setIfNotNil(updates, "is_focus_today", fields.IsFocusToday)
 	
-	if err := setFlexibleTimeField(updates, "due_at", fields.DueAt); err != nil {
+	if err := setFlexibleTimeField(updates, "due_at", fields.DueAt, loc); err != nil {
 		return nil, err
 	}
 	if err := setRFC3339TimeField(updates, "completed_at", fields.CompletedAt); err != nil {
@@ -239,7 +269,7 @@ func buildTaskUpdateMap(fields UpdateTaskFields) (map[string]any, error) {
 	return updates, nil
 }
 
-func buildStepUpdateMap(fields UpdateStepFields) (map[string]any, error) {
+func buildStepUpdateMap(fields UpdateStepFields, loc *time.Location) (map[string]any, error) {
 	updates := make(map[string]any)
 
 	setIfNotNil(updates, "title", fields.Title)
@@ -249,10 +279,10 @@ func buildStepUpdateMap(fields UpdateStepFields) (map[string]any, error) {
 	setIfNotNil(updates, "estimate_minutes", fields.EstimateMin)
 	setIfNotNil(updates, "order_index", fields.OrderIndex)
 	
-	if err := setFlexibleTimeField(updates, "planned_start", fields.PlannedStart); err != nil {
+	if err := setFlexibleTimeField(updates, "planned_start", fields.PlannedStart, loc); err != nil {
 		return nil, err
 	}
-	if err := setFlexibleTimeField(updates, "planned_end", fields.PlannedEnd); err != nil {
+	if err := setFlexibleTimeField(updates, "planned_end", fields.PlannedEnd, loc); err != nil {
 		return nil, err
 	}
 	if err := setRFC3339TimeField(updates, "completed_at", fields.CompletedAt); err != nil {
@@ -270,7 +300,7 @@ func setIfNotNil[T any](m map[string]any, key string, value *T) {
 }
 
 // setFlexibleTimeField 设置 FlexibleTime 字段
-func setFlexibleTimeField(m map[string]any, key string, value *string) error {
+func setFlexibleTimeField(m map[string]any, key string, value *string, loc *time.Location) error {
 	if value == nil {
 		return nil
 	}
@@ -278,7 +308,7 @@ func setFlexibleTimeField(m map[string]any, key string, value *string) error {
 		m[key] = nil
 		return nil
 	}
-	ft, err := ParseFlexibleTime(*value)
+	ft, err := ParseFlexibleTimeIn(*value, loc)
 	if err != nil {
 		return err
 	}
@@ -322,11 +352,14 @@ func (r *Repository) DeleteTask(ctx context.Context, userID, taskID uint64) erro
 			return err
 		}
 
-		// 4. 删除会话的消息
+		// 4. 删除会话的消息和滚动摘要
 		if len(sessionIDs) > 0 {
 			if err := tx.Table("messages").Where("session_id IN ?", sessionIDs).Delete(nil).Error; err != nil {
 				return err
 			}
+			if err := tx.Table("session_summaries").Where("session_id IN ?", sessionIDs).Delete(nil).Error; err != nil {
+				return err
+			}
 		}
 
 		// 5. 删除会话
@@ -334,7 +367,17 @@ func (r *Repository) DeleteTask(ctx context.Context, userID, taskID uint64) erro
 			return err
 		}
 
-		// 6. 删除任务本身（带 user_id 验证）
+		// 6. 删除时间记录和各天计划中的该任务
+		if err := tx.Table("time_entries").Where("task_id = ? AND user_id = ?", taskID, userID).Delete(nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Table("daily_plan_items").
+			Where("task_id = ? AND plan_id IN (?)", taskID, tx.Table("daily_plans").Select("id").Where("user_id = ?", userID)).
+			Delete(nil).Error; err != nil {
+			return err
+		}
+
+		// 7. 删除任务本身（带 user_id 验证）
 		result := tx.Where("id = ? AND user_id = ?", taskID, userID).Delete(&Task{})
 		if result.Error != nil {
 			return result.Error
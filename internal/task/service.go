@@ -2,12 +2,14 @@ package task
 
 import (
 	"context"
-	"errors"
 	"time"
 
 	"assistant-qisumi/internal/domain"
 	"assistant-qisumi/internal/llm"
 	"assistant-qisumi/internal/prompts"
+	"assistant-qisumi/internal/quickadd"
+
+	"gorm.io/gorm"
 )
 
 type Service struct {
@@ -22,6 +24,28 @@ func NewService(repo *Repository, llmClient llm.Client) *Service {
 // CreateFromText: 调用 LLM 把一段文本变成 Task + Steps
 // 使用 TaskCreationAgent 的 prompt 来生成高质量的任务和步骤
 func (s *Service) CreateFromText(ctx context.Context, userID uint64, rawText string, cfg llm.Config) (*Task, error) {
+	output, err := s.generateTask(ctx, userID, rawText, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// 转换为 Task 对象并入库
+	t := output.ToTask(userID)
+	if err := s.repo.InsertTaskWithSteps(ctx, t); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// generateTask 调用 LLM 把文本解析为任务创建结果，不带时区的截止时间按用户时区理解
+func (s *Service) generateTask(ctx context.Context, userID uint64, rawText string, cfg llm.Config) (*domain.TaskCreationOutput, error) {
+	// 用量统计记在 task_creation 名下
+	ctx = llm.WithCallInfo(ctx, llm.CallInfo{UserID: userID, Agent: "task_creation"})
+
+	// 用户时区：渲染 now 以及理解模型输出的不带时区的时间
+	loc := domain.LocationFromContext(ctx)
+
 	// 1. 构造 messages（使用 TaskCreationSystemPrompt）
 	messages := []llm.Message{
 		{
@@ -30,7 +54,7 @@ func (s *Service) CreateFromText(ctx context.Context, userID uint64, rawText str
 		},
 		{
 			Role:    "system",
-			Content: "当前时间 now: " + time.Now().Format(time.RFC3339),
+			Content: prompts.NowMessage(time.Now().In(loc)),
 		},
 		{
 			Role:    "user",
@@ -38,36 +62,71 @@ func (s *Service) CreateFromText(ctx context.Context, userID uint64, rawText str
 		},
 	}
 
-	req := llm.ChatRequest{
-		Model:    cfg.Model,
-		Messages: messages,
-	}
-
-	// 2. 调用 llmClient.Chat
-	resp, err := s.llmClient.Chat(ctx, cfg, req)
+	// 2. 结构化输出 / 工具调用生成并校验结果
+	output, err := GenerateTaskCreation(ctx, s.llmClient, cfg, messages)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(resp.Choices) == 0 || resp.Choices[0].Message.Content == "" {
-		return nil, errors.New("llm returned empty response")
-	}
+	output.DueAt.AssumeLocation(loc)
+	return output, nil
+}
+
+// QuickAddResult 快速添加的结果
+type QuickAddResult struct {
+	Task     *Task  `json:"task"`
+	Enriched bool   `json:"enriched"`          // 是否成功用 LLM 补全了步骤等信息
+	Warning  string `json:"warning,omitempty"` // 补全失败的原因，任务本身仍会创建
+}
 
-	// 3. 使用 domain 包的共享逻辑解析响应
-	output, err := domain.ParseTaskCreationResponse(resp.Choices[0].Message.Content)
+// QuickAdd 按快速添加语法确定性地解析文本并创建任务，不依赖 LLM
+// cfg 不为空时会先调用 LLM 补全用户没有写出的部分（步骤、描述、优先级、截止时间），
+// 用户显式给出的字段始终优先；补全失败不影响任务创建。
+func (s *Service) QuickAdd(ctx context.Context, userID uint64, text string, cfg *llm.Config) (*QuickAddResult, error) {
+	loc := domain.LocationFromContext(ctx)
+	t, err := quickadd.Parse(text, time.Now().In(loc))
 	if err != nil {
 		return nil, err
 	}
+	t.UserID = userID
+
+	result := &QuickAddResult{Task: t}
+	if cfg != nil {
+		if output, err := s.generateTask(ctx, userID, text, *cfg); err != nil {
+			result.Warning = "enrichment failed: " + err.Error()
+		} else {
+			mergeGenerated(t, output)
+			result.Enriched = true
+		}
+	}
+	if t.Priority == "" {
+		t.Priority = "medium"
+	}
 
-	// 4. 转换为 Task 对象
-	t := output.ToTask(userID)
-
-	// 5. 调用 repo.InsertTaskWithSteps
 	if err := s.repo.InsertTaskWithSteps(ctx, t); err != nil {
 		return nil, err
 	}
+	return result, nil
+}
 
-	return t, nil
+// mergeGenerated 用 LLM 的结果填充快速添加任务中缺失的字段
+func mergeGenerated(t *Task, output *domain.TaskCreationOutput) {
+	generated := output.ToTask(t.UserID)
+	if len(t.Steps) == 0 {
+		t.Steps = generated.Steps
+	}
+	if t.Description == "" {
+		t.Description = generated.Description
+	}
+	if t.Priority == "" {
+		switch generated.Priority {
+		case "low", "medium", "high":
+			t.Priority = generated.Priority
+		}
+	}
+	if t.DueAt == nil {
+		t.DueAt = generated.DueAt
+	}
 }
 
 // ListTasks 获取用户任务列表
@@ -104,6 +163,20 @@ func (s *Service) CreateTask(ctx context.Context, t *Task) error {
 	return s.repo.InsertTaskWithSteps(ctx, t)
 }
 
+// ImportTasks 在同一个事务中批量创建导入的任务，任意一个失败则全部回滚
+func (s *Service) ImportTasks(ctx context.Context, userID uint64, tasks []*Task) error {
+	return s.repo.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		repo := s.repo.WithTx(tx)
+		for _, t := range tasks {
+			t.UserID = userID
+			if err := repo.InsertTaskWithSteps(ctx, t); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 // DeleteTask 删除任务
 func (s *Service) DeleteTask(ctx context.Context, userID, taskID uint64) error {
 	// 验证任务是否存在且属于该用户
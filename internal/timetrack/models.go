@@ -0,0 +1,13 @@
+package timetrack
+
+import "assistant-qisumi/internal/domain"
+
+// 类型别名 - 引用 domain 包中的定义，避免循环依赖
+type TimeEntry = domain.TimeEntry
+
+// 时间记录来源
+const (
+	SourceTimer  = "timer"
+	SourceManual = "manual"
+	SourceAuto   = "auto"
+)
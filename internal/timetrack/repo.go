@@ -0,0 +1,170 @@
+package timetrack
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) WithTx(tx *gorm.DB) *Repository {
+	return &Repository{db: tx}
+}
+
+func (r *Repository) Create(ctx context.Context, e *TimeEntry) error {
+	return r.db.WithContext(ctx).Create(e).Error
+}
+
+// GetEntry 获取用户的一条时间记录
+func (r *Repository) GetEntry(ctx context.Context, userID, entryID uint64) (*TimeEntry, error) {
+	var e TimeEntry
+	err := r.db.WithContext(ctx).
+		Where("id = ? AND user_id = ?", entryID, userID).
+		First(&e).Error
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (r *Repository) DeleteEntry(ctx context.Context, userID, entryID uint64) error {
+	res := r.db.WithContext(ctx).
+		Where("id = ? AND user_id = ?", entryID, userID).
+		Delete(&TimeEntry{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// ListRunningTimers 获取用户正在计时的计时器（不含自动记录）
+func (r *Repository) ListRunningTimers(ctx context.Context, userID uint64) ([]TimeEntry, error) {
+	var entries []TimeEntry
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND ended_at IS NULL AND source <> ?", userID, SourceAuto).
+		Order("started_at ASC").
+		Find(&entries).Error
+	return entries, err
+}
+
+// ListByTask 获取任务的全部时间记录
+func (r *Repository) ListByTask(ctx context.Context, userID, taskID uint64) ([]TimeEntry, error) {
+	var entries []TimeEntry
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND task_id = ?", userID, taskID).
+		Order("started_at ASC, id ASC").
+		Find(&entries).Error
+	return entries, err
+}
+
+// ListOverlapping 获取与 [from, to) 有交集的时间记录（包括仍在计时的记录）
+func (r *Repository) ListOverlapping(ctx context.Context, userID uint64, from, to time.Time) ([]TimeEntry, error) {
+	var entries []TimeEntry
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND started_at < ? AND (ended_at IS NULL OR ended_at > ?)", userID, to, from).
+		Order("started_at ASC, id ASC").
+		Find(&entries).Error
+	return entries, err
+}
+
+// ListByTasks 获取若干任务的全部时间记录
+func (r *Repository) ListByTasks(ctx context.Context, userID uint64, taskIDs []uint64) ([]TimeEntry, error) {
+	var entries []TimeEntry
+	if len(taskIDs) == 0 {
+		return entries, nil
+	}
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND task_id IN ?", userID, taskIDs).
+		Find(&entries).Error
+	return entries, err
+}
+
+// CloseEntries 结束所有符合条件的未结束记录，并写入时长
+func (r *Repository) CloseEntries(ctx context.Context, query *gorm.DB, at time.Time) ([]TimeEntry, error) {
+	var open []TimeEntry
+	if err := query.WithContext(ctx).Where("ended_at IS NULL").Find(&open).Error; err != nil {
+		return nil, err
+	}
+	for i := range open {
+		end := at
+		if end.Before(open[i].StartedAt) {
+			end = open[i].StartedAt
+		}
+		open[i].EndedAt = &end
+		open[i].DurationMin = minutesBetween(open[i].StartedAt, end)
+		err := r.db.WithContext(ctx).
+			Model(&TimeEntry{}).
+			Where("id = ?", open[i].ID).
+			Updates(map[string]any{
+				"ended_at":         end,
+				"duration_minutes": open[i].DurationMin,
+			}).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	return open, nil
+}
+
+// stepEntries 构造某个步骤时间记录的查询
+func (r *Repository) stepEntries(userID, stepID uint64) *gorm.DB {
+	return r.db.Model(&TimeEntry{}).Where("user_id = ? AND step_id = ?", userID, stepID)
+}
+
+// OnStepStatusChange 在步骤状态变化时维护自动时间记录：
+//   - 进入 in_progress：如果没有未结束的自动记录，则开始一条
+//   - 离开 in_progress：结束该步骤的自动记录
+//   - 变为 done：同时结束该步骤上仍在运行的计时器
+func (r *Repository) OnStepStatusChange(ctx context.Context, userID, taskID, stepID uint64, from, to string, at time.Time) error {
+	if from == to {
+		return nil
+	}
+
+	if to == "in_progress" {
+		var count int64
+		err := r.stepEntries(userID, stepID).
+			WithContext(ctx).
+			Where("source = ? AND ended_at IS NULL", SourceAuto).
+			Count(&count).Error
+		if err != nil || count > 0 {
+			return err
+		}
+		return r.Create(ctx, &TimeEntry{
+			UserID:    userID,
+			TaskID:    taskID,
+			StepID:    &stepID,
+			Source:    SourceAuto,
+			StartedAt: at,
+		})
+	}
+
+	if from == "in_progress" {
+		if _, err := r.CloseEntries(ctx, r.stepEntries(userID, stepID).Where("source = ?", SourceAuto), at); err != nil {
+			return err
+		}
+	}
+	if to == "done" {
+		if _, err := r.CloseEntries(ctx, r.stepEntries(userID, stepID).Where("source <> ?", SourceAuto), at); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func minutesBetween(start, end time.Time) int {
+	if !end.After(start) {
+		return 0
+	}
+	return int((end.Sub(start) + 30*time.Second) / time.Minute)
+}
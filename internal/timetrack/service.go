@@ -0,0 +1,476 @@
+// Package timetrack 记录任务/步骤的实际投入时间，并与预估时间做对比
+package timetrack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"assistant-qisumi/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrNoRunningTimer 没有正在运行的计时器
+	ErrNoRunningTimer = errors.New("no running timer")
+	// ErrInvalidEntry 时间记录参数不合法
+	ErrInvalidEntry = errors.New("invalid time entry")
+)
+
+// accuracySampleSize 计算估时准确度时最多取最近完成的步骤数
+const accuracySampleSize = 50
+
+// minAccuracySamples 样本少于该数量时不给出估时准确度
+const minAccuracySamples = 3
+
+type Service struct {
+	repo *Repository
+	db   *gorm.DB
+	now  func() time.Time
+}
+
+func NewService(db *gorm.DB) *Service {
+	return &Service{repo: NewRepository(db), db: db, now: time.Now}
+}
+
+// WithTx 返回绑定到事务的 Service，供 agent 在应用 TaskPatch 时使用
+func (s *Service) WithTx(tx *gorm.DB) *Service {
+	return &Service{repo: s.repo.WithTx(tx), db: tx, now: s.now}
+}
+
+// checkTarget 校验任务（以及步骤）属于该用户
+func (s *Service) checkTarget(ctx context.Context, userID, taskID uint64, stepID *uint64) error {
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&domain.Task{}).
+		Where("id = ? AND user_id = ?", taskID, userID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	if stepID == nil {
+		return nil
+	}
+	if err := s.db.WithContext(ctx).Model(&domain.TaskStep{}).
+		Where("id = ? AND task_id = ?", *stepID, taskID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// StartTimer 开始计时。同一时间只允许一个计时器运行，已有的计时器会先被停止
+func (s *Service) StartTimer(ctx context.Context, userID, taskID uint64, stepID *uint64, note string) (*TimeEntry, error) {
+	if err := s.checkTarget(ctx, userID, taskID, stepID); err != nil {
+		return nil, err
+	}
+	now := s.now()
+	running := s.db.Model(&TimeEntry{}).Where("user_id = ? AND source <> ?", userID, SourceAuto)
+	if _, err := s.repo.CloseEntries(ctx, running, now); err != nil {
+		return nil, err
+	}
+
+	e := &TimeEntry{
+		UserID:    userID,
+		TaskID:    taskID,
+		StepID:    stepID,
+		Source:    SourceTimer,
+		StartedAt: now,
+		Note:      note,
+	}
+	if err := s.repo.Create(ctx, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// StopTimer 停止正在运行的计时器；taskID / stepID 不为空时只停止匹配的计时器
+func (s *Service) StopTimer(ctx context.Context, userID uint64, taskID, stepID *uint64) ([]TimeEntry, error) {
+	query := s.db.Model(&TimeEntry{}).Where("user_id = ? AND source <> ?", userID, SourceAuto)
+	if taskID != nil {
+		query = query.Where("task_id = ?", *taskID)
+	}
+	if stepID != nil {
+		query = query.Where("step_id = ?", *stepID)
+	}
+	stopped, err := s.repo.CloseEntries(ctx, query, s.now())
+	if err != nil {
+		return nil, err
+	}
+	if len(stopped) == 0 {
+		return nil, ErrNoRunningTimer
+	}
+	return stopped, nil
+}
+
+// RunningTimers 获取正在运行的计时器
+func (s *Service) RunningTimers(ctx context.Context, userID uint64) ([]TimeEntry, error) {
+	return s.repo.ListRunningTimers(ctx, userID)
+}
+
+// AddManualEntry 手动补录一段时间
+func (s *Service) AddManualEntry(ctx context.Context, userID, taskID uint64, stepID *uint64, startedAt time.Time, minutes int, note string) (*TimeEntry, error) {
+	if minutes <= 0 {
+		return nil, fmt.Errorf("%w: duration must be positive", ErrInvalidEntry)
+	}
+	if startedAt.IsZero() {
+		startedAt = s.now().Add(-time.Duration(minutes) * time.Minute)
+	}
+	if err := s.checkTarget(ctx, userID, taskID, stepID); err != nil {
+		return nil, err
+	}
+	end := startedAt.Add(time.Duration(minutes) * time.Minute)
+	e := &TimeEntry{
+		UserID:      userID,
+		TaskID:      taskID,
+		StepID:      stepID,
+		Source:      SourceManual,
+		StartedAt:   startedAt,
+		EndedAt:     &end,
+		DurationMin: minutes,
+		Note:        note,
+	}
+	if err := s.repo.Create(ctx, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// DeleteEntry 删除一条时间记录
+func (s *Service) DeleteEntry(ctx context.Context, userID, entryID uint64) error {
+	return s.repo.DeleteEntry(ctx, userID, entryID)
+}
+
+// ==================== 统计 ====================
+
+// StepTime 单个步骤的预估与实际耗时
+type StepTime struct {
+	StepID      uint64 `json:"stepId"`
+	Title       string `json:"title"`
+	Status      string `json:"status"`
+	EstimateMin *int   `json:"estimateMinutes,omitempty"`
+	ActualMin   int    `json:"actualMinutes"`
+}
+
+// TaskTime 任务的时间统计
+type TaskTime struct {
+	TaskID      uint64      `json:"taskId"`
+	Title       string      `json:"title"`
+	EstimateMin int         `json:"estimateMinutes"`
+	ActualMin   int         `json:"actualMinutes"`
+	Steps       []StepTime  `json:"steps"`
+	Entries     []TimeEntry `json:"entries"`
+}
+
+// effectiveMinutes 计算一组记录的有效时长（分钟）
+// 规则：某个步骤只要有计时器或手动记录，就忽略它的自动记录；自动记录只作为兜底
+// 仍在计时的记录按截至 now 的时长计算；clipFrom/clipTo 非零时只统计落在窗口内的部分
+func effectiveMinutes(entries []TimeEntry, now, clipFrom, clipTo time.Time) (byStep map[uint64]int, taskLevel int) {
+	explicit := make(map[uint64]bool)
+	for _, e := range entries {
+		if e.StepID != nil && e.Source != SourceAuto {
+			explicit[*e.StepID] = true
+		}
+	}
+
+	byStep = make(map[uint64]int)
+	for _, e := range entries {
+		if e.Source == SourceAuto && e.StepID != nil && explicit[*e.StepID] {
+			continue
+		}
+		m := entryMinutes(e, now, clipFrom, clipTo)
+		if e.StepID == nil {
+			taskLevel += m
+		} else {
+			byStep[*e.StepID] += m
+		}
+	}
+	return byStep, taskLevel
+}
+
+func entryMinutes(e TimeEntry, now, clipFrom, clipTo time.Time) int {
+	start := e.StartedAt
+	end := now
+	if e.EndedAt != nil {
+		end = *e.EndedAt
+	}
+	if clipFrom.IsZero() && clipTo.IsZero() {
+		if e.EndedAt != nil {
+			return e.DurationMin
+		}
+		return minutesBetween(start, end)
+	}
+	if start.Before(clipFrom) {
+		start = clipFrom
+	}
+	if end.After(clipTo) {
+		end = clipTo
+	}
+	return minutesBetween(start, end)
+}
+
+// TaskSummary 获取任务各步骤的预估与实际耗时
+func (s *Service) TaskSummary(ctx context.Context, userID, taskID uint64) (*TaskTime, error) {
+	var t domain.Task
+	err := s.db.WithContext(ctx).
+		Preload("Steps", func(db *gorm.DB) *gorm.DB {
+			return db.Order("order_index ASC")
+		}).
+		Where("id = ? AND user_id = ?", taskID, userID).
+		First(&t).Error
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := s.repo.ListByTask(ctx, userID, taskID)
+	if err != nil {
+		return nil, err
+	}
+	byStep, taskLevel := effectiveMinutes(entries, s.now(), time.Time{}, time.Time{})
+
+	summary := &TaskTime{
+		TaskID:    t.ID,
+		Title:     t.Title,
+		ActualMin: taskLevel,
+		Steps:     make([]StepTime, 0, len(t.Steps)),
+		Entries:   entries,
+	}
+	for _, st := range t.Steps {
+		summary.Steps = append(summary.Steps, StepTime{
+			StepID:      st.ID,
+			Title:       st.Title,
+			Status:      st.Status,
+			EstimateMin: st.EstimateMin,
+			ActualMin:   byStep[st.ID],
+		})
+		if st.EstimateMin != nil {
+			summary.EstimateMin += *st.EstimateMin
+		}
+		summary.ActualMin += byStep[st.ID]
+	}
+	return summary, nil
+}
+
+// ActualMinutes 返回任务中有时间记录的步骤的实际耗时，供报告导出使用
+func (s *Service) ActualMinutes(ctx context.Context, userID, taskID uint64) (map[uint64]int, error) {
+	entries, err := s.repo.ListByTask(ctx, userID, taskID)
+	if err != nil {
+		return nil, err
+	}
+	byStep, _ := effectiveMinutes(entries, s.now(), time.Time{}, time.Time{})
+	return byStep, nil
+}
+
+// WeekTask 一周内某个任务的时间统计
+type WeekTask struct {
+	TaskID     uint64 `json:"taskId"`
+	Title      string `json:"title"`
+	TrackedMin int    `json:"trackedMinutes"` // 本周投入的时间
+	// 本周完成的步骤：预估合计与实际合计（实际耗时包含本周之前投入的时间）
+	CompletedSteps int `json:"completedSteps"`
+	EstimateMin    int `json:"estimateMinutes"`
+	ActualMin      int `json:"actualMinutes"`
+}
+
+// WeekSummary 一周（周一至周日）的时间统计
+type WeekSummary struct {
+	Start          time.Time  `json:"start"`
+	End            time.Time  `json:"end"`
+	TrackedMin     int        `json:"trackedMinutes"`
+	DailyMin       [7]int     `json:"dailyMinutes"` // 周一到周日
+	CompletedSteps int        `json:"completedSteps"`
+	EstimateMin    int        `json:"estimateMinutes"`
+	ActualMin      int        `json:"actualMinutes"`
+	Tasks          []WeekTask `json:"tasks"`
+}
+
+// WeekStart 返回 t 所在周的周一 00:00（t 所在时区）
+func WeekStart(t time.Time) time.Time {
+	offset := (int(t.Weekday()) + 6) % 7
+	y, m, d := t.Date()
+	return time.Date(y, m, d-offset, 0, 0, 0, 0, t.Location())
+}
+
+// WeeklySummary 统计 ref 所在周的投入时间，以及本周完成步骤的预估与实际对比
+func (s *Service) WeeklySummary(ctx context.Context, userID uint64, ref time.Time) (*WeekSummary, error) {
+	start := WeekStart(ref)
+	end := start.AddDate(0, 0, 7)
+	now := s.now()
+
+	windowEntries, err := s.repo.ListOverlapping(ctx, userID, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	var doneSteps []domain.TaskStep
+	err = s.db.WithContext(ctx).
+		Where("task_id IN (SELECT id FROM tasks WHERE user_id = ?)", userID).
+		Where("status = ? AND completed_at >= ? AND completed_at < ?", "done", start, end).
+		Find(&doneSteps).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// 收集涉及的任务，取其全部记录用于判断兜底规则和计算已完成步骤的实际耗时
+	taskSet := make(map[uint64]bool)
+	for _, e := range windowEntries {
+		taskSet[e.TaskID] = true
+	}
+	for _, st := range doneSteps {
+		taskSet[st.TaskID] = true
+	}
+	taskIDs := make([]uint64, 0, len(taskSet))
+	for id := range taskSet {
+		taskIDs = append(taskIDs, id)
+	}
+	allEntries, err := s.repo.ListByTasks(ctx, userID, taskIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []domain.Task
+	if len(taskIDs) > 0 {
+		if err := s.db.WithContext(ctx).Where("user_id = ? AND id IN ?", userID, taskIDs).Find(&tasks).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	summary := &WeekSummary{Start: start, End: end, Tasks: []WeekTask{}}
+	rows := make(map[uint64]*WeekTask, len(tasks))
+	for _, t := range tasks {
+		rows[t.ID] = &WeekTask{TaskID: t.ID, Title: t.Title}
+	}
+
+	entriesByTask := make(map[uint64][]TimeEntry)
+	for _, e := range allEntries {
+		entriesByTask[e.TaskID] = append(entriesByTask[e.TaskID], e)
+	}
+
+	for taskID, entries := range entriesByTask {
+		row := rows[taskID]
+		if row == nil {
+			continue
+		}
+		// 本周投入：逐天裁剪
+		for day := 0; day < 7; day++ {
+			dayStart := start.AddDate(0, 0, day)
+			byStep, taskLevel := effectiveMinutes(entries, now, dayStart, dayStart.AddDate(0, 0, 1))
+			m := taskLevel
+			for _, v := range byStep {
+				m += v
+			}
+			summary.DailyMin[day] += m
+			row.TrackedMin += m
+		}
+	}
+
+	for _, st := range doneSteps {
+		row := rows[st.TaskID]
+		if row == nil {
+			continue
+		}
+		byStep, _ := effectiveMinutes(entriesByTask[st.TaskID], now, time.Time{}, time.Time{})
+		row.CompletedSteps++
+		if st.EstimateMin != nil {
+			row.EstimateMin += *st.EstimateMin
+		}
+		row.ActualMin += byStep[st.ID]
+	}
+
+	for _, row := range rows {
+		if row.TrackedMin == 0 && row.CompletedSteps == 0 {
+			continue
+		}
+		summary.TrackedMin += row.TrackedMin
+		summary.CompletedSteps += row.CompletedSteps
+		summary.EstimateMin += row.EstimateMin
+		summary.ActualMin += row.ActualMin
+		summary.Tasks = append(summary.Tasks, *row)
+	}
+	sort.Slice(summary.Tasks, func(i, j int) bool {
+		if summary.Tasks[i].TrackedMin != summary.Tasks[j].TrackedMin {
+			return summary.Tasks[i].TrackedMin > summary.Tasks[j].TrackedMin
+		}
+		return summary.Tasks[i].TaskID < summary.Tasks[j].TaskID
+	})
+	return summary, nil
+}
+
+// Accuracy 用户历史估时准确度
+type Accuracy struct {
+	Samples     int     `json:"samples"`
+	EstimateMin int     `json:"estimateMinutes"`
+	ActualMin   int     `json:"actualMinutes"`
+	Ratio       float64 `json:"ratio"`       // 实际合计 / 预估合计
+	MedianRatio float64 `json:"medianRatio"` // 单步骤 实际/预估 的中位数
+}
+
+// EstimateAccuracy 基于最近完成且同时有预估和实际耗时的步骤计算估时准确度
+// 样本不足时返回 nil
+func (s *Service) EstimateAccuracy(ctx context.Context, userID uint64) (*Accuracy, error) {
+	var steps []domain.TaskStep
+	err := s.db.WithContext(ctx).
+		Where("task_id IN (SELECT id FROM tasks WHERE user_id = ?)", userID).
+		Where("status = ? AND estimate_minutes > 0", "done").
+		Where("id IN (SELECT step_id FROM time_entries WHERE user_id = ? AND step_id IS NOT NULL)", userID).
+		Order("completed_at DESC, id DESC").
+		Limit(accuracySampleSize).
+		Find(&steps).Error
+	if err != nil {
+		return nil, err
+	}
+
+	taskSet := make(map[uint64]bool)
+	for _, st := range steps {
+		taskSet[st.TaskID] = true
+	}
+	taskIDs := make([]uint64, 0, len(taskSet))
+	for id := range taskSet {
+		taskIDs = append(taskIDs, id)
+	}
+	entries, err := s.repo.ListByTasks(ctx, userID, taskIDs)
+	if err != nil {
+		return nil, err
+	}
+	byStep, _ := effectiveMinutes(entries, s.now(), time.Time{}, time.Time{})
+
+	acc := &Accuracy{}
+	var ratios []float64
+	for _, st := range steps {
+		actual := byStep[st.ID]
+		if actual <= 0 {
+			continue
+		}
+		acc.Samples++
+		acc.EstimateMin += *st.EstimateMin
+		acc.ActualMin += actual
+		ratios = append(ratios, float64(actual)/float64(*st.EstimateMin))
+	}
+	if acc.Samples < minAccuracySamples {
+		return nil, nil
+	}
+	acc.Ratio = float64(acc.ActualMin) / float64(acc.EstimateMin)
+	sort.Float64s(ratios)
+	if n := len(ratios); n%2 == 1 {
+		acc.MedianRatio = ratios[n/2]
+	} else {
+		acc.MedianRatio = (ratios[n/2-1] + ratios[n/2]) / 2
+	}
+	return acc, nil
+}
+
+// Describe 生成给 LLM 的估时准确度说明
+func (a *Accuracy) Describe() string {
+	return fmt.Sprintf(
+		"用户历史估时准确度：最近 %d 个已完成步骤预估合计 %d 分钟，实际合计 %d 分钟，实际/预估 = %.2f（单步骤中位数 %.2f）。"+
+			"给新步骤填写 estimate_minutes 时请按这个比例校准，例如比例明显大于 1 说明用户经常低估耗时。",
+		a.Samples, a.EstimateMin, a.ActualMin, a.Ratio, a.MedianRatio,
+	)
+}
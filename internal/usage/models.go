@@ -0,0 +1,59 @@
+package usage
+
+import (
+	"time"
+
+	"assistant-qisumi/internal/domain"
+)
+
+// 类型别名 - 引用 domain 包中的定义，避免循环依赖
+type LLMUsage = domain.LLMUsage
+
+// GroupBy 用量统计的分组方式
+type GroupBy string
+
+const (
+	GroupByAgent GroupBy = "agent"
+	GroupByModel GroupBy = "model"
+	GroupByDay   GroupBy = "day"
+	GroupByUser  GroupBy = "user" // 仅管理员视图可用
+)
+
+// Query 用量查询条件
+type Query struct {
+	UserID   *uint64 // 为空表示全部用户（管理员视图）
+	From     time.Time
+	To       time.Time // 不含
+	GroupBy  GroupBy
+	Location *time.Location // 按天分组使用的时区
+}
+
+// Bucket 一个分组内的用量合计
+type Bucket struct {
+	Key              string  `json:"key"`
+	Calls            int64   `json:"calls"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	ReasoningTokens  int64   `json:"reasoning_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+func (b *Bucket) add(o Bucket) {
+	b.Calls += o.Calls
+	b.PromptTokens += o.PromptTokens
+	b.CompletionTokens += o.CompletionTokens
+	b.ReasoningTokens += o.ReasoningTokens
+	b.TotalTokens += o.TotalTokens
+	b.Cost += o.Cost
+}
+
+// Report 用量报表
+type Report struct {
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	GroupBy  GroupBy   `json:"group_by"`
+	Currency string    `json:"currency"`
+	Items    []Bucket  `json:"items"`
+	Total    Bucket    `json:"total"`
+}
@@ -0,0 +1,83 @@
+package usage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"assistant-qisumi/internal/llm"
+)
+
+// Price 每百万 token 的价格
+// 深度思考的 token 已包含在 completion_tokens 中，按 Output 计价
+type Price struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// PriceTable 模型名 -> 价格。
+// 键可以是完整模型名、以 * 结尾的前缀（例如 "qwen-*"），或者 "*" 作为兜底价格。
+// 匹配时忽略大小写：完整模型名 > 最长前缀 > "*"。
+type PriceTable map[string]Price
+
+// ParsePriceTable 解析 JSON 格式的价格表，例如
+// {"qwen-plus": {"input": 0.8, "output": 2}, "gpt-4o*": {"input": 2.5, "output": 10}}
+func ParsePriceTable(data []byte) (PriceTable, error) {
+	var raw map[string]Price
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid price table: %w", err)
+	}
+	table := make(PriceTable, len(raw))
+	for model, price := range raw {
+		if price.Input < 0 || price.Output < 0 {
+			return nil, fmt.Errorf("invalid price table: negative price for %q", model)
+		}
+		table[strings.ToLower(strings.TrimSpace(model))] = price
+	}
+	return table, nil
+}
+
+// LoadPriceTable 从 JSON 文件加载价格表，path 为空时返回空表（所有调用费用记为 0）
+func LoadPriceTable(path string) (PriceTable, error) {
+	if path == "" {
+		return PriceTable{}, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read price table: %w", err)
+	}
+	return ParsePriceTable(data)
+}
+
+// Lookup 查找模型的价格
+func (t PriceTable) Lookup(model string) (Price, bool) {
+	model = strings.ToLower(model)
+	if p, ok := t[model]; ok {
+		return p, true
+	}
+	best, found := "", false
+	for key := range t {
+		prefix, ok := strings.CutSuffix(key, "*")
+		if !ok || prefix == "" || !strings.HasPrefix(model, prefix) {
+			continue
+		}
+		if !found || len(prefix) > len(best) {
+			best, found = prefix, true
+		}
+	}
+	if found {
+		return t[best+"*"], true
+	}
+	p, ok := t["*"]
+	return p, ok
+}
+
+// Cost 计算一次调用的费用，未配置价格的模型费用为 0
+func (t PriceTable) Cost(model string, u llm.Usage) float64 {
+	p, ok := t.Lookup(model)
+	if !ok {
+		return 0
+	}
+	return (float64(u.PromptTokens)*p.Input + float64(u.CompletionTokens)*p.Output) / 1e6
+}
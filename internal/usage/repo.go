@@ -0,0 +1,75 @@
+package usage
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Create 保存一条用量记录
+func (r *Repository) Create(ctx context.Context, u *LLMUsage) error {
+	return r.db.WithContext(ctx).Create(u).Error
+}
+
+// bucketRow 分组汇总查询的一行结果
+type bucketRow struct {
+	BucketKey        string
+	Calls            int64
+	PromptTokens     int64
+	CompletionTokens int64
+	ReasoningTokens  int64
+	TotalTokens      int64
+	Cost             float64
+}
+
+// Aggregate 在数据库中按 keyExpr（可以带 ? 参数）分组汇总时间范围内的用量
+func (r *Repository) Aggregate(ctx context.Context, q Query, keyExpr string, args ...interface{}) ([]Bucket, error) {
+	var rows []bucketRow
+	// created_at 由 GORM 按服务器时区写入，SQLite 以字符串比较时间，查询条件需使用同一时区
+	db := r.db.WithContext(ctx).Model(&LLMUsage{}).
+		Select(keyExpr+` AS bucket_key, COUNT(*) AS calls,
+			COALESCE(SUM(prompt_tokens), 0) AS prompt_tokens,
+			COALESCE(SUM(completion_tokens), 0) AS completion_tokens,
+			COALESCE(SUM(reasoning_tokens), 0) AS reasoning_tokens,
+			COALESCE(SUM(total_tokens), 0) AS total_tokens,
+			COALESCE(SUM(cost), 0) AS cost`, args...).
+		Where("created_at >= ? AND created_at < ?", q.From.Local(), q.To.Local())
+	if q.UserID != nil {
+		db = db.Where("user_id = ?", *q.UserID)
+	}
+	if err := db.Group("bucket_key").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	buckets := make([]Bucket, 0, len(rows))
+	for _, row := range rows {
+		buckets = append(buckets, Bucket{
+			Key:              row.BucketKey,
+			Calls:            row.Calls,
+			PromptTokens:     row.PromptTokens,
+			CompletionTokens: row.CompletionTokens,
+			ReasoningTokens:  row.ReasoningTokens,
+			TotalTokens:      row.TotalTokens,
+			Cost:             row.Cost,
+		})
+	}
+	return buckets, nil
+}
+
+// SumTokens 统计用户自 since 以来使用（或未使用）服务器默认 API Key 的调用消耗的 token 数
+func (r *Repository) SumTokens(ctx context.Context, userID uint64, defaultKey bool, since time.Time) (int64, error) {
+	var total int64
+	err := r.db.WithContext(ctx).Model(&LLMUsage{}).
+		Where("user_id = ? AND default_key = ? AND created_at >= ?", userID, defaultKey, since.Local()).
+		Select("COALESCE(SUM(total_tokens), 0)").
+		Scan(&total).Error
+	return total, err
+}
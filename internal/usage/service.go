@@ -0,0 +1,149 @@
+// Package usage 记录每次 LLM 调用的 token 用量，按价格表折算费用，并按 agent / 模型 / 天汇总
+package usage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"assistant-qisumi/internal/llm"
+)
+
+// ErrInvalidQuery 查询条件不合法
+var ErrInvalidQuery = errors.New("invalid usage query")
+
+// DefaultCurrency 价格表未指定币种时使用
+const DefaultCurrency = "CNY"
+
+// MaxReportDays 一次报表最多统计的天数
+const MaxReportDays = 366
+
+type Service struct {
+	repo     *Repository
+	prices   PriceTable
+	currency string
+}
+
+func NewService(repo *Repository, prices PriceTable, currency string) *Service {
+	if prices == nil {
+		prices = PriceTable{}
+	}
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	return &Service{repo: repo, prices: prices, currency: currency}
+}
+
+// RecordUsage 实现 llm.UsageRecorder，保存一次成功调用的用量。没有用户归属的调用不记录
+func (s *Service) RecordUsage(ctx context.Context, info llm.CallInfo, cfg llm.Config, resp *llm.ChatResponse) error {
+	if info.UserID == 0 || resp == nil {
+		return nil
+	}
+	u := &LLMUsage{
+		UserID:           info.UserID,
+		SessionID:        info.SessionID,
+		Agent:            info.Agent,
+		Provider:         resp.Provider,
+		Model:            resp.Model,
+		DefaultKey:       cfg.IsDefault,
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+		ReasoningTokens:  resp.Usage.ReasoningTokens,
+		TotalTokens:      resp.Usage.TotalTokens,
+		Cost:             s.prices.Cost(resp.Model, resp.Usage),
+	}
+	return s.repo.Create(ctx, u)
+}
+
+// TokensUsed 实现 quota.TokenCounter，统计用户自 since 以来消耗的 token 数
+func (s *Service) TokensUsed(ctx context.Context, userID uint64, defaultKey bool, since time.Time) (int64, error) {
+	return s.repo.SumTokens(ctx, userID, defaultKey, since)
+}
+
+// Report 按分组汇总时间范围内的用量
+func (s *Service) Report(ctx context.Context, q Query) (*Report, error) {
+	if !q.To.After(q.From) {
+		return nil, fmt.Errorf("%w: from must be before to", ErrInvalidQuery)
+	}
+	if q.To.Sub(q.From) > MaxReportDays*24*time.Hour {
+		return nil, fmt.Errorf("%w: range must not exceed %d days", ErrInvalidQuery, MaxReportDays)
+	}
+	switch q.GroupBy {
+	case GroupByAgent, GroupByModel, GroupByDay:
+	case GroupByUser:
+		if q.UserID != nil {
+			return nil, fmt.Errorf("%w: group_by=user is only available in the admin view", ErrInvalidQuery)
+		}
+	default:
+		return nil, fmt.Errorf("%w: unsupported group_by %q", ErrInvalidQuery, q.GroupBy)
+	}
+	loc := q.Location
+	if loc == nil {
+		loc = time.Local
+	}
+
+	keyExpr, args := bucketKeyExpr(q, loc)
+	rows, err := s.repo.Aggregate(ctx, q, keyExpr, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{From: q.From, To: q.To, GroupBy: q.GroupBy, Currency: s.currency, Items: []Bucket{}}
+	buckets := map[string]*Bucket{}
+	for _, row := range rows {
+		if row.Key == "" && q.GroupBy == GroupByAgent {
+			row.Key = "unknown"
+		}
+		b, ok := buckets[row.Key]
+		if !ok {
+			b = &Bucket{Key: row.Key}
+			buckets[row.Key] = b
+		}
+		b.add(row)
+		report.Total.add(row)
+	}
+	for _, b := range buckets {
+		report.Items = append(report.Items, *b)
+	}
+	sort.Slice(report.Items, func(i, j int) bool {
+		a, b := report.Items[i], report.Items[j]
+		if q.GroupBy == GroupByDay || a.Cost == b.Cost {
+			return a.Key < b.Key
+		}
+		// 其他分组按费用从高到低
+		return a.Cost > b.Cost
+	})
+	return report, nil
+}
+
+// bucketKeyExpr 返回分组键的 SQL 表达式及其参数。
+// 按天分组时各数据库的日期函数和时区处理不同，改用按用户时区计算好的每天的分界时间拼成 CASE 表达式
+func bucketKeyExpr(q Query, loc *time.Location) (string, []interface{}) {
+	switch q.GroupBy {
+	case GroupByModel:
+		return "model", nil
+	case GroupByUser:
+		return "CAST(user_id AS CHAR)", nil
+	case GroupByDay:
+		from := q.From.In(loc)
+		day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc)
+		var b strings.Builder
+		var args []interface{}
+		b.WriteString("CASE")
+		for next := day.AddDate(0, 0, 1); next.Before(q.To); day, next = next, next.AddDate(0, 0, 1) {
+			b.WriteString(" WHEN created_at < ? THEN ?")
+			args = append(args, next.Local(), day.Format("2006-01-02"))
+		}
+		if len(args) == 0 {
+			// 范围不超过一天
+			return "?", []interface{}{day.Format("2006-01-02")}
+		}
+		b.WriteString(" ELSE ? END")
+		return b.String(), append(args, day.Format("2006-01-02"))
+	default:
+		return "agent", nil
+	}
+}
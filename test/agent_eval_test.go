@@ -0,0 +1,98 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"assistant-qisumi/internal/eval"
+	"assistant-qisumi/internal/llm"
+)
+
+// toolCallLLMClient 第一次调用返回指定的工具调用，收到工具结果后返回文本回复
+type toolCallLLMClient struct {
+	name  string
+	args  string
+	reply string
+}
+
+func (m *toolCallLLMClient) Chat(ctx context.Context, cfg llm.Config, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	msg := llm.ChatMessage{Role: "assistant", Content: m.reply}
+	finish := "stop"
+	if last := req.Messages[len(req.Messages)-1]; last.Role != "tool" {
+		msg = llm.ChatMessage{Role: "assistant", ToolCalls: []llm.ToolCall{{
+			ID: "call_1", Type: "function", Function: llm.ToolCallFunc{Name: m.name, Arguments: m.args},
+		}}}
+		finish = "tool_calls"
+	}
+	resp := &llm.ChatResponse{}
+	resp.Choices = append(resp.Choices, struct {
+		Message      llm.ChatMessage `json:"message"`
+		FinishReason string          `json:"finish_reason"`
+	}{Message: msg, FinishReason: finish})
+	return resp, nil
+}
+
+func TestAgentEval_RunScenario(t *testing.T) {
+	sc, err := eval.LoadScenario(filepath.Join("..", "eval", "scenarios", "complete_step.yaml"))
+	if err != nil {
+		t.Fatalf("LoadScenario failed: %v", err)
+	}
+	cfg := llm.Config{Model: "test-model"}
+
+	good := &toolCallLLMClient{name: "update_steps", args: `{"task_id":1,"updates":[{"step_id":2,"fields":{"status":"done"}}]}`, reply: "好的，初稿已完成"}
+	result := eval.NewRunner(good, cfg).Run(context.Background(), sc)
+	if !result.Passed() {
+		t.Fatalf("scenario should pass, got err=%v failures=%v", result.Err, result.Failures)
+	}
+	if result.Agent != "executor" || len(result.Patches) != 1 {
+		t.Errorf("unexpected result: agent=%s patches=%d", result.Agent, len(result.Patches))
+	}
+
+	// 模型错误地取消了任务：期望的 patch 缺失，断言失败，报告中带 diff
+	bad := &toolCallLLMClient{name: "update_task", args: `{"task_id":1,"fields":{"status":"cancelled"}}`, reply: "已取消"}
+	result = eval.NewRunner(bad, cfg).Run(context.Background(), sc)
+	if result.Passed() || result.Err != nil {
+		t.Fatalf("scenario should fail without error, got err=%v", result.Err)
+	}
+	var out bytes.Buffer
+	if failed := eval.WriteReport(&out, []*eval.Result{result}, false); failed != 1 {
+		t.Errorf("expected 1 failed scenario, got %d", failed)
+	}
+	report := out.String()
+	for _, want := range []string{"--- FAIL", "step 2 done: step 2 is in_progress", "no task cancelled: task 1 is cancelled", `- {"kind":"update_step"`, `+ {"kind":"update_task"`, "0 passed, 1 failed"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report should contain %q:\n%s", want, report)
+		}
+	}
+}
+
+func TestAgentEval_LoadScenarios(t *testing.T) {
+	scenarios, err := eval.LoadScenarios(filepath.Join("..", "eval", "scenarios"))
+	if err != nil {
+		t.Fatalf("bundled scenarios should load: %v", err)
+	}
+	if len(scenarios) == 0 {
+		t.Fatal("expected bundled scenarios")
+	}
+
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(content), 0o644)
+		return path
+	}
+	if _, err := eval.LoadScenario(write("typo.yaml", "input: hi\ntasks: [{title: t}]\nexpcet: {}\n")); err == nil {
+		t.Errorf("unknown fields should be rejected")
+	}
+	if _, err := eval.LoadScenario(write("assert.yaml", "input: hi\ntasks: [{title: t}]\nexpect:\n  assertions: [\"everything fine\"]\n")); err == nil || !strings.Contains(err.Error(), "unknown assertion") {
+		t.Errorf("unknown assertions should be rejected, got %v", err)
+	}
+	sc, err := eval.LoadScenario(write("ok.yaml", "session: global\ninput: hi\nexpect:\n  assertions: [\"No Patches\", \"reply contains Hello World\"]\n"))
+	if err != nil || sc.Name != "ok" {
+		t.Errorf("scenario name should default to the file name, got %+v (%v)", sc, err)
+	}
+}
@@ -86,11 +86,11 @@ func TestAgentServiceCreation(t *testing.T) {
 	// 创建LLM客户端
 	llmClient := &MockAgentLLMClient{}
 
-	// 初始化工具执行器映射
-	toolMap := make(map[string]agent.ToolExecutor)
+	// 初始化工具注册表
+	tools := agent.NewToolRegistry(nil, nil)
 
 	// 初始化Chat Completions处理器
-	chatCompletionsHandler := agent.NewChatCompletionsHandler(llmClient, toolMap)
+	chatCompletionsHandler := agent.NewChatCompletionsHandler(llmClient, tools)
 
 	// 创建Agent
 	executorAgent := agent.NewExecutorAgent(llmClient, chatCompletionsHandler)
@@ -0,0 +1,264 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"assistant-qisumi/internal/archive"
+	appdb "assistant-qisumi/internal/db"
+	"assistant-qisumi/internal/domain"
+	"assistant-qisumi/internal/task"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupArchiveTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to connect database: %v", err)
+	}
+	if err := appdb.AutoMigrate(db); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	return db
+}
+
+// seedArchiveData 为用户 1 准备两个任务、一条步骤级依赖、一个任务会话和一个全局会话
+func seedArchiveData(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	tasks := []domain.Task{
+		{UserID: 1, Title: "写论文", Status: "in_progress", Priority: "high", Steps: []domain.TaskStep{
+			{Title: "查资料", Status: "done", OrderIndex: 0},
+			{Title: "写初稿", Status: "todo", OrderIndex: 1},
+		}},
+		{UserID: 1, Title: "投稿", Status: "todo", Priority: "medium", Steps: []domain.TaskStep{
+			{Title: "选期刊", Status: "locked", OrderIndex: 0},
+		}},
+	}
+	for i := range tasks {
+		if err := db.Create(&tasks[i]).Error; err != nil {
+			t.Fatalf("failed to create task: %v", err)
+		}
+	}
+	predStep := tasks[0].Steps[1].ID
+	succStep := tasks[1].Steps[0].ID
+	dep := domain.TaskDependency{
+		PredecessorTaskID: tasks[0].ID, PredecessorStepID: &predStep,
+		SuccessorTaskID: tasks[1].ID, SuccessorStepID: &succStep,
+		Condition: "step_done", Action: "unlock_step",
+	}
+	if err := db.Create(&dep).Error; err != nil {
+		t.Fatalf("failed to create dependency: %v", err)
+	}
+
+	taskSess := domain.Session{UserID: 1, TaskID: &tasks[0].ID, Type: "task"}
+	globalSess := domain.Session{UserID: 1, Type: "global"}
+	db.Create(&taskSess)
+	db.Create(&globalSess)
+	db.Create(&domain.Message{SessionID: taskSess.ID, Role: "user", Content: "拆一下步骤"})
+	db.Create(&domain.Message{SessionID: taskSess.ID, Role: "assistant", Content: "好的"})
+	db.Create(&domain.Message{SessionID: globalSess.ID, Role: "user", Content: "今天做什么"})
+
+	db.Create(&domain.UserLLMSetting{UserID: 1, BaseURL: "https://api.example.com/v1", APIKeyEnc: "secret", Model: "gpt-x", AssistantName: "小奇"})
+	db.Create(&domain.UserProfile{UserID: 1, Timezone: "Asia/Shanghai", WorkStart: "10:00", WorkEnd: "19:00", WorkDays: []int{1, 2, 3, 4, 5}})
+}
+
+func TestArchive_ExportImportRoundTrip(t *testing.T) {
+	db := setupArchiveTestDB(t)
+	seedArchiveData(t, db)
+	svc := archive.NewService(db, task.NewRepository(db))
+	ctx := context.Background()
+
+	a, err := svc.Export(ctx, 1)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if len(a.Tasks) != 2 || len(a.Dependencies) != 1 || len(a.Sessions) != 2 {
+		t.Fatalf("unexpected export: %d tasks, %d deps, %d sessions", len(a.Tasks), len(a.Dependencies), len(a.Sessions))
+	}
+	if a.Settings == nil || a.Settings.Model != "gpt-x" {
+		t.Fatalf("expected settings to be exported, got %+v", a.Settings)
+	}
+
+	// 导出内容不应包含 API Key
+	raw, _ := json.Marshal(a)
+	if bytes.Contains(raw, []byte("secret")) {
+		t.Fatal("export must not contain the api key")
+	}
+
+	// 经过 zip 打包再读回
+	var buf bytes.Buffer
+	if err := archive.WriteZip(&buf, a); err != nil {
+		t.Fatalf("WriteZip failed: %v", err)
+	}
+	restored, err := archive.Read(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+
+	result, err := svc.Import(ctx, 2, restored, false)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if result.Tasks != 2 || result.Steps != 3 || result.Dependencies != 1 || result.Sessions != 2 || result.Messages != 3 {
+		t.Fatalf("unexpected import result: %+v", result)
+	}
+	if result.SettingsRestored {
+		t.Error("settings should not be restored for a user without an existing config")
+	}
+	var prof domain.UserProfile
+	if err := db.Where("user_id = ?", 2).First(&prof).Error; err != nil || prof.Timezone != "Asia/Shanghai" || prof.WorkStart != "10:00" || len(prof.WorkDays) != 5 {
+		t.Errorf("expected profile to be restored, got %+v, %v", prof, err)
+	}
+
+	var tasks []domain.Task
+	db.Preload("Steps").Where("user_id = ?", 2).Order("id").Find(&tasks)
+	if len(tasks) != 2 || tasks[0].Title != "写论文" || len(tasks[0].Steps) != 2 {
+		t.Fatalf("unexpected imported tasks: %+v", tasks)
+	}
+
+	// 依赖应指向新分配的任务和步骤 ID
+	var deps []domain.TaskDependency
+	db.Where("predecessor_task_id = ?", tasks[0].ID).Find(&deps)
+	if len(deps) != 1 {
+		t.Fatalf("expected 1 remapped dependency, got %d", len(deps))
+	}
+	if deps[0].SuccessorTaskID != tasks[1].ID || *deps[0].PredecessorStepID != tasks[0].Steps[1].ID || *deps[0].SuccessorStepID != tasks[1].Steps[0].ID {
+		t.Errorf("dependency was not remapped: %+v", deps[0])
+	}
+
+	var taskSess domain.Session
+	if err := db.Where("user_id = ? AND task_id = ?", 2, tasks[0].ID).First(&taskSess).Error; err != nil {
+		t.Fatalf("task session not imported: %v", err)
+	}
+	var count int64
+	db.Model(&domain.Message{}).Where("session_id = ?", taskSess.ID).Count(&count)
+	if count != 2 {
+		t.Errorf("expected 2 messages in task session, got %d", count)
+	}
+
+	// 原用户数据不受影响
+	db.Model(&domain.Task{}).Where("user_id = ?", 1).Count(&count)
+	if count != 2 {
+		t.Errorf("source user tasks changed, got %d", count)
+	}
+}
+
+func TestArchive_ImportReplace(t *testing.T) {
+	db := setupArchiveTestDB(t)
+	seedArchiveData(t, db)
+	svc := archive.NewService(db, task.NewRepository(db))
+	ctx := context.Background()
+
+	a, err := svc.Export(ctx, 1)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	a.Settings.Model = "gpt-y"
+
+	// 再导入一次，merge 模式下数据翻倍，全局会话复用已有的
+	if _, err := svc.Import(ctx, 1, a, false); err != nil {
+		t.Fatalf("merge import failed: %v", err)
+	}
+	var count int64
+	db.Model(&domain.Task{}).Where("user_id = ?", 1).Count(&count)
+	if count != 4 {
+		t.Fatalf("expected 4 tasks after merge, got %d", count)
+	}
+	db.Model(&domain.Session{}).Where("user_id = ? AND type = 'global'", 1).Count(&count)
+	if count != 1 {
+		t.Fatalf("expected a single global session after merge, got %d", count)
+	}
+
+	result, err := svc.Import(ctx, 1, a, true)
+	if err != nil {
+		t.Fatalf("replace import failed: %v", err)
+	}
+	if !result.SettingsRestored {
+		t.Error("expected existing settings to be updated")
+	}
+	db.Model(&domain.Task{}).Where("user_id = ?", 1).Count(&count)
+	if count != 2 {
+		t.Errorf("expected 2 tasks after replace, got %d", count)
+	}
+	db.Model(&domain.TaskStep{}).Count(&count)
+	if count != 3 {
+		t.Errorf("expected 3 steps after replace, got %d", count)
+	}
+	db.Model(&domain.Message{}).Count(&count)
+	if count != 3 {
+		t.Errorf("expected 3 messages after replace, got %d", count)
+	}
+
+	var setting domain.UserLLMSetting
+	db.Where("user_id = ?", 1).First(&setting)
+	if setting.Model != "gpt-y" || setting.APIKeyEnc != "secret" {
+		t.Errorf("unexpected settings after import: %+v", setting)
+	}
+}
+
+func TestArchive_ImportReplaceRemovesDataReferencingOldRecords(t *testing.T) {
+	db := setupArchiveTestDB(t)
+	seedArchiveData(t, db)
+	svc := archive.NewService(db, task.NewRepository(db))
+	ctx := context.Background()
+
+	a, err := svc.Export(ctx, 1)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	var old domain.Task
+	var oldSess domain.Session
+	db.Where("user_id = ?", 1).Order("id ASC").First(&old)
+	db.Where("user_id = ? AND type = 'task'", 1).First(&oldSess)
+	db.Create(&domain.TimeEntry{UserID: 1, TaskID: old.ID, StartedAt: time.Date(2025, 12, 8, 9, 0, 0, 0, time.UTC), DurationMin: 30})
+	db.Create(&domain.DailyPlan{UserID: 1, Date: "2025-12-08", Items: []domain.DailyPlanItem{{TaskID: old.ID}}})
+	db.Create(&domain.SessionSummary{SessionID: oldSess.ID, Content: "摘要", UpToMessageID: 1})
+	db.Create(&domain.Digest{UserID: 1, Kind: "daily", PeriodStart: "2025-12-08", PeriodEnd: "2025-12-09", Content: "回顾"})
+	db.Create(&domain.Memory{UserID: 1, Content: "喜欢早上写作", SessionID: &oldSess.ID})
+	// 其他用户的数据不受影响
+	db.Create(&domain.TimeEntry{UserID: 2, TaskID: 999, StartedAt: time.Date(2025, 12, 8, 9, 0, 0, 0, time.UTC), DurationMin: 15})
+
+	if _, err := svc.Import(ctx, 1, a, true); err != nil {
+		t.Fatalf("replace import failed: %v", err)
+	}
+	// 只有用户 1 有计划项和会话摘要，直接按表计数
+	for _, c := range []struct {
+		name  string
+		model any
+		where string
+	}{
+		{"time entries", &domain.TimeEntry{}, "user_id = 1"},
+		{"daily plans", &domain.DailyPlan{}, "user_id = 1"},
+		{"daily plan items", &domain.DailyPlanItem{}, "1 = 1"},
+		{"session summaries", &domain.SessionSummary{}, "1 = 1"},
+		{"digests", &domain.Digest{}, "user_id = 1"},
+	} {
+		if n := countRows(t, db, c.model, c.where); n != 0 {
+			t.Errorf("expected %s of the replaced data to be removed, got %d", c.name, n)
+		}
+	}
+	var count int64
+	db.Model(&domain.TimeEntry{}).Where("user_id = ?", 2).Count(&count)
+	if count != 1 {
+		t.Errorf("other users' time entries should be kept, got %d", count)
+	}
+	var memories []domain.Memory
+	db.Where("user_id = ?", 1).Find(&memories)
+	if len(memories) != 1 || memories[0].SessionID != nil {
+		t.Errorf("memories should be kept without the old session link, got %+v", memories)
+	}
+}
+
+func TestArchive_ReadRejectsUnknownVersion(t *testing.T) {
+	if _, err := archive.Read([]byte(`{"version": 99}`)); err == nil {
+		t.Error("expected error for unsupported version")
+	}
+	if _, err := archive.Read([]byte(`not json`)); err == nil {
+		t.Error("expected error for invalid json")
+	}
+}
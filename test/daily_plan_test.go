@@ -0,0 +1,380 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"assistant-qisumi/internal/agent"
+	"assistant-qisumi/internal/dailyplan"
+	"assistant-qisumi/internal/dependency"
+	"assistant-qisumi/internal/domain"
+	internalHTTP "assistant-qisumi/internal/http"
+	"assistant-qisumi/internal/llm"
+	"assistant-qisumi/internal/session"
+	"assistant-qisumi/internal/task"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupDailyPlanDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "plans.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&domain.Task{}, &domain.TaskStep{}, &domain.DailyPlan{}, &domain.DailyPlanItem{}); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	return db
+}
+
+func focusedTaskIDs(t *testing.T, db *gorm.DB) []uint64 {
+	var ids []uint64
+	if err := db.Model(&domain.Task{}).Where("is_focus_today = ?", true).Order("id").Pluck("id", &ids).Error; err != nil {
+		t.Fatalf("failed to query focus: %v", err)
+	}
+	return ids
+}
+
+func planItemsString(plan *dailyplan.DailyPlan) string {
+	var parts []string
+	for _, it := range plan.Items {
+		s := jsonID(it.TaskID)
+		if it.StepID != nil {
+			s += "/" + jsonID(*it.StepID)
+		}
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, ",")
+}
+
+func TestDailyPlanService_MarkUnmarkReorderAcrossDays(t *testing.T) {
+	db := setupDailyPlanDB(t)
+	a := &domain.Task{UserID: 1, Title: "写周报", Status: "todo", Steps: []domain.TaskStep{{Title: "整理数据", Status: "todo"}, {Title: "画图表", Status: "todo", OrderIndex: 1}}}
+	b := &domain.Task{UserID: 1, Title: "交房租", Status: "todo"}
+	c := &domain.Task{UserID: 1, Title: "订机票", Status: "todo"}
+	other := &domain.Task{UserID: 2, Title: "别人的任务", Status: "todo"}
+	for _, tk := range []*domain.Task{a, b, c, other} {
+		db.Create(tk)
+	}
+	step1, step2 := a.Steps[0].ID, a.Steps[1].ID
+
+	// 上海 12 月 8 日 23:30，UTC 仍是同一天；第二天早上再看
+	shanghai := time.FixedZone("CST", 8*3600)
+	ctx := domain.WithLocation(context.Background(), shanghai)
+	now := time.Date(2025, 12, 8, 23, 30, 0, 0, shanghai)
+	svc := dailyplan.NewService(db).WithClock(func() time.Time { return now })
+	today := svc.Today(ctx)
+	if today != "2025-12-08" {
+		t.Fatalf("today = %s, want 2025-12-08", today)
+	}
+
+	plan, err := svc.Mark(ctx, 1, today, []dailyplan.ItemRef{{TaskID: a.ID}, {TaskID: b.ID}, {TaskID: a.ID, StepID: &step1}, {TaskID: b.ID}})
+	if err != nil {
+		t.Fatalf("Mark failed: %v", err)
+	}
+	if plan.Status != dailyplan.StatusAccepted || planItemsString(plan) != jsonID(a.ID)+","+jsonID(b.ID)+","+jsonID(a.ID)+"/"+jsonID(step1) {
+		t.Fatalf("unexpected plan after mark: %s %s", plan.Status, planItemsString(plan))
+	}
+	if got := focusedTaskIDs(t, db); len(got) != 2 {
+		t.Errorf("marked tasks should be today's focus, got %v", got)
+	}
+	if _, err := svc.Mark(ctx, 1, today, []dailyplan.ItemRef{{TaskID: other.ID}}); !errors.Is(err, task.ErrNotOwned) {
+		t.Errorf("marking another user's task should fail with ErrNotOwned, got %v", err)
+	}
+
+	plan, _ = svc.Reorder(ctx, 1, today, []dailyplan.ItemRef{{TaskID: b.ID}, {TaskID: a.ID, StepID: &step1}, {TaskID: c.ID}})
+	if want := jsonID(b.ID) + "," + jsonID(a.ID) + "/" + jsonID(step1) + "," + jsonID(a.ID); planItemsString(plan) != want {
+		t.Errorf("reorder: got %s, want %s", planItemsString(plan), want)
+	}
+
+	// 移出任务时也移出它的步骤
+	plan, _ = svc.Unmark(ctx, 1, today, []dailyplan.ItemRef{{TaskID: a.ID}})
+	if planItemsString(plan) != jsonID(b.ID) {
+		t.Errorf("unmark should remove the task and its steps, got %s", planItemsString(plan))
+	}
+	if got := focusedTaskIDs(t, db); len(got) != 1 || got[0] != b.ID {
+		t.Errorf("only the remaining task should be focus, got %v", got)
+	}
+
+	// 日期变化后前一天的重点自动清除，旧计划仍可按日期查询
+	now = now.Add(10 * time.Hour)
+	view, err := svc.View(ctx, 1)
+	if err != nil {
+		t.Fatalf("View failed: %v", err)
+	}
+	if view.Date != "2025-12-09" || view.Plan != nil || !view.NeedsPlanning || len(focusedTaskIDs(t, db)) != 0 {
+		t.Errorf("new day should start without focus, got %+v focus=%v", view, focusedTaskIDs(t, db))
+	}
+	if old, _ := svc.Get(ctx, 1, "2025-12-08"); old == nil || len(old.Items) != 1 {
+		t.Errorf("yesterday's plan should be kept, got %+v", old)
+	}
+
+	// 拟定的计划确认前不影响今日重点
+	today = svc.Today(ctx)
+	plan, err = svc.Propose(ctx, 1, today, []dailyplan.ItemRef{{TaskID: c.ID}, {TaskID: a.ID, StepID: &step2}}, "先把机票订了")
+	if err != nil || plan.Status != dailyplan.StatusProposed || plan.ProposalNote != "先把机票订了" || len(plan.Proposal) != 2 || len(plan.Items) != 0 {
+		t.Fatalf("Propose: got %+v, %v", plan, err)
+	}
+	if len(focusedTaskIDs(t, db)) != 0 {
+		t.Error("proposed plan should not set focus before it is accepted")
+	}
+	if _, err := svc.Accept(ctx, 1, "2025-12-10"); !errors.Is(err, dailyplan.ErrNoPlan) {
+		t.Errorf("accepting a missing plan should return ErrNoPlan, got %v", err)
+	}
+	if _, err := svc.Accept(ctx, 1, today); err != nil {
+		t.Fatalf("Accept failed: %v", err)
+	}
+	view, _ = svc.View(ctx, 1)
+	if view.NeedsPlanning || len(view.Items) != 2 || view.Items[0].Task.ID != c.ID || view.Items[1].Step == nil || view.Items[1].Step.Title != "画图表" {
+		t.Errorf("unexpected today view: %+v", view)
+	}
+	if got := focusedTaskIDs(t, db); len(got) != 2 {
+		t.Errorf("accepted plan tasks should be focus, got %v", got)
+	}
+
+	if _, err := svc.ResolveDate(ctx, "12/09"); !errors.Is(err, dailyplan.ErrInvalidDate) {
+		t.Errorf("expected ErrInvalidDate, got %v", err)
+	}
+}
+
+func TestDailyPlanService_ProposalKeepsAcceptedPlanUntilAccepted(t *testing.T) {
+	db := setupDailyPlanDB(t)
+	a := &domain.Task{UserID: 1, Title: "写周报", Status: "todo"}
+	b := &domain.Task{UserID: 1, Title: "交房租", Status: "todo"}
+	for _, tk := range []*domain.Task{a, b} {
+		db.Create(tk)
+	}
+	ctx := context.Background()
+	svc := dailyplan.NewService(db)
+	today := svc.Today(ctx)
+	if _, err := svc.Mark(ctx, 1, today, []dailyplan.ItemRef{{TaskID: a.ID}}); err != nil {
+		t.Fatalf("Mark failed: %v", err)
+	}
+	if _, err := svc.SetNote(ctx, 1, today, "先写周报"); err != nil {
+		t.Fatalf("SetNote failed: %v", err)
+	}
+
+	// 拟定新计划后，用户没有确认（或拒绝了）时原来的计划和今日重点不变
+	if _, err := svc.Propose(ctx, 1, today, []dailyplan.ItemRef{{TaskID: b.ID}}, "改成交房租"); err != nil {
+		t.Fatalf("Propose failed: %v", err)
+	}
+	plan, err := svc.Get(ctx, 1, today)
+	if err != nil || plan.Status != dailyplan.StatusProposed || planItemsString(plan) != jsonID(a.ID) || plan.Note != "先写周报" {
+		t.Fatalf("accepted plan should be kept while a proposal is pending, got %+v, %v", plan, err)
+	}
+	if len(plan.Proposal) != 1 || plan.Proposal[0].TaskID != b.ID || plan.ProposalNote != "改成交房租" {
+		t.Errorf("unexpected proposal: %+v", plan)
+	}
+	if got := focusedTaskIDs(t, db); len(got) != 1 || got[0] != a.ID {
+		t.Errorf("focus should stay on the accepted plan, got %v", got)
+	}
+	view, err := svc.View(ctx, 1)
+	if err != nil || view.NeedsPlanning || len(view.Items) != 1 || view.Items[0].Task.ID != a.ID {
+		t.Errorf("today view should show the accepted plan, got %+v, %v", view, err)
+	}
+
+	// 确认后新计划替换原来的计划
+	plan, err = svc.Accept(ctx, 1, today)
+	if err != nil || plan.Status != dailyplan.StatusAccepted || planItemsString(plan) != jsonID(b.ID) || plan.Note != "改成交房租" || len(plan.Proposal) != 0 {
+		t.Fatalf("Accept: got %+v, %v", plan, err)
+	}
+	if got := focusedTaskIDs(t, db); len(got) != 1 || got[0] != b.ID {
+		t.Errorf("focus should follow the accepted proposal, got %v", got)
+	}
+	// 没有待确认的计划时 Accept 不做修改
+	plan, err = svc.Accept(ctx, 1, today)
+	if err != nil || planItemsString(plan) != jsonID(b.ID) {
+		t.Errorf("second Accept should keep the plan, got %+v, %v", plan, err)
+	}
+	if plan, _ := svc.Get(ctx, 1, today); plan.ProposalNote != "" || len(plan.Proposal) != 0 {
+		t.Errorf("accepted proposal should be cleared, got %+v", plan)
+	}
+}
+
+func TestGlobalAgent_ProposesAndAcceptsDailyPlan(t *testing.T) {
+	db, report, _ := setupReadToolsDB(t)
+	if err := db.AutoMigrate(&domain.UserProfile{}, &domain.TimeEntry{}, &domain.DailyPlan{}, &domain.DailyPlanItem{}); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	taskRepo, sessionRepo := task.NewRepository(db), session.NewRepository(db)
+	sess, err := sessionRepo.GetGlobalSessionOrCreate(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("failed to create global session: %v", err)
+	}
+	chartStep := report.Steps[1].ID
+	newService := func(client llm.Client) *agent.Service {
+		handler := agent.NewChatCompletionsHandler(client, agent.NewToolRegistry(taskRepo, sessionRepo))
+		return agent.NewService(agent.NewSimpleRouter(), agent.NewDefaultAgents(client, handler), taskRepo, sessionRepo,
+			dependency.NewService(db, taskRepo, sessionRepo), db, client)
+	}
+
+	propose := &scriptedToolLLMClient{
+		rounds: [][]llm.ToolCallFunc{{
+			{Name: "propose_daily_plan", Arguments: `{"items":[{"task_id":` + jsonID(report.ID) + `,"step_id":` + jsonID(chartStep) + `}],"note":"上午画完图表"}`},
+		}},
+		reply: "今天建议先画周报的图表，可以吗？",
+	}
+	if _, err := newService(propose).HandleUserMessage(context.Background(), 1, sess.ID, "帮我安排一下今天", llm.Config{Model: "m"}); err != nil {
+		t.Fatalf("HandleUserMessage failed: %v", err)
+	}
+	var planMsg string
+	for _, m := range propose.requests[0].Messages {
+		if strings.Contains(m.Content, "计划") && m.Role == "system" && strings.HasPrefix(m.Content, "今天") {
+			planMsg = m.Content
+		}
+	}
+	if !strings.Contains(planMsg, "还没有计划") {
+		t.Errorf("prompt should say there is no plan yet, got %q", planMsg)
+	}
+
+	plans := dailyplan.NewService(db)
+	ctx := context.Background()
+	plan, _ := plans.Get(ctx, 1, plans.Today(ctx))
+	if plan == nil || plan.Status != dailyplan.StatusProposed || len(plan.Proposal) != 1 || plan.ProposalNote != "上午画完图表" {
+		t.Fatalf("expected a proposed plan, got %+v", plan)
+	}
+
+	// 用户同意后确认计划，并把整个任务也加进来
+	accept := &scriptedToolLLMClient{
+		rounds: [][]llm.ToolCallFunc{{
+			{Name: "mark_tasks_focus_today", Arguments: `{"action":"accept"}`},
+			{Name: "mark_tasks_focus_today", Arguments: `{"task_ids":[` + jsonID(report.ID) + `]}`},
+		}},
+		reply: "好的，今天的计划定了",
+	}
+	resp, err := newService(accept).HandleUserMessage(context.Background(), 1, sess.ID, "可以", llm.Config{Model: "m"})
+	if err != nil {
+		t.Fatalf("HandleUserMessage failed: %v", err)
+	}
+	if len(resp.TaskPatches) != 2 {
+		t.Fatalf("expected two plan patches, got %+v", resp.TaskPatches)
+	}
+	var prompt string
+	for _, m := range accept.requests[0].Messages {
+		prompt += m.Content
+	}
+	if !strings.Contains(prompt, "待用户确认") || !strings.Contains(prompt, "步骤#"+jsonID(chartStep)+" 画图表") {
+		t.Errorf("prompt should show the proposed plan, got %q", prompt)
+	}
+	plan, _ = plans.Get(ctx, 1, plans.Today(ctx))
+	if plan.Status != dailyplan.StatusAccepted || len(plan.Items) != 2 {
+		t.Fatalf("plan should be accepted with both items, got %+v", plan)
+	}
+	updated, _ := taskRepo.GetTaskWithSteps(ctx, 1, report.ID)
+	if !updated.IsFocusToday {
+		t.Error("task in the accepted plan should be today's focus")
+	}
+}
+
+func TestAgentService_TaskSessionClearsStaleFocus(t *testing.T) {
+	db, report, _ := setupReadToolsDB(t)
+	if err := db.AutoMigrate(&domain.UserProfile{}, &domain.TimeEntry{}, &domain.DailyPlan{}, &domain.DailyPlanItem{}); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	// 昨天计划里的重点，今天还没有计划
+	if err := db.Model(&domain.Task{}).Where("id = ?", report.ID).Update("is_focus_today", true).Error; err != nil {
+		t.Fatalf("failed to mark focus: %v", err)
+	}
+	taskRepo, sessionRepo := task.NewRepository(db), session.NewRepository(db)
+	sess, err := sessionRepo.GetTaskSessionOrCreate(context.Background(), 1, report.ID)
+	if err != nil {
+		t.Fatalf("failed to get task session: %v", err)
+	}
+	client := &scriptedToolLLMClient{reply: "好的"}
+	handler := agent.NewChatCompletionsHandler(client, agent.NewToolRegistry(taskRepo, sessionRepo))
+	svc := agent.NewService(agent.NewSimpleRouter(), agent.NewDefaultAgents(client, handler), taskRepo, sessionRepo,
+		dependency.NewService(db, taskRepo, sessionRepo), db, client)
+	if _, err := svc.HandleUserMessage(context.Background(), 1, sess.ID, "进展如何", llm.Config{Model: "m"}); err != nil {
+		t.Fatalf("HandleUserMessage failed: %v", err)
+	}
+	svc.Wait()
+
+	if ids := focusedTaskIDs(t, db); len(ids) != 0 {
+		t.Errorf("stale focus should be cleared in task sessions too, still focused: %v", ids)
+	}
+}
+
+func TestDailyPlanHandler_TodayAndEdits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupDailyPlanDB(t)
+	a := &domain.Task{UserID: 1, Title: "写周报", Status: "todo"}
+	b := &domain.Task{UserID: 1, Title: "交房租", Status: "todo"}
+	other := &domain.Task{UserID: 2, Title: "别人的任务", Status: "todo"}
+	for _, tk := range []*domain.Task{a, b, other} {
+		db.Create(tk)
+	}
+
+	handler := internalHTTP.NewDailyPlanHandler(dailyplan.NewService(db), nil, nil, nil)
+	router := gin.New()
+	group := router.Group("/api")
+	group.Use(func(c *gin.Context) {
+		c.Set("userID", uint64(1))
+		c.Next()
+	})
+	handler.RegisterRoutes(group)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("GET", "/api/today", "")
+	var view dailyplan.TodayView
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &view) != nil || !view.NeedsPlanning || view.Plan != nil {
+		t.Fatalf("empty today: %d %s", w.Code, w.Body.String())
+	}
+
+	items := `{"items":[{"taskId":` + jsonID(a.ID) + `},{"taskId":` + jsonID(b.ID) + `}]}`
+	w = do("POST", "/api/daily-plans/today/items", items)
+	var marked struct {
+		Plan dailyplan.DailyPlan `json:"plan"`
+	}
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &marked) != nil || len(marked.Plan.Items) != 2 {
+		t.Fatalf("mark: %d %s", w.Code, w.Body.String())
+	}
+	// 返回的计划项可以直接发回来调整顺序
+	reversed, _ := json.Marshal(map[string]any{"items": []domain.DailyPlanItem{marked.Plan.Items[1], marked.Plan.Items[0]}})
+	w = do("PUT", "/api/daily-plans/today/order", string(reversed))
+	var reordered struct {
+		Plan dailyplan.DailyPlan `json:"plan"`
+	}
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &reordered) != nil || planItemsString(&reordered.Plan) != jsonID(b.ID)+","+jsonID(a.ID) {
+		t.Fatalf("reorder: %d %s", w.Code, w.Body.String())
+	}
+	if w := do("POST", "/api/daily-plans/today/unmark", `{"items":[{"taskId":`+jsonID(a.ID)+`}]}`); w.Code != http.StatusOK {
+		t.Fatalf("unmark: %d %s", w.Code, w.Body.String())
+	}
+	if w := do("PUT", "/api/daily-plans/today/note", `{"note":"早点交"}`); w.Code != http.StatusOK {
+		t.Fatalf("note: %d %s", w.Code, w.Body.String())
+	}
+
+	w = do("GET", "/api/today", "")
+	view = dailyplan.TodayView{}
+	if err := json.Unmarshal(w.Body.Bytes(), &view); err != nil {
+		t.Fatalf("decode today: %v", err)
+	}
+	if view.NeedsPlanning || view.Plan.Note != "早点交" || len(view.Items) != 1 || view.Items[0].Task.ID != b.ID || !view.Items[0].Task.IsFocusToday {
+		t.Errorf("unexpected today view: %s", w.Body.String())
+	}
+
+	if w := do("POST", "/api/daily-plans/today/items", `{"items":[{"taskId":`+jsonID(other.ID)+`}]}`); w.Code != http.StatusNotFound {
+		t.Errorf("foreign task: expected 404, got %d", w.Code)
+	}
+	if w := do("GET", "/api/daily-plans/2025-13-40", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid date: expected 400, got %d", w.Code)
+	}
+	if w := do("POST", "/api/daily-plans/2025-01-01/accept", ""); w.Code != http.StatusNotFound {
+		t.Errorf("accept without plan: expected 404, got %d", w.Code)
+	}
+}
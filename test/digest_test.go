@@ -0,0 +1,306 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"assistant-qisumi/internal/digest"
+	"assistant-qisumi/internal/domain"
+	internalHTTP "assistant-qisumi/internal/http"
+	"assistant-qisumi/internal/llm"
+	"assistant-qisumi/internal/profile"
+	"assistant-qisumi/internal/session"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+var digestLoc = time.FixedZone("CST", 8*3600)
+
+// digestSunday 2025-12-14 是周日，本周为 12-08 ~ 12-14
+func digestAt(day, hour int) time.Time {
+	return time.Date(2025, 12, day, hour, 0, 0, 0, digestLoc)
+}
+
+func digestTasks() []domain.Task {
+	at := func(day, hour int) *time.Time {
+		t := digestAt(day, hour)
+		return &t
+	}
+	return []domain.Task{
+		{ID: 1, UserID: 1, Title: "写周报", Status: "done", Priority: "high", DueAt: flex(digestAt(14, 18)), CompletedAt: at(14, 10),
+			Steps: []domain.TaskStep{{ID: 11, Title: "画图表", Status: "done", CompletedAt: at(14, 9)}}},
+		{ID: 2, UserID: 1, Title: "交房租", Status: "todo", Priority: "medium", DueAt: flex(digestAt(14, 12))},
+		{ID: 3, UserID: 1, Title: "报销", Status: "todo", Priority: "low", DueAt: flex(digestAt(1, 18))},
+		{ID: 4, UserID: 1, Title: "订机票", Status: "todo", Priority: "medium", DueAt: flex(digestAt(15, 9))},
+		{ID: 5, UserID: 1, Title: "装修", Status: "in_progress", Priority: "medium",
+			Steps: []domain.TaskStep{{ID: 51, Title: "改水电", Status: "blocked", BlockingReason: "等物业审批"}}},
+		{ID: 6, UserID: 1, Title: "旧任务", Status: "done", Priority: "low", DueAt: flex(digestAt(9, 18)), CompletedAt: at(10, 10)},
+		{ID: 7, UserID: 1, Title: "取消的任务", Status: "cancelled", Priority: "low", DueAt: flex(digestAt(14, 8))},
+	}
+}
+
+func digestTitles(items []digest.TaskItem) string {
+	var titles []string
+	for _, it := range items {
+		titles = append(titles, it.Title)
+	}
+	return strings.Join(titles, ",")
+}
+
+func TestDigestBuild_DailyAndWeekly(t *testing.T) {
+	now := digestAt(14, 22)
+	start, end := digest.Period(digest.KindDaily, now)
+	daily := digest.Build(digest.Input{Kind: digest.KindDaily, Start: start, End: end, Now: now, Tasks: digestTasks()})
+	if daily.PeriodStart != "2025-12-14" || daily.PeriodEnd != "2025-12-15" {
+		t.Errorf("daily period = %s ~ %s", daily.PeriodStart, daily.PeriodEnd)
+	}
+	checks := map[string][2]string{
+		"completed": {digestTitles(daily.Completed), "写周报"},
+		"slipped":   {digestTitles(daily.Slipped), "交房租"},
+		"overdue":   {digestTitles(daily.Overdue), "报销"},
+		"upcoming":  {digestTitles(daily.Upcoming), "订机票"},
+	}
+	for name, c := range checks {
+		if c[0] != c[1] {
+			t.Errorf("daily %s = %q, want %q", name, c[0], c[1])
+		}
+	}
+	if daily.CompletedSteps != 1 || len(daily.Blocked) != 1 || daily.Blocked[0].Reason != "等物业审批" {
+		t.Errorf("unexpected steps: completed=%d blocked=%+v", daily.CompletedSteps, daily.Blocked)
+	}
+
+	// 周报覆盖整周：迟交的旧任务算作完成且没按时完成，下周一到期的任务算作即将到期
+	start, end = digest.Period(digest.KindWeekly, now)
+	weekly := digest.Build(digest.Input{Kind: digest.KindWeekly, Start: start, End: end, Now: now, Tasks: digestTasks()})
+	if weekly.PeriodStart != "2025-12-08" || weekly.PeriodEnd != "2025-12-15" {
+		t.Errorf("weekly period = %s ~ %s", weekly.PeriodStart, weekly.PeriodEnd)
+	}
+	if got := digestTitles(weekly.Completed); got != "旧任务,写周报" {
+		t.Errorf("weekly completed = %q", got)
+	}
+	if got := digestTitles(weekly.Slipped); got != "旧任务,交房租" {
+		t.Errorf("weekly slipped = %q", got)
+	}
+
+	md := digest.Render(daily, digestLoc)
+	for _, want := range []string{"# 每日回顾 2025-12-14", "## 已完成（1）", "共完成 1 个步骤", "交房租：截止 12-14 12:00，仍未完成", "装修 / 改水电：等物业审批"} {
+		if !strings.Contains(md, want) {
+			t.Errorf("daily markdown should contain %q, got:\n%s", want, md)
+		}
+	}
+	if md := digest.Render(weekly, digestLoc); !strings.Contains(md, "# 每周回顾 2025-12-08 ~ 2025-12-14") || !strings.Contains(md, "旧任务：截止 12-09 18:00，于 12-10 10:00 完成") {
+		t.Errorf("unexpected weekly markdown:\n%s", md)
+	}
+
+	empty := digest.Build(digest.Input{Kind: digest.KindDaily, Start: start, End: start.AddDate(0, 0, 1), Now: now})
+	if !empty.Empty() || !strings.Contains(digest.Render(empty, digestLoc), "没有完成、到期或受阻的任务") {
+		t.Errorf("empty digest should say so, got %+v", empty)
+	}
+}
+
+func TestDigestBuild_DailyBeforeDayEndsIncludesLaterDueToday(t *testing.T) {
+	// 21:00 生成日报，当天 23:00 才到期的任务还没过期，算作即将到期
+	now := digestAt(14, 21)
+	tasks := append(digestTasks(), domain.Task{ID: 8, UserID: 1, Title: "倒垃圾", Status: "todo", Priority: "low", DueAt: flex(digestAt(14, 23))})
+	start, end := digest.Period(digest.KindDaily, now)
+	daily := digest.Build(digest.Input{Kind: digest.KindDaily, Start: start, End: end, Now: now, Tasks: tasks})
+	if got := digestTitles(daily.Upcoming); got != "倒垃圾,订机票" {
+		t.Errorf("daily upcoming = %q, want 倒垃圾,订机票", got)
+	}
+	if got := digestTitles(daily.Slipped); got != "交房租" {
+		t.Errorf("daily slipped = %q, want 交房租", got)
+	}
+}
+
+func setupDigestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "digests.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&domain.User{}, &domain.UserProfile{}, &domain.Task{}, &domain.TaskStep{},
+		&domain.Session{}, &domain.Message{}, &domain.Digest{}); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	db.Create(&domain.User{ID: 1, Email: "a@example.com", PasswordHash: "x"})
+	db.Create(&domain.UserProfile{UserID: 1, Timezone: "Asia/Shanghai", WorkStart: "09:00", WorkEnd: "18:00"})
+	for _, tk := range digestTasks() {
+		if err := db.Create(&tk).Error; err != nil {
+			t.Fatalf("failed to create task: %v", err)
+		}
+	}
+	return db
+}
+
+func TestDigestService_GeneratePolishAndJob(t *testing.T) {
+	db := setupDigestDB(t)
+	now := digestAt(14, 20)
+	polisher := &scriptedToolLLMClient{reply: "# 每日回顾 2025-12-14\n\n今天收获不错。"}
+	svc := digest.NewService(db, polisher).WithClock(func() time.Time { return now })
+	ctx := domain.WithLocation(context.Background(), digestLoc)
+
+	d, err := svc.Generate(ctx, 1, digest.KindDaily, "", nil)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if d.PeriodStart != "2025-12-14" || d.Polished || !strings.Contains(d.Content, "交房租") {
+		t.Fatalf("unexpected digest: %+v", d)
+	}
+	var data digest.Data
+	if err := json.Unmarshal([]byte(d.Data), &data); err != nil || len(data.Overdue) != 1 {
+		t.Errorf("structured data should be stored, got %s, %v", d.Data, err)
+	}
+
+	// 润色后覆盖同一周期的回顾；润色失败时保留自动生成的内容
+	d, err = svc.Generate(ctx, 1, digest.KindDaily, "2025-12-14", &llm.Config{Model: "m"})
+	if err != nil || !d.Polished || d.Content != polisher.reply {
+		t.Fatalf("expected polished digest, got %+v, %v", d, err)
+	}
+	if !strings.Contains(polisher.requests[0].Messages[1].Content, "交房租") {
+		t.Errorf("polisher should receive the generated digest")
+	}
+	polisher.reply = ""
+	d, err = svc.Generate(ctx, 1, digest.KindDaily, "2025-12-14", &llm.Config{Model: "m"})
+	if err != nil || d.Polished || !strings.Contains(d.Content, "## 已过期（1）") {
+		t.Fatalf("failed polishing should fall back to generated content, got %+v, %v", d, err)
+	}
+	if list, _ := svc.List(ctx, 1, digest.KindDaily, 10); len(list) != 1 {
+		t.Errorf("regenerating should replace the digest, got %d", len(list))
+	}
+	if _, err := svc.Generate(ctx, 1, digest.KindDaily, "12/14", nil); err == nil {
+		t.Error("expected error for invalid date")
+	}
+
+	// 定时任务：21 点前不生成；之后周日生成当天和本周的回顾并发到全局会话，只发一次
+	db.Where("1 = 1").Delete(&domain.Digest{})
+	sessions := session.NewRepository(db)
+	job := digest.NewJob(svc, sessions, profile.NewService(profile.NewRepository(db)), nil).
+		WithOptions(digest.JobOptions{Hour: 21})
+	if err := job.RunOnce(context.Background()); err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	if list, _ := svc.List(ctx, 1, "", 10); len(list) != 0 {
+		t.Fatalf("no digest should be generated before 21:00, got %d", len(list))
+	}
+	now = digestAt(14, 21)
+	for i := 0; i < 2; i++ {
+		if err := job.RunOnce(context.Background()); err != nil {
+			t.Fatalf("RunOnce failed: %v", err)
+		}
+	}
+	list, _ := svc.List(ctx, 1, "", 10)
+	if len(list) != 2 {
+		t.Fatalf("expected daily and weekly digests, got %+v", list)
+	}
+	sess, _ := sessions.GetGlobalSessionOrCreate(context.Background(), 1)
+	msgs, _ := sessions.ListRecentMessages(context.Background(), sess.ID, 10)
+	if len(msgs) != 2 || msgs[0].Role != "system" || msgs[0].AgentName == nil || *msgs[0].AgentName != "digest" {
+		t.Fatalf("digests should be delivered once to the global session, got %+v", msgs)
+	}
+	for _, d := range list {
+		if d.DeliveredAt == nil {
+			t.Errorf("delivered digest should record delivery time, got %+v", d)
+		}
+	}
+}
+
+func TestDigestJob_RetriesFailedDelivery(t *testing.T) {
+	db := setupDigestDB(t)
+	now := digestAt(13, 21)
+	svc := digest.NewService(db, nil).WithClock(func() time.Time { return now })
+	sessions := session.NewRepository(db)
+	job := digest.NewJob(svc, sessions, profile.NewService(profile.NewRepository(db)), nil).
+		WithOptions(digest.JobOptions{Hour: 21})
+	ctx := context.Background()
+
+	// 回顾已保存但发送失败，下一次运行时仍要发出
+	sess, _ := sessions.GetGlobalSessionOrCreate(ctx, 1)
+	if err := db.Migrator().DropTable(&domain.Message{}); err != nil {
+		t.Fatalf("failed to drop messages: %v", err)
+	}
+	if err := job.RunOnce(ctx); err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	list, _ := svc.List(ctx, 1, digest.KindDaily, 10)
+	if len(list) != 1 || list[0].DeliveredAt != nil {
+		t.Fatalf("digest should be saved but not marked delivered, got %+v", list)
+	}
+
+	if err := db.AutoMigrate(&domain.Message{}); err != nil {
+		t.Fatalf("failed to migrate messages: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := job.RunOnce(ctx); err != nil {
+			t.Fatalf("RunOnce failed: %v", err)
+		}
+	}
+	msgs, _ := sessions.ListRecentMessages(ctx, sess.ID, 10)
+	if len(msgs) != 1 || !strings.Contains(msgs[0].Content, "每日回顾 2025-12-13") {
+		t.Fatalf("digest should be delivered once after the failure, got %+v", msgs)
+	}
+	if list, _ := svc.List(ctx, 1, digest.KindDaily, 10); len(list) != 1 || list[0].DeliveredAt == nil {
+		t.Errorf("digest should be marked delivered, got %+v", list)
+	}
+}
+
+func TestDigestHandler_ListAndGenerate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupDigestDB(t)
+	svc := digest.NewService(db, nil).WithClock(func() time.Time { return digestAt(14, 22) })
+	handler := internalHTTP.NewDigestHandler(svc, nil)
+
+	router := gin.New()
+	group := router.Group("/api")
+	group.Use(func(c *gin.Context) {
+		c.Set("userID", uint64(1))
+		c.Request = c.Request.WithContext(domain.WithLocation(c.Request.Context(), digestLoc))
+		c.Next()
+	})
+	handler.RegisterRoutes(group)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("POST", "/api/digests", `{"kind":"weekly","date":"2025-12-10"}`)
+	var d domain.Digest
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &d) != nil || d.PeriodStart != "2025-12-08" {
+		t.Fatalf("generate weekly: %d %s", w.Code, w.Body.String())
+	}
+	if w := do("POST", "/api/digests", ""); w.Code != http.StatusOK {
+		t.Fatalf("generate daily: %d %s", w.Code, w.Body.String())
+	}
+	if w := do("POST", "/api/digests", `{"kind":"monthly"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown kind: expected 400, got %d", w.Code)
+	}
+	if w := do("POST", "/api/digests", `{"date":"yesterday"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid date: expected 400, got %d", w.Code)
+	}
+
+	w = do("GET", "/api/digests?kind=weekly", "")
+	var list struct {
+		Digests []domain.Digest `json:"digests"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || len(list.Digests) != 1 || list.Digests[0].Kind != "weekly" {
+		t.Fatalf("list weekly: %s", w.Body.String())
+	}
+	if w := do("GET", "/api/digests", ""); !strings.Contains(w.Body.String(), "每日回顾") {
+		t.Errorf("list all should include the daily digest: %s", w.Body.String())
+	}
+	if w := do("GET", "/api/digests/"+jsonID(list.Digests[0].ID), ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "每周回顾") {
+		t.Errorf("get digest: %d %s", w.Code, w.Body.String())
+	}
+	if w := do("GET", "/api/digests/999", ""); w.Code != http.StatusNotFound {
+		t.Errorf("missing digest: expected 404, got %d", w.Code)
+	}
+}
@@ -0,0 +1,120 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"assistant-qisumi/internal/agent"
+	"assistant-qisumi/internal/dependency"
+	"assistant-qisumi/internal/domain"
+	"assistant-qisumi/internal/llm"
+	"assistant-qisumi/internal/session"
+	"assistant-qisumi/internal/task"
+)
+
+func TestGlobalAgent_UpdatesStepsAndCreatesTasks(t *testing.T) {
+	db, report, _ := setupReadToolsDB(t)
+	if err := db.AutoMigrate(&domain.UserProfile{}, &domain.TimeEntry{}); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	taskRepo, sessionRepo := task.NewRepository(db), session.NewRepository(db)
+	sess, err := sessionRepo.GetGlobalSessionOrCreate(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("failed to create global session: %v", err)
+	}
+	chartStep := report.Steps[1].ID
+
+	// 同一轮完成周报任务的步骤并创建新任务
+	client := &scriptedToolLLMClient{
+		rounds: [][]llm.ToolCallFunc{{
+			{Name: "update_steps", Arguments: `{"task_id":` + jsonID(report.ID) + `,"updates":[{"step_id":` + jsonID(chartStep) + `,"fields":{"status":"done"}}]}`},
+			{Name: "create_task", Arguments: `{"title":"订机票","due_at":"2025-12-20T12:00:00","priority":"high","steps":[{"title":"比价","estimate_minutes":20}]}`},
+		}},
+		reply: "图表画完了，周报也完成了",
+	}
+	handler := agent.NewChatCompletionsHandler(client, agent.NewToolRegistry(taskRepo, sessionRepo))
+	svc := agent.NewService(agent.NewSimpleRouter(), agent.NewDefaultAgents(client, handler), taskRepo, sessionRepo,
+		dependency.NewService(db, taskRepo, sessionRepo), db, client)
+	resp, err := svc.HandleUserMessage(context.Background(), 1, sess.ID, "周报的图表画好了，另外帮我建个订机票的任务", llm.Config{Model: "m"})
+	if err != nil {
+		t.Fatalf("HandleUserMessage failed: %v", err)
+	}
+	if len(resp.TaskPatches) != 2 {
+		t.Fatalf("expected update_step and create_task patches, got %+v", resp.TaskPatches)
+	}
+
+	// 提示词里带有步骤摘要，模型才能引用其他任务的步骤
+	var index string
+	for _, m := range client.requests[0].Messages {
+		if strings.Contains(m.Content, "任务索引") {
+			index = m.Content
+		}
+	}
+	if !strings.Contains(index, "步骤#"+jsonID(chartStep)+" | todo | 画图表") {
+		t.Errorf("task index should list steps, got %s", index)
+	}
+	tools := map[string]bool{}
+	for _, tool := range client.requests[0].Tools {
+		tools[tool.Function.Name] = true
+	}
+	for _, name := range []string{"update_steps", "add_steps", "create_task", "add_dependencies"} {
+		if !tools[name] {
+			t.Errorf("global agent should offer %s", name)
+		}
+	}
+
+	updated, err := taskRepo.GetTaskWithSteps(context.Background(), 1, report.ID)
+	if err != nil {
+		t.Fatalf("GetTaskWithSteps failed: %v", err)
+	}
+	if updated.Steps[1].Status != "done" || updated.Status != "done" {
+		t.Errorf("step and task should be done, got step=%s task=%s", updated.Steps[1].Status, updated.Status)
+	}
+	created, err := taskRepo.SearchTasks(context.Background(), 1, "订机票", 10)
+	if err != nil || len(created) != 1 || created[0].Priority != "high" || len(created[0].Steps) != 1 || created[0].DueAt == nil {
+		t.Fatalf("new task should be created with its steps, got %+v, %v", created, err)
+	}
+}
+
+func TestGlobalAgent_RejectsForeignTaskIDs(t *testing.T) {
+	db, report, _ := setupReadToolsDB(t)
+	var foreign domain.Task
+	db.Preload("Steps").Where("user_id = ?", 2).First(&foreign)
+	taskRepo := task.NewRepository(db)
+	ctx := context.Background()
+
+	err := taskRepo.CheckOwnership(ctx, 1, []uint64{report.ID, foreign.ID}, nil)
+	if !errors.Is(err, task.ErrNotOwned) || !strings.Contains(err.Error(), "task "+jsonID(foreign.ID)) {
+		t.Errorf("foreign task should be rejected, got %v", err)
+	}
+	// 步骤必须属于声明的任务
+	err = taskRepo.CheckOwnership(ctx, 1, nil, []task.StepRef{{TaskID: report.ID, StepID: 999}})
+	if !errors.Is(err, task.ErrNotOwned) {
+		t.Errorf("step of another task should be rejected, got %v", err)
+	}
+	if err := taskRepo.CheckOwnership(ctx, 1, []uint64{report.ID}, []task.StepRef{{TaskID: report.ID, StepID: report.Steps[0].ID}}); err != nil {
+		t.Errorf("own task and step should pass, got %v", err)
+	}
+
+	client := &scriptedToolLLMClient{
+		rounds: [][]llm.ToolCallFunc{{
+			{Name: "add_dependencies", Arguments: `{"items":[{"predecessor_task_id":` + jsonID(foreign.ID) + `,"predecessor_step_id":null,"successor_task_id":` + jsonID(report.ID) + `,"successor_step_id":null,"condition":"task_done","action":"notify_only"}]}`},
+		}},
+		reply: "没有找到这个任务",
+	}
+	handler := agent.NewChatCompletionsHandler(client, agent.NewToolRegistry(taskRepo, nil))
+	req := agent.AgentRequest{UserID: 1, LLMConfig: llm.Config{Model: "m"}}
+	_, patches, err := handler.HandleChatCompletions(ctx, req, []llm.Message{{Role: "user", Content: "别人的任务完成后再写周报"}}, []string{"add_dependencies"})
+	if err != nil {
+		t.Fatalf("HandleChatCompletions failed: %v", err)
+	}
+	if len(patches) != 0 {
+		t.Errorf("foreign task IDs should not produce patches, got %+v", patches)
+	}
+	toolMsg := client.requests[1].Messages[len(client.requests[1].Messages)-1]
+	if toolMsg.Role != "tool" || !strings.Contains(toolMsg.Content, "not found, nothing was changed") || client.requests[1].ToolChoice != "auto" {
+		t.Errorf("model should be told the task was not found and allowed to retry, got %+v (%q)", toolMsg, client.requests[1].ToolChoice)
+	}
+}
@@ -0,0 +1,210 @@
+package test
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"assistant-qisumi/internal/agent"
+	"assistant-qisumi/internal/dependency"
+	"assistant-qisumi/internal/domain"
+	"assistant-qisumi/internal/history"
+	"assistant-qisumi/internal/llm"
+	"assistant-qisumi/internal/prompts"
+	"assistant-qisumi/internal/session"
+	"assistant-qisumi/internal/task"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupHistoryDB(t *testing.T) (*gorm.DB, *session.Repository, *session.Session) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "history.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&domain.Session{}, &domain.Message{}, &domain.SessionSummary{}); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	repo := session.NewRepository(db)
+	sess, err := repo.GetGlobalSessionOrCreate(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	return db, repo, sess
+}
+
+// addTurns 写入 n 轮对话，每条消息约 100 token
+func addTurns(t *testing.T, repo *session.Repository, sessionID uint64, from, n int) {
+	for i := from; i < from+n; i++ {
+		for _, role := range []string{"user", "assistant"} {
+			m := session.Message{SessionID: sessionID, Role: role, Content: role + jsonID(uint64(i)) + strings.Repeat("字", 95)}
+			if err := repo.CreateMessage(context.Background(), &m); err != nil {
+				t.Fatalf("CreateMessage failed: %v", err)
+			}
+		}
+	}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	cases := map[string]int{
+		"":            0,
+		"hello world": 3,
+		"今天写周报":       5,
+		"写 weekly 周报": 5,
+	}
+	for text, want := range cases {
+		if got := llm.EstimateTokens(text); got != want {
+			t.Errorf("EstimateTokens(%q) = %d, want %d", text, got, want)
+		}
+	}
+}
+
+func TestHistoryService_LoadAndCompactIncrementally(t *testing.T) {
+	db, repo, sess := setupHistoryDB(t)
+	ctx := context.Background()
+	client := &scriptedToolLLMClient{reply: "用户在写周报，已完成数据整理"}
+	svc := history.NewService(db, client).WithBudget(history.Budget{RecentTokens: 1000, KeepTokens: 400, MaxMessages: 200})
+
+	addTurns(t, repo, sess.ID, 0, 3)
+	hc, err := svc.Load(ctx, sess.ID)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if hc.Summary != nil || len(hc.Messages) != 6 {
+		t.Fatalf("short history should be returned as is, got %d messages", len(hc.Messages))
+	}
+	if updated, err := svc.Compact(ctx, sess.ID, llm.Config{Model: "m"}); err != nil || updated || len(client.requests) != 0 {
+		t.Fatalf("history within budget should not be summarized, got %v, %v", updated, err)
+	}
+
+	// 超出预算：加载时只保留预算内的最近消息，摘要后只保留 KeepTokens 内的消息
+	addTurns(t, repo, sess.ID, 3, 5)
+	hc, _ = svc.Load(ctx, sess.ID)
+	if len(hc.Messages) != 9 || !strings.HasPrefix(hc.Messages[len(hc.Messages)-1].Content, "assistant7") {
+		t.Fatalf("expected the 9 most recent messages within budget, got %d", len(hc.Messages))
+	}
+	updated, err := svc.Compact(ctx, sess.ID, llm.Config{Model: "m"})
+	if err != nil || !updated {
+		t.Fatalf("Compact failed: %v, %v", updated, err)
+	}
+	prompt := client.requests[0].Messages[1].Content
+	if !strings.Contains(prompt, "（无）") || !strings.Contains(prompt, "[用户] user0") || strings.Contains(prompt, "assistant6") {
+		t.Errorf("first summary should cover only the older messages, got %q", prompt)
+	}
+
+	hc, _ = svc.Load(ctx, sess.ID)
+	if hc.Summary == nil || hc.Summary.MessageCount != 13 || len(hc.Messages) != 3 {
+		t.Fatalf("expected a summary of 13 messages and 3 recent ones, got %+v and %d", hc.Summary, len(hc.Messages))
+	}
+	agentMsgs := hc.AgentMessages()
+	if agentMsgs[0].Role != "system" || !strings.Contains(agentMsgs[0].Content, "较早的 13 条消息的摘要") || !strings.Contains(agentMsgs[0].Content, client.reply) {
+		t.Errorf("agent history should start with the summary, got %+v", agentMsgs[0])
+	}
+
+	// 再次超出预算时只把新的较早消息合并进已有摘要
+	addTurns(t, repo, sess.ID, 8, 4)
+	client.reply = "用户在写周报，已完成数据整理和图表"
+	if updated, err := svc.Compact(ctx, sess.ID, llm.Config{Model: "m"}); err != nil || !updated {
+		t.Fatalf("second Compact failed: %v, %v", updated, err)
+	}
+	prompt = client.requests[1].Messages[1].Content
+	if !strings.Contains(prompt, "用户在写周报，已完成数据整理\n") || strings.Contains(prompt, "user6") || !strings.Contains(prompt, "[助手] assistant6") {
+		t.Errorf("second summary should merge only newer messages into the old summary, got %q", prompt)
+	}
+	hc, _ = svc.Load(ctx, sess.ID)
+	if hc.Summary.MessageCount != 21 || hc.Summary.Content != client.reply {
+		t.Errorf("unexpected summary after second compaction: %+v", hc.Summary)
+	}
+
+	// 清空消息时摘要一并删除
+	if err := repo.ClearMessages(ctx, sess.ID); err != nil {
+		t.Fatalf("ClearMessages failed: %v", err)
+	}
+	if hc, _ := svc.Load(ctx, sess.ID); hc.Summary != nil || len(hc.Messages) != 0 {
+		t.Errorf("expected empty history after clearing, got %+v", hc)
+	}
+}
+
+func TestHistoryService_CompactsBacklogInBatches(t *testing.T) {
+	db, repo, sess := setupHistoryDB(t)
+	ctx := context.Background()
+	client := &scriptedToolLLMClient{reply: "较早的对话"}
+	// 每次最多读取 4 条，远少于未摘要的消息
+	svc := history.NewService(db, client).WithBudget(history.Budget{RecentTokens: 1000, KeepTokens: 250, MaxMessages: 4})
+
+	addTurns(t, repo, sess.ID, 0, 10)
+	updated, err := svc.Compact(ctx, sess.ID, llm.Config{Model: "m"})
+	if err != nil || !updated {
+		t.Fatalf("Compact failed: %v, %v", updated, err)
+	}
+	// 保留最近 2 条，较早的 18 条从最早的开始每批 4 条依次合并
+	if len(client.requests) != 5 {
+		t.Fatalf("expected 5 summary batches, got %d", len(client.requests))
+	}
+	first := client.requests[0].Messages[1].Content
+	if !strings.Contains(first, "（无）") || !strings.Contains(first, "[用户] user0") || !strings.Contains(first, "[助手] assistant1") || strings.Contains(first, "user2") {
+		t.Errorf("first batch should start from the oldest message, got %q", first)
+	}
+	last := client.requests[4].Messages[1].Content
+	if !strings.Contains(last, "较早的对话\n") || !strings.Contains(last, "[用户] user8") || !strings.Contains(last, "[助手] assistant8") || strings.Contains(last, "user9") {
+		t.Errorf("last batch should merge the messages right before the kept ones, got %q", last)
+	}
+
+	hc, _ := svc.Load(ctx, sess.ID)
+	if hc.Summary == nil || hc.Summary.MessageCount != 18 || len(hc.Messages) != 2 || !strings.HasPrefix(hc.Messages[0].Content, "user9") {
+		t.Fatalf("expected a summary of 18 messages and the last turn, got %+v and %d", hc.Summary, len(hc.Messages))
+	}
+}
+
+func TestAgentService_SummarizesLongHistory(t *testing.T) {
+	db, _, _ := setupReadToolsDB(t)
+	if err := db.AutoMigrate(&domain.UserProfile{}, &domain.DailyPlan{}, &domain.DailyPlanItem{}); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	ctx := context.Background()
+	taskRepo, sessionRepo := task.NewRepository(db), session.NewRepository(db)
+	sess, err := sessionRepo.GetGlobalSessionOrCreate(ctx, 1)
+	if err != nil {
+		t.Fatalf("failed to create global session: %v", err)
+	}
+	addTurns(t, sessionRepo, sess.ID, 0, 10)
+
+	client := &scriptedToolLLMClient{reply: "好的"}
+	handler := agent.NewChatCompletionsHandler(client, agent.NewToolRegistry(taskRepo, sessionRepo))
+	svc := agent.NewService(agent.NewSimpleRouter(), agent.NewDefaultAgents(client, handler), taskRepo, sessionRepo,
+		dependency.NewService(db, taskRepo, sessionRepo), db, client)
+	svc.SetHistoryBudget(history.Budget{RecentTokens: 1000, KeepTokens: 400, MaxMessages: 200})
+
+	if _, err := svc.HandleUserMessage(ctx, 1, sess.ID, "继续", llm.Config{Model: "m"}); err != nil {
+		t.Fatalf("HandleUserMessage failed: %v", err)
+	}
+	svc.Wait()
+	var first []string
+	for _, m := range client.requests[0].Messages {
+		if strings.HasPrefix(m.Content, "user") || strings.HasPrefix(m.Content, "assistant") {
+			first = append(first, m.Content[:strings.Index(m.Content, "字")])
+		}
+	}
+	if len(first) != 9 || first[0] != "assistant5" {
+		t.Errorf("first turn should only see the recent messages within budget, got %v", first)
+	}
+	if len(client.requests) != 2 || client.requests[1].Messages[0].Content != prompts.HistorySummarySystemPrompt {
+		t.Fatalf("expected one agent call and one summary call, got %d", len(client.requests))
+	}
+
+	if _, err := svc.HandleUserMessage(ctx, 1, sess.ID, "然后呢", llm.Config{Model: "m"}); err != nil {
+		t.Fatalf("HandleUserMessage failed: %v", err)
+	}
+	svc.Wait()
+	var summaryMsg string
+	for _, m := range client.requests[2].Messages {
+		if m.Role == "system" && strings.Contains(m.Content, "条消息的摘要") {
+			summaryMsg = m.Content
+		}
+	}
+	if !strings.Contains(summaryMsg, "好的") {
+		t.Errorf("second turn should see the running summary, got %q", summaryMsg)
+	}
+}
@@ -0,0 +1,359 @@
+package test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"assistant-qisumi/internal/importer"
+	"assistant-qisumi/internal/task"
+)
+
+type expectedImportTask struct {
+	title       string
+	description string
+	priority    string
+	status      string
+	due         string // RFC3339，空表示没有截止时间
+	steps       []string
+	doneSteps   int
+}
+
+func checkImportedTasks(t *testing.T, got []*task.Task, want []expectedImportTask) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("expected %d tasks, got %d", len(want), len(got))
+	}
+	for i, w := range want {
+		g := got[i]
+		if g.Title != w.title {
+			t.Errorf("task %d: title = %q, want %q", i, g.Title, w.title)
+		}
+		if g.Description != w.description {
+			t.Errorf("task %d: description = %q, want %q", i, g.Description, w.description)
+		}
+		if w.priority != "" && g.Priority != w.priority {
+			t.Errorf("task %d: priority = %q, want %q", i, g.Priority, w.priority)
+		}
+		if w.status != "" && g.Status != w.status {
+			t.Errorf("task %d: status = %q, want %q", i, g.Status, w.status)
+		}
+		switch {
+		case w.due == "" && g.DueAt != nil:
+			t.Errorf("task %d: unexpected due %v", i, g.DueAt.ToTime())
+		case w.due != "":
+			wantDue, _ := time.Parse(time.RFC3339, w.due)
+			if g.DueAt == nil || !g.DueAt.ToTime().Equal(wantDue) {
+				t.Errorf("task %d: due = %v, want %v", i, g.DueAt, wantDue)
+			}
+		}
+		if len(g.Steps) != len(w.steps) {
+			t.Fatalf("task %d: expected %d steps, got %d", i, len(w.steps), len(g.Steps))
+		}
+		done := 0
+		for j, s := range w.steps {
+			if g.Steps[j].Title != s {
+				t.Errorf("task %d step %d: title = %q, want %q", i, j, g.Steps[j].Title, s)
+			}
+			if g.Steps[j].OrderIndex != j {
+				t.Errorf("task %d step %d: order_index = %d, want %d", i, j, g.Steps[j].OrderIndex, j)
+			}
+			if g.Steps[j].Status == "done" {
+				done++
+			}
+		}
+		if done != w.doneSteps {
+			t.Errorf("task %d: %d done steps, want %d", i, done, w.doneSteps)
+		}
+	}
+}
+
+func TestParseICS(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []expectedImportTask
+		wantErr bool
+	}{
+		{
+			name:  "single todo with utc due",
+			input: "BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nUID:1\r\nSUMMARY:写周报\r\nDESCRIPTION:第一行\\n第二行\\, 带逗号\r\nDUE:20251201T100000Z\r\nPRIORITY:1\r\nEND:VTODO\r\nEND:VCALENDAR\r\n",
+			want: []expectedImportTask{
+				{title: "写周报", description: "第一行\n第二行, 带逗号", priority: "high", status: "todo", due: "2025-12-01T10:00:00Z"},
+			},
+		},
+		{
+			name:  "folded lines and tzid",
+			input: "BEGIN:VTODO\nSUMMARY:Prepare the quarterly\n  review deck\nDUE;TZID=Asia/Shanghai:20251201T180000\nPRIORITY:9\nSTATUS:IN-PROCESS\nEND:VTODO\n",
+			want: []expectedImportTask{
+				{title: "Prepare the quarterly review deck", priority: "low", status: "in_progress", due: "2025-12-01T18:00:00+08:00"},
+			},
+		},
+		{
+			name: "date only due and related-to children become steps",
+			input: "BEGIN:VTODO\nUID:parent\nSUMMARY:Trip\nDUE;VALUE=DATE:20251224\nEND:VTODO\n" +
+				"BEGIN:VTODO\nUID:c1\nRELATED-TO:parent\nSUMMARY:Book flight\nSTATUS:COMPLETED\nEND:VTODO\n" +
+				"BEGIN:VTODO\nUID:c2\nRELATED-TO:parent\nSUMMARY:Book hotel\nEND:VTODO\n",
+			want: []expectedImportTask{
+				{title: "Trip", priority: "medium", due: "2025-12-24T00:00:00Z", steps: []string{"Book flight", "Book hotel"}, doneSteps: 1},
+			},
+		},
+		{
+			name: "properties of nested alarms are ignored",
+			input: "BEGIN:VTODO\nUID:1\nSUMMARY:交房租\nDESCRIPTION:转账给房东\n" +
+				"BEGIN:VALARM\nACTION:DISPLAY\nDESCRIPTION:Reminder\nTRIGGER:-PT15M\nEND:VALARM\n" +
+				"PRIORITY:1\nEND:VTODO\n",
+			want: []expectedImportTask{
+				{title: "交房租", description: "转账给房东", priority: "high", status: "todo"},
+			},
+		},
+		{
+			name:  "orphan child becomes task",
+			input: "BEGIN:VTODO\nUID:c1\nRELATED-TO:missing\nSUMMARY:Orphan\nEND:VTODO\n",
+			want:  []expectedImportTask{{title: "Orphan"}},
+		},
+		{
+			name:  "ignores events",
+			input: "BEGIN:VEVENT\nSUMMARY:Meeting\nEND:VEVENT\n",
+			want:  nil,
+		},
+		{
+			name:    "missing summary",
+			input:   "BEGIN:VTODO\nUID:1\nEND:VTODO\n",
+			wantErr: true,
+		},
+		{
+			name:    "unterminated todo",
+			input:   "BEGIN:VTODO\nSUMMARY:x\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid due",
+			input:   "BEGIN:VTODO\nSUMMARY:x\nDUE:tomorrow\nEND:VTODO\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := importer.ParseICS(strings.NewReader(tt.input))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			checkImportedTasks(t, got, tt.want)
+		})
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []expectedImportTask
+		wantErr bool
+	}{
+		{
+			name:  "all columns",
+			input: "title,description,due,priority,steps\n写论文,AIGC 小论文,2025-12-08,high,查资料;写初稿;[x]定题\n",
+			want: []expectedImportTask{
+				{title: "写论文", description: "AIGC 小论文", priority: "high", due: "2025-12-08T00:00:00Z", steps: []string{"查资料", "写初稿", "定题"}, doneSteps: 1},
+			},
+		},
+		{
+			name:  "aliases, reordered columns and multiline steps",
+			input: "Priority,Name,Steps\nlow,\"Clean up\",\"a\nb\"\n,Second,\n",
+			want: []expectedImportTask{
+				{title: "Clean up", priority: "low", steps: []string{"a", "b"}},
+				{title: "Second", priority: "medium"},
+			},
+		},
+		{
+			name:  "skips blank rows",
+			input: "title,description\n,\nOnly,\n",
+			want:  []expectedImportTask{{title: "Only"}},
+		},
+		{
+			name:    "missing title column",
+			input:   "description,due\nx,2025-01-01\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid priority",
+			input:   "title,priority\nx,asap\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid due",
+			input:   "title,due\nx,someday\n",
+			wantErr: true,
+		},
+		{
+			name:    "empty input",
+			input:   "",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := importer.ParseCSV(strings.NewReader(tt.input))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			checkImportedTasks(t, got, tt.want)
+		})
+	}
+}
+
+func TestParseMarkdown(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []expectedImportTask
+	}{
+		{
+			name: "headings with checklists",
+			input: `# 待办
+
+## 出差准备
+记得带充电器
+- [ ] 订机票
+- [x] 订酒店
+
+### Read paper
+- [ ] Download
+* [X] Skim
+`,
+			want: []expectedImportTask{
+				{title: "出差准备", description: "记得带充电器", steps: []string{"订机票", "订酒店"}, doneSteps: 1},
+				{title: "Read paper", steps: []string{"Download", "Skim"}, doneSteps: 1},
+			},
+		},
+		{
+			name:  "checklist without heading",
+			input: "- [ ] Buy milk\n- [x] Call mom\n- plain bullet\n",
+			want: []expectedImportTask{
+				{title: "Buy milk", status: "todo"},
+				{title: "Call mom", status: "done"},
+			},
+		},
+		{
+			name:  "ignores checkboxes inside code fences",
+			input: "# Task\n```\n- [ ] not a step\n```\n- [ ] real step\n",
+			want: []expectedImportTask{
+				{title: "Task", description: "```\n- [ ] not a step\n```", steps: []string{"real step"}},
+			},
+		},
+		{
+			name:  "hashtag is not a heading",
+			input: "#tag\n- [ ] item\n",
+			want:  []expectedImportTask{{title: "item"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := importer.ParseMarkdown(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			checkImportedTasks(t, got, tt.want)
+		})
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    importer.Format
+		wantErr bool
+	}{
+		{input: "ics", want: importer.FormatICS},
+		{input: ".ICS", want: importer.FormatICS},
+		{input: "csv", want: importer.FormatCSV},
+		{input: ".md", want: importer.FormatMarkdown},
+		{input: "markdown", want: importer.FormatMarkdown},
+		{input: "xlsx", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := importer.ParseFormat(tt.input)
+		if tt.wantErr != (err != nil) {
+			t.Errorf("ParseFormat(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseFormat(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestService_ImportTasks(t *testing.T) {
+	db := setupTaskServiceTestDB(t)
+	service := task.NewService(task.NewRepository(db), nil)
+
+	tasks, err := importer.Parse(importer.FormatMarkdown, strings.NewReader("# A\n- [ ] a1\n- [ ] a2\n# B\n- [ ] b1\n"))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+
+	if err := service.ImportTasks(context.Background(), 7, tasks); err != nil {
+		t.Fatalf("ImportTasks failed: %v", err)
+	}
+
+	var stored []task.Task
+	if err := db.Preload("Steps").Where("user_id = ?", 7).Order("id").Find(&stored).Error; err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if len(stored) != 2 || len(stored[0].Steps) != 2 || len(stored[1].Steps) != 1 {
+		t.Fatalf("unexpected stored tasks: %+v", stored)
+	}
+	if stored[0].CreatedFrom != "import:markdown" {
+		t.Errorf("created_from = %q, want import:markdown", stored[0].CreatedFrom)
+	}
+}
+
+func TestImport_FloatingTimesUseUserZone(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skipf("tzdata unavailable: %v", err)
+	}
+	tests := []struct {
+		name   string
+		format importer.Format
+		input  string
+		want   string
+	}{
+		{name: "csv date", format: importer.FormatCSV, input: "title,due\nx,2025-12-08\n", want: "2025-12-08T00:00:00+08:00"},
+		{name: "csv date-time", format: importer.FormatCSV, input: "title,due\nx,2025-12-08 18:00\n", want: "2025-12-08T18:00:00+08:00"},
+		{name: "csv zoned", format: importer.FormatCSV, input: "title,due\nx,2025-12-08T18:00:00Z\n", want: "2025-12-08T18:00:00Z"},
+		{name: "ics floating", format: importer.FormatICS, input: "BEGIN:VTODO\nSUMMARY:x\nDUE:20251208T180000\nEND:VTODO\n", want: "2025-12-08T18:00:00+08:00"},
+		{name: "ics utc", format: importer.FormatICS, input: "BEGIN:VTODO\nSUMMARY:x\nDUE:20251208T180000Z\nEND:VTODO\n", want: "2025-12-08T18:00:00Z"},
+		{name: "ics tzid", format: importer.FormatICS, input: "BEGIN:VTODO\nSUMMARY:x\nDUE;TZID=America/New_York:20251208T180000\nEND:VTODO\n", want: "2025-12-08T18:00:00-05:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tasks, err := importer.Parse(tt.format, strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("parse failed: %v", err)
+			}
+			tasks[0].AssumeLocation(shanghai)
+			want, _ := time.Parse(time.RFC3339, tt.want)
+			if got := tasks[0].DueAt.ToTime(); !got.Equal(want) {
+				t.Errorf("due = %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"assistant-qisumi/internal/agent"
+	"assistant-qisumi/internal/auth"
+	"assistant-qisumi/internal/domain"
+	internalHTTP "assistant-qisumi/internal/http"
+	"assistant-qisumi/internal/session"
+	"assistant-qisumi/internal/task"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupLLMProfileTest(t *testing.T) (*auth.LLMSettingService, *gorm.DB) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to connect database: %v", err)
+	}
+	if err := db.AutoMigrate(&domain.UserLLMSetting{}, &domain.LLMProfile{}, &domain.LLMAgentAssignment{}); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	defaultCfg := &auth.LLMConfig{BaseURL: "https://default", APIKey: "sk-default", Model: "default-model", AssistantName: "小奇", IsDefault: true}
+	svc := auth.NewLLMSettingService(auth.NewLLMSettingRepository(db), "12345678901234567890123456789012", defaultCfg).
+		WithProfiles(auth.NewLLMProfileRepository(db))
+	return svc, db
+}
+
+func TestLLMProfiles_AssignmentsAndFallback(t *testing.T) {
+	svc, db := setupLLMProfileTest(t)
+	ctx := context.Background()
+
+	cheap, err := svc.CreateProfile(ctx, 1, auth.LLMProfileRequest{Name: "cheap", BaseURL: "https://cheap", APIKey: "sk-cheap", Model: "mini"})
+	if err != nil {
+		t.Fatalf("CreateProfile failed: %v", err)
+	}
+	strong, _ := svc.CreateProfile(ctx, 1, auth.LLMProfileRequest{Name: "strong", BaseURL: "https://strong", APIKey: "sk-strong", Model: "large"})
+
+	if _, err := svc.CreateProfile(ctx, 1, auth.LLMProfileRequest{Name: "cheap", BaseURL: "x", APIKey: "k", Model: "m"}); !errors.Is(err, auth.ErrInvalidLLMProfile) {
+		t.Errorf("duplicate name should be rejected, got %v", err)
+	}
+	if _, err := svc.CreateProfile(ctx, 1, auth.LLMProfileRequest{Name: "nokey", BaseURL: "x", Model: "m"}); !errors.Is(err, auth.ErrInvalidLLMProfile) {
+		t.Errorf("missing api key should be rejected, got %v", err)
+	}
+
+	// API Key 加密保存
+	var stored domain.LLMProfile
+	db.First(&stored, cheap.ID)
+	if stored.APIKeyEnc == "" || stored.APIKeyEnc == "sk-cheap" {
+		t.Errorf("api key should be stored encrypted, got %q", stored.APIKeyEnc)
+	}
+
+	if _, err := svc.SetAssignments(ctx, 1, map[string]uint64{"nobody": cheap.ID}); !errors.Is(err, auth.ErrInvalidLLMProfile) {
+		t.Errorf("unknown agent should be rejected, got %v", err)
+	}
+	if _, err := svc.SetAssignments(ctx, 1, map[string]uint64{"router": 999}); !errors.Is(err, auth.ErrLLMProfileNotFound) {
+		t.Errorf("unknown profile should be rejected, got %v", err)
+	}
+	// 其他用户的配置不能被指派
+	other, _ := svc.CreateProfile(ctx, 2, auth.LLMProfileRequest{Name: "other", BaseURL: "x", APIKey: "k", Model: "m"})
+	if _, err := svc.SetAssignments(ctx, 1, map[string]uint64{"router": other.ID}); !errors.Is(err, auth.ErrLLMProfileNotFound) {
+		t.Errorf("another user's profile should be rejected, got %v", err)
+	}
+
+	if _, err := svc.SetAssignments(ctx, 1, map[string]uint64{"router": cheap.ID, "summarizer": cheap.ID, "planner": strong.ID, "executor": 0}); err != nil {
+		t.Fatalf("SetAssignments failed: %v", err)
+	}
+
+	cases := map[string]string{"router": "mini", "summarizer": "mini", "planner": "large", "executor": "default-model"}
+	for agentName, model := range cases {
+		cfg, err := svc.GetAgentLLMConfig(ctx, 1, agentName)
+		if err != nil || cfg.Model != model {
+			t.Errorf("%s uses %+v (%v), want model %s", agentName, cfg, err, model)
+		}
+	}
+	if cfg, _ := svc.GetAgentLLMConfig(ctx, 1, "planner"); cfg.APIKey != "sk-strong" {
+		t.Errorf("planner api key = %q", cfg.APIKey)
+	}
+
+	// 设置了 fallback_order 的配置按顺序作为备用提供商
+	b2, _ := svc.CreateProfile(ctx, 1, auth.LLMProfileRequest{Name: "backup-2", BaseURL: "https://b2", APIKey: "k2", Model: "b2", FallbackOrder: 2})
+	b1, _ := svc.CreateProfile(ctx, 1, auth.LLMProfileRequest{Name: "backup-1", BaseURL: "https://b1", APIKey: "k1", Model: "b1", FallbackOrder: 1})
+	if cfg, _ := svc.GetAgentLLMConfig(ctx, 1, "executor"); len(cfg.Fallbacks) != 2 || cfg.Fallbacks[0].Model != "b1" || cfg.Fallbacks[1].APIKey != "k2" {
+		t.Errorf("unexpected fallbacks: %+v", cfg.Fallbacks)
+	}
+	svc.DeleteProfile(ctx, 1, b1.ID)
+	svc.DeleteProfile(ctx, 1, b2.ID)
+
+	profiles, _, _ := svc.ListProfiles(ctx, 1)
+	if len(profiles) != 2 || len(profiles[0].Agents) != 2 || !profiles[1].HasAPIKey {
+		t.Errorf("unexpected profile list: %+v", profiles)
+	}
+
+	// 删除配置后相关 agent 回退到默认配置
+	if err := svc.DeleteProfile(ctx, 1, strong.ID); err != nil {
+		t.Fatalf("DeleteProfile failed: %v", err)
+	}
+	if cfg, _ := svc.GetAgentLLMConfig(ctx, 1, "planner"); cfg.Model != "default-model" {
+		t.Errorf("planner should fall back to the default config, got %s", cfg.Model)
+	}
+	if err := svc.DeleteProfile(ctx, 1, other.ID); !errors.Is(err, auth.ErrLLMProfileNotFound) {
+		t.Errorf("deleting another user's profile should fail, got %v", err)
+	}
+}
+
+// recordingAgent 记录收到的 LLM 配置
+type recordingAgent struct {
+	name  string
+	model string
+}
+
+func (a *recordingAgent) Name() string { return a.name }
+func (a *recordingAgent) Handle(req agent.AgentRequest) (*agent.AgentResponse, error) {
+	a.model = req.LLMConfig.Model
+	return &agent.AgentResponse{AssistantMessage: "ok"}, nil
+}
+
+func TestAgentService_UsesAssignedLLMProfile(t *testing.T) {
+	svc, db := setupLLMProfileTest(t)
+	if err := db.AutoMigrate(&domain.Session{}, &domain.Message{}, &domain.Task{}, &domain.TaskStep{},
+		&domain.TaskDependency{}, &domain.UserProfile{}, &domain.TimeEntry{}); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	ctx := context.Background()
+
+	strong, _ := svc.CreateProfile(ctx, 1, auth.LLMProfileRequest{Name: "strong", BaseURL: "https://strong", APIKey: "sk-strong", Model: "large"})
+	if _, err := svc.SetAssignments(ctx, 1, map[string]uint64{"planner": strong.ID}); err != nil {
+		t.Fatalf("SetAssignments failed: %v", err)
+	}
+
+	tk := &domain.Task{UserID: 1, Title: "任务", Status: "todo"}
+	db.Create(tk)
+	sess := &domain.Session{UserID: 1, TaskID: &tk.ID, Type: "task"}
+	db.Create(sess)
+
+	planner := &recordingAgent{name: "planner"}
+	executor := &recordingAgent{name: "executor"}
+	agentSvc := agent.NewService(agent.NewSimpleRouter(), []agent.Agent{planner, executor},
+		task.NewRepository(db), session.NewRepository(db), nil, db, &MockAgentLLMClient{})
+	agentSvc.SetLLMConfigResolver(svc)
+
+	base, _ := svc.GetLLMConfig(ctx, 1)
+	if _, err := agentSvc.HandleUserMessage(ctx, 1, sess.ID, "重新规划一下", *base); err != nil {
+		t.Fatalf("HandleUserMessage failed: %v", err)
+	}
+	if _, err := agentSvc.HandleUserMessage(ctx, 1, sess.ID, "第一步做完了", *base); err != nil {
+		t.Fatalf("HandleUserMessage failed: %v", err)
+	}
+	if planner.model != "large" || executor.model != "default-model" {
+		t.Errorf("planner used %q, executor used %q", planner.model, executor.model)
+	}
+}
+
+func TestLLMProfileHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	svc, _ := setupLLMProfileTest(t)
+
+	router := gin.New()
+	authGroup := router.Group("/api")
+	authGroup.Use(func(c *gin.Context) {
+		c.Set("userID", uint64(1))
+		c.Next()
+	})
+	internalHTTP.NewSettingsHandler(svc).RegisterRoutes(authGroup)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("POST", "/api/settings/llm/profiles", `{"name":"cheap","base_url":"https://cheap","api_key":"sk-cheap","model":"mini"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if bytes.Contains(w.Body.Bytes(), []byte("sk-cheap")) {
+		t.Error("api key must not be returned")
+	}
+	var created domain.LLMProfileView
+	json.Unmarshal(w.Body.Bytes(), &created)
+
+	if w := do("PUT", "/api/settings/llm/profiles/assignments", `{"router":`+jsonUint(created.ID)+`}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do("PUT", "/api/settings/llm/profiles/assignments", `{"bogus":1}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for unknown agent, got %d", w.Code)
+	}
+
+	// 更新时不传 api_key 保留原密钥
+	if w := do("PUT", "/api/settings/llm/profiles/"+jsonUint(created.ID), `{"name":"fast","base_url":"https://cheap","model":"mini-2"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if cfg, _ := svc.GetAgentLLMConfig(context.Background(), 1, "router"); cfg.Model != "mini-2" || cfg.APIKey != "sk-cheap" {
+		t.Errorf("unexpected router config after update: %+v", cfg)
+	}
+
+	w = do("GET", "/api/settings/llm/profiles", "")
+	var list struct {
+		Profiles    []domain.LLMProfileView `json:"profiles"`
+		Assignments map[string]uint64       `json:"assignments"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if len(list.Profiles) != 1 || list.Profiles[0].Name != "fast" || list.Assignments["router"] != created.ID {
+		t.Errorf("unexpected list: %s", w.Body.String())
+	}
+
+	if w := do("DELETE", "/api/settings/llm/profiles/999", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", w.Code)
+	}
+	if w := do("DELETE", "/api/settings/llm/profiles/"+jsonUint(created.ID), ""); w.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", w.Code)
+	}
+}
+
+func jsonUint(v uint64) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -0,0 +1,279 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"assistant-qisumi/internal/agent"
+	"assistant-qisumi/internal/auth"
+	"assistant-qisumi/internal/domain"
+	"assistant-qisumi/internal/llm"
+)
+
+// capturedRequest 记录 fixture 服务器收到的请求
+type capturedRequest struct {
+	Path   string
+	Header http.Header
+	Body   map[string]any
+}
+
+// serveFixture 启动返回 testdata/llm 下录制响应的服务器
+func serveFixture(t *testing.T, fixture string, status int, header map[string]string) (*httptest.Server, *capturedRequest) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "llm", fixture))
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	captured := &capturedRequest{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		captured.Path = r.URL.Path
+		captured.Header = r.Header.Clone()
+		raw, _ := io.ReadAll(r.Body)
+		captured.Body = nil
+		json.Unmarshal(raw, &captured.Body)
+		for k, v := range header {
+			w.Header().Set(k, v)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(data)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, captured
+}
+
+// registeredTools 返回已注册工具的定义
+func registeredTools(names ...string) []llm.Tool {
+	tools, err := agent.NewToolRegistry(nil, nil).Definitions(names)
+	if err != nil {
+		panic(err)
+	}
+	return tools
+}
+
+// toolConversation 一段包含工具调用及结果的对话
+func toolConversation() llm.ChatRequest {
+	return llm.ChatRequest{
+		Messages: []llm.Message{
+			{Role: "system", Content: "你是任务助手"},
+			{Role: "user", Content: "把截止时间改到周五"},
+			{Role: "assistant", ToolCalls: []llm.ToolCall{{
+				ID: "call_1", Type: "function",
+				Function: llm.ToolCallFunc{Name: "get_task", Arguments: `{"task_id":12}`},
+			}}},
+			{Role: "tool", ToolCallID: "call_1", Content: `{"id":12,"title":"写周报"}`},
+		},
+		Tools:       registeredTools("update_task"),
+		ToolChoice:  "auto",
+		Temperature: 0.3,
+		MaxTokens:   1000,
+	}
+}
+
+func TestHTTPClient_AnthropicProtocol(t *testing.T) {
+	srv, got := serveFixture(t, "anthropic_tool_use.json", http.StatusOK, nil)
+	client := llm.NewHTTPClient()
+	cfg := llm.Config{Protocol: llm.ProtocolAnthropic, BaseURL: srv.URL, APIKey: "sk-ant"}
+
+	req := toolConversation()
+	req.Model = "claude-sonnet-4-20250514"
+	resp, err := client.Chat(context.Background(), cfg, req)
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+
+	if got.Path != "/v1/messages" || got.Header.Get("x-api-key") != "sk-ant" || got.Header.Get("anthropic-version") == "" {
+		t.Errorf("unexpected request: path=%s headers=%v", got.Path, got.Header)
+	}
+	if got.Body["system"] != "你是任务助手" || got.Body["max_tokens"] != float64(1000) || got.Body["temperature"] != 0.3 {
+		t.Errorf("unexpected request body: %v", got.Body)
+	}
+	messages := got.Body["messages"].([]any)
+	if len(messages) != 3 {
+		t.Fatalf("expected user/assistant/user turns, got %v", messages)
+	}
+	toolUse := messages[1].(map[string]any)["content"].([]any)[0].(map[string]any)
+	if toolUse["type"] != "tool_use" || toolUse["input"].(map[string]any)["task_id"] != float64(12) {
+		t.Errorf("assistant tool call should be a tool_use block with object input, got %v", toolUse)
+	}
+	toolResult := messages[2].(map[string]any)
+	block := toolResult["content"].([]any)[0].(map[string]any)
+	if toolResult["role"] != "user" || block["type"] != "tool_result" || block["tool_use_id"] != "call_1" {
+		t.Errorf("tool message should be a tool_result block, got %v", toolResult)
+	}
+	tool := got.Body["tools"].([]any)[0].(map[string]any)
+	if tool["name"] != "update_task" || tool["input_schema"] == nil {
+		t.Errorf("unexpected tool definition: %v", tool)
+	}
+
+	choice := resp.Choices[0]
+	if choice.FinishReason != "tool_calls" || choice.Message.Content != "好的，我来更新截止时间。" {
+		t.Errorf("unexpected choice: %+v", choice)
+	}
+	call := choice.Message.ToolCalls[0]
+	var args map[string]any
+	json.Unmarshal([]byte(call.Function.Arguments), &args)
+	if call.ID != "toolu_01A09q90qw90lq917835lq9" || call.Function.Name != "update_task" || args["task_id"] != float64(12) {
+		t.Errorf("unexpected tool call: %+v", call)
+	}
+	if resp.Usage.PromptTokens != 1012 || resp.Usage.CompletionTokens != 96 || resp.Usage.TotalTokens != 1108 {
+		t.Errorf("unexpected usage: %+v", resp.Usage)
+	}
+
+	// 开启思考：按强度换算预算，不设置 temperature
+	thinkReq := llm.ChatRequest{Model: "m", Messages: []llm.Message{{Role: "user", Content: "hi"}}, Temperature: 0.3}
+	cfg.ThinkingType, cfg.ReasoningEffort = "enabled", "high"
+	if _, err := client.Chat(context.Background(), cfg, thinkReq); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	thinking, _ := got.Body["thinking"].(map[string]any)
+	if thinking["type"] != "enabled" || thinking["budget_tokens"] != float64(16384) || got.Body["temperature"] != nil {
+		t.Errorf("unexpected thinking params: %v", got.Body)
+	}
+	if maxTokens := got.Body["max_tokens"].(float64); maxTokens <= 16384 {
+		t.Errorf("max_tokens should exceed the thinking budget, got %v", maxTokens)
+	}
+}
+
+func TestHTTPClient_GeminiProtocol(t *testing.T) {
+	srv, got := serveFixture(t, "gemini_function_call.json", http.StatusOK, nil)
+	client := llm.NewHTTPClient()
+	cfg := llm.Config{Protocol: llm.ProtocolGemini, BaseURL: srv.URL, APIKey: "g-key", ThinkingType: "enabled", ReasoningEffort: "low"}
+
+	req := toolConversation()
+	req.Model = "gemini-2.5-flash"
+	req.ToolChoice = "required"
+	resp, err := client.Chat(context.Background(), cfg, req)
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+
+	if got.Path != "/v1beta/models/gemini-2.5-flash:generateContent" || got.Header.Get("x-goog-api-key") != "g-key" {
+		t.Errorf("unexpected request: path=%s headers=%v", got.Path, got.Header)
+	}
+	system := got.Body["systemInstruction"].(map[string]any)["parts"].([]any)[0].(map[string]any)
+	if system["text"] != "你是任务助手" {
+		t.Errorf("unexpected system instruction: %v", system)
+	}
+	contents := got.Body["contents"].([]any)
+	if len(contents) != 3 || contents[1].(map[string]any)["role"] != "model" {
+		t.Fatalf("unexpected contents: %v", contents)
+	}
+	fnResp := contents[2].(map[string]any)["parts"].([]any)[0].(map[string]any)["functionResponse"].(map[string]any)
+	if fnResp["name"] != "get_task" || fnResp["response"].(map[string]any)["title"] != "写周报" {
+		t.Errorf("tool result should be a functionResponse matched by name, got %v", fnResp)
+	}
+	if mode := got.Body["toolConfig"].(map[string]any)["functionCallingConfig"].(map[string]any)["mode"]; mode != "ANY" {
+		t.Errorf("tool_choice required should map to ANY, got %v", mode)
+	}
+	gen := got.Body["generationConfig"].(map[string]any)
+	if gen["maxOutputTokens"] != float64(1000) || gen["thinkingConfig"].(map[string]any)["thinkingBudget"] != float64(1024) {
+		t.Errorf("unexpected generation config: %v", gen)
+	}
+
+	choice := resp.Choices[0]
+	if choice.FinishReason != "tool_calls" || choice.Message.Content != "我把步骤拆分好了。" {
+		t.Errorf("thought parts should be dropped, got %+v", choice)
+	}
+	if call := choice.Message.ToolCalls[0]; call.ID == "" || call.Function.Name != "add_steps" {
+		t.Errorf("unexpected tool call: %+v", call)
+	}
+	if resp.Usage.PromptTokens != 640 || resp.Usage.CompletionTokens != 178 || resp.Usage.ReasoningTokens != 120 || resp.Usage.TotalTokens != 818 {
+		t.Errorf("unexpected usage: %+v", resp.Usage)
+	}
+}
+
+func TestHTTPClient_OllamaProtocol(t *testing.T) {
+	srv, got := serveFixture(t, "ollama_chat.json", http.StatusOK, nil)
+	client := llm.NewHTTPClient()
+	// 本地 Ollama 不需要 API Key
+	cfg := llm.Config{Protocol: llm.ProtocolOllama, BaseURL: srv.URL, ThinkingType: "disabled"}
+
+	req := toolConversation()
+	req.Model = "qwen3:8b"
+	resp, err := client.Chat(context.Background(), cfg, req)
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+
+	if got.Path != "/api/chat" || got.Header.Get("Authorization") != "" {
+		t.Errorf("unexpected request: path=%s headers=%v", got.Path, got.Header)
+	}
+	if got.Body["stream"] != false || got.Body["think"] != false {
+		t.Errorf("expected non-streaming request with thinking disabled, got %v", got.Body)
+	}
+	messages := got.Body["messages"].([]any)
+	args := messages[2].(map[string]any)["tool_calls"].([]any)[0].(map[string]any)["function"].(map[string]any)["arguments"]
+	if args.(map[string]any)["task_id"] != float64(12) || messages[3].(map[string]any)["tool_name"] != "get_task" {
+		t.Errorf("unexpected tool messages: %v", messages)
+	}
+	if options := got.Body["options"].(map[string]any); options["num_predict"] != float64(1000) || options["temperature"] != 0.3 {
+		t.Errorf("unexpected options: %v", options)
+	}
+
+	choice := resp.Choices[0]
+	call := choice.Message.ToolCalls[0]
+	if choice.FinishReason != "tool_calls" || call.ID == "" || call.Function.Name != "mark_tasks_focus_today" || call.Function.Arguments != `{"task_ids": [3, 5]}` {
+		t.Errorf("unexpected choice: %+v", choice)
+	}
+	if resp.Usage.PromptTokens != 356 || resp.Usage.CompletionTokens != 42 || resp.Usage.TotalTokens != 398 {
+		t.Errorf("unexpected usage: %+v", resp.Usage)
+	}
+}
+
+func TestHTTPClient_ProtocolErrors(t *testing.T) {
+	srv, _ := serveFixture(t, "anthropic_error.json", 529, map[string]string{"Retry-After": "7"})
+	client := llm.NewHTTPClient()
+
+	_, err := client.Chat(context.Background(), llm.Config{Protocol: llm.ProtocolAnthropic, BaseURL: srv.URL, APIKey: "k"}, llm.ChatRequest{Model: "m"})
+	var apiErr *llm.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 529 || apiErr.RetryAfter != 7*time.Second || apiErr.Message != "overloaded_error: Overloaded" {
+		t.Fatalf("expected APIError with Retry-After, got %#v", err)
+	}
+
+	if _, err := client.Chat(context.Background(), llm.Config{Protocol: "soap"}, llm.ChatRequest{}); err == nil {
+		t.Errorf("unknown protocol should fail")
+	}
+}
+
+func TestLLMProfiles_Protocol(t *testing.T) {
+	svc, _ := setupLLMProfileTest(t)
+	ctx := context.Background()
+
+	if _, err := svc.CreateProfile(ctx, 1, auth.LLMProfileRequest{Name: "bad", Protocol: "soap", BaseURL: "https://x", APIKey: "k", Model: "m"}); !errors.Is(err, auth.ErrInvalidLLMProfile) {
+		t.Errorf("unknown protocol should be rejected, got %v", err)
+	}
+	if _, err := svc.CreateProfile(ctx, 1, auth.LLMProfileRequest{Name: "claude", Protocol: "anthropic", BaseURL: "https://api.anthropic.com", Model: "claude"}); !errors.Is(err, auth.ErrInvalidLLMProfile) {
+		t.Errorf("api key should be required for hosted protocols, got %v", err)
+	}
+
+	// Ollama 不需要 API Key
+	local, err := svc.CreateProfile(ctx, 1, auth.LLMProfileRequest{Name: "local", Protocol: "Ollama", BaseURL: "http://localhost:11434", Model: "qwen3:8b"})
+	if err != nil {
+		t.Fatalf("CreateProfile failed: %v", err)
+	}
+	if local.Protocol != domain.LLMProtocolOllama {
+		t.Errorf("protocol should be normalized, got %q", local.Protocol)
+	}
+	if _, err := svc.SetAssignments(ctx, 1, map[string]uint64{"executor": local.ID}); err != nil {
+		t.Fatalf("SetAssignments failed: %v", err)
+	}
+	cfg, err := svc.GetAgentLLMConfig(ctx, 1, "executor")
+	if err != nil || cfg.Protocol != domain.LLMProtocolOllama || cfg.Model != "qwen3:8b" {
+		t.Errorf("agent config should carry the profile protocol, got %+v (%v)", cfg, err)
+	}
+
+	// 未指定协议时为 openai
+	def, _ := svc.GetAgentLLMConfig(ctx, 1, "planner")
+	if def == nil || (def.Protocol != "" && def.Protocol != domain.LLMProtocolOpenAI) {
+		t.Errorf("default config should use the openai protocol, got %+v", def)
+	}
+}
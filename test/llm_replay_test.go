@@ -0,0 +1,123 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"assistant-qisumi/internal/domain"
+	"assistant-qisumi/internal/llm"
+	"assistant-qisumi/internal/task"
+)
+
+// sequenceLLMClient 依次返回预设的回复内容
+type sequenceLLMClient struct {
+	replies []string
+	calls   int
+}
+
+func (m *sequenceLLMClient) Chat(ctx context.Context, cfg llm.Config, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	reply := m.replies[m.calls%len(m.replies)]
+	m.calls++
+	resp := &llm.ChatResponse{Usage: llm.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}}
+	resp.Choices = append(resp.Choices, struct {
+		Message      llm.ChatMessage `json:"message"`
+		FinishReason string          `json:"finish_reason"`
+	}{Message: llm.ChatMessage{Role: "assistant", Content: reply}, FinishReason: "stop"})
+	return resp, nil
+}
+
+func TestRecordingClient_RecordAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	apiKey := "my-secret-provider-key-123"
+	cfg := llm.Config{BaseURL: "https://example.com/v1", APIKey: apiKey, Model: "m"}
+	inner := &sequenceLLMClient{replies: []string{"第一次回复", "第二次回复 key=" + apiKey + " sk-abcdefghijklmnopqrstuvwx"}}
+	recorder := llm.NewRecordingClient(inner, dir)
+	ctx := llm.WithAgent(context.Background(), "executor")
+
+	conversation := func(now string, callID string) llm.ChatRequest {
+		return llm.ChatRequest{
+			Model: "m",
+			Messages: []llm.Message{
+				{Role: "system", Content: "当前时间 now: " + now},
+				{Role: "user", Content: "把截止时间改到  " + now},
+				{Role: "assistant", ToolCalls: []llm.ToolCall{{ID: callID, Type: "function", Function: llm.ToolCallFunc{Name: "update_task", Arguments: `{"task_id": 1, "fields": {"due_at": "` + now + `"}}`}}}},
+				{Role: "tool", ToolCallID: callID, Content: `{"ok":true}`},
+			},
+			Tools: registeredTools("update_task"),
+		}
+	}
+	req := conversation("2025-06-10T09:30:00+08:00", "call_abc")
+	for i := 0; i < 2; i++ {
+		if _, err := recorder.Chat(ctx, cfg, req); err != nil {
+			t.Fatalf("Chat failed: %v", err)
+		}
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 2 {
+		t.Fatalf("expected 2 fixture files, got %v", files)
+	}
+	for _, f := range files {
+		data, _ := os.ReadFile(f)
+		if strings.Contains(string(data), apiKey) || strings.Contains(string(data), "sk-abcdefghij") {
+			t.Errorf("fixture %s leaks a secret", f)
+		}
+		if !strings.HasPrefix(filepath.Base(f), "executor-") {
+			t.Errorf("fixture name should start with the agent name, got %s", f)
+		}
+	}
+
+	replay, err := llm.NewReplayClient(dir)
+	if err != nil {
+		t.Fatalf("NewReplayClient failed: %v", err)
+	}
+	// 时间和工具调用 ID 不同、模型不同，规范化后仍然匹配；重复请求按录制顺序返回
+	other := conversation("2026-01-02T15:04:05Z", "toolu_xyz")
+	want := []string{"第一次回复", "第二次回复", "第二次回复"}
+	for i, w := range want {
+		resp, err := replay.Chat(context.Background(), llm.Config{Model: "other"}, other)
+		if err != nil {
+			t.Fatalf("replay %d failed: %v", i, err)
+		}
+		if got := resp.Choices[0].Message.Content; !strings.HasPrefix(got, w) {
+			t.Errorf("replay %d: expected %q, got %q", i, w, got)
+		}
+	}
+
+	changed := conversation("2025-06-10T09:30:00+08:00", "call_abc")
+	changed.Messages[1].Content = "另一个问题"
+	if _, err := replay.Chat(context.Background(), cfg, changed); !errors.Is(err, llm.ErrFixtureNotFound) || !strings.Contains(err.Error(), "另一个问题") {
+		t.Errorf("expected ErrFixtureNotFound mentioning the user message, got %v", err)
+	}
+}
+
+// TestTaskService_ReplayRecordedFixture 用录制的模型输出回归测试从文本创建任务
+func TestTaskService_ReplayRecordedFixture(t *testing.T) {
+	replay, err := llm.NewReplayClient(filepath.Join("testdata", "llm", "replay"))
+	if err != nil {
+		t.Fatalf("NewReplayClient failed: %v", err)
+	}
+	_, _, _, db := setupTaskTest(t)
+	svc := task.NewService(task.NewRepository(db), replay)
+
+	loc, _ := time.LoadLocation("Asia/Shanghai")
+	ctx := domain.WithLocation(context.Background(), loc)
+	created, err := svc.CreateFromText(ctx, 1, "下周五之前写完季度总结报告，先整理数据再写初稿，最后给老板过目", llm.Config{Model: "test-model"})
+	if err != nil {
+		t.Fatalf("CreateFromText failed: %v", err)
+	}
+	if created.Title != "撰写季度总结报告" || created.Priority != "high" || len(created.Steps) != 3 {
+		t.Fatalf("unexpected task: %+v", created)
+	}
+	if created.DueAt == nil || !created.DueAt.Time.Equal(time.Date(2025, 6, 20, 18, 0, 0, 0, loc)) {
+		t.Errorf("unexpected due_at: %v", created.DueAt)
+	}
+	if created.Steps[1].Title != "撰写报告初稿" || created.Steps[1].EstimateMin == nil || *created.Steps[1].EstimateMin != 180 {
+		t.Errorf("unexpected steps: %+v", created.Steps[1])
+	}
+}
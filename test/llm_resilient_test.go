@@ -0,0 +1,187 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"assistant-qisumi/internal/llm"
+)
+
+// scriptedLLMClient 按 base URL 依次返回预设的错误，错误用完后返回成功
+type scriptedLLMClient struct {
+	mu     sync.Mutex
+	errs   map[string][]error
+	calls  map[string]int
+	models []string
+	block  bool // 阻塞直到 ctx 结束，用于测试单次调用超时
+}
+
+func newScriptedLLMClient(errs map[string][]error) *scriptedLLMClient {
+	return &scriptedLLMClient{errs: errs, calls: map[string]int{}}
+}
+
+func (m *scriptedLLMClient) Chat(ctx context.Context, cfg llm.Config, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	m.mu.Lock()
+	m.calls[cfg.BaseURL]++
+	m.models = append(m.models, req.Model)
+	var err error
+	if queue := m.errs[cfg.BaseURL]; len(queue) > 0 {
+		err, m.errs[cfg.BaseURL] = queue[0], queue[1:]
+	}
+	m.mu.Unlock()
+
+	if m.block {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return (&MockAgentLLMClient{}).Chat(ctx, cfg, req)
+}
+
+func (m *scriptedLLMClient) callCount(baseURL string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.calls[baseURL]
+}
+
+func fastResilientOptions() llm.ResilientOptions {
+	return llm.ResilientOptions{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 50 * time.Millisecond, CallTimeout: time.Second}
+}
+
+func TestResilientClient_RetryAndRetryAfter(t *testing.T) {
+	primary := llm.Config{BaseURL: "https://primary", Model: "m1"}
+	inner := newScriptedLLMClient(map[string][]error{
+		"https://primary": {
+			&llm.APIError{StatusCode: 503, Message: "overloaded"},
+			&llm.APIError{StatusCode: 429, Message: "slow down", RetryAfter: 30 * time.Millisecond},
+		},
+	})
+	client := llm.NewResilientClient(inner, fastResilientOptions())
+
+	start := time.Now()
+	resp, err := client.Chat(context.Background(), primary, llm.ChatRequest{Model: "m1"})
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if resp.Attempts != 3 || resp.Provider != "https://primary" || resp.Model != "m1" {
+		t.Errorf("unexpected response metadata: provider=%s model=%s attempts=%d", resp.Provider, resp.Model, resp.Attempts)
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("Retry-After was not honored, elapsed %v", elapsed)
+	}
+
+	// 4xx（除 429）不重试
+	inner = newScriptedLLMClient(map[string][]error{"https://primary": {&llm.APIError{StatusCode: 400, Message: "bad request"}}})
+	client = llm.NewResilientClient(inner, fastResilientOptions())
+	_, err = client.Chat(context.Background(), primary, llm.ChatRequest{Model: "m1"})
+	if err == nil || inner.callCount("https://primary") != 1 || errors.Is(err, llm.ErrUnavailable) {
+		t.Errorf("400 should fail without retry and not be reported as unavailable: calls=%d err=%v", inner.callCount("https://primary"), err)
+	}
+}
+
+func TestResilientClient_FallbackProviders(t *testing.T) {
+	cfg := llm.Config{
+		BaseURL: "https://primary", Model: "m1",
+		Fallbacks: []llm.Config{
+			{BaseURL: "https://primary", Model: "m1"}, // 与主配置重复，会被忽略
+			{BaseURL: "https://limited", Model: "m2"},
+			{BaseURL: "https://backup", Model: "m3"},
+		},
+	}
+	inner := newScriptedLLMClient(map[string][]error{
+		"https://primary": {&llm.APIError{StatusCode: 500}, &llm.APIError{StatusCode: 500}, &llm.APIError{StatusCode: 500}},
+		// Retry-After 超过上限，不等待，直接切换
+		"https://limited": {&llm.APIError{StatusCode: 429, RetryAfter: time.Hour}},
+	})
+	client := llm.NewResilientClient(inner, fastResilientOptions())
+
+	resp, err := client.Chat(context.Background(), cfg, llm.ChatRequest{Model: "m1"})
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if resp.Provider != "https://backup" || resp.Model != "m3" || resp.Attempts != 5 {
+		t.Errorf("unexpected response metadata: provider=%s model=%s attempts=%d", resp.Provider, resp.Model, resp.Attempts)
+	}
+	if inner.callCount("https://primary") != 3 || inner.callCount("https://limited") != 1 {
+		t.Errorf("unexpected calls: %+v", inner.calls)
+	}
+	if got := inner.models[len(inner.models)-1]; got != "m3" {
+		t.Errorf("fallback should use its own model, got %s", got)
+	}
+}
+
+func TestResilientClient_RequestErrorSkipsFallback(t *testing.T) {
+	cfg := llm.Config{
+		BaseURL: "https://primary", Model: "m1",
+		Fallbacks: []llm.Config{{BaseURL: "https://backup", Model: "m2"}},
+	}
+	inner := newScriptedLLMClient(map[string][]error{
+		"https://primary": {&llm.APIError{StatusCode: 400, Message: "response_format is not supported"}},
+	})
+	client := llm.NewResilientClient(inner, fastResilientOptions())
+
+	_, err := client.Chat(context.Background(), cfg, llm.ChatRequest{Model: "m1"})
+	if !llm.IsResponseFormatRejected(err) {
+		t.Errorf("expected the 400 to reach the caller, got %v", err)
+	}
+	if inner.callCount("https://primary") != 1 || inner.callCount("https://backup") != 0 {
+		t.Errorf("4xx should neither retry nor fall back: %+v", inner.calls)
+	}
+}
+
+func TestResilientClient_CircuitBreakerAndTimeout(t *testing.T) {
+	cfg := llm.Config{BaseURL: "https://flaky", Model: "m1"}
+	failures := make([]error, 10)
+	for i := range failures {
+		failures[i] = &llm.APIError{StatusCode: 502}
+	}
+	inner := newScriptedLLMClient(map[string][]error{"https://flaky": failures})
+	opts := fastResilientOptions()
+	opts.MaxAttempts = 1
+	opts.FailureThreshold = 2
+	opts.Cooldown = 30 * time.Millisecond
+	client := llm.NewResilientClient(inner, opts)
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.Chat(context.Background(), cfg, llm.ChatRequest{}); !errors.Is(err, llm.ErrUnavailable) {
+			t.Fatalf("call %d: expected ErrUnavailable, got %v", i, err)
+		}
+	}
+	// 熔断打开：不再调用提供商
+	_, err := client.Chat(context.Background(), cfg, llm.ChatRequest{})
+	if !errors.Is(err, llm.ErrCircuitOpen) || !errors.Is(err, llm.ErrUnavailable) || inner.callCount("https://flaky") != 2 {
+		t.Fatalf("expected an open circuit, got %v after %d calls", err, inner.callCount("https://flaky"))
+	}
+
+	// 冷却后放行一次试探调用
+	time.Sleep(40 * time.Millisecond)
+	client.Chat(context.Background(), cfg, llm.ChatRequest{})
+	if inner.callCount("https://flaky") != 3 {
+		t.Errorf("expected a probe call after cooldown, got %d calls", inner.callCount("https://flaky"))
+	}
+
+	// 单次调用超时会重试，全部超时后报告不可用
+	slow := newScriptedLLMClient(nil)
+	slow.block = true
+	opts = fastResilientOptions()
+	opts.MaxAttempts = 2
+	opts.CallTimeout = 20 * time.Millisecond
+	_, err = llm.NewResilientClient(slow, opts).Chat(context.Background(), llm.Config{BaseURL: "https://slow"}, llm.ChatRequest{})
+	if !errors.Is(err, llm.ErrUnavailable) || slow.callCount("https://slow") != 2 {
+		t.Errorf("expected 2 timed-out attempts, got %d: %v", slow.callCount("https://slow"), err)
+	}
+
+	// 调用方取消时立即返回，不重试
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	slow = newScriptedLLMClient(nil)
+	slow.block = true
+	if _, err := llm.NewResilientClient(slow, opts).Chat(ctx, llm.Config{BaseURL: "https://slow"}, llm.ChatRequest{}); !errors.Is(err, context.Canceled) || slow.callCount("https://slow") != 1 {
+		t.Errorf("cancelled call should not be retried: calls=%d err=%v", slow.callCount("https://slow"), err)
+	}
+}
@@ -0,0 +1,359 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"assistant-qisumi/internal/agent"
+	"assistant-qisumi/internal/dependency"
+	"assistant-qisumi/internal/domain"
+	internalHTTP "assistant-qisumi/internal/http"
+	"assistant-qisumi/internal/llm"
+	"assistant-qisumi/internal/memory"
+	"assistant-qisumi/internal/session"
+	"assistant-qisumi/internal/task"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupMemoryDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "memories.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&domain.Memory{}); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	return db
+}
+
+func memoryContents(memories []memory.Memory) string {
+	var parts []string
+	for _, m := range memories {
+		parts = append(parts, m.Content)
+	}
+	return strings.Join(parts, "|")
+}
+
+func TestMemoryService_CRUDAndValidation(t *testing.T) {
+	svc := memory.NewService(setupMemoryDB(t), nil)
+	ctx := context.Background()
+
+	m, err := svc.Create(ctx, 1, memory.Input{Content: "  用户周日不工作 ", Category: memory.CategorySchedule})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if m.Content != "用户周日不工作" || m.Source != memory.SourceManual {
+		t.Errorf("unexpected memory: %+v", m)
+	}
+	plain, err := svc.Create(ctx, 1, memory.Input{Content: "用户在上海工作"})
+	if err != nil || plain.Category != memory.CategoryFact {
+		t.Fatalf("empty category should default to fact, got %+v, %v", plain, err)
+	}
+
+	for _, in := range []memory.Input{
+		{Content: "   "},
+		{Content: strings.Repeat("长", memory.MaxContentLength+1)},
+		{Content: "用户喜欢猫", Category: "hobby"},
+	} {
+		if _, err := svc.Create(ctx, 1, in); !errors.Is(err, memory.ErrInvalidMemory) {
+			t.Errorf("Create(%q, %q): expected ErrInvalidMemory, got %v", in.Content, in.Category, err)
+		}
+	}
+
+	if _, err := svc.Update(ctx, 2, m.ID, memory.Input{Content: "别人改的"}); !errors.Is(err, memory.ErrNotFound) {
+		t.Errorf("updating another user's memory: expected ErrNotFound, got %v", err)
+	}
+	if _, err := svc.Update(ctx, 1, m.ID, memory.Input{Content: "用户周六周日都不工作", Category: memory.CategorySchedule}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if err := svc.Delete(ctx, 2, plain.ID); !errors.Is(err, memory.ErrNotFound) {
+		t.Errorf("deleting another user's memory: expected ErrNotFound, got %v", err)
+	}
+	if err := svc.Delete(ctx, 1, plain.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	list, _ := svc.List(ctx, 1)
+	if got := memoryContents(list); got != "用户周六周日都不工作" {
+		t.Errorf("unexpected memories after update and delete: %q", got)
+	}
+	if err := svc.DeleteAll(ctx, 1); err != nil {
+		t.Fatalf("DeleteAll failed: %v", err)
+	}
+	if list, _ := svc.List(ctx, 1); len(list) != 0 {
+		t.Errorf("expected no memories after DeleteAll, got %d", len(list))
+	}
+}
+
+func TestMemoryService_RelevantPrefersSharedTerms(t *testing.T) {
+	svc := memory.NewService(setupMemoryDB(t), nil)
+	ctx := context.Background()
+	for _, c := range []string{"用户的经理是老李", "用户周日不工作", "用户用 Notion 记笔记", "用户喜欢上午写代码"} {
+		if _, err := svc.Create(ctx, 1, memory.Input{Content: c}); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+
+	// 记忆不超过上限时全部返回
+	all, _ := svc.Relevant(ctx, 1, "随便聊聊", 10)
+	if len(all) != 4 {
+		t.Fatalf("expected all memories under the limit, got %d", len(all))
+	}
+
+	got, err := svc.Relevant(ctx, 1, "这周日要不要安排加班？notion 里记一下", 2)
+	if err != nil {
+		t.Fatalf("Relevant failed: %v", err)
+	}
+	if memoryContents(got) != "用户周日不工作|用户用 Notion 记笔记" && memoryContents(got) != "用户用 Notion 记笔记|用户周日不工作" {
+		t.Errorf("expected the Sunday and Notion memories, got %q", memoryContents(got))
+	}
+
+	// 没有共有词时取最近更新的
+	recent, _ := svc.Relevant(ctx, 1, "hello", 1)
+	if memoryContents(recent) != "用户喜欢上午写代码" {
+		t.Errorf("expected the most recent memory, got %q", memoryContents(recent))
+	}
+}
+
+func TestMemoryService_ExtractDedupesAndReplaces(t *testing.T) {
+	db := setupMemoryDB(t)
+	ctx := context.Background()
+	old := &memory.Memory{UserID: 1, Content: "用户的经理是老李", Category: memory.CategoryPerson, Source: memory.SourceExtracted}
+	if err := db.Create(old).Error; err != nil {
+		t.Fatalf("failed to create memory: %v", err)
+	}
+	// 用户手动记录的记忆不能被提取结果替换
+	manual, err := memory.NewService(db, nil).Create(ctx, 1, memory.Input{Content: "用户在上海工作", Category: memory.CategoryFact})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	client := &scriptedToolLLMClient{reply: "```json\n" + `{"memories":[
+		{"content":"用户的经理是老王","category":"person","replaces":` + jsonID(old.ID) + `},
+		{"content":"用户在北京工作","category":"fact","replaces":` + jsonID(manual.ID) + `},
+		{"content":"用户周日不工作","category":"schedule","replaces":null},
+		{"content":"用户周日不工作","category":"schedule","replaces":null},
+		{"content":"","category":"fact","replaces":null},
+		{"content":"用户养了一只猫","category":"hobby","replaces":null}
+	]}` + "\n```"}
+	svc := memory.NewService(db, client)
+	sessionID := uint64(7)
+
+	// 太短的输入不调用模型
+	if saved, err := svc.Extract(ctx, 1, &sessionID, llm.Config{Model: "m"}, "好的", "好"); err != nil || saved != nil || len(client.requests) != 0 {
+		t.Fatalf("short input should be skipped, got %v, %v, %d requests", saved, err, len(client.requests))
+	}
+
+	saved, err := svc.Extract(ctx, 1, &sessionID, llm.Config{Model: "m"}, "我换经理了，现在是老王。另外周日别给我排任务", "好的，记住了")
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if len(saved) != 3 {
+		t.Fatalf("expected one replaced and two new memories, got %+v", saved)
+	}
+	prompt := client.requests[0].Messages[1].Content
+	if !strings.Contains(prompt, "#"+jsonID(old.ID)+" [person] 用户的经理是老李") || !strings.Contains(prompt, "周日别给我排任务") {
+		t.Errorf("prompt should list existing memories and the turn, got %q", prompt)
+	}
+	if !strings.Contains(prompt, "#"+jsonID(manual.ID)+" [fact] (用户手动记录，不能替换) 用户在上海工作") {
+		t.Errorf("prompt should mark manual memories as not replaceable, got %q", prompt)
+	}
+
+	list, _ := svc.List(ctx, 1)
+	if len(list) != 4 {
+		t.Fatalf("expected four memories, got %q", memoryContents(list))
+	}
+	for _, m := range list {
+		if m.ID == manual.ID {
+			if m.Content != "用户在上海工作" || m.Source != memory.SourceManual {
+				t.Errorf("manual memory should not be replaced, got %+v", m)
+			}
+			continue
+		}
+		if m.Source != memory.SourceExtracted || m.SessionID == nil || *m.SessionID != sessionID {
+			t.Errorf("extracted memory should record its source session, got %+v", m)
+		}
+		if m.ID == old.ID && m.Content != "用户的经理是老王" {
+			t.Errorf("replaced memory should be updated in place, got %+v", m)
+		}
+	}
+
+	// 模型重复已有内容时不再保存
+	client.requests = nil
+	client.reply = `{"memories":[{"content":"用户周日不工作","category":"schedule","replaces":null}]}`
+	if saved, err := svc.Extract(ctx, 1, &sessionID, llm.Config{Model: "m"}, "再提醒一次，周日不工作", "好的"); err != nil || len(saved) != 0 {
+		t.Errorf("duplicate memory should be ignored, got %+v, %v", saved, err)
+	}
+}
+
+// beforeChatLLMClient 在返回回复前执行 before，模拟后台调用模型期间发生的修改
+type beforeChatLLMClient struct {
+	llm.Client
+	before func()
+}
+
+func (m *beforeChatLLMClient) Chat(ctx context.Context, cfg llm.Config, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	m.before()
+	return m.Client.Chat(ctx, cfg, req)
+}
+
+func TestMemoryService_ExtractKeepsUserChangesMadeDuringExtraction(t *testing.T) {
+	db := setupMemoryDB(t)
+	ctx := context.Background()
+	edited := &memory.Memory{UserID: 1, Content: "用户的经理是老李", Category: memory.CategoryPerson, Source: memory.SourceExtracted}
+	deleted := &memory.Memory{UserID: 1, Content: "用户住在浦东", Category: memory.CategoryFact, Source: memory.SourceExtracted}
+	for _, m := range []*memory.Memory{edited, deleted} {
+		if err := db.Create(m).Error; err != nil {
+			t.Fatalf("failed to create memory: %v", err)
+		}
+	}
+
+	scripted := &scriptedToolLLMClient{reply: `{"memories":[
+		{"content":"用户的经理是老王","category":"person","replaces":` + jsonID(edited.ID) + `},
+		{"content":"用户住在徐汇","category":"fact","replaces":` + jsonID(deleted.ID) + `}
+	]}`}
+	svc := memory.NewService(db, nil)
+	// 模型还在提取时，用户手动修改了一条记忆、删除了另一条
+	client := &beforeChatLLMClient{Client: scripted, before: func() {
+		if _, err := svc.Update(ctx, 1, edited.ID, memory.Input{Content: "用户的经理是老张", Category: memory.CategoryPerson}); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		if err := svc.Delete(ctx, 1, deleted.ID); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+	}}
+	sessionID := uint64(7)
+	saved, err := memory.NewService(db, client).Extract(ctx, 1, &sessionID, llm.Config{Model: "m"}, "我换经理了，现在是老王。另外我搬到徐汇了", "好的，记住了")
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if len(saved) != 0 {
+		t.Errorf("memories changed by the user should not be saved, got %+v", saved)
+	}
+	list, _ := svc.List(ctx, 1)
+	if len(list) != 1 || list[0].ID != edited.ID || list[0].Content != "用户的经理是老张" || list[0].Source != memory.SourceManual {
+		t.Errorf("user's edit should be kept and the deleted memory should stay deleted, got %+v", list)
+	}
+}
+
+func TestAgentService_InjectsAndExtractsMemories(t *testing.T) {
+	db, _, _ := setupReadToolsDB(t)
+	if err := db.AutoMigrate(&domain.UserProfile{}, &domain.DailyPlan{}, &domain.DailyPlanItem{}, &domain.Memory{}); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	ctx := context.Background()
+	taskRepo, sessionRepo := task.NewRepository(db), session.NewRepository(db)
+	sess, err := sessionRepo.GetGlobalSessionOrCreate(ctx, 1)
+	if err != nil {
+		t.Fatalf("failed to create global session: %v", err)
+	}
+
+	extractor := &scriptedToolLLMClient{reply: `{"memories":[{"content":"用户每周三下午有组会","category":"schedule","replaces":null}]}`}
+	memories := memory.NewService(db, extractor)
+	if _, err := memories.Create(ctx, 1, memory.Input{Content: "用户周日不工作", Category: memory.CategorySchedule}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := memories.Create(ctx, 2, memory.Input{Content: "别人的记忆"}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	client := &scriptedToolLLMClient{reply: "好的，周三下午不安排任务"}
+	handler := agent.NewChatCompletionsHandler(client, agent.NewToolRegistry(taskRepo, sessionRepo))
+	svc := agent.NewService(agent.NewSimpleRouter(), agent.NewDefaultAgents(client, handler), taskRepo, sessionRepo,
+		dependency.NewService(db, taskRepo, sessionRepo), db, client)
+	svc.SetMemory(memories)
+
+	if _, err := svc.HandleUserMessage(ctx, 1, sess.ID, "我每周三下午有组会，那段时间别安排任务", llm.Config{Model: "m"}); err != nil {
+		t.Fatalf("HandleUserMessage failed: %v", err)
+	}
+	svc.Wait()
+
+	var memoryMsg string
+	for _, m := range client.requests[0].Messages {
+		if m.Role == "system" && strings.HasPrefix(m.Content, "关于用户的长期记忆") {
+			memoryMsg = m.Content
+		}
+	}
+	if !strings.Contains(memoryMsg, "- [作息] 用户周日不工作") || strings.Contains(memoryMsg, "别人的记忆") {
+		t.Errorf("prompt should include only the user's memories, got %q", memoryMsg)
+	}
+
+	if len(extractor.requests) != 1 || !strings.Contains(extractor.requests[0].Messages[1].Content, "好的，周三下午不安排任务") {
+		t.Fatalf("extraction should see the finished turn, got %+v", extractor.requests)
+	}
+	list, _ := memories.List(ctx, 1)
+	if !strings.Contains(memoryContents(list), "用户每周三下午有组会") {
+		t.Errorf("expected the extracted memory to be saved, got %q", memoryContents(list))
+	}
+}
+
+func TestMemoryHandler_CRUD(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupMemoryDB(t)
+	svc := memory.NewService(db, nil)
+	others, _ := svc.Create(context.Background(), 2, memory.Input{Content: "别人的记忆"})
+
+	router := gin.New()
+	group := router.Group("/api")
+	group.Use(func(c *gin.Context) {
+		c.Set("userID", uint64(1))
+		c.Next()
+	})
+	internalHTTP.NewMemoryHandler(svc).RegisterRoutes(group)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("POST", "/api/memories", `{"content":"用户喜欢上午写代码","category":"preference"}`)
+	var created memory.Memory
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &created) != nil || created.ID == 0 {
+		t.Fatalf("create: %d %s", w.Code, w.Body.String())
+	}
+	if w := do("POST", "/api/memories", `{"content":"x","category":"hobby"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid category: expected 400, got %d", w.Code)
+	}
+	if w := do("PUT", "/api/memories/"+jsonID(created.ID), `{"content":"用户喜欢上午写代码、下午开会","category":"preference"}`); w.Code != http.StatusOK {
+		t.Fatalf("update: %d %s", w.Code, w.Body.String())
+	}
+	if w := do("PUT", "/api/memories/"+jsonID(others.ID), `{"content":"改别人的"}`); w.Code != http.StatusNotFound {
+		t.Errorf("update another user's memory: expected 404, got %d", w.Code)
+	}
+
+	w = do("GET", "/api/memories", "")
+	var list struct {
+		Memories []memory.Memory `json:"memories"`
+	}
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &list) != nil || memoryContents(list.Memories) != "用户喜欢上午写代码、下午开会" {
+		t.Fatalf("list: %d %s", w.Code, w.Body.String())
+	}
+
+	if w := do("DELETE", "/api/memories/"+jsonID(others.ID), ""); w.Code != http.StatusNotFound {
+		t.Errorf("delete another user's memory: expected 404, got %d", w.Code)
+	}
+	if w := do("DELETE", "/api/memories/"+jsonID(created.ID), ""); w.Code != http.StatusOK {
+		t.Fatalf("delete: %d %s", w.Code, w.Body.String())
+	}
+	do("POST", "/api/memories", `{"content":"用户在上海工作"}`)
+	if w := do("DELETE", "/api/memories", ""); w.Code != http.StatusOK {
+		t.Fatalf("delete all: %d %s", w.Code, w.Body.String())
+	}
+	if remaining, _ := svc.List(context.Background(), 1); len(remaining) != 0 {
+		t.Errorf("expected no memories left, got %d", len(remaining))
+	}
+	if theirs, _ := svc.List(context.Background(), 2); len(theirs) != 1 {
+		t.Errorf("other users' memories should be kept, got %d", len(theirs))
+	}
+}
@@ -0,0 +1,144 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"assistant-qisumi/internal/agent"
+	"assistant-qisumi/internal/domain"
+	"assistant-qisumi/internal/llm"
+	"assistant-qisumi/internal/nldate"
+	"assistant-qisumi/internal/task"
+)
+
+func TestNLDate_Parse(t *testing.T) {
+	cst := time.FixedZone("CST", 8*3600)
+	// 2025-12-10 周三 14:30
+	ref := time.Date(2025, 12, 10, 14, 30, 0, 0, cst)
+	at := func(m time.Month, d, h, min int) time.Time { return time.Date(2025, m, d, h, min, 0, 0, cst) }
+
+	cases := []struct {
+		in       string
+		want     time.Time
+		hasClock bool
+	}{
+		{"明天下午三点", at(12, 11, 15, 0), true},
+		{"下周五前", at(12, 19, 0, 0), false},
+		{"周五", at(12, 12, 0, 0), false},
+		{"周一", at(12, 15, 0, 0), false},
+		{"今晚8点", at(12, 10, 20, 0), true},
+		{"后天上午10点半", at(12, 12, 10, 30), true},
+		{"3天后", at(12, 13, 0, 0), false},
+		{"2小时后", at(12, 10, 16, 30), true},
+		{"半小时后", at(12, 10, 15, 0), true},
+		{"12月25日", at(12, 25, 0, 0), false},
+		{"下个月5号", time.Date(2026, 1, 5, 0, 0, 0, 0, cst), false},
+		{"月底前", at(12, 31, 0, 0), false},
+		{"二〇二六年一月三日", time.Date(2026, 1, 3, 0, 0, 0, 0, cst), false},
+		{"3点", at(12, 10, 15, 0), true},
+		{"中午1点", at(12, 11, 13, 0), true},
+		{"in 2 days", at(12, 12, 0, 0), false},
+		{"end of month", at(12, 31, 0, 0), false},
+		{"next friday 3pm", at(12, 19, 15, 0), true},
+		{"tomorrow at 9", at(12, 11, 9, 0), true},
+		{"by Dec 20", at(12, 20, 0, 0), false},
+		{"this weekend", at(12, 13, 0, 0), false},
+		{"2025-12-20 18:00", at(12, 20, 18, 0), true},
+	}
+	for _, c := range cases {
+		r, err := nldate.Parse(c.in, ref)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", c.in, err)
+			continue
+		}
+		if !r.Time.Equal(c.want) || r.HasClock != c.hasClock {
+			t.Errorf("Parse(%q) = %v (clock=%v), want %v (clock=%v)", c.in, r.Time, r.HasClock, c.want, c.hasClock)
+		}
+	}
+
+	for _, in := range []string{"", "随便什么时候", "2月30日", "blah tomorrow"} {
+		if _, err := nldate.Parse(in, ref); !errors.Is(err, nldate.ErrUnrecognized) {
+			t.Errorf("Parse(%q) should fail, got %v", in, err)
+		}
+	}
+
+	deadline, err := nldate.ParseDeadline("下周五前", ref)
+	if err != nil || !deadline.Equal(at(12, 19, 23, 59)) {
+		t.Errorf("ParseDeadline = %v, %v", deadline, err)
+	}
+}
+
+func TestNLDate_MidnightAndOverflow(t *testing.T) {
+	cst := time.FixedZone("CST", 8*3600)
+	// 2025-12-10 周三 10:00
+	ref := time.Date(2025, 12, 10, 10, 0, 0, 0, cst)
+	at := func(m time.Month, d, h, min int) time.Time { return time.Date(2025, m, d, h, min, 0, 0, cst) }
+
+	cases := []struct {
+		in   string
+		want time.Time
+	}{
+		{"0点", at(12, 11, 0, 0)},
+		{"0点半", at(12, 11, 0, 30)},
+		{"明天0点", at(12, 11, 0, 0)},
+		{"晚上0点", at(12, 11, 0, 0)},
+		{"今晚12点", at(12, 11, 0, 0)},
+		{"晚上12点", at(12, 11, 0, 0)},
+		{"夜里12点", at(12, 11, 0, 0)},
+		{"明晚12点", at(12, 12, 0, 0)},
+		{"中午12点", at(12, 10, 12, 0)},
+		{"下午12点", at(12, 10, 12, 0)},
+		{"12点", at(12, 10, 12, 0)},
+		{"9点", at(12, 10, 21, 0)},
+	}
+	for _, c := range cases {
+		r, err := nldate.Parse(c.in, ref)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", c.in, err)
+			continue
+		}
+		if !r.Time.Equal(c.want) || !r.HasClock {
+			t.Errorf("Parse(%q) = %v (clock=%v), want %v", c.in, r.Time, r.HasClock, c.want)
+		}
+	}
+
+	for _, in := range []string{"in 999999999999 days", "999999999999小时后", "in 99999999999999999999 minutes", "5000个月后"} {
+		if r, err := nldate.Parse(in, ref); !errors.Is(err, nldate.ErrUnrecognized) {
+			t.Errorf("Parse(%q) should fail, got %v, %v", in, r, err)
+		}
+	}
+	if r, err := nldate.Parse("in 36000 days", ref); err != nil || r.Time.Year() != 2124 {
+		t.Errorf("Parse(in 36000 days) = %v, %v", r, err)
+	}
+}
+
+// 自然语言时间在工具调用校验时按本轮的 Now 解析，仓库只接受解析后的时间
+func TestNLDate_ToolArgumentsReachRepository(t *testing.T) {
+	db := setupProfileTestDB(t)
+	tk := &domain.Task{UserID: 1, Title: "任务", Status: "todo"}
+	db.Create(tk)
+
+	req := agent.AgentRequest{Now: time.Date(2025, 12, 8, 10, 0, 0, 0, time.UTC)}
+	patches, err := agent.NewToolRegistry(nil, nil).TaskPatches(req, llm.ToolCall{Function: llm.ToolCallFunc{
+		Name: "update_task", Arguments: `{"task_id":1,"fields":{"due_at":"明天"}}`,
+	}})
+	if err != nil {
+		t.Fatalf("TaskPatches failed: %v", err)
+	}
+	ctx := domain.WithLocation(context.Background(), time.UTC)
+	if err := task.NewRepository(db).ApplyUpdateTaskFields(ctx, 1, tk.ID, patches[0].UpdateTask.Fields); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	var stored domain.Task
+	db.First(&stored, tk.ID)
+	if want := time.Date(2025, 12, 9, 23, 59, 0, 0, time.UTC); !stored.DueAt.ToTime().Equal(want) {
+		t.Errorf("due_at = %v, want %v", stored.DueAt.ToTime().UTC(), want)
+	}
+
+	bad := "某个时候"
+	if err := task.NewRepository(db).ApplyUpdateTaskFields(ctx, 1, tk.ID, task.UpdateTaskFields{DueAt: &bad}); err == nil {
+		t.Error("expected an error for an unparseable due_at")
+	}
+}
@@ -0,0 +1,179 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"assistant-qisumi/internal/domain"
+	internalHTTP "assistant-qisumi/internal/http"
+	"assistant-qisumi/internal/profile"
+	"assistant-qisumi/internal/scheduler"
+	"assistant-qisumi/internal/task"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupProfileTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to connect database: %v", err)
+	}
+	if err := db.AutoMigrate(&domain.UserProfile{}, &domain.Task{}, &domain.TaskStep{}, &domain.TimeEntry{}); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	return db
+}
+
+func TestFlexibleTime_UserLocation(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+
+	ft, err := domain.ParseFlexibleTimeIn("2025-12-08T20:00:00", shanghai)
+	if err != nil {
+		t.Fatalf("ParseFlexibleTimeIn failed: %v", err)
+	}
+	if want := time.Date(2025, 12, 8, 12, 0, 0, 0, time.UTC); !ft.ToTime().Equal(want) {
+		t.Errorf("zone-less time = %v, want %v", ft.ToTime().UTC(), want)
+	}
+	// 带时区的时间不受用户时区影响
+	ft, _ = domain.ParseFlexibleTimeIn("2025-12-08T20:00:00Z", shanghai)
+	if want := time.Date(2025, 12, 8, 20, 0, 0, 0, time.UTC); !ft.ToTime().Equal(want) {
+		t.Errorf("zoned time = %v, want %v", ft.ToTime().UTC(), want)
+	}
+
+	// JSON 解析出的不带时区时间可以事后按用户时区理解
+	var out domain.TaskCreationOutput
+	if err := json.Unmarshal([]byte(`{"title":"x","due_at":"2025-12-08 20:00"}`), &out); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	out.DueAt.AssumeLocation(shanghai)
+	if want := time.Date(2025, 12, 8, 12, 0, 0, 0, time.UTC); !out.DueAt.ToTime().Equal(want) {
+		t.Errorf("due_at = %v, want %v", out.DueAt.ToTime().UTC(), want)
+	}
+
+	// 更新步骤时按 context 中的用户时区解析
+	db := setupProfileTestDB(t)
+	tk := &domain.Task{UserID: 1, Title: "任务", Status: "todo", Steps: []domain.TaskStep{{Title: "步骤", Status: "todo"}}}
+	db.Create(tk)
+	ctx := domain.WithLocation(context.Background(), shanghai)
+	start := "2025-12-08T20:00:00"
+	if err := task.NewRepository(db).ApplyUpdateStepFields(ctx, 1, tk.ID, tk.Steps[0].ID, task.UpdateStepFields{PlannedStart: &start}); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	var step domain.TaskStep
+	db.First(&step, tk.Steps[0].ID)
+	if want := time.Date(2025, 12, 8, 12, 0, 0, 0, time.UTC); !step.PlannedStart.ToTime().Equal(want) {
+		t.Errorf("planned_start = %v, want %v", step.PlannedStart.ToTime().UTC(), want)
+	}
+}
+
+func TestProfileService_UpdateAndWorkingHours(t *testing.T) {
+	db := setupProfileTestDB(t)
+	svc := profile.NewService(profile.NewRepository(db))
+	ctx := context.Background()
+
+	p, err := svc.Get(ctx, 1)
+	if err != nil || p.WorkStart != "09:00" || len(p.WorkDays) != 5 {
+		t.Fatalf("expected default profile, got %+v, %v", p, err)
+	}
+
+	bad := "Mars/Olympus"
+	if _, err := svc.Update(ctx, 1, profile.UpdateInput{Timezone: &bad}); err == nil {
+		t.Error("expected error for unknown timezone")
+	}
+	late, early := "20:00", "08:00"
+	if _, err := svc.Update(ctx, 1, profile.UpdateInput{WorkStart: &late, WorkEnd: &early}); err == nil {
+		t.Error("expected error when work end is before work start")
+	}
+
+	tz, start, end := "Asia/Shanghai", "10:00", "19:00"
+	if _, err := svc.Update(ctx, 1, profile.UpdateInput{
+		Timezone:  &tz,
+		WorkStart: &start,
+		WorkEnd:   &end,
+		WorkDays:  []int{6, 1, 2, 3, 4, 5, 1},
+		DaysOff:   []string{"2025-12-08"},
+	}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	p, _ = svc.Get(ctx, 1)
+	if p.Timezone != tz || len(p.WorkDays) != 6 || p.WorkDays[5] != 6 || len(p.DaysOff) != 1 {
+		t.Fatalf("unexpected stored profile: %+v", p)
+	}
+
+	// 部分更新保留其他字段
+	end = "18:30"
+	p, _ = svc.Update(ctx, 1, profile.UpdateInput{WorkEnd: &end})
+	if p.WorkStart != "10:00" || p.WorkEnd != "18:30" || p.Timezone != tz {
+		t.Errorf("partial update lost fields: %+v", p)
+	}
+
+	// 休息日和时区会被排期使用：周一请假，任务从周二 10:00（上海）开始
+	hours := profile.WorkingHours(p)
+	loc := profile.Location(p)
+	res, err := scheduler.Schedule(scheduler.Input{
+		Tasks: []domain.Task{{ID: 1, Title: "任务", Status: "todo", Steps: []domain.TaskStep{schedStep(1, 0, 60, "todo")}}},
+		Hours: hours,
+		Start: time.Date(2025, 12, 8, 0, 0, 0, 0, loc),
+	})
+	if err != nil {
+		t.Fatalf("Schedule failed: %v", err)
+	}
+	if want := time.Date(2025, 12, 9, 10, 0, 0, 0, loc); len(res.Items) != 1 || !res.Items[0].Start.Equal(want) {
+		t.Errorf("expected the step to start at %v, got %+v", want, res.Items)
+	}
+}
+
+func TestProfileHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupProfileTestDB(t)
+	svc := profile.NewService(profile.NewRepository(db))
+
+	router := gin.New()
+	router.ContextWithFallback = true
+	authGroup := router.Group("/api")
+	authGroup.Use(func(c *gin.Context) {
+		c.Set("userID", uint64(1))
+		c.Next()
+	}, internalHTTP.ProfileMiddleware(svc))
+	internalHTTP.NewProfileHandler(svc).RegisterRoutes(authGroup)
+	// 用于检查中间件放入 context 的时区
+	authGroup.GET("/whoami/location", func(c *gin.Context) {
+		c.String(http.StatusOK, domain.LocationFromContext(c).String())
+	})
+
+	body := `{"timezone":"Asia/Shanghai","work_days":[1,2,3]}`
+	req, _ := http.NewRequest("PUT", "/api/settings/profile", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var p domain.UserProfile
+	json.Unmarshal(w.Body.Bytes(), &p)
+	if p.Timezone != "Asia/Shanghai" || len(p.WorkDays) != 3 || p.WorkStart != "09:00" {
+		t.Errorf("unexpected profile: %+v", p)
+	}
+
+	req, _ = http.NewRequest("GET", "/api/whoami/location", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Body.String() != "Asia/Shanghai" {
+		t.Errorf("middleware location = %q", w.Body.String())
+	}
+
+	req, _ = http.NewRequest("PUT", "/api/settings/profile", bytes.NewBufferString(`{"work_days":[0]}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid work day, got %d", w.Code)
+	}
+}
@@ -0,0 +1,118 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"assistant-qisumi/internal/domain"
+	internalHTTP "assistant-qisumi/internal/http"
+	"assistant-qisumi/internal/quickadd"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestQuickAdd_Parse(t *testing.T) {
+	cst := time.FixedZone("CST", 8*3600)
+	// 2025-12-10 周三
+	ref := time.Date(2025, 12, 10, 14, 30, 0, 0, cst)
+
+	tk, err := quickadd.Parse("写周报 !high #工作 #Report @周五\n需要包含本周数据\n- 收集数据\n- [ ] 写初稿\n\n* 发给老板 #工作", ref)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if tk.Title != "写周报" || tk.Priority != "high" || tk.Description != "需要包含本周数据" {
+		t.Errorf("unexpected task: %+v", tk)
+	}
+	if len(tk.Tags) != 2 || tk.Tags[0] != "工作" || tk.Tags[1] != "Report" {
+		t.Errorf("unexpected tags: %v", tk.Tags)
+	}
+	if want := time.Date(2025, 12, 12, 23, 59, 0, 0, cst); tk.DueAt == nil || !tk.DueAt.ToTime().Equal(want) {
+		t.Errorf("due_at = %v, want %v", tk.DueAt, want)
+	}
+	if len(tk.Steps) != 3 || tk.Steps[1].Title != "写初稿" || tk.Steps[2].Title != "发给老板 #工作" || tk.Steps[2].OrderIndex != 2 {
+		t.Errorf("unexpected steps: %+v", tk.Steps)
+	}
+
+	// due: 与带引号 / 下划线的多词表达式，未指定优先级时留空；无法识别的 ! 原样保留
+	tk, err = quickadd.Parse(`Ship it! due:"next friday 3pm"`, ref)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if tk.Title != "Ship it!" || tk.Priority != "" || !tk.DueAt.ToTime().Equal(time.Date(2025, 12, 19, 15, 0, 0, 0, cst)) {
+		t.Errorf("unexpected task: %+v (due %v)", tk, tk.DueAt)
+	}
+	tk, _ = quickadd.Parse("Call mom @next_friday due:2025-12-01", ref)
+	if !tk.DueAt.ToTime().Equal(time.Date(2025, 12, 1, 23, 59, 0, 0, cst)) {
+		t.Errorf("the last due date should win, got %v", tk.DueAt)
+	}
+
+	// 无法识别为日期的 @ 标记是正文的一部分
+	tk, err = quickadd.Parse("ping @alice about report @明天", ref)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if tk.Title != "ping @alice about report" || !tk.DueAt.ToTime().Equal(time.Date(2025, 12, 11, 23, 59, 0, 0, cst)) {
+		t.Errorf("unexpected task: %+v (due %v)", tk, tk.DueAt)
+	}
+
+	for _, in := range []string{"", "#只有标签 !low", "开会 due:某个时候"} {
+		if _, err := quickadd.Parse(in, ref); !errors.Is(err, quickadd.ErrInvalidInput) {
+			t.Errorf("Parse(%q) should fail with ErrInvalidInput, got %v", in, err)
+		}
+	}
+}
+
+func TestQuickAddHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	taskSvc, sessionRepo, llmSettingSvc, gormDB := setupTaskTest(t)
+
+	router := gin.New()
+	router.ContextWithFallback = true
+	authGroup := router.Group("/api")
+	authGroup.Use(func(c *gin.Context) {
+		c.Set("userID", uint64(1))
+		c.Next()
+	})
+	internalHTTP.NewTaskHandler(taskSvc, sessionRepo, llmSettingSvc).RegisterRoutes(authGroup)
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/tasks/quick", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// 没有配置 LLM 时 enrich 只会被跳过，不影响创建
+	w := post(`{"text":"买菜 #家务 @明天\n- 鸡蛋\n- 牛奶","enrich":true}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Task     domain.Task `json:"task"`
+		Enriched bool        `json:"enriched"`
+		Warning  string      `json:"warning"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Enriched || resp.Warning == "" {
+		t.Errorf("expected enrichment to be skipped with a warning, got %+v", resp)
+	}
+
+	var stored domain.Task
+	if err := gormDB.Preload("Steps").First(&stored, resp.Task.ID).Error; err != nil {
+		t.Fatalf("task not stored: %v", err)
+	}
+	if stored.Title != "买菜" || stored.Priority != "medium" || stored.CreatedFrom != quickadd.CreatedFrom ||
+		len(stored.Tags) != 1 || stored.DueAt == nil || len(stored.Steps) != 2 {
+		t.Errorf("unexpected stored task: %+v", stored)
+	}
+
+	if w := post(`{"text":"@明天"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a missing title, got %d", w.Code)
+	}
+}
@@ -0,0 +1,207 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"assistant-qisumi/internal/auth"
+	"assistant-qisumi/internal/domain"
+	internalHTTP "assistant-qisumi/internal/http"
+	"assistant-qisumi/internal/llm"
+	"assistant-qisumi/internal/quota"
+	"assistant-qisumi/internal/usage"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestLimiter_RequestsPerMinute(t *testing.T) {
+	limiter := quota.NewLimiter(quota.Policy{
+		DefaultKey: quota.Limits{RequestsPerMinute: 2},
+		OwnKey:     quota.Limits{RequestsPerMinute: 0},
+	}, nil)
+
+	for i := 0; i < 2; i++ {
+		if _, err := limiter.AllowRequest(1, true); err != nil {
+			t.Fatalf("request %d should be allowed: %v", i, err)
+		}
+	}
+	_, err := limiter.AllowRequest(1, true)
+	var exceeded *quota.ExceededError
+	if !errors.As(err, &exceeded) || !errors.Is(err, quota.ErrQuotaExceeded) || exceeded.Limit != quota.LimitRequestsPerMinute {
+		t.Fatalf("expected requests_per_minute to be exceeded, got %v", err)
+	}
+	if d := time.Until(exceeded.ResetAt); d <= 0 || d > time.Minute {
+		t.Errorf("unexpected reset time in %v", d)
+	}
+
+	// 其他用户不受影响；使用自己 Key 时适用另一组限额（这里不限制）
+	if _, err := limiter.AllowRequest(2, true); err != nil {
+		t.Errorf("another user should not be limited: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		if _, err := limiter.AllowRequest(1, false); err != nil {
+			t.Fatalf("own-key requests should not be limited: %v", err)
+		}
+	}
+}
+
+func TestLimiter_ForgetsIdleUsers(t *testing.T) {
+	now := time.Date(2025, 12, 8, 9, 0, 0, 0, time.UTC)
+	limiter := quota.NewLimiter(quota.Policy{DefaultKey: quota.Limits{RequestsPerMinute: 2}}, nil).
+		WithClock(func() time.Time { return now })
+
+	for userID := uint64(1); userID <= 3; userID++ {
+		if _, err := limiter.AllowRequest(userID, true); err != nil {
+			t.Fatalf("request should be allowed: %v", err)
+		}
+	}
+	if n := limiter.TrackedUsers(); n != 3 {
+		t.Fatalf("expected 3 tracked users, got %d", n)
+	}
+
+	// 一分钟后只有用户 3 还在请求，其他用户的计数被清理
+	now = now.Add(61 * time.Second)
+	if _, err := limiter.AllowRequest(3, true); err != nil {
+		t.Fatalf("request should be allowed: %v", err)
+	}
+	if n := limiter.TrackedUsers(); n != 1 {
+		t.Errorf("idle users should be forgotten, %d still tracked", n)
+	}
+	if _, err := limiter.AllowRequest(1, true); err != nil {
+		t.Errorf("a forgotten user starts a fresh window: %v", err)
+	}
+}
+
+func TestLimiter_TokensPerDayAndClient(t *testing.T) {
+	usageSvc, db := setupUsageTest(t, nil)
+	limiter := quota.NewLimiter(quota.Policy{
+		DefaultKey: quota.Limits{TokensPerDay: 1000},
+		OwnKey:     quota.Limits{TokensPerDay: 5000},
+	}, usageSvc)
+	ctx := context.Background()
+
+	db.Create(&domain.LLMUsage{UserID: 1, DefaultKey: true, TotalTokens: 600})
+	db.Create(&domain.LLMUsage{UserID: 1, DefaultKey: false, TotalTokens: 3000})
+	// 之前几天的用量不计入
+	db.Create(&domain.LLMUsage{UserID: 1, DefaultKey: true, TotalTokens: 5000, CreatedAt: time.Now().AddDate(0, 0, -2)})
+
+	status, err := limiter.CheckTokens(ctx, 1, true)
+	if err != nil || status.Remaining != 400 {
+		t.Fatalf("expected 400 tokens remaining, got %+v (%v)", status, err)
+	}
+
+	inner := &usageLLMClient{usage: llm.Usage{PromptTokens: 300, CompletionTokens: 200, TotalTokens: 500}}
+	client := quota.NewClient(llm.NewMeteredClient(inner, usageSvc), limiter)
+	userCtx := llm.WithCallInfo(ctx, llm.CallInfo{UserID: 1, Agent: "executor"})
+	defaultCfg := llm.Config{Model: "m", IsDefault: true}
+
+	if _, err := client.Chat(userCtx, defaultCfg, llm.ChatRequest{}); err != nil {
+		t.Fatalf("first call should pass: %v", err)
+	}
+	// 用量达到 1100，超出默认 Key 的限额
+	_, err = client.Chat(userCtx, defaultCfg, llm.ChatRequest{})
+	var exceeded *quota.ExceededError
+	if !errors.As(err, &exceeded) || exceeded.Limit != quota.LimitTokensPerDay || exceeded.Max != 1000 {
+		t.Fatalf("expected tokens_per_day to be exceeded, got %v", err)
+	}
+	now := time.Now()
+	if !exceeded.ResetAt.After(now) || exceeded.ResetAt.Sub(now) > 24*time.Hour {
+		t.Errorf("tokens should reset at the next midnight, got %v", exceeded.ResetAt)
+	}
+
+	// 自己的 Key 单独计算
+	if _, err := client.Chat(userCtx, llm.Config{Model: "m"}, llm.ChatRequest{}); err != nil {
+		t.Errorf("own-key call should pass: %v", err)
+	}
+	// 没有用户归属的调用不受限制
+	if _, err := client.Chat(ctx, defaultCfg, llm.ChatRequest{}); err != nil {
+		t.Errorf("calls without a user should pass: %v", err)
+	}
+}
+
+func TestQuotaMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	llmSettingSvc, db := setupLLMProfileTest(t)
+	if err := db.AutoMigrate(&domain.LLMUsage{}); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	usageSvc := usage.NewService(usage.NewRepository(db), nil, "")
+	limiter := quota.NewLimiter(quota.Policy{
+		DefaultKey: quota.Limits{RequestsPerMinute: 1, TokensPerDay: 100},
+		OwnKey:     quota.Limits{RequestsPerMinute: 3},
+	}, usageSvc)
+
+	// 用户 2 使用自己的 API Key
+	ctx := context.Background()
+	own, _ := llmSettingSvc.CreateProfile(ctx, 2, auth.LLMProfileRequest{Name: "own", BaseURL: "https://own", APIKey: "sk-own", Model: "m"})
+	llmSettingSvc.SetAssignments(ctx, 2, map[string]uint64{domain.LLMAgentDefault: own.ID})
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		var id uint64
+		fmt.Sscan(c.GetHeader("X-User"), &id)
+		c.Set("userID", id)
+		c.Next()
+	})
+	router.POST("/chat", internalHTTP.QuotaMiddleware(limiter, llmSettingSvc), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+	// handler 中 LLM 调用返回的限额错误同样输出 429
+	router.POST("/llm-error", func(c *gin.Context) {
+		err := fmt.Errorf("executor agent Handle failed: %w", &quota.ExceededError{Limit: quota.LimitTokensPerDay, Max: 100, ResetAt: time.Now().Add(time.Hour)})
+		internalHTTP.RespondLLMError(c, "HandleUserMessage failed: ", err)
+	})
+
+	do := func(path, user string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", path, nil)
+		req.Header.Set("X-User", user)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do("/chat", "1"); w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Limit") != "1" || w.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Fatalf("first request should pass with rate limit headers, got %d %v", w.Code, w.Header())
+	}
+	w := do("/chat", "1")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected 429 with Retry-After, got %d %v", w.Code, w.Header())
+	}
+	var body struct {
+		Limit             string    `json:"limit"`
+		ResetAt           time.Time `json:"reset_at"`
+		RetryAfterSeconds int64     `json:"retry_after_seconds"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if body.Limit != quota.LimitRequestsPerMinute || body.ResetAt.IsZero() || body.RetryAfterSeconds < 1 || body.RetryAfterSeconds > 60 {
+		t.Errorf("unexpected 429 body: %s", w.Body.String())
+	}
+
+	// 自己 Key 的用户适用更宽松的限额
+	for i := 0; i < 3; i++ {
+		if w := do("/chat", "2"); w.Code != http.StatusOK {
+			t.Fatalf("own-key request %d should pass, got %d", i, w.Code)
+		}
+	}
+	if w := do("/chat", "2"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("own-key user should be limited after 3 requests, got %d", w.Code)
+	}
+
+	// 当天 token 用尽
+	db.Create(&domain.LLMUsage{UserID: 3, DefaultKey: true, TotalTokens: 100})
+	w = do("/chat", "3")
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if w.Code != http.StatusTooManyRequests || body.Limit != quota.LimitTokensPerDay {
+		t.Errorf("expected tokens_per_day 429, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if w := do("/llm-error", "1"); w.Code != http.StatusTooManyRequests || w.Header().Get("X-RateLimit-Reset") == "" {
+		t.Errorf("quota errors from the LLM client should map to 429, got %d", w.Code)
+	}
+}
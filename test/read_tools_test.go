@@ -0,0 +1,257 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"assistant-qisumi/internal/agent"
+	"assistant-qisumi/internal/domain"
+	"assistant-qisumi/internal/llm"
+	"assistant-qisumi/internal/session"
+	"assistant-qisumi/internal/task"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// scriptedToolLLMClient 每轮按顺序返回预设的一组工具调用，用完后返回文本回复，并记录每次请求
+type scriptedToolLLMClient struct {
+	rounds   [][]llm.ToolCallFunc
+	reply    string
+	requests []llm.ChatRequest
+}
+
+func (m *scriptedToolLLMClient) Chat(ctx context.Context, cfg llm.Config, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	n := len(m.requests)
+	m.requests = append(m.requests, req)
+	msg := llm.ChatMessage{Role: "assistant", Content: m.reply}
+	if n < len(m.rounds) {
+		msg = llm.ChatMessage{Role: "assistant"}
+		for i, call := range m.rounds[n] {
+			msg.ToolCalls = append(msg.ToolCalls, llm.ToolCall{
+				ID: fmt.Sprintf("call_%d_%d", n, i), Type: "function", Function: call,
+			})
+		}
+	}
+	resp := &llm.ChatResponse{}
+	resp.Choices = append(resp.Choices, struct {
+		Message      llm.ChatMessage `json:"message"`
+		FinishReason string          `json:"finish_reason"`
+	}{Message: msg})
+	return resp, nil
+}
+
+// setupReadToolsDB 准备两个任务：周报（有截止时间、步骤和会话）和已完成的采购
+func setupReadToolsDB(t *testing.T) (*gorm.DB, *domain.Task, *domain.Task) {
+	// 使用文件数据库：应用 Patch 时事务内外会同时使用多个连接
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "tasks.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&domain.Task{}, &domain.TaskStep{}, &domain.TaskDependency{}, &domain.Session{}, &domain.Message{}, &domain.SessionSummary{}); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+
+	done := time.Date(2025, 12, 8, 15, 0, 0, 0, time.UTC)
+	report := &domain.Task{UserID: 1, Title: "写周报", Status: "in_progress", Priority: "high", DueAt: flex(schedMonday.AddDate(0, 0, 4)),
+		Steps: []domain.TaskStep{
+			{Title: "整理数据", Status: "done", OrderIndex: 0, CompletedAt: &done},
+			{Title: "画图表", Detail: "用 100% 堆叠图", Status: "todo", OrderIndex: 1},
+		}}
+	purchase := &domain.Task{UserID: 1, Title: "采购显示器", Status: "done", Priority: "low"}
+	other := &domain.Task{UserID: 2, Title: "别人的周报", Status: "todo", Priority: "high"}
+	for _, tk := range []*domain.Task{report, purchase, other} {
+		if err := db.Create(tk).Error; err != nil {
+			t.Fatalf("failed to create task: %v", err)
+		}
+	}
+	if err := db.Create(&domain.TaskDependency{PredecessorTaskID: purchase.ID, SuccessorTaskID: report.ID, Condition: "task_done", Action: "notify_only"}).Error; err != nil {
+		t.Fatalf("failed to create dependency: %v", err)
+	}
+
+	sessions := session.NewRepository(db)
+	sess, err := sessions.GetTaskSessionOrCreate(context.Background(), 1, report.ID)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	if err := sessions.CreateMessage(context.Background(), &domain.Message{SessionID: sess.ID, Role: "user", Content: "数据整理好了"}); err != nil {
+		t.Fatalf("failed to create message: %v", err)
+	}
+	return db, report, purchase
+}
+
+func TestTaskRepository_FindAndSearch(t *testing.T) {
+	db, report, purchase := setupReadToolsDB(t)
+	repo := task.NewRepository(db)
+	ctx := context.Background()
+
+	tasks, err := repo.FindTasks(ctx, 1, task.TaskFilter{Statuses: []string{"done"}})
+	if err != nil || len(tasks) != 1 || tasks[0].ID != purchase.ID {
+		t.Fatalf("status filter: got %+v, %v", tasks, err)
+	}
+	before := schedMonday.AddDate(0, 0, 5)
+	tasks, err = repo.FindTasks(ctx, 1, task.TaskFilter{DueBefore: &before})
+	if err != nil || len(tasks) != 1 || tasks[0].ID != report.ID || len(tasks[0].Steps) != 2 {
+		t.Fatalf("due filter should return the report with its steps, got %+v, %v", tasks, err)
+	}
+	before = schedMonday
+	if tasks, _ = repo.FindTasks(ctx, 1, task.TaskFilter{DueBefore: &before}); len(tasks) != 0 {
+		t.Errorf("no task is due before %s, got %d", before, len(tasks))
+	}
+
+	for _, tc := range []struct {
+		query string
+		want  int
+	}{
+		{"周报", 1},   // 标题，不返回其他用户的任务
+		{"图表", 1},   // 步骤标题
+		{"100%", 1}, // 通配符按字面匹配
+		{"1_0", 0},  // 下划线不是通配符
+		{"显示器", 1},  // 已完成的任务也能搜到
+		{"不存在的内容", 0},
+	} {
+		hits, err := repo.SearchTasks(ctx, 1, tc.query, 10)
+		if err != nil || len(hits) != tc.want {
+			t.Errorf("search %q: expected %d hits, got %d (%v)", tc.query, tc.want, len(hits), err)
+		}
+	}
+}
+
+func TestReadTools_Execute(t *testing.T) {
+	db, report, purchase := setupReadToolsDB(t)
+	registry := agent.NewToolRegistry(task.NewRepository(db), session.NewRepository(db))
+	req := agent.AgentRequest{UserID: 1, Now: schedMonday}
+
+	run := func(name, args string) string {
+		t.Helper()
+		if !registry.IsReadOnly(name) {
+			t.Errorf("%s should be read-only", name)
+		}
+		exec, ok := registry.Executor(name)
+		if !ok {
+			t.Fatalf("%s should be registered", name)
+		}
+		out, err := exec.Execute(context.Background(), req, args)
+		if err != nil {
+			t.Fatalf("%s failed: %v", name, err)
+		}
+		data, _ := json.Marshal(out)
+		return string(data)
+	}
+
+	if out := run("list_tasks", `{}`); !strings.Contains(out, `"title":"写周报"`) || !strings.Contains(out, `"steps_done":1,"steps_total":2`) || strings.Contains(out, "采购") {
+		t.Errorf("list_tasks should return open tasks as summaries, got %s", out)
+	}
+	if out := run("list_tasks", `{"status":["done"]}`); !strings.Contains(out, "采购显示器") {
+		t.Errorf("list_tasks should filter by status, got %s", out)
+	}
+	if out := run("get_task", `{"task_id":`+jsonID(report.ID)+`}`); !strings.Contains(out, "画图表") {
+		t.Errorf("get_task should include steps, got %s", out)
+	}
+	if out := run("get_task", `{"task_id":999}`); !strings.Contains(out, "task 999 not found") {
+		t.Errorf("missing task should be reported to the model, got %s", out)
+	}
+	if out := run("get_dependencies", `{"task_id":`+jsonID(report.ID)+`}`); !strings.Contains(out, `"predecessorTaskId":`+jsonID(purchase.ID)) {
+		t.Errorf("get_dependencies should return the dependency, got %s", out)
+	}
+	if out := run("search", `{"query":"图表"}`); !strings.Contains(out, `"matched_steps":[{"id"`) || !strings.Contains(out, "画图表") {
+		t.Errorf("search should list matched steps, got %s", out)
+	}
+	out := run("get_task_history", `{"task_id":`+jsonID(report.ID)+`}`)
+	if !strings.Contains(out, `"title":"整理数据","completed_at":"2025-12-08T15:00:00Z"`) || !strings.Contains(out, "数据整理好了") {
+		t.Errorf("get_task_history should include completed steps and messages, got %s", out)
+	}
+	if registry.IsReadOnly("update_task") {
+		t.Errorf("update_task is not read-only")
+	}
+}
+
+func TestReadTools_TimesUseUserZone(t *testing.T) {
+	db, report, _ := setupReadToolsDB(t)
+	registry := agent.NewToolRegistry(task.NewRepository(db), session.NewRepository(db))
+	shanghai, _ := time.LoadLocation("Asia/Shanghai")
+	req := agent.AgentRequest{UserID: 1, Now: schedMonday.In(shanghai)}
+
+	for _, tc := range []struct{ name, args, want string }{
+		{"list_tasks", `{}`, `"due_at":"2025-12-12T08:00:00+08:00"`},
+		{"search", `{"query":"周报"}`, `"due_at":"2025-12-12T08:00:00+08:00"`},
+		{"get_task", `{"task_id":` + jsonID(report.ID) + `}`, `"dueAt":"2025-12-12T08:00:00+08:00"`},
+		{"get_task_history", `{"task_id":` + jsonID(report.ID) + `}`, `"completed_at":"2025-12-08T23:00:00+08:00"`},
+	} {
+		exec, _ := registry.Executor(tc.name)
+		out, err := exec.Execute(context.Background(), req, tc.args)
+		if err != nil {
+			t.Fatalf("%s failed: %v", tc.name, err)
+		}
+		if data, _ := json.Marshal(out); !strings.Contains(string(data), tc.want) {
+			t.Errorf("%s should show times in the user's zone (%s), got %s", tc.name, tc.want, data)
+		}
+	}
+}
+
+func TestChatCompletions_ReadToolsAllowFollowUpCalls(t *testing.T) {
+	db, report, _ := setupReadToolsDB(t)
+	registry := agent.NewToolRegistry(task.NewRepository(db), session.NewRepository(db))
+	client := &scriptedToolLLMClient{
+		rounds: [][]llm.ToolCallFunc{
+			{{Name: "search", Arguments: `{"query":"周报"}`}},
+			{{Name: "get_task", Arguments: `{"task_id":` + jsonID(report.ID) + `}`}},
+			{{Name: "update_task", Arguments: `{"task_id":` + jsonID(report.ID) + `,"fields":{"priority":"medium"}}`}},
+		},
+		reply: "已把周报的优先级调为中",
+	}
+	handler := agent.NewChatCompletionsHandler(client, registry)
+	req := agent.AgentRequest{UserID: 1, LLMConfig: llm.Config{Model: "m"}}
+	messages := []llm.Message{{Role: "user", Content: "周报没那么急了"}}
+
+	reply, patches, err := handler.HandleChatCompletions(context.Background(), req, messages, []string{"search", "get_task", "update_task"})
+	if err != nil {
+		t.Fatalf("HandleChatCompletions failed: %v", err)
+	}
+	if reply != "已把周报的优先级调为中" || len(patches) != 1 || patches[0].UpdateTask == nil || patches[0].UpdateTask.TaskID != report.ID {
+		t.Fatalf("unexpected result: reply=%q patches=%+v", reply, patches)
+	}
+	if len(client.requests) != 4 {
+		t.Fatalf("expected 4 llm calls, got %d", len(client.requests))
+	}
+	if client.requests[1].ToolChoice != "auto" || client.requests[2].ToolChoice != "auto" || client.requests[3].ToolChoice != "none" {
+		t.Errorf("tools should stay available after read-only calls, got %q %q %q",
+			client.requests[1].ToolChoice, client.requests[2].ToolChoice, client.requests[3].ToolChoice)
+	}
+
+	// 只读工具的连续调用有上限
+	looping := &scriptedToolLLMClient{reply: "好的"}
+	for i := 0; i < 10; i++ {
+		looping.rounds = append(looping.rounds, []llm.ToolCallFunc{{Name: "search", Arguments: `{"query":"周报"}`}})
+	}
+	if _, _, err := agent.NewChatCompletionsHandler(looping, registry).HandleChatCompletions(context.Background(), req, messages, []string{"search"}); err != nil {
+		t.Fatalf("HandleChatCompletions failed: %v", err)
+	}
+	if last := looping.requests[len(looping.requests)-1]; len(looping.requests) != 6 || last.ToolChoice != "none" {
+		t.Errorf("expected tool calls to stop after 5 rounds, got %d calls", len(looping.requests))
+	}
+}
+
+func TestBuildTaskIndex(t *testing.T) {
+	// 截止时间以 UTC 保存，按用户时区显示
+	shanghai := time.FixedZone("CST", 8*3600)
+	index := agent.BuildTaskIndex([]domain.Task{
+		{ID: 3, Title: "写周报", Status: "todo", Priority: "high", IsFocusToday: true, DueAt: flex(schedMonday.Add(18 * time.Hour))},
+		{ID: 4, Title: "读书", Status: "in_progress", Priority: "low"},
+	}, shanghai)
+	for _, want := range []string{"#3 | 写周报 | todo | high | 2025-12-09 02:00 | 是", "#4 | 读书 | in_progress | low | 无 |", "get_task"} {
+		if !strings.Contains(index, want) {
+			t.Errorf("index should contain %q, got %s", want, index)
+		}
+	}
+}
+
+func jsonID(id uint64) string {
+	data, _ := json.Marshal(id)
+	return string(data)
+}
@@ -0,0 +1,200 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"assistant-qisumi/internal/domain"
+	"assistant-qisumi/internal/report"
+	"assistant-qisumi/internal/task"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+type fakeDurations map[uint64]int
+
+func (f fakeDurations) ActualMinutes(ctx context.Context, userID, taskID uint64) (map[uint64]int, error) {
+	return f, nil
+}
+
+func setupReportTestDB(t *testing.T) (*gorm.DB, *domain.Task, *domain.Task) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to connect database: %v", err)
+	}
+	err = db.AutoMigrate(&domain.Task{}, &domain.TaskStep{}, &domain.TaskDependency{},
+		&domain.Session{}, &domain.Message{}, &domain.ReportTemplate{})
+	if err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+
+	estimate := 30
+	main := &domain.Task{UserID: 1, Title: "写论文", Status: "in_progress", Priority: "high", Steps: []domain.TaskStep{
+		{Title: "查资料", Status: "done", OrderIndex: 0, EstimateMin: &estimate},
+		{Title: "写初稿", Status: "blocked", OrderIndex: 1, BlockingReason: "等导师反馈 <b>", EstimateMin: &estimate},
+	}}
+	other := &domain.Task{UserID: 1, Title: "导师会议", Status: "todo", Priority: "medium", Steps: []domain.TaskStep{
+		{Title: "约时间", Status: "todo", OrderIndex: 0},
+	}}
+	db.Create(main)
+	db.Create(other)
+	db.Create(&domain.TaskDependency{
+		PredecessorTaskID: other.ID, PredecessorStepID: &other.Steps[0].ID,
+		SuccessorTaskID: main.ID, SuccessorStepID: &main.Steps[1].ID,
+		Condition: "step_done", Action: "unlock_step",
+	})
+
+	sess := domain.Session{UserID: 1, TaskID: &main.ID, Type: "task"}
+	db.Create(&sess)
+	summarizer, executor := "summarizer", "executor"
+	db.Create(&domain.Message{SessionID: sess.ID, Role: "assistant", AgentName: &summarizer, Content: "旧总结"})
+	db.Create(&domain.Message{SessionID: sess.ID, Role: "assistant", AgentName: &summarizer, Content: "已完成资料收集"})
+	db.Create(&domain.Message{SessionID: sess.ID, Role: "assistant", AgentName: &executor, Content: "好的"})
+
+	return db, main, other
+}
+
+func TestReport_ExportMarkdown(t *testing.T) {
+	db, main, _ := setupReportTestDB(t)
+	svc := report.NewService(report.NewRepository(db), task.NewRepository(db), fakeDurations{main.Steps[0].ID: 45})
+	ctx := context.Background()
+
+	out, err := svc.Export(ctx, 1, main.ID, report.Options{Format: report.FormatMarkdown, IncludeSummary: true})
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	md := string(out)
+	for _, want := range []string{
+		"## 写论文",
+		"- [x] 查资料 — 预计 30 分钟 / 实际 45 分钟",
+		"- [ ] 写初稿（阻塞）",
+		"阻塞原因：等导师反馈 <b>",
+		"依赖「导师会议」的「约时间」，解锁「写初稿」",
+		"进度：1/2（50%）",
+		"预计耗时：1 小时，实际耗时：45 分钟",
+		"已完成资料收集",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("markdown missing %q:\n%s", want, md)
+		}
+	}
+	if strings.Contains(md, "旧总结") {
+		t.Error("only the latest summary should be included")
+	}
+
+	out, err = svc.Export(ctx, 1, main.ID, report.Options{Format: report.FormatMarkdown})
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if strings.Contains(string(out), "最新总结") {
+		t.Error("summary should be omitted unless requested")
+	}
+}
+
+func TestReport_ExportHTMLAndJSON(t *testing.T) {
+	db, main, other := setupReportTestDB(t)
+	svc := report.NewService(report.NewRepository(db), task.NewRepository(db), nil)
+	ctx := context.Background()
+
+	out, err := svc.Export(ctx, 1, main.ID, report.Options{Format: report.FormatHTML})
+	if err != nil {
+		t.Fatalf("Export html failed: %v", err)
+	}
+	html := string(out)
+	if !strings.Contains(html, "等导师反馈 &lt;b&gt;") {
+		t.Errorf("html output should escape user content:\n%s", html)
+	}
+	if !strings.Contains(html, "checked") {
+		t.Error("done step should render a checked checkbox")
+	}
+
+	out, err = svc.Export(ctx, 1, other.ID, report.Options{Format: report.FormatJSON})
+	if err != nil {
+		t.Fatalf("Export json failed: %v", err)
+	}
+	var r report.Report
+	if err := json.Unmarshal(out, &r); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	if len(r.Dependencies) != 1 || r.Dependencies[0].Direction != "blocks" || r.Dependencies[0].TaskTitle != "写论文" {
+		t.Errorf("unexpected dependencies: %+v", r.Dependencies)
+	}
+	if r.Progress.ActualMin != nil {
+		t.Error("actual minutes should be empty without a duration provider")
+	}
+
+	if _, err := svc.Export(ctx, 2, main.ID, report.Options{Format: report.FormatJSON}); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("expected not found for another user, got %v", err)
+	}
+}
+
+func TestReport_CustomTemplate(t *testing.T) {
+	db, main, _ := setupReportTestDB(t)
+	svc := report.NewService(report.NewRepository(db), task.NewRepository(db), nil)
+	ctx := context.Background()
+
+	if err := svc.SaveTemplate(ctx, 1, report.FormatMarkdown, "{{.Task.Title"); !errors.Is(err, report.ErrInvalidTemplate) {
+		t.Fatalf("expected ErrInvalidTemplate, got %v", err)
+	}
+	if err := svc.SaveTemplate(ctx, 1, report.FormatMarkdown, "{{.Task.Missing}}"); !errors.Is(err, report.ErrInvalidTemplate) {
+		t.Fatalf("expected ErrInvalidTemplate for unknown field, got %v", err)
+	}
+
+	tpl := "{{.Task.Title}}: {{.Progress.Percent}}%{{range .Steps}} {{checkbox .Done}}{{end}}"
+	if err := svc.SaveTemplate(ctx, 1, report.FormatMarkdown, tpl); err != nil {
+		t.Fatalf("SaveTemplate failed: %v", err)
+	}
+	// 再次保存会覆盖
+	if err := svc.SaveTemplate(ctx, 1, report.FormatMarkdown, "v2 "+tpl); err != nil {
+		t.Fatalf("SaveTemplate overwrite failed: %v", err)
+	}
+
+	out, err := svc.Export(ctx, 1, main.ID, report.Options{Format: report.FormatMarkdown})
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if got := string(out); got != "v2 写论文: 50% [x] [ ]" {
+		t.Errorf("unexpected custom output %q", got)
+	}
+
+	if err := svc.ResetTemplate(ctx, 1, report.FormatMarkdown); err != nil {
+		t.Fatalf("ResetTemplate failed: %v", err)
+	}
+	_, custom, err := svc.GetTemplate(ctx, 1, report.FormatMarkdown)
+	if err != nil || custom {
+		t.Errorf("expected default template after reset, custom=%v err=%v", custom, err)
+	}
+}
+
+func TestReport_TimesUseUserZone(t *testing.T) {
+	db, main, _ := setupReportTestDB(t)
+	db.Model(main).Update("due_at", time.Date(2025, 12, 8, 10, 0, 0, 0, time.UTC))
+	svc := report.NewService(report.NewRepository(db), task.NewRepository(db), nil)
+	shanghai, _ := time.LoadLocation("Asia/Shanghai")
+	ctx := domain.WithLocation(context.Background(), shanghai)
+
+	out, err := svc.Export(ctx, 1, main.ID, report.Options{Format: report.FormatMarkdown})
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if !strings.Contains(string(out), "截止时间：2025-12-08 18:00") {
+		t.Errorf("due time should be shown in the user's zone:\n%s", out)
+	}
+
+	out, err = svc.Export(ctx, 1, main.ID, report.Options{Format: report.FormatJSON})
+	if err != nil {
+		t.Fatalf("Export json failed: %v", err)
+	}
+	var r report.Report
+	if err := json.Unmarshal(out, &r); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	if _, offset := r.GeneratedAt.Zone(); offset != 8*3600 {
+		t.Errorf("generatedAt should be in the user's zone, got %v", r.GeneratedAt)
+	}
+}
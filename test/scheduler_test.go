@@ -0,0 +1,234 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"assistant-qisumi/internal/agent"
+	"assistant-qisumi/internal/domain"
+	"assistant-qisumi/internal/scheduler"
+	"assistant-qisumi/internal/task"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func schedStep(id uint64, order int, estimate int, status string) domain.TaskStep {
+	e := estimate
+	return domain.TaskStep{ID: id, Title: "步骤", Status: status, OrderIndex: order, EstimateMin: &e}
+}
+
+func flex(t time.Time) *domain.FlexibleTime {
+	ft := domain.FlexibleTime{Time: t}
+	return &ft
+}
+
+// 2025-12-08 是周一
+var schedMonday = time.Date(2025, 12, 8, 0, 0, 0, 0, time.UTC)
+
+func TestScheduler_SequentialWithinWorkingHours(t *testing.T) {
+	in := scheduler.Input{
+		Tasks: []domain.Task{{
+			ID: 1, Title: "写报告", Priority: "medium", Status: "todo",
+			Steps: []domain.TaskStep{
+				schedStep(11, 1, 120, "todo"),
+				schedStep(10, 0, 60, "done"),
+				schedStep(12, 2, 240, "todo"),
+				schedStep(13, 3, 600, "todo"),
+			},
+		}},
+		Hours: scheduler.DefaultWorkingHours(time.UTC),
+		// 周五 16:02，取整到 16:05
+		Start: schedMonday.AddDate(0, 0, 4).Add(16*time.Hour + 2*time.Minute),
+	}
+	res, err := scheduler.Schedule(in)
+	if err != nil {
+		t.Fatalf("Schedule failed: %v", err)
+	}
+	if len(res.Items) != 3 {
+		t.Fatalf("expected 3 scheduled steps (done step skipped), got %+v", res.Items)
+	}
+	nextMonday := schedMonday.AddDate(0, 0, 7)
+	want := []struct {
+		step       uint64
+		start, end time.Time
+	}{
+		// 周五 16:05-18:00 放不下 2 小时的步骤，整段顺延到下周一
+		{11, nextMonday.Add(9 * time.Hour), nextMonday.Add(11 * time.Hour)},
+		{12, nextMonday.Add(11 * time.Hour), nextMonday.Add(15 * time.Hour)},
+		// 超过一天工时的步骤可以跨天
+		{13, nextMonday.Add(15 * time.Hour), nextMonday.AddDate(0, 0, 1).Add(16 * time.Hour)},
+	}
+	for i, w := range want {
+		it := res.Items[i]
+		if it.StepID != w.step || !it.Start.Equal(w.start) || !it.End.Equal(w.end) {
+			t.Errorf("item %d = step %d %v-%v, want step %d %v-%v", i, it.StepID, it.Start, it.End, w.step, w.start, w.end)
+		}
+	}
+}
+
+func TestScheduler_DependenciesPriorityAndDeadlines(t *testing.T) {
+	due := schedMonday.Add(10 * time.Hour)
+	in := scheduler.Input{
+		Tasks: []domain.Task{
+			{ID: 1, Title: "低优先级", Priority: "low", Status: "todo", Steps: []domain.TaskStep{schedStep(11, 0, 60, "todo")}},
+			{ID: 2, Title: "高优先级", Priority: "high", Status: "todo", Steps: []domain.TaskStep{schedStep(21, 0, 60, "todo")}},
+			{ID: 3, Title: "急事", Priority: "medium", Status: "todo", DueAt: flex(due), Steps: []domain.TaskStep{
+				schedStep(31, 0, 60, "todo"),
+				schedStep(32, 1, 60, "todo"),
+			}},
+		},
+		// 高优先级任务要等低优先级任务完成
+		Dependencies: []domain.TaskDependency{{PredecessorTaskID: 1, SuccessorTaskID: 2}},
+		Hours:        scheduler.DefaultWorkingHours(time.UTC),
+		Start:        schedMonday.Add(9 * time.Hour),
+	}
+	res, err := scheduler.Schedule(in)
+	if err != nil {
+		t.Fatalf("Schedule failed: %v", err)
+	}
+	var order []uint64
+	for i, it := range res.Items {
+		order = append(order, it.StepID)
+		if i > 0 && it.Start.Before(res.Items[i-1].End) {
+			t.Errorf("items overlap: %+v and %+v", res.Items[i-1], it)
+		}
+	}
+	// 有截止时间的任务最先，其次是依赖顺序约束下的 1 -> 2
+	if len(order) != 4 || order[0] != 31 || order[1] != 32 || order[2] != 11 || order[3] != 21 {
+		t.Fatalf("unexpected order: %v", order)
+	}
+	if !res.Items[1].Late || res.Items[0].Late {
+		t.Errorf("only the second step should be late: %+v", res.Items[:2])
+	}
+	if len(res.Warnings) != 1 || !strings.Contains(res.Warnings[0], "急事") {
+		t.Errorf("expected a deadline warning, got %v", res.Warnings)
+	}
+}
+
+func TestScheduler_BusyAndBlocked(t *testing.T) {
+	in := scheduler.Input{
+		Tasks: []domain.Task{
+			{ID: 1, Title: "排期中", Priority: "medium", Status: "todo", Steps: []domain.TaskStep{
+				schedStep(11, 0, 60, "todo"),
+				schedStep(12, 1, 30, "blocked"),
+				schedStep(13, 2, 30, "todo"),
+			}},
+			{ID: 2, Title: "已有安排", Priority: "medium", Status: "todo", Steps: []domain.TaskStep{{
+				ID: 21, Title: "会议", Status: "todo",
+				PlannedStart: flex(schedMonday.Add(9 * time.Hour)),
+				PlannedEnd:   flex(schedMonday.Add(10*time.Hour + 30*time.Minute)),
+			}}},
+		},
+		TaskIDs: []uint64{1},
+		Hours:   scheduler.DefaultWorkingHours(time.UTC),
+		Start:   schedMonday.Add(9 * time.Hour),
+	}
+	res, err := scheduler.Schedule(in)
+	if err != nil {
+		t.Fatalf("Schedule failed: %v", err)
+	}
+	if len(res.Items) != 1 || res.Items[0].StepID != 11 || !res.Items[0].Start.Equal(schedMonday.Add(10*time.Hour+30*time.Minute)) {
+		t.Fatalf("step should start after the existing plan, got %+v", res.Items)
+	}
+	if len(res.Unscheduled) != 2 || res.Unscheduled[0].StepID != 12 || res.Unscheduled[1].StepID != 13 {
+		t.Fatalf("blocked step and its successor should be unscheduled, got %+v", res.Unscheduled)
+	}
+
+	if _, err := scheduler.Schedule(scheduler.Input{Hours: scheduler.WorkingHours{Start: 18 * time.Hour, End: 9 * time.Hour}}); err == nil {
+		t.Error("expected an error for invalid working hours")
+	}
+}
+
+func TestScheduler_MultiDayStepSkipsBusyBetweenDays(t *testing.T) {
+	in := scheduler.Input{
+		Tasks: []domain.Task{{ID: 1, Title: "长步骤", Priority: "medium", Status: "todo",
+			Steps: []domain.TaskStep{schedStep(11, 0, 600, "todo")}}},
+		// 周一晚上到周二早上的占用夹在两个工作日之间
+		Busy:  []scheduler.Interval{{Start: schedMonday.Add(19 * time.Hour), End: schedMonday.Add(32 * time.Hour)}},
+		Hours: scheduler.DefaultWorkingHours(time.UTC),
+		Start: schedMonday.Add(9 * time.Hour),
+	}
+	res, err := scheduler.Schedule(in)
+	if err != nil {
+		t.Fatalf("Schedule failed: %v", err)
+	}
+	if len(res.Items) != 1 {
+		t.Fatalf("expected the step to be scheduled, got %+v", res)
+	}
+	it := res.Items[0]
+	for _, b := range in.Busy {
+		if it.Start.Before(b.End) && it.End.After(b.Start) {
+			t.Errorf("step %v-%v overlaps busy %v-%v", it.Start, it.End, b.Start, b.End)
+		}
+	}
+	// 从周二开始，跨到周三
+	tuesday := schedMonday.AddDate(0, 0, 1)
+	if !it.Start.Equal(tuesday.Add(9*time.Hour)) || !it.End.Equal(tuesday.AddDate(0, 0, 1).Add(10*time.Hour)) {
+		t.Errorf("expected Tuesday 9:00 to Wednesday 10:00, got %v-%v", it.Start, it.End)
+	}
+}
+
+func TestScheduleStepsExecutor_ProducesPatches(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to connect database: %v", err)
+	}
+	if err := db.AutoMigrate(&domain.Task{}, &domain.TaskStep{}, &domain.TaskDependency{}); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	tk := &domain.Task{UserID: 1, Title: "任务", Status: "todo", Priority: "medium", Steps: []domain.TaskStep{
+		schedStep(0, 0, 30, "todo"),
+		schedStep(0, 1, 45, "todo"),
+	}}
+	if err := db.Create(tk).Error; err != nil {
+		t.Fatalf("failed to create task: %v", err)
+	}
+
+	exec, ok := agent.NewToolRegistry(task.NewRepository(db), nil).Executor("schedule_steps")
+	if !ok {
+		t.Fatal("schedule_steps should be registered")
+	}
+	req := agent.AgentRequest{UserID: 1, Task: tk, Now: schedMonday.Add(9 * time.Hour)}
+	out, err := exec.Execute(context.Background(), req, `{"work_start":"10:00","work_days":[1,2,3,4,5]}`)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	patches := out.(agent.PatchProducer).TaskPatches()
+	if len(patches) != 2 || patches[0].Kind != agent.PatchUpdateStep {
+		t.Fatalf("expected 2 update_step patches, got %+v", patches)
+	}
+	if got := *patches[0].UpdateStep.Fields.PlannedStart; got != "2025-12-08T10:00:00Z" {
+		t.Errorf("first step planned start = %s", got)
+	}
+	if got := *patches[1].UpdateStep.Fields.PlannedEnd; got != "2025-12-08T11:15:00Z" {
+		t.Errorf("second step planned end = %s", got)
+	}
+
+	preview, err := exec.Execute(context.Background(), req, `{"apply":false}`)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if len(preview.(agent.PatchProducer).TaskPatches()) != 0 {
+		t.Error("preview should not produce patches")
+	}
+
+	// 参数错误作为工具结果返回给模型修正，而不是中断对话
+	for _, args := range []string{
+		`{"start_from":"someday"}`,
+		`{"work_start":"25:00"}`,
+		`{"work_start":"18:00","work_end":"09:00"}`,
+	} {
+		out, err := exec.Execute(context.Background(), req, args)
+		if err != nil {
+			t.Fatalf("%s: expected a tool error result, got %v", args, err)
+		}
+		data, _ := json.Marshal(out)
+		if !strings.Contains(string(data), `"error"`) {
+			t.Errorf("%s: expected an error result, got %s", args, data)
+		}
+	}
+}
@@ -31,6 +31,10 @@ func setupSessionTest(t *testing.T) (*agent.Service, *session.Repository, *auth.
         user_id INTEGER NOT NULL,
         task_id INTEGER,
         type TEXT NOT NULL DEFAULT "task",
+        title VARCHAR(100) NOT NULL DEFAULT '',
+        titled BOOLEAN NOT NULL DEFAULT FALSE,
+        archived_at DATETIME,
+        last_message_at DATETIME,
         created_at DATETIME
     )`)
 
@@ -53,8 +57,10 @@ func setupSessionTest(t *testing.T) (*agent.Service, *session.Repository, *auth.
         is_focus_today BOOLEAN DEFAULT FALSE,
         due_at DATETIME,
         created_from TEXT,
+        tags TEXT,
         created_at DATETIME,
-        updated_at DATETIME
+        updated_at DATETIME,
+        completed_at DATETIME
     )`)
 
 	err = gormDB.AutoMigrate(&auth.UserLLMSetting{})
@@ -80,8 +86,8 @@ func setupSessionTest(t *testing.T) (*agent.Service, *session.Repository, *auth.
 
 func TestSessionHandler(t *testing.T) {
 	gin.SetMode(gin.TestMode)
-	agentSvc, sessionRepo, llmSettingSvc, _ := setupSessionTest(t)
-	handler := internalHTTP.NewSessionHandler(agentSvc, sessionRepo, llmSettingSvc)
+	agentSvc, sessionRepo, llmSettingSvc, gormDB := setupSessionTest(t)
+	handler := internalHTTP.NewSessionHandler(agentSvc, sessionRepo, task.NewRepository(gormDB), llmSettingSvc)
 
 	router := gin.Default()
 	authGroup := router.Group("/api")
@@ -0,0 +1,230 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"assistant-qisumi/internal/agent"
+	"assistant-qisumi/internal/dependency"
+	"assistant-qisumi/internal/domain"
+	internalHTTP "assistant-qisumi/internal/http"
+	"assistant-qisumi/internal/llm"
+	"assistant-qisumi/internal/session"
+	"assistant-qisumi/internal/task"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTitleFromMessage(t *testing.T) {
+	cases := map[string]string{
+		"  帮我安排一下今天  ":              "帮我安排一下今天",
+		"写周报\n先整理数据，再画图表":           "写周报",
+		"plan   the\tweekly report": "plan the weekly report",
+		strings.Repeat("长", 40):     strings.Repeat("长", 30) + "…",
+	}
+	for in, want := range cases {
+		if got := session.TitleFromMessage(in); got != want {
+			t.Errorf("TitleFromMessage(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestSessionRepository_MultipleSessionsPerScope(t *testing.T) {
+	db, report, _ := setupReadToolsDB(t)
+	ctx := context.Background()
+	repo := session.NewRepository(db)
+
+	// setupReadToolsDB 已经为周报创建了一个带消息的会话
+	first, err := repo.GetTaskSessionOrCreate(ctx, 1, report.ID)
+	if err != nil {
+		t.Fatalf("GetTaskSessionOrCreate failed: %v", err)
+	}
+
+	// 开始新会话：旧会话和消息保留，新会话成为当前会话
+	fresh := &session.Session{UserID: 1, TaskID: &report.ID, Type: "task", Title: "重新开始"}
+	if err := repo.CreateSession(ctx, fresh); err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	if cur, _ := repo.GetTaskSessionOrCreate(ctx, 1, report.ID); cur.ID != fresh.ID {
+		t.Errorf("newest session should be current, got %d", cur.ID)
+	}
+	if err := repo.CreateMessage(ctx, &session.Message{SessionID: fresh.ID, Role: "user", Content: "新会话的消息"}); err != nil {
+		t.Fatalf("CreateMessage failed: %v", err)
+	}
+
+	// 旧会话有了新消息后重新成为当前会话
+	time.Sleep(10 * time.Millisecond)
+	if err := repo.CreateMessage(ctx, &session.Message{SessionID: first.ID, Role: "user", Content: "回到旧会话"}); err != nil {
+		t.Fatalf("CreateMessage failed: %v", err)
+	}
+	if cur, _ := repo.GetTaskSessionOrCreate(ctx, 1, report.ID); cur.ID != first.ID {
+		t.Errorf("most recently active session should be current, got %d", cur.ID)
+	}
+
+	// 归档后不再是当前会话，也不在默认列表里
+	now := time.Now()
+	first.ArchivedAt = &now
+	if err := repo.SaveSession(ctx, first); err != nil {
+		t.Fatalf("SaveSession failed: %v", err)
+	}
+	if cur, _ := repo.GetTaskSessionOrCreate(ctx, 1, report.ID); cur.ID != fresh.ID {
+		t.Errorf("archived session should not be current, got %d", cur.ID)
+	}
+	active, _ := repo.ListSessions(ctx, 1, session.SessionFilter{TaskID: &report.ID})
+	archivedOnly := true
+	archived, _ := repo.ListSessions(ctx, 1, session.SessionFilter{TaskID: &report.ID, Archived: &archivedOnly})
+	if len(active) != 1 || active[0].ID != fresh.ID || len(archived) != 1 || archived[0].ID != first.ID {
+		t.Errorf("unexpected lists: active %+v, archived %+v", active, archived)
+	}
+
+	// 任务历史包含所有会话的消息
+	msgs, err := repo.ListRecentTaskMessages(ctx, 1, report.ID, 20)
+	if err != nil {
+		t.Fatalf("ListRecentTaskMessages failed: %v", err)
+	}
+	var contents []string
+	for _, m := range msgs {
+		contents = append(contents, m.Content)
+	}
+	joined := strings.Join(contents, "|")
+	if !strings.Contains(joined, "新会话的消息") || !strings.HasSuffix(joined, "回到旧会话") {
+		t.Errorf("task history should include all sessions in order, got %q", joined)
+	}
+	if others, _ := repo.ListRecentTaskMessages(ctx, 2, report.ID, 20); len(others) != 0 {
+		t.Errorf("other users should not see the task's messages, got %d", len(others))
+	}
+}
+
+func TestAgentService_AutoTitlesSession(t *testing.T) {
+	db, _, _ := setupReadToolsDB(t)
+	if err := db.AutoMigrate(&domain.UserProfile{}, &domain.DailyPlan{}, &domain.DailyPlanItem{}); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	ctx := context.Background()
+	taskRepo, sessionRepo := task.NewRepository(db), session.NewRepository(db)
+	sess, err := sessionRepo.GetGlobalSessionOrCreate(ctx, 1)
+	if err != nil {
+		t.Fatalf("failed to create global session: %v", err)
+	}
+	client := &scriptedToolLLMClient{reply: "好的"}
+	handler := agent.NewChatCompletionsHandler(client, agent.NewToolRegistry(taskRepo, sessionRepo))
+	svc := agent.NewService(agent.NewSimpleRouter(), agent.NewDefaultAgents(client, handler), taskRepo, sessionRepo,
+		dependency.NewService(db, taskRepo, sessionRepo), db, client)
+
+	for _, input := range []string{"帮我安排一下这周的工作\n重点是周报", "再加一个任务"} {
+		if _, err := svc.HandleUserMessage(ctx, 1, sess.ID, input, llm.Config{Model: "m"}); err != nil {
+			t.Fatalf("HandleUserMessage failed: %v", err)
+		}
+	}
+	svc.Wait()
+	updated, _ := sessionRepo.GetSession(ctx, sess.ID)
+	if updated.Title != "帮我安排一下这周的工作" || updated.LastMessageAt == nil {
+		t.Errorf("session should be titled from the first message and track activity, got %+v", updated)
+	}
+}
+
+func TestSessionHandler_CreateListArchive(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, report, _ := setupReadToolsDB(t)
+	other := &domain.Task{UserID: 2, Title: "别人的任务", Status: "todo"}
+	db.Create(other)
+	taskRepo, sessionRepo := task.NewRepository(db), session.NewRepository(db)
+
+	router := gin.New()
+	group := router.Group("/api")
+	group.Use(func(c *gin.Context) {
+		c.Set("userID", uint64(1))
+		c.Next()
+	})
+	internalHTTP.NewSessionHandler(nil, sessionRepo, taskRepo, nil).RegisterRoutes(group)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	type sessionResp struct {
+		Session session.Session `json:"session"`
+	}
+	type listResp struct {
+		Sessions []session.Session `json:"sessions"`
+	}
+
+	w := do("POST", "/api/sessions", `{"type":"task","taskId":`+jsonID(report.ID)+`,"title":" 第二轮讨论 "}`)
+	var created sessionResp
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &created) != nil || created.Session.Title != "第二轮讨论" {
+		t.Fatalf("create task session: %d %s", w.Code, w.Body.String())
+	}
+	if w := do("POST", "/api/sessions", `{"type":"global"}`); w.Code != http.StatusOK {
+		t.Fatalf("create global session: %d %s", w.Code, w.Body.String())
+	}
+	if w := do("POST", "/api/sessions", `{"type":"task","taskId":`+jsonID(other.ID)+`}`); w.Code != http.StatusNotFound {
+		t.Errorf("foreign task: expected 404, got %d", w.Code)
+	}
+	if w := do("POST", "/api/sessions", `{"type":"task"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("missing taskId: expected 400, got %d", w.Code)
+	}
+	if w := do("POST", "/api/sessions", `{"type":"chat"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid type: expected 400, got %d", w.Code)
+	}
+
+	var list listResp
+	w = do("GET", "/api/sessions?type=task&taskId="+jsonID(report.ID), "")
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &list) != nil || len(list.Sessions) != 2 || list.Sessions[0].ID != created.Session.ID {
+		t.Fatalf("list task sessions: %d %s", w.Code, w.Body.String())
+	}
+	w = do("GET", "/api/sessions?type=global", "")
+	list = listResp{}
+	if json.Unmarshal(w.Body.Bytes(), &list) != nil || len(list.Sessions) != 1 {
+		t.Fatalf("list global sessions: %d %s", w.Code, w.Body.String())
+	}
+	if w := do("GET", "/api/sessions?archived=maybe", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid archived: expected 400, got %d", w.Code)
+	}
+
+	path := "/api/sessions/" + jsonID(created.Session.ID)
+	w = do("PATCH", path, `{"title":"改名了","archived":true}`)
+	var patched sessionResp
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &patched) != nil || patched.Session.Title != "改名了" || patched.Session.ArchivedAt == nil {
+		t.Fatalf("archive: %d %s", w.Code, w.Body.String())
+	}
+	w = do("GET", "/api/sessions?archived=true", "")
+	list = listResp{}
+	if json.Unmarshal(w.Body.Bytes(), &list) != nil || len(list.Sessions) != 1 || list.Sessions[0].Title != "改名了" {
+		t.Errorf("list archived: %s", w.Body.String())
+	}
+	w = do("PATCH", path, `{"archived":false}`)
+	patched = sessionResp{}
+	if json.Unmarshal(w.Body.Bytes(), &patched) != nil || patched.Session.ArchivedAt != nil || patched.Session.Title != "改名了" {
+		t.Errorf("unarchive: %d %s", w.Code, w.Body.String())
+	}
+	if w := do("PATCH", path, `{"title":"`+strings.Repeat("长", 101)+`"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("long title: expected 400, got %d", w.Code)
+	}
+
+	// 用户清空的标题不会再被第一条消息自动命名覆盖
+	if w := do("PATCH", path, `{"title":""}`); w.Code != http.StatusOK {
+		t.Fatalf("clear title: %d %s", w.Code, w.Body.String())
+	}
+	if err := sessionRepo.SetTitleIfUntitled(context.Background(), created.Session.ID, "自动标题"); err != nil {
+		t.Fatalf("SetTitleIfUntitled failed: %v", err)
+	}
+	if sess, _ := sessionRepo.GetSession(context.Background(), created.Session.ID); sess.Title != "" {
+		t.Errorf("cleared title should stay empty, got %q", sess.Title)
+	}
+
+	foreign := &session.Session{UserID: 2, Type: "global"}
+	sessionRepo.CreateSession(context.Background(), foreign)
+	if w := do("GET", "/api/sessions/"+jsonID(foreign.ID), ""); w.Code != http.StatusForbidden {
+		t.Errorf("foreign session: expected 403, got %d", w.Code)
+	}
+	if w := do("GET", path, ""); w.Code != http.StatusOK {
+		t.Errorf("get session: %d %s", w.Code, w.Body.String())
+	}
+}
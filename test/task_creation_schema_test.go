@@ -0,0 +1,213 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"assistant-qisumi/internal/agent"
+	"assistant-qisumi/internal/domain"
+	"assistant-qisumi/internal/jsonschema"
+	"assistant-qisumi/internal/llm"
+	"assistant-qisumi/internal/task"
+)
+
+// creationReply 任务创建模拟客户端的一次回复：err 不为空时返回错误，toolArgs 不为空时以 create_task 工具调用返回
+type creationReply struct {
+	content  string
+	toolArgs string
+	err      error
+}
+
+// creationLLMClient 按顺序返回预设回复并记录每次请求
+type creationLLMClient struct {
+	replies  []creationReply
+	requests []llm.ChatRequest
+}
+
+func (m *creationLLMClient) Chat(ctx context.Context, cfg llm.Config, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	reply := m.replies[len(m.requests)]
+	m.requests = append(m.requests, req)
+	if reply.err != nil {
+		return nil, reply.err
+	}
+	msg := llm.ChatMessage{Role: "assistant", Content: reply.content}
+	if reply.toolArgs != "" {
+		msg.ToolCalls = []llm.ToolCall{{ID: "call_9", Type: "function", Function: llm.ToolCallFunc{Name: "create_task", Arguments: reply.toolArgs}}}
+	}
+	resp := &llm.ChatResponse{}
+	resp.Choices = append(resp.Choices, struct {
+		Message      llm.ChatMessage `json:"message"`
+		FinishReason string          `json:"finish_reason"`
+	}{Message: msg, FinishReason: "stop"})
+	return resp, nil
+}
+
+const validCreation = `{"title":"准备周会","description":"整理本周进展","due_at":"2025-06-13T10:00:00+08:00","priority":"high","steps":[{"title":"收集进展","detail":"","estimate_minutes":30,"order_index":1},{"title":"制作幻灯片","detail":"","estimate_minutes":60,"order_index":2}]}`
+
+func creationMessages() []llm.Message {
+	return []llm.Message{
+		{Role: "system", Content: "你是任务规划助手"},
+		{Role: "user", Content: "周五上午开周会前准备好材料"},
+	}
+}
+
+func TestJSONSchema_Validate(t *testing.T) {
+	schema := jsonschema.MustParse(domain.TaskCreationSchema)
+
+	if err := schema.Validate([]byte(validCreation)); err != nil {
+		t.Fatalf("valid output rejected: %v", err)
+	}
+
+	err := schema.Validate([]byte(`{"title":"","due_at":5,"priority":"urgent","steps":[{"title":"a","estimate_minutes":30},{"estimate_minutes":"1h"}]}`))
+	var verr *jsonschema.ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+	for _, want := range []string{
+		"title: must be at least 1 characters",
+		"due_at: expected string or null, got integer",
+		`priority: must be one of ["low", "medium", "high"]`,
+		"steps[1].title: is required",
+		"steps[1].estimate_minutes: expected integer, got string",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error should contain %q, got %s", want, err)
+		}
+	}
+
+	strict := jsonschema.MustParse(`{"type":"object","properties":{"n":{"type":"integer","minimum":1}},"additionalProperties":false}`)
+	if err := strict.Validate([]byte(`{"n":1.5,"x":true}`)); err == nil || !strings.Contains(err.Error(), "n: expected integer, got number") || !strings.Contains(err.Error(), "x: is not allowed") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := strict.Validate([]byte(`{"n":1} trailing`)); err == nil {
+		t.Errorf("trailing data should be rejected")
+	}
+}
+
+func TestGenerateTaskCreation_StructuredOutputWithRepair(t *testing.T) {
+	client := &creationLLMClient{replies: []creationReply{
+		{content: `{"title":"准备周会","priority":"urgent","steps":[{"title":"收集进展","estimate_minutes":0}]}`},
+		{content: validCreation},
+	}}
+
+	output, err := task.GenerateTaskCreation(context.Background(), client, llm.Config{Model: "m"}, creationMessages())
+	if err != nil {
+		t.Fatalf("GenerateTaskCreation failed: %v", err)
+	}
+	if output.Title != "准备周会" || len(output.Steps) != 2 || output.DueAt == nil {
+		t.Errorf("unexpected output: %+v", output)
+	}
+
+	if len(client.requests) != 2 {
+		t.Fatalf("expected one repair round, got %d calls", len(client.requests))
+	}
+	first := client.requests[0]
+	if first.ResponseFormat == nil || first.ResponseFormat.Name != "task_creation" || len(first.Tools) != 0 {
+		t.Errorf("openai protocol should use structured output, got %+v", first)
+	}
+	repair := client.requests[1].Messages
+	if len(repair) != 4 || repair[2].Role != "assistant" || repair[3].Role != "user" {
+		t.Fatalf("repair round should replay the output and the errors, got %+v", repair)
+	}
+	for _, want := range []string{"priority: must be one of", "steps[0].estimate_minutes: must be >= 1"} {
+		if !strings.Contains(repair[3].Content, want) {
+			t.Errorf("repair message should contain %q, got %s", want, repair[3].Content)
+		}
+	}
+}
+
+func TestGenerateTaskCreation_ToolFallback(t *testing.T) {
+	// Anthropic 不支持结构化输出，直接强制调用 create_task，校验错误以工具结果反馈
+	client := &creationLLMClient{replies: []creationReply{
+		{toolArgs: `{"title":"准备周会","priority":"high"}`},
+		{toolArgs: validCreation},
+	}}
+	cfg := llm.Config{Model: "m", Protocol: llm.ProtocolAnthropic}
+	if _, err := task.GenerateTaskCreation(context.Background(), client, cfg, creationMessages()); err != nil {
+		t.Fatalf("GenerateTaskCreation failed: %v", err)
+	}
+	first := client.requests[0]
+	if first.ResponseFormat != nil || first.ToolChoice != "required" || len(first.Tools) != 1 || first.Tools[0].Function.Name != "create_task" {
+		t.Errorf("expected a forced create_task call, got %+v", first)
+	}
+	repair := client.requests[1].Messages
+	if last := repair[len(repair)-1]; last.Role != "tool" || last.ToolCallID != "call_9" || !strings.Contains(last.Content, "steps: is required") {
+		t.Errorf("validation errors should be returned as the tool result, got %+v", last)
+	}
+
+	// 提供商拒绝 response_format 时改用工具调用
+	client = &creationLLMClient{replies: []creationReply{
+		{err: &llm.APIError{StatusCode: http.StatusBadRequest, Message: "response_format json_schema is not supported"}},
+		{toolArgs: validCreation},
+	}}
+	if _, err := task.GenerateTaskCreation(context.Background(), client, llm.Config{Model: "m"}, creationMessages()); err != nil {
+		t.Fatalf("GenerateTaskCreation should fall back to the tool, got %v", err)
+	}
+	if client.requests[0].ResponseFormat == nil || client.requests[1].ResponseFormat != nil || client.requests[1].ToolChoice != "required" {
+		t.Errorf("second call should use the create_task tool, got %+v", client.requests[1])
+	}
+}
+
+func TestTaskCreationAgent_GivesUpAfterOneRepair(t *testing.T) {
+	bad := creationReply{content: "好的，我来帮你安排！"}
+	client := &creationLLMClient{replies: []creationReply{bad, bad, bad}}
+
+	_, err := task.GenerateTaskCreation(context.Background(), client, llm.Config{Model: "m"}, creationMessages())
+	if !errors.Is(err, task.ErrInvalidTaskCreation) || len(client.requests) != 2 {
+		t.Fatalf("expected ErrInvalidTaskCreation after one repair, got %v (%d calls)", err, len(client.requests))
+	}
+
+	client = &creationLLMClient{replies: []creationReply{bad, bad}}
+	resp, err := agent.NewTaskCreationAgent(client).Handle(agent.AgentRequest{UserInput: "周会", LLMConfig: llm.Config{Model: "m"}})
+	if err != nil {
+		t.Fatalf("agent should reply instead of failing: %v", err)
+	}
+	if len(resp.TaskPatches) != 0 || !strings.Contains(resp.AssistantMessage, "未能") {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+}
+
+func TestHTTPClient_ResponseFormat(t *testing.T) {
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		body = nil
+		json.Unmarshal(raw, &body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"c1","object":"chat.completion","model":"m","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"{}"}}]}`))
+	}))
+	defer srv.Close()
+
+	req := llm.ChatRequest{
+		Model:          "m",
+		Messages:       creationMessages(),
+		ResponseFormat: &llm.ResponseFormat{Name: "task_creation", Schema: json.RawMessage(domain.TaskCreationSchema)},
+	}
+	client := llm.NewHTTPClient().WithMaxRetries(0)
+	if _, err := client.Chat(context.Background(), llm.Config{BaseURL: srv.URL, APIKey: "k"}, req); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	format, _ := body["response_format"].(map[string]any)
+	spec, _ := format["json_schema"].(map[string]any)
+	if format["type"] != "json_schema" || spec["name"] != "task_creation" || spec["schema"].(map[string]any)["type"] != "object" {
+		t.Errorf("unexpected response_format: %v", body["response_format"])
+	}
+
+	srv2, got := serveFixture(t, "ollama_chat.json", http.StatusOK, nil)
+	if _, err := client.Chat(context.Background(), llm.Config{Protocol: llm.ProtocolOllama, BaseURL: srv2.URL}, req); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if format, _ := got.Body["format"].(map[string]any); format["type"] != "object" {
+		t.Errorf("ollama should receive the schema as format, got %v", got.Body["format"])
+	}
+
+	if _, err := client.Chat(context.Background(), llm.Config{Protocol: llm.ProtocolAnthropic, BaseURL: srv.URL}, req); err == nil {
+		t.Errorf("anthropic should reject response_format")
+	}
+}
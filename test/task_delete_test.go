@@ -0,0 +1,97 @@
+package test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	appdb "assistant-qisumi/internal/db"
+	"assistant-qisumi/internal/domain"
+	"assistant-qisumi/internal/task"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupTaskDeleteDB 准备两个任务 keep 和 doomed，稍后删除 doomed，keep 的关联数据应保留
+func setupTaskDeleteDB(t *testing.T) (db *gorm.DB, keep, doomed *domain.Task) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "delete.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := appdb.AutoMigrate(db); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	keep = &domain.Task{UserID: 1, Title: "保留", Status: "todo"}
+	doomed = &domain.Task{UserID: 1, Title: "删除", Status: "todo"}
+	db.Create(keep)
+	db.Create(doomed)
+	return db, keep, doomed
+}
+
+func countRows(t *testing.T, db *gorm.DB, model any, where string, args ...any) int64 {
+	var n int64
+	if err := db.Model(model).Where(where, args...).Count(&n).Error; err != nil {
+		t.Fatalf("count failed: %v", err)
+	}
+	return n
+}
+
+func deleteTask(t *testing.T, db *gorm.DB, taskID uint64) {
+	if err := task.NewRepository(db).DeleteTask(context.Background(), 1, taskID); err != nil {
+		t.Fatalf("DeleteTask failed: %v", err)
+	}
+}
+
+func TestDeleteTask_RemovesTimeEntries(t *testing.T) {
+	db, keep, doomed := setupTaskDeleteDB(t)
+	start := time.Date(2025, 12, 8, 9, 0, 0, 0, time.UTC)
+	for _, id := range []uint64{keep.ID, doomed.ID} {
+		db.Create(&domain.TimeEntry{UserID: 1, TaskID: id, StartedAt: start, DurationMin: 30})
+	}
+
+	deleteTask(t, db, doomed.ID)
+	if n := countRows(t, db, &domain.TimeEntry{}, "task_id = ?", doomed.ID); n != 0 {
+		t.Errorf("expected time entries of the deleted task to be removed, got %d", n)
+	}
+	if n := countRows(t, db, &domain.TimeEntry{}, "task_id = ?", keep.ID); n != 1 {
+		t.Errorf("other tasks' time entries should be kept, got %d", n)
+	}
+}
+
+func TestDeleteTask_RemovesDailyPlanItems(t *testing.T) {
+	db, keep, doomed := setupTaskDeleteDB(t)
+	plan := &domain.DailyPlan{UserID: 1, Date: "2025-12-08", Items: []domain.DailyPlanItem{
+		{TaskID: doomed.ID, OrderIndex: 0},
+		{TaskID: keep.ID, OrderIndex: 1},
+	}}
+	db.Create(plan)
+
+	deleteTask(t, db, doomed.ID)
+	if n := countRows(t, db, &domain.DailyPlanItem{}, "task_id = ?", doomed.ID); n != 0 {
+		t.Errorf("expected plan items of the deleted task to be removed, got %d", n)
+	}
+	if n := countRows(t, db, &domain.DailyPlanItem{}, "plan_id = ?", plan.ID); n != 1 {
+		t.Errorf("other plan items should be kept, got %d", n)
+	}
+}
+
+func TestDeleteTask_RemovesSessionSummaries(t *testing.T) {
+	db, keep, doomed := setupTaskDeleteDB(t)
+	var sessionIDs []uint64
+	for _, id := range []uint64{keep.ID, doomed.ID} {
+		sess := &domain.Session{UserID: 1, TaskID: &id, Type: "task"}
+		db.Create(sess)
+		db.Create(&domain.SessionSummary{SessionID: sess.ID, Content: "摘要", UpToMessageID: 1})
+		sessionIDs = append(sessionIDs, sess.ID)
+	}
+
+	deleteTask(t, db, doomed.ID)
+	if n := countRows(t, db, &domain.SessionSummary{}, "session_id = ?", sessionIDs[1]); n != 0 {
+		t.Errorf("expected the summary of the deleted task's session to be removed, got %d", n)
+	}
+	if n := countRows(t, db, &domain.SessionSummary{}, "session_id = ?", sessionIDs[0]); n != 1 {
+		t.Errorf("other sessions' summaries should be kept, got %d", n)
+	}
+}
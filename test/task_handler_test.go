@@ -6,9 +6,11 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"assistant-qisumi/internal/auth"
 	"assistant-qisumi/internal/db"
+	"assistant-qisumi/internal/domain"
 	internalHTTP "assistant-qisumi/internal/http"
 	"assistant-qisumi/internal/session"
 	"assistant-qisumi/internal/task"
@@ -34,8 +36,10 @@ func setupTaskTest(t *testing.T) (*task.Service, *session.Repository, *auth.LLMS
         is_focus_today BOOLEAN DEFAULT FALSE,
         due_at DATETIME,
         created_from TEXT,
+        tags TEXT,
         created_at DATETIME,
-        updated_at DATETIME
+        updated_at DATETIME,
+        completed_at DATETIME
     )`)
 
 	gormDB.Exec(`CREATE TABLE task_steps (
@@ -50,7 +54,8 @@ func setupTaskTest(t *testing.T) (*task.Service, *session.Repository, *auth.LLMS
         planned_start DATETIME,
         planned_end DATETIME,
         created_at DATETIME,
-        updated_at DATETIME
+        updated_at DATETIME,
+        completed_at DATETIME
     )`)
 
 	// 迁移 Session 相关表
@@ -145,3 +150,55 @@ func TestTaskHandlerValidation(t *testing.T) {
 		}
 	})
 }
+
+func TestTaskHandler_ZonelessTimesUseUserZone(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skipf("tzdata unavailable: %v", err)
+	}
+	taskSvc, sessionRepo, llmSettingSvc, gormDB := setupTaskTest(t)
+	handler := internalHTTP.NewTaskHandler(taskSvc, sessionRepo, llmSettingSvc)
+
+	router := gin.Default()
+	authGroup := router.Group("/api")
+	authGroup.Use(func(c *gin.Context) {
+		c.Set("userID", uint64(1))
+		c.Request = c.Request.WithContext(domain.WithLocation(c.Request.Context(), shanghai))
+		c.Next()
+	})
+	handler.RegisterRoutes(authGroup)
+
+	post := func(path, body string) {
+		t.Helper()
+		req, _ := http.NewRequest("POST", path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("POST %s: expected status 200, got %d, body: %s", path, w.Code, w.Body.String())
+		}
+	}
+	post("/api/tasks", `{"title":"周报","dueAt":"2025-12-08 18:00","steps":[{"title":"整理数据","plannedStart":"2025-12-08 09:00"}]}`)
+	post("/api/tasks/1/steps", `{"title":"写总结","plannedEnd":"2025-12-08T17:00"}`)
+
+	var stored task.Task
+	if err := gormDB.Preload("Steps").First(&stored, 1).Error; err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	want := time.Date(2025, 12, 8, 18, 0, 0, 0, shanghai)
+	if stored.DueAt == nil || !stored.DueAt.ToTime().Equal(want) {
+		t.Errorf("due_at = %v, want %v", stored.DueAt, want)
+	}
+	if len(stored.Steps) != 2 {
+		t.Fatalf("expected 2 steps, got %d", len(stored.Steps))
+	}
+	want = time.Date(2025, 12, 8, 9, 0, 0, 0, shanghai)
+	if s := stored.Steps[0]; s.PlannedStart == nil || !s.PlannedStart.ToTime().Equal(want) {
+		t.Errorf("planned_start = %v, want %v", s.PlannedStart, want)
+	}
+	want = time.Date(2025, 12, 8, 17, 0, 0, 0, shanghai)
+	if s := stored.Steps[1]; s.PlannedEnd == nil || !s.PlannedEnd.ToTime().Equal(want) {
+		t.Errorf("planned_end = %v, want %v", s.PlannedEnd, want)
+	}
+}
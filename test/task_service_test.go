@@ -6,6 +6,7 @@ import (
 
 	"assistant-qisumi/internal/llm"
 	"assistant-qisumi/internal/task"
+	"assistant-qisumi/internal/timetrack"
 
 	"github.com/glebarez/sqlite"
 	"gorm.io/gorm"
@@ -47,7 +48,7 @@ func setupTaskServiceTestDB(t *testing.T) *gorm.DB {
 		t.Fatalf("failed to connect database: %v", err)
 	}
 
-	err = db.AutoMigrate(&task.Task{}, &task.TaskStep{})
+	err = db.AutoMigrate(&task.Task{}, &task.TaskStep{}, &timetrack.TimeEntry{})
 	if err != nil {
 		t.Fatalf("failed to migrate database: %v", err)
 	}
@@ -0,0 +1,227 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"assistant-qisumi/internal/domain"
+	"assistant-qisumi/internal/task"
+	"assistant-qisumi/internal/timetrack"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTimetrackTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to connect database: %v", err)
+	}
+	if err := db.AutoMigrate(&domain.Task{}, &domain.TaskStep{}, &domain.TimeEntry{}); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	return db
+}
+
+func createTimedTask(t *testing.T, db *gorm.DB, userID uint64, estimates ...int) *domain.Task {
+	t.Helper()
+	tk := &domain.Task{UserID: userID, Title: "计时任务", Status: "todo"}
+	for i, e := range estimates {
+		e := e
+		tk.Steps = append(tk.Steps, domain.TaskStep{Title: "步骤", Status: "todo", OrderIndex: i, EstimateMin: &e})
+	}
+	if err := db.Create(tk).Error; err != nil {
+		t.Fatalf("failed to create task: %v", err)
+	}
+	return tk
+}
+
+func TestTimetrack_StartStopTimer(t *testing.T) {
+	db := setupTimetrackTestDB(t)
+	svc := timetrack.NewService(db)
+	ctx := context.Background()
+	tk := createTimedTask(t, db, 1, 30, 30)
+
+	first, err := svc.StartTimer(ctx, 1, tk.ID, &tk.Steps[0].ID, "")
+	if err != nil {
+		t.Fatalf("StartTimer failed: %v", err)
+	}
+	// 开始新的计时器会先停止旧的
+	second, err := svc.StartTimer(ctx, 1, tk.ID, &tk.Steps[1].ID, "")
+	if err != nil {
+		t.Fatalf("StartTimer failed: %v", err)
+	}
+	running, _ := svc.RunningTimers(ctx, 1)
+	if len(running) != 1 || running[0].ID != second.ID {
+		t.Fatalf("expected only the second timer to run, got %+v", running)
+	}
+	var stored domain.TimeEntry
+	db.First(&stored, first.ID)
+	if stored.EndedAt == nil {
+		t.Error("first timer should have been stopped")
+	}
+
+	stopped, err := svc.StopTimer(ctx, 1, nil, nil)
+	if err != nil || len(stopped) != 1 || stopped[0].ID != second.ID {
+		t.Fatalf("StopTimer = %+v, %v", stopped, err)
+	}
+	if _, err := svc.StopTimer(ctx, 1, nil, nil); !errors.Is(err, timetrack.ErrNoRunningTimer) {
+		t.Errorf("expected ErrNoRunningTimer, got %v", err)
+	}
+
+	// 其他用户的任务不能计时
+	if _, err := svc.StartTimer(ctx, 2, tk.ID, nil, ""); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("expected not found for another user's task, got %v", err)
+	}
+	// 步骤必须属于该任务
+	bogus := uint64(9999)
+	if _, err := svc.StartTimer(ctx, 1, tk.ID, &bogus, ""); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("expected not found for unknown step, got %v", err)
+	}
+}
+
+func TestTimetrack_AutoEntryFromStatusTransition(t *testing.T) {
+	db := setupTimetrackTestDB(t)
+	svc := timetrack.NewService(db)
+	repo := task.NewRepository(db)
+	ctx := context.Background()
+	tk := createTimedTask(t, db, 1, 60)
+	stepID := tk.Steps[0].ID
+
+	inProgress, done := "in_progress", "done"
+	if err := repo.ApplyUpdateStepFields(ctx, 1, tk.ID, stepID, task.UpdateStepFields{Status: &inProgress}); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	var entries []domain.TimeEntry
+	db.Where("step_id = ?", stepID).Find(&entries)
+	if len(entries) != 1 || entries[0].Source != timetrack.SourceAuto || entries[0].EndedAt != nil {
+		t.Fatalf("expected one open auto entry, got %+v", entries)
+	}
+
+	// 模拟步骤已经进行了 90 分钟
+	db.Model(&domain.TimeEntry{}).Where("id = ?", entries[0].ID).Update("started_at", time.Now().Add(-90*time.Minute))
+
+	if err := repo.ApplyUpdateStepFields(ctx, 1, tk.ID, stepID, task.UpdateStepFields{Status: &done}); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	actual, err := svc.ActualMinutes(ctx, 1, tk.ID)
+	if err != nil {
+		t.Fatalf("ActualMinutes failed: %v", err)
+	}
+	if actual[stepID] != 90 {
+		t.Errorf("expected 90 auto minutes, got %d", actual[stepID])
+	}
+
+	// 有手动记录时自动记录只作为兜底，不再计入
+	if _, err := svc.AddManualEntry(ctx, 1, tk.ID, &stepID, time.Time{}, 40, "补录"); err != nil {
+		t.Fatalf("AddManualEntry failed: %v", err)
+	}
+	summary, err := svc.TaskSummary(ctx, 1, tk.ID)
+	if err != nil {
+		t.Fatalf("TaskSummary failed: %v", err)
+	}
+	if summary.ActualMin != 40 || summary.EstimateMin != 60 || summary.Steps[0].ActualMin != 40 {
+		t.Errorf("unexpected summary: %+v", summary)
+	}
+}
+
+func TestTimetrack_StepDoneStopsTimer(t *testing.T) {
+	db := setupTimetrackTestDB(t)
+	svc := timetrack.NewService(db)
+	repo := task.NewRepository(db)
+	ctx := context.Background()
+	tk := createTimedTask(t, db, 1, 30)
+	stepID := tk.Steps[0].ID
+
+	if _, err := svc.StartTimer(ctx, 1, tk.ID, &stepID, ""); err != nil {
+		t.Fatalf("StartTimer failed: %v", err)
+	}
+	done := "done"
+	if err := repo.ApplyUpdateStepFields(ctx, 1, tk.ID, stepID, task.UpdateStepFields{Status: &done}); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	running, _ := svc.RunningTimers(ctx, 1)
+	if len(running) != 0 {
+		t.Errorf("timer should stop when the step is done, got %+v", running)
+	}
+}
+
+func TestTimetrack_WeeklySummary(t *testing.T) {
+	db := setupTimetrackTestDB(t)
+	svc := timetrack.NewService(db)
+	ctx := context.Background()
+	tk := createTimedTask(t, db, 1, 30, 60)
+
+	monday := time.Date(2025, 12, 8, 0, 0, 0, 0, time.Local)
+	if got := timetrack.WeekStart(monday.AddDate(0, 0, 6).Add(23 * time.Hour)); !got.Equal(monday) {
+		t.Fatalf("WeekStart(sunday) = %v, want %v", got, monday)
+	}
+
+	s0, s1 := tk.Steps[0].ID, tk.Steps[1].ID
+	// 上周五的记录不计入本周投入，但计入本周完成步骤的实际耗时
+	svc.AddManualEntry(ctx, 1, tk.ID, &s0, monday.AddDate(0, 0, -3).Add(10*time.Hour), 20, "")
+	svc.AddManualEntry(ctx, 1, tk.ID, &s0, monday.Add(10*time.Hour), 25, "")
+	svc.AddManualEntry(ctx, 1, tk.ID, &s1, monday.AddDate(0, 0, 2).Add(9*time.Hour), 50, "")
+	// 跨越周日午夜的记录只计入本周部分
+	svc.AddManualEntry(ctx, 1, tk.ID, nil, monday.AddDate(0, 0, 7).Add(-30*time.Minute), 60, "")
+
+	db.Model(&domain.TaskStep{}).Where("id = ?", s0).Updates(map[string]any{"status": "done", "completed_at": monday.Add(11 * time.Hour)})
+
+	week, err := svc.WeeklySummary(ctx, 1, monday.AddDate(0, 0, 3))
+	if err != nil {
+		t.Fatalf("WeeklySummary failed: %v", err)
+	}
+	if week.TrackedMin != 25+50+30 {
+		t.Errorf("tracked = %d, want %d", week.TrackedMin, 25+50+30)
+	}
+	if week.DailyMin[0] != 25 || week.DailyMin[2] != 50 || week.DailyMin[6] != 30 {
+		t.Errorf("unexpected daily minutes: %v", week.DailyMin)
+	}
+	if week.CompletedSteps != 1 || week.EstimateMin != 30 || week.ActualMin != 45 {
+		t.Errorf("unexpected completed step stats: %+v", week)
+	}
+	if len(week.Tasks) != 1 || week.Tasks[0].TaskID != tk.ID {
+		t.Errorf("unexpected task rows: %+v", week.Tasks)
+	}
+}
+
+func TestTimetrack_EstimateAccuracy(t *testing.T) {
+	db := setupTimetrackTestDB(t)
+	svc := timetrack.NewService(db)
+	ctx := context.Background()
+	tk := createTimedTask(t, db, 1, 30, 30, 60)
+
+	actuals := []int{45, 30, 120}
+	for i, st := range tk.Steps {
+		stepID := st.ID
+		if _, err := svc.AddManualEntry(ctx, 1, tk.ID, &stepID, time.Time{}, actuals[i], ""); err != nil {
+			t.Fatalf("AddManualEntry failed: %v", err)
+		}
+		if i < 2 {
+			db.Model(&domain.TaskStep{}).Where("id = ?", stepID).Updates(map[string]any{"status": "done", "completed_at": time.Now()})
+		}
+	}
+
+	acc, err := svc.EstimateAccuracy(ctx, 1)
+	if err != nil || acc != nil {
+		t.Fatalf("expected no accuracy with too few samples, got %+v, %v", acc, err)
+	}
+
+	db.Model(&domain.TaskStep{}).Where("id = ?", tk.Steps[2].ID).Updates(map[string]any{"status": "done", "completed_at": time.Now()})
+	acc, err = svc.EstimateAccuracy(ctx, 1)
+	if err != nil || acc == nil {
+		t.Fatalf("EstimateAccuracy = %+v, %v", acc, err)
+	}
+	if acc.Samples != 3 || acc.EstimateMin != 120 || acc.ActualMin != 195 {
+		t.Errorf("unexpected accuracy: %+v", acc)
+	}
+	if acc.MedianRatio != 1.5 {
+		t.Errorf("median ratio = %v, want 1.5", acc.MedianRatio)
+	}
+	if !strings.Contains(acc.Describe(), "1.62") {
+		t.Errorf("description should contain the overall ratio: %s", acc.Describe())
+	}
+}
@@ -0,0 +1,140 @@
+package test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"assistant-qisumi/internal/agent"
+	"assistant-qisumi/internal/llm"
+)
+
+// argsRepairLLMClient 按顺序返回 update_steps 工具调用，用完后返回文本回复，并记录每次请求
+type argsRepairLLMClient struct {
+	calls    []string
+	requests []llm.ChatRequest
+}
+
+func (m *argsRepairLLMClient) Chat(ctx context.Context, cfg llm.Config, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	n := len(m.requests)
+	m.requests = append(m.requests, req)
+	msg := llm.ChatMessage{Role: "assistant", Content: "好的，已更新"}
+	if n < len(m.calls) {
+		msg = llm.ChatMessage{Role: "assistant", ToolCalls: []llm.ToolCall{{
+			ID: "call_" + string(rune('a'+n)), Type: "function",
+			Function: llm.ToolCallFunc{Name: "update_steps", Arguments: m.calls[n]},
+		}}}
+	}
+	resp := &llm.ChatResponse{}
+	resp.Choices = append(resp.Choices, struct {
+		Message      llm.ChatMessage `json:"message"`
+		FinishReason string          `json:"finish_reason"`
+	}{Message: msg})
+	return resp, nil
+}
+
+func TestChatCompletions_InvalidToolArgsAreReturnedToModel(t *testing.T) {
+	invalid := `{"task_id":1,"updates":[{"step_id":2,"fields":{"status":"finished","color":"red"}}]}`
+	valid := `{"task_id":1,"updates":[{"step_id":2,"fields":{"status":"done"}}]}`
+	client := &argsRepairLLMClient{calls: []string{invalid, valid}}
+	handler := agent.NewChatCompletionsHandler(client, agent.NewToolRegistry(nil, nil))
+	req := agent.AgentRequest{UserID: 1, LLMConfig: llm.Config{Model: "m"}}
+	messages := []llm.Message{{Role: "user", Content: "第二步做完了"}}
+
+	reply, patches, err := handler.HandleChatCompletions(context.Background(), req, messages, []string{"update_steps"})
+	if err != nil {
+		t.Fatalf("HandleChatCompletions failed: %v", err)
+	}
+	if reply != "好的，已更新" || len(patches) != 1 || patches[0].UpdateStep == nil || patches[0].UpdateStep.StepID != 2 {
+		t.Fatalf("only the corrected call should produce a patch, got reply=%q patches=%+v", reply, patches)
+	}
+
+	if len(client.requests) != 3 {
+		t.Fatalf("expected 3 llm calls, got %d", len(client.requests))
+	}
+	if client.requests[1].ToolChoice != "auto" || client.requests[2].ToolChoice != "none" {
+		t.Errorf("model should be allowed to retry the tool once, got %q then %q", client.requests[1].ToolChoice, client.requests[2].ToolChoice)
+	}
+	toolMsg := client.requests[1].Messages[len(client.requests[1].Messages)-1]
+	for _, want := range []string{"invalid arguments for update_steps", "updates[0].fields.status: must be one of", "updates[0].fields.color: is not allowed"} {
+		if toolMsg.Role != "tool" || toolMsg.ToolCallID != "call_a" || !strings.Contains(toolMsg.Content, want) {
+			t.Errorf("tool error should contain %q, got %+v", want, toolMsg)
+		}
+	}
+
+	// 修正后仍然错误：不再允许调用工具，也不产生 patch
+	client = &argsRepairLLMClient{calls: []string{invalid, `{"task_id":1}`}}
+	handler = agent.NewChatCompletionsHandler(client, agent.NewToolRegistry(nil, nil))
+	reply, patches, err = handler.HandleChatCompletions(context.Background(), req, messages, []string{"update_steps"})
+	if err != nil {
+		t.Fatalf("HandleChatCompletions failed: %v", err)
+	}
+	if len(patches) != 0 || len(client.requests) != 3 || client.requests[2].ToolChoice != "none" {
+		t.Errorf("expected no patches after a failed retry, got %d patches in %d calls", len(patches), len(client.requests))
+	}
+	if last := client.requests[2].Messages[len(client.requests[2].Messages)-1]; !strings.Contains(last.Content, "updates: is required") {
+		t.Errorf("second tool error should list the missing field, got %s", last.Content)
+	}
+}
+
+func TestChatCompletions_UndecodableToolArgsAreReturnedToModel(t *testing.T) {
+	req := agent.AgentRequest{UserID: 1, LLMConfig: llm.Config{Model: "m"}}
+	messages := []llm.Message{{Role: "user", Content: "第二步做完了"}}
+	valid := `{"task_id":1,"updates":[{"step_id":2,"fields":{"status":"done"}}]}`
+
+	for _, tc := range []struct {
+		name, args, want string
+	}{
+		{"negative id", `{"task_id":1,"updates":[{"step_id":-2,"fields":{"status":"done"}}]}`, "updates[0].step_id: must be"},
+		{"id overflows uint64", `{"task_id":1,"updates":[{"step_id":18446744073709551616,"fields":{"status":"done"}}]}`, "invalid arguments for update_steps"},
+		{"unknown time", `{"task_id":1,"updates":[{"step_id":2,"fields":{"planned_start":"whenever"}}]}`, "planned_start: cannot understand time"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &argsRepairLLMClient{calls: []string{tc.args, valid}}
+			handler := agent.NewChatCompletionsHandler(client, agent.NewToolRegistry(nil, nil))
+			_, patches, err := handler.HandleChatCompletions(context.Background(), req, messages, []string{"update_steps"})
+			if err != nil {
+				t.Fatalf("HandleChatCompletions failed: %v", err)
+			}
+			if len(patches) != 1 || len(client.requests) != 3 || client.requests[1].ToolChoice != "auto" {
+				t.Fatalf("the model should get a repair round, got %d patches in %d calls", len(patches), len(client.requests))
+			}
+			if toolMsg := client.requests[1].Messages[len(client.requests[1].Messages)-1]; !strings.Contains(toolMsg.Content, tc.want) {
+				t.Errorf("tool error should contain %q, got %s", tc.want, toolMsg.Content)
+			}
+		})
+	}
+}
+
+func TestChatCompletions_ToolsOutsideTheOfferedSetAreRejected(t *testing.T) {
+	client := &scriptedToolLLMClient{
+		rounds: [][]llm.ToolCallFunc{{
+			// 已注册但本次没有提供给模型的工具
+			{Name: "create_task", Arguments: `{"title":"偷偷建的任务"}`},
+			// 没有注册的工具
+			{Name: "drop_tables", Arguments: `{}`},
+			{Name: "update_steps", Arguments: `{"task_id":1,"updates":[{"step_id":2,"fields":{"status":"done"}}]}`},
+		}},
+		reply: "好的，已更新",
+	}
+	handler := agent.NewChatCompletionsHandler(client, agent.NewToolRegistry(nil, nil))
+	req := agent.AgentRequest{UserID: 1, LLMConfig: llm.Config{Model: "m"}}
+	messages := []llm.Message{{Role: "user", Content: "第二步做完了"}}
+
+	_, patches, err := handler.HandleChatCompletions(context.Background(), req, messages, []string{"update_steps"})
+	if err != nil {
+		t.Fatalf("HandleChatCompletions failed: %v", err)
+	}
+	if len(patches) != 1 || patches[0].UpdateStep == nil {
+		t.Fatalf("only the offered tool should produce a patch, got %+v", patches)
+	}
+	if len(client.requests) != 2 || client.requests[1].ToolChoice != "auto" {
+		t.Fatalf("the model should get a repair round, got %d calls", len(client.requests))
+	}
+	toolMsgs := client.requests[1].Messages[len(client.requests[1].Messages)-3:]
+	for i, name := range []string{"create_task", "drop_tables"} {
+		if !strings.Contains(toolMsgs[i].Content, "tool "+name+" is not available") || !strings.Contains(toolMsgs[i].Content, "update_steps") {
+			t.Errorf("call to %s should be answered with a tool error, got %s", name, toolMsgs[i].Content)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"assistant-qisumi/internal/agent"
+	"assistant-qisumi/internal/jsonschema"
+	"assistant-qisumi/internal/llm"
+)
+
+func TestToolRegistry_Definitions(t *testing.T) {
+	registry := agent.NewToolRegistry(nil, nil)
+	names := strings.Join(registry.Names(), ",")
+	for _, want := range []string{"update_task", "update_steps", "add_steps", "add_dependencies", "mark_tasks_focus_today", "start_timer", "stop_timer", "schedule_steps"} {
+		if !strings.Contains(names, want) {
+			t.Errorf("tool %s should be registered, got %s", want, names)
+		}
+	}
+
+	if _, err := registry.Definitions([]string{"update_task", "drop_database"}); err == nil {
+		t.Errorf("unknown tool names should be rejected")
+	}
+	tools, err := registry.Definitions([]string{"add_steps"})
+	if err != nil {
+		t.Fatalf("Definitions failed: %v", err)
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(tools[0].Function.Parameters, &schema); err != nil {
+		t.Fatalf("schema should be valid JSON: %v", err)
+	}
+	steps := schema["properties"].(map[string]any)["steps"].(map[string]any)["items"].(map[string]any)
+	if required, _ := json.Marshal(steps["required"]); string(required) != `["title"]` || steps["additionalProperties"] != false {
+		t.Errorf("unexpected step schema: %v", steps)
+	}
+}
+
+func TestToolRegistry_SnakeCaseFieldsReachPatches(t *testing.T) {
+	registry := agent.NewToolRegistry(nil, nil)
+	shanghai, _ := time.LoadLocation("Asia/Shanghai")
+	req := agent.AgentRequest{Now: time.Date(2025, 12, 8, 10, 0, 0, 0, shanghai)}
+
+	patches, err := registry.TaskPatches(req, llm.ToolCall{Function: llm.ToolCallFunc{
+		Name: "update_task", Arguments: `{"task_id":3,"fields":{"due_at":"2025-12-08T20:00:00","priority":"high"}}`,
+	}})
+	if err != nil {
+		t.Fatalf("TaskPatches failed: %v", err)
+	}
+	fields := patches[0].UpdateTask.Fields
+	if fields.DueAt == nil || *fields.DueAt != "2025-12-08T20:00:00+08:00" || fields.Priority == nil || *fields.Priority != "high" {
+		t.Errorf("due_at and priority should be applied, got %+v", fields)
+	}
+
+	patches, err = registry.TaskPatches(req, llm.ToolCall{Function: llm.ToolCallFunc{
+		Name: "update_steps", Arguments: `{"task_id":3,"updates":[{"step_id":7,"fields":{"estimate_minutes":45,"blocking_reason":"等审批"}}]}`,
+	}})
+	if err != nil {
+		t.Fatalf("TaskPatches failed: %v", err)
+	}
+	step := patches[0].UpdateStep.Fields
+	if step.EstimateMin == nil || *step.EstimateMin != 45 || step.BlockingReason == nil || *step.BlockingReason != "等审批" {
+		t.Errorf("estimate_minutes and blocking_reason should be applied, got %+v", step)
+	}
+
+	// 没有执行逻辑的工具返回成功
+	exec, _ := registry.Executor("start_timer")
+	out, err := exec.Execute(context.Background(), agent.AgentRequest{}, `{"task_id":3}`)
+	if err != nil || out.(map[string]interface{})["success"] != true {
+		t.Errorf("unexpected start_timer result: %v, %v", out, err)
+	}
+}
+
+func TestToolRegistry_NormalizesTimeArguments(t *testing.T) {
+	registry := agent.NewToolRegistry(nil, nil)
+	shanghai, _ := time.LoadLocation("Asia/Shanghai")
+	// 2025-12-08 是周一
+	req := agent.AgentRequest{Now: time.Date(2025, 12, 8, 10, 0, 0, 0, shanghai)}
+	patches := func(name, args string) []agent.TaskPatch {
+		t.Helper()
+		out, err := registry.TaskPatches(req, llm.ToolCall{Function: llm.ToolCallFunc{Name: name, Arguments: args}})
+		if err != nil {
+			t.Fatalf("%s: TaskPatches failed: %v", name, err)
+		}
+		return out
+	}
+
+	// 创建、更新任务和更新步骤都按同一规则解析：自然语言相对 req.Now，没有时刻时使用各字段的默认时刻
+	if due := patches("create_task", `{"title":"交报告","due_at":"明天下午3点"}`)[0].CreateTask.DueAt; due == nil || *due != "2025-12-09T15:00:00+08:00" {
+		t.Errorf("create_task due_at = %v", due)
+	}
+	if due := patches("update_task", `{"task_id":3,"fields":{"due_at":"下周五"}}`)[0].UpdateTask.Fields.DueAt; due == nil || *due != "2025-12-19T23:59:00+08:00" {
+		t.Errorf("update_task due_at = %v", due)
+	}
+	step := patches("update_steps", `{"task_id":3,"updates":[{"step_id":7,"fields":{"planned_start":"明天","planned_end":"2025-12-09T12:00:00Z"}}]}`)[0].UpdateStep.Fields
+	if step.PlannedStart == nil || *step.PlannedStart != "2025-12-09T09:00:00+08:00" || step.PlannedEnd == nil || *step.PlannedEnd != "2025-12-09T12:00:00Z" {
+		t.Errorf("update_steps planned times = %v, %v", step.PlannedStart, step.PlannedEnd)
+	}
+	// 空字符串表示清空
+	if due := patches("update_task", `{"task_id":3,"fields":{"due_at":""}}`)[0].UpdateTask.Fields.DueAt; due == nil || *due != "" {
+		t.Errorf("empty due_at should clear the deadline, got %v", due)
+	}
+
+	if _, err := registry.TaskPatches(req, llm.ToolCall{Function: llm.ToolCallFunc{
+		Name: "create_task", Arguments: `{"title":"交报告","due_at":"等有空再说"}`,
+	}}); err == nil || !strings.Contains(err.Error(), "due_at") {
+		t.Errorf("expected an error naming due_at, got %v", err)
+	}
+}
+
+func TestJSONSchema_Reflect(t *testing.T) {
+	type item struct {
+		Name string `json:"name"`
+	}
+	type args struct {
+		ID     uint64   `json:"id" description:"ID"`
+		Mode   *string  `json:"mode" enum:"a,b"`
+		Note   string   `json:"note,omitempty"`
+		Days   []int    `json:"days,omitempty" minimum:"1" maximum:"7"`
+		Items  []item   `json:"items"`
+		Skip   string   `json:"-"`
+		Weight *float64 `json:"weight"`
+	}
+	schema := jsonschema.Reflect(args{})
+
+	if strings.Join(schema.Required, ",") != "id,items" {
+		t.Errorf("unexpected required fields: %v", schema.Required)
+	}
+	if _, ok := schema.Properties["Skip"]; ok {
+		t.Errorf("fields tagged json:\"-\" should be skipped")
+	}
+	for _, tc := range []struct {
+		doc  string
+		want string
+	}{
+		{`{"id":1,"items":[],"mode":null,"weight":0.5}`, ""},
+		{`{"id":1,"items":[{"name":"x"}],"mode":"c"}`, "mode: must be one of"},
+		{`{"id":1,"items":[{}],"days":[0]}`, "days[0]: must be >= 1"},
+		{`{"id":1,"items":[{}]}`, "items[0].name: is required"},
+		{`{"id":-1.5,"items":[],"extra":1}`, "extra: is not allowed"},
+	} {
+		err := schema.Validate([]byte(tc.doc))
+		if tc.want == "" {
+			if err != nil {
+				t.Errorf("%s should be valid, got %v", tc.doc, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tc.doc, tc.want, err)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"assistant-qisumi/internal/agent"
+	"assistant-qisumi/internal/auth"
+	"assistant-qisumi/internal/domain"
+	internalHTTP "assistant-qisumi/internal/http"
+	"assistant-qisumi/internal/llm"
+	"assistant-qisumi/internal/session"
+	"assistant-qisumi/internal/task"
+	"assistant-qisumi/internal/usage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// usageLLMClient 返回固定 token 用量的响应
+type usageLLMClient struct {
+	usage llm.Usage
+}
+
+func (m *usageLLMClient) Chat(ctx context.Context, cfg llm.Config, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	resp, _ := (&MockAgentLLMClient{}).Chat(ctx, cfg, req)
+	resp.Usage = m.usage
+	return resp, nil
+}
+
+func setupUsageTest(t *testing.T, prices usage.PriceTable) (*usage.Service, *gorm.DB) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to connect database: %v", err)
+	}
+	if err := db.AutoMigrate(&domain.User{}, &domain.LLMUsage{}); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	return usage.NewService(usage.NewRepository(db), prices, "USD"), db
+}
+
+func TestPriceTable(t *testing.T) {
+	prices, err := usage.ParsePriceTable([]byte(`{"gpt-4o": {"input": 2.5, "output": 10}, "GPT-*": {"input": 1, "output": 2}, "gpt-4o-*": {"input": 0.15, "output": 0.6}, "*": {"input": 0.1, "output": 0.1}}`))
+	if err != nil {
+		t.Fatalf("ParsePriceTable failed: %v", err)
+	}
+	cases := map[string]float64{
+		"gpt-4o":      2.5,  // 完整模型名
+		"gpt-4o-mini": 0.15, // 最长前缀
+		"GPT-3.5":     1,    // 忽略大小写
+		"qwen-plus":   0.1,  // 兜底价格
+	}
+	for model, input := range cases {
+		if p, ok := prices.Lookup(model); !ok || p.Input != input {
+			t.Errorf("Lookup(%s) = %+v, want input %v", model, p, input)
+		}
+	}
+
+	cost := prices.Cost("gpt-4o", llm.Usage{PromptTokens: 1000, CompletionTokens: 500})
+	if math.Abs(cost-0.0075) > 1e-9 {
+		t.Errorf("unexpected cost %v", cost)
+	}
+	if c := (usage.PriceTable{}).Cost("gpt-4o", llm.Usage{PromptTokens: 1000}); c != 0 {
+		t.Errorf("unpriced model should cost 0, got %v", c)
+	}
+	if _, err := usage.ParsePriceTable([]byte(`{"m": {"input": -1}}`)); err == nil {
+		t.Error("negative prices should be rejected")
+	}
+}
+
+func TestMeteredClient_RecordsAndReportsUsage(t *testing.T) {
+	svc, db := setupUsageTest(t, usage.PriceTable{"m1": {Input: 1, Output: 2}})
+	inner := &usageLLMClient{usage: llm.Usage{PromptTokens: 1000, CompletionTokens: 200, ReasoningTokens: 50, TotalTokens: 1200}}
+	client := llm.NewMeteredClient(inner, svc)
+
+	sessionID := uint64(7)
+	ctx := llm.WithCallInfo(context.Background(), llm.CallInfo{UserID: 1, SessionID: &sessionID, Agent: "executor"})
+	cfg := llm.Config{BaseURL: "https://primary", Model: "m1", IsDefault: true}
+	for i := 0; i < 2; i++ {
+		if _, err := client.Chat(ctx, cfg, llm.ChatRequest{Model: "m1"}); err != nil {
+			t.Fatalf("Chat failed: %v", err)
+		}
+	}
+	if _, err := client.Chat(llm.WithAgent(ctx, "router"), cfg, llm.ChatRequest{Model: "m2"}); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	client.Chat(llm.WithCallInfo(context.Background(), llm.CallInfo{UserID: 2, Agent: "planner"}), cfg, llm.ChatRequest{Model: "m1"})
+	// 没有用户归属的调用不记录
+	client.Chat(context.Background(), cfg, llm.ChatRequest{Model: "m1"})
+
+	var rows []domain.LLMUsage
+	db.Order("id").Find(&rows)
+	if len(rows) != 4 {
+		t.Fatalf("expected 4 usage rows, got %d", len(rows))
+	}
+	if r := rows[0]; r.SessionID == nil || *r.SessionID != 7 || r.Provider != "https://primary" || !r.DefaultKey || r.ReasoningTokens != 50 {
+		t.Errorf("unexpected usage row: %+v", r)
+	}
+	if math.Abs(rows[0].Cost-0.0014) > 1e-9 || rows[2].Cost != 0 {
+		t.Errorf("unexpected costs: %v, %v", rows[0].Cost, rows[2].Cost)
+	}
+
+	userID := uint64(1)
+	from := time.Now().Add(-time.Hour)
+	to := time.Now().Add(time.Hour)
+	report, err := svc.Report(context.Background(), usage.Query{UserID: &userID, From: from, To: to, GroupBy: usage.GroupByAgent})
+	if err != nil {
+		t.Fatalf("Report failed: %v", err)
+	}
+	if len(report.Items) != 2 || report.Items[0].Key != "executor" || report.Items[0].Calls != 2 || report.Total.TotalTokens != 3600 || report.Currency != "USD" {
+		t.Errorf("unexpected agent report: %+v", report)
+	}
+
+	report, _ = svc.Report(context.Background(), usage.Query{From: from, To: to, GroupBy: usage.GroupByModel})
+	if len(report.Items) != 2 || report.Total.Calls != 4 {
+		t.Errorf("unexpected model report: %+v", report)
+	}
+	report, _ = svc.Report(context.Background(), usage.Query{UserID: &userID, From: from, To: to, GroupBy: usage.GroupByDay, Location: time.UTC})
+	if len(report.Items) == 0 || report.Total.Calls != 3 {
+		t.Errorf("unexpected day report: %+v", report)
+	}
+
+	if _, err := svc.Report(context.Background(), usage.Query{UserID: &userID, From: from, To: to, GroupBy: usage.GroupByUser}); err == nil {
+		t.Error("group_by=user should be admin only")
+	}
+	if _, err := svc.Report(context.Background(), usage.Query{From: to, To: from, GroupBy: usage.GroupByAgent}); err == nil {
+		t.Error("from after to should be rejected")
+	}
+}
+
+func TestUsageReport_AggregatesInDatabase(t *testing.T) {
+	svc, db := setupUsageTest(t, nil)
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skipf("tzdata unavailable: %v", err)
+	}
+	at := func(d, h, min int) time.Time { return time.Date(2025, 12, d, h, min, 0, 0, shanghai).Local() }
+	db.Create(&domain.LLMUsage{UserID: 1, Agent: "executor", Model: "m1", TotalTokens: 10, CreatedAt: at(8, 23, 30)})
+	db.Create(&domain.LLMUsage{UserID: 1, Agent: "", Model: "m1", TotalTokens: 20, CreatedAt: at(9, 0, 30)})
+	db.Create(&domain.LLMUsage{UserID: 2, Agent: "unknown", Model: "m2", TotalTokens: 40, Cost: 0.5, CreatedAt: at(10, 12, 0)})
+	db.Create(&domain.LLMUsage{UserID: 2, Agent: "planner", Model: "m2", TotalTokens: 80, CreatedAt: at(11, 0, 0)})
+
+	from, to := at(8, 0, 0), at(11, 0, 0)
+	report, err := svc.Report(context.Background(), usage.Query{From: from, To: to, GroupBy: usage.GroupByDay, Location: shanghai})
+	if err != nil {
+		t.Fatalf("Report failed: %v", err)
+	}
+	var days []string
+	for _, b := range report.Items {
+		days = append(days, fmt.Sprintf("%s=%d", b.Key, b.TotalTokens))
+	}
+	if got := strings.Join(days, ","); got != "2025-12-08=10,2025-12-09=20,2025-12-10=40" || report.Total.TotalTokens != 70 {
+		t.Errorf("unexpected day buckets %s (total %d)", got, report.Total.TotalTokens)
+	}
+
+	report, _ = svc.Report(context.Background(), usage.Query{From: from, To: to, GroupBy: usage.GroupByAgent})
+	if len(report.Items) != 2 || report.Items[0].Key != "unknown" || report.Items[0].TotalTokens != 60 || report.Items[0].Calls != 2 {
+		t.Errorf("empty agents should merge into unknown: %+v", report.Items)
+	}
+	report, _ = svc.Report(context.Background(), usage.Query{From: from, To: to, GroupBy: usage.GroupByUser})
+	if len(report.Items) != 2 || report.Items[0].Key != "2" || report.Items[1].Key != "1" || report.Items[1].TotalTokens != 30 {
+		t.Errorf("unexpected user buckets: %+v", report.Items)
+	}
+
+	if _, err := svc.Report(context.Background(), usage.Query{From: from.AddDate(-2, 0, 0), To: to, GroupBy: usage.GroupByAgent}); !errors.Is(err, usage.ErrInvalidQuery) {
+		t.Errorf("ranges over %d days should be rejected, got %v", usage.MaxReportDays, err)
+	}
+}
+
+// callInfoAgent 调用 LLM，检查调用归属是否通过 context 传递
+type callInfoAgent struct {
+	client llm.Client
+}
+
+func (a *callInfoAgent) Name() string { return "executor" }
+func (a *callInfoAgent) Handle(req agent.AgentRequest) (*agent.AgentResponse, error) {
+	if _, err := a.client.Chat(req.Context(), req.LLMConfig, llm.ChatRequest{Model: req.LLMConfig.Model}); err != nil {
+		return nil, err
+	}
+	return &agent.AgentResponse{AssistantMessage: "ok"}, nil
+}
+
+func TestAgentService_RecordsUsagePerSessionAndAgent(t *testing.T) {
+	svc, db := setupUsageTest(t, nil)
+	if err := db.AutoMigrate(&domain.Session{}, &domain.Message{}, &domain.Task{}, &domain.TaskStep{},
+		&domain.TaskDependency{}, &domain.UserProfile{}, &domain.TimeEntry{}); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	sess := &domain.Session{UserID: 1, Type: "global"}
+	db.Create(sess)
+
+	client := llm.NewMeteredClient(&usageLLMClient{usage: llm.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}}, svc)
+	agentSvc := agent.NewService(agent.NewSimpleRouter(), []agent.Agent{&callInfoAgent{client: client}},
+		task.NewRepository(db), session.NewRepository(db), nil, db, client)
+	if _, err := agentSvc.HandleUserMessage(context.Background(), 1, sess.ID, "你好", llm.Config{Model: "m1"}); err != nil {
+		t.Fatalf("HandleUserMessage failed: %v", err)
+	}
+
+	var rows []domain.LLMUsage
+	db.Find(&rows)
+	if len(rows) != 1 || rows[0].UserID != 1 || rows[0].SessionID == nil || *rows[0].SessionID != sess.ID || rows[0].Agent != "executor" {
+		t.Errorf("unexpected usage rows: %+v", rows)
+	}
+}
+
+func TestUsageHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	svc, db := setupUsageTest(t, nil)
+	db.Create(&domain.User{ID: 1, Email: "user@example.com", PasswordHash: "x"})
+	db.Create(&domain.User{ID: 2, Email: "boss@example.com", PasswordHash: "x", IsAdmin: true})
+	// 与管理员邮箱只有大小写不同的普通账号不能获得管理员权限
+	db.Create(&domain.User{ID: 3, Email: "Boss@Example.com", PasswordHash: "x"})
+	db.Create(&domain.LLMUsage{UserID: 1, Agent: "executor", Model: "m1", TotalTokens: 100})
+	db.Create(&domain.LLMUsage{UserID: 2, Agent: "planner", Model: "m2", TotalTokens: 50})
+
+	authSvc := auth.NewService(db, nil)
+	handler := internalHTTP.NewUsageHandler(svc)
+
+	router := gin.New()
+	authGroup := router.Group("/api")
+	authGroup.Use(func(c *gin.Context) {
+		id, _ := strconv.ParseUint(c.GetHeader("X-User"), 10, 64)
+		c.Set("userID", id)
+		c.Next()
+	})
+	handler.RegisterRoutes(authGroup)
+	adminGroup := authGroup.Group("/admin")
+	adminGroup.Use(internalHTTP.AdminMiddleware(authSvc))
+	handler.RegisterAdminRoutes(adminGroup)
+
+	do := func(path, user string) (*httptest.ResponseRecorder, usage.Report) {
+		req, _ := http.NewRequest("GET", path, nil)
+		req.Header.Set("X-User", user)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var report usage.Report
+		json.Unmarshal(w.Body.Bytes(), &report)
+		return w, report
+	}
+
+	w, report := do("/api/usage?group_by=model", "1")
+	if w.Code != http.StatusOK || report.Total.TotalTokens != 100 || len(report.Items) != 1 || report.Items[0].Key != "m1" {
+		t.Errorf("unexpected user report %d: %s", w.Code, w.Body.String())
+	}
+	today := time.Now().Format("2006-01-02")
+	if w, report := do("/api/usage?group_by=day&from="+today+"&to="+today, "1"); w.Code != http.StatusOK || report.Total.Calls != 1 {
+		t.Errorf("date range should include today, got %d: %s", w.Code, w.Body.String())
+	}
+	if w, _ := do("/api/usage?group_by=user", "1"); w.Code != http.StatusBadRequest {
+		t.Errorf("group_by=user should be rejected for users, got %d", w.Code)
+	}
+	if w, _ := do("/api/usage?from=bogus", "1"); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid from should be rejected, got %d", w.Code)
+	}
+
+	if w, _ := do("/api/admin/usage", "1"); w.Code != http.StatusForbidden {
+		t.Errorf("non-admin should get 403, got %d", w.Code)
+	}
+	if w, _ := do("/api/admin/usage", "3"); w.Code != http.StatusForbidden {
+		t.Errorf("an email matching the admin's should not grant admin, got %d", w.Code)
+	}
+	w, report = do("/api/admin/usage?group_by=user", "2")
+	if w.Code != http.StatusOK || report.Total.TotalTokens != 150 || len(report.Items) != 2 {
+		t.Errorf("unexpected admin report %d: %s", w.Code, w.Body.String())
+	}
+
+	// 运维把 is_admin 设为 true 后即可访问
+	db.Model(&domain.User{}).Where("id = ?", 1).Update("is_admin", true)
+	if w, report := do("/api/admin/usage?user_id=2", "1"); w.Code != http.StatusOK || report.Total.TotalTokens != 50 {
+		t.Errorf("unexpected filtered admin report %d: %s", w.Code, w.Body.String())
+	}
+}